@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,13 +11,21 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/gigmile/payment-service/internal/application/service"
 	"github.com/gigmile/payment-service/internal/config"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/gigmile/payment-service/internal/infrastructure/logging"
 	"github.com/gigmile/payment-service/internal/infrastructure/messaging"
 	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"github.com/gigmile/payment-service/internal/infrastructure/redisclient"
 	sqlrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/mysql"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
 	"github.com/gigmile/payment-service/internal/interface/http/handler"
+	"github.com/gigmile/payment-service/internal/interface/http/middleware"
 	"github.com/gigmile/payment-service/internal/interface/http/router"
-	"github.com/go-redis/redis/v8"
+	"github.com/gigmile/payment-service/internal/interface/http/server"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -23,22 +33,26 @@ import (
 )
 
 func main() {
-	logger, err := zap.NewProduction()
+	configFile := flag.String("config", "", "path to a YAML or JSON config file (defaults to $CONFIG_FILE)")
+	skipMigrate := flag.Bool("skip-migrate", false, "don't migrate the database at startup (same as MYSQL_SKIP_MIGRATE=true)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load config: %v", err))
+	}
+
+	logger, err := logging.New(logging.Options{
+		Level:    cfg.Log.Level,
+		Encoding: cfg.Log.Encoding,
+		Sampling: cfg.Log.Sampling,
+	})
 	if err != nil {
 		panic(fmt.Sprintf("failed to initialize logger: %v", err))
 	}
 	defer logger.Sync()
 
-	cfg := config.Load()
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&loc=Local",
-		cfg.MySQL.User,
-		cfg.MySQL.Password,
-		cfg.MySQL.Host,
-		cfg.MySQL.Database,
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(mysql.Open(cfg.MySQL.DSN()), &gorm.Config{
 		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
 	})
 	if err != nil {
@@ -51,49 +65,277 @@ func main() {
 	}
 	defer sqlDB.Close()
 
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxOpenConns(cfg.MySQL.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MySQL.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.MySQL.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.MySQL.ConnMaxIdleTime)
 
 	ctx := context.Background()
 	if err := sqlDB.PingContext(ctx); err != nil {
 		logger.Fatal("MySQL ping failed", zap.Error(err))
 	}
 
-	if err := db.AutoMigrate(&persistence.CustomerModel{}, &persistence.PaymentModel{}); err != nil {
-		logger.Fatal("failed to auto-migrate schemas", zap.Error(err))
+	if *skipMigrate || cfg.MySQL.SkipMigrate {
+		logger.Info("skipping startup migration")
+	} else if err := persistence.Migrate(ctx, db, domain.DedupScope(cfg.Payment.DedupScope)); err != nil {
+		logger.Fatal("failed to migrate schema", zap.Error(err))
+	}
+
+	if cfg.MySQL.VerifySchema {
+		version, err := persistence.CheckSchemaVersion(ctx, db)
+		switch {
+		case errors.Is(err, persistence.ErrSchemaNewer):
+			// Expected mid-deploy: migrations run before old pods are replaced
+			logger.Warn("database schema is ahead of this build", zap.Error(err))
+		case err != nil:
+			logger.Fatal("database schema version mismatch", zap.Error(err))
+		default:
+			logger.Info("database schema version verified", zap.Int("version", version))
+		}
 	}
 
 	logger.Info("connected to MySQL successfully", zap.String("host", cfg.MySQL.Host))
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-		PoolSize: cfg.Redis.PoolSize,
+	redisClient, err := redisclient.New(redisclient.Options{
+		Mode:       redisclient.Mode(cfg.Redis.Mode),
+		Addrs:      cfg.Redis.NodeAddrs(),
+		MasterName: cfg.Redis.MasterName,
+		Password:   cfg.Redis.Password,
+		DB:         cfg.Redis.DB,
+		PoolSize:   cfg.Redis.PoolSize,
 	})
+	if err != nil {
+		logger.Fatal("invalid redis configuration", zap.Error(err))
+	}
 
 	if err := redisClient.Ping(ctx).Err(); err != nil {
 		logger.Fatal("failed to connect to Redis", zap.Error(err))
 	}
 	logger.Info("connected to Redis successfully")
 
-	repos := sqlrepository.NewRepositories(db, redisClient, logger)
+	repos := sqlrepository.NewRepositories(db, redisClient, sqlrepository.Config{
+		PaymentDedupTTL:        cfg.Redis.PaymentDedupTTL,
+		KeyPrefix:              keyspace.Prefix(cfg.Redis.KeyPrefix),
+		RejectInvalidCustomers: cfg.MySQL.RejectInvalidCustomers,
+		TrackCustomerAccess:    cfg.CacheWarm.Refresh,
+		DisableCache:           !cfg.Redis.CacheEnabled,
+		DedupScope:             domain.DedupScope(cfg.Payment.DedupScope),
+	}, logger)
 
-	eventPublisher := messaging.NewRedisEventPublisher(redisClient, logger)
-	logger.Info("event publishing enabled")
+	if cfg.CacheWarm.Enabled {
+		sqlrepository.NewCustomerCacheWarmer(db, redisClient, sqlrepository.CacheWarmConfig{
+			BatchSize:    cfg.CacheWarm.BatchSize,
+			Concurrency:  cfg.CacheWarm.Concurrency,
+			MaxCustomers: cfg.CacheWarm.MaxCustomers,
+			KeyPrefix:    keyspace.Prefix(cfg.Redis.KeyPrefix),
+		}, logger).Start(ctx)
+	}
+	if cfg.CacheWarm.Refresh && cfg.CacheWarm.RefreshRunner == "api" {
+		sqlrepository.NewCustomerCacheRefresher(db, redisClient, sqlrepository.CacheRefreshConfig{
+			Interval:  cfg.CacheWarm.RefreshInterval,
+			Window:    cfg.CacheWarm.RefreshWindow,
+			Lead:      cfg.CacheWarm.RefreshLead,
+			MaxPerRun: cfg.CacheWarm.RefreshMaxPerRun,
+			KeyPrefix: keyspace.Prefix(cfg.Redis.KeyPrefix),
+		}, logger).Start(ctx)
+	}
 
-	handlers := handler.NewHandlers(repos, eventPublisher, logger)
-	r := router.NewRouter(handlers, logger)
+	var schemas *messaging.SchemaRegistry
+	if cfg.Events.SchemaValidation {
+		schemas, err = messaging.NewSchemaRegistry()
+		if err != nil {
+			logger.Fatal("failed to load event schemas", zap.Error(err))
+		}
+	}
+	retention, err := messaging.ParseStreamRetention(messaging.StreamRetention{
+		MaxLen: cfg.Events.StreamMaxLen,
+		MaxAge: cfg.Events.StreamMaxAge,
+		Approx: cfg.Events.StreamApproxTrim,
+	}, cfg.Events.StreamRetention)
+	if err != nil {
+		logger.Fatal("invalid EVENT_STREAM_RETENTION", zap.Error(err))
+	}
+
+	publisherOpts := []messaging.PublisherOption{
+		messaging.WithStreamRetention(retention),
+		messaging.WithPublishDeduplication(cfg.Events.PublishDedupTTL),
+	}
+	if schemas != nil {
+		publisherOpts = append(publisherOpts, messaging.WithSchemaValidation(schemas))
+	}
+
+	var eventPublisher domain.EventPublisher
+	switch cfg.Events.Backend {
+	case "kafka":
+		var kafkaOpts []messaging.KafkaPublisherOption
+		if schemas != nil {
+			kafkaOpts = append(kafkaOpts, messaging.WithKafkaSchemaValidation(schemas))
+		}
+		kafkaPublisher := messaging.NewKafkaEventPublisher(messaging.NewKafkaWriterFactory(cfg.Events.KafkaBrokers), cfg.Events.KafkaTopicPrefix, logger, kafkaOpts...)
+		defer kafkaPublisher.Close()
+		eventPublisher = kafkaPublisher
+	default:
+		eventPublisher = messaging.NewRedisEventPublisher(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix), logger, publisherOpts...)
+	}
+	var eventLog domain.EventLog
+	if cfg.Events.LogEnabled {
+		eventLog = repos.EventLog
+	}
+	logger.Info("event publishing enabled",
+		zap.String("backend", cfg.Events.Backend),
+		zap.Bool("schema_validation", cfg.Events.SchemaValidation),
+		zap.Bool("sync", cfg.Events.PublishSync),
+		zap.Bool("event_log", cfg.Events.LogEnabled),
+	)
+
+	txRefRule, err := service.ParseTransactionReferenceRule(cfg.Payment.TxRefNormalization)
+	if err != nil {
+		logger.Fatal("invalid PAYMENT_TX_REF_NORMALIZATION", zap.Error(err))
+	}
+	defaultCurrency, err := domain.LookupCurrency(cfg.Payment.DefaultCurrency)
+	if err != nil {
+		logger.Fatal("invalid PAYMENT_DEFAULT_CURRENCY", zap.Error(err))
+	}
+	paymentTimezone, err := time.LoadLocation(cfg.Payment.Timezone)
+	if err != nil {
+		logger.Fatal("invalid PAYMENT_TIMEZONE", zap.Error(err))
+	}
+	overpaymentPolicy, err := service.ParseOverpaymentPolicy(cfg.Payment.OverpaymentPolicy)
+	if err != nil {
+		logger.Fatal("invalid PAYMENT_OVERPAYMENT_POLICY", zap.Error(err))
+	}
+
+	velocityLimits := service.VelocityLimits{
+		MaxPayments: cfg.Payment.VelocityMaxPayments,
+		MaxAmount:   cfg.Payment.VelocityMaxAmount,
+	}
+	var velocityTracker domain.VelocityTracker
+	if velocityLimits.MaxPayments > 0 || velocityLimits.MaxAmount > 0 {
+		velocityTracker = redisrepository.NewRedisVelocityTracker(redisClient, cfg.Payment.VelocityWindow, keyspace.Prefix(cfg.Redis.KeyPrefix))
+	}
+
+	var outcomeLog domain.PaymentOutcomeLog
+	if cfg.Report.DailyEnabled {
+		outcomeLog = redisrepository.NewRedisPaymentOutcomeLog(redisClient, cfg.Report.OutcomeRetention, keyspace.Prefix(cfg.Redis.KeyPrefix))
+	}
+
+	customerIDFormat, err := dto.NewCustomerIDFormat(cfg.Server.CustomerIDPattern)
+	if err != nil {
+		logger.Fatal("invalid CUSTOMER_ID_PATTERN", zap.Error(err))
+	}
+
+	var responseCache *middleware.ResponseCache
+	var viewInvalidator service.CustomerViewInvalidator
+	if len(cfg.Server.ResponseCacheRoutes) > 0 {
+		responseCache, err = middleware.NewResponseCache(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix),
+			cfg.Server.ResponseCacheTTL, cfg.Server.ResponseCacheRoutes, logger)
+		if err != nil {
+			logger.Fatal("invalid HTTP_RESPONSE_CACHE_ROUTES", zap.Error(err))
+		}
+		viewInvalidator = responseCache
+		logger.Info("response caching enabled",
+			zap.Strings("routes", cfg.Server.ResponseCacheRoutes),
+			zap.Duration("ttl", cfg.Server.ResponseCacheTTL),
+		)
+	}
+
+	maintenance := middleware.NewMaintenanceMode(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix),
+		cfg.Server.MaintenanceRefresh, cfg.Server.MaintenanceRetryAfter, logger)
+
+	featureFlags := redisrepository.NewRedisFeatureFlags(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix),
+		cfg.Payment.FeatureFlagRefresh, logger)
+
+	overpaymentLimit := domain.OverpaymentLimit{
+		Enabled: cfg.Payment.OverpaymentLimitEnabled,
+		Percent: cfg.Payment.OverpaymentLimitPercent,
+		Amount:  cfg.Payment.OverpaymentLimitAmount,
+	}
+
+	var atomicApplier domain.AtomicPaymentApplier
+	if cfg.Payment.AtomicApply {
+		applier, err := redisrepository.NewRedisPaymentApplier(redisClient, cfg.Redis.PaymentDedupTTL,
+			keyspace.Prefix(cfg.Redis.KeyPrefix), domain.DedupScope(cfg.Payment.DedupScope))
+		if err != nil {
+			logger.Fatal("failed to set up atomic payment apply", zap.Error(err))
+		}
+		atomicApplier = applier
+		logger.Warn("atomic payment apply enabled; COMPLETE payments are written to Redis only")
+	}
+
+	pageSizes := service.PageSizes{Default: cfg.Payment.PageSizeDefault, Max: cfg.Payment.PageSizeMax}
+	handlers := handler.NewHandlers(repos, eventPublisher, handler.Config{
+		DisallowUnknownFields: cfg.Server.DisallowUnknownFields,
+		MinimumPaymentAmount:  cfg.Payment.MinimumAmount,
+		SlowPaymentThreshold:  cfg.Payment.SlowThreshold,
+		TxRefRule:             txRefRule,
+		OverpaymentPolicy:     overpaymentPolicy,
+		PayoffDiscountBPS:     cfg.Payment.EarlyPayoffDiscountBPS,
+		OverpaymentLimit:      overpaymentLimit,
+		CustomerIDFormat:      customerIDFormat,
+		Timezone:              paymentTimezone,
+		DefaultCurrency:       defaultCurrency,
+		CurrencySymbol:        cfg.Server.CurrencySymbol,
+		MaxBatchCustomerIDs:   cfg.Server.CustomerBatchMaxIDs,
+		MaxPaymentDateRange:   cfg.Payment.QueryMaxRange,
+		PageSizes:             pageSizes,
+		FeedSettleWindow:      cfg.Payment.FeedSettleWindow,
+		DefaultThreshold:      cfg.Payment.DefaultMissedInstallments,
+		VelocityTracker:       velocityTracker,
+		VelocityLimits:        velocityLimits,
+		ViewInvalidator:       viewInvalidator,
+		Maintenance:           maintenance,
+		EventInspector:        messaging.NewStreamInspector(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix)),
+		EventReclaimer:        messaging.NewStreamReclaimer(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix)),
+		EventClaimMinIdle:     cfg.Events.ClaimMinIdle,
+		EventLog:              eventLog,
+		DedupScope:            domain.DedupScope(cfg.Payment.DedupScope),
+		EventLogSettleWindow:  cfg.Events.LogSettleWindow,
+		FeatureFlags:          featureFlags,
+		Workers:               messaging.NewWorkerHeartbeats(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix), cfg.Worker.HeartbeatTTL),
+		OutcomeLog:            outcomeLog,
+		UploadConcurrency:     cfg.Payment.UploadConcurrency,
+		MaxUploadBytes:        cfg.Payment.UploadMaxBytes,
+		SyncEventPublishing:   cfg.Events.PublishSync,
+		EventMoneyAsStrings:   cfg.Events.MoneyAsStrings,
+		AtomicApplier:         atomicApplier,
+	}, logger)
+	if cfg.Server.AdminToken == "" {
+		logger.Warn("ADMIN_API_TOKEN not set; admin routes will reject all requests")
+	}
+
+	paymentAllowList, err := middleware.NewIPAllowList(cfg.Server.PaymentSourceCIDRs, cfg.Server.TrustedProxyCIDRs, logger)
+	if err != nil {
+		logger.Fatal("invalid PAYMENT_SOURCE_CIDRS or HTTP_TRUSTED_PROXY_CIDRS", zap.Error(err))
+	}
+
+	r := router.NewRouter(handlers, router.Config{
+		ExposeErrorDetails:     cfg.Server.ExposeErrorDetails,
+		PaymentSourceAllowList: paymentAllowList,
+		AdminToken:             cfg.Server.AdminToken,
+		DebugEnabled:           cfg.Server.DebugEnabled,
+		DebugPprof:             cfg.Server.DebugPprof,
+		ResponseCache:          responseCache,
+		Maintenance:            maintenance,
+		MaxPaymentDateRange:    cfg.Payment.QueryMaxRange,
+		MaxPageSize:            cfg.Payment.PageSizeMax,
+		RequestTimeout: middleware.RequestTimeout{
+			Default: cfg.Server.RequestTimeout,
+			Max:     cfg.Server.MaxRequestTimeout,
+		},
+		Idempotency: middleware.NewIdempotency(redisClient, keyspace.Prefix(cfg.Redis.KeyPrefix),
+			cfg.Server.IdempotencyTTL, cfg.Server.IdempotencyWait, cfg.Server.MaxRequestTimeout, logger),
+	}, logger)
 
 	serverAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 	srv := &http.Server{
 		Addr:         serverAddr,
 		Handler:      r,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: server.WriteTimeout(cfg.Server.MaxRequestTimeout),
 		IdleTimeout:  60 * time.Second,
 	}
+	drain := server.NewDrain(srv, logger)
+	drain.LogInterval = cfg.Server.ShutdownLogInterval
 
 	// Start server in a goroutine
 	go func() {
@@ -107,13 +349,23 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	logger.Info("shutting down server...")
+	open, active := drain.Remaining()
+	logger.Info("shutting down server...",
+		zap.Duration("timeout", cfg.Server.ShutdownTimeout),
+		zap.Int("open_connections", open),
+		zap.Int("active_requests", active))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatal("server forced to shutdown", zap.Error(err))
+	// Drain logs the connections it had to cut off; the events below
+	// still get whatever is left of the timeout
+	drain.Shutdown(ctx)
+
+	// Handlers publish events in the background; let those finish before
+	// the deferred database close runs and the process exits
+	if err := handlers.WaitForEvents(ctx); err != nil {
+		logger.Error("timed out waiting for in-flight events", zap.Error(err))
 	}
 
 	logger.Info("server exited")
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/config"
+	"github.com/gigmile/payment-service/internal/infrastructure/logging"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// backfill sets processed_at on COMPLETE payments written before
+// MarkAsProcessed existed. Re-running it only touches rows still missing one.
+func main() {
+	configFile := flag.String("config", "", "path to a YAML or JSON config file (defaults to $CONFIG_FILE)")
+	batchSize := flag.Int("batch-size", 500, "rows to update per statement")
+	source := flag.String("source", persistence.BackfillFromCreatedAt,
+		fmt.Sprintf("column to copy processed_at from (%s or %s)", persistence.BackfillFromCreatedAt, persistence.BackfillFromTransactionDate))
+	timeout := flag.Duration("timeout", time.Hour, "give up if the backfill takes longer than this")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load config: %v", err))
+	}
+
+	logger, err := logging.New(logging.Options{
+		Level:    cfg.Log.Level,
+		Encoding: cfg.Log.Encoding,
+		Sampling: cfg.Log.Sampling,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize logger: %v", err))
+	}
+	defer logger.Sync()
+
+	db, err := gorm.Open(mysql.Open(cfg.MySQL.DSN()), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Warn),
+	})
+	if err != nil {
+		logger.Fatal("failed to connect to MySQL", zap.Error(err))
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Fatal("failed to get underlying sql.DB", zap.Error(err))
+	}
+	defer sqlDB.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	logger.Info("backfilling processed_at",
+		zap.String("host", cfg.MySQL.Host),
+		zap.String("source", *source),
+		zap.Int("batch_size", *batchSize),
+	)
+
+	start := time.Now()
+	updated, err := persistence.BackfillProcessedAt(ctx, db, persistence.BackfillOptions{
+		BatchSize: *batchSize,
+		Source:    *source,
+		OnBatch: func(batch, updated int64) {
+			logger.Info("backfill progress", zap.Int64("batch", batch), zap.Int64("updated", updated))
+		},
+	})
+	if err != nil {
+		logger.Fatal("backfill failed", zap.Error(err),
+			zap.Int64("updated", updated), zap.Duration("elapsed", time.Since(start)))
+	}
+
+	logger.Info("backfill complete",
+		zap.Int64("updated", updated),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+}
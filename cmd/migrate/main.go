@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/config"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/logging"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// migrate applies the schema as a separate deploy step so API startup
+// never blocks on table changes
+func main() {
+	configFile := flag.String("config", "", "path to a YAML or JSON config file (defaults to $CONFIG_FILE)")
+	timeout := flag.Duration("timeout", 30*time.Minute, "give up if migrating takes longer than this")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load config: %v", err))
+	}
+
+	logger, err := logging.New(logging.Options{
+		Level:    cfg.Log.Level,
+		Encoding: cfg.Log.Encoding,
+		Sampling: cfg.Log.Sampling,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize logger: %v", err))
+	}
+	defer logger.Sync()
+
+	db, err := gorm.Open(mysql.Open(cfg.MySQL.DSN()), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Warn),
+	})
+	if err != nil {
+		logger.Fatal("failed to connect to MySQL", zap.Error(err))
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Fatal("failed to get underlying sql.DB", zap.Error(err))
+	}
+	defer sqlDB.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	before, err := persistence.CurrentSchemaVersion(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to read schema version", zap.Error(err))
+	}
+
+	logger.Info("migrating schema",
+		zap.String("host", cfg.MySQL.Host),
+		zap.Int("from_version", before),
+		zap.Int("to_version", persistence.SchemaVersion),
+		zap.String("dedup_scope", cfg.Payment.DedupScope),
+	)
+
+	start := time.Now()
+	if err := persistence.Migrate(ctx, db, domain.DedupScope(cfg.Payment.DedupScope)); err != nil {
+		logger.Fatal("migration failed", zap.Error(err), zap.Duration("elapsed", time.Since(start)))
+	}
+
+	logger.Info("migration complete",
+		zap.Int("version", persistence.SchemaVersion),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+}
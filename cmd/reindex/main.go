@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/config"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/gigmile/payment-service/internal/infrastructure/logging"
+	"github.com/gigmile/payment-service/internal/infrastructure/redisclient"
+	sqlrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/mysql"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// reindex rebuilds the Redis payment dedup keys from the payments table,
+// for after Redis has been flushed or replaced. Keys already present are
+// left alone, so it is safe to re-run; pass the last logged cursor as
+// -after to resume an interrupted run.
+func main() {
+	configFile := flag.String("config", "", "path to a YAML or JSON config file (defaults to $CONFIG_FILE)")
+	batchSize := flag.Int("batch-size", 500, "payments to read and restore per batch")
+	after := flag.String("after", "", "resume after this cursor, as logged by an earlier run")
+	lists := flag.Bool("lists", false, "also rebuild each customer's payment list")
+	timeout := flag.Duration("timeout", time.Hour, "give up if the rebuild takes longer than this")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load config: %v", err))
+	}
+
+	logger, err := logging.New(logging.Options{
+		Level:    cfg.Log.Level,
+		Encoding: cfg.Log.Encoding,
+		Sampling: cfg.Log.Sampling,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize logger: %v", err))
+	}
+	defer logger.Sync()
+
+	cursor, err := sqlrepository.ParsePaymentCursor(*after)
+	if err != nil {
+		logger.Fatal("invalid -after", zap.Error(err))
+	}
+
+	db, err := gorm.Open(mysql.Open(cfg.MySQL.DSN()), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Warn),
+	})
+	if err != nil {
+		logger.Fatal("failed to connect to MySQL", zap.Error(err))
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Fatal("failed to get underlying sql.DB", zap.Error(err))
+	}
+	defer sqlDB.Close()
+
+	redisClient, err := redisclient.New(redisclient.Options{
+		Mode:       redisclient.Mode(cfg.Redis.Mode),
+		Addrs:      cfg.Redis.NodeAddrs(),
+		MasterName: cfg.Redis.MasterName,
+		Password:   cfg.Redis.Password,
+		DB:         cfg.Redis.DB,
+		PoolSize:   cfg.Redis.PoolSize,
+	})
+	if err != nil {
+		logger.Fatal("invalid redis configuration", zap.Error(err))
+	}
+	defer redisClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	logger.Info("rebuilding payment dedup keys",
+		zap.String("mysql_host", cfg.MySQL.Host),
+		zap.Duration("dedup_ttl", cfg.Redis.PaymentDedupTTL),
+		zap.Int("batch_size", *batchSize),
+		zap.Bool("lists", *lists),
+		zap.String("after", cursor.String()),
+	)
+
+	start := time.Now()
+	progress, err := sqlrepository.ReindexPaymentDedup(ctx, db, redisClient, sqlrepository.PaymentReindexOptions{
+		BatchSize:  *batchSize,
+		DedupTTL:   cfg.Redis.PaymentDedupTTL,
+		KeyPrefix:  keyspace.Prefix(cfg.Redis.KeyPrefix),
+		DedupScope: domain.DedupScope(cfg.Payment.DedupScope),
+		Lists:      *lists,
+		After:      cursor,
+		OnBatch: func(p sqlrepository.PaymentReindexProgress) {
+			logger.Info("reindex progress",
+				zap.Int64("scanned", p.Scanned),
+				zap.Int64("restored", p.Restored),
+				zap.String("cursor", p.Cursor.String()),
+			)
+		},
+	}, logger)
+	if err != nil {
+		logger.Fatal("reindex failed", zap.Error(err),
+			zap.Int64("restored", progress.Restored),
+			zap.String("resume_after", progress.Cursor.String()),
+			zap.Duration("elapsed", time.Since(start)))
+	}
+
+	logger.Info("reindex complete",
+		zap.Int64("scanned", progress.Scanned),
+		zap.Int64("restored", progress.Restored),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+}
@@ -2,35 +2,59 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gigmile/payment-service/internal/application/service"
 	"github.com/gigmile/payment-service/internal/config"
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/gigmile/payment-service/internal/infrastructure/logging"
 	"github.com/gigmile/payment-service/internal/infrastructure/messaging"
+	"github.com/gigmile/payment-service/internal/infrastructure/redisclient"
+	sqlrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/mysql"
 	"github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 func main() {
-	logger, err := zap.NewProduction()
+	configFile := flag.String("config", "", "path to a YAML or JSON config file (defaults to $CONFIG_FILE)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load config: %v", err))
+	}
+
+	logger, err := logging.New(logging.Options{
+		Level:    cfg.Log.Level,
+		Encoding: cfg.Log.Encoding,
+		Sampling: cfg.Log.Sampling,
+	})
 	if err != nil {
 		panic(fmt.Sprintf("failed to initialize logger: %v", err))
 	}
 	defer logger.Sync()
 
-	cfg := config.Load()
-
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-		PoolSize: cfg.Redis.PoolSize,
+	redisClient, err := redisclient.New(redisclient.Options{
+		Mode:       redisclient.Mode(cfg.Redis.Mode),
+		Addrs:      cfg.Redis.NodeAddrs(),
+		MasterName: cfg.Redis.MasterName,
+		Password:   cfg.Redis.Password,
+		DB:         cfg.Redis.DB,
+		PoolSize:   cfg.Redis.PoolSize,
 	})
+	if err != nil {
+		logger.Fatal("invalid redis configuration", zap.Error(err))
+	}
 
 	ctx := context.Background()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
@@ -38,13 +62,58 @@ func main() {
 	}
 	logger.Info("connected to Redis successfully")
 
-	customerRepo := redisrepository.NewRedisCustomerRepository(redisClient, 0)
+	keys := keyspace.Prefix(cfg.Redis.KeyPrefix)
+
+	db := openMySQL(ctx, cfg, logger)
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	logger.Info("connected to MySQL successfully")
+
+	// The same Redis-then-MySQL view the API reads, so a customer missing
+	// from the cache is still found
+	repos := sqlrepository.NewRepositories(db, redisClient, sqlrepository.Config{
+		PaymentDedupTTL:        cfg.Redis.PaymentDedupTTL,
+		KeyPrefix:              keys,
+		RejectInvalidCustomers: cfg.MySQL.RejectInvalidCustomers,
+		TrackCustomerAccess:    cfg.CacheWarm.Refresh,
+		DisableCache:           !cfg.Redis.CacheEnabled,
+		DedupScope:             domain.DedupScope(cfg.Payment.DedupScope),
+	}, logger)
+
+	// A claim must outlive the handler holding it, or a slow send could be
+	// repeated by a redelivery
+	claimTTL := 5 * time.Minute
+	if cfg.Worker.HandlerTimeout > 0 {
+		claimTTL = 2 * cfg.Worker.HandlerTimeout
+	}
+	handledEvents := messaging.NewRedisHandledEvents(redisClient, keys, "notifications", claimTTL, cfg.Worker.NotificationDedupTTL)
+
+	smsSender := service.NewLogSMSSender(logger)
+	if cfg.Worker.SMSBreakerFailurePercent > 0 {
+		smsSender = service.NewCircuitBreakerSMSSender(smsSender, service.BreakerSettings{
+			FailureRatio: float64(cfg.Worker.SMSBreakerFailurePercent) / 100,
+			MinRequests:  cfg.Worker.SMSBreakerMinRequests,
+			Interval:     cfg.Worker.SMSBreakerInterval,
+			OpenTimeout:  cfg.Worker.SMSBreakerOpenTimeout,
+		}, logger)
+	}
 
-	notificationService := service.NewNotificationService(customerRepo, logger)
+	notificationService := service.NewNotificationService(repos.Customer, logger,
+		service.WithSMSSender(smsSender),
+		service.WithHandledEvents(handledEvents),
+		service.WithEventSequences(messaging.NewRedisEventSequences(redisClient, keys, "notifications", cfg.Worker.NotificationDedupTTL)),
+	)
 
 	hostname, _ := os.Hostname()
 	consumerName := fmt.Sprintf("worker-%s-%d", hostname, os.Getpid())
-	eventSubscriber := messaging.NewRedisEventSubscriber(redisClient, logger, consumerName)
+	eventSubscriber := messaging.NewRedisEventSubscriber(redisClient, logger, consumerName,
+		messaging.WithRetryBackoff(cfg.Worker.RetryInitialBackoff, cfg.Worker.RetryMaxBackoff),
+		messaging.WithMaxConsecutiveFailures(cfg.Worker.MaxConsecutiveFailures),
+		messaging.WithHandlerTimeout(cfg.Worker.HandlerTimeout),
+		messaging.WithKeyPrefix(keys),
+		messaging.WithHeartbeat(messaging.NewWorkerHeartbeats(redisClient, keys, cfg.Worker.HeartbeatTTL)),
+	)
 
 	if err := eventSubscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, notificationService.HandlePaymentProcessed); err != nil {
 		logger.Fatal("failed to subscribe to events", zap.Error(err))
@@ -67,9 +136,110 @@ func main() {
 		cancel()
 	}()
 
+	if cfg.CacheWarm.Refresh && cfg.CacheWarm.RefreshRunner == "worker" {
+		sqlrepository.NewCustomerCacheRefresher(db, redisClient, sqlrepository.CacheRefreshConfig{
+			Interval:  cfg.CacheWarm.RefreshInterval,
+			Window:    cfg.CacheWarm.RefreshWindow,
+			Lead:      cfg.CacheWarm.RefreshLead,
+			MaxPerRun: cfg.CacheWarm.RefreshMaxPerRun,
+			KeyPrefix: keys,
+		}, logger).Start(ctx)
+	}
+
+	if cfg.Report.DailyEnabled {
+		reports := newDailyReportService(cfg, redisClient, repos, keys, logger)
+		go reports.Run(ctx)
+		logger.Info("daily reconciliation report enabled",
+			zap.String("at", cfg.Report.DailyAt),
+			zap.String("timezone", cfg.Report.Timezone),
+		)
+	}
+
 	if err := eventSubscriber.Start(ctx); err != nil {
-		logger.Info("worker stopped", zap.Error(err))
+		// Exit non-zero so the orchestrator restarts or alerts on us
+		logger.Fatal("worker stopped", zap.Error(err))
 	}
 
 	logger.Info("worker exited")
 }
+
+// openMySQL connects to MySQL, exiting if it can't be reached
+func openMySQL(ctx context.Context, cfg *config.Config, logger *zap.Logger) *gorm.DB {
+	db, err := gorm.Open(mysql.Open(cfg.MySQL.DSN()), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		logger.Fatal("failed to connect to MySQL", zap.Error(err))
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Fatal("failed to get underlying sql.DB", zap.Error(err))
+	}
+	sqlDB.SetMaxOpenConns(cfg.MySQL.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MySQL.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.MySQL.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.MySQL.ConnMaxIdleTime)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		logger.Fatal("MySQL ping failed", zap.Error(err))
+	}
+	return db
+}
+
+func newDailyReportService(cfg *config.Config, redisClient redis.UniversalClient, repos *sqlrepository.Repositories, keys keyspace.Prefix, logger *zap.Logger) *service.DailyReportService {
+	schedule, err := service.ParseDailySchedule(cfg.Report.DailyAt, cfg.Report.Timezone)
+	if err != nil {
+		logger.Fatal("invalid daily report schedule", zap.Error(err))
+	}
+
+	var schemas *messaging.SchemaRegistry
+	if cfg.Events.SchemaValidation {
+		schemas, err = messaging.NewSchemaRegistry()
+		if err != nil {
+			logger.Fatal("failed to load event schemas", zap.Error(err))
+		}
+	}
+	retention, err := messaging.ParseStreamRetention(messaging.StreamRetention{
+		MaxLen: cfg.Events.StreamMaxLen,
+		MaxAge: cfg.Events.StreamMaxAge,
+		Approx: cfg.Events.StreamApproxTrim,
+	}, cfg.Events.StreamRetention)
+	if err != nil {
+		logger.Fatal("invalid EVENT_STREAM_RETENTION", zap.Error(err))
+	}
+
+	publisherOpts := []messaging.PublisherOption{
+		messaging.WithStreamRetention(retention),
+		messaging.WithPublishDeduplication(cfg.Events.PublishDedupTTL),
+	}
+	if schemas != nil {
+		publisherOpts = append(publisherOpts, messaging.WithSchemaValidation(schemas))
+	}
+
+	var publisher domain.EventPublisher
+	switch cfg.Events.Backend {
+	case "kafka":
+		var kafkaOpts []messaging.KafkaPublisherOption
+		if schemas != nil {
+			kafkaOpts = append(kafkaOpts, messaging.WithKafkaSchemaValidation(schemas))
+		}
+		// Writes are synchronous, so a report is on its topic once Publish
+		// returns and nothing is lost by not closing the writer on exit
+		publisher = messaging.NewKafkaEventPublisher(messaging.NewKafkaWriterFactory(cfg.Events.KafkaBrokers), cfg.Events.KafkaTopicPrefix, logger, kafkaOpts...)
+	default:
+		publisher = messaging.NewRedisEventPublisher(redisClient, keys, logger, publisherOpts...)
+	}
+	var opts []service.DailyReportOption
+	if cfg.Events.LogEnabled {
+		opts = append(opts, service.WithDailyReportEventLog(repos.EventLog))
+	}
+
+	return service.NewDailyReportService(
+		repos.PaymentTotals,
+		redisrepository.NewRedisPaymentOutcomeLog(redisClient, cfg.Report.OutcomeRetention, keys),
+		publisher,
+		schedule,
+		logger,
+		opts...,
+	)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// WithAtomicApply applies payments through applier in one step instead of
+// reading the customer, applying the payment and saving it under an
+// optimistic lock. It suits deployments whose customers and payments live
+// in the store applier writes to; nil keeps the usual path.
+//
+// The atomic path applies payments the way Customer.ApplyPayment does:
+// overpayments stay in TotalPaid whatever the overpayment policy, the
+// default status is not re-evaluated and the installment check is skipped.
+// Dry runs still read and project the customer. Events are logged after
+// the apply, not in the same transaction.
+func WithAtomicApply(applier domain.AtomicPaymentApplier) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.atomicApply = applier
+	}
+}
+
+// processPaymentAtomic is ProcessPayment for a COMPLETE payment once an
+// AtomicPaymentApplier is set. Dedup happens inside the apply, so there is
+// no window between checking the reference and recording it.
+func (s *PaymentService) processPaymentAtomic(ctx context.Context, correlationID string, req ProcessPaymentRequest, timings *paymentTimings) (*ProcessPaymentResponse, error) {
+	payment, err := domain.NewPayment(
+		req.CustomerID,
+		req.TransactionAmount,
+		req.TransactionReference,
+		req.TransactionDate,
+		domain.PaymentStatusComplete,
+		s.clock.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment data: %w", err)
+	}
+	// The applier checks it against the customer's before applying
+	payment.CurrencyCode = req.Currency
+	payment.Metadata = req.Metadata
+
+	step := time.Now()
+	customer, previousStatus, err := s.atomicApply.ApplyPayment(ctx, payment, domain.PaymentLimits{
+		Minimum:     s.minimumPaymentAmount,
+		Overpayment: s.overpaymentLimit,
+	})
+	timings.customerSave = time.Since(step)
+	if errors.Is(err, domain.ErrDuplicateTransaction) {
+		s.logger.Info("duplicate payment detected",
+			zap.String("customer_id", req.CustomerID),
+			zap.String("tx_ref", req.TransactionReference),
+		)
+		s.recordOutcome(ctx, domain.PaymentStatusDuplicate, req)
+
+		customer, err := s.customerRepo.FindByID(ctx, req.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get customer for duplicate payment: %w", err)
+		}
+		return (&ProcessPaymentResponse{
+			Outcome:            OutcomeDuplicate,
+			Success:            true,
+			Processed:          true,
+			Message:            "duplicate transaction - already processed",
+			CustomerID:         customer.ID,
+			OutstandingBalance: customer.OutstandingBalance,
+			TotalPaid:          customer.TotalPaid,
+			PaymentProgress:    customer.GetPaymentProgress(),
+			IsFullyPaid:        customer.IsFullyPaid(),
+		}).withPayment(s.recordedPayment(ctx, req.CustomerID, req.TransactionReference)), nil
+	}
+	if errors.Is(err, domain.ErrAssetAlreadyOwned) {
+		customer, err := s.customerRepo.FindByID(ctx, req.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get customer for settled payment: %w", err)
+		}
+		return s.acceptSettledPayment(ctx, correlationID, customer, req)
+	}
+	if err != nil {
+		logFailure(s.logger, "failed to apply payment", err,
+			zap.String("customer_id", req.CustomerID),
+			zap.String("tx_ref", req.TransactionReference),
+		)
+		return nil, fmt.Errorf("failed to apply payment: %w", err)
+	}
+	defer s.invalidateCustomerViews(ctx, customer.ID)
+
+	payment.MarkAsProcessed(s.clock.Now())
+
+	s.logger.Info("payment processed successfully",
+		zap.String("customer_id", req.CustomerID),
+		zap.Int64("amount", req.TransactionAmount),
+		zap.String("tx_ref", req.TransactionReference),
+		zap.Int64("new_balance", customer.OutstandingBalance),
+		zap.Bool("atomic", true),
+	)
+
+	// The applier's store is not the event log's, so the events are logged
+	// after the payment is applied rather than with it
+	if s.eventPublisher != nil {
+		for _, event := range s.paymentEvents(correlationID, customer, previousStatus, req) {
+			if err := s.publishEvent(ctx, event); err != nil {
+				return nil, fmt.Errorf("failed to publish %s event: %w", event.GetEventType(), err)
+			}
+		}
+	}
+
+	flagged, err := s.checkVelocity(ctx, correlationID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish payment flagged event: %w", err)
+	}
+
+	return (&ProcessPaymentResponse{
+		Outcome:            OutcomeProcessed,
+		Success:            true,
+		Processed:          true,
+		Message:            "payment processed successfully",
+		CustomerID:         customer.ID,
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		PaymentProgress:    customer.GetPaymentProgress(),
+		IsFullyPaid:        customer.IsFullyPaid(),
+		Flagged:            flagged,
+	}).withPayment(payment), nil
+}
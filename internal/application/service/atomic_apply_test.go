@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeApplier applies payments to a single customer, remembering references
+type fakeApplier struct {
+	customer *domain.Customer
+	seen     map[string]bool
+	limits   domain.PaymentLimits
+}
+
+func (a *fakeApplier) ApplyPayment(ctx context.Context, payment *domain.Payment, limits domain.PaymentLimits) (*domain.Customer, domain.CustomerStatus, error) {
+	a.limits = limits
+	if a.seen[payment.TransactionReference] {
+		return nil, "", domain.ErrDuplicateTransaction
+	}
+	if err := a.customer.AcceptsCurrency(payment.CurrencyCode); err != nil {
+		return nil, "", err
+	}
+	previous := a.customer.Status
+	if err := a.customer.ApplyPayment(payment.Amount, payment.TransactionDate); err != nil {
+		return nil, "", err
+	}
+	a.seen[payment.TransactionReference] = true
+	payment.ID = "payment-" + payment.TransactionReference
+	saved := *a.customer
+	return &saved, previous, nil
+}
+
+func TestProcessPayment_AtomicApply(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00050"
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	applier := &fakeApplier{customer: customer, seen: map[string]bool{}}
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(),
+		WithAtomicApply(applier),
+		WithMinimumPaymentAmount(50000),
+		WithOverpaymentLimit(domain.OverpaymentLimit{Enabled: true, Percent: 10}),
+		WithSyncPublishing(true),
+	)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN050"))
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeProcessed, result.Outcome)
+	assert.Equal(t, int64(99000000), result.OutstandingBalance)
+	assert.Equal(t, domain.PaymentLimits{
+		Minimum:     50000,
+		Overpayment: domain.OverpaymentLimit{Enabled: true, Percent: 10},
+	}, applier.limits)
+	assert.Equal(t, "payment-TXN050", result.PaymentID)
+	assert.False(t, result.ProcessedAt.IsZero())
+	assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentProcessed), 1)
+	// Neither the dedup check nor the read-modify-write runs
+	mockPaymentRepo.AssertNotCalled(t, "ExistsByTransactionReference", mock.Anything, mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockPaymentRepo.On("FindByTransactionReference", ctx, customerID, "TXN050").Return(&domain.Payment{ID: "payment-TXN050"}, nil)
+	result, err = service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN050"))
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, result.Outcome)
+	assert.Equal(t, "payment-TXN050", result.PaymentID)
+	assert.Equal(t, int64(99000000), result.OutstandingBalance)
+	assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentProcessed), 1)
+}
+
+func TestProcessPayment_AtomicApplyRejection(t *testing.T) {
+	customer := &domain.Customer{ID: "GIG00051", AssetValue: 100000, Status: domain.CustomerStatusWrittenOff, Version: 1}
+	service := NewPaymentService(new(MockCustomerRepository), new(MockPaymentRepository), nil, zap.NewNop(),
+		WithAtomicApply(&fakeApplier{customer: customer, seen: map[string]bool{}}),
+	)
+
+	result, err := service.ProcessPayment(context.Background(), completePaymentRequest("GIG00051", "TXN051"))
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, domain.ErrLoanWrittenOff)
+}
+
+func TestProcessPayment_AtomicApplyDryRunOnlyReads(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00052", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	applier := &fakeApplier{customer: &domain.Customer{}, seen: map[string]bool{}}
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, "TXN052").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, "GIG00052").Return(customer, nil)
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithAtomicApply(applier))
+
+	req := completePaymentRequest("GIG00052", "TXN052")
+	req.DryRun = true
+	result, err := service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomePreview, result.Outcome)
+	assert.Empty(t, applier.seen)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Collections worklist filters. AT_RISK is an active customer who has
+// fallen behind the repayment schedule.
+const (
+	CollectionsDefaulted = "DEFAULTED"
+	CollectionsAtRisk    = "AT_RISK"
+	CollectionsAll       = "ALL"
+)
+
+var ErrInvalidCollectionsFilter = errors.New("status must be DEFAULTED, AT_RISK or ALL")
+
+// collectionsScanBatch is how many customers are read per status query
+const collectionsScanBatch = 500
+
+// CollectionsService builds the collections team's worklist from the
+// repayment schedule
+type CollectionsService struct {
+	customers domain.CustomerLister
+	logger    *zap.Logger
+	now       func() time.Time
+	pageSizes PageSizes
+}
+
+// CollectionsOption configures optional CollectionsService behaviour
+type CollectionsOption func(*CollectionsService)
+
+// WithWorklistPageSizes sets the worklist's default and maximum page size.
+// The zero value keeps the defaults of 20 and 100.
+func WithWorklistPageSizes(sizes PageSizes) CollectionsOption {
+	return func(s *CollectionsService) {
+		if sizes != (PageSizes{}) {
+			s.pageSizes = sizes
+		}
+	}
+}
+
+func NewCollectionsService(customers domain.CustomerLister, logger *zap.Logger, opts ...CollectionsOption) *CollectionsService {
+	s := &CollectionsService{
+		customers: customers,
+		logger:    logger,
+		now:       time.Now,
+		pageSizes: PageSizes{Default: 20, Max: 100},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type CollectionsQuery struct {
+	// Status is one of the Collections* filters; empty means DEFAULTED
+	Status string
+	PaginationParams
+}
+
+type CollectionsEntry struct {
+	Customer    *domain.Customer
+	Arrears     int64
+	DaysOverdue int
+}
+
+type CollectionsPage struct {
+	Entries      []CollectionsEntry
+	TotalCount   int64
+	TotalArrears int64
+	Page         int
+	PageSize     int
+	TotalPages   int
+}
+
+// Worklist returns customers needing collection, largest arrears first.
+// Arrears depend on the current date, so every matching customer is read
+// and ranked before the requested page is cut.
+func (s *CollectionsService) Worklist(ctx context.Context, q CollectionsQuery) (*CollectionsPage, error) {
+	if q.Status == "" {
+		q.Status = CollectionsDefaulted
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	q.PageSize = s.pageSizes.size(q.PageSize)
+
+	var statuses []domain.CustomerStatus
+	switch q.Status {
+	case CollectionsDefaulted:
+		statuses = []domain.CustomerStatus{domain.CustomerStatusDefaulted}
+	case CollectionsAtRisk:
+		statuses = []domain.CustomerStatus{domain.CustomerStatusActive}
+	case CollectionsAll:
+		statuses = []domain.CustomerStatus{domain.CustomerStatusDefaulted, domain.CustomerStatusActive}
+	default:
+		return nil, ErrInvalidCollectionsFilter
+	}
+
+	now := s.now()
+	page := &CollectionsPage{Page: q.Page, PageSize: q.PageSize}
+
+	var entries []CollectionsEntry
+	for _, status := range statuses {
+		for offset := 0; ; offset += collectionsScanBatch {
+			customers, err := s.customers.FindByStatus(ctx, string(status), collectionsScanBatch, offset)
+			if err != nil {
+				logFailure(s.logger, "failed to list customers for collections", err,
+					zap.String("status", string(status)),
+				)
+				return nil, fmt.Errorf("failed to list customers: %w", err)
+			}
+
+			for _, customer := range customers {
+				arrears := customer.Arrears(now)
+				// Active customers only make the list once they fall behind
+				if status == domain.CustomerStatusActive && arrears == 0 {
+					continue
+				}
+				entries = append(entries, CollectionsEntry{
+					Customer:    customer,
+					Arrears:     arrears,
+					DaysOverdue: customer.DaysOverdue(now),
+				})
+				page.TotalArrears += arrears
+			}
+
+			if len(customers) < collectionsScanBatch {
+				break
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Arrears != entries[j].Arrears {
+			return entries[i].Arrears > entries[j].Arrears
+		}
+		return entries[i].Customer.ID < entries[j].Customer.ID
+	})
+
+	page.TotalCount = int64(len(entries))
+	page.TotalPages = (len(entries) + q.PageSize - 1) / q.PageSize
+
+	start := (q.Page - 1) * q.PageSize
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + q.PageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	page.Entries = entries[start:end]
+
+	s.logger.Info("built collections worklist",
+		zap.String("status", q.Status),
+		zap.Int64("total_count", page.TotalCount),
+		zap.Int64("total_arrears", page.TotalArrears),
+	)
+
+	return page, nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// statusLister serves FindByStatus from an in-memory slice
+type statusLister struct {
+	customers []*domain.Customer
+	err       error
+}
+
+func (l *statusLister) FindByStatus(ctx context.Context, status string, limit, offset int) ([]*domain.Customer, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	var matched []*domain.Customer
+	for _, c := range l.customers {
+		if string(c.Status) == status {
+			matched = append(matched, c)
+		}
+	}
+	if offset >= len(matched) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[offset:end], nil
+}
+
+var collectionsNow = time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+// overdueCustomer owes 10,000 kobo at 1,000 a week, deployed weeksAgo weeks ago
+func overdueCustomer(id string, status domain.CustomerStatus, weeksAgo int, totalPaid int64) *domain.Customer {
+	return &domain.Customer{
+		ID:                 id,
+		AssetValue:         10000,
+		RepaymentTermWeeks: 10,
+		OutstandingBalance: 10000 - totalPaid,
+		TotalPaid:          totalPaid,
+		DeploymentDate:     collectionsNow.Add(-time.Duration(weeksAgo)*7*24*time.Hour - time.Hour),
+		Status:             status,
+	}
+}
+
+func newTestCollectionsService(customers ...*domain.Customer) *CollectionsService {
+	s := NewCollectionsService(&statusLister{customers: customers}, zap.NewNop())
+	s.now = func() time.Time { return collectionsNow }
+	return s
+}
+
+func collectionIDs(page *CollectionsPage) []string {
+	ids := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		ids[i] = entry.Customer.ID
+	}
+	return ids
+}
+
+func TestCollectionsWorklist_OrdersByArrears(t *testing.T) {
+	s := newTestCollectionsService(
+		overdueCustomer("GIG00001", domain.CustomerStatusDefaulted, 4, 1000), // 3,000 behind
+		overdueCustomer("GIG00002", domain.CustomerStatusDefaulted, 8, 0),    // 8,000 behind
+		overdueCustomer("GIG00003", domain.CustomerStatusDefaulted, 4, 3000), // 1,000 behind
+		overdueCustomer("GIG00004", domain.CustomerStatusActive, 6, 0),       // at risk, 6,000 behind
+		overdueCustomer("GIG00005", domain.CustomerStatusActive, 3, 3000),    // on track
+		overdueCustomer("GIG00006", domain.CustomerStatusWrittenOff, 8, 0),   // never listed
+	)
+
+	page, err := s.Worklist(context.Background(), CollectionsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GIG00002", "GIG00001", "GIG00003"}, collectionIDs(page))
+	assert.Equal(t, int64(12000), page.TotalArrears)
+	assert.Equal(t, int64(8000), page.Entries[0].Arrears)
+	assert.Equal(t, 7*7, page.Entries[0].DaysOverdue)
+
+	page, err = s.Worklist(context.Background(), CollectionsQuery{Status: CollectionsAtRisk})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GIG00004"}, collectionIDs(page))
+
+	page, err = s.Worklist(context.Background(), CollectionsQuery{Status: CollectionsAll})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GIG00002", "GIG00004", "GIG00001", "GIG00003"}, collectionIDs(page))
+}
+
+func TestCollectionsWorklist_Paginates(t *testing.T) {
+	var customers []*domain.Customer
+	for i := 0; i < collectionsScanBatch+5; i++ {
+		customers = append(customers, overdueCustomer(fmt.Sprintf("GIG%05d", i), domain.CustomerStatusDefaulted, 2, int64(i%2)*1000))
+	}
+	s := newTestCollectionsService(customers...)
+
+	page, err := s.Worklist(context.Background(), CollectionsQuery{PaginationParams: PaginationParams{Page: 3, PageSize: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, 100, page.PageSize, "page size is capped")
+	assert.Equal(t, int64(collectionsScanBatch+5), page.TotalCount, "every batch is scanned")
+	assert.Equal(t, 6, page.TotalPages)
+	require.Len(t, page.Entries, 100)
+	assert.Equal(t, int64(2000), page.Entries[0].Arrears)
+	assert.Equal(t, int64(1000), page.Entries[99].Arrears)
+
+	page, err = s.Worklist(context.Background(), CollectionsQuery{PaginationParams: PaginationParams{Page: 7, PageSize: 100}})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+
+	page, err = s.Worklist(context.Background(), CollectionsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 20, page.PageSize)
+
+	configured := NewCollectionsService(&statusLister{customers: customers}, zap.NewNop(),
+		WithWorklistPageSizes(PageSizes{Default: 5, Max: 50}))
+	configured.now = s.now
+	page, err = configured.Worklist(context.Background(), CollectionsQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 5, "configured default")
+	page, err = configured.Worklist(context.Background(), CollectionsQuery{PaginationParams: PaginationParams{PageSize: 1000}})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 50, "configured max")
+}
+
+func TestCollectionsWorklist_Errors(t *testing.T) {
+	s := newTestCollectionsService()
+	_, err := s.Worklist(context.Background(), CollectionsQuery{Status: "COMPLETED"})
+	assert.ErrorIs(t, err, ErrInvalidCollectionsFilter)
+
+	failing := NewCollectionsService(&statusLister{err: errors.New("db down")}, zap.NewNop())
+	_, err = failing.Worklist(context.Background(), CollectionsQuery{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// processConcurrently runs ProcessPayment for every request at once and
+// counts the outcomes. Failures other than losing the optimistic lock twice
+// fail the test.
+func processConcurrently(t *testing.T, service *PaymentService, reqs []ProcessPaymentRequest) map[PaymentOutcome]int {
+	t.Helper()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		outcomes = make(map[PaymentOutcome]int)
+	)
+	for _, req := range reqs {
+		wg.Add(1)
+		go func(req ProcessPaymentRequest) {
+			defer wg.Done()
+			result, err := service.ProcessPayment(context.Background(), req)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				assert.ErrorIs(t, err, domain.ErrOptimisticLock, req.TransactionReference)
+				outcomes[OutcomeFailed]++
+				return
+			}
+			outcomes[result.Outcome]++
+		}(req)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+func TestProcessPayment_ConcurrentPaymentsAreNeverLost(t *testing.T) {
+	ctx := context.Background()
+	customers := testutil.NewMemoryCustomerRepository(&domain.Customer{
+		ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50,
+		OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1,
+	})
+	payments := testutil.NewMemoryPaymentRepository()
+	service := NewPaymentService(customers, payments, nil, zap.NewNop())
+
+	reqs := make([]ProcessPaymentRequest, 20)
+	for i := range reqs {
+		reqs[i] = completePaymentRequest("GIG00001", fmt.Sprintf("TXN%03d", i))
+	}
+
+	outcomes := processConcurrently(t, service, reqs)
+
+	processed := outcomes[OutcomeProcessed]
+	require.Positive(t, processed)
+	assert.Equal(t, len(reqs), processed+outcomes[OutcomeFailed])
+
+	customer, err := customers.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(processed)*1000000, customer.TotalPaid, "every processed payment is applied exactly once")
+	assert.Equal(t, int64(1+processed), customer.Version)
+	assert.NoError(t, customer.Validate())
+
+	count, err := payments.CountByCustomerID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(processed), count, "a payment is recorded for every processed request and no other")
+}
+
+func TestProcessPayment_ConcurrentRetriesOfOnePaymentApplyItOnce(t *testing.T) {
+	ctx := context.Background()
+	customers := testutil.NewMemoryCustomerRepository(&domain.Customer{
+		ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50,
+		OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1,
+	})
+	payments := testutil.NewMemoryPaymentRepository()
+	service := NewPaymentService(customers, payments, nil, zap.NewNop())
+
+	reqs := make([]ProcessPaymentRequest, 8)
+	for i := range reqs {
+		reqs[i] = completePaymentRequest("GIG00001", "TXN001")
+	}
+
+	outcomes := processConcurrently(t, service, reqs)
+
+	assert.Equal(t, 1, outcomes[OutcomeProcessed])
+	assert.Equal(t, len(reqs)-1, outcomes[OutcomeDuplicate]+outcomes[OutcomeFailed])
+
+	customer, err := customers.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000000), customer.TotalPaid)
+	assert.Equal(t, int64(99000000), customer.OutstandingBalance)
+
+	count, err := payments.CountByCustomerID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
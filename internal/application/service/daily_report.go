@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// DailySchedule fires once a day at a wall-clock time in Location
+type DailySchedule struct {
+	Hour     int
+	Minute   int
+	Location *time.Location
+}
+
+// ParseDailySchedule reads at as HH:MM in the named IANA timezone
+func ParseDailySchedule(at, timezone string) (DailySchedule, error) {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		return DailySchedule{}, fmt.Errorf("invalid daily time %q, want HH:MM", at)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return DailySchedule{}, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return DailySchedule{Hour: clock.Hour(), Minute: clock.Minute(), Location: loc}, nil
+}
+
+// Next returns the first scheduled time strictly after after
+func (d DailySchedule) Next(after time.Time) time.Time {
+	local := after.In(d.Location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), d.Hour, d.Minute, 0, 0, d.Location)
+	if !next.After(after) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, d.Hour, d.Minute, 0, 0, d.Location)
+	}
+	return next
+}
+
+// DailyReportService summarizes each day's payments into a
+// reconciliation.daily event. Completions and their amount come from the
+// stored payments by transaction date; duplicates and failures are never
+// stored, so they come from the outcome log by the time they were received.
+type DailyReportService struct {
+	payments  domain.PaymentStatusTotaler
+	outcomes  domain.PaymentOutcomeLog
+	publisher domain.EventPublisher
+	eventLog  domain.EventLog
+	schedule  DailySchedule
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+func NewDailyReportService(
+	payments domain.PaymentStatusTotaler,
+	outcomes domain.PaymentOutcomeLog,
+	publisher domain.EventPublisher,
+	schedule DailySchedule,
+	logger *zap.Logger,
+	opts ...DailyReportOption,
+) *DailyReportService {
+	s := &DailyReportService{
+		payments:  payments,
+		outcomes:  outcomes,
+		publisher: publisher,
+		schedule:  schedule,
+		logger:    logger,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// DailyReportOption configures optional DailyReportService behaviour
+type DailyReportOption func(*DailyReportService)
+
+// WithDailyReportEventLog appends each report's event to log before it is
+// published; a report that can't be logged is not published
+func WithDailyReportEventLog(log domain.EventLog) DailyReportOption {
+	return func(s *DailyReportService) {
+		s.eventLog = log
+	}
+}
+
+// Build aggregates the calendar day containing day, in the schedule's
+// timezone. A day without activity yields a report of zeros.
+func (s *DailyReportService) Build(ctx context.Context, day time.Time) (domain.DailyReconciliationPayload, error) {
+	local := day.In(s.schedule.Location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.schedule.Location)
+	end := start.AddDate(0, 0, 1)
+
+	totals, err := s.payments.TotalsByStatus(ctx, start, end)
+	if err != nil {
+		return domain.DailyReconciliationPayload{}, fmt.Errorf("failed to total payments: %w", err)
+	}
+	duplicates, err := s.outcomes.Count(ctx, domain.PaymentStatusDuplicate, start, end)
+	if err != nil {
+		return domain.DailyReconciliationPayload{}, fmt.Errorf("failed to count duplicates: %w", err)
+	}
+	failed, err := s.outcomes.Count(ctx, domain.PaymentStatusFailed, start, end)
+	if err != nil {
+		return domain.DailyReconciliationPayload{}, fmt.Errorf("failed to count failures: %w", err)
+	}
+
+	// Write-off rows are audit records, not payments, so they are left out
+	completed := totals[domain.PaymentStatusComplete]
+	return domain.DailyReconciliationPayload{
+		ReportDate:     start.Format("2006-01-02"),
+		Timezone:       s.schedule.Location.String(),
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		TotalCount:     completed.Count + duplicates + failed,
+		TotalAmount:    completed.Amount,
+		CompletedCount: completed.Count,
+		DuplicateCount: duplicates,
+		FailedCount:    failed,
+		GeneratedAt:    s.now(),
+	}, nil
+}
+
+// Emit builds the report for day, logs it and publishes it
+func (s *DailyReportService) Emit(ctx context.Context, day time.Time) error {
+	report, err := s.Build(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("daily reconciliation report",
+		zap.String("report_date", report.ReportDate),
+		zap.String("timezone", report.Timezone),
+		zap.Int64("total_count", report.TotalCount),
+		zap.Int64("total_amount", report.TotalAmount),
+		zap.Int64("completed_count", report.CompletedCount),
+		zap.Int64("duplicate_count", report.DuplicateCount),
+		zap.Int64("failed_count", report.FailedCount),
+	)
+
+	if s.publisher == nil {
+		return nil
+	}
+	event := domain.NewDailyReconciliationEvent(report, report.GeneratedAt)
+	if s.eventLog != nil {
+		if _, err := s.eventLog.Append(ctx, event); err != nil {
+			eventLogFailures.Inc()
+			return fmt.Errorf("failed to log daily report: %w", err)
+		}
+	}
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish daily report: %w", err)
+	}
+	return nil
+}
+
+// Run reports on the day just ended each time the schedule fires, until
+// ctx is done. A failed report is logged and not retried; a run missed
+// while the worker was down is not made up.
+func (s *DailyReportService) Run(ctx context.Context) {
+	for {
+		next := s.schedule.Next(s.now())
+		s.logger.Info("next daily reconciliation report scheduled", zap.Time("at", next))
+
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		day := next.In(s.schedule.Location).AddDate(0, 0, -1)
+		if err := s.Emit(ctx, day); err != nil {
+			s.logger.Error("daily reconciliation report failed",
+				zap.Error(err),
+				zap.Time("day", day),
+			)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// seededPayments totals a fixed set of stored payments
+type seededPayments []*domain.Payment
+
+func (p seededPayments) TotalsByStatus(ctx context.Context, from, to time.Time) (map[domain.PaymentStatus]domain.PaymentTotals, error) {
+	totals := make(map[domain.PaymentStatus]domain.PaymentTotals)
+	for _, payment := range p {
+		if payment.TransactionDate.Before(from) || !payment.TransactionDate.Before(to) {
+			continue
+		}
+		t := totals[payment.Status]
+		t.Count++
+		t.Amount += payment.Amount
+		totals[payment.Status] = t
+	}
+	return totals, nil
+}
+
+// memoryOutcomeLog is an in-memory PaymentOutcomeLog
+type memoryOutcomeLog struct {
+	mu      sync.Mutex
+	entries map[domain.PaymentStatus][]time.Time
+}
+
+func (l *memoryOutcomeLog) Record(ctx context.Context, status domain.PaymentStatus, txRef string, at time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.entries == nil {
+		l.entries = make(map[domain.PaymentStatus][]time.Time)
+	}
+	l.entries[status] = append(l.entries[status], at)
+	return nil
+}
+
+func (l *memoryOutcomeLog) Count(ctx context.Context, status domain.PaymentStatus, from, to time.Time) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var count int64
+	for _, at := range l.entries[status] {
+		if !at.Before(from) && at.Before(to) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func seededPayment(t *testing.T, ref string, amount int64, at time.Time, status domain.PaymentStatus) *domain.Payment {
+	t.Helper()
+	payment, err := domain.NewPayment("GIG00001", amount, ref, at, status, time.Now())
+	require.NoError(t, err)
+	return payment
+}
+
+func TestParseDailySchedule(t *testing.T) {
+	schedule, err := ParseDailySchedule("00:05", "Africa/Lagos")
+	require.NoError(t, err)
+	assert.Equal(t, 0, schedule.Hour)
+	assert.Equal(t, 5, schedule.Minute)
+	assert.Equal(t, "Africa/Lagos", schedule.Location.String())
+
+	_, err = ParseDailySchedule("25:00", "UTC")
+	assert.Error(t, err)
+	_, err = ParseDailySchedule("00:05", "Mars/Olympus")
+	assert.Error(t, err)
+}
+
+func TestDailySchedule_Next(t *testing.T) {
+	lagos := time.FixedZone("WAT", 60*60)
+	schedule := DailySchedule{Hour: 0, Minute: 5, Location: lagos}
+
+	// 23:30 UTC on the 1st is already 00:30 on the 2nd in Lagos
+	next := schedule.Next(time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 5, 0, 0, lagos), next)
+
+	next = schedule.Next(time.Date(2025, 3, 2, 0, 4, 0, 0, lagos))
+	assert.Equal(t, time.Date(2025, 3, 2, 0, 5, 0, 0, lagos), next)
+
+	next = schedule.Next(time.Date(2025, 3, 2, 0, 5, 0, 0, lagos))
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 5, 0, 0, lagos), next, "a time exactly on schedule moves to the next day")
+}
+
+func TestDailyReport_AggregatesOneLocalDay(t *testing.T) {
+	ctx := context.Background()
+	lagos := time.FixedZone("WAT", 60*60)
+	dayStart := time.Date(2025, 3, 1, 0, 0, 0, 0, lagos)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	payments := seededPayments{
+		seededPayment(t, "TXN-PREV-DAY", 9000, dayStart.Add(-time.Second), domain.PaymentStatusComplete),
+		seededPayment(t, "TXN-MIDNIGHT", 1000, dayStart, domain.PaymentStatusComplete),
+		seededPayment(t, "TXN-NOON", 2500, dayStart.Add(12*time.Hour), domain.PaymentStatusComplete),
+		seededPayment(t, "TXN-LATE", 500, dayEnd.Add(-time.Second), domain.PaymentStatusComplete),
+		seededPayment(t, "WRITEOFF-1", 40000, dayStart.Add(13*time.Hour), domain.PaymentStatusWriteOff),
+		seededPayment(t, "TXN-NEXT-DAY", 7000, dayEnd, domain.PaymentStatusComplete),
+	}
+	outcomes := &memoryOutcomeLog{}
+	require.NoError(t, outcomes.Record(ctx, domain.PaymentStatusDuplicate, "TXN-NOON", dayStart.Add(12*time.Hour+time.Minute)))
+	require.NoError(t, outcomes.Record(ctx, domain.PaymentStatusDuplicate, "TXN-PREV-DAY", dayStart.Add(-time.Minute)))
+	require.NoError(t, outcomes.Record(ctx, domain.PaymentStatusFailed, "TXN-FAILED-1", dayStart.Add(3*time.Hour)))
+	require.NoError(t, outcomes.Record(ctx, domain.PaymentStatusFailed, "TXN-FAILED-2", dayEnd.Add(-time.Millisecond)))
+	require.NoError(t, outcomes.Record(ctx, domain.PaymentStatusFailed, "TXN-FAILED-3", dayEnd))
+
+	publisher := &recordingPublisher{}
+	reports := NewDailyReportService(payments, outcomes, publisher, DailySchedule{Location: lagos}, zap.NewNop())
+	generatedAt := dayEnd.Add(5 * time.Minute)
+	reports.now = func() time.Time { return generatedAt }
+
+	// Any instant on the day selects it, wherever it is expressed
+	require.NoError(t, reports.Emit(ctx, dayStart.Add(20*time.Hour).UTC()))
+
+	events := publisher.eventsOfType(domain.EventTypeReconciliationDaily)
+	require.Len(t, events, 1)
+	assert.Equal(t, "2025-03-01", events[0].GetAggregateID())
+	assert.Equal(t, domain.DailyReconciliationPayload{
+		ReportDate:     "2025-03-01",
+		Timezone:       "WAT",
+		PeriodStart:    dayStart,
+		PeriodEnd:      dayEnd,
+		TotalCount:     6,
+		TotalAmount:    4000,
+		CompletedCount: 3,
+		DuplicateCount: 1,
+		FailedCount:    2,
+		GeneratedAt:    generatedAt,
+	}, events[0].GetPayload())
+}
+
+func TestDailyReport_ZeroActivityDay(t *testing.T) {
+	publisher := &recordingPublisher{}
+	reports := NewDailyReportService(seededPayments{}, &memoryOutcomeLog{}, publisher, DailySchedule{Location: time.UTC}, zap.NewNop())
+
+	require.NoError(t, reports.Emit(context.Background(), time.Date(2025, 12, 25, 9, 0, 0, 0, time.UTC)))
+
+	events := publisher.eventsOfType(domain.EventTypeReconciliationDaily)
+	require.Len(t, events, 1)
+	report := events[0].GetPayload().(domain.DailyReconciliationPayload)
+	assert.Equal(t, "2025-12-25", report.ReportDate)
+	assert.Zero(t, report.TotalCount)
+	assert.Zero(t, report.TotalAmount)
+	assert.Zero(t, report.CompletedCount)
+	assert.Zero(t, report.DuplicateCount)
+	assert.Zero(t, report.FailedCount)
+}
+
+func TestProcessPayment_RecordsDuplicateAndFailedOutcomes(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	outcomes := &memoryOutcomeLog{}
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithOutcomeLog(outcomes))
+
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, "TXN-DUP").Return(true, nil)
+	mockPaymentRepo.On("FindByTransactionReference", ctx, mock.Anything, "TXN-DUP").Return(&domain.Payment{ID: "payment-1"}, nil)
+	mockCustomerRepo.On("FindByID", ctx, "GIG00001").Return(&domain.Customer{ID: "GIG00001", AssetValue: 1000, OutstandingBalance: 1000}, nil)
+
+	req := completePaymentRequest("GIG00001", "TXN-DUP")
+	_, err := service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+
+	req.TransactionReference = "TXN-FAILED"
+	req.PaymentStatus = "FAILED"
+	_, err = service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+
+	req.DryRun = true
+	_, err = service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+
+	window := time.Now()
+	duplicates, _ := outcomes.Count(ctx, domain.PaymentStatusDuplicate, window.Add(-time.Minute), window.Add(time.Minute))
+	failed, _ := outcomes.Count(ctx, domain.PaymentStatusFailed, window.Add(-time.Minute), window.Add(time.Minute))
+	assert.Equal(t, int64(1), duplicates)
+	assert.Equal(t, int64(1), failed, "dry runs are not recorded")
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidDateRange = errors.New("from must not be after to")
+	ErrDateRangeTooWide = errors.New("date range exceeds the maximum allowed width")
+)
+
+// WithMaxPaymentDateRange caps the width of a date range query so finance
+// exports cannot trigger unbounded scans. Zero leaves it uncapped.
+func WithMaxPaymentDateRange(d time.Duration) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.maxDateRange = d
+	}
+}
+
+// DateRangeQuery selects payments with From <= transaction_date <= To
+// that match Filter. An empty CustomerID spans all customers.
+type DateRangeQuery struct {
+	From       time.Time
+	To         time.Time
+	CustomerID string
+	PaginationParams
+}
+
+type DateRangePaymentsResponse struct {
+	PaginatedPaymentsResponse
+	// TotalAmount is the sum over the whole range, not just this page, in kobo
+	TotalAmount int64
+}
+
+// checkDateRange validates the range and normalizes the customer filter
+func (s *PaymentService) checkDateRange(q DateRangeQuery) (DateRangeQuery, error) {
+	if q.From.After(q.To) {
+		return q, ErrInvalidDateRange
+	}
+	if s.maxDateRange > 0 && q.To.Sub(q.From) > s.maxDateRange {
+		return q, fmt.Errorf("%w (%s)", ErrDateRangeTooWide, s.maxDateRange)
+	}
+	if q.CustomerID != "" {
+		q.CustomerID = NormalizeCustomerID(q.CustomerID)
+	}
+	return q, nil
+}
+
+// GetPaymentsByDateRange pages through payments in a transaction date range,
+// optionally for a single customer, with totals across the whole range.
+func (s *PaymentService) GetPaymentsByDateRange(ctx context.Context, q DateRangeQuery) (*DateRangePaymentsResponse, error) {
+	q, err := s.checkDateRange(q)
+	if err != nil {
+		return nil, err
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	q.PageSize = s.pageSizes.size(q.PageSize)
+
+	totals, err := s.paymentRepo.TotalsByDateRange(ctx, q.From, q.To, q.CustomerID, q.Filter)
+	if err != nil {
+		logFailure(s.logger, "failed to total payments by date range", err,
+			zap.String("customer_id", q.CustomerID),
+		)
+		return nil, fmt.Errorf("failed to count payments: %w", err)
+	}
+
+	offset := (q.Page - 1) * q.PageSize
+
+	payments, err := s.paymentRepo.FindByDateRange(ctx, q.From, q.To, q.CustomerID, q.Filter, q.PageSize, offset)
+	if err != nil {
+		logFailure(s.logger, "failed to get payments by date range", err,
+			zap.String("customer_id", q.CustomerID),
+		)
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	totalPages := int(totals.Count) / q.PageSize
+	if int(totals.Count)%q.PageSize > 0 {
+		totalPages++
+	}
+
+	s.logger.Info("retrieved payments by date range",
+		zap.Time("from", q.From),
+		zap.Time("to", q.To),
+		zap.String("customer_id", q.CustomerID),
+		zap.Int("count", len(payments)),
+		zap.Int64("total_count", totals.Count),
+	)
+
+	return &DateRangePaymentsResponse{
+		PaginatedPaymentsResponse: PaginatedPaymentsResponse{
+			Payments:   payments,
+			TotalCount: totals.Count,
+			Page:       q.Page,
+			PageSize:   q.PageSize,
+			TotalPages: totalPages,
+		},
+		TotalAmount: totals.Amount,
+	}, nil
+}
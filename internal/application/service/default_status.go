@@ -0,0 +1,52 @@
+package service
+
+import (
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+// Reasons recorded on customer.updated when a payment moves a customer
+// into or out of DEFAULTED
+const (
+	defaultReachedReason   = "missed installments reached the default threshold"
+	defaultRecoveredReason = "payment brought arrears under the default threshold"
+)
+
+// WithDefaultThreshold marks customers DEFAULTED once they have missed
+// missed weekly installments, and reactivates them when a payment brings
+// them back under it. Zero disables default tracking.
+func WithDefaultThreshold(missed int) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.defaultThreshold = missed
+	}
+}
+
+// defaultStatusChangedEvent announces a payment that moved the customer
+// into or out of DEFAULTED, and is nil for any other transition, such as
+// ACTIVE to COMPLETED, which payment.processed already carries
+func (s *PaymentService) defaultStatusChangedEvent(correlationID string, customer *domain.Customer, previousStatus domain.CustomerStatus) domain.DomainEvent {
+	var reason string
+	switch {
+	case customer.Status == previousStatus:
+		return nil
+	case previousStatus == domain.CustomerStatusDefaulted:
+		reason = defaultRecoveredReason
+	case customer.Status == domain.CustomerStatusDefaulted:
+		reason = defaultReachedReason
+	default:
+		return nil
+	}
+
+	now := s.clock.Now()
+	event := domain.NewCustomerUpdatedEvent(customer.ID, domain.CustomerUpdatedPayload{
+		CustomerID:         customer.ID,
+		PreviousStatus:     string(previousStatus),
+		Status:             string(customer.Status),
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		Reason:             reason,
+		UpdatedAt:          now,
+	}, now)
+	event.CorrelationID = correlationID
+	event.Sequence = customer.Version
+	return event
+}
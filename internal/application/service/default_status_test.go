@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestProcessPayment_DefaultedCustomerRecovery(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		wantStatus  domain.CustomerStatus
+		wantUpdated bool
+	}{
+		{"pays a little", 1000000, domain.CustomerStatusDefaulted, false},
+		{"pays enough", 3000000, domain.CustomerStatusActive, true},
+		{"pays it all", 10000000, domain.CustomerStatusCompleted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			customerID := "GIG00050"
+			req := completePaymentRequest(customerID, "TXN050")
+			req.TransactionAmount = tt.amount
+
+			mockCustomerRepo := new(MockCustomerRepository)
+			mockPaymentRepo := new(MockPaymentRepository)
+			publisher := &recordingPublisher{}
+			service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(),
+				WithDefaultThreshold(3))
+
+			// 1,000,000 kobo a week; five installments due and none paid
+			customer := &domain.Customer{
+				ID:                 customerID,
+				AssetValue:         10000000,
+				RepaymentTermWeeks: 10,
+				OutstandingBalance: 10000000,
+				DeploymentDate:     req.TransactionDate.Add(-5*7*24*time.Hour - time.Hour),
+				Status:             domain.CustomerStatusDefaulted,
+				Version:            1,
+			}
+			mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN050").Return(false, nil)
+			mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+			mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+			mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+			result, err := service.ProcessPayment(ctx, req)
+			require.NoError(t, err)
+			assert.True(t, result.Processed)
+			assert.Equal(t, tt.wantStatus, customer.Status)
+			require.NoError(t, service.WaitForPublishes(ctx))
+
+			updates := publisher.eventsOfType(domain.EventTypeCustomerUpdated)
+			if !tt.wantUpdated {
+				assert.Empty(t, updates)
+				return
+			}
+			require.Len(t, updates, 1)
+			payload := updates[0].(*domain.CustomerUpdatedEvent).Payload
+			assert.Equal(t, string(domain.CustomerStatusDefaulted), payload.PreviousStatus)
+			assert.Equal(t, string(tt.wantStatus), payload.Status)
+			assert.Equal(t, defaultRecoveredReason, payload.Reason)
+		})
+	}
+}
+
+func TestProcessPayment_DefaultTrackingDisabledByDefault(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00051"
+	req := completePaymentRequest(customerID, "TXN051")
+	req.TransactionAmount = 1000000
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	customer := &domain.Customer{
+		ID:                 customerID,
+		AssetValue:         10000000,
+		RepaymentTermWeeks: 10,
+		OutstandingBalance: 10000000,
+		DeploymentDate:     req.TransactionDate.Add(-9*7*24*time.Hour - time.Hour),
+		Status:             domain.CustomerStatusActive,
+		Version:            1,
+	}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN051").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	_, err := service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CustomerStatusActive, customer.Status)
+}
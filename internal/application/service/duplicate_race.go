@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// reversalAttempts bounds how often undoing a double-applied payment is
+// retried when other writers keep winning the customer's optimistic lock
+const reversalAttempts = 3
+
+// resolveDuplicateAfterUpdate handles a payment whose insert lost to a
+// concurrent request with the same reference after this one had already
+// saved the customer. ProcessPayment only writes the payment row once its
+// own balance change is saved, so whoever holds the reference has already
+// applied it and the change made here is always one too many: it is
+// reversed whatever the stored row says.
+//
+// The stored row decides the answer. The same customer and amount means it
+// was a genuine duplicate and the caller gets the usual duplicate response.
+// A different payment under the reference, or no row at all because the
+// other writer's insert never committed, is an error the caller can retry
+// or investigate; the reference is not recorded for this payment. So is a
+// stored row that can't be read.
+func (s *PaymentService) resolveDuplicateAfterUpdate(ctx context.Context, customer *domain.Customer, req ProcessPaymentRequest, applied int64) (*ProcessPaymentResponse, error) {
+	stored, findErr := s.paymentRepo.FindByTransactionReference(ctx, customer.ID, req.TransactionReference)
+	if errors.Is(findErr, domain.ErrPaymentNotFound) {
+		findErr = nil
+	}
+	matched := stored != nil && stored.CustomerID == customer.ID && stored.Amount == req.TransactionAmount
+
+	fields := []zap.Field{
+		zap.String("customer_id", customer.ID),
+		zap.String("tx_ref", req.TransactionReference),
+		zap.Int64("amount", req.TransactionAmount),
+		zap.Int64("applied", applied),
+		zap.Int64("balance_before_reversal", customer.OutstandingBalance),
+		zap.Bool("stored_found", stored != nil),
+		zap.Bool("stored_matches", matched),
+	}
+	if findErr != nil {
+		fields = append(fields, zap.NamedError("lookup_error", findErr))
+	}
+	if stored != nil {
+		fields = append(fields,
+			zap.String("stored_customer_id", stored.CustomerID),
+			zap.Int64("stored_amount", stored.Amount),
+		)
+	}
+
+	// The customer row has already changed, so the reversal must finish even
+	// if the client has gone away
+	reversed, err := s.reversePayment(context.WithoutCancel(ctx), customer.ID, req, applied)
+	if err != nil {
+		s.logger.Error("failed to reverse payment applied twice, customer balance needs manual correction",
+			append(fields, zap.Error(err))...,
+		)
+		return nil, fmt.Errorf("failed to reverse duplicate payment: %w", err)
+	}
+	fields = append(fields, zap.Int64("balance_after_reversal", reversed.OutstandingBalance))
+
+	if findErr != nil {
+		s.logger.Error("could not check the payment stored under a duplicate reference, balance change reversed", fields...)
+		return nil, fmt.Errorf("failed to get payment for duplicate reference: %w", findErr)
+	}
+	if !matched {
+		s.logger.Error("transaction reference taken by a different payment after customer update, balance change reversed", fields...)
+		if stored == nil {
+			return nil, fmt.Errorf("payment %s was not recorded by the concurrent request, retry it", req.TransactionReference)
+		}
+		return nil, fmt.Errorf("transaction reference %s belongs to another payment: %w", req.TransactionReference, domain.ErrDuplicateTransaction)
+	}
+
+	s.logger.Warn("duplicate payment detected after customer update, balance change reversed", fields...)
+	s.recordOutcome(ctx, domain.PaymentStatusDuplicate, req)
+
+	return (&ProcessPaymentResponse{
+		Outcome:            OutcomeDuplicate,
+		Success:            true,
+		Processed:          true,
+		Message:            "duplicate transaction - already processed",
+		CustomerID:         reversed.ID,
+		OutstandingBalance: reversed.OutstandingBalance,
+		TotalPaid:          reversed.TotalPaid,
+		PaymentProgress:    reversed.GetPaymentProgress(),
+		IsFullyPaid:        reversed.IsFullyPaid(),
+	}).withPayment(stored), nil
+}
+
+// reversePayment takes applied back off a fresh copy of the customer, so
+// anything other writers saved meanwhile is kept
+func (s *PaymentService) reversePayment(ctx context.Context, customerID string, req ProcessPaymentRequest, applied int64) (*domain.Customer, error) {
+	var err error
+	for attempt := 0; attempt < reversalAttempts; attempt++ {
+		var customer *domain.Customer
+		if attempt == 0 {
+			customer, err = s.customerRepo.FindByID(ctx, customerID)
+		} else {
+			customer, err = s.refetchCustomer(ctx, customerID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = customer.ReversePayment(applied); err != nil {
+			return nil, err
+		}
+		customer.UpdateDefaultStatus(req.TransactionDate, s.defaultThreshold)
+
+		err = s.customerRepo.Save(ctx, customer)
+		if err == nil {
+			return customer, nil
+		}
+		if !errors.Is(err, domain.ErrOptimisticLock) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
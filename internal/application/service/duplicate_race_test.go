@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// raceDuplicate sets up a payment whose insert loses to a concurrent
+// request with the same reference. otherWriter runs just before the insert
+// fails, standing in for whatever that request did to the customer.
+func raceDuplicate(customer *domain.Customer, txRef string, stored *domain.Payment, otherWriter func()) (*PaymentService, *MockCustomerRepository, *observer.ObservedLogs) {
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	core, logs := observer.New(zapcore.WarnLevel)
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.New(core))
+
+	mockPaymentRepo.On("ExistsByTransactionReference", mock.Anything, mock.Anything, txRef).Return(false, nil)
+	mockCustomerRepo.On("FindByID", mock.Anything, customer.ID).Return(customer, nil)
+	mockCustomerRepo.On("Save", mock.Anything, customer).Return(nil)
+	mockPaymentRepo.On("Save", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		if otherWriter != nil {
+			otherWriter()
+		}
+	}).Return(domain.ErrDuplicateTransaction)
+	if stored != nil {
+		mockPaymentRepo.On("FindByTransactionReference", mock.Anything, mock.Anything, txRef).Return(stored, nil)
+	} else {
+		mockPaymentRepo.On("FindByTransactionReference", mock.Anything, mock.Anything, txRef).Return(nil, domain.ErrPaymentNotFound)
+	}
+	return service, mockCustomerRepo, logs
+}
+
+func TestProcessPayment_DuplicateAfterUpdateIsAppliedOnce(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00030", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	req := completePaymentRequest(customer.ID, "TXN030")
+	stored := &domain.Payment{ID: "payment-30", CustomerID: customer.ID, Amount: req.TransactionAmount, TransactionReference: "TXN030", Status: domain.PaymentStatusComplete}
+
+	// The concurrent request saved the same payment against the customer
+	// and then won the insert
+	service, mockCustomerRepo, logs := raceDuplicate(customer, "TXN030", stored, func() {
+		require.NoError(t, customer.ApplyPayment(req.TransactionAmount, req.TransactionDate))
+	})
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, result.Outcome)
+	assert.True(t, result.Processed)
+	assert.Equal(t, "duplicate transaction - already processed", result.Message)
+	assert.Equal(t, "payment-30", result.PaymentID, "the payment that holds the reference")
+	assert.Equal(t, int64(99000000), result.OutstandingBalance, "applied once, by the request that holds the reference")
+	assert.Equal(t, int64(1000000), customer.TotalPaid)
+	assert.NoError(t, customer.Validate())
+	mockCustomerRepo.AssertNumberOfCalls(t, "Save", 2)
+
+	entries := logs.FilterMessage("duplicate payment detected after customer update, balance change reversed").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "TXN030", fields["tx_ref"])
+	assert.Equal(t, true, fields["stored_matches"])
+	assert.Equal(t, int64(98000000), fields["balance_before_reversal"])
+	assert.Equal(t, int64(99000000), fields["balance_after_reversal"])
+}
+
+func TestProcessPayment_DuplicateAfterUpdateForAnotherPayment(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00031", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	stored := &domain.Payment{CustomerID: "GIG00099", Amount: 500, TransactionReference: "TXN031", Status: domain.PaymentStatusComplete}
+
+	service, _, logs := raceDuplicate(customer, "TXN031", stored, nil)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(customer.ID, "TXN031"))
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, domain.ErrDuplicateTransaction)
+	assert.Equal(t, int64(100000000), customer.OutstandingBalance, "our balance change is reversed")
+	assert.Zero(t, customer.TotalPaid)
+
+	entries := logs.FilterMessage("transaction reference taken by a different payment after customer update, balance change reversed").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+	assert.Equal(t, "GIG00099", entries[0].ContextMap()["stored_customer_id"])
+}
+
+func TestProcessPayment_DuplicateAfterUpdateWithNoStoredPayment(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00032", AssetValue: 1000000, OutstandingBalance: 1000000, Status: domain.CustomerStatusActive, Version: 1}
+
+	service, _, _ := raceDuplicate(customer, "TXN032", nil, nil)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(customer.ID, "TXN032"))
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, domain.ErrDuplicateTransaction)
+	assert.Equal(t, int64(1000000), customer.OutstandingBalance)
+	assert.Equal(t, domain.CustomerStatusActive, customer.Status, "the loan the payment settled is reopened")
+}
+
+func TestProcessPayment_DuplicateAfterUpdateReversalRetriesLockConflicts(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00033", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	// What the reversal reads back: our payment is saved, and the second
+	// read also carries another writer's payment that beat the first save
+	saved := &domain.Customer{ID: "GIG00033", AssetValue: 100000000, OutstandingBalance: 99000000, TotalPaid: 1000000, Status: domain.CustomerStatusActive, Version: 2}
+	raced := &domain.Customer{ID: "GIG00033", AssetValue: 100000000, OutstandingBalance: 98500000, TotalPaid: 1500000, Status: domain.CustomerStatusActive, Version: 3}
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	mockPaymentRepo.On("ExistsByTransactionReference", mock.Anything, mock.Anything, "TXN033").Return(false, nil)
+	mockCustomerRepo.On("FindByID", mock.Anything, "GIG00033").Return(customer, nil).Once()
+	mockCustomerRepo.On("FindByID", mock.Anything, "GIG00033").Return(saved, nil).Once()
+	mockCustomerRepo.On("FindByID", mock.Anything, "GIG00033").Return(raced, nil).Once()
+	mockCustomerRepo.On("Save", mock.Anything, customer).Return(nil).Once()
+	mockCustomerRepo.On("Save", mock.Anything, saved).Return(domain.ErrOptimisticLock).Once()
+	mockCustomerRepo.On("Save", mock.Anything, raced).Return(nil).Once()
+	mockPaymentRepo.On("Save", mock.Anything, mock.Anything).Return(domain.ErrDuplicateTransaction)
+	mockPaymentRepo.On("FindByTransactionReference", mock.Anything, mock.Anything, "TXN033").Return(nil, domain.ErrPaymentNotFound)
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(customer.ID, "TXN033"))
+
+	assert.Error(t, err)
+	mockCustomerRepo.AssertExpectations(t)
+	assert.Equal(t, int64(500000), raced.TotalPaid, "only our payment is taken back")
+	assert.Equal(t, int64(99500000), raced.OutstandingBalance)
+}
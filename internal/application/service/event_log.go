@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
+	"go.uber.org/zap"
+)
+
+var eventLogFailures = metrics.NewCounter(
+	"event_log_append_failures_total",
+	"Events not published because they could not be written to the event log.",
+)
+
+// WithEventLog keeps every event the service publishes in log. An event
+// that records a change is appended in the transaction that saves the
+// change (see WithTransactor), so the two commit or roll back together;
+// the rest are appended just before they are published. An event that
+// can't be logged is never published.
+func WithEventLog(log domain.EventLog) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.eventLog = log
+	}
+}
+
+// logEvents appends events through log, the transaction's when they record
+// a change being saved in one. Without an event log it does nothing.
+func (s *PaymentService) logEvents(ctx context.Context, log domain.EventLog, events ...domain.DomainEvent) error {
+	if s.eventLog == nil {
+		return nil
+	}
+	for _, event := range events {
+		if _, err := log.Append(ctx, event); err != nil {
+			eventLogFailures.Inc()
+			logFailure(s.logger, "failed to write event to event log", err,
+				zap.String("event_id", event.GetEventID()),
+				zap.String("event_type", event.GetEventType()),
+			)
+			return fmt.Errorf("failed to log event: %w", err)
+		}
+	}
+	return nil
+}
+
+// commit saves a change through save and logs the events raise returns in
+// the same transaction. raise runs once save has succeeded, so the events
+// carry what was saved, and only when the service publishes events. The
+// events are returned for publishCommitted once the transaction is done.
+func (s *PaymentService) commit(ctx context.Context, save func(repos domain.TxRepositories) error, raise func() []domain.DomainEvent) ([]domain.DomainEvent, error) {
+	var events []domain.DomainEvent
+	err := s.inTx(ctx, func(repos domain.TxRepositories) error {
+		if err := save(repos); err != nil {
+			return err
+		}
+		if s.eventPublisher != nil {
+			events = raise()
+		}
+		return s.logEvents(ctx, repos.EventLog, events...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// publishCommitted publishes events commit has already logged
+func (s *PaymentService) publishCommitted(ctx context.Context, events []domain.DomainEvent) error {
+	for _, event := range events {
+		if err := s.dispatchEvent(ctx, event, s.publishesSync(ctx, event)); err != nil {
+			return fmt.Errorf("failed to publish %s event: %w", event.GetEventType(), err)
+		}
+	}
+	return nil
+}
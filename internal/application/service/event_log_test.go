@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryEventLog is an in-memory EventLog that fails every append with err
+// when set
+type memoryEventLog struct {
+	events []domain.DomainEvent
+	err    error
+}
+
+func (l *memoryEventLog) Append(ctx context.Context, event domain.DomainEvent) (int64, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	l.events = append(l.events, event)
+	return int64(len(l.events)), nil
+}
+
+func (l *memoryEventLog) After(ctx context.Context, afterSeq int64, until time.Time, limit int) ([]*domain.LoggedEvent, error) {
+	return nil, nil
+}
+
+func (l *memoryEventLog) eventIDs() []string {
+	var ids []string
+	for _, event := range l.events {
+		ids = append(ids, event.GetEventID())
+	}
+	return ids
+}
+
+func publishedIDs(publisher *recordingPublisher) []string {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	var ids []string
+	for _, event := range publisher.events {
+		ids = append(ids, event.GetEventID())
+	}
+	return ids
+}
+
+func TestProcessPayment_LogsEventsInThePaymentTransaction(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	customers := new(MockCustomerRepository)
+	payments := new(MockPaymentRepository)
+	customers.On("FindByID", ctx, customer.ID).Return(customer, nil)
+	payments.On("ExistsByTransactionReference", ctx, customer.ID, "TXN001").Return(false, nil)
+	tx := newRecordingTransactor()
+	tx.customers.On("Save", ctx, customer).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.Customer).Version++
+	}).Return(nil)
+	tx.payments.On("Save", ctx, mock.AnythingOfType("*domain.Payment")).Return(nil)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithTransactor(tx), WithEventLog(tx.eventLog), WithSyncPublishing(true))
+
+	_, err := service.ProcessPayment(ctx, transactionTestRequest(customer.ID))
+
+	require.NoError(t, err)
+	require.Len(t, tx.eventLog.events, 2)
+	assert.Equal(t, domain.EventTypePaymentReceived, tx.eventLog.events[0].GetEventType())
+	assert.Equal(t, domain.EventTypePaymentProcessed, tx.eventLog.events[1].GetEventType())
+	assert.Equal(t, int64(2), tx.eventLog.events[1].(*domain.PaymentProcessedEvent).Sequence,
+		"the event is raised from the customer as saved")
+	assert.Equal(t, tx.eventLog.eventIDs(), publishedIDs(publisher))
+}
+
+func TestProcessPayment_FailedEventLogRollsBackPayment(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	customers := new(MockCustomerRepository)
+	payments := new(MockPaymentRepository)
+	customers.On("FindByID", ctx, customer.ID).Return(customer, nil)
+	payments.On("ExistsByTransactionReference", ctx, customer.ID, "TXN001").Return(false, nil)
+	tx := newRecordingTransactor()
+	errDown := errors.New("event log down")
+	tx.eventLog.err = errDown
+	tx.customers.On("Save", ctx, customer).Return(nil)
+	tx.payments.On("Save", ctx, mock.AnythingOfType("*domain.Payment")).Return(nil)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithTransactor(tx), WithEventLog(tx.eventLog))
+
+	_, err := service.ProcessPayment(ctx, transactionTestRequest(customer.ID))
+	require.NoError(t, service.WaitForPublishes(ctx))
+
+	require.ErrorIs(t, err, errDown)
+	assert.Equal(t, 0, tx.committed)
+	assert.Equal(t, 1, tx.rolledBack, "the payment must not commit without its event")
+	assert.Empty(t, publishedIDs(publisher))
+}
+
+func TestProcessPayment_DoesNotPublishUnloggedEvents(t *testing.T) {
+	ctx := context.Background()
+	payments := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+	log := &memoryEventLog{err: errors.New("event log down")}
+	service := NewPaymentService(new(MockCustomerRepository), payments, publisher, zap.NewNop(),
+		WithEventLog(log), WithSyncPublishing(true))
+
+	_, err := service.ProcessPayment(ctx, ProcessPaymentRequest{
+		CustomerID:           "GIG00001",
+		PaymentStatus:        "PENDING",
+		TransactionAmount:    1000000,
+		TransactionDate:      time.Now(),
+		TransactionReference: "TXN001",
+	})
+
+	assert.ErrorIs(t, err, log.err)
+	assert.Empty(t, publishedIDs(publisher))
+}
+
+func TestDailyReport_LogsBeforePublishing(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	log := &memoryEventLog{}
+	reports := NewDailyReportService(seededPayments{}, &memoryOutcomeLog{}, publisher, DailySchedule{Location: time.UTC}, zap.NewNop(),
+		WithDailyReportEventLog(log))
+
+	require.NoError(t, reports.Emit(ctx, time.Date(2025, 12, 25, 9, 0, 0, 0, time.UTC)))
+	assert.Equal(t, log.eventIDs(), publishedIDs(publisher))
+
+	log.err = errors.New("event log down")
+	require.ErrorIs(t, reports.Emit(ctx, time.Date(2025, 12, 26, 9, 0, 0, 0, time.UTC)), log.err)
+	assert.Len(t, publishedIDs(publisher), 1, "an unlogged report is not published")
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+// Feature flags that roll out a behaviour to a share of customers. Each
+// only narrows a behaviour its own option turns on; an unset flag leaves
+// the option's behaviour as it is.
+const (
+	// FeatureAtomicApply applies payments through WithAtomicApply's applier
+	FeatureAtomicApply = "atomic_apply"
+	// FeatureSyncPublishing publishes a customer's events before responding,
+	// as WithSyncPublishing does
+	FeatureSyncPublishing = "sync_event_publishing"
+	// FeatureVelocityCheck flags a customer's payments as WithVelocityCheck does
+	FeatureVelocityCheck = "velocity_check"
+)
+
+// WithFeatureFlags decides per customer whether the behaviours behind the
+// Feature* flags apply. Without it they apply to everyone their options
+// turn them on for.
+func WithFeatureFlags(flags domain.FeatureFlags) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.featureFlags = flags
+	}
+}
+
+// featureEnabled reports whether the flag lets a behaviour that is already
+// turned on apply to the customer
+func (s *PaymentService) featureEnabled(ctx context.Context, name, customerID string) bool {
+	if s.featureFlags == nil {
+		return true
+	}
+	return s.featureFlags.Enabled(ctx, name, customerID, true)
+}
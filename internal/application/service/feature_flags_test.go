@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// customerFlags turns each flag on for the listed customers only; flags
+// not in the map answer the fallback
+type customerFlags map[string][]string
+
+func (f customerFlags) Enabled(ctx context.Context, name, customerID string, fallback bool) bool {
+	customers, ok := f[name]
+	if !ok {
+		return fallback
+	}
+	for _, id := range customers {
+		if id == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+func newFlaggedTestService(flags domain.FeatureFlags, publisher domain.EventPublisher, opts ...PaymentServiceOption) *PaymentService {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, mock.Anything).Return(false, nil)
+	for _, id := range []string{"GIG00080", "GIG00081"} {
+		customer := &domain.Customer{ID: id, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+		mockCustomerRepo.On("FindByID", ctx, id).Return(customer, nil)
+	}
+	mockCustomerRepo.On("Save", ctx, mock.Anything).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	return NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(),
+		append(opts, WithFeatureFlags(flags))...)
+}
+
+func TestFeatureFlags_VelocityCheckOnlyForRolledOutCustomers(t *testing.T) {
+	ctx := context.Background()
+	tracker := &memoryVelocityTracker{}
+	service := newFlaggedTestService(customerFlags{FeatureVelocityCheck: {"GIG00080"}}, nil,
+		WithVelocityCheck(tracker, VelocityLimits{MaxPayments: 100}))
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00080", "TXN080"))
+	require.NoError(t, err)
+	_, err = service.ProcessPayment(ctx, completePaymentRequest("GIG00081", "TXN081"))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), tracker.counts["GIG00080"].Count)
+	assert.NotContains(t, tracker.counts, "GIG00081")
+}
+
+func TestFeatureFlags_SyncPublishingOnlyForRolledOutCustomers(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{err: errors.New("broker down"), failOn: domain.EventTypePaymentReceived}
+	service := newFlaggedTestService(customerFlags{FeatureSyncPublishing: {"GIG00080"}}, publisher,
+		WithSyncPublishing(true))
+
+	// Publishing synchronously, the failure reaches the caller
+	_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00080", "TXN080"))
+	assert.Error(t, err)
+
+	// Outside the rollout the event is published in the background as before
+	result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00081", "TXN081"))
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	require.NoError(t, service.WaitForPublishes(ctx))
+}
+
+func TestFeatureFlags_UnsetFlagLeavesBehaviourOn(t *testing.T) {
+	ctx := context.Background()
+	tracker := &memoryVelocityTracker{}
+	service := newFlaggedTestService(customerFlags{}, nil, WithVelocityCheck(tracker, VelocityLimits{MaxPayments: 100}))
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00081", "TXN081"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tracker.counts["GIG00081"].Count)
+}
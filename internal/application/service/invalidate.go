@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// CustomerViewInvalidator drops cached read views of a customer, such as
+// HTTP responses, once their account has changed
+type CustomerViewInvalidator interface {
+	InvalidateCustomer(ctx context.Context, customerID string) error
+}
+
+// WithCustomerViewInvalidator has every write to a customer's account
+// invalidate inv, so cached reads never outlive the data they showed
+func WithCustomerViewInvalidator(inv CustomerViewInvalidator) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.viewInvalidator = inv
+	}
+}
+
+// invalidateCustomerViews is best effort: a failure leaves cached views to
+// expire on their own TTL
+func (s *PaymentService) invalidateCustomerViews(ctx context.Context, customerID string) {
+	if s.viewInvalidator == nil {
+		return
+	}
+	if err := s.viewInvalidator.InvalidateCustomer(ctx, customerID); err != nil {
+		s.logger.Warn("failed to invalidate cached customer views",
+			zap.Error(err),
+			zap.String("customer_id", customerID),
+		)
+	}
+}
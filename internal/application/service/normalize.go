@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TransactionReferenceRule says how transaction references are canonicalized
+// before dedup. References must still match what the provider sends back,
+// so the default only strips surrounding whitespace.
+type TransactionReferenceRule string
+
+const (
+	// TransactionReferenceTrim strips surrounding whitespace and keeps case
+	TransactionReferenceTrim TransactionReferenceRule = "trim"
+	// TransactionReferenceUpper also uppercases, for providers whose
+	// references are case-insensitive
+	TransactionReferenceUpper TransactionReferenceRule = "upper"
+)
+
+// ParseTransactionReferenceRule validates a configured rule; empty means trim
+func ParseTransactionReferenceRule(s string) (TransactionReferenceRule, error) {
+	switch rule := TransactionReferenceRule(strings.ToLower(strings.TrimSpace(s))); rule {
+	case "":
+		return TransactionReferenceTrim, nil
+	case TransactionReferenceTrim, TransactionReferenceUpper:
+		return rule, nil
+	default:
+		return "", fmt.Errorf("unknown transaction reference rule %q", s)
+	}
+}
+
+func (r TransactionReferenceRule) Normalize(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if r == TransactionReferenceUpper {
+		ref = strings.ToUpper(ref)
+	}
+	return ref
+}
+
+// NormalizeCustomerID trims and uppercases so "gig00001 " and "GIG00001"
+// resolve to the same customer
+func NormalizeCustomerID(customerID string) string {
+	return strings.ToUpper(strings.TrimSpace(customerID))
+}
+
+// normalizeRequest runs before any lookup, dedup check or event so every
+// step sees the canonical identifiers
+func (s *PaymentService) normalizeRequest(req ProcessPaymentRequest) ProcessPaymentRequest {
+	req.CustomerID = NormalizeCustomerID(req.CustomerID)
+	req.TransactionReference = s.txRefRule.Normalize(req.TransactionReference)
+	req.PaymentStatus = strings.TrimSpace(req.PaymentStatus)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	return req
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNormalizeCustomerID(t *testing.T) {
+	for _, in := range []string{"GIG00001", "gig00001", "  GIG00001", "Gig00001\t\n", " gIg00001 "} {
+		assert.Equal(t, "GIG00001", NormalizeCustomerID(in), "%q", in)
+	}
+}
+
+func TestTransactionReferenceRule(t *testing.T) {
+	assert.Equal(t, "VPay-001", TransactionReferenceTrim.Normalize("  VPay-001 "))
+	assert.Equal(t, "VPAY-001", TransactionReferenceUpper.Normalize("  VPay-001 "))
+
+	rule, err := ParseTransactionReferenceRule("")
+	require.NoError(t, err)
+	assert.Equal(t, TransactionReferenceTrim, rule)
+
+	rule, err = ParseTransactionReferenceRule("UPPER")
+	require.NoError(t, err)
+	assert.Equal(t, TransactionReferenceUpper, rule)
+
+	_, err = ParseTransactionReferenceRule("lower")
+	assert.Error(t, err)
+}
+
+func TestProcessPayment_NormalizesBeforeLookupAndDedup(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       TransactionReferenceRule
+		customerID string
+		txRef      string
+		wantRef    string
+	}{
+		{"whitespace", TransactionReferenceTrim, "  GIG00060\t", " TXN060 ", "TXN060"},
+		{"lowercase customer", TransactionReferenceTrim, "gig00060", "Txn060", "Txn060"},
+		{"upper rule", TransactionReferenceUpper, " Gig00060", " txn060", "TXN060"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCustomerRepo := new(MockCustomerRepository)
+			mockPaymentRepo := new(MockPaymentRepository)
+
+			customer := &domain.Customer{ID: "GIG00060", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+			mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, tt.wantRef).Return(false, nil)
+			mockCustomerRepo.On("FindByID", ctx, "GIG00060").Return(customer, nil)
+			mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+			mockPaymentRepo.On("Save", ctx, mock.MatchedBy(func(p *domain.Payment) bool {
+				return p.CustomerID == "GIG00060" && p.TransactionReference == tt.wantRef
+			})).Return(nil)
+
+			service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(),
+				WithTransactionReferenceRule(tt.rule),
+			)
+
+			result, err := service.ProcessPayment(ctx, completePaymentRequest(tt.customerID, tt.txRef))
+
+			require.NoError(t, err)
+			assert.Equal(t, "GIG00060", result.CustomerID)
+			mockCustomerRepo.AssertExpectations(t)
+			mockPaymentRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCustomer_NormalizesID(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	customer := &domain.Customer{ID: "GIG00061"}
+	mockCustomerRepo.On("FindByID", ctx, "GIG00061").Return(customer, nil)
+
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), nil, zap.NewNop())
+
+	got, err := service.GetCustomer(ctx, " gig00061 ")
+	require.NoError(t, err)
+	assert.Same(t, customer, got)
+}
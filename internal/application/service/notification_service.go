@@ -2,29 +2,105 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
 	"go.uber.org/zap"
 )
 
+var notificationsSkipped = metrics.NewCounter(
+	"notifications_skipped_total",
+	"Payment notifications dropped because the SMS circuit breaker was open.",
+)
+
+var notificationsOutOfOrder = metrics.NewCounter(
+	"notifications_out_of_order_total",
+	"Payment processed events handled after a later event for the same customer.",
+)
+
+// SMSSender delivers a text message to a customer
+type SMSSender interface {
+	SendSMS(ctx context.Context, customerID, message string) error
+}
+
+// logSMSSender stands in for an SMS gateway by logging each message
+type logSMSSender struct {
+	logger *zap.Logger
+}
+
+// NewLogSMSSender returns the sender used until a real SMS gateway is wired in
+func NewLogSMSSender(logger *zap.Logger) SMSSender {
+	return logSMSSender{logger: logger}
+}
+
+func (s logSMSSender) SendSMS(ctx context.Context, customerID, message string) error {
+	s.logger.Info("SMS notification sent",
+		zap.String("customer_id", customerID),
+		zap.String("message", message),
+	)
+	return nil
+}
+
 // NotificationService handles side effects like SMS, emails, etc.
 type NotificationService struct {
 	customerRepo domain.CustomerRepository
+	sms          SMSSender
+	handled      domain.HandledEvents
+	sequences    domain.EventSequences
 	logger       *zap.Logger
 }
 
+// NotificationOption configures optional NotificationService behaviour
+type NotificationOption func(*NotificationService)
+
+// WithSMSSender replaces the default sender, which only logs
+func WithSMSSender(sender SMSSender) NotificationOption {
+	return func(s *NotificationService) {
+		s.sms = sender
+	}
+}
+
+// WithHandledEvents skips events already handled, so a redelivered event
+// does not text the customer twice
+func WithHandledEvents(handled domain.HandledEvents) NotificationOption {
+	return func(s *NotificationService) {
+		s.handled = handled
+	}
+}
+
+// WithEventSequences recognizes payment processed events delivered after a
+// later one for the same customer. Their outstanding balance is out of
+// date, so it is left out of the message.
+func WithEventSequences(sequences domain.EventSequences) NotificationOption {
+	return func(s *NotificationService) {
+		s.sequences = sequences
+	}
+}
+
 func NewNotificationService(
 	customerRepo domain.CustomerRepository,
 	logger *zap.Logger,
+	opts ...NotificationOption,
 ) *NotificationService {
-	return &NotificationService{
+	s := &NotificationService{
 		customerRepo: customerRepo,
+		sms:          NewLogSMSSender(logger),
 		logger:       logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// HandlePaymentProcessed handles payment processed events
+// HandlePaymentProcessed handles payment processed events. With handled
+// events configured, an event is claimed before sending and only marked
+// handled once every message went out; a failed send releases the claim
+// so the redelivery retries it. Notifications are best effort, so while
+// the SMS breaker is open the event is dropped and acknowledged rather
+// than retried into a provider that is down.
 func (s *NotificationService) HandlePaymentProcessed(ctx context.Context, event domain.DomainEvent) error {
 	paymentEvent, ok := event.(*domain.PaymentProcessedEvent)
 	if !ok {
@@ -32,35 +108,124 @@ func (s *NotificationService) HandlePaymentProcessed(ctx context.Context, event
 	}
 
 	payload := paymentEvent.Payload
+	eventID := event.GetEventID()
+
+	if s.handled != nil {
+		claimed, err := s.handled.Claim(ctx, eventID)
+		if errors.Is(err, domain.ErrEventInProgress) {
+			// Left unacked; it comes back once the other claim settles
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim event: %w", err)
+		}
+		if !claimed {
+			s.logger.Info("skipping already handled payment processed event",
+				zap.String("event_id", eventID),
+				zap.String("customer_id", payload.CustomerID),
+			)
+			return nil
+		}
+	}
 
 	s.logger.Info("handling payment processed event",
-		zap.String("event_id", event.GetEventID()),
+		zap.String("event_id", eventID),
+		zap.String("correlation_id", domain.CorrelationIDFromContext(ctx)),
 		zap.String("customer_id", payload.CustomerID),
 		zap.Int64("amount", payload.Amount),
 	)
 
-	// TODO: Implement actual notification logic
+	err := s.notifyPaymentProcessed(ctx, payload, s.outOfOrder(ctx, event))
+	if errors.Is(err, ErrSMSCircuitOpen) {
+		notificationsSkipped.Inc()
+		s.logger.Warn("skipping payment notification, SMS circuit open",
+			zap.String("event_id", eventID),
+			zap.String("customer_id", payload.CustomerID),
+		)
+		err = nil
+	}
+	if err != nil {
+		if s.handled != nil {
+			if releaseErr := s.handled.Release(ctx, eventID); releaseErr != nil {
+				s.logger.Warn("failed to release event claim",
+					zap.Error(releaseErr),
+					zap.String("event_id", eventID),
+				)
+			}
+		}
+		return err
+	}
+
+	if s.handled != nil {
+		// The messages are out; failing the event now would only send them again
+		if err := s.handled.Complete(ctx, eventID); err != nil {
+			s.logger.Warn("failed to mark event handled",
+				zap.Error(err),
+				zap.String("event_id", eventID),
+			)
+		}
+	}
+
+	return nil
+}
+
+// outOfOrder reports whether an event for a later change to the customer
+// was already handled. If that can't be checked the event is taken to be
+// in order.
+func (s *NotificationService) outOfOrder(ctx context.Context, event domain.DomainEvent) bool {
+	if s.sequences == nil || event.GetSequence() == 0 {
+		return false
+	}
+	stale, err := s.sequences.Observe(ctx, event.GetAggregateID(), event.GetSequence())
+	if err != nil {
+		s.logger.Warn("failed to check event order", zap.Error(err), zap.String("event_id", event.GetEventID()))
+		return false
+	}
+	if stale {
+		notificationsOutOfOrder.Inc()
+		s.logger.Warn("payment processed event delivered out of order",
+			zap.String("event_id", event.GetEventID()),
+			zap.String("customer_id", event.GetAggregateID()),
+			zap.Int64("sequence", event.GetSequence()),
+		)
+	}
+	return stale
+}
+
+func (s *NotificationService) notifyPaymentProcessed(ctx context.Context, payload domain.PaymentProcessedPayload, outOfOrder bool) error {
+	// TODO: Implement the remaining notifications
 	// Examples:
-	// - Send SMS: "Payment of N%d received. Balance: N%d"
 	// - Send Email receipt
 	// - Update analytics dashboard
 	// - Trigger loyalty points
 	// - Generate invoice
 
-	// Simulate SMS sending
-	s.logger.Info("SMS notification sent",
-		zap.String("customer_id", payload.CustomerID),
-		zap.String("message", fmt.Sprintf("Payment of N%d received. Outstanding balance: N%d",
-			payload.Amount/100, payload.OutstandingBalance/100)),
-	)
+	currency := domain.CurrencyFor(payload.Currency)
+	message := fmt.Sprintf("Payment of %s received. Outstanding balance: %s",
+		smsAmount(payload.Amount, currency), smsAmount(payload.OutstandingBalance, currency))
+	if outOfOrder {
+		// A later payment's message already gave a newer balance
+		message = fmt.Sprintf("Payment of %s received.", smsAmount(payload.Amount, currency))
+	}
+	if err := s.sms.SendSMS(ctx, payload.CustomerID, message); err != nil {
+		return fmt.Errorf("failed to send payment SMS: %w", err)
+	}
 
 	// If customer fully paid, send congratulations
-	if payload.IsFullyPaid {
-		s.logger.Info("Congratulations SMS sent",
-			zap.String("customer_id", payload.CustomerID),
-			zap.String("message", "Congratulations! You now own your asset!"),
-		)
+	if payload.IsFullyPaid && !outOfOrder {
+		if err := s.sms.SendSMS(ctx, payload.CustomerID, "Congratulations! You now own your asset!"); err != nil {
+			return fmt.Errorf("failed to send congratulations SMS: %w", err)
+		}
 	}
 
 	return nil
 }
+
+// smsAmount renders an amount in whole major units in plain ASCII, as
+// "N2500" for naira and "UGX 2500" for other currencies
+func smsAmount(amount int64, currency domain.Currency) string {
+	if currency.Code == domain.CurrencyNGN.Code {
+		return fmt.Sprintf("N%d", currency.WholeUnits(amount))
+	}
+	return fmt.Sprintf("%s %d", currency.Code, currency.WholeUnits(amount))
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryHandledEvents is an in-memory HandledEvents
+type memoryHandledEvents struct {
+	mu    sync.Mutex
+	state map[string]string
+}
+
+func (h *memoryHandledEvents) Claim(ctx context.Context, eventID string) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state == nil {
+		h.state = make(map[string]string)
+	}
+	switch h.state[eventID] {
+	case "done":
+		return false, nil
+	case "processing":
+		return false, domain.ErrEventInProgress
+	}
+	h.state[eventID] = "processing"
+	return true, nil
+}
+
+func (h *memoryHandledEvents) Complete(ctx context.Context, eventID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state[eventID] = "done"
+	return nil
+}
+
+func (h *memoryHandledEvents) Release(ctx context.Context, eventID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.state, eventID)
+	return nil
+}
+
+// recordingSMSSender keeps every message sent and fails while err is set
+type recordingSMSSender struct {
+	sent []string
+	err  error
+}
+
+func (s *recordingSMSSender) SendSMS(ctx context.Context, customerID, message string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, customerID+": "+message)
+	return nil
+}
+
+func paymentProcessedEvent() *domain.PaymentProcessedEvent {
+	return domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{
+		CustomerID:           "GIG00001",
+		TransactionReference: "TXN-1",
+		Amount:               25000000,
+		OutstandingBalance:   75000000,
+	}, time.Now())
+}
+
+func TestHandlePaymentProcessed_RedeliverySendsOnce(t *testing.T) {
+	ctx := context.Background()
+	sms := &recordingSMSSender{}
+	handled := &memoryHandledEvents{}
+	notifications := NewNotificationService(nil, zap.NewNop(), WithSMSSender(sms), WithHandledEvents(handled))
+	event := paymentProcessedEvent()
+
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, event))
+	require.Equal(t, []string{"GIG00001: Payment of N250000 received. Outstanding balance: N750000"}, sms.sent)
+
+	// Exact redelivery is acknowledged without texting again
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, event))
+	assert.Len(t, sms.sent, 1)
+
+	// A different event still goes out
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, paymentProcessedEvent()))
+	assert.Len(t, sms.sent, 2)
+}
+
+func TestHandlePaymentProcessed_FailedSendIsRetried(t *testing.T) {
+	ctx := context.Background()
+	sms := &recordingSMSSender{err: errors.New("gateway unavailable")}
+	handled := &memoryHandledEvents{}
+	notifications := NewNotificationService(nil, zap.NewNop(), WithSMSSender(sms), WithHandledEvents(handled))
+	event := paymentProcessedEvent()
+
+	err := notifications.HandlePaymentProcessed(ctx, event)
+	require.Error(t, err)
+	assert.Empty(t, sms.sent)
+
+	sms.err = nil
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, event), "the failed send must not be suppressed")
+	assert.Len(t, sms.sent, 1)
+
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, event))
+	assert.Len(t, sms.sent, 1)
+}
+
+func TestHandlePaymentProcessed_InFlightClaimLeavesEventUnacked(t *testing.T) {
+	ctx := context.Background()
+	sms := &recordingSMSSender{}
+	handled := &memoryHandledEvents{}
+	notifications := NewNotificationService(nil, zap.NewNop(), WithSMSSender(sms), WithHandledEvents(handled))
+	event := paymentProcessedEvent()
+
+	claimed, err := handled.Claim(ctx, event.GetEventID())
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	err = notifications.HandlePaymentProcessed(ctx, event)
+	assert.ErrorIs(t, err, domain.ErrEventInProgress)
+	assert.Empty(t, sms.sent)
+}
+
+func TestHandlePaymentProcessed_SMSInTheCustomersCurrency(t *testing.T) {
+	sms := &recordingSMSSender{}
+	notifications := NewNotificationService(nil, zap.NewNop(), WithSMSSender(sms))
+	event := paymentProcessedEvent()
+	event.Payload.Currency = "UGX"
+	event.Payload.Amount = 250000
+	event.Payload.OutstandingBalance = 4750000
+
+	require.NoError(t, notifications.HandlePaymentProcessed(context.Background(), event))
+
+	assert.Equal(t, []string{"GIG00001: Payment of UGX 250000 received. Outstanding balance: UGX 4750000"}, sms.sent)
+}
+
+// memoryEventSequences is an in-memory EventSequences
+type memoryEventSequences struct {
+	seen map[string]int64
+}
+
+func (s *memoryEventSequences) Observe(ctx context.Context, aggregateID string, sequence int64) (bool, error) {
+	if s.seen == nil {
+		s.seen = make(map[string]int64)
+	}
+	if sequence < s.seen[aggregateID] {
+		return true, nil
+	}
+	s.seen[aggregateID] = sequence
+	return false, nil
+}
+
+func TestHandlePaymentProcessed_OutOfOrderLeavesOutBalance(t *testing.T) {
+	ctx := context.Background()
+	sms := &recordingSMSSender{}
+	notifications := NewNotificationService(nil, zap.NewNop(), WithSMSSender(sms), WithEventSequences(&memoryEventSequences{}))
+
+	later := paymentProcessedEvent()
+	later.Sequence = 3
+	later.Payload.OutstandingBalance = 0
+	later.Payload.IsFullyPaid = true
+	earlier := paymentProcessedEvent()
+	earlier.Sequence = 2
+
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, later))
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, earlier))
+
+	require.Len(t, sms.sent, 3)
+	assert.Equal(t, "GIG00001: Payment of N250000 received.", sms.sent[2])
+
+	// Events without a sequence are never taken as out of order
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, paymentProcessedEvent()))
+	assert.Contains(t, sms.sent[3], "Outstanding balance: N750000")
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// WithOutcomeLog records inbound payments that are not stored as payments,
+// duplicates and non-complete statuses, so the daily report can count them
+func WithOutcomeLog(log domain.PaymentOutcomeLog) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.outcomeLog = log
+	}
+}
+
+// recordOutcome is best effort: a failure only makes the daily report
+// undercount, which is no reason to fail the payment
+func (s *PaymentService) recordOutcome(ctx context.Context, status domain.PaymentStatus, req ProcessPaymentRequest) {
+	if s.outcomeLog == nil || req.DryRun {
+		return
+	}
+	if err := s.outcomeLog.Record(ctx, status, req.TransactionReference, s.clock.Now()); err != nil {
+		s.logger.Warn("failed to record payment outcome",
+			zap.Error(err),
+			zap.String("status", string(status)),
+			zap.String("tx_ref", req.TransactionReference),
+		)
+	}
+}
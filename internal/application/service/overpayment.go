@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// OverpaymentPolicy says what happens to the part of a payment that
+// exceeds the balance it settles
+type OverpaymentPolicy string
+
+const (
+	// OverpaymentIgnore counts the excess towards the paid-off loan, as
+	// payments always have
+	OverpaymentIgnore OverpaymentPolicy = "ignore"
+	// OverpaymentCredit records the excess as refundable customer credit
+	OverpaymentCredit OverpaymentPolicy = "credit"
+	// OverpaymentApplyToOtherLoan pays the excess into the borrower's oldest
+	// other open loan, crediting whatever that loan can't take
+	OverpaymentApplyToOtherLoan OverpaymentPolicy = "apply_to_other_loan"
+)
+
+// ParseOverpaymentPolicy validates a configured policy; empty means ignore
+func ParseOverpaymentPolicy(s string) (OverpaymentPolicy, error) {
+	switch policy := OverpaymentPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return OverpaymentIgnore, nil
+	case OverpaymentIgnore, OverpaymentCredit, OverpaymentApplyToOtherLoan:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown overpayment policy %q", s)
+	}
+}
+
+// WithOverpaymentPolicy selects how overpayments are handled. credits is
+// needed by the credit and apply policies, loans only by apply.
+func WithOverpaymentPolicy(policy OverpaymentPolicy, credits domain.CreditLedger, loans domain.OtherLoanFinder) PaymentServiceOption {
+	return func(s *PaymentService) {
+		switch policy {
+		case OverpaymentCredit:
+			s.overpayment = creditOverpayment{ledger: credits}
+		case OverpaymentApplyToOtherLoan:
+			s.overpayment = applyOverpaymentToOtherLoan{loans: loans, credit: creditOverpayment{ledger: credits}}
+		default:
+			s.overpayment = ignoreOverpayment{}
+		}
+	}
+}
+
+// overpaymentStrategy settles the excess once the payment has been saved
+type overpaymentStrategy interface {
+	policy() OverpaymentPolicy
+	// redirects reports whether the excess leaves the paying loan, in which
+	// case only its balance is applied to it
+	redirects() bool
+	settle(ctx context.Context, s *PaymentService, customer *domain.Customer, req ProcessPaymentRequest, excess int64) domain.PaymentOverpaidPayload
+}
+
+type ignoreOverpayment struct{}
+
+func (ignoreOverpayment) policy() OverpaymentPolicy { return OverpaymentIgnore }
+func (ignoreOverpayment) redirects() bool           { return false }
+
+func (ignoreOverpayment) settle(_ context.Context, _ *PaymentService, _ *domain.Customer, _ ProcessPaymentRequest, _ int64) domain.PaymentOverpaidPayload {
+	return domain.PaymentOverpaidPayload{Destination: domain.OverpaymentIgnored}
+}
+
+type creditOverpayment struct {
+	ledger domain.CreditLedger
+}
+
+func (creditOverpayment) policy() OverpaymentPolicy { return OverpaymentCredit }
+func (creditOverpayment) redirects() bool           { return true }
+
+func (c creditOverpayment) settle(ctx context.Context, s *PaymentService, customer *domain.Customer, req ProcessPaymentRequest, excess int64) domain.PaymentOverpaidPayload {
+	if err := c.record(ctx, customer.ID, req.TransactionReference, excess, s.clock.Now()); err != nil {
+		logFailure(s.logger, "failed to record overpayment credit", err,
+			zap.String("customer_id", customer.ID),
+			zap.String("tx_ref", req.TransactionReference),
+			zap.Int64("amount", excess),
+		)
+		return domain.PaymentOverpaidPayload{Destination: domain.OverpaymentIgnored}
+	}
+	return domain.PaymentOverpaidPayload{Destination: domain.OverpaymentCredited, CreditedAmount: excess}
+}
+
+// record treats an already credited reference as done, so a retried
+// payment is never credited twice
+func (c creditOverpayment) record(ctx context.Context, customerID, txRef string, amount int64, at time.Time) error {
+	err := c.ledger.Record(ctx, &domain.CustomerCredit{
+		CustomerID:           customerID,
+		TransactionReference: txRef,
+		Amount:               amount,
+		CreatedAt:            at,
+	})
+	if errors.Is(err, domain.ErrDuplicateTransaction) {
+		return nil
+	}
+	return err
+}
+
+type applyOverpaymentToOtherLoan struct {
+	loans  domain.OtherLoanFinder
+	credit creditOverpayment
+}
+
+func (applyOverpaymentToOtherLoan) policy() OverpaymentPolicy { return OverpaymentApplyToOtherLoan }
+func (applyOverpaymentToOtherLoan) redirects() bool           { return true }
+
+// settle falls back to credit for whatever the other loan doesn't take,
+// including all of it when the borrower has no other open loan
+func (a applyOverpaymentToOtherLoan) settle(ctx context.Context, s *PaymentService, customer *domain.Customer, req ProcessPaymentRequest, excess int64) domain.PaymentOverpaidPayload {
+	target, applied, err := a.applyToOtherLoan(ctx, s, customer, req, excess)
+	switch {
+	case errors.Is(err, domain.ErrCustomerNotFound):
+		s.logger.Info("no other open loan for overpayment, crediting instead",
+			zap.String("customer_id", customer.ID),
+			zap.String("tx_ref", req.TransactionReference),
+		)
+	case err != nil:
+		s.logger.Warn("failed to apply overpayment to other loan, crediting instead",
+			zap.Error(err),
+			zap.String("customer_id", customer.ID),
+			zap.String("tx_ref", req.TransactionReference),
+		)
+	}
+
+	rest := excess - applied
+	if rest == 0 {
+		return domain.PaymentOverpaidPayload{
+			Destination:      domain.OverpaymentApplied,
+			AppliedAmount:    applied,
+			TargetCustomerID: target,
+		}
+	}
+
+	payload := a.credit.settle(ctx, s, customer, req, rest)
+	if applied > 0 {
+		payload.Destination = domain.OverpaymentApplied
+		payload.AppliedAmount = applied
+		payload.TargetCustomerID = target
+	}
+	return payload
+}
+
+// applyToOtherLoan pays up to excess into the borrower's other loan,
+// re-reading it once if a concurrent payment bumped its version
+func (a applyOverpaymentToOtherLoan) applyToOtherLoan(ctx context.Context, s *PaymentService, customer *domain.Customer, req ProcessPaymentRequest, excess int64) (string, int64, error) {
+	target, err := a.loans.FindOtherActiveLoan(ctx, customer)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		applied := min(excess, target.OutstandingBalance)
+		if err := target.ApplyPayment(applied, req.TransactionDate); err != nil {
+			return "", 0, err
+		}
+		target.UpdateDefaultStatus(req.TransactionDate, s.defaultThreshold)
+
+		err = s.customerRepo.Save(ctx, target)
+		if err == nil {
+			s.invalidateCustomerViews(ctx, target.ID)
+			return target.ID, applied, nil
+		}
+		if err != domain.ErrOptimisticLock || attempt > 0 {
+			return "", 0, err
+		}
+
+		s.logger.Warn("optimistic lock conflict on other loan, retrying once",
+			zap.String("customer_id", target.ID),
+		)
+		if target, err = s.refetchCustomer(ctx, target.ID); err != nil {
+			return "", 0, err
+		}
+	}
+}
+
+// settleOverpayment runs after the payment is saved. Failures are logged
+// rather than returned: the payment itself has been applied, and a retry
+// would only be treated as a duplicate. The one error returned is a failed
+// publish under sync publishing.
+func (s *PaymentService) settleOverpayment(ctx context.Context, correlationID string, customer *domain.Customer, req ProcessPaymentRequest, excess int64) error {
+	payload := s.overpayment.settle(ctx, s, customer, req, excess)
+	payload.CustomerID = customer.ID
+	payload.TransactionReference = req.TransactionReference
+	payload.Excess = excess
+	payload.Policy = string(s.overpayment.policy())
+	payload.OccurredAt = s.clock.Now()
+
+	s.logger.Info("overpayment settled",
+		zap.String("customer_id", customer.ID),
+		zap.String("tx_ref", req.TransactionReference),
+		zap.Int64("excess", excess),
+		zap.String("destination", payload.Destination),
+		zap.Int64("applied", payload.AppliedAmount),
+		zap.Int64("credited", payload.CreditedAmount),
+	)
+
+	if s.eventPublisher == nil {
+		return nil
+	}
+	event := domain.NewPaymentOverpaidEvent(customer.ID, payload, payload.OccurredAt)
+	event.CorrelationID = correlationID
+	return s.publishEvent(ctx, event)
+}
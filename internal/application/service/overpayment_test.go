@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type memoryCreditLedger struct {
+	mu      sync.Mutex
+	credits map[string]*domain.CustomerCredit
+}
+
+func newMemoryCreditLedger() *memoryCreditLedger {
+	return &memoryCreditLedger{credits: map[string]*domain.CustomerCredit{}}
+}
+
+func (l *memoryCreditLedger) Record(ctx context.Context, credit *domain.CustomerCredit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.credits[credit.TransactionReference]; ok {
+		return domain.ErrDuplicateTransaction
+	}
+	l.credits[credit.TransactionReference] = credit
+	return nil
+}
+
+// staticLoanFinder returns a copy of loan, or ErrCustomerNotFound when nil
+type staticLoanFinder struct {
+	loan *domain.Customer
+}
+
+func (f staticLoanFinder) FindOtherActiveLoan(ctx context.Context, customer *domain.Customer) (*domain.Customer, error) {
+	if f.loan == nil {
+		return nil, domain.ErrCustomerNotFound
+	}
+	loan := *f.loan
+	return &loan, nil
+}
+
+const (
+	overpayingCustomer = "GIG00020"
+	otherLoanCustomer  = "GIG00021"
+)
+
+func isCustomer(id string) interface{} {
+	return mock.MatchedBy(func(c *domain.Customer) bool { return c.ID == id })
+}
+
+// newOverpaymentMocks sets up a customer owing 400,000 kobo, so the
+// 1,000,000 kobo completePaymentRequest overpays by 600,000
+func newOverpaymentMocks(ctx context.Context) (*MockCustomerRepository, *MockPaymentRepository, *domain.Customer) {
+	customer := &domain.Customer{
+		ID: overpayingCustomer, BorrowerID: "BRW1", AssetValue: 10000000, RepaymentTermWeeks: 50,
+		OutstandingBalance: 400000, TotalPaid: 9600000, Status: domain.CustomerStatusActive, Version: 3,
+	}
+
+	customers := new(MockCustomerRepository)
+	payments := new(MockPaymentRepository)
+	payments.On("ExistsByTransactionReference", ctx, mock.Anything, "TXN020").Return(false, nil)
+	customers.On("FindByID", ctx, overpayingCustomer).Return(customer, nil)
+	customers.On("Save", ctx, isCustomer(overpayingCustomer)).Return(nil)
+	payments.On("Save", ctx, mock.Anything).Return(nil)
+	return customers, payments, customer
+}
+
+func otherLoan(balance int64) *domain.Customer {
+	return &domain.Customer{
+		ID: otherLoanCustomer, BorrowerID: "BRW1", AssetValue: 10000000, RepaymentTermWeeks: 50,
+		OutstandingBalance: balance, TotalPaid: 10000000 - balance, Status: domain.CustomerStatusActive, Version: 7,
+	}
+}
+
+func overpaidEvent(t *testing.T, publisher *recordingPublisher) domain.PaymentOverpaidPayload {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return len(publisher.eventsOfType(domain.EventTypePaymentOverpaid)) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	event, ok := publisher.eventsOfType(domain.EventTypePaymentOverpaid)[0].(*domain.PaymentOverpaidEvent)
+	require.True(t, ok)
+	assert.Equal(t, overpayingCustomer, event.GetAggregateID())
+	assert.Equal(t, "TXN020", event.Payload.TransactionReference)
+	assert.Equal(t, int64(600000), event.Payload.Excess)
+	return event.Payload
+}
+
+func TestParseOverpaymentPolicy(t *testing.T) {
+	for in, want := range map[string]OverpaymentPolicy{
+		"":                    OverpaymentIgnore,
+		"ignore":              OverpaymentIgnore,
+		" Credit ":            OverpaymentCredit,
+		"apply_to_other_loan": OverpaymentApplyToOtherLoan,
+	} {
+		got, err := ParseOverpaymentPolicy(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := ParseOverpaymentPolicy("refund")
+	assert.Error(t, err)
+}
+
+func TestProcessPayment_OverpaymentIgnoredByDefault(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, customer := newOverpaymentMocks(ctx)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop())
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+
+	require.NoError(t, err)
+	assert.Zero(t, result.OutstandingBalance)
+	assert.Equal(t, int64(10600000), customer.TotalPaid, "the excess stays on the settled loan")
+	assert.Equal(t, domain.CustomerStatusCompleted, customer.Status)
+
+	payload := overpaidEvent(t, publisher)
+	assert.Equal(t, string(OverpaymentIgnore), payload.Policy)
+	assert.Equal(t, domain.OverpaymentIgnored, payload.Destination)
+	assert.Zero(t, payload.AppliedAmount)
+	assert.Zero(t, payload.CreditedAmount)
+}
+
+func TestProcessPayment_OverpaymentCredited(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, customer := newOverpaymentMocks(ctx)
+	publisher := &recordingPublisher{}
+	ledger := newMemoryCreditLedger()
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentCredit, ledger, nil))
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000000), customer.TotalPaid, "only the balance counts towards the loan")
+	assert.Equal(t, domain.CustomerStatusCompleted, customer.Status)
+
+	require.Contains(t, ledger.credits, "TXN020")
+	assert.Equal(t, overpayingCustomer, ledger.credits["TXN020"].CustomerID)
+	assert.Equal(t, int64(600000), ledger.credits["TXN020"].Amount)
+
+	payload := overpaidEvent(t, publisher)
+	assert.Equal(t, string(OverpaymentCredit), payload.Policy)
+	assert.Equal(t, domain.OverpaymentCredited, payload.Destination)
+	assert.Equal(t, int64(600000), payload.CreditedAmount)
+}
+
+func TestProcessPayment_OverpaymentAppliedToOtherLoan(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, customer := newOverpaymentMocks(ctx)
+	customers.On("Save", ctx, isCustomer(otherLoanCustomer)).Return(nil)
+	publisher := &recordingPublisher{}
+	ledger := newMemoryCreditLedger()
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentApplyToOtherLoan, ledger, staticLoanFinder{loan: otherLoan(5000000)}))
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000000), customer.TotalPaid)
+
+	var saved *domain.Customer
+	for _, call := range customers.Calls {
+		if c, ok := call.Arguments.Get(1).(*domain.Customer); ok && call.Method == "Save" && c.ID == otherLoanCustomer {
+			saved = c
+		}
+	}
+	require.NotNil(t, saved)
+	assert.Equal(t, int64(4400000), saved.OutstandingBalance)
+	assert.Equal(t, int64(5600000), saved.TotalPaid)
+	assert.NoError(t, saved.Validate())
+	assert.NoError(t, customer.Validate(), "the overpaid loan keeps its excess in TotalPaid")
+	assert.Empty(t, ledger.credits)
+
+	payload := overpaidEvent(t, publisher)
+	assert.Equal(t, domain.OverpaymentApplied, payload.Destination)
+	assert.Equal(t, int64(600000), payload.AppliedAmount)
+	assert.Equal(t, otherLoanCustomer, payload.TargetCustomerID)
+	assert.Zero(t, payload.CreditedAmount)
+}
+
+func TestProcessPayment_OverpaymentBeyondOtherLoanIsCredited(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, _ := newOverpaymentMocks(ctx)
+	customers.On("Save", ctx, isCustomer(otherLoanCustomer)).Return(nil)
+	publisher := &recordingPublisher{}
+	ledger := newMemoryCreditLedger()
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentApplyToOtherLoan, ledger, staticLoanFinder{loan: otherLoan(200000)}))
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+	require.NoError(t, err)
+
+	payload := overpaidEvent(t, publisher)
+	assert.Equal(t, domain.OverpaymentApplied, payload.Destination)
+	assert.Equal(t, int64(200000), payload.AppliedAmount)
+	assert.Equal(t, int64(400000), payload.CreditedAmount)
+	require.Contains(t, ledger.credits, "TXN020")
+	assert.Equal(t, int64(400000), ledger.credits["TXN020"].Amount)
+}
+
+func TestProcessPayment_OverpaymentWithoutOtherLoanFallsBackToCredit(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, _ := newOverpaymentMocks(ctx)
+	publisher := &recordingPublisher{}
+	ledger := newMemoryCreditLedger()
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentApplyToOtherLoan, ledger, staticLoanFinder{}))
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+	require.NoError(t, err)
+
+	payload := overpaidEvent(t, publisher)
+	assert.Equal(t, string(OverpaymentApplyToOtherLoan), payload.Policy)
+	assert.Equal(t, domain.OverpaymentCredited, payload.Destination)
+	assert.Zero(t, payload.AppliedAmount)
+	assert.Empty(t, payload.TargetCustomerID)
+	assert.Equal(t, int64(600000), payload.CreditedAmount)
+	customers.AssertNotCalled(t, "Save", ctx, isCustomer(otherLoanCustomer))
+}
+
+func TestProcessPayment_OverpaymentRetriesOtherLoanOnOptimisticLock(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, _ := newOverpaymentMocks(ctx)
+	customers.On("Save", ctx, isCustomer(otherLoanCustomer)).Return(domain.ErrOptimisticLock).Once()
+	customers.On("Save", ctx, isCustomer(otherLoanCustomer)).Return(nil).Once()
+	// Someone paid into the other loan between our read and our save
+	fresh := otherLoan(300000)
+	fresh.Version = 8
+	customers.On("FindByID", ctx, otherLoanCustomer).Return(fresh, nil)
+	publisher := &recordingPublisher{}
+	ledger := newMemoryCreditLedger()
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentApplyToOtherLoan, ledger, staticLoanFinder{loan: otherLoan(5000000)}))
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+	require.NoError(t, err)
+
+	assert.Zero(t, fresh.OutstandingBalance, "the retry applies to the re-read loan")
+	assert.Equal(t, domain.CustomerStatusCompleted, fresh.Status)
+
+	payload := overpaidEvent(t, publisher)
+	assert.Equal(t, int64(300000), payload.AppliedAmount)
+	assert.Equal(t, int64(300000), payload.CreditedAmount)
+}
+
+func TestProcessPayment_NoOverpaymentEventForExactPayment(t *testing.T) {
+	ctx := context.Background()
+	customers, payments, customer := newOverpaymentMocks(ctx)
+	customer.OutstandingBalance = 5000000
+	publisher := &recordingPublisher{}
+	ledger := newMemoryCreditLedger()
+
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentCredit, ledger, nil))
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(overpayingCustomer, "TXN020"))
+	require.NoError(t, err)
+
+	require.NoError(t, service.WaitForPublishes(ctx))
+	assert.Empty(t, publisher.eventsOfType(domain.EventTypePaymentOverpaid))
+	assert.Empty(t, ledger.credits)
+	assert.Equal(t, int64(10600000), customer.TotalPaid)
+}
@@ -0,0 +1,30 @@
+package service
+
+// PageSizes are the page size a list query gets when it doesn't ask for
+// one, and the most it can get
+type PageSizes struct {
+	Default int
+	Max     int
+}
+
+// DefaultPageSizes apply to payment listings unless WithPageSizes says
+// otherwise
+var DefaultPageSizes = PageSizes{Default: 10, Max: 100}
+
+// WithPageSizes sets the default and maximum page size of every payment
+// listing. The zero value keeps DefaultPageSizes.
+func WithPageSizes(sizes PageSizes) PaymentServiceOption {
+	return func(s *PaymentService) {
+		if sizes != (PageSizes{}) {
+			s.pageSizes = sizes
+		}
+	}
+}
+
+// size is requested, or Default when requested is unset, capped at Max
+func (p PageSizes) size(requested int) int {
+	if requested < 1 {
+		requested = p.Default
+	}
+	return min(requested, p.Max)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+// permanentPaymentErrors are the rejections sending the same payment again
+// can't change
+var permanentPaymentErrors = []error{
+	domain.ErrCustomerNotFound,
+	domain.ErrInvalidCustomerID,
+	domain.ErrInvalidAmount,
+	domain.ErrInvalidTransactionRef,
+	domain.ErrUnknownCurrency,
+	domain.ErrAmountTooPrecise,
+	domain.ErrCurrencyMismatch,
+	domain.ErrDuplicateTransaction,
+	domain.ErrAssetAlreadyOwned,
+	domain.ErrLoanWrittenOff,
+	domain.ErrBelowMinimumPayment,
+	domain.ErrExcessiveOverpayment,
+}
+
+// PaymentError is a failed payment. Retryable means it may succeed if sent
+// again, as after a database or Redis failure; a payment the rules reject
+// is not. Errors not known to be permanent count as retryable, since a
+// payment retried needlessly costs less than one dropped.
+type PaymentError struct {
+	Err       error
+	Retryable bool
+}
+
+func (e *PaymentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PaymentError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err may succeed if the payment is sent again.
+// Any error other than a PaymentError is taken to be retryable.
+func IsRetryable(err error) bool {
+	var paymentErr *PaymentError
+	if errors.As(err, &paymentErr) {
+		return paymentErr.Retryable
+	}
+	return true
+}
+
+func classifyPaymentError(err error) error {
+	var paymentErr *PaymentError
+	if errors.As(err, &paymentErr) {
+		return err
+	}
+	for _, permanent := range permanentPaymentErrors {
+		if errors.Is(err, permanent) {
+			return &PaymentError{Err: err, Retryable: false}
+		}
+	}
+	return &PaymentError{Err: err, Retryable: true}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+const defaultFeedLimit = 100
+
+// MaxFeedLimit caps the payments one poll of the feed returns
+const MaxFeedLimit = 1000
+
+// DefaultFeedSettleWindow is how long a payment must have been recorded
+// before the feed serves it
+const DefaultFeedSettleWindow = 5 * time.Second
+
+// FeedCursor is the (CreatedAt, ID) key of the last payment a feed poll
+// returned. It is handed to clients as an opaque base64 string.
+type FeedCursor struct {
+	RecordedAt time.Time `json:"t"`
+	ID         string    `json:"i"`
+}
+
+func (c FeedCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeFeedCursor parses an opaque feed cursor; an empty string is the
+// start of the feed
+func DecodeFeedCursor(cursor string) (FeedCursor, error) {
+	var c FeedCursor
+	if cursor == "" {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return FeedCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// PaymentFeedPage is one poll of the payment feed
+type PaymentFeedPage struct {
+	Payments []*domain.Payment
+	// NextCursor is what to poll with next. It is the cursor polled with
+	// when nothing new was found, so a client can always store it.
+	NextCursor string
+	// HasMore is set when more payments were ready than the limit allowed
+	HasMore bool
+	// Totals count and sum Payments by currency code
+	Totals map[string]domain.PaymentTotals
+}
+
+// PaymentFeedService serves every customer's payments in the order they
+// were recorded, for clients that poll for everything since their last
+// cursor
+type PaymentFeedService struct {
+	feed   domain.PaymentFeed
+	settle time.Duration
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewPaymentFeedService serves payments once they are settle old. Rows can
+// commit slightly out of CreatedAt order, from clock skew between replicas
+// or a slow insert, and a row committed behind a cursor already handed out
+// would never be served; holding back the newest settle of rows gives them
+// time to land. Zero uses DefaultFeedSettleWindow.
+func NewPaymentFeedService(feed domain.PaymentFeed, settle time.Duration, logger *zap.Logger) *PaymentFeedService {
+	if settle <= 0 {
+		settle = DefaultFeedSettleWindow
+	}
+	return &PaymentFeedService{
+		feed:   feed,
+		settle: settle,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Since returns up to limit payments recorded after cursor, oldest first.
+// Following NextCursor from poll to poll returns every payment exactly
+// once, however many are recorded in between.
+func (s *PaymentFeedService) Since(ctx context.Context, cursor string, limit int) (*PaymentFeedPage, error) {
+	after, err := DecodeFeedCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 {
+		limit = defaultFeedLimit
+	}
+	if limit > MaxFeedLimit {
+		limit = MaxFeedLimit
+	}
+
+	until := s.now().Add(-s.settle)
+	// One extra row says whether there is more without a count
+	payments, err := s.feed.FindRecordedAfter(ctx, after.RecordedAt, after.ID, until, limit+1)
+	if err != nil {
+		logFailure(s.logger, "failed to read payment feed", err,
+			zap.Time("after", after.RecordedAt),
+			zap.String("after_id", after.ID),
+		)
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	page := &PaymentFeedPage{
+		NextCursor: cursor,
+		Totals:     make(map[string]domain.PaymentTotals),
+	}
+	if len(payments) > limit {
+		payments = payments[:limit]
+		page.HasMore = true
+	}
+	page.Payments = payments
+	for _, payment := range payments {
+		code := payment.Currency().Code
+		totals := page.Totals[code]
+		totals.Count++
+		totals.Amount += payment.Amount
+		page.Totals[code] = totals
+	}
+	if len(payments) > 0 {
+		last := payments[len(payments)-1]
+		page.NextCursor = FeedCursor{RecordedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func recordPayment(t *testing.T, repo *testutil.MemoryPaymentRepository, ref string, amount int64, recordedAt time.Time) {
+	t.Helper()
+	payment, err := domain.NewPayment("GIG00001", amount, ref, recordedAt, domain.PaymentStatusComplete, recordedAt)
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(context.Background(), payment))
+}
+
+func TestPaymentFeed_IncrementalPollingNeverSkipsOrRepeats(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	now := start
+	repo := testutil.NewMemoryPaymentRepository()
+	feed := NewPaymentFeedService(repo, 5*time.Second, zap.NewNop())
+	feed.now = func() time.Time { return now }
+
+	for i := 0; i < 7; i++ {
+		// Pairs share a timestamp so the ID tie-breaker is exercised
+		recordPayment(t, repo, fmt.Sprintf("TXN%03d", i), 1000, start.Add(-time.Minute+time.Duration(i/2)*time.Second))
+	}
+	// Too fresh to serve yet: a payment recorded just before it may still be committing
+	recordPayment(t, repo, "FRESH", 1000, start.Add(-2*time.Second))
+
+	seen := map[string]int{}
+	cursor := ""
+	poll := func() *PaymentFeedPage {
+		page, err := feed.Since(ctx, cursor, 3)
+		require.NoError(t, err)
+		for _, p := range page.Payments {
+			seen[p.TransactionReference]++
+		}
+		cursor = page.NextCursor
+		return page
+	}
+
+	page := poll()
+	assert.Len(t, page.Payments, 3)
+	assert.True(t, page.HasMore)
+	poll()
+	page = poll()
+	assert.Len(t, page.Payments, 1)
+	assert.False(t, page.HasMore)
+	assert.Zero(t, seen["FRESH"])
+
+	// Nothing new: the cursor stays put
+	before := cursor
+	page = poll()
+	assert.Empty(t, page.Payments)
+	assert.Equal(t, before, cursor)
+
+	// A slow insert lands with a timestamp older than FRESH's, after the
+	// last poll, and new payments keep arriving
+	recordPayment(t, repo, "LATE", 1000, start.Add(-3*time.Second))
+	recordPayment(t, repo, "NEW", 1000, start.Add(time.Second))
+	now = start.Add(10 * time.Second)
+
+	page = poll()
+	require.Len(t, page.Payments, 3)
+	assert.Equal(t, []string{"LATE", "FRESH", "NEW"}, []string{
+		page.Payments[0].TransactionReference, page.Payments[1].TransactionReference, page.Payments[2].TransactionReference,
+	})
+
+	for i := 0; i < 7; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("TXN%03d", i)], "TXN%03d", i)
+	}
+	assert.Equal(t, 1, seen["FRESH"])
+	assert.Equal(t, 1, seen["LATE"])
+	assert.Equal(t, 1, seen["NEW"])
+}
+
+func TestPaymentFeed_TotalsByCurrency(t *testing.T) {
+	recordedAt := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	repo := testutil.NewMemoryPaymentRepository()
+	recordPayment(t, repo, "TXN001", 250000, recordedAt)
+	recordPayment(t, repo, "TXN002", 100000, recordedAt)
+	ugx, err := domain.NewPayment("GIG00002", 50000, "TXN003", recordedAt, domain.PaymentStatusComplete, recordedAt)
+	require.NoError(t, err)
+	ugx.CurrencyCode = "UGX"
+	require.NoError(t, repo.Save(context.Background(), ugx))
+
+	feed := NewPaymentFeedService(repo, 0, zap.NewNop())
+	page, err := feed.Since(context.Background(), "", 0)
+
+	require.NoError(t, err)
+	assert.Len(t, page.Payments, 3)
+	assert.Equal(t, map[string]domain.PaymentTotals{
+		"NGN": {Count: 2, Amount: 350000},
+		"UGX": {Count: 1, Amount: 50000},
+	}, page.Totals)
+}
+
+func TestPaymentFeed_InvalidCursor(t *testing.T) {
+	feed := NewPaymentFeedService(testutil.NewMemoryPaymentRepository(), 0, zap.NewNop())
+
+	for _, cursor := range []string{"not base64!", "e30"} {
+		_, err := feed.Since(context.Background(), cursor, 10)
+		assert.ErrorIs(t, err, ErrInvalidCursor, cursor)
+	}
+}
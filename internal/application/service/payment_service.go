@@ -2,33 +2,168 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
 	"go.uber.org/zap"
 )
 
 type PaymentService struct {
 	customerRepo   domain.CustomerRepository
 	paymentRepo    domain.PaymentRepository
-	eventPublisher domain.EventPublisher 
+	eventPublisher domain.EventPublisher
 	logger         *zap.Logger
+
+	minimumPaymentAmount int64
+	overpaymentLimit     domain.OverpaymentLimit
+	payoffDiscountBPS    int64
+	slowPaymentThreshold time.Duration
+	txRefRule            TransactionReferenceRule
+	velocityTracker      domain.VelocityTracker
+	velocityLimits       VelocityLimits
+	maxDateRange         time.Duration
+	pageSizes            PageSizes
+	defaultThreshold     int
+	viewInvalidator      CustomerViewInvalidator
+	outcomeLog           domain.PaymentOutcomeLog
+	overpayment          overpaymentStrategy
+	clock                domain.Clock
+	syncPublish          bool
+	atomicApply          domain.AtomicPaymentApplier
+	transactor           domain.Transactor
+	eventLog             domain.EventLog
+	uncachedCustomers    domain.UncachedCustomerFinder
+	paymentPager         domain.PaymentPager
+	paymentWeeks         domain.PaymentWeekTotaler
+	featureFlags         domain.FeatureFlags
+	moneyAsStrings       bool
+
+	// publishes tracks events still being published in the background
+	publishes sync.WaitGroup
+}
+
+// PaymentServiceOption configures optional PaymentService behaviour
+type PaymentServiceOption func(*PaymentService)
+
+// WithMinimumPaymentAmount rejects payments below amount (in kobo) unless
+// they settle the outstanding balance
+func WithMinimumPaymentAmount(amount int64) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.minimumPaymentAmount = amount
+	}
+}
+
+// WithOverpaymentLimit rejects payments that exceed the outstanding
+// balance by more than limit allows, instead of applying them and settling
+// the excess under the overpayment policy
+func WithOverpaymentLimit(limit domain.OverpaymentLimit) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.overpaymentLimit = limit
+	}
+}
+
+// WithSlowPaymentThreshold logs a warning with sub-timings for any
+// ProcessPayment call slower than d. Zero disables the log.
+func WithSlowPaymentThreshold(d time.Duration) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.slowPaymentThreshold = d
+	}
+}
+
+// WithTransactionReferenceRule sets how references are canonicalized before
+// dedup. The default only trims whitespace.
+func WithTransactionReferenceRule(rule TransactionReferenceRule) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.txRefRule = rule
+	}
 }
 
+// WithSyncPublishing publishes events before the call that raised them
+// returns, failing that call when publishing fails, instead of in the
+// background. Meant for single-node and test setups that would rather fail
+// a request than lose its event.
+func WithSyncPublishing(sync bool) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.syncPublish = sync
+	}
+}
+
+// WithClock sets the clock that stamps payments and events; tests pass a
+// fixed one
+func WithClock(clock domain.Clock) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.clock = clock
+	}
+}
+
+// WithUncachedCustomerFinder re-reads customers through finder after an
+// optimistic lock conflict, so a retry never sees the cached version that
+// just lost. Without it retries read through the customer repository.
+func WithUncachedCustomerFinder(finder domain.UncachedCustomerFinder) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.uncachedCustomers = finder
+	}
+}
+
+// WithPaymentPager reads pages of a customer's payments through pager, so
+// the total count always matches the page. Without it the count and the
+// page are separate queries and can disagree while payments are recorded.
+func WithPaymentPager(pager domain.PaymentPager) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.paymentPager = pager
+	}
+}
+
+// WithMoneyAsStrings publishes the amounts in payment.processed events as
+// decimal strings rather than JSON numbers, for consumers outside Go
+func WithMoneyAsStrings(asStrings bool) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.moneyAsStrings = asStrings
+	}
+}
+
+var paymentLatency = metrics.NewHistogram(
+	"payment_process_duration_seconds",
+	"End-to-end ProcessPayment latency.",
+	metrics.ExponentialBuckets(0.001, 2, 14),
+)
+
+// paymentTimings attributes ProcessPayment latency to its repository calls
+type paymentTimings struct {
+	dedupCheck    time.Duration
+	customerFetch time.Duration
+	customerSave  time.Duration
+	paymentSave   time.Duration
+	retried       bool
+}
 
 func NewPaymentService(
 	customerRepo domain.CustomerRepository,
 	paymentRepo domain.PaymentRepository,
 	eventPublisher domain.EventPublisher,
 	logger *zap.Logger,
+	opts ...PaymentServiceOption,
 ) *PaymentService {
-	return &PaymentService{
+	s := &PaymentService{
 		customerRepo:   customerRepo,
 		paymentRepo:    paymentRepo,
 		eventPublisher: eventPublisher,
 		logger:         logger,
+		txRefRule:      TransactionReferenceTrim,
+		overpayment:    ignoreOverpayment{},
+		clock:          domain.SystemClock,
+		pageSizes:      DefaultPageSizes,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 type ProcessPaymentRequest struct {
@@ -37,72 +172,192 @@ type ProcessPaymentRequest struct {
 	TransactionAmount    int64
 	TransactionDate      time.Time
 	TransactionReference string
+	// Currency is the ISO 4217 code TransactionAmount is in. It must be
+	// the customer's; empty skips the check.
+	Currency string
+	// Metadata is stored with the payment as given; callers bound its size
+	Metadata map[string]string
+	// DryRun previews the outcome without saving anything or publishing events
+	DryRun bool
+}
+
+// Reasons a payment was acknowledged but not applied
+const (
+	ReasonStatusNotComplete = "STATUS_NOT_COMPLETE"
+	ReasonDryRun            = "DRY_RUN"
+	ReasonAlreadyFullyPaid  = "ALREADY_FULLY_PAID"
+)
+
+// PaymentReasons lists every reason a payment can be left unapplied
+func PaymentReasons() []string {
+	return []string{ReasonStatusNotComplete, ReasonDryRun, ReasonAlreadyFullyPaid}
+}
+
+// PaymentOutcome says what a ProcessPayment call did, so callers can tell
+// a call that changed state from a no-op without reading Message
+type PaymentOutcome string
+
+const (
+	// OutcomeProcessed: this call applied the payment
+	OutcomeProcessed PaymentOutcome = "PROCESSED"
+	// OutcomeDuplicate: an earlier call with the same reference applied it
+	OutcomeDuplicate PaymentOutcome = "DUPLICATE"
+	// OutcomeNotComplete: the provider reported a status other than COMPLETE
+	OutcomeNotComplete PaymentOutcome = "NOT_COMPLETE"
+	// OutcomePreview: a dry run that would have applied the payment
+	OutcomePreview PaymentOutcome = "PREVIEW"
+	// OutcomeAlreadyPaid: the customer had already paid off the asset, so
+	// the payment was recorded and settled as an overpayment instead
+	OutcomeAlreadyPaid PaymentOutcome = "ALREADY_PAID"
+	// OutcomeFailed: ProcessPayment returned an error. No response carries
+	// it; it is what callers report for the error.
+	OutcomeFailed PaymentOutcome = "FAILED"
+)
+
+// PaymentOutcomes lists every PaymentOutcome
+func PaymentOutcomes() []PaymentOutcome {
+	return []PaymentOutcome{OutcomeProcessed, OutcomeDuplicate, OutcomeNotComplete, OutcomePreview, OutcomeAlreadyPaid, OutcomeFailed}
 }
 
 type ProcessPaymentResponse struct {
-	Success            bool
+	Outcome PaymentOutcome
+	Success bool
+	// Processed is true once the payment has been applied to the balance,
+	// including by an earlier request with the same reference. Reason
+	// says why it was not.
+	Processed          bool
+	Reason             string
 	Message            string
 	CustomerID         string
 	OutstandingBalance int64
 	TotalPaid          int64
 	PaymentProgress    float64
 	IsFullyPaid        bool
+	// DryRun marks a projected result that was not persisted
+	DryRun bool
+	// Flagged marks a payment that crossed the velocity limits; it was
+	// still applied
+	Flagged bool
+	// Installment compares the payment with the repayment schedule; nil
+	// when the payment was not evaluated
+	Installment *InstallmentCheck
+	// PaymentID is the recorded payment, or for a duplicate the one
+	// recorded first; empty when nothing was recorded. ProcessedAt is when
+	// it was applied, zero if it wasn't or isn't known.
+	PaymentID   string
+	ProcessedAt time.Time
+}
+
+// withPayment points the response at the recorded payment; nil leaves it
+// as it is
+func (r *ProcessPaymentResponse) withPayment(payment *domain.Payment) *ProcessPaymentResponse {
+	if payment != nil {
+		r.PaymentID = payment.ID
+		r.ProcessedAt = payment.ProcessedAt
+	}
+	return r
 }
 
+// InstallmentCheck reports how a payment measured up to the installment
+// due that week, and the arrears left once it was applied
+type InstallmentCheck struct {
+	Expected int64
+	Standing domain.InstallmentStanding
+	Arrears  int64
+}
+
+// checkInstallment must be given the customer before the payment is applied
+func checkInstallment(customer *domain.Customer, req ProcessPaymentRequest) *InstallmentCheck {
+	return &InstallmentCheck{
+		Expected: customer.ExpectedInstallment(req.TransactionDate),
+		Standing: customer.CompareInstallment(req.TransactionAmount, req.TransactionDate),
+	}
+}
+
+// ProcessPayment applies a payment. A failure is returned as a
+// *PaymentError saying whether sending the payment again could succeed.
 func (s *PaymentService) ProcessPayment(ctx context.Context, req ProcessPaymentRequest) (*ProcessPaymentResponse, error) {
+	response, err := s.processPayment(ctx, req)
+	if err != nil {
+		return nil, classifyPaymentError(err)
+	}
+	return response, nil
+}
+
+func (s *PaymentService) processPayment(ctx context.Context, req ProcessPaymentRequest) (*ProcessPaymentResponse, error) {
+	req = s.normalizeRequest(req)
+
+	// Published for every inbound request, whatever its outcome, so
+	// analytics can measure raw volume including non-complete payments
+	correlationID := domain.CorrelationIDFromContext(ctx)
+	if s.eventPublisher != nil && !req.DryRun {
+		if err := s.publishPaymentReceivedEvent(ctx, correlationID, req); err != nil {
+			return nil, fmt.Errorf("failed to publish payment received event: %w", err)
+		}
+	}
+
+	start := time.Now()
+	var timings paymentTimings
+	defer func() { s.observeLatency(req, time.Since(start), &timings) }()
+
 	if req.PaymentStatus != "COMPLETE" {
 		s.logger.Info("payment not complete",
 			zap.String("customer_id", req.CustomerID),
 			zap.String("status", req.PaymentStatus),
 			zap.String("tx_ref", req.TransactionReference),
 		)
+		s.recordOutcome(ctx, domain.PaymentStatusFailed, req)
 		return &ProcessPaymentResponse{
+			Outcome: OutcomeNotComplete,
 			Success: false,
+			Reason:  ReasonStatusNotComplete,
 			Message: fmt.Sprintf("payment status is %s, not COMPLETE", req.PaymentStatus),
+			DryRun:  req.DryRun,
 		}, nil
 	}
 
-	exists, err := s.paymentRepo.ExistsByTransactionReference(ctx, req.TransactionReference)
+	if s.atomicApply != nil && !req.DryRun && s.featureEnabled(ctx, FeatureAtomicApply, req.CustomerID) {
+		return s.processPaymentAtomic(ctx, correlationID, req, &timings)
+	}
+
+	step := time.Now()
+	exists, err := s.paymentRepo.ExistsByTransactionReference(ctx, req.CustomerID, req.TransactionReference)
+	timings.dedupCheck = time.Since(step)
 	if err != nil {
-		s.logger.Error("failed to check payment existence",
-			zap.Error(err),
+		logFailure(s.logger, "failed to check payment existence", err,
 			zap.String("tx_ref", req.TransactionReference),
 		)
 		return nil, fmt.Errorf("failed to check payment existence: %w", err)
 	}
 
 	if exists {
-		s.logger.Info("duplicate payment detected",
-			zap.String("customer_id", req.CustomerID),
-			zap.String("tx_ref", req.TransactionReference),
-		)
-
-		customer, err := s.customerRepo.FindByID(ctx, req.CustomerID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get customer for duplicate payment: %w", err)
-		}
-
-		return &ProcessPaymentResponse{
-			Success:            true,
-			Message:            "duplicate transaction - already processed",
-			CustomerID:         customer.ID,
-			OutstandingBalance: customer.OutstandingBalance,
-			TotalPaid:          customer.TotalPaid,
-			PaymentProgress:    customer.GetPaymentProgress(),
-			IsFullyPaid:        customer.IsFullyPaid(),
-		}, nil
+		return s.duplicatePayment(ctx, req)
 	}
 
+	step = time.Now()
 	customer, err := s.customerRepo.FindByID(ctx, req.CustomerID)
+	timings.customerFetch = time.Since(step)
 	if err != nil {
-		s.logger.Error("failed to get customer",
-			zap.Error(err),
+		logFailure(s.logger, "failed to get customer", err,
 			zap.String("customer_id", req.CustomerID),
 		)
 		return nil, fmt.Errorf("failed to get customer: %w", err)
 	}
 
-	if err := customer.ApplyPayment(req.TransactionAmount, req.TransactionDate); err != nil {
+	if err := customer.AcceptsCurrency(req.Currency); err != nil {
+		return nil, err
+	}
+	if customer.Status == domain.CustomerStatusCompleted {
+		return s.acceptSettledPayment(ctx, correlationID, customer, req)
+	}
+	if req.DryRun {
+		return s.previewPayment(customer, req)
+	}
+
+	previousStatus := customer.Status
+	installment := checkInstallment(customer, req)
+	applied, excess, err := s.applyPayment(customer, req)
+	if err != nil {
 		s.logger.Error("failed to apply payment",
 			zap.Error(err),
 			zap.String("customer_id", req.CustomerID),
@@ -110,38 +365,141 @@ func (s *PaymentService) ProcessPayment(ctx context.Context, req ProcessPaymentR
 		return nil, fmt.Errorf("failed to apply payment: %w", err)
 	}
 
-	err = s.customerRepo.Save(ctx, customer)
-	if err == domain.ErrOptimisticLock {
+	payment, err := s.newAppliedPayment(customer, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reads customer and previousStatus as they stand when the save succeeds
+	raise := func() []domain.DomainEvent {
+		return s.paymentEvents(correlationID, customer, previousStatus, req)
+	}
+	events, err := s.saveAppliedPayment(ctx, customer, payment, raise, &timings)
+	if errors.Is(err, domain.ErrOptimisticLock) {
 		s.logger.Warn("optimistic lock conflict, retrying once",
 			zap.String("customer_id", req.CustomerID),
 		)
+		timings.retried = true
 
-		customer, err = s.customerRepo.FindByID(ctx, req.CustomerID)
+		step = time.Now()
+		customer, err = s.refetchCustomer(ctx, req.CustomerID)
+		timings.customerFetch += time.Since(step)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get customer on retry: %w", err)
 		}
 
-		if err := customer.ApplyPayment(req.TransactionAmount, req.TransactionDate); err != nil {
+		previousStatus = customer.Status
+		installment = checkInstallment(customer, req)
+		applied, excess, err = s.applyPayment(customer, req)
+		if errors.Is(err, domain.ErrAssetAlreadyOwned) {
+			// The payment we lost the race to paid the asset off
+			return s.acceptSettledPayment(ctx, correlationID, customer, req)
+		}
+		if err != nil {
 			return nil, fmt.Errorf("failed to apply payment on retry: %w", err)
 		}
 
-		err = s.customerRepo.Save(ctx, customer)
+		if payment, err = s.newAppliedPayment(customer, req); err != nil {
+			return nil, err
+		}
+		events, err = s.saveAppliedPayment(ctx, customer, payment, raise, &timings)
 	}
 
+	if errors.Is(err, domain.ErrDuplicateTransaction) {
+		if s.transactor != nil {
+			// The customer write rolled back with the payment's, so this is
+			// a plain duplicate
+			return s.duplicatePayment(ctx, req)
+		}
+		return s.resolveDuplicateAfterUpdate(ctx, customer, req, applied)
+	}
 	if err != nil {
-		s.logger.Error("failed to save customer",
-			zap.Error(err),
+		logFailure(s.logger, "failed to save payment", err,
 			zap.String("customer_id", req.CustomerID),
 		)
-		return nil, fmt.Errorf("failed to save customer: %w", err)
+		return nil, err
 	}
+	defer s.invalidateCustomerViews(ctx, customer.ID)
+	installment.Arrears = customer.Arrears(req.TransactionDate)
+
+	payment.MarkAsProcessed(s.clock.Now())
+
+	s.logger.Info("payment processed successfully",
+		zap.String("customer_id", req.CustomerID),
+		zap.Int64("amount", req.TransactionAmount),
+		zap.String("tx_ref", req.TransactionReference),
+		zap.Int64("new_balance", customer.OutstandingBalance),
+	)
+
+	// With sync publishing a failure here still leaves the payment applied
+	// and its events logged; a retry is answered as a duplicate
+	if err := s.publishCommitted(ctx, events); err != nil {
+		return nil, err
+	}
+	if excess > 0 {
+		if err := s.settleOverpayment(ctx, correlationID, customer, req, excess); err != nil {
+			return nil, fmt.Errorf("failed to publish payment overpaid event: %w", err)
+		}
+	}
+
+	flagged, err := s.checkVelocity(ctx, correlationID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish payment flagged event: %w", err)
+	}
+
+	return (&ProcessPaymentResponse{
+		Outcome:            OutcomeProcessed,
+		Success:            true,
+		Processed:          true,
+		Message:            "payment processed successfully",
+		CustomerID:         customer.ID,
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		PaymentProgress:    customer.GetPaymentProgress(),
+		IsFullyPaid:        customer.IsFullyPaid(),
+		Flagged:            flagged,
+		Installment:        installment,
+	}).withPayment(payment), nil
+}
+
+// duplicatePayment answers a payment whose reference is already recorded
+// with the customer as they stand
+func (s *PaymentService) duplicatePayment(ctx context.Context, req ProcessPaymentRequest) (*ProcessPaymentResponse, error) {
+	s.logger.Info("duplicate payment detected",
+		zap.String("customer_id", req.CustomerID),
+		zap.String("tx_ref", req.TransactionReference),
+	)
+	s.recordOutcome(ctx, domain.PaymentStatusDuplicate, req)
 
+	customer, err := s.customerRepo.FindByID(ctx, req.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer for duplicate payment: %w", err)
+	}
+
+	return (&ProcessPaymentResponse{
+		Outcome:            OutcomeDuplicate,
+		Success:            true,
+		Processed:          true,
+		Message:            "duplicate transaction - already processed",
+		CustomerID:         customer.ID,
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		PaymentProgress:    customer.GetPaymentProgress(),
+		IsFullyPaid:        customer.IsFullyPaid(),
+		DryRun:             req.DryRun,
+	}).withPayment(s.recordedPayment(ctx, req.CustomerID, req.TransactionReference)), nil
+}
+
+// newAppliedPayment is the payment row for req once it has been applied to
+// customer
+func (s *PaymentService) newAppliedPayment(customer *domain.Customer, req ProcessPaymentRequest) (*domain.Payment, error) {
 	payment, err := domain.NewPayment(
 		req.CustomerID,
 		req.TransactionAmount,
 		req.TransactionReference,
 		req.TransactionDate,
 		domain.PaymentStatusComplete,
+		s.clock.Now(),
 	)
 	if err != nil {
 		s.logger.Error("failed to create payment entity",
@@ -150,60 +508,148 @@ func (s *PaymentService) ProcessPayment(ctx context.Context, req ProcessPaymentR
 		)
 		return nil, fmt.Errorf("invalid payment data: %w", err)
 	}
+	payment.CurrencyCode = customer.Currency().Code
+	payment.RecordBalanceAfter(customer.OutstandingBalance)
+	payment.Metadata = req.Metadata
+	return payment, nil
+}
 
-	if err := s.paymentRepo.Save(ctx, payment); err != nil {
-		if err == domain.ErrDuplicateTransaction {
-			s.logger.Warn("duplicate payment detected after customer update",
-				zap.String("customer_id", req.CustomerID),
-				zap.String("tx_ref", req.TransactionReference),
-			)
+// saveAppliedPayment saves the customer a payment was applied to, records
+// the payment and logs the events raise returns, in one transaction when
+// the service has a Transactor. A lost optimistic lock or a duplicate
+// reference leaves nothing written.
+func (s *PaymentService) saveAppliedPayment(ctx context.Context, customer *domain.Customer, payment *domain.Payment, raise func() []domain.DomainEvent, timings *paymentTimings) ([]domain.DomainEvent, error) {
+	return s.commit(ctx, func(repos domain.TxRepositories) error {
+		step := time.Now()
+		err := repos.Customers.Save(ctx, customer)
+		timings.customerSave += time.Since(step)
+		if err != nil {
+			return fmt.Errorf("failed to save customer: %w", err)
+		}
 
-			return &ProcessPaymentResponse{
-				Success:            true,
-				Message:            "payment processed successfully",
-				CustomerID:         customer.ID,
-				OutstandingBalance: customer.OutstandingBalance,
-				TotalPaid:          customer.TotalPaid,
-				PaymentProgress:    customer.GetPaymentProgress(),
-				IsFullyPaid:        customer.IsFullyPaid(),
-			}, nil
+		step = time.Now()
+		err = repos.Payments.Save(ctx, payment)
+		timings.paymentSave += time.Since(step)
+		if err != nil {
+			return fmt.Errorf("failed to save payment: %w", err)
 		}
+		return nil
+	}, raise)
+}
 
-		s.logger.Error("failed to save payment",
-			zap.Error(err),
-			zap.String("customer_id", req.CustomerID),
+// paymentEvents are the events recording req applied to customer, as saved
+func (s *PaymentService) paymentEvents(correlationID string, customer *domain.Customer, previousStatus domain.CustomerStatus, req ProcessPaymentRequest) []domain.DomainEvent {
+	events := []domain.DomainEvent{s.paymentProcessedEvent(correlationID, customer, req)}
+	if event := s.defaultStatusChangedEvent(correlationID, customer, previousStatus); event != nil {
+		events = append(events, event)
+	}
+	return events
+}
+
+// recordedPayment finds the payment already stored under a duplicate
+// reference so the response can name it. It only adds detail, so a failed
+// lookup is logged and answered with nil.
+func (s *PaymentService) recordedPayment(ctx context.Context, customerID, txRef string) *domain.Payment {
+	payment, err := s.paymentRepo.FindByTransactionReference(ctx, customerID, txRef)
+	if err != nil {
+		logFailure(s.logger, "failed to get payment for duplicate reference", err,
+			zap.String("tx_ref", txRef),
 		)
-		return nil, fmt.Errorf("failed to save payment: %w", err)
+		return nil
 	}
+	return payment
+}
 
-	payment.MarkAsProcessed()
+// refetchCustomer reads a customer again after an optimistic lock conflict,
+// past the cache when the service has an uncached finder
+func (s *PaymentService) refetchCustomer(ctx context.Context, customerID string) (*domain.Customer, error) {
+	if s.uncachedCustomers != nil {
+		return s.uncachedCustomers.FindByIDSkipCache(ctx, customerID)
+	}
+	return s.customerRepo.FindByID(ctx, customerID)
+}
 
-	s.logger.Info("payment processed successfully",
+func (s *PaymentService) observeLatency(req ProcessPaymentRequest, total time.Duration, timings *paymentTimings) {
+	paymentLatency.Observe(total.Seconds())
+
+	if s.slowPaymentThreshold <= 0 || total <= s.slowPaymentThreshold {
+		return
+	}
+
+	s.logger.Warn("slow payment processing",
 		zap.String("customer_id", req.CustomerID),
-		zap.Int64("amount", req.TransactionAmount),
 		zap.String("tx_ref", req.TransactionReference),
-		zap.Int64("new_balance", customer.OutstandingBalance),
+		zap.Duration("total", total),
+		zap.Bool("retried", timings.retried),
+		zap.Duration("dedup_check", timings.dedupCheck),
+		zap.Duration("customer_fetch", timings.customerFetch),
+		zap.Duration("customer_save", timings.customerSave),
+		zap.Duration("payment_save", timings.paymentSave),
+		zap.Duration("threshold", s.slowPaymentThreshold),
 	)
+}
 
-	if s.eventPublisher != nil {
-		go s.publishPaymentProcessedEvent(customer, req)
+// previewPayment projects the payment onto a copy of the customer so
+// nothing the repositories handed us is mutated
+func (s *PaymentService) previewPayment(customer *domain.Customer, req ProcessPaymentRequest) (*ProcessPaymentResponse, error) {
+	projected := *customer
+	installment := checkInstallment(&projected, req)
+	if _, _, err := s.applyPayment(&projected, req); err != nil {
+		return nil, fmt.Errorf("failed to apply payment: %w", err)
 	}
+	installment.Arrears = projected.Arrears(req.TransactionDate)
+
+	s.logger.Info("payment previewed",
+		zap.String("customer_id", req.CustomerID),
+		zap.Int64("amount", req.TransactionAmount),
+		zap.String("tx_ref", req.TransactionReference),
+		zap.Int64("projected_balance", projected.OutstandingBalance),
+	)
 
 	return &ProcessPaymentResponse{
+		Outcome:            OutcomePreview,
 		Success:            true,
-		Message:            "payment processed successfully",
-		CustomerID:         customer.ID,
-		OutstandingBalance: customer.OutstandingBalance,
-		TotalPaid:          customer.TotalPaid,
-		PaymentProgress:    customer.GetPaymentProgress(),
-		IsFullyPaid:        customer.IsFullyPaid(),
+		Reason:             ReasonDryRun,
+		Message:            "preview only - payment not applied",
+		CustomerID:         projected.ID,
+		OutstandingBalance: projected.OutstandingBalance,
+		TotalPaid:          projected.TotalPaid,
+		PaymentProgress:    projected.GetPaymentProgress(),
+		IsFullyPaid:        projected.IsFullyPaid(),
+		DryRun:             true,
+		Installment:        installment,
 	}, nil
 }
 
-func (s *PaymentService) publishPaymentProcessedEvent(customer *domain.Customer, req ProcessPaymentRequest) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// applyPayment enforces service-level payment rules before mutating the
+// customer, then re-evaluates default against the schedule. It returns the
+// amount added to the customer's total and the overpayment, which is left
+// off that total when the policy sends it elsewhere.
+func (s *PaymentService) applyPayment(customer *domain.Customer, req ProcessPaymentRequest) (applied, excess int64, err error) {
+	if err := customer.ValidatePaymentAmount(req.TransactionAmount, s.minimumPaymentAmount); err != nil {
+		return 0, 0, err
+	}
+	if err := customer.ValidateOverpayment(req.TransactionAmount, s.overpaymentLimit); err != nil {
+		return 0, 0, err
+	}
+
+	amount := req.TransactionAmount
+	// A settled loan has no balance to split against; ApplyPayment rejects it
+	excess = customer.Overpayment(amount)
+	if excess > 0 && excess < amount && s.overpayment.redirects() {
+		amount -= excess
+	}
+
+	if err := customer.ApplyPayment(amount, req.TransactionDate); err != nil {
+		return 0, 0, err
+	}
+	customer.UpdateDefaultStatus(req.TransactionDate, s.defaultThreshold)
+	return amount, excess, nil
+}
 
+// paymentProcessedEvent records req applied to customer, as saved
+func (s *PaymentService) paymentProcessedEvent(correlationID string, customer *domain.Customer, req ProcessPaymentRequest) *domain.PaymentProcessedEvent {
+	now := s.clock.Now()
 	event := domain.NewPaymentProcessedEvent(customer.ID, domain.PaymentProcessedPayload{
 		CustomerID:           customer.ID,
 		TransactionReference: req.TransactionReference,
@@ -212,30 +658,161 @@ func (s *PaymentService) publishPaymentProcessedEvent(customer *domain.Customer,
 		TotalPaid:            customer.TotalPaid,
 		PaymentProgress:      customer.GetPaymentProgress(),
 		IsFullyPaid:          customer.IsFullyPaid(),
-		ProcessedAt:          time.Now(),
-	})
+		ProcessedAt:          now,
+		Currency:             customer.Currency().Code,
+		MoneyAsStrings:       s.moneyAsStrings,
+	}, now)
+	event.CorrelationID = correlationID
+	event.Sequence = customer.Version
+	return event
+}
 
-	if err := s.eventPublisher.Publish(ctx, event); err != nil {
-		s.logger.Error("failed to publish payment processed event",
+func (s *PaymentService) publishPaymentReceivedEvent(ctx context.Context, correlationID string, req ProcessPaymentRequest) error {
+	now := s.clock.Now()
+	event := domain.NewPaymentReceivedEvent(req.CustomerID, domain.PaymentReceivedPayload{
+		CustomerID:           req.CustomerID,
+		PaymentStatus:        req.PaymentStatus,
+		TransactionReference: req.TransactionReference,
+		Amount:               req.TransactionAmount,
+		TransactionDate:      req.TransactionDate,
+		ReceivedAt:           now,
+	}, now)
+	event.CorrelationID = correlationID
+
+	return s.publishEvent(ctx, event)
+}
+
+// publishEvent logs event and publishes it in the background so callers
+// never wait on the broker. WaitForPublishes lets shutdown wait for it to
+// finish. With sync publishing it publishes before returning instead and
+// hands back the error. An event that can't be logged is dropped, failing
+// the call only with sync publishing.
+func (s *PaymentService) publishEvent(ctx context.Context, event domain.DomainEvent) error {
+	sync := s.publishesSync(ctx, event)
+	if err := s.logEvents(ctx, s.eventLog, event); err != nil {
+		if sync {
+			return err
+		}
+		return nil
+	}
+	return s.dispatchEvent(ctx, event, sync)
+}
+
+// publishesSync reports whether event is published before the call that
+// raised it returns
+func (s *PaymentService) publishesSync(ctx context.Context, event domain.DomainEvent) bool {
+	return s.syncPublish && s.featureEnabled(ctx, FeatureSyncPublishing, event.GetAggregateID())
+}
+
+// dispatchEvent hands an event that is already logged to the publisher
+func (s *PaymentService) dispatchEvent(ctx context.Context, event domain.DomainEvent, sync bool) error {
+	if sync {
+		return s.sendEvent(ctx, event)
+	}
+
+	s.publishes.Add(1)
+	go func() {
+		defer s.publishes.Done()
+		s.sendEvent(context.Background(), event)
+	}()
+	return nil
+}
+
+// WaitForPublishes blocks until every event handed to the publisher so far
+// has been sent, or ctx is done. Call it once no new requests can arrive.
+func (s *PaymentService) WaitForPublishes(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.publishes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logFailure logs a failed repository call. A caller that hung up is logged
+// at info and one that ran out of time at warn, so neither pages anyone
+// the way a real database error should.
+func logFailure(logger *zap.Logger, msg string, err error, fields ...zap.Field) {
+	fields = append([]zap.Field{zap.Error(err)}, fields...)
+	switch {
+	case errors.Is(err, domain.ErrRequestCanceled):
+		logger.Info(msg, fields...)
+	case errors.Is(err, domain.ErrRequestTimeout):
+		logger.Warn(msg, fields...)
+	default:
+		logger.Error(msg, fields...)
+	}
+}
+
+func (s *PaymentService) sendEvent(ctx context.Context, event domain.DomainEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := s.eventPublisher.Publish(ctx, event)
+	if err != nil {
+		s.logger.Error("failed to publish event",
 			zap.Error(err),
-			zap.String("customer_id", customer.ID),
+			zap.String("event_type", event.GetEventType()),
+			zap.String("customer_id", event.GetAggregateID()),
 			zap.String("event_id", event.GetEventID()),
+			zap.String("correlation_id", event.GetCorrelationID()),
 		)
 	} else {
-		s.logger.Debug("payment processed event published",
+		s.logger.Debug("event published",
+			zap.String("event_type", event.GetEventType()),
 			zap.String("event_id", event.GetEventID()),
-			zap.String("customer_id", customer.ID),
+			zap.String("customer_id", event.GetAggregateID()),
+			zap.String("correlation_id", event.GetCorrelationID()),
 		)
 	}
+	return err
 }
 
 func (s *PaymentService) GetCustomer(ctx context.Context, customerID string) (*domain.Customer, error) {
-	return s.customerRepo.FindByID(ctx, customerID)
+	return s.customerRepo.FindByID(ctx, NormalizeCustomerID(customerID))
+}
+
+// GetCustomers looks up several customers at once. IDs are normalized and
+// de-duplicated; those that don't exist are returned in notFound, in the
+// order they were first requested.
+func (s *PaymentService) GetCustomers(ctx context.Context, customerIDs []string) (customers map[string]*domain.Customer, notFound []string, err error) {
+	ids := make([]string, 0, len(customerIDs))
+	seen := make(map[string]struct{}, len(customerIDs))
+	for _, id := range customerIDs {
+		id = NormalizeCustomerID(id)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	customers, err = s.customerRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notFound = []string{}
+	for _, id := range ids {
+		if _, ok := customers[id]; !ok {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return customers, notFound, nil
 }
 
 type PaginationParams struct {
 	Page     int
 	PageSize int
+	// Filter narrows and orders the payments paged over
+	Filter domain.PaymentFilter
 }
 
 type PaginatedPaymentsResponse struct {
@@ -247,10 +824,11 @@ type PaginatedPaymentsResponse struct {
 }
 
 func (s *PaymentService) GetCustomerPayments(ctx context.Context, customerID string) ([]*domain.Payment, error) {
+	customerID = NormalizeCustomerID(customerID)
+
 	_, err := s.customerRepo.FindByID(ctx, customerID)
 	if err != nil {
-		s.logger.Error("failed to get customer",
-			zap.Error(err),
+		logFailure(s.logger, "failed to get customer", err,
 			zap.String("customer_id", customerID),
 		)
 		return nil, fmt.Errorf("failed to get customer: %w", err)
@@ -258,8 +836,7 @@ func (s *PaymentService) GetCustomerPayments(ctx context.Context, customerID str
 
 	payments, err := s.paymentRepo.FindByCustomerID(ctx, customerID)
 	if err != nil {
-		s.logger.Error("failed to get customer payments",
-			zap.Error(err),
+		logFailure(s.logger, "failed to get customer payments", err,
 			zap.String("customer_id", customerID),
 		)
 		return nil, fmt.Errorf("failed to get payments: %w", err)
@@ -273,44 +850,57 @@ func (s *PaymentService) GetCustomerPayments(ctx context.Context, customerID str
 	return payments, nil
 }
 
-func (s *PaymentService) GetCustomerPaymentsPaginated(ctx context.Context, customerID string, params PaginationParams) (*PaginatedPaymentsResponse, error) {
-	if params.Page < 1 {
-		params.Page = 1
-	}
-	if params.PageSize < 1 {
-		params.PageSize = 10
-	}
-	if params.PageSize > 100 {
-		params.PageSize = 100
-	}
+// GetCustomerPaymentsFiltered lists a customer's payments in the given
+// statuses and order
+func (s *PaymentService) GetCustomerPaymentsFiltered(ctx context.Context, customerID string, filter domain.PaymentFilter) ([]*domain.Payment, error) {
+	customerID = NormalizeCustomerID(customerID)
 
 	_, err := s.customerRepo.FindByID(ctx, customerID)
 	if err != nil {
-		s.logger.Error("failed to get customer",
-			zap.Error(err),
+		logFailure(s.logger, "failed to get customer", err,
 			zap.String("customer_id", customerID),
 		)
 		return nil, fmt.Errorf("failed to get customer: %w", err)
 	}
 
-	totalCount, err := s.paymentRepo.CountByCustomerID(ctx, customerID)
+	payments, err := s.paymentRepo.FindByCustomerIDFiltered(ctx, customerID, filter)
 	if err != nil {
-		s.logger.Error("failed to count customer payments",
-			zap.Error(err),
+		logFailure(s.logger, "failed to get filtered customer payments", err,
 			zap.String("customer_id", customerID),
 		)
-		return nil, fmt.Errorf("failed to count payments: %w", err)
+		return nil, fmt.Errorf("failed to get payments: %w", err)
 	}
 
-	offset := (params.Page - 1) * params.PageSize
+	s.logger.Info("retrieved filtered customer payments",
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(payments)),
+		zap.String("order", string(filter.Order)),
+	)
+
+	return payments, nil
+}
+
+func (s *PaymentService) GetCustomerPaymentsPaginated(ctx context.Context, customerID string, params PaginationParams) (*PaginatedPaymentsResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
 
-	payments, err := s.paymentRepo.FindByCustomerIDWithPagination(ctx, customerID, params.PageSize, offset)
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	params.PageSize = s.pageSizes.size(params.PageSize)
+
+	_, err := s.customerRepo.FindByID(ctx, customerID)
 	if err != nil {
-		s.logger.Error("failed to get customer payments",
-			zap.Error(err),
+		logFailure(s.logger, "failed to get customer", err,
 			zap.String("customer_id", customerID),
 		)
-		return nil, fmt.Errorf("failed to get payments: %w", err)
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	offset := (params.Page - 1) * params.PageSize
+
+	payments, totalCount, err := s.findPaymentPage(ctx, customerID, params.Filter, params.PageSize, offset)
+	if err != nil {
+		return nil, err
 	}
 
 	totalPages := int(totalCount) / params.PageSize
@@ -334,3 +924,135 @@ func (s *PaymentService) GetCustomerPaymentsPaginated(ctx context.Context, custo
 		TotalPages: totalPages,
 	}, nil
 }
+
+// findPaymentPage reads a page of the customer's payments matching filter
+// and their total count, from one snapshot when a PaymentPager is configured
+func (s *PaymentService) findPaymentPage(ctx context.Context, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, int64, error) {
+	if s.paymentPager != nil {
+		payments, totalCount, err := s.paymentPager.FindPageByCustomerID(ctx, customerID, filter, limit, offset)
+		if err != nil {
+			logFailure(s.logger, "failed to get customer payments", err,
+				zap.String("customer_id", customerID),
+			)
+			return nil, 0, fmt.Errorf("failed to get payments: %w", err)
+		}
+		return payments, totalCount, nil
+	}
+	if len(filter.Statuses) > 0 || filter.Order != "" {
+		// Without a pager there is no filtered count, so the page is cut
+		// from the full filtered listing
+		payments, err := s.paymentRepo.FindByCustomerIDFiltered(ctx, customerID, filter)
+		if err != nil {
+			logFailure(s.logger, "failed to get filtered customer payments", err,
+				zap.String("customer_id", customerID),
+			)
+			return nil, 0, fmt.Errorf("failed to get payments: %w", err)
+		}
+		if offset >= len(payments) {
+			return []*domain.Payment{}, int64(len(payments)), nil
+		}
+		return payments[offset:min(offset+limit, len(payments))], int64(len(payments)), nil
+	}
+
+	totalCount, err := s.paymentRepo.CountByCustomerID(ctx, customerID)
+	if err != nil {
+		logFailure(s.logger, "failed to count customer payments", err,
+			zap.String("customer_id", customerID),
+		)
+		return nil, 0, fmt.Errorf("failed to count payments: %w", err)
+	}
+
+	payments, err := s.paymentRepo.FindByCustomerIDWithPagination(ctx, customerID, limit, offset)
+	if err != nil {
+		logFailure(s.logger, "failed to get customer payments", err,
+			zap.String("customer_id", customerID),
+		)
+		return nil, 0, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	return payments, totalCount, nil
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PaymentCursor is the last-seen (transaction_date, id) key of a keyset page.
+// It is handed to clients as an opaque base64 string.
+type PaymentCursor struct {
+	TransactionDate time.Time `json:"d"`
+	ID              string    `json:"i"`
+}
+
+func (c PaymentCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodePaymentCursor parses an opaque cursor; an empty string is the start.
+func DecodePaymentCursor(cursor string) (PaymentCursor, error) {
+	var c PaymentCursor
+	if cursor == "" {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return PaymentCursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+type CursorPaymentsResponse struct {
+	Payments   []*domain.Payment
+	NextCursor string
+}
+
+// GetCustomerPaymentsByCursor pages through a customer's payments matching
+// filter in (transaction_date, id) order, oldest first unless filter says
+// otherwise. Unlike offset pagination, rows inserted mid-iteration never
+// cause earlier rows to be skipped or repeated.
+func (s *PaymentService) GetCustomerPaymentsByCursor(ctx context.Context, customerID string, filter domain.PaymentFilter, cursor string, limit int) (*CursorPaymentsResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
+
+	limit = s.pageSizes.size(limit)
+
+	after, err := DecodePaymentCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		logFailure(s.logger, "failed to get customer", err,
+			zap.String("customer_id", customerID),
+		)
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	payments, err := s.paymentRepo.FindByCustomerIDAfter(ctx, customerID, filter, after.TransactionDate, after.ID, limit+1)
+	if err != nil {
+		logFailure(s.logger, "failed to get customer payments", err,
+			zap.String("customer_id", customerID),
+		)
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	response := &CursorPaymentsResponse{Payments: payments}
+	if len(payments) > limit {
+		response.Payments = payments[:limit]
+		last := response.Payments[limit-1]
+		response.NextCursor = PaymentCursor{TransactionDate: last.TransactionDate, ID: last.ID}.Encode()
+	}
+
+	s.logger.Info("retrieved customer payments with cursor",
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(response.Payments)),
+		zap.Bool("has_more", response.NextCursor != ""),
+	)
+
+	return response, nil
+}
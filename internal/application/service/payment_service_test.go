@@ -3,13 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type MockCustomerRepository struct {
@@ -24,6 +29,14 @@ func (m *MockCustomerRepository) FindByID(ctx context.Context, customerID string
 	return args.Get(0).(*domain.Customer), args.Error(1)
 }
 
+func (m *MockCustomerRepository) FindByIDs(ctx context.Context, customerIDs []string) (map[string]*domain.Customer, error) {
+	args := m.Called(ctx, customerIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*domain.Customer), args.Error(1)
+}
+
 func (m *MockCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
 	args := m.Called(ctx, customer)
 	return args.Error(0)
@@ -43,16 +56,16 @@ func (m *MockPaymentRepository) Save(ctx context.Context, payment *domain.Paymen
 	return args.Error(0)
 }
 
-func (m *MockPaymentRepository) FindByTransactionReference(ctx context.Context, txRef string) (*domain.Payment, error) {
-	args := m.Called(ctx, txRef)
+func (m *MockPaymentRepository) FindByTransactionReference(ctx context.Context, customerID, txRef string) (*domain.Payment, error) {
+	args := m.Called(ctx, customerID, txRef)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.Payment), args.Error(1)
 }
 
-func (m *MockPaymentRepository) ExistsByTransactionReference(ctx context.Context, txRef string) (bool, error) {
-	args := m.Called(ctx, txRef)
+func (m *MockPaymentRepository) ExistsByTransactionReference(ctx context.Context, customerID, txRef string) (bool, error) {
+	args := m.Called(ctx, customerID, txRef)
 	return args.Bool(0), args.Error(1)
 }
 
@@ -64,6 +77,14 @@ func (m *MockPaymentRepository) FindByCustomerID(ctx context.Context, customerID
 	return args.Get(0).([]*domain.Payment), args.Error(1)
 }
 
+func (m *MockPaymentRepository) FindByCustomerIDFiltered(ctx context.Context, customerID string, filter domain.PaymentFilter) ([]*domain.Payment, error) {
+	args := m.Called(ctx, customerID, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Payment), args.Error(1)
+}
+
 func (m *MockPaymentRepository) CountByCustomerID(ctx context.Context, customerID string) (int64, error) {
 	args := m.Called(ctx, customerID)
 	return args.Get(0).(int64), args.Error(1)
@@ -77,6 +98,59 @@ func (m *MockPaymentRepository) FindByCustomerIDWithPagination(ctx context.Conte
 	return args.Get(0).([]*domain.Payment), args.Error(1)
 }
 
+func (m *MockPaymentRepository) FindByCustomerIDAfter(ctx context.Context, customerID string, filter domain.PaymentFilter, afterDate time.Time, afterID string, limit int) ([]*domain.Payment, error) {
+	args := m.Called(ctx, customerID, filter, afterDate, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) FindByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, error) {
+	args := m.Called(ctx, from, to, customerID, filter, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) TotalsByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter) (domain.PaymentTotals, error) {
+	args := m.Called(ctx, from, to, customerID, filter)
+	return args.Get(0).(domain.PaymentTotals), args.Error(1)
+}
+
+// recordingPublisher is a concurrency-safe EventPublisher that keeps
+// every event it is handed
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []domain.DomainEvent
+	err    error
+	// failOn limits err to events of this type when set
+	failOn string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event domain.DomainEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil && (p.failOn == "" || p.failOn == event.GetEventType()) {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) eventsOfType(eventType string) []domain.DomainEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var events []domain.DomainEvent
+	for _, event := range p.events {
+		if event.GetEventType() == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
 func TestGetCustomerPayments_Success(t *testing.T) {
 	ctx := context.Background()
 	customerID := "GIG00001"
@@ -286,3 +360,915 @@ func TestGetCustomerPayments_ContextCancellation(t *testing.T) {
 
 	mockCustomerRepo.AssertExpectations(t)
 }
+
+func TestGetCustomerPaymentsPaginated_CountFromPager(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00006"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	pager := testutil.NewMemoryPaymentRepository()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, ref := range []string{"TXN001", "TXN002", "TXN003"} {
+		payment, err := domain.NewPayment(customerID, 1000, ref, base.Add(time.Duration(i)*time.Hour), domain.PaymentStatusComplete, base)
+		require.NoError(t, err)
+		require.NoError(t, pager.Save(ctx, payment))
+	}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithPaymentPager(pager))
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(&domain.Customer{ID: customerID}, nil)
+
+	result, err := service.GetCustomerPaymentsPaginated(ctx, customerID, PaginationParams{Page: 2, PageSize: 2})
+
+	require.NoError(t, err)
+	require.Len(t, result.Payments, 1)
+	assert.Equal(t, "TXN001", result.Payments[0].TransactionReference)
+	assert.Equal(t, int64(3), result.TotalCount)
+	assert.Equal(t, 2, result.TotalPages)
+	// Neither separate query is made
+	mockPaymentRepo.AssertNotCalled(t, "CountByCustomerID", mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "FindByCustomerIDWithPagination", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetCustomerPaymentsByCursor_ReturnsNextCursor(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00006"
+	logger := zap.NewNop()
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, logger)
+
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(&domain.Customer{ID: customerID}, nil)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	payments := []*domain.Payment{
+		{ID: "p1", CustomerID: customerID, TransactionDate: base},
+		{ID: "p2", CustomerID: customerID, TransactionDate: base.Add(time.Hour)},
+		{ID: "p3", CustomerID: customerID, TransactionDate: base.Add(2 * time.Hour)},
+	}
+	mockPaymentRepo.On("FindByCustomerIDAfter", ctx, customerID, domain.PaymentFilter{}, time.Time{}, "", 3).Return(payments, nil)
+
+	result, err := service.GetCustomerPaymentsByCursor(ctx, customerID, domain.PaymentFilter{}, "", 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Payments, 2)
+	assert.NotEmpty(t, result.NextCursor)
+
+	cursor, err := DecodePaymentCursor(result.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, "p2", cursor.ID)
+	assert.True(t, cursor.TransactionDate.Equal(base.Add(time.Hour)))
+
+	mockCustomerRepo.AssertExpectations(t)
+	mockPaymentRepo.AssertExpectations(t)
+}
+
+func TestGetCustomerPaymentsByCursor_LastPageHasNoCursor(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00007"
+	logger := zap.NewNop()
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, logger)
+
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(&domain.Customer{ID: customerID}, nil)
+
+	after := PaymentCursor{TransactionDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ID: "p2"}
+	mockPaymentRepo.On("FindByCustomerIDAfter", ctx, customerID, domain.PaymentFilter{}, after.TransactionDate, "p2", 11).
+		Return([]*domain.Payment{{ID: "p3", CustomerID: customerID}}, nil)
+
+	result, err := service.GetCustomerPaymentsByCursor(ctx, customerID, domain.PaymentFilter{}, after.Encode(), 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Payments, 1)
+	assert.Empty(t, result.NextCursor)
+}
+
+func TestGetCustomerPaymentsByCursor_InvalidCursor(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, logger)
+
+	result, err := service.GetCustomerPaymentsByCursor(ctx, "GIG00008", domain.PaymentFilter{}, "not-a-cursor!", 10)
+
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	assert.Nil(t, result)
+	mockCustomerRepo.AssertNotCalled(t, "FindByID")
+}
+
+func completePaymentRequest(customerID, txRef string) ProcessPaymentRequest {
+	return ProcessPaymentRequest{
+		CustomerID:           customerID,
+		PaymentStatus:        "COMPLETE",
+		TransactionAmount:    1000000,
+		TransactionDate:      time.Date(2025, 11, 24, 14, 54, 16, 0, time.UTC),
+		TransactionReference: txRef,
+	}
+}
+
+// assertReceivedOnce waits for the service's async publishes and checks the
+// received event went out exactly once
+func assertReceivedOnce(t *testing.T, service *PaymentService, publisher *recordingPublisher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, service.WaitForPublishes(ctx))
+	assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentReceived), 1)
+}
+
+func TestProcessPayment_PublishesReceivedEventForSuccess(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00010"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN010").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN010"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeProcessed, result.Outcome)
+	assert.True(t, result.Success)
+	assert.True(t, result.Processed)
+	assert.Empty(t, result.Reason)
+	assertReceivedOnce(t, service, publisher)
+
+	received, ok := publisher.eventsOfType(domain.EventTypePaymentReceived)[0].(*domain.PaymentReceivedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, customerID, received.GetAggregateID())
+		assert.Equal(t, "COMPLETE", received.Payload.PaymentStatus)
+		assert.Equal(t, "TXN010", received.Payload.TransactionReference)
+		assert.Equal(t, int64(1000000), received.Payload.Amount)
+	}
+}
+
+func TestProcessPayment_PublishesReceivedEventForNonCompleteStatus(t *testing.T) {
+	ctx := context.Background()
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	req := completePaymentRequest("GIG00011", "TXN011")
+	req.PaymentStatus = "PENDING"
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeNotComplete, result.Outcome)
+	assert.False(t, result.Success)
+	assert.False(t, result.Processed)
+	assert.Equal(t, ReasonStatusNotComplete, result.Reason)
+	assertReceivedOnce(t, service, publisher)
+	assert.Empty(t, publisher.eventsOfType(domain.EventTypePaymentProcessed))
+}
+
+func TestProcessPayment_PublishesReceivedEventForDuplicate(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00012"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN012").Return(true, nil)
+	mockPaymentRepo.On("FindByTransactionReference", ctx, customerID, "TXN012").Return(&domain.Payment{ID: "payment-12"}, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(&domain.Customer{ID: customerID, AssetValue: 100}, nil)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN012"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, result.Outcome)
+	assert.Contains(t, result.Message, "duplicate")
+	assertReceivedOnce(t, service, publisher)
+	assert.Empty(t, publisher.eventsOfType(domain.EventTypePaymentProcessed))
+}
+
+func TestProcessPayment_PublishesReceivedEventForFailure(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00013"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN013").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(nil, errors.New("customer not found"))
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN013"))
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assertReceivedOnce(t, service, publisher)
+}
+
+func TestProcessPayment_ErrorsSayWhetherToRetry(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"database failure", errors.New("database error: connection refused"), true},
+		{"timed out", fmt.Errorf("%w: %w", domain.ErrRequestTimeout, context.DeadlineExceeded), true},
+		{"unknown customer", domain.ErrCustomerNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCustomerRepo := new(MockCustomerRepository)
+			mockPaymentRepo := new(MockPaymentRepository)
+			service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+			mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, "TXN014").Return(false, nil)
+			mockCustomerRepo.On("FindByID", ctx, "GIG00014").Return(nil, tt.err)
+
+			_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00014", "TXN014"))
+
+			var paymentErr *PaymentError
+			require.ErrorAs(t, err, &paymentErr)
+			assert.Equal(t, tt.retryable, paymentErr.Retryable)
+			assert.Equal(t, tt.retryable, IsRetryable(err))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestProcessPayment_RejectsPaymentBelowMinimum(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00020"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithMinimumPaymentAmount(50000))
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN020").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+	req := completePaymentRequest(customerID, "TXN020")
+	req.TransactionAmount = 49999
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	assert.ErrorIs(t, err, domain.ErrBelowMinimumPayment)
+	assert.Nil(t, result)
+	assert.Equal(t, int64(100000000), customer.OutstandingBalance)
+	mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestProcessPayment_OverpaymentLimit(t *testing.T) {
+	customerID := "GIG00024"
+	limit := domain.OverpaymentLimit{Enabled: true, Percent: 10}
+
+	newCustomer := func() *domain.Customer {
+		return &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 1000000, TotalPaid: 99000000, Status: domain.CustomerStatusActive, Version: 1}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		amount int64
+	}{
+		{"exact payoff", 1000000},
+		{"within tolerance", 1100000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCustomerRepo := new(MockCustomerRepository)
+			mockPaymentRepo := new(MockPaymentRepository)
+			service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithOverpaymentLimit(limit))
+
+			customer := newCustomer()
+			mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN024").Return(false, nil)
+			mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+			mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+			mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+			req := completePaymentRequest(customerID, "TXN024")
+			req.TransactionAmount = tt.amount
+
+			result, err := service.ProcessPayment(ctx, req)
+
+			require.NoError(t, err)
+			assert.Equal(t, OutcomeProcessed, result.Outcome)
+			assert.True(t, result.IsFullyPaid)
+			assert.Zero(t, result.OutstandingBalance)
+		})
+	}
+
+	t.Run("gross overpayment", func(t *testing.T) {
+		ctx := context.Background()
+		mockCustomerRepo := new(MockCustomerRepository)
+		mockPaymentRepo := new(MockPaymentRepository)
+		service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithOverpaymentLimit(limit))
+
+		customer := newCustomer()
+		mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN024").Return(false, nil)
+		mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+		req := completePaymentRequest(customerID, "TXN024")
+		req.TransactionAmount = 10000000
+
+		result, err := service.ProcessPayment(ctx, req)
+
+		assert.ErrorIs(t, err, domain.ErrExcessiveOverpayment)
+		assert.Nil(t, result)
+		assert.Equal(t, int64(1000000), customer.OutstandingBalance)
+		assert.Equal(t, domain.CustomerStatusActive, customer.Status)
+		mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+}
+
+func TestProcessPayment_RejectsPaymentInAnotherCurrency(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00022"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 5000000, OutstandingBalance: 5000000, Status: domain.CustomerStatusActive, Version: 1, CurrencyCode: "UGX"}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN022").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+	req := completePaymentRequest(customerID, "TXN022")
+	req.Currency = "NGN"
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	assert.ErrorIs(t, err, domain.ErrCurrencyMismatch)
+	assert.Nil(t, result)
+	assert.Equal(t, int64(5000000), customer.OutstandingBalance)
+	mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestProcessPayment_RecordsTheCustomersCurrency(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00023"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 5000000, OutstandingBalance: 5000000, Status: domain.CustomerStatusActive, Version: 1, CurrencyCode: "UGX"}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN023").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.MatchedBy(func(p *domain.Payment) bool {
+		return p.CurrencyCode == "UGX" && p.Amount == 250000
+	})).Return(nil)
+
+	req := completePaymentRequest(customerID, "TXN023")
+	req.TransactionAmount = 250000
+	req.Currency = " ugx "
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(4750000), result.OutstandingBalance)
+	mockPaymentRepo.AssertExpectations(t)
+}
+
+func TestProcessPayment_AllowsSmallPaymentThatSettlesBalance(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00021"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(), WithMinimumPaymentAmount(50000))
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 20000, TotalPaid: 99980000, Status: domain.CustomerStatusActive, Version: 5}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN021").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	req := completePaymentRequest(customerID, "TXN021")
+	req.TransactionAmount = 20000
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.True(t, result.IsFullyPaid)
+	assert.Equal(t, int64(0), result.OutstandingBalance)
+	assert.NoError(t, customer.Validate())
+}
+
+func TestProcessPayment_MinimumDisabledByDefault(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00022"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN022").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	req := completePaymentRequest(customerID, "TXN022")
+	req.TransactionAmount = 100
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+}
+
+func TestProcessPayment_ReportsInstallmentStanding(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00030"
+	req := completePaymentRequest(customerID, "TXN030")
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	// 10,000,000 kobo over 10 weeks, three installments due and none paid
+	customer := &domain.Customer{
+		ID:                 customerID,
+		AssetValue:         10000000,
+		RepaymentTermWeeks: 10,
+		OutstandingBalance: 10000000,
+		DeploymentDate:     req.TransactionDate.Add(-3*7*24*time.Hour - time.Hour),
+		Status:             domain.CustomerStatusActive,
+		Version:            1,
+	}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN030").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	result, err := service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	require.NotNil(t, result.Installment)
+	assert.Equal(t, int64(1000000), result.Installment.Expected)
+	assert.Equal(t, domain.InstallmentMet, result.Installment.Standing)
+	assert.Equal(t, int64(2000000), result.Installment.Arrears)
+}
+
+func TestProcessPayment_DryRunPersistsNothing(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00040"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 1000000, Status: domain.CustomerStatusActive, Version: 4}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN040").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+	req := completePaymentRequest(customerID, "TXN040")
+	req.DryRun = true
+	result, err := service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomePreview, result.Outcome)
+	assert.True(t, result.DryRun)
+	assert.True(t, result.Success)
+	assert.Equal(t, int64(0), result.OutstandingBalance)
+	assert.Equal(t, int64(1000000), result.TotalPaid)
+	assert.True(t, result.IsFullyPaid)
+
+	// The fetched customer is left untouched
+	assert.Equal(t, int64(1000000), customer.OutstandingBalance)
+	assert.Equal(t, domain.CustomerStatusActive, customer.Status)
+	assert.Equal(t, int64(4), customer.Version)
+
+	mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, service.WaitForPublishes(waitCtx))
+	publisher.mu.Lock()
+	assert.Empty(t, publisher.events)
+	publisher.mu.Unlock()
+}
+
+func TestProcessPayment_DryRunReportsDuplicate(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00041"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 5000000, Status: domain.CustomerStatusActive, Version: 2}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN041").Return(true, nil)
+	mockPaymentRepo.On("FindByTransactionReference", ctx, customerID, "TXN041").Return(nil, domain.ErrPaymentNotFound)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+	req := completePaymentRequest(customerID, "TXN041")
+	req.DryRun = true
+	result, err := service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, result.Outcome, "a dry run of a duplicate is still a duplicate")
+	assert.True(t, result.DryRun)
+	assert.Contains(t, result.Message, "duplicate")
+	assert.Equal(t, int64(5000000), result.OutstandingBalance)
+	assert.Empty(t, result.PaymentID, "the stored payment couldn't be read")
+	mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func newSlowPaymentMocks(ctx context.Context, customerID, txRef string, fetchDelay time.Duration) (*MockCustomerRepository, *MockPaymentRepository) {
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, txRef).Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).After(fetchDelay).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+	return mockCustomerRepo, mockPaymentRepo
+}
+
+func TestProcessPayment_LogsSlowPayment(t *testing.T) {
+	ctx := context.Background()
+	core, logs := observer.New(zap.WarnLevel)
+	mockCustomerRepo, mockPaymentRepo := newSlowPaymentMocks(ctx, "GIG00050", "TXN050", 40*time.Millisecond)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.New(core),
+		WithSlowPaymentThreshold(20*time.Millisecond),
+	)
+	before := paymentLatency.Count()
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00050", "TXN050"))
+	require.NoError(t, err)
+
+	assert.Equal(t, before+1, paymentLatency.Count())
+
+	slow := logs.FilterMessage("slow payment processing").All()
+	require.Len(t, slow, 1)
+	fields := slow[0].ContextMap()
+	assert.Equal(t, "GIG00050", fields["customer_id"])
+	assert.Equal(t, false, fields["retried"])
+	assert.GreaterOrEqual(t, fields["customer_fetch"], 40*time.Millisecond)
+	assert.GreaterOrEqual(t, fields["total"], fields["customer_fetch"])
+	assert.Contains(t, fields, "customer_save")
+	assert.Contains(t, fields, "payment_save")
+}
+
+func TestProcessPayment_NoSlowLogUnderThreshold(t *testing.T) {
+	ctx := context.Background()
+	core, logs := observer.New(zap.WarnLevel)
+	mockCustomerRepo, mockPaymentRepo := newSlowPaymentMocks(ctx, "GIG00051", "TXN051", 0)
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.New(core),
+		WithSlowPaymentThreshold(time.Second),
+	)
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00051", "TXN051"))
+	require.NoError(t, err)
+
+	assert.Zero(t, logs.FilterMessage("slow payment processing").Len())
+}
+
+// slowPublisher holds every publish for delay, like a broker under load
+type slowPublisher struct {
+	recordingPublisher
+	delay time.Duration
+}
+
+func (p *slowPublisher) Publish(ctx context.Context, event domain.DomainEvent) error {
+	time.Sleep(p.delay)
+	return p.recordingPublisher.Publish(ctx, event)
+}
+
+func TestWaitForPublishes_PaymentJustBeforeShutdownIsPublished(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00050"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &slowPublisher{delay: 50 * time.Millisecond}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN050").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN050"))
+	require.NoError(t, err)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, service.WaitForPublishes(shutdownCtx))
+
+	assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentReceived), 1)
+	assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentProcessed), 1)
+}
+
+func TestWaitForPublishes_GivesUpAtDeadline(t *testing.T) {
+	ctx := context.Background()
+	publisher := &slowPublisher{delay: 200 * time.Millisecond}
+
+	service := NewPaymentService(new(MockCustomerRepository), new(MockPaymentRepository), publisher, zap.NewNop())
+
+	req := completePaymentRequest("GIG00051", "TXN051")
+	req.PaymentStatus = "PENDING"
+	_, err := service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, service.WaitForPublishes(shutdownCtx), context.DeadlineExceeded)
+
+	// Nothing pending returns immediately
+	require.NoError(t, service.WaitForPublishes(ctx))
+}
+
+// recordingInvalidator notes invalidated customers, and what had been
+// saved at the time, via saved
+type recordingInvalidator struct {
+	mu          sync.Mutex
+	invalidated []string
+	saved       func() bool
+	savedFirst  []bool
+}
+
+func (i *recordingInvalidator) InvalidateCustomer(ctx context.Context, customerID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.invalidated = append(i.invalidated, customerID)
+	i.savedFirst = append(i.savedFirst, i.saved())
+	return nil
+}
+
+func TestProcessPayment_InvalidatesCustomerViewsAfterWrite(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00060"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	var paymentSaved bool
+	invalidator := &recordingInvalidator{saved: func() bool { return paymentSaved }}
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, nil, zap.NewNop(),
+		WithCustomerViewInvalidator(invalidator))
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN060").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Run(func(mock.Arguments) { paymentSaved = true }).Return(nil)
+
+	_, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN060"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{customerID}, invalidator.invalidated)
+	assert.Equal(t, []bool{true}, invalidator.savedFirst, "invalidated only once the payment row exists")
+
+	// Nothing was written for a non-complete payment, so nothing is invalidated
+	req := completePaymentRequest(customerID, "TXN061")
+	req.PaymentStatus = "PENDING"
+	_, err = service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, invalidator.invalidated, 1)
+}
+
+func TestProcessPayment_StampsTimesFromClock(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00012"
+	frozen := time.Date(2025, 11, 25, 8, 30, 0, 0, time.UTC)
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(),
+		WithClock(domain.ClockFunc(func() time.Time { return frozen })),
+	)
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	var saved *domain.Payment
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN012").Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*domain.Payment)
+	}).Return(nil)
+
+	req := completePaymentRequest(customerID, "TXN012")
+	_, err := service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, service.WaitForPublishes(ctx))
+
+	require.NotNil(t, saved)
+	assert.Equal(t, frozen, saved.CreatedAt)
+	assert.Equal(t, frozen, saved.ProcessedAt)
+	assert.Equal(t, req.TransactionDate, saved.TransactionDate, "the provider's date is kept as sent")
+
+	received := publisher.eventsOfType(domain.EventTypePaymentReceived)
+	require.Len(t, received, 1)
+	assert.Equal(t, frozen, received[0].GetOccurredAt())
+	assert.Equal(t, frozen, received[0].(*domain.PaymentReceivedEvent).Payload.ReceivedAt)
+
+	processed := publisher.eventsOfType(domain.EventTypePaymentProcessed)
+	require.Len(t, processed, 1)
+	assert.Equal(t, frozen, processed[0].GetOccurredAt())
+	assert.Equal(t, frozen, processed[0].(*domain.PaymentProcessedEvent).Payload.ProcessedAt)
+}
+
+func TestProcessPayment_SyncPublishing(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00013"
+	errBroker := errors.New("broker unavailable")
+
+	newService := func(publisher *recordingPublisher, opts ...PaymentServiceOption) (*PaymentService, *MockCustomerRepository) {
+		mockCustomerRepo := new(MockCustomerRepository)
+		mockPaymentRepo := new(MockPaymentRepository)
+		customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+		mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, "TXN013").Return(false, nil)
+		mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+		mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+		mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+		return NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(), opts...), mockCustomerRepo
+	}
+
+	t.Run("events are published before returning", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		service, _ := newService(publisher, WithSyncPublishing(true))
+
+		_, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN013"))
+
+		require.NoError(t, err)
+		assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentReceived), 1)
+		assert.Len(t, publisher.eventsOfType(domain.EventTypePaymentProcessed), 1)
+	})
+
+	t.Run("failed received event stops the payment", func(t *testing.T) {
+		publisher := &recordingPublisher{err: errBroker, failOn: domain.EventTypePaymentReceived}
+		service, mockCustomerRepo := newService(publisher, WithSyncPublishing(true))
+
+		result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN013"))
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, errBroker)
+		mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed processed event fails the request", func(t *testing.T) {
+		publisher := &recordingPublisher{err: errBroker, failOn: domain.EventTypePaymentProcessed}
+		service, mockCustomerRepo := newService(publisher, WithSyncPublishing(true))
+
+		result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN013"))
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, errBroker)
+		mockCustomerRepo.AssertNumberOfCalls(t, "Save", 1)
+	})
+
+	t.Run("async publishing never fails the request", func(t *testing.T) {
+		publisher := &recordingPublisher{err: errBroker}
+		service, _ := newService(publisher)
+
+		result, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, "TXN013"))
+
+		require.NoError(t, err)
+		assert.True(t, result.Processed)
+		require.NoError(t, service.WaitForPublishes(ctx))
+		assert.Empty(t, publisher.eventsOfType(domain.EventTypePaymentProcessed))
+	})
+}
+
+func TestProcessPayment_RecordsBalanceAfter(t *testing.T) {
+	ctx := context.Background()
+	customers := testutil.NewMemoryCustomerRepository(&domain.Customer{
+		ID: "GIG00001", AssetValue: 3000000, RepaymentTermWeeks: 3,
+		OutstandingBalance: 3000000, Status: domain.CustomerStatusActive, Version: 1,
+	})
+	payments := testutil.NewMemoryPaymentRepository()
+	service := NewPaymentService(customers, payments, nil, zap.NewNop())
+
+	refs := []string{"TXN001", "TXN002", "TXN003"}
+	for _, ref := range refs {
+		_, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00001", ref))
+		require.NoError(t, err)
+	}
+
+	// Each row's balance is the asset value less everything paid up to it
+	var paid int64
+	for _, ref := range refs {
+		payment, err := payments.FindByTransactionReference(ctx, "GIG00001", ref)
+		require.NoError(t, err)
+		paid += payment.Amount
+		require.NotNil(t, payment.BalanceAfter, ref)
+		assert.Equal(t, 3000000-paid, *payment.BalanceAfter, ref)
+	}
+}
+
+func TestProcessPayment_ReturnsTheRecordedPayment(t *testing.T) {
+	ctx := context.Background()
+	frozen := time.Date(2025, 11, 24, 9, 30, 0, 0, time.UTC)
+	customers := testutil.NewMemoryCustomerRepository(&domain.Customer{
+		ID: "GIG00001", AssetValue: 3000000, OutstandingBalance: 3000000, Status: domain.CustomerStatusActive, Version: 1,
+	})
+	payments := testutil.NewMemoryPaymentRepository()
+	service := NewPaymentService(customers, payments, nil, zap.NewNop(),
+		WithClock(domain.ClockFunc(func() time.Time { return frozen })),
+	)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00001", "TXN001"))
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeProcessed, result.Outcome)
+	stored, err := payments.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	require.NotEmpty(t, stored.ID)
+	assert.Equal(t, stored.ID, result.PaymentID)
+	assert.Equal(t, frozen, result.ProcessedAt)
+
+	// A retry names the payment the first request recorded
+	result, err = service.ProcessPayment(ctx, completePaymentRequest("GIG00001", "TXN001"))
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, result.Outcome)
+	assert.Equal(t, stored.ID, result.PaymentID)
+
+	// Not COMPLETE, so nothing was recorded
+	req := completePaymentRequest("GIG00001", "TXN002")
+	req.PaymentStatus = "FAILED"
+	result, err = service.ProcessPayment(ctx, req)
+
+	require.NoError(t, err)
+	assert.Empty(t, result.PaymentID)
+	assert.True(t, result.ProcessedAt.IsZero())
+}
+
+func TestProcessPayment_SequenceFollowsCustomerVersion(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00014"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(), WithSyncPublishing(true))
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, customerID, mock.Anything).Return(false, nil)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	// Saving bumps the version, as the repositories do
+	mockCustomerRepo.On("Save", ctx, customer).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.Customer).Version++
+	}).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	for _, ref := range []string{"TXN014", "TXN015"} {
+		_, err := service.ProcessPayment(ctx, completePaymentRequest(customerID, ref))
+		require.NoError(t, err)
+	}
+
+	processed := publisher.eventsOfType(domain.EventTypePaymentProcessed)
+	require.Len(t, processed, 2)
+	assert.Equal(t, int64(2), processed[0].GetSequence())
+	assert.Equal(t, int64(3), processed[1].GetSequence())
+	assert.Equal(t, customer.Version, processed[1].GetSequence())
+
+	// Nothing changed the customer when the payment arrived
+	for _, received := range publisher.eventsOfType(domain.EventTypePaymentReceived) {
+		assert.Zero(t, received.GetSequence())
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// PayoffProjectionResponse is a customer with their projected payoff as of At
+type PayoffProjectionResponse struct {
+	Customer   *domain.Customer
+	Projection domain.PayoffProjection
+	At         time.Time
+}
+
+// ProjectPayoff estimates when a customer will finish paying, on schedule
+// and at the pace of their payments over the last few weeks
+func (s *PaymentService) ProjectPayoff(ctx context.Context, customerID string) (*PayoffProjectionResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
+
+	customer, err := s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	now := s.clock.Now()
+	if customer.OutstandingBalance <= 0 {
+		return &PayoffProjectionResponse{Customer: customer, Projection: customer.ProjectPayoff(now, 0), At: now}, nil
+	}
+
+	from, _ := customer.PaceWindow(now)
+	recent, err := s.paymentRepo.TotalsByDateRange(ctx, from, now, customerID, domain.PaymentFilter{})
+	if err != nil {
+		logFailure(s.logger, "failed to total recent payments", err,
+			zap.String("customer_id", customerID),
+		)
+		return nil, fmt.Errorf("failed to total recent payments: %w", err)
+	}
+
+	return &PayoffProjectionResponse{
+		Customer:   customer,
+		Projection: customer.ProjectPayoff(now, recent.Amount),
+		At:         now,
+	}, nil
+}
+
+// WithEarlyPayoffDiscount takes basisPoints (250 is 2.5%) off the balance
+// in payoff quotes. Quoting doesn't change what a payment settles; the
+// discount still has to be granted on the loan.
+func WithEarlyPayoffDiscount(basisPoints int64) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.payoffDiscountBPS = basisPoints
+	}
+}
+
+// PayoffQuoteResponse is a customer with what it takes to settle their loan
+// as of At, and the discount rate the quote used
+type PayoffQuoteResponse struct {
+	Customer            *domain.Customer
+	Quote               domain.PayoffQuote
+	DiscountBasisPoints int64
+	At                  time.Time
+}
+
+// QuotePayoff quotes the amount that settles a customer's loan in one
+// payment. It reads and changes nothing else.
+func (s *PaymentService) QuotePayoff(ctx context.Context, customerID string) (*PayoffQuoteResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
+
+	customer, err := s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	return &PayoffQuoteResponse{
+		Customer:            customer,
+		Quote:               customer.QuotePayoff(s.payoffDiscountBPS),
+		DiscountBasisPoints: s.payoffDiscountBPS,
+		At:                  s.clock.Now(),
+	}, nil
+}
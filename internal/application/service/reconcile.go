@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// reconcileReason is recorded on the customer.updated event for repairs
+const reconcileReason = "status reconciled from balance"
+
+type StatusChange struct {
+	CustomerID string
+	From       domain.CustomerStatus
+	To         domain.CustomerStatus
+}
+
+type ReconcileReport struct {
+	Checked  int
+	Changed  []StatusChange
+	NotFound []string
+	// Failed lists customers whose repaired status could not be saved,
+	// typically because they were updated concurrently; rerun to retry
+	Failed []string
+}
+
+// ReconcileCustomerStatuses recomputes each customer's status from their
+// balance and, when a default threshold is set, how far behind schedule
+// they are, then saves those that were wrong. It is idempotent: a second
+// run over the same customers changes nothing.
+func (s *PaymentService) ReconcileCustomerStatuses(ctx context.Context, customerIDs []string) (*ReconcileReport, error) {
+	customers, notFound, err := s.GetCustomers(ctx, customerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customers: %w", err)
+	}
+
+	correlationID := domain.CorrelationIDFromContext(ctx)
+	now := s.clock.Now()
+	report := &ReconcileReport{
+		Checked:  len(customers),
+		Changed:  []StatusChange{},
+		NotFound: notFound,
+		Failed:   []string{},
+	}
+
+	for _, id := range customerIDs {
+		customer, ok := customers[NormalizeCustomerID(id)]
+		if !ok {
+			continue
+		}
+		// Duplicate IDs map to the same customer; handle it once
+		delete(customers, customer.ID)
+
+		previousStatus := customer.Status
+		changed := customer.ReconcileStatus()
+		if customer.UpdateDefaultStatus(now, s.defaultThreshold) {
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		events, err := s.commit(ctx, func(repos domain.TxRepositories) error {
+			return repos.Customers.Save(ctx, customer)
+		}, func() []domain.DomainEvent {
+			return []domain.DomainEvent{s.customerStatusReconciledEvent(correlationID, customer, previousStatus)}
+		})
+		if err != nil {
+			s.logger.Warn("failed to save reconciled customer status",
+				zap.Error(err),
+				zap.String("customer_id", customer.ID),
+			)
+			report.Failed = append(report.Failed, customer.ID)
+			continue
+		}
+		s.invalidateCustomerViews(ctx, customer.ID)
+
+		s.logger.Info("customer status reconciled",
+			zap.String("customer_id", customer.ID),
+			zap.String("previous_status", string(previousStatus)),
+			zap.String("status", string(customer.Status)),
+			zap.Int64("outstanding_balance", customer.OutstandingBalance),
+		)
+		report.Changed = append(report.Changed, StatusChange{
+			CustomerID: customer.ID,
+			From:       previousStatus,
+			To:         customer.Status,
+		})
+
+		if err := s.publishCommitted(ctx, events); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+func (s *PaymentService) customerStatusReconciledEvent(correlationID string, customer *domain.Customer, previousStatus domain.CustomerStatus) *domain.CustomerUpdatedEvent {
+	now := s.clock.Now()
+	event := domain.NewCustomerUpdatedEvent(customer.ID, domain.CustomerUpdatedPayload{
+		CustomerID:         customer.ID,
+		PreviousStatus:     string(previousStatus),
+		Status:             string(customer.Status),
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		Reason:             reconcileReason,
+		UpdatedAt:          now,
+	}, now)
+	event.CorrelationID = correlationID
+	event.Sequence = customer.Version
+	return event
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReconcileCustomerStatuses(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), publisher, zap.NewNop())
+
+	stuckActive := &domain.Customer{ID: "GIG00030", OutstandingBalance: 0, TotalPaid: 100000000, Status: domain.CustomerStatusActive}
+	stuckCompleted := &domain.Customer{ID: "GIG00031", OutstandingBalance: 500, Status: domain.CustomerStatusCompleted}
+	consistent := &domain.Customer{ID: "GIG00032", OutstandingBalance: 500, Status: domain.CustomerStatusActive}
+	writtenOff := &domain.Customer{ID: "GIG00033", OutstandingBalance: 0, Status: domain.CustomerStatusWrittenOff}
+	conflicted := &domain.Customer{ID: "GIG00034", OutstandingBalance: 0, Status: domain.CustomerStatusActive}
+
+	ids := []string{"GIG00030", "GIG00031", "GIG00032", "GIG00033", "GIG00034", "GIG00099"}
+	mockCustomerRepo.On("FindByIDs", ctx, ids).Return(map[string]*domain.Customer{
+		"GIG00030": stuckActive,
+		"GIG00031": stuckCompleted,
+		"GIG00032": consistent,
+		"GIG00033": writtenOff,
+		"GIG00034": conflicted,
+	}, nil)
+	mockCustomerRepo.On("Save", ctx, stuckActive).Return(nil)
+	mockCustomerRepo.On("Save", ctx, stuckCompleted).Return(nil)
+	mockCustomerRepo.On("Save", ctx, conflicted).Return(domain.ErrOptimisticLock)
+
+	report, err := service.ReconcileCustomerStatuses(ctx, append(ids, " gig00030"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, report.Checked)
+	assert.Equal(t, []StatusChange{
+		{CustomerID: "GIG00030", From: domain.CustomerStatusActive, To: domain.CustomerStatusCompleted},
+		{CustomerID: "GIG00031", From: domain.CustomerStatusCompleted, To: domain.CustomerStatusActive},
+	}, report.Changed)
+	assert.Equal(t, []string{"GIG00099"}, report.NotFound)
+	assert.Equal(t, []string{"GIG00034"}, report.Failed)
+
+	mockCustomerRepo.AssertNumberOfCalls(t, "Save", 3)
+	mockCustomerRepo.AssertNotCalled(t, "Save", ctx, mock.MatchedBy(func(c *domain.Customer) bool {
+		return c.ID == "GIG00032" || c.ID == "GIG00033"
+	}))
+
+	assert.Eventually(t, func() bool {
+		return len(publisher.eventsOfType(domain.EventTypeCustomerUpdated)) == 2
+	}, time.Second, 5*time.Millisecond)
+	updated := publisher.eventsOfType(domain.EventTypeCustomerUpdated)[0].(*domain.CustomerUpdatedEvent)
+	assert.Equal(t, reconcileReason, updated.Payload.Reason)
+}
+
+func TestReconcileCustomerStatuses_MarksDefaulters(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), nil, zap.NewNop(),
+		WithDefaultThreshold(2))
+
+	deployed := time.Now().Add(-3*7*24*time.Hour - time.Hour)
+	behind := &domain.Customer{ID: "GIG00040", AssetValue: 1000, RepaymentTermWeeks: 10, OutstandingBalance: 1000,
+		DeploymentDate: deployed, Status: domain.CustomerStatusActive}
+	onTrack := &domain.Customer{ID: "GIG00041", AssetValue: 1000, RepaymentTermWeeks: 10, OutstandingBalance: 700, TotalPaid: 300,
+		DeploymentDate: deployed, Status: domain.CustomerStatusActive}
+
+	ids := []string{"GIG00040", "GIG00041"}
+	mockCustomerRepo.On("FindByIDs", ctx, ids).Return(map[string]*domain.Customer{
+		"GIG00040": behind,
+		"GIG00041": onTrack,
+	}, nil)
+	mockCustomerRepo.On("Save", ctx, behind).Return(nil)
+
+	report, err := service.ReconcileCustomerStatuses(ctx, ids)
+	require.NoError(t, err)
+
+	assert.Equal(t, []StatusChange{
+		{CustomerID: "GIG00040", From: domain.CustomerStatusActive, To: domain.CustomerStatusDefaulted},
+	}, report.Changed)
+	mockCustomerRepo.AssertNumberOfCalls(t, "Save", 1)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrRestructureReasonRequired is returned when a restructure has no reason
+	ErrRestructureReasonRequired = errors.New("restructure reason is required")
+	// ErrNoRestructureTerms is returned when a restructure changes nothing
+	ErrNoRestructureTerms = errors.New("restructure needs a new asset value or term")
+)
+
+// RestructureRequest sets a loan's new terms; a nil term is kept as it is
+type RestructureRequest struct {
+	AssetValue *int64
+	TermWeeks  *int
+	Reason     string
+}
+
+type RestructureResponse struct {
+	Customer           *domain.Customer
+	PreviousAssetValue int64
+	PreviousTermWeeks  int
+}
+
+// RestructureLoan changes a customer's asset value and/or repayment term,
+// recomputing the balance from what they have already paid and
+// re-deriving their status, including whether they are still in default
+// under the new schedule.
+func (s *PaymentService) RestructureLoan(ctx context.Context, customerID string, req RestructureRequest) (*RestructureResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
+
+	if req.Reason == "" {
+		return nil, ErrRestructureReasonRequired
+	}
+	if req.AssetValue == nil && req.TermWeeks == nil {
+		return nil, ErrNoRestructureTerms
+	}
+
+	correlationID := domain.CorrelationIDFromContext(ctx)
+
+	customer, err := s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	response := &RestructureResponse{
+		Customer:           customer,
+		PreviousAssetValue: customer.AssetValue,
+		PreviousTermWeeks:  customer.RepaymentTermWeeks,
+	}
+	assetValue, termWeeks := customer.AssetValue, customer.RepaymentTermWeeks
+	if req.AssetValue != nil {
+		assetValue = *req.AssetValue
+	}
+	if req.TermWeeks != nil {
+		termWeeks = *req.TermWeeks
+	}
+
+	previousStatus := customer.Status
+	if err := customer.Restructure(assetValue, termWeeks); err != nil {
+		return nil, err
+	}
+	customer.UpdateDefaultStatus(s.clock.Now(), s.defaultThreshold)
+
+	events, err := s.commit(ctx, func(repos domain.TxRepositories) error {
+		if err := repos.Customers.Save(ctx, customer); err != nil {
+			logFailure(s.logger, "failed to save restructured customer", err,
+				zap.String("customer_id", customerID),
+			)
+			return fmt.Errorf("failed to save customer: %w", err)
+		}
+		return nil
+	}, func() []domain.DomainEvent {
+		return []domain.DomainEvent{s.customerRestructuredEvent(correlationID, customer, previousStatus, req.Reason)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer s.invalidateCustomerViews(ctx, customerID)
+
+	s.logger.Info("customer loan restructured",
+		zap.String("customer_id", customerID),
+		zap.Int64("previous_asset_value", response.PreviousAssetValue),
+		zap.Int64("asset_value", customer.AssetValue),
+		zap.Int("previous_term_weeks", response.PreviousTermWeeks),
+		zap.Int("term_weeks", customer.RepaymentTermWeeks),
+		zap.String("previous_status", string(previousStatus)),
+		zap.String("status", string(customer.Status)),
+		zap.Int64("outstanding_balance", customer.OutstandingBalance),
+		zap.String("reason", req.Reason),
+	)
+
+	if err := s.publishCommitted(ctx, events); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (s *PaymentService) customerRestructuredEvent(correlationID string, customer *domain.Customer, previousStatus domain.CustomerStatus, reason string) *domain.CustomerUpdatedEvent {
+	now := s.clock.Now()
+	event := domain.NewCustomerUpdatedEvent(customer.ID, domain.CustomerUpdatedPayload{
+		CustomerID:         customer.ID,
+		PreviousStatus:     string(previousStatus),
+		Status:             string(customer.Status),
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		Reason:             reason,
+		UpdatedAt:          now,
+		AssetValue:         customer.AssetValue,
+		RepaymentTermWeeks: customer.RepaymentTermWeeks,
+	}, now)
+	event.CorrelationID = correlationID
+	event.Sequence = customer.Version
+	return event
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func intPtr(v int) *int { return &v }
+
+func TestRestructureLoan_ReducesAssetValue(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00030"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, RepaymentTermWeeks: 52, OutstandingBalance: 60000000, TotalPaid: 40000000, Status: domain.CustomerStatusActive, Version: 4}
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+
+	result, err := service.RestructureLoan(ctx, customerID, RestructureRequest{
+		AssetValue: int64Ptr(90000000),
+		Reason:     "negotiated discount",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000000), result.PreviousAssetValue)
+	assert.Equal(t, 52, result.PreviousTermWeeks)
+	assert.Equal(t, int64(90000000), result.Customer.AssetValue)
+	assert.Equal(t, 52, result.Customer.RepaymentTermWeeks)
+	assert.Equal(t, int64(50000000), result.Customer.OutstandingBalance)
+	assert.Equal(t, int64(40000000), result.Customer.TotalPaid)
+	mockCustomerRepo.AssertExpectations(t)
+
+	require.NoError(t, service.WaitForPublishes(ctx))
+	updated := publisher.eventsOfType(domain.EventTypeCustomerUpdated)
+	require.Len(t, updated, 1)
+	payload := updated[0].GetPayload().(domain.CustomerUpdatedPayload)
+	assert.Equal(t, "negotiated discount", payload.Reason)
+	assert.Equal(t, int64(90000000), payload.AssetValue)
+	assert.Equal(t, 52, payload.RepaymentTermWeeks)
+	assert.Equal(t, int64(50000000), payload.OutstandingBalance)
+}
+
+func TestRestructureLoan_TermExtensionCuresDefault(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00031"
+	deployed := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	now := deployed.AddDate(0, 0, 7*20)
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), publisher, zap.NewNop(),
+		WithDefaultThreshold(4),
+		WithClock(domain.ClockFunc(func() time.Time { return now })),
+	)
+
+	// 20 weeks in, paid for 14 of 52: six installments behind
+	customer := &domain.Customer{ID: customerID, AssetValue: 52000000, RepaymentTermWeeks: 52, OutstandingBalance: 38000000, TotalPaid: 14000000,
+		DeploymentDate: deployed, Status: domain.CustomerStatusDefaulted, Version: 2}
+	require.GreaterOrEqual(t, customer.MissedInstallments(now), 4)
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+
+	result, err := service.RestructureLoan(ctx, customerID, RestructureRequest{
+		TermWeeks: intPtr(104),
+		Reason:    "hardship extension",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 104, result.Customer.RepaymentTermWeeks)
+	assert.Equal(t, int64(52000000), result.Customer.AssetValue)
+	assert.Equal(t, int64(38000000), result.Customer.OutstandingBalance)
+	assert.Equal(t, domain.CustomerStatusActive, result.Customer.Status, "the longer schedule puts them back under the threshold")
+
+	require.NoError(t, service.WaitForPublishes(ctx))
+	updated := publisher.eventsOfType(domain.EventTypeCustomerUpdated)
+	require.Len(t, updated, 1)
+	payload := updated[0].GetPayload().(domain.CustomerUpdatedPayload)
+	assert.Equal(t, string(domain.CustomerStatusDefaulted), payload.PreviousStatus)
+	assert.Equal(t, string(domain.CustomerStatusActive), payload.Status)
+}
+
+func TestRestructureLoan_BelowPaidIsRejected(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00032"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, RepaymentTermWeeks: 52, OutstandingBalance: 60000000, TotalPaid: 40000000, Status: domain.CustomerStatusActive, Version: 4}
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+	_, err := service.RestructureLoan(ctx, customerID, RestructureRequest{
+		AssetValue: int64Ptr(39999999),
+		Reason:     "negotiated discount",
+	})
+
+	assert.ErrorIs(t, err, domain.ErrAssetValueBelowPaid)
+	mockCustomerRepo.AssertNotCalled(t, "Save")
+	require.NoError(t, service.WaitForPublishes(ctx))
+	assert.Empty(t, publisher.eventsOfType(domain.EventTypeCustomerUpdated))
+}
+
+func TestRestructureLoan_RequiresReasonAndTerms(t *testing.T) {
+	service := NewPaymentService(new(MockCustomerRepository), new(MockPaymentRepository), nil, zap.NewNop())
+
+	_, err := service.RestructureLoan(context.Background(), "GIG00033", RestructureRequest{AssetValue: int64Ptr(1000)})
+	assert.ErrorIs(t, err, ErrRestructureReasonRequired)
+
+	_, err = service.RestructureLoan(context.Background(), "GIG00033", RestructureRequest{Reason: "discount"})
+	assert.ErrorIs(t, err, ErrNoRestructureTerms)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// acceptSettledPayment answers a payment for a customer who has already
+// paid off the asset: a late payment or a provider retry under a new
+// reference, not a server error. The payment is recorded, so a retry with
+// the same reference is a duplicate, and all of it is settled as an
+// overpayment under the configured policy. The customer is left untouched.
+func (s *PaymentService) acceptSettledPayment(ctx context.Context, correlationID string, customer *domain.Customer, req ProcessPaymentRequest) (*ProcessPaymentResponse, error) {
+	response := &ProcessPaymentResponse{
+		Outcome:            OutcomeAlreadyPaid,
+		Success:            true,
+		Reason:             ReasonAlreadyFullyPaid,
+		Message:            "customer has already fully paid - payment recorded as an overpayment",
+		CustomerID:         customer.ID,
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		PaymentProgress:    customer.GetPaymentProgress(),
+		IsFullyPaid:        customer.IsFullyPaid(),
+		DryRun:             req.DryRun,
+	}
+	if req.DryRun {
+		response.Message = "preview only - customer has already fully paid"
+		return response, nil
+	}
+
+	payment, err := domain.NewPayment(
+		req.CustomerID,
+		req.TransactionAmount,
+		req.TransactionReference,
+		req.TransactionDate,
+		domain.PaymentStatusComplete,
+		s.clock.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment data: %w", err)
+	}
+	payment.CurrencyCode = customer.Currency().Code
+	payment.RecordBalanceAfter(customer.OutstandingBalance)
+	payment.Metadata = req.Metadata
+
+	if err := s.paymentRepo.Save(ctx, payment); err != nil {
+		if err == domain.ErrDuplicateTransaction {
+			// A concurrent request recorded it first; nothing to undo
+			s.recordOutcome(ctx, domain.PaymentStatusDuplicate, req)
+			response.Outcome = OutcomeDuplicate
+			response.Processed = true
+			response.Reason = ""
+			response.Message = "duplicate transaction - already processed"
+			return response.withPayment(s.recordedPayment(ctx, req.CustomerID, req.TransactionReference)), nil
+		}
+		logFailure(s.logger, "failed to save payment", err,
+			zap.String("customer_id", req.CustomerID),
+		)
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+	defer s.invalidateCustomerViews(ctx, customer.ID)
+	// Recorded but not applied, so there is no ProcessedAt
+	response.PaymentID = payment.ID
+
+	s.logger.Info("payment received for fully paid customer",
+		zap.String("customer_id", req.CustomerID),
+		zap.Int64("amount", req.TransactionAmount),
+		zap.String("tx_ref", req.TransactionReference),
+	)
+
+	if err := s.settleOverpayment(ctx, correlationID, customer, req, req.TransactionAmount); err != nil {
+		return nil, fmt.Errorf("failed to publish payment overpaid event: %w", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newPaidOffCustomer() *domain.Customer {
+	return &domain.Customer{
+		ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50,
+		TotalPaid: 100000000, Status: domain.CustomerStatusCompleted, Version: 7,
+	}
+}
+
+func TestProcessPayment_FullyPaidCustomerIsRecordedNotApplied(t *testing.T) {
+	ctx := context.Background()
+	customers := testutil.NewMemoryCustomerRepository(newPaidOffCustomer())
+	payments := testutil.NewMemoryPaymentRepository()
+	publisher := &recordingPublisher{}
+	service := NewPaymentService(customers, payments, publisher, zap.NewNop(), WithSyncPublishing(true))
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00001", "TXN001"))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeAlreadyPaid, result.Outcome)
+	assert.True(t, result.Success)
+	assert.False(t, result.Processed)
+	assert.Equal(t, ReasonAlreadyFullyPaid, result.Reason)
+	assert.True(t, result.IsFullyPaid)
+	assert.Equal(t, int64(100000000), result.TotalPaid)
+
+	customer, err := customers.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000000), customer.TotalPaid, "the customer is left untouched")
+	assert.Equal(t, int64(7), customer.Version)
+
+	payment, err := payments.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	require.NotNil(t, payment.BalanceAfter)
+	assert.Zero(t, *payment.BalanceAfter)
+
+	overpaid := publisher.eventsOfType(domain.EventTypePaymentOverpaid)
+	require.Len(t, overpaid, 1)
+	payload := overpaid[0].(*domain.PaymentOverpaidEvent).Payload
+	assert.Equal(t, int64(1000000), payload.Excess, "all of it is excess")
+	assert.Equal(t, domain.OverpaymentIgnored, payload.Destination)
+
+	result, err = service.ProcessPayment(ctx, completePaymentRequest("GIG00001", "TXN001"))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, result.Outcome, "a provider retry is a duplicate")
+}
+
+func TestProcessPayment_FullyPaidCustomerIsCreditedUnderCreditPolicy(t *testing.T) {
+	ctx := context.Background()
+	ledger := newMemoryCreditLedger()
+	service := NewPaymentService(
+		testutil.NewMemoryCustomerRepository(newPaidOffCustomer()),
+		testutil.NewMemoryPaymentRepository(),
+		nil, zap.NewNop(),
+		WithOverpaymentPolicy(OverpaymentCredit, ledger, nil),
+	)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00001", "TXN001"))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeAlreadyPaid, result.Outcome)
+
+	require.Contains(t, ledger.credits, "TXN001")
+	assert.Equal(t, int64(1000000), ledger.credits["TXN001"].Amount)
+}
+
+func TestProcessPayment_FullyPaidCustomerDryRunRecordsNothing(t *testing.T) {
+	ctx := context.Background()
+	payments := testutil.NewMemoryPaymentRepository()
+	service := NewPaymentService(testutil.NewMemoryCustomerRepository(newPaidOffCustomer()), payments, nil, zap.NewNop())
+
+	req := completePaymentRequest("GIG00001", "TXN001")
+	req.DryRun = true
+	result, err := service.ProcessPayment(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeAlreadyPaid, result.Outcome)
+	assert.True(t, result.DryRun)
+
+	count, err := payments.CountByCustomerID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
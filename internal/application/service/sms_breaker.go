@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrSMSCircuitOpen is returned without calling the provider while the
+// breaker is open
+var ErrSMSCircuitOpen = errors.New("sms circuit breaker is open")
+
+// BreakerSettings control when the SMS breaker trips and recovers
+type BreakerSettings struct {
+	// FailureRatio of calls within Interval that opens the breaker, once
+	// at least MinRequests calls were made
+	FailureRatio float64
+	MinRequests  int
+	// Interval is how often the closed breaker forgets its counts
+	Interval time.Duration
+	// OpenTimeout is how long the breaker fast-fails before letting a
+	// single probe through
+	OpenTimeout time.Duration
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerSMSSender stops calling an SMS provider that keeps failing,
+// so an outage fails sends at once instead of stalling every handler. After
+// OpenTimeout one probe is let through; its result closes or reopens the
+// breaker.
+type CircuitBreakerSMSSender struct {
+	next     SMSSender
+	settings BreakerSettings
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu          sync.Mutex
+	state       breakerState
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probing     bool
+}
+
+func NewCircuitBreakerSMSSender(next SMSSender, settings BreakerSettings, logger *zap.Logger) *CircuitBreakerSMSSender {
+	return &CircuitBreakerSMSSender{
+		next:     next,
+		settings: settings,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (b *CircuitBreakerSMSSender) SendSMS(ctx context.Context, customerID, message string) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := b.next.SendSMS(ctx, customerID, message)
+	b.record(err == nil)
+	return err
+}
+
+// allow decides whether a call may go through, moving an expired open
+// breaker to half-open
+func (b *CircuitBreakerSMSSender) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.settings.OpenTimeout {
+			return ErrSMSCircuitOpen
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		// Only one probe at a time
+		if b.probing {
+			return ErrSMSCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		if b.settings.Interval > 0 && now.Sub(b.windowStart) >= b.settings.Interval {
+			b.requests, b.failures = 0, 0
+			b.windowStart = now
+		}
+		return nil
+	}
+}
+
+func (b *CircuitBreakerSMSSender) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if success {
+			b.setState(breakerClosed)
+		} else {
+			b.setState(breakerOpen)
+		}
+		return
+	}
+
+	b.requests++
+	if !success {
+		b.failures++
+	}
+	if b.requests >= b.settings.MinRequests &&
+		float64(b.failures)/float64(b.requests) >= b.settings.FailureRatio {
+		b.setState(breakerOpen)
+	}
+}
+
+// setState must be called with mu held
+func (b *CircuitBreakerSMSSender) setState(state breakerState) {
+	if b.state == state {
+		return
+	}
+	b.logger.Warn("sms circuit breaker state changed",
+		zap.Stringer("from", b.state),
+		zap.Stringer("to", state),
+		zap.Int("requests", b.requests),
+		zap.Int("failures", b.failures),
+	)
+
+	b.state = state
+	b.requests, b.failures = 0, 0
+	b.windowStart = b.now()
+	if state == breakerOpen {
+		b.openedAt = b.now()
+	}
+}
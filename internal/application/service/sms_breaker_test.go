@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// countingSMSSender counts calls and fails while err is set
+type countingSMSSender struct {
+	calls int
+	err   error
+}
+
+func (s *countingSMSSender) SendSMS(ctx context.Context, customerID, message string) error {
+	s.calls++
+	return s.err
+}
+
+func newTestBreaker(next SMSSender) (*CircuitBreakerSMSSender, *time.Time) {
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreakerSMSSender(next, BreakerSettings{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Interval:     time.Minute,
+		OpenTimeout:  30 * time.Second,
+	}, zap.NewNop())
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreakerSMSSender_OpensAndFastFails(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingSMSSender{err: errors.New("provider timeout")}
+	breaker, _ := newTestBreaker(provider)
+
+	// Below MinRequests the breaker stays closed however bad the ratio
+	for i := 0; i < 3; i++ {
+		assert.EqualError(t, breaker.SendSMS(ctx, "GIG00001", "hi"), "provider timeout")
+	}
+	assert.EqualError(t, breaker.SendSMS(ctx, "GIG00001", "hi"), "provider timeout")
+	require.Equal(t, 4, provider.calls)
+
+	for i := 0; i < 10; i++ {
+		assert.ErrorIs(t, breaker.SendSMS(ctx, "GIG00001", "hi"), ErrSMSCircuitOpen)
+	}
+	assert.Equal(t, 4, provider.calls, "an open breaker never reaches the provider")
+}
+
+func TestCircuitBreakerSMSSender_StaysClosedBelowFailureRatio(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingSMSSender{}
+	breaker, _ := newTestBreaker(provider)
+
+	for i := 0; i < 6; i++ {
+		provider.err = nil
+		if i%3 == 2 {
+			provider.err = errors.New("flaky")
+		}
+		breaker.SendSMS(ctx, "GIG00001", "hi")
+	}
+
+	provider.err = nil
+	assert.NoError(t, breaker.SendSMS(ctx, "GIG00001", "hi"))
+	assert.Equal(t, 7, provider.calls)
+}
+
+func TestCircuitBreakerSMSSender_HalfOpenProbe(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingSMSSender{err: errors.New("down")}
+	breaker, now := newTestBreaker(provider)
+
+	for i := 0; i < 4; i++ {
+		breaker.SendSMS(ctx, "GIG00001", "hi")
+	}
+	require.ErrorIs(t, breaker.SendSMS(ctx, "GIG00001", "hi"), ErrSMSCircuitOpen)
+
+	// A failed probe reopens the breaker for another OpenTimeout
+	*now = now.Add(31 * time.Second)
+	assert.EqualError(t, breaker.SendSMS(ctx, "GIG00001", "hi"), "down")
+	assert.ErrorIs(t, breaker.SendSMS(ctx, "GIG00001", "hi"), ErrSMSCircuitOpen)
+	assert.Equal(t, 5, provider.calls)
+
+	// A successful probe closes it
+	*now = now.Add(31 * time.Second)
+	provider.err = nil
+	assert.NoError(t, breaker.SendSMS(ctx, "GIG00001", "hi"))
+	assert.NoError(t, breaker.SendSMS(ctx, "GIG00001", "hi"))
+	assert.Equal(t, 7, provider.calls)
+}
+
+func TestHandlePaymentProcessed_OpenBreakerSkipsAndAcks(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingSMSSender{err: errors.New("down")}
+	breaker, _ := newTestBreaker(provider)
+	handled := &memoryHandledEvents{}
+	notifications := NewNotificationService(nil, zap.NewNop(), WithSMSSender(breaker), WithHandledEvents(handled))
+
+	// Failures before the breaker trips are retried as usual
+	for i := 0; i < 4; i++ {
+		require.Error(t, notifications.HandlePaymentProcessed(ctx, paymentProcessedEvent()))
+	}
+
+	skipped := notificationsSkipped.Value()
+	event := paymentProcessedEvent()
+	require.NoError(t, notifications.HandlePaymentProcessed(ctx, event), "an open breaker acks the event")
+	assert.Equal(t, skipped+1, notificationsSkipped.Value())
+	assert.Equal(t, 4, provider.calls)
+
+	claimed, err := handled.Claim(ctx, event.GetEventID())
+	require.NoError(t, err)
+	assert.False(t, claimed, "the skipped event is not redelivered into a send")
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// PaymentPageFunc receives one page of a streamed listing; returning an
+// error stops the stream and is passed back to the caller
+type PaymentPageFunc func(payments []*domain.Payment) error
+
+// StreamCustomerPayments hands every payment of a customer matching filter
+// to fn, oldest first unless filter says otherwise, reading pageSize rows at
+// a time so the full history is never held in memory. It walks the keyset
+// cursor, so concurrent inserts can't cause rows to be skipped or repeated.
+func (s *PaymentService) StreamCustomerPayments(ctx context.Context, customerID string, filter domain.PaymentFilter, pageSize int, fn PaymentPageFunc) error {
+	customerID = NormalizeCustomerID(customerID)
+
+	if _, err := s.customerRepo.FindByID(ctx, customerID); err != nil {
+		logFailure(s.logger, "failed to get customer", err,
+			zap.String("customer_id", customerID),
+		)
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	var after PaymentCursor
+	for {
+		payments, err := s.paymentRepo.FindByCustomerIDAfter(ctx, customerID, filter, after.TransactionDate, after.ID, pageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get payments: %w", err)
+		}
+		if len(payments) == 0 {
+			return nil
+		}
+		if err := fn(payments); err != nil {
+			return err
+		}
+		if len(payments) < pageSize {
+			return nil
+		}
+
+		last := payments[len(payments)-1]
+		after = PaymentCursor{TransactionDate: last.TransactionDate, ID: last.ID}
+	}
+}
+
+// StreamPaymentsByDateRange hands every payment in the range matching
+// q.Filter to fn, newest first unless the filter says otherwise, pageSize
+// rows at a time. Page and PageSize on q are ignored.
+func (s *PaymentService) StreamPaymentsByDateRange(ctx context.Context, q DateRangeQuery, pageSize int, fn PaymentPageFunc) error {
+	q, err := s.checkDateRange(q)
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; ; offset += pageSize {
+		payments, err := s.paymentRepo.FindByDateRange(ctx, q.From, q.To, q.CustomerID, q.Filter, pageSize, offset)
+		if err != nil {
+			return fmt.Errorf("failed to get payments: %w", err)
+		}
+		if len(payments) == 0 {
+			return nil
+		}
+		if err := fn(payments); err != nil {
+			return err
+		}
+		if len(payments) < pageSize {
+			return nil
+		}
+	}
+}
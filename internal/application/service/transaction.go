@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+// WithTransactor saves a customer and the payment that changed them in one
+// transaction, so a request cut off between the two writes never leaves a
+// balance applied with no payment row for dedup to find. Without it the
+// writes go through the service's repositories one at a time.
+func WithTransactor(transactor domain.Transactor) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.transactor = transactor
+	}
+}
+
+// inTx runs fn in a transaction when the service has a Transactor, and
+// against the service's own repositories otherwise
+func (s *PaymentService) inTx(ctx context.Context, fn func(repos domain.TxRepositories) error) error {
+	if s.transactor == nil {
+		return fn(domain.TxRepositories{
+			Customers: s.customerRepo,
+			Payments:  s.paymentRepo,
+			EventLog:  s.eventLog,
+		})
+	}
+	return s.transactor.InTx(ctx, fn)
+}
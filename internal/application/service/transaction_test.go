@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingTransactor hands fn repositories of its own and counts how its
+// transactions ended, standing in for a database transaction. Events
+// logged in a transaction reach eventLog only when it commits.
+type recordingTransactor struct {
+	customers  *MockCustomerRepository
+	payments   *MockPaymentRepository
+	eventLog   *memoryEventLog
+	committed  int
+	rolledBack int
+}
+
+func newRecordingTransactor() *recordingTransactor {
+	return &recordingTransactor{
+		customers: new(MockCustomerRepository),
+		payments:  new(MockPaymentRepository),
+		eventLog:  &memoryEventLog{},
+	}
+}
+
+func (t *recordingTransactor) InTx(ctx context.Context, fn func(repos domain.TxRepositories) error) error {
+	pending := &memoryEventLog{err: t.eventLog.err}
+	if err := fn(domain.TxRepositories{Customers: t.customers, Payments: t.payments, EventLog: pending}); err != nil {
+		t.rolledBack++
+		return err
+	}
+	t.eventLog.events = append(t.eventLog.events, pending.events...)
+	t.committed++
+	return nil
+}
+
+// newTransactionalService reads through the returned mocks and writes only
+// through the transactor's
+func newTransactionalService(ctx context.Context, customer *domain.Customer) (*PaymentService, *MockCustomerRepository, *recordingTransactor) {
+	customers := new(MockCustomerRepository)
+	payments := new(MockPaymentRepository)
+	tx := newRecordingTransactor()
+
+	customers.On("FindByID", ctx, customer.ID).Return(customer, nil)
+	payments.On("ExistsByTransactionReference", ctx, customer.ID, "TXN001").Return(false, nil)
+
+	return NewPaymentService(customers, payments, nil, zap.NewNop(), WithTransactor(tx)), customers, tx
+}
+
+func transactionTestRequest(customerID string) ProcessPaymentRequest {
+	return ProcessPaymentRequest{
+		CustomerID:           customerID,
+		PaymentStatus:        "COMPLETE",
+		TransactionAmount:    1000000,
+		TransactionDate:      time.Now(),
+		TransactionReference: "TXN001",
+	}
+}
+
+func TestProcessPayment_SavesCustomerAndPaymentInOneTransaction(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	service, customers, tx := newTransactionalService(ctx, customer)
+	tx.customers.On("Save", ctx, customer).Return(nil)
+	tx.payments.On("Save", ctx, mock.AnythingOfType("*domain.Payment")).Return(nil)
+
+	response, err := service.ProcessPayment(ctx, transactionTestRequest(customer.ID))
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeProcessed, response.Outcome)
+	assert.Equal(t, 1, tx.committed)
+	tx.customers.AssertExpectations(t)
+	tx.payments.AssertExpectations(t)
+	customers.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestProcessPayment_FailedPaymentSaveRollsBackCustomer(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	service, _, tx := newTransactionalService(ctx, customer)
+	tx.customers.On("Save", ctx, customer).Return(nil)
+	tx.payments.On("Save", ctx, mock.AnythingOfType("*domain.Payment")).
+		Return(domain.ContextError(ctx, context.DeadlineExceeded))
+
+	_, err := service.ProcessPayment(ctx, transactionTestRequest(customer.ID))
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrRequestTimeout)
+	assert.Equal(t, 0, tx.committed)
+	assert.Equal(t, 1, tx.rolledBack, "the customer write must not outlive the failed payment insert")
+}
+
+func TestProcessPayment_DuplicateInTransactionNeedsNoReversal(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	service, customers, tx := newTransactionalService(ctx, customer)
+	tx.customers.On("Save", ctx, mock.Anything).Return(nil).Once()
+	tx.payments.On("Save", ctx, mock.AnythingOfType("*domain.Payment")).Return(domain.ErrDuplicateTransaction)
+	stored := &domain.Payment{ID: "payment-1", CustomerID: customer.ID, TransactionReference: "TXN001", Amount: 1000000}
+	service.paymentRepo.(*MockPaymentRepository).On("FindByTransactionReference", ctx, customer.ID, "TXN001").Return(stored, nil)
+
+	response, err := service.ProcessPayment(ctx, transactionTestRequest(customer.ID))
+
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, response.Outcome)
+	assert.Equal(t, "payment-1", response.PaymentID)
+	assert.Equal(t, 1, tx.rolledBack)
+	tx.customers.AssertNumberOfCalls(t, "Save", 1)
+	customers.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestWriteOffCustomer_RollsBackWhenAuditPaymentFails(t *testing.T) {
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "GIG00020", AssetValue: 100000000, OutstandingBalance: 2500000, TotalPaid: 97500000, Status: domain.CustomerStatusDefaulted, Version: 3}
+	customers := new(MockCustomerRepository)
+	customers.On("FindByID", ctx, customer.ID).Return(customer, nil)
+	tx := newRecordingTransactor()
+	tx.customers.On("Save", ctx, customer).Return(nil)
+	tx.payments.On("Save", ctx, mock.AnythingOfType("*domain.Payment")).Return(assert.AnError)
+	service := NewPaymentService(customers, new(MockPaymentRepository), nil, zap.NewNop(), WithTransactor(tx))
+
+	_, err := service.WriteOffCustomer(ctx, customer.ID, "customer deceased")
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 0, tx.committed)
+	assert.Equal(t, 1, tx.rolledBack)
+	customers.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// VelocityLimits are the per-window thresholds above which a customer's
+// payments are flagged for review. Zero disables a limit.
+type VelocityLimits struct {
+	MaxPayments int64
+	MaxAmount   int64
+}
+
+// WithVelocityCheck records every applied payment with tracker and flags
+// those that push the customer past limits. Flagged payments are still
+// processed; a payment.flagged event is published for ops to review.
+func WithVelocityCheck(tracker domain.VelocityTracker, limits VelocityLimits) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.velocityTracker = tracker
+		s.velocityLimits = limits
+	}
+}
+
+// checkVelocity records the payment and reports whether it should be
+// flagged. Tracking failures are logged and never block the payment; the
+// only error is a failed publish under sync publishing.
+func (s *PaymentService) checkVelocity(ctx context.Context, correlationID string, req ProcessPaymentRequest) (bool, error) {
+	if s.velocityTracker == nil || !s.featureEnabled(ctx, FeatureVelocityCheck, req.CustomerID) {
+		return false, nil
+	}
+
+	velocity, err := s.velocityTracker.Record(ctx, req.CustomerID, req.TransactionAmount)
+	if err != nil {
+		s.logger.Warn("failed to record payment velocity",
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+		)
+		return false, nil
+	}
+
+	var reasons []string
+	if s.velocityLimits.MaxPayments > 0 && velocity.Count > s.velocityLimits.MaxPayments {
+		reasons = append(reasons, fmt.Sprintf("more than %d payments in %s", s.velocityLimits.MaxPayments, velocity.Window))
+	}
+	if s.velocityLimits.MaxAmount > 0 && velocity.TotalAmount > s.velocityLimits.MaxAmount {
+		reasons = append(reasons, fmt.Sprintf("more than %d kobo in %s", s.velocityLimits.MaxAmount, velocity.Window))
+	}
+	if len(reasons) == 0 {
+		return false, nil
+	}
+
+	s.logger.Warn("payment flagged for review",
+		zap.String("customer_id", req.CustomerID),
+		zap.String("tx_ref", req.TransactionReference),
+		zap.Int64("window_payment_count", velocity.Count),
+		zap.Int64("window_total_amount", velocity.TotalAmount),
+		zap.Strings("reasons", reasons),
+	)
+
+	if s.eventPublisher != nil {
+		if err := s.publishPaymentFlaggedEvent(ctx, correlationID, req, velocity, reasons); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+func (s *PaymentService) publishPaymentFlaggedEvent(ctx context.Context, correlationID string, req ProcessPaymentRequest, velocity domain.PaymentVelocity, reasons []string) error {
+	now := s.clock.Now()
+	event := domain.NewPaymentFlaggedEvent(req.CustomerID, domain.PaymentFlaggedPayload{
+		CustomerID:           req.CustomerID,
+		TransactionReference: req.TransactionReference,
+		Amount:               req.TransactionAmount,
+		WindowPaymentCount:   velocity.Count,
+		WindowTotalAmount:    velocity.TotalAmount,
+		WindowStart:          velocity.WindowStart,
+		WindowSeconds:        int64(velocity.Window / time.Second),
+		Reasons:              reasons,
+		FlaggedAt:            now,
+	}, now)
+	event.CorrelationID = correlationID
+
+	return s.publishEvent(ctx, event)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryVelocityTracker keeps a single window per customer
+type memoryVelocityTracker struct {
+	mu     sync.Mutex
+	counts map[string]domain.PaymentVelocity
+	err    error
+}
+
+func (t *memoryVelocityTracker) Record(ctx context.Context, customerID string, amount int64) (domain.PaymentVelocity, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return domain.PaymentVelocity{}, t.err
+	}
+	if t.counts == nil {
+		t.counts = make(map[string]domain.PaymentVelocity)
+	}
+	v := t.counts[customerID]
+	v.Count++
+	v.TotalAmount += amount
+	v.WindowStart = time.Date(2025, 11, 24, 14, 0, 0, 0, time.UTC)
+	v.Window = time.Hour
+	t.counts[customerID] = v
+	return v, nil
+}
+
+func newVelocityTestService(tracker domain.VelocityTracker, limits VelocityLimits, publisher domain.EventPublisher) (*PaymentService, *MockPaymentRepository) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+
+	mockPaymentRepo.On("ExistsByTransactionReference", ctx, mock.Anything, mock.Anything).Return(false, nil)
+	// A fresh customer per call, as a real repository would return, so the
+	// async processed event never shares state with the next payment
+	for i := 0; i < 3; i++ {
+		customer := &domain.Customer{ID: "GIG00070", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+		mockCustomerRepo.On("FindByID", ctx, "GIG00070").Return(customer, nil).Once()
+	}
+	mockCustomerRepo.On("Save", ctx, mock.Anything).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	return NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(),
+		WithVelocityCheck(tracker, limits),
+	), mockPaymentRepo
+}
+
+func TestProcessPayment_FlagsPaymentsOverCountLimit(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	service, mockPaymentRepo := newVelocityTestService(&memoryVelocityTracker{}, VelocityLimits{MaxPayments: 2}, publisher)
+
+	for i := 1; i <= 3; i++ {
+		result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00070", fmt.Sprintf("TXN07%d", i)))
+		require.NoError(t, err)
+		assert.True(t, result.Success, "flagging must not block the payment")
+		assert.Equal(t, i == 3, result.Flagged, "payment %d", i)
+	}
+
+	mockPaymentRepo.AssertNumberOfCalls(t, "Save", 3)
+
+	require.Eventually(t, func() bool {
+		return len(publisher.eventsOfType(domain.EventTypePaymentFlagged)) == 1
+	}, time.Second, 5*time.Millisecond)
+	flagged := publisher.eventsOfType(domain.EventTypePaymentFlagged)[0].(*domain.PaymentFlaggedEvent)
+	assert.Equal(t, "TXN073", flagged.Payload.TransactionReference)
+	assert.Equal(t, int64(3), flagged.Payload.WindowPaymentCount)
+	assert.Equal(t, int64(3600), flagged.Payload.WindowSeconds)
+	assert.Len(t, flagged.Payload.Reasons, 1)
+}
+
+func TestProcessPayment_FlagsPaymentsOverAmountLimit(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	service, _ := newVelocityTestService(&memoryVelocityTracker{}, VelocityLimits{MaxAmount: 1500000}, publisher)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00070", "TXN081"))
+	require.NoError(t, err)
+	assert.False(t, result.Flagged)
+
+	result, err = service.ProcessPayment(ctx, completePaymentRequest("GIG00070", "TXN082"))
+	require.NoError(t, err)
+	assert.True(t, result.Flagged)
+
+	require.Eventually(t, func() bool {
+		return len(publisher.eventsOfType(domain.EventTypePaymentFlagged)) == 1
+	}, time.Second, 5*time.Millisecond)
+	flagged := publisher.eventsOfType(domain.EventTypePaymentFlagged)[0].(*domain.PaymentFlaggedEvent)
+	assert.Equal(t, int64(2000000), flagged.Payload.WindowTotalAmount)
+}
+
+func TestProcessPayment_VelocityTrackerErrorDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	tracker := &memoryVelocityTracker{err: errors.New("redis down")}
+	service, _ := newVelocityTestService(tracker, VelocityLimits{MaxPayments: 1}, nil)
+
+	result, err := service.ProcessPayment(ctx, completePaymentRequest("GIG00070", "TXN091"))
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.False(t, result.Flagged)
+}
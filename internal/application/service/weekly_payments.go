@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// scheduleWeek is the length of one repayment schedule week
+const scheduleWeek = 7 * 24 * time.Hour
+
+// WithPaymentWeekTotaler totals weekly payments through totaler, which
+// groups them where they are stored. Without it the customer's payments
+// are read and grouped here.
+func WithPaymentWeekTotaler(totaler domain.PaymentWeekTotaler) PaymentServiceOption {
+	return func(s *PaymentService) {
+		s.paymentWeeks = totaler
+	}
+}
+
+// PaymentWeek is one week of the repayment schedule: the COMPLETE payments
+// made in it and the installment that falls due at its end. Week 1 starts
+// at deployment.
+type PaymentWeek struct {
+	Week     int
+	Start    time.Time
+	End      time.Time
+	Totals   domain.PaymentTotals
+	Expected int64
+	// Partial marks the week in progress
+	Partial bool
+}
+
+// WeeklyPaymentsResponse is a customer with every schedule week from
+// deployment to At, including weeks without payments
+type WeeklyPaymentsResponse struct {
+	Customer *domain.Customer
+	Weeks    []PaymentWeek
+	Totals   domain.PaymentTotals
+	At       time.Time
+}
+
+// WeeklyPayments totals a customer's payments per schedule week, up to the
+// week in progress. Payments dated before deployment count towards week 1,
+// and the weeks run on past the current one to cover any dated later.
+func (s *PaymentService) WeeklyPayments(ctx context.Context, customerID string) (*WeeklyPaymentsResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
+
+	customer, err := s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	totals, err := s.totalsByWeek(ctx, customer)
+	if err != nil {
+		logFailure(s.logger, "failed to total payments by week", err,
+			zap.String("customer_id", customerID),
+		)
+		return nil, fmt.Errorf("failed to total payments by week: %w", err)
+	}
+
+	now := s.clock.Now()
+	current := customer.ScheduleWeek(now)
+	last := current
+	for week := range totals {
+		last = max(last, week)
+	}
+
+	response := &WeeklyPaymentsResponse{Customer: customer, Weeks: make([]PaymentWeek, 0, last+1), At: now}
+	for week := 0; week <= last; week++ {
+		start := customer.DeploymentDate.Add(time.Duration(week) * scheduleWeek)
+		entry := PaymentWeek{
+			Week:     week + 1,
+			Start:    start,
+			End:      start.Add(scheduleWeek),
+			Totals:   totals[week],
+			Expected: customer.InstallmentAmount(week + 1),
+			Partial:  week == current && now.After(customer.DeploymentDate),
+		}
+		response.Weeks = append(response.Weeks, entry)
+		response.Totals.Count += entry.Totals.Count
+		response.Totals.Amount += entry.Totals.Amount
+	}
+	return response, nil
+}
+
+func (s *PaymentService) totalsByWeek(ctx context.Context, customer *domain.Customer) (map[int]domain.PaymentTotals, error) {
+	if s.paymentWeeks != nil {
+		return s.paymentWeeks.TotalsByWeek(ctx, customer.ID, customer.DeploymentDate)
+	}
+
+	payments, err := s.paymentRepo.FindByCustomerID(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[int]domain.PaymentTotals)
+	for _, payment := range payments {
+		if payment.Status != domain.PaymentStatusComplete {
+			continue
+		}
+		week := customer.ScheduleWeek(payment.TransactionDate)
+		totals[week] = domain.PaymentTotals{
+			Count:  totals[week].Count + 1,
+			Amount: totals[week].Amount + payment.Amount,
+		}
+	}
+	return totals, nil
+}
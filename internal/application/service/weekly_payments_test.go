@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type stubWeekTotaler struct {
+	totals     map[int]domain.PaymentTotals
+	customerID string
+	start      time.Time
+}
+
+func (s *stubWeekTotaler) TotalsByWeek(ctx context.Context, customerID string, start time.Time) (map[int]domain.PaymentTotals, error) {
+	s.customerID, s.start = customerID, start
+	return s.totals, nil
+}
+
+func TestWeeklyPayments_FillsGapsThroughLatestWeek(t *testing.T) {
+	ctx := context.Background()
+	deployed := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	now := deployed.Add(2*scheduleWeek + time.Hour)
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 1000000, RepaymentTermWeeks: 4, DeploymentDate: deployed, Status: domain.CustomerStatusActive}
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockCustomerRepo.On("FindByID", ctx, "GIG00001").Return(customer, nil)
+	totaler := &stubWeekTotaler{totals: map[int]domain.PaymentTotals{
+		0: {Count: 1, Amount: 250000},
+		// Dated after the current week, so the chart runs on to cover it
+		5: {Count: 1, Amount: 1000},
+	}}
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), nil, zap.NewNop(),
+		WithPaymentWeekTotaler(totaler),
+		WithClock(domain.ClockFunc(func() time.Time { return now })),
+	)
+
+	result, err := service.WeeklyPayments(ctx, "gig00001")
+	require.NoError(t, err)
+	assert.Equal(t, "GIG00001", totaler.customerID)
+	assert.Equal(t, deployed, totaler.start)
+
+	require.Len(t, result.Weeks, 6)
+	for i, week := range result.Weeks {
+		assert.Equal(t, i+1, week.Week)
+		assert.Equal(t, deployed.Add(time.Duration(i)*scheduleWeek), week.Start)
+		assert.Equal(t, i == 2, week.Partial, "week %d", week.Week)
+	}
+	assert.Equal(t, int64(250000), result.Weeks[0].Totals.Amount)
+	assert.Zero(t, result.Weeks[1].Totals.Count)
+	assert.Equal(t, int64(1000), result.Weeks[5].Totals.Amount)
+	assert.Equal(t, int64(250000), result.Weeks[3].Expected)
+	assert.Zero(t, result.Weeks[4].Expected, "past the end of the term")
+	assert.Equal(t, domain.PaymentTotals{Count: 2, Amount: 251000}, result.Totals)
+}
+
+func TestWeeklyPayments_UnknownCustomer(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockCustomerRepo.On("FindByID", ctx, "GIG09999").Return(nil, domain.ErrCustomerNotFound)
+	service := NewPaymentService(mockCustomerRepo, new(MockPaymentRepository), nil, zap.NewNop(),
+		WithPaymentWeekTotaler(&stubWeekTotaler{}),
+	)
+
+	_, err := service.WeeklyPayments(ctx, "GIG09999")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+}
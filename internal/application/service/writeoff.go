@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrWriteOffReasonRequired is returned when a write-off has no reason
+var ErrWriteOffReasonRequired = errors.New("write-off reason is required")
+
+type WriteOffResponse struct {
+	Customer             *domain.Customer
+	AmountWrittenOff     int64
+	TransactionReference string
+}
+
+// WriteOffCustomer forgives a customer's outstanding balance and records a
+// WRITEOFF audit payment for the amount forgiven.
+func (s *PaymentService) WriteOffCustomer(ctx context.Context, customerID, reason string) (*WriteOffResponse, error) {
+	customerID = NormalizeCustomerID(customerID)
+
+	if reason == "" {
+		return nil, ErrWriteOffReasonRequired
+	}
+
+	correlationID := domain.CorrelationIDFromContext(ctx)
+
+	customer, err := s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	previousStatus := customer.Status
+	amount, err := customer.WriteOff()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &WriteOffResponse{
+		Customer:         customer,
+		AmountWrittenOff: amount,
+	}
+
+	var payment *domain.Payment
+	if amount > 0 {
+		txRef := fmt.Sprintf("WRITEOFF-%s-%s", customerID, uuid.New().String())
+		now := s.clock.Now()
+
+		payment, err = domain.NewPayment(customerID, amount, txRef, now, domain.PaymentStatusWriteOff, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid write-off payment: %w", err)
+		}
+		payment.MarkAsProcessed(now)
+		payment.CurrencyCode = customer.Currency().Code
+		payment.RecordBalanceAfter(customer.OutstandingBalance)
+	}
+
+	// The audit payment and the event commit with the balance they explain,
+	// or none of them do
+	events, err := s.commit(ctx, func(repos domain.TxRepositories) error {
+		if err := repos.Customers.Save(ctx, customer); err != nil {
+			logFailure(s.logger, "failed to save written-off customer", err,
+				zap.String("customer_id", customerID),
+			)
+			return fmt.Errorf("failed to save customer: %w", err)
+		}
+		if payment == nil {
+			return nil
+		}
+		if err := repos.Payments.Save(ctx, payment); err != nil {
+			logFailure(s.logger, "failed to save write-off audit payment", err,
+				zap.String("customer_id", customerID),
+				zap.String("tx_ref", payment.TransactionReference),
+			)
+			return fmt.Errorf("failed to save write-off payment: %w", err)
+		}
+		return nil
+	}, func() []domain.DomainEvent {
+		return []domain.DomainEvent{s.customerWrittenOffEvent(correlationID, customer, previousStatus, reason)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer s.invalidateCustomerViews(ctx, customerID)
+	if payment != nil {
+		response.TransactionReference = payment.TransactionReference
+	}
+
+	s.logger.Info("customer loan written off",
+		zap.String("customer_id", customerID),
+		zap.String("previous_status", string(previousStatus)),
+		zap.Int64("amount", amount),
+		zap.String("reason", reason),
+	)
+
+	if err := s.publishCommitted(ctx, events); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (s *PaymentService) customerWrittenOffEvent(correlationID string, customer *domain.Customer, previousStatus domain.CustomerStatus, reason string) *domain.CustomerUpdatedEvent {
+	now := s.clock.Now()
+	event := domain.NewCustomerUpdatedEvent(customer.ID, domain.CustomerUpdatedPayload{
+		CustomerID:         customer.ID,
+		PreviousStatus:     string(previousStatus),
+		Status:             string(customer.Status),
+		OutstandingBalance: customer.OutstandingBalance,
+		TotalPaid:          customer.TotalPaid,
+		Reason:             reason,
+		UpdatedAt:          now,
+	}, now)
+	event.CorrelationID = correlationID
+	event.Sequence = customer.Version
+	return event
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWriteOffCustomer_Success(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00020"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 2500000, TotalPaid: 97500000, Status: domain.CustomerStatusDefaulted, Version: 3}
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.MatchedBy(func(p *domain.Payment) bool {
+		return p.Status == domain.PaymentStatusWriteOff && p.Amount == 2500000 && p.CustomerID == customerID &&
+			p.BalanceAfter != nil && *p.BalanceAfter == 0
+	})).Return(nil)
+
+	result, err := service.WriteOffCustomer(ctx, customerID, "customer deceased")
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500000), result.AmountWrittenOff)
+	assert.NotEmpty(t, result.TransactionReference)
+	assert.Equal(t, domain.CustomerStatusWrittenOff, result.Customer.Status)
+	assert.Equal(t, int64(0), result.Customer.OutstandingBalance)
+	assert.Equal(t, int64(97500000), result.Customer.TotalPaid)
+	assert.False(t, result.Customer.IsFullyPaid())
+	assert.NoError(t, result.Customer.Validate())
+	mockCustomerRepo.AssertExpectations(t)
+	mockPaymentRepo.AssertExpectations(t)
+
+	assert.Eventually(t, func() bool {
+		return len(publisher.eventsOfType(domain.EventTypeCustomerUpdated)) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	updated, ok := publisher.eventsOfType(domain.EventTypeCustomerUpdated)[0].(*domain.CustomerUpdatedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, "DEFAULTED", updated.Payload.PreviousStatus)
+		assert.Equal(t, "WRITTEN_OFF", updated.Payload.Status)
+		assert.Equal(t, "customer deceased", updated.Payload.Reason)
+	}
+}
+
+func TestWriteOffCustomer_StampsTimesFromClock(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00023"
+	frozen := time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop(),
+		WithClock(domain.ClockFunc(func() time.Time { return frozen })),
+	)
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 2500000, TotalPaid: 97500000, Status: domain.CustomerStatusDefaulted, Version: 3}
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+	mockCustomerRepo.On("Save", ctx, customer).Return(nil)
+	mockPaymentRepo.On("Save", ctx, mock.MatchedBy(func(p *domain.Payment) bool {
+		return p.TransactionDate.Equal(frozen) && p.CreatedAt.Equal(frozen) && p.ProcessedAt.Equal(frozen)
+	})).Return(nil)
+
+	_, err := service.WriteOffCustomer(ctx, customerID, "customer deceased")
+	require.NoError(t, err)
+	require.NoError(t, service.WaitForPublishes(ctx))
+	mockPaymentRepo.AssertExpectations(t)
+
+	updated := publisher.eventsOfType(domain.EventTypeCustomerUpdated)
+	require.Len(t, updated, 1)
+	assert.Equal(t, frozen, updated[0].GetOccurredAt())
+	assert.Equal(t, frozen, updated[0].(*domain.CustomerUpdatedEvent).Payload.UpdatedAt)
+}
+
+func TestWriteOffCustomer_AlreadyCompleted(t *testing.T) {
+	ctx := context.Background()
+	customerID := "GIG00021"
+
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPaymentRepo := new(MockPaymentRepository)
+	publisher := &recordingPublisher{}
+
+	service := NewPaymentService(mockCustomerRepo, mockPaymentRepo, publisher, zap.NewNop())
+
+	customer := &domain.Customer{ID: customerID, AssetValue: 100000000, OutstandingBalance: 0, TotalPaid: 100000000, Status: domain.CustomerStatusCompleted, Version: 5}
+	mockCustomerRepo.On("FindByID", ctx, customerID).Return(customer, nil)
+
+	result, err := service.WriteOffCustomer(ctx, customerID, "goodwill")
+
+	assert.ErrorIs(t, err, domain.ErrAssetAlreadyOwned)
+	assert.Nil(t, result)
+	mockCustomerRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	mockPaymentRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestWriteOffCustomer_RequiresReason(t *testing.T) {
+	service := NewPaymentService(new(MockCustomerRepository), new(MockPaymentRepository), nil, zap.NewNop())
+
+	_, err := service.WriteOffCustomer(context.Background(), "GIG00022", "")
+	assert.ErrorIs(t, err, ErrWriteOffReasonRequired)
+}
@@ -0,0 +1,10 @@
+// Package buildinfo carries build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/gigmile/payment-service/internal/buildinfo.Version=v1.4.0"
+package buildinfo
+
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
@@ -1,75 +1,550 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
-	"strconv"
+	"regexp"
+	"time"
+	// The runtime image has no zoneinfo; configured timezones must still load
+	_ "time/tzdata"
 
 	_ "github.com/joho/godotenv/autoload"
+	"go.uber.org/zap/zapcore"
 )
 
+// Fields are populated by the loader in loader.go from, in increasing order
+// of precedence, the `default` tag, the config file key named by `key`
+// (nested under the parent struct's key) and the `env` variable.
 type Config struct {
-	Server ServerConfig
-	Redis  RedisConfig
-	MySQL  MySQLConfig
+	Server    ServerConfig    `key:"server"`
+	Redis     RedisConfig     `key:"redis"`
+	MySQL     MySQLConfig     `key:"mysql"`
+	Payment   PaymentConfig   `key:"payment"`
+	CacheWarm CacheWarmConfig `key:"cache_warm"`
+	Worker    WorkerConfig    `key:"worker"`
+	Events    EventsConfig    `key:"events"`
+	Report    ReportConfig    `key:"report"`
+	Log       LogConfig       `key:"log"`
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port string `key:"port" env:"SERVER_PORT" default:"8072"`
+	Host string `key:"host" env:"SERVER_HOST" default:"0.0.0.0"`
+	// DisallowUnknownFields rejects request bodies carrying fields we don't know
+	DisallowUnknownFields bool `key:"disallow_unknown_fields" env:"HTTP_DISALLOW_UNKNOWN_FIELDS" default:"false"`
+	// ExposeErrorDetails includes panic messages in 500 responses (dev only)
+	ExposeErrorDetails bool `key:"expose_error_details" env:"HTTP_EXPOSE_ERROR_DETAILS" default:"false"`
+	// PaymentSourceCIDRs limits who may POST payments; empty allows all
+	PaymentSourceCIDRs []string `key:"payment_source_cidrs" env:"PAYMENT_SOURCE_CIDRS"`
+	// TrustedProxyCIDRs are the proxies whose X-Forwarded-For and X-Real-IP
+	// headers PaymentSourceCIDRs is checked against; other peers are
+	// checked by their own address
+	TrustedProxyCIDRs []string `key:"trusted_proxy_cidrs" env:"HTTP_TRUSTED_PROXY_CIDRS"`
+	// AdminToken is the bearer token for admin routes; empty disables them
+	AdminToken string `key:"admin_token" env:"ADMIN_API_TOKEN"`
+	// CustomerBatchMaxIDs caps how many customers one batch lookup may request
+	CustomerBatchMaxIDs int `key:"customer_batch_max_ids" env:"CUSTOMER_BATCH_MAX_IDS" default:"100"`
+	// CustomerIDPattern is the regexp a customer ID in a route must match;
+	// anything else is answered with 400 before any lookup
+	CustomerIDPattern string `key:"customer_id_pattern" env:"CUSTOMER_ID_PATTERN" default:"^GIG\\d{5}$"`
+	// CurrencySymbol prefixes the formatted amounts in responses that are in
+	// the default payment currency; other currencies use their own symbol
+	CurrencySymbol string `key:"currency_symbol" env:"CURRENCY_SYMBOL" default:"₦"`
+	// DebugEnabled serves GET /debug/info to admins; DebugPprof adds pprof.
+	// Never expose these publicly.
+	DebugEnabled bool `key:"debug_enabled" env:"DEBUG_ENDPOINTS_ENABLED" default:"false"`
+	DebugPprof   bool `key:"debug_pprof" env:"DEBUG_PPROF_ENABLED" default:"false"`
+	// ResponseCacheRoutes opts GET routes into Redis response caching:
+	// "customer" and/or "payments". Empty disables the cache.
+	ResponseCacheRoutes []string      `key:"response_cache_routes" env:"HTTP_RESPONSE_CACHE_ROUTES"`
+	ResponseCacheTTL    time.Duration `key:"response_cache_ttl" env:"HTTP_RESPONSE_CACHE_TTL" default:"5s"`
+	// MaintenanceRefresh is how stale each replica's copy of the maintenance
+	// flag may get; MaintenanceRetryAfter is sent with the 503s
+	MaintenanceRefresh    time.Duration `key:"maintenance_refresh" env:"HTTP_MAINTENANCE_REFRESH" default:"2s"`
+	MaintenanceRetryAfter time.Duration `key:"maintenance_retry_after" env:"HTTP_MAINTENANCE_RETRY_AFTER" default:"60s"`
+	// IdempotencyTTL is how long a POST /payments Idempotency-Key keeps
+	// replaying its first response; after that the key may be reused.
+	// IdempotencyWait is how long a duplicate waits for the first request
+	// with the same key to finish before it is answered with 409.
+	IdempotencyTTL  time.Duration `key:"idempotency_ttl" env:"HTTP_IDEMPOTENCY_TTL" default:"24h"`
+	IdempotencyWait time.Duration `key:"idempotency_wait" env:"HTTP_IDEMPOTENCY_WAIT" default:"5s"`
+	// RequestTimeout bounds each request; callers may ask for their own
+	// with X-Request-Timeout, up to MaxRequestTimeout
+	RequestTimeout    time.Duration `key:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" default:"30s"`
+	MaxRequestTimeout time.Duration `key:"max_request_timeout" env:"HTTP_MAX_REQUEST_TIMEOUT" default:"30s"`
+	// ShutdownTimeout is how long shutdown waits for in-flight requests
+	// and events before cutting them off; ShutdownLogInterval is how often
+	// it logs the connections still draining
+	ShutdownTimeout     time.Duration `key:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" default:"30s"`
+	ShutdownLogInterval time.Duration `key:"shutdown_log_interval" env:"HTTP_SHUTDOWN_LOG_INTERVAL" default:"1s"`
 }
 
 type RedisConfig struct {
-	Host     string
-	Port     string
-	Password string
-	DB       int
-	PoolSize int
+	// Mode is "single", "sentinel" or "cluster"
+	Mode string `key:"mode" env:"REDIS_MODE" default:"single"`
+	Host string `key:"host" env:"REDIS_HOST" default:"localhost"`
+	Port string `key:"port" env:"REDIS_PORT" default:"6379"`
+	// Addrs lists the sentinels or cluster seed nodes; single mode uses Host:Port
+	Addrs []string `key:"addrs" env:"REDIS_ADDRS"`
+	// MasterName is the Sentinel master to follow
+	MasterName string `key:"master_name" env:"REDIS_MASTER_NAME"`
+	Password   string `key:"password" env:"REDIS_PASSWORD"`
+	DB         int    `key:"db" env:"REDIS_DB" default:"0"`
+	PoolSize   int    `key:"pool_size" env:"REDIS_POOL_SIZE" default:"100"`
+	// PaymentDedupTTL bounds how long payment dedup keys live in Redis.
+	// MySQL's unique index remains the permanent duplicate guard.
+	PaymentDedupTTL time.Duration `key:"payment_dedup_ttl" env:"REDIS_PAYMENT_DEDUP_TTL" default:"720h"`
+	// KeyPrefix namespaces all keys and streams, e.g. "staging:payments".
+	// Empty keeps the bare legacy key names.
+	KeyPrefix string `key:"key_prefix" env:"REDIS_KEY_PREFIX"`
+	// CacheEnabled puts Redis in front of MySQL for customer reads and
+	// payment dedup. Off, both go to MySQL only; events and everything else
+	// kept in Redis are unaffected.
+	CacheEnabled bool `key:"cache_enabled" env:"REDIS_CACHE_ENABLED" default:"true"`
+}
+
+// NodeAddrs is Addrs in sentinel and cluster mode, otherwise Host:Port
+func (c RedisConfig) NodeAddrs() []string {
+	if c.Mode == "sentinel" || c.Mode == "cluster" {
+		return c.Addrs
+	}
+	return []string{c.Host + ":" + c.Port}
 }
 
 type MySQLConfig struct {
-	Host     string
-	User     string
-	Password string
-	Database string
+	Host     string `key:"host" env:"MYSQL_HOST" default:"localhost:3306"`
+	User     string `key:"user" env:"MYSQL_USER" default:"gigmile"`
+	Password string `key:"password" env:"MYSQL_PASSWORD" default:"gigmile123"`
+	Database string `key:"database" env:"MYSQL_DATABASE" default:"gigmile"`
+	// SkipMigrate stops the API migrating at startup; run cmd/migrate instead
+	SkipMigrate bool `key:"skip_migrate" env:"MYSQL_SKIP_MIGRATE" default:"false"`
+	// VerifySchema refuses to start the API when the database schema
+	// version is behind the build
+	VerifySchema bool `key:"verify_schema" env:"MYSQL_VERIFY_SCHEMA" default:"false"`
+	// RejectInvalidCustomers fails customer saves that break a domain
+	// invariant; by default they are logged and counted but still saved
+	RejectInvalidCustomers bool `key:"reject_invalid_customers" env:"MYSQL_REJECT_INVALID_CUSTOMERS" default:"false"`
+	// Connection pool; MaxOpenConns 0 is unlimited and MaxIdleConns may not
+	// exceed it. Zero lifetimes keep connections indefinitely.
+	MaxOpenConns    int           `key:"max_open_conns" env:"MYSQL_MAX_OPEN_CONNS" default:"100"`
+	MaxIdleConns    int           `key:"max_idle_conns" env:"MYSQL_MAX_IDLE_CONNS" default:"10"`
+	ConnMaxLifetime time.Duration `key:"conn_max_lifetime" env:"MYSQL_CONN_MAX_LIFETIME" default:"1h"`
+	ConnMaxIdleTime time.Duration `key:"conn_max_idle_time" env:"MYSQL_CONN_MAX_IDLE_TIME" default:"10m"`
+	// DialTimeout, ReadTimeout and WriteTimeout go into the DSN; 0 leaves
+	// the driver default (no limit)
+	DialTimeout  time.Duration `key:"dial_timeout" env:"MYSQL_DIAL_TIMEOUT" default:"5s"`
+	ReadTimeout  time.Duration `key:"read_timeout" env:"MYSQL_READ_TIMEOUT" default:"30s"`
+	WriteTimeout time.Duration `key:"write_timeout" env:"MYSQL_WRITE_TIMEOUT" default:"30s"`
 }
 
-func Load() *Config {
-	return &Config{
-		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8072"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-		},
-		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
-			PoolSize: getEnvAsInt("REDIS_POOL_SIZE", 100),
-		},
-		MySQL: MySQLConfig{
-			Host:     getEnv("MYSQL_HOST", "localhost:3306"),
-			User:     getEnv("MYSQL_USER", "gigmile"),
-			Password: getEnv("MYSQL_PASSWORD", "gigmile123"),
-			Database: getEnv("MYSQL_DATABASE", "gigmile"),
-		},
+// DSN is the go-sql-driver connection string for this database. Times are
+// written and read as UTC, and the session zone is UTC too so TIMESTAMP
+// columns aren't converted through the MySQL server's zone; the stored
+// instant doesn't depend on the zone of either server.
+func (c MySQLConfig) DSN() string {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&loc=UTC&time_zone=%%27%%2B00%%3A00%%27", c.User, c.Password, c.Host, c.Database)
+	if c.DialTimeout > 0 {
+		dsn += "&timeout=" + c.DialTimeout.String()
+	}
+	if c.ReadTimeout > 0 {
+		dsn += "&readTimeout=" + c.ReadTimeout.String()
+	}
+	if c.WriteTimeout > 0 {
+		dsn += "&writeTimeout=" + c.WriteTimeout.String()
 	}
+	return dsn
+}
+
+type PaymentConfig struct {
+	// MinimumAmount in kobo; payments below it are rejected unless they
+	// settle the balance. Zero disables the check.
+	MinimumAmount int64 `key:"minimum_amount_kobo" env:"PAYMENT_MINIMUM_AMOUNT_KOBO" default:"0"`
+	// SlowThreshold logs payments slower than this with sub-timings; 0 disables
+	SlowThreshold time.Duration `key:"slow_threshold" env:"PAYMENT_SLOW_THRESHOLD" default:"1s"`
+	// TxRefNormalization is "trim" (default) or "upper"
+	TxRefNormalization string `key:"tx_ref_normalization" env:"PAYMENT_TX_REF_NORMALIZATION" default:"trim"`
+	// VelocityMaxPayments and VelocityMaxAmount (kobo) flag customers who
+	// exceed them within VelocityWindow; zero disables each limit
+	VelocityMaxPayments int64         `key:"velocity_max_payments" env:"PAYMENT_VELOCITY_MAX_PAYMENTS" default:"0"`
+	VelocityMaxAmount   int64         `key:"velocity_max_amount_kobo" env:"PAYMENT_VELOCITY_MAX_AMOUNT_KOBO" default:"0"`
+	VelocityWindow      time.Duration `key:"velocity_window" env:"PAYMENT_VELOCITY_WINDOW" default:"1h"`
+	// QueryMaxRange caps the from..to width of a payment date range query
+	QueryMaxRange time.Duration `key:"query_max_range" env:"PAYMENT_QUERY_MAX_RANGE" default:"744h"`
+	// PageSizeDefault is the page size of paginated listings that don't ask
+	// for one, and PageSizeMax the largest they may ask for
+	PageSizeDefault int `key:"page_size_default" env:"PAYMENT_PAGE_SIZE_DEFAULT" default:"10"`
+	PageSizeMax     int `key:"page_size_max" env:"PAYMENT_PAGE_SIZE_MAX" default:"100"`
+	// FeedSettleWindow is how old a payment must be before GET
+	// /payments/stream serves it, so late commits are never skipped
+	FeedSettleWindow time.Duration `key:"feed_settle_window" env:"PAYMENT_FEED_SETTLE_WINDOW" default:"5s"`
+	// DefaultMissedInstallments marks a customer DEFAULTED once they are this
+	// many weekly installments behind; 0 disables default tracking
+	DefaultMissedInstallments int `key:"default_missed_installments" env:"PAYMENT_DEFAULT_MISSED_INSTALLMENTS" default:"4"`
+	// OverpaymentPolicy is "ignore" (default), "credit" or "apply_to_other_loan"
+	OverpaymentPolicy string `key:"overpayment_policy" env:"PAYMENT_OVERPAYMENT_POLICY" default:"ignore"`
+	// OverpaymentLimitEnabled rejects payments exceeding the outstanding
+	// balance by more than the larger of OverpaymentLimitPercent of the
+	// balance and OverpaymentLimitAmount (kobo), rather than applying them
+	OverpaymentLimitEnabled bool  `key:"overpayment_limit_enabled" env:"PAYMENT_OVERPAYMENT_LIMIT_ENABLED" default:"false"`
+	OverpaymentLimitPercent int64 `key:"overpayment_limit_percent" env:"PAYMENT_OVERPAYMENT_LIMIT_PERCENT" default:"10"`
+	OverpaymentLimitAmount  int64 `key:"overpayment_limit_amount_kobo" env:"PAYMENT_OVERPAYMENT_LIMIT_AMOUNT_KOBO" default:"0"`
+	// EarlyPayoffDiscountBPS is the discount, in basis points of the
+	// outstanding balance (250 = 2.5%), offered by payoff quotes
+	EarlyPayoffDiscountBPS int64 `key:"early_payoff_discount_bps" env:"PAYMENT_EARLY_PAYOFF_DISCOUNT_BPS" default:"0"`
+	// Timezone is the zone wall-clock transaction_date values and date
+	// range bounds are read in, and the zone response dates are written in
+	Timezone string `key:"timezone" env:"PAYMENT_TIMEZONE" default:"Africa/Lagos"`
+	// DefaultCurrency is the ISO 4217 code assumed for payment requests
+	// that don't carry a currency; it must match the customer's loan
+	DefaultCurrency string `key:"default_currency" env:"PAYMENT_DEFAULT_CURRENCY" default:"NGN"`
+	// UploadConcurrency bounds how many rows of a CSV upload are processed
+	// at once; UploadMaxBytes caps the size of the upload
+	UploadConcurrency int   `key:"upload_concurrency" env:"PAYMENT_UPLOAD_CONCURRENCY" default:"4"`
+	UploadMaxBytes    int64 `key:"upload_max_bytes" env:"PAYMENT_UPLOAD_MAX_BYTES" default:"10485760"`
+	// DedupScope is what a transaction reference must be unique within:
+	// "global" (default) or "customer", for providers that only keep
+	// references unique per customer. Changing it needs cmd/migrate to run.
+	DedupScope string `key:"dedup_scope" env:"PAYMENT_DEDUP_SCOPE" default:"global"`
+	// FeatureFlagRefresh is how stale each replica's copy of the feature
+	// flags may get
+	FeatureFlagRefresh time.Duration `key:"feature_flag_refresh" env:"PAYMENT_FEATURE_FLAG_REFRESH" default:"5s"`
+	// AtomicApply applies COMPLETE payments to the Redis customer in one
+	// Lua script, dedup included, instead of the optimistic-lock retry. It
+	// writes Redis only, so it is for deployments where Redis is the store
+	// of record. The atomic_apply feature flag can narrow it.
+	AtomicApply bool `key:"atomic_apply" env:"PAYMENT_ATOMIC_APPLY" default:"false"`
+}
+
+type CacheWarmConfig struct {
+	// Enabled pre-populates Redis with active customers on startup
+	Enabled      bool `key:"enabled" env:"CACHE_WARM_ENABLED" default:"false"`
+	BatchSize    int  `key:"batch_size" env:"CACHE_WARM_BATCH_SIZE" default:"500"`
+	Concurrency  int  `key:"concurrency" env:"CACHE_WARM_CONCURRENCY" default:"8"`
+	MaxCustomers int  `key:"max_customers" env:"CACHE_WARM_MAX_CUSTOMERS" default:"10000"`
+
+	// Refresh keeps customers read within RefreshWindow cached by reloading
+	// them once they have less than RefreshLead left. RefreshRunner, "api" or
+	// "worker", is the process that reloads them; both record reads.
+	Refresh       bool   `key:"refresh" env:"CACHE_REFRESH_ENABLED" default:"false"`
+	RefreshRunner string `key:"refresh_runner" env:"CACHE_REFRESH_RUNNER" default:"worker"`
+	// RefreshInterval is how often the runner checks; at most
+	// RefreshMaxPerRun customers are reloaded each time
+	RefreshInterval  time.Duration `key:"refresh_interval" env:"CACHE_REFRESH_INTERVAL" default:"30s"`
+	RefreshWindow    time.Duration `key:"refresh_window" env:"CACHE_REFRESH_WINDOW" default:"10m"`
+	RefreshLead      time.Duration `key:"refresh_lead" env:"CACHE_REFRESH_LEAD" default:"1m"`
+	RefreshMaxPerRun int           `key:"refresh_max_per_run" env:"CACHE_REFRESH_MAX_PER_RUN" default:"200"`
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+type WorkerConfig struct {
+	// RetryInitialBackoff and RetryMaxBackoff bound the jittered exponential
+	// wait after a failed stream read
+	RetryInitialBackoff time.Duration `key:"retry_initial_backoff" env:"WORKER_RETRY_INITIAL_BACKOFF" default:"1s"`
+	RetryMaxBackoff     time.Duration `key:"retry_max_backoff" env:"WORKER_RETRY_MAX_BACKOFF" default:"30s"`
+	// MaxConsecutiveFailures stops the worker so it can be restarted; 0 retries forever
+	MaxConsecutiveFailures int `key:"max_consecutive_failures" env:"WORKER_MAX_CONSECUTIVE_FAILURES" default:"20"`
+	// HandlerTimeout bounds each event handler call; timed-out events stay
+	// unacked. 0 disables the limit.
+	HandlerTimeout time.Duration `key:"handler_timeout" env:"WORKER_HANDLER_TIMEOUT" default:"30s"`
+	// NotificationDedupTTL is how long handled event IDs are remembered so
+	// a redelivered event doesn't notify the customer twice
+	NotificationDedupTTL time.Duration `key:"notification_dedup_ttl" env:"WORKER_NOTIFICATION_DEDUP_TTL" default:"72h"`
+	// SMSBreakerFailurePercent of SMS sends failing within SMSBreakerInterval
+	// (after SMSBreakerMinRequests sends) opens the breaker for
+	// SMSBreakerOpenTimeout; notifications are skipped meanwhile. 0 disables it.
+	SMSBreakerFailurePercent int           `key:"sms_breaker_failure_percent" env:"WORKER_SMS_BREAKER_FAILURE_PERCENT" default:"50"`
+	SMSBreakerMinRequests    int           `key:"sms_breaker_min_requests" env:"WORKER_SMS_BREAKER_MIN_REQUESTS" default:"10"`
+	SMSBreakerInterval       time.Duration `key:"sms_breaker_interval" env:"WORKER_SMS_BREAKER_INTERVAL" default:"1m"`
+	SMSBreakerOpenTimeout    time.Duration `key:"sms_breaker_open_timeout" env:"WORKER_SMS_BREAKER_OPEN_TIMEOUT" default:"30s"`
+	// HeartbeatTTL is how long a worker counts as alive after its last
+	// heartbeat; GET /api/v1/admin/workers lists the live ones
+	HeartbeatTTL time.Duration `key:"heartbeat_ttl" env:"WORKER_HEARTBEAT_TTL" default:"1m"`
+}
+
+type EventsConfig struct {
+	// Backend is where events are published: redis (streams) or kafka
+	Backend string `key:"backend" env:"EVENT_BACKEND" default:"redis"`
+	// KafkaBrokers are the bootstrap brokers for the kafka backend. Each
+	// event type goes to its own topic, KafkaTopicPrefix + the type.
+	KafkaBrokers     []string `key:"kafka_brokers" env:"EVENT_KAFKA_BROKERS"`
+	KafkaTopicPrefix string   `key:"kafka_topic_prefix" env:"EVENT_KAFKA_TOPIC_PREFIX" default:"events."`
+	// SchemaValidation checks each event against its JSON Schema before publishing
+	SchemaValidation bool `key:"schema_validation" env:"EVENT_SCHEMA_VALIDATION" default:"true"`
+	// PublishSync publishes events before the request that raised them
+	// returns and fails the request when publishing fails, instead of
+	// publishing in the background
+	PublishSync bool `key:"publish_sync" env:"EVENT_PUBLISH_SYNC" default:"false"`
+	// LogEnabled writes every event to the MySQL event_log table before
+	// publishing it, in the transaction of the change it records, and
+	// serves the log at GET /api/v1/admin/events/log
+	LogEnabled bool `key:"log_enabled" env:"EVENT_LOG_ENABLED" default:"true"`
+	// LogSettleWindow is how old an event must be before the event log
+	// serves it, so one whose transaction commits late is not skipped
+	LogSettleWindow time.Duration `key:"log_settle_window" env:"EVENT_LOG_SETTLE_WINDOW" default:"5s"`
+	// MoneyAsStrings writes the amounts in payment.processed events as
+	// decimal strings, so consumers that read JSON numbers as doubles
+	// (JavaScript) don't lose precision past 2^53
+	MoneyAsStrings bool `key:"money_as_strings" env:"EVENT_MONEY_AS_STRINGS" default:"false"`
+	// StreamMaxLen and StreamMaxAge bound every events:<type> stream; zero
+	// lifts that limit. StreamApproxTrim trims lazily, which is much
+	// cheaper but keeps a few extra entries.
+	StreamMaxLen     int64         `key:"stream_max_len" env:"EVENT_STREAM_MAX_LEN" default:"100000"`
+	StreamMaxAge     time.Duration `key:"stream_max_age" env:"EVENT_STREAM_MAX_AGE" default:"0"`
+	StreamApproxTrim bool          `key:"stream_approx_trim" env:"EVENT_STREAM_APPROX_TRIM" default:"true"`
+	// StreamRetention overrides the limits per event type, as
+	// <event_type>=<max_len>[/<max_age>] entries
+	StreamRetention []string `key:"stream_retention" env:"EVENT_STREAM_RETENTION"`
+	// ClaimMinIdle is how long a message must have gone unacknowledged
+	// before POST /api/v1/admin/events/{event_type}/claim re-queues it,
+	// unless the request sets min_idle
+	ClaimMinIdle time.Duration `key:"claim_min_idle" env:"EVENT_CLAIM_MIN_IDLE" default:"5m"`
+	// PublishDedupTTL is how long the Redis publisher remembers an event ID,
+	// publishing it again within that time being a no-op; 0 disables it
+	PublishDedupTTL time.Duration `key:"publish_dedup_ttl" env:"EVENT_PUBLISH_DEDUP_TTL" default:"24h"`
+}
+
+type ReportConfig struct {
+	// DailyEnabled has the worker publish a reconciliation.daily event for
+	// the previous day at DailyAt (HH:MM) in Timezone, and the API record
+	// the duplicate and failed payments it counts
+	DailyEnabled bool   `key:"daily_enabled" env:"REPORT_DAILY_ENABLED" default:"false"`
+	DailyAt      string `key:"daily_at" env:"REPORT_DAILY_AT" default:"00:05"`
+	Timezone     string `key:"timezone" env:"REPORT_TIMEZONE" default:"Africa/Lagos"`
+	// OutcomeRetention is how long recorded duplicates and failures are kept
+	OutcomeRetention time.Duration `key:"outcome_retention" env:"REPORT_OUTCOME_RETENTION" default:"192h"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error (dpanic, panic and fatal also parse)
+	Level string `key:"level" env:"LOG_LEVEL" default:"info"`
+	// Encoding is json, for log shipping, or console, for reading locally
+	Encoding string `key:"encoding" env:"LOG_ENCODING" default:"json"`
+	// Sampling drops repeats of the same message under load
+	Sampling bool `key:"sampling" env:"LOG_SAMPLING" default:"true"`
+}
+
+// Load builds the config from defaults, an optional YAML or JSON file and
+// the environment, in that order of precedence. An empty path falls back to
+// CONFIG_FILE; with neither set only defaults and env vars are used.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	var file map[string]interface{}
+	if path != "" {
+		var err error
+		if file, err = readFile(path); err != nil {
+			return nil, err
+		}
 	}
-	return defaultValue
+
+	cfg := &Config{}
+	if err := load(cfg, file); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
+// Validate reports settings that can't work, all at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port == "" {
+		errs = append(errs, errors.New("server port is required"))
+	}
+	if c.Server.CustomerBatchMaxIDs <= 0 {
+		errs = append(errs, errors.New("customer batch max IDs must be positive"))
+	}
+	if _, err := regexp.Compile(c.Server.CustomerIDPattern); err != nil {
+		errs = append(errs, fmt.Errorf("customer ID pattern is not a valid regexp: %w", err))
+	}
+	for _, route := range c.Server.ResponseCacheRoutes {
+		if route != "customer" && route != "payments" {
+			errs = append(errs, fmt.Errorf("response cache route must be customer or payments, got %q", route))
+		}
+	}
+	if len(c.Server.ResponseCacheRoutes) > 0 && c.Server.ResponseCacheTTL <= 0 {
+		errs = append(errs, errors.New("response cache TTL must be positive"))
+	}
+	if c.Server.MaintenanceRefresh < 0 {
+		errs = append(errs, errors.New("maintenance refresh must not be negative"))
+	}
+	if c.Server.MaintenanceRetryAfter < time.Second {
+		errs = append(errs, errors.New("maintenance retry-after must be at least 1s"))
+	}
+	if c.Server.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("idempotency TTL must be positive"))
+	}
+	if c.Server.IdempotencyWait < 0 {
+		errs = append(errs, errors.New("idempotency wait must not be negative"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("request timeout must be positive"))
+	}
+	if c.Server.MaxRequestTimeout < c.Server.RequestTimeout {
+		errs = append(errs, errors.New("max request timeout must not be below the request timeout"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+	if c.Server.ShutdownLogInterval <= 0 {
+		errs = append(errs, errors.New("shutdown log interval must be positive"))
+	}
+	switch c.Redis.Mode {
+	case "single":
+	case "sentinel":
+		if c.Redis.MasterName == "" || len(c.Redis.Addrs) == 0 {
+			errs = append(errs, errors.New("redis sentinel mode requires a master name and sentinel addrs"))
+		}
+	case "cluster":
+		if len(c.Redis.Addrs) == 0 {
+			errs = append(errs, errors.New("redis cluster mode requires cluster addrs"))
+		}
+		if c.Redis.DB != 0 {
+			errs = append(errs, errors.New("redis cluster mode requires DB 0"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("redis mode must be single, sentinel or cluster, got %q", c.Redis.Mode))
 	}
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
+	if c.Redis.PoolSize <= 0 {
+		errs = append(errs, errors.New("redis pool size must be positive"))
 	}
-	return value
+	if c.Redis.PaymentDedupTTL <= 0 {
+		errs = append(errs, errors.New("redis payment dedup TTL must be positive"))
+	}
+	if c.MySQL.MaxOpenConns < 0 || c.MySQL.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("mysql pool sizes must not be negative"))
+	}
+	if c.MySQL.MaxOpenConns > 0 && c.MySQL.MaxIdleConns > c.MySQL.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("mysql max idle conns (%d) must not exceed max open conns (%d)",
+			c.MySQL.MaxIdleConns, c.MySQL.MaxOpenConns))
+	}
+	if c.MySQL.ConnMaxLifetime < 0 || c.MySQL.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("mysql connection lifetimes must not be negative"))
+	}
+	if c.MySQL.DialTimeout < 0 || c.MySQL.ReadTimeout < 0 || c.MySQL.WriteTimeout < 0 {
+		errs = append(errs, errors.New("mysql timeouts must not be negative"))
+	}
+	if c.Payment.MinimumAmount < 0 {
+		errs = append(errs, errors.New("payment minimum amount must not be negative"))
+	}
+	if c.Payment.OverpaymentLimitPercent < 0 || c.Payment.OverpaymentLimitAmount < 0 {
+		errs = append(errs, errors.New("payment overpayment limits must not be negative"))
+	}
+	if _, err := time.LoadLocation(c.Payment.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("invalid payment timezone %q: %w", c.Payment.Timezone, err))
+	}
+	if c.Payment.EarlyPayoffDiscountBPS < 0 || c.Payment.EarlyPayoffDiscountBPS > 10000 {
+		errs = append(errs, errors.New("payment early payoff discount must be between 0 and 10000 basis points"))
+	}
+	if c.Payment.VelocityMaxPayments < 0 || c.Payment.VelocityMaxAmount < 0 {
+		errs = append(errs, errors.New("payment velocity limits must not be negative"))
+	}
+	if c.Payment.VelocityWindow <= 0 {
+		errs = append(errs, errors.New("payment velocity window must be positive"))
+	}
+	if c.Payment.QueryMaxRange < 0 {
+		errs = append(errs, errors.New("payment query max range must not be negative"))
+	}
+	if c.Payment.PageSizeDefault < 1 || c.Payment.PageSizeMax < c.Payment.PageSizeDefault {
+		errs = append(errs, errors.New("payment page size default must be positive and no more than the max"))
+	}
+	if c.Payment.DefaultMissedInstallments < 0 {
+		errs = append(errs, errors.New("payment default missed installments must not be negative"))
+	}
+	if c.Payment.UploadConcurrency <= 0 || c.Payment.UploadMaxBytes <= 0 {
+		errs = append(errs, errors.New("payment upload concurrency and max bytes must be positive"))
+	}
+	if c.Payment.DedupScope != "global" && c.Payment.DedupScope != "customer" {
+		errs = append(errs, fmt.Errorf("payment dedup scope must be global or customer, got %q", c.Payment.DedupScope))
+	}
+	if c.Payment.FeatureFlagRefresh < 0 {
+		errs = append(errs, errors.New("payment feature flag refresh must not be negative"))
+	}
+	if c.Payment.AtomicApply && (!c.Redis.CacheEnabled || c.Redis.Mode == "cluster") {
+		errs = append(errs, errors.New("payment atomic apply needs the Redis cache enabled and a single or sentinel Redis"))
+	}
+	if !c.Redis.CacheEnabled && (c.CacheWarm.Enabled || c.CacheWarm.Refresh) {
+		errs = append(errs, errors.New("cache warming and refresh need the Redis cache enabled"))
+	}
+	if c.CacheWarm.Enabled && (c.CacheWarm.BatchSize <= 0 || c.CacheWarm.Concurrency <= 0) {
+		errs = append(errs, errors.New("cache warm batch size and concurrency must be positive"))
+	}
+	if c.CacheWarm.Refresh {
+		if c.CacheWarm.RefreshRunner != "api" && c.CacheWarm.RefreshRunner != "worker" {
+			errs = append(errs, fmt.Errorf("cache refresh runner must be api or worker, got %q", c.CacheWarm.RefreshRunner))
+		}
+		if c.CacheWarm.RefreshInterval <= 0 || c.CacheWarm.RefreshWindow <= 0 || c.CacheWarm.RefreshLead <= 0 || c.CacheWarm.RefreshMaxPerRun <= 0 {
+			errs = append(errs, errors.New("cache refresh interval, window, lead and max per run must be positive"))
+		}
+	}
+	if c.Worker.RetryInitialBackoff <= 0 || c.Worker.RetryMaxBackoff < c.Worker.RetryInitialBackoff {
+		errs = append(errs, errors.New("worker retry backoff must be positive with max >= initial"))
+	}
+	if c.Worker.MaxConsecutiveFailures < 0 {
+		errs = append(errs, errors.New("worker max consecutive failures must not be negative"))
+	}
+	if c.Worker.HandlerTimeout < 0 {
+		errs = append(errs, errors.New("worker handler timeout must not be negative"))
+	}
+	if c.Worker.NotificationDedupTTL <= 0 {
+		errs = append(errs, errors.New("worker notification dedup TTL must be positive"))
+	}
+	switch c.Events.Backend {
+	case "redis":
+	case "kafka":
+		if len(c.Events.KafkaBrokers) == 0 {
+			errs = append(errs, errors.New("kafka event backend requires kafka brokers"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("event backend must be redis or kafka, got %q", c.Events.Backend))
+	}
+	if c.Events.StreamMaxLen < 0 {
+		errs = append(errs, errors.New("event stream max length must not be negative"))
+	}
+	if c.Events.StreamMaxAge < 0 {
+		errs = append(errs, errors.New("event stream max age must not be negative"))
+	}
+	if c.Events.ClaimMinIdle <= 0 {
+		errs = append(errs, errors.New("event claim min idle must be positive"))
+	}
+	if c.Events.PublishDedupTTL < 0 {
+		errs = append(errs, errors.New("event publish dedup TTL must not be negative"))
+	}
+	if c.Worker.HeartbeatTTL < 5*time.Second {
+		errs = append(errs, errors.New("worker heartbeat TTL must be at least 5s"))
+	}
+	if c.Worker.SMSBreakerFailurePercent < 0 || c.Worker.SMSBreakerFailurePercent > 100 {
+		errs = append(errs, errors.New("worker SMS breaker failure percent must be between 0 and 100"))
+	}
+	if c.Worker.SMSBreakerFailurePercent > 0 &&
+		(c.Worker.SMSBreakerMinRequests <= 0 || c.Worker.SMSBreakerInterval < 0 || c.Worker.SMSBreakerOpenTimeout <= 0) {
+		errs = append(errs, errors.New("worker SMS breaker needs positive min requests and open timeout"))
+	}
+	if c.Report.DailyEnabled {
+		if _, err := time.Parse("15:04", c.Report.DailyAt); err != nil {
+			errs = append(errs, fmt.Errorf("invalid report daily time %q, want HH:MM", c.Report.DailyAt))
+		}
+		if _, err := time.LoadLocation(c.Report.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("invalid report timezone %q: %w", c.Report.Timezone, err))
+		}
+		if c.Report.OutcomeRetention < 24*time.Hour {
+			errs = append(errs, errors.New("report outcome retention must cover at least a day"))
+		}
+	}
+
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.Log.Level))
+	}
+	if c.Log.Encoding != "json" && c.Log.Encoding != "console" {
+		errs = append(errs, fmt.Errorf("invalid log encoding %q, want json or console", c.Log.Encoding))
+	}
+
+	return errors.Join(errs...)
 }
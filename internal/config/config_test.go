@@ -0,0 +1,251 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv blanks the variables these tests touch; empty counts as unset
+func clearEnv(t *testing.T) {
+	for _, key := range []string{
+		"CONFIG_FILE", "SERVER_PORT", "REDIS_HOST", "REDIS_POOL_SIZE", "PAYMENT_SOURCE_CIDRS",
+		"PAYMENT_MINIMUM_AMOUNT_KOBO", "WORKER_RETRY_MAX_BACKOFF", "CACHE_WARM_ENABLED",
+		"REDIS_MODE", "REDIS_ADDRS", "REDIS_MASTER_NAME", "HTTP_RESPONSE_CACHE_ROUTES",
+		"CUSTOMER_ID_PATTERN", "REPORT_DAILY_ENABLED", "REPORT_DAILY_AT", "REPORT_TIMEZONE",
+		"CURRENCY_SYMBOL", "MYSQL_MAX_OPEN_CONNS", "MYSQL_MAX_IDLE_CONNS", "MYSQL_READ_TIMEOUT",
+		"HTTP_MAINTENANCE_RETRY_AFTER", "LOG_LEVEL", "LOG_ENCODING", "LOG_SAMPLING",
+		"HTTP_IDEMPOTENCY_TTL", "HTTP_IDEMPOTENCY_WAIT", "PAYMENT_UPLOAD_CONCURRENCY", "EVENT_BACKEND",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+const yamlConfig = `
+server:
+  port: "9090"
+  payment_source_cidrs:
+    - 10.0.0.0/8
+    - 192.168.1.0/24
+redis:
+  host: redis.internal
+  pool_size: 20
+payment:
+  minimum_amount_kobo: 10000000
+worker:
+  retry_max_backoff: 1m
+cache_warm:
+  enabled: true
+`
+
+func TestLoad_EnvOnly(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SERVER_PORT", "8081")
+	t.Setenv("PAYMENT_SOURCE_CIDRS", "10.0.0.0/8, ,192.168.1.0/24")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+
+	assert.Equal(t, "8081", cfg.Server.Port)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.0/24"}, cfg.Server.PaymentSourceCIDRs)
+	// Untouched fields keep their defaults
+	assert.Equal(t, "localhost", cfg.Redis.Host)
+	assert.Equal(t, 100, cfg.Redis.PoolSize)
+	assert.Equal(t, 30*24*time.Hour, cfg.Redis.PaymentDedupTTL)
+	assert.True(t, cfg.Redis.CacheEnabled)
+	assert.True(t, cfg.Events.SchemaValidation)
+	assert.False(t, cfg.Events.PublishSync)
+	assert.Equal(t, "redis", cfg.Events.Backend)
+	assert.Equal(t, "events.", cfg.Events.KafkaTopicPrefix)
+	assert.Equal(t, int64(100000), cfg.Events.StreamMaxLen)
+	assert.True(t, cfg.Events.StreamApproxTrim)
+	assert.Equal(t, 5*time.Minute, cfg.Events.ClaimMinIdle)
+	assert.Equal(t, 5*time.Second, cfg.Events.LogSettleWindow)
+	assert.Equal(t, 24*time.Hour, cfg.Events.PublishDedupTTL)
+	assert.Equal(t, `^GIG\d{5}$`, cfg.Server.CustomerIDPattern)
+	assert.Equal(t, "₦", cfg.Server.CurrencySymbol)
+	assert.Equal(t, "NGN", cfg.Payment.DefaultCurrency)
+	assert.Equal(t, "Africa/Lagos", cfg.Payment.Timezone)
+	assert.Equal(t, 10, cfg.Payment.PageSizeDefault)
+	assert.Equal(t, 100, cfg.Payment.PageSizeMax)
+	assert.Equal(t, "global", cfg.Payment.DedupScope)
+	assert.False(t, cfg.CacheWarm.Refresh)
+	assert.Equal(t, "worker", cfg.CacheWarm.RefreshRunner)
+	assert.Equal(t, time.Minute, cfg.CacheWarm.RefreshLead)
+	assert.Equal(t, LogConfig{Level: "info", Encoding: "json", Sampling: true}, cfg.Log)
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
+}
+
+func TestLoad_FileOnly(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", yamlConfig)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "9090", cfg.Server.Port)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.0/24"}, cfg.Server.PaymentSourceCIDRs)
+	assert.Equal(t, "redis.internal", cfg.Redis.Host)
+	assert.Equal(t, 20, cfg.Redis.PoolSize)
+	assert.Equal(t, int64(10000000), cfg.Payment.MinimumAmount)
+	assert.Equal(t, time.Minute, cfg.Worker.RetryMaxBackoff)
+	assert.True(t, cfg.CacheWarm.Enabled)
+	assert.Equal(t, "6379", cfg.Redis.Port, "keys missing from the file keep their defaults")
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", yamlConfig)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("REDIS_HOST", "override.internal")
+	t.Setenv("CACHE_WARM_ENABLED", "false")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+
+	assert.Equal(t, "override.internal", cfg.Redis.Host)
+	assert.False(t, cfg.CacheWarm.Enabled)
+	assert.Equal(t, "9090", cfg.Server.Port, "file value stands where env is unset")
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "config.json", `{
+		"server": {"port": "7070"},
+		"payment": {"minimum_amount_kobo": 123456789012, "slow_threshold": "250ms"}
+	}`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "7070", cfg.Server.Port)
+	assert.Equal(t, int64(123456789012), cfg.Payment.MinimumAmount)
+	assert.Equal(t, 250*time.Millisecond, cfg.Payment.SlowThreshold)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		wantErr string
+	}{
+		{"unknown key", "config.yaml", "server:\n  prot: 9090\n", nil, "server.prot"},
+		{"bad file value", "config.yaml", "redis:\n  pool_size: lots\n", nil, "redis.pool_size"},
+		{"bad env value", "", "", map[string]string{"REDIS_POOL_SIZE": "lots"}, "REDIS_POOL_SIZE"},
+		{"fails validation", "config.yaml", "redis:\n  pool_size: 0\n", nil, "pool size"},
+		{"unsupported extension", "config.toml", "", nil, "extension"},
+		{"unknown redis mode", "", "", map[string]string{"REDIS_MODE": "replicated"}, "redis mode"},
+		{"sentinel without master", "", "", map[string]string{"REDIS_MODE": "sentinel", "REDIS_ADDRS": "s1:26379"}, "master name"},
+		{"cluster without addrs", "", "", map[string]string{"REDIS_MODE": "cluster"}, "cluster addrs"},
+		{"unknown event backend", "", "", map[string]string{"EVENT_BACKEND": "nats"}, "event backend"},
+		{"kafka without brokers", "", "", map[string]string{"EVENT_BACKEND": "kafka"}, "kafka brokers"},
+		{"negative stream max age", "", "", map[string]string{"EVENT_STREAM_MAX_AGE": "-1h"}, "event stream max age"},
+		{"negative overpayment limit", "", "", map[string]string{"PAYMENT_OVERPAYMENT_LIMIT_PERCENT": "-5"}, "overpayment limits"},
+		{"unknown payment timezone", "", "", map[string]string{"PAYMENT_TIMEZONE": "Mars/Olympus"}, "payment timezone"},
+		{"page size default above max", "", "", map[string]string{"PAYMENT_PAGE_SIZE_DEFAULT": "50", "PAYMENT_PAGE_SIZE_MAX": "20"}, "page size"},
+		{"zero default page size", "", "", map[string]string{"PAYMENT_PAGE_SIZE_DEFAULT": "0"}, "page size"},
+		{"early payoff discount over 100%", "", "", map[string]string{"PAYMENT_EARLY_PAYOFF_DISCOUNT_BPS": "10001"}, "early payoff discount"},
+		{"zero event claim min idle", "", "", map[string]string{"EVENT_CLAIM_MIN_IDLE": "0s"}, "event claim min idle"},
+		{"negative event publish dedup TTL", "", "", map[string]string{"EVENT_PUBLISH_DEDUP_TTL": "-1s"}, "event publish dedup TTL"},
+		{"bad customer ID pattern", "", "", map[string]string{"CUSTOMER_ID_PATTERN": "^GIG(\\d+$"}, "customer ID pattern"},
+		{"idle conns above open conns", "", "", map[string]string{"MYSQL_MAX_OPEN_CONNS": "5", "MYSQL_MAX_IDLE_CONNS": "10"}, "max idle conns (10) must not exceed max open conns (5)"},
+		{"negative mysql timeout", "", "", map[string]string{"MYSQL_READ_TIMEOUT": "-1s"}, "mysql timeouts"},
+		{"SMS breaker percent out of range", "", "", map[string]string{"WORKER_SMS_BREAKER_FAILURE_PERCENT": "150"}, "between 0 and 100"},
+		{"unknown cache refresh runner", "", "", map[string]string{"CACHE_REFRESH_ENABLED": "true", "CACHE_REFRESH_RUNNER": "cron"}, "cache refresh runner"},
+		{"cache refresh without cache", "", "", map[string]string{"REDIS_CACHE_ENABLED": "false", "CACHE_REFRESH_ENABLED": "true"}, "need the Redis cache"},
+		{"atomic apply without cache", "", "", map[string]string{"REDIS_CACHE_ENABLED": "false", "PAYMENT_ATOMIC_APPLY": "true"}, "atomic apply"},
+		{"atomic apply on cluster", "", "", map[string]string{"REDIS_MODE": "cluster", "REDIS_ADDRS": "a:7000,b:7000", "PAYMENT_ATOMIC_APPLY": "true"}, "atomic apply"},
+		{"zero cache refresh lead", "", "", map[string]string{"CACHE_REFRESH_ENABLED": "true", "CACHE_REFRESH_LEAD": "0s"}, "cache refresh interval"},
+		{"short heartbeat TTL", "", "", map[string]string{"WORKER_HEARTBEAT_TTL": "2s"}, "heartbeat TTL"},
+		{"zero notification dedup TTL", "", "", map[string]string{"WORKER_NOTIFICATION_DEDUP_TTL": "0s"}, "notification dedup TTL"},
+		{"bad report time", "", "", map[string]string{"REPORT_DAILY_ENABLED": "true", "REPORT_DAILY_AT": "24:30"}, "report daily time"},
+		{"bad report timezone", "", "", map[string]string{"REPORT_DAILY_ENABLED": "true", "REPORT_TIMEZONE": "Lagos"}, "report timezone"},
+		{"sub-second maintenance retry-after", "", "", map[string]string{"HTTP_MAINTENANCE_RETRY_AFTER": "500ms"}, "maintenance retry-after"},
+		{"unknown dedup scope", "", "", map[string]string{"PAYMENT_DEDUP_SCOPE": "provider"}, "dedup scope"},
+		{"zero upload concurrency", "", "", map[string]string{"PAYMENT_UPLOAD_CONCURRENCY": "0"}, "upload concurrency"},
+		{"zero idempotency TTL", "", "", map[string]string{"HTTP_IDEMPOTENCY_TTL": "0s"}, "idempotency TTL"},
+		{"negative idempotency wait", "", "", map[string]string{"HTTP_IDEMPOTENCY_WAIT": "-1s"}, "idempotency wait"},
+		{"zero shutdown timeout", "", "", map[string]string{"HTTP_SHUTDOWN_TIMEOUT": "0s"}, "shutdown timeout"},
+		{"unknown log level", "", "", map[string]string{"LOG_LEVEL": "verbose"}, "log level"},
+		{"unknown log encoding", "", "", map[string]string{"LOG_ENCODING": "logfmt"}, "log encoding"},
+		{"unknown response cache route", "", "", map[string]string{"HTTP_RESPONSE_CACHE_ROUTES": "customer,customers"}, "response cache route"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file, tt.content)
+			}
+
+			_, err := Load(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_MySQLPool(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+mysql:
+  host: db.staging:3306
+  max_open_conns: 10
+  max_idle_conns: 10
+  conn_max_idle_time: 2m
+  read_timeout: 0s
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, cfg.MySQL.MaxOpenConns)
+	assert.Equal(t, 10, cfg.MySQL.MaxIdleConns, "idle may equal open")
+	assert.Equal(t, time.Hour, cfg.MySQL.ConnMaxLifetime)
+	assert.Equal(t, 2*time.Minute, cfg.MySQL.ConnMaxIdleTime)
+	assert.Equal(t,
+		"gigmile:gigmile123@tcp(db.staging:3306)/gigmile?parseTime=true&loc=UTC&time_zone=%27%2B00%3A00%27&timeout=5s&writeTimeout=30s",
+		cfg.MySQL.DSN(), "a zero timeout is left out of the DSN")
+
+	// Unlimited open connections place no cap on idle ones
+	t.Setenv("MYSQL_MAX_OPEN_CONNS", "0")
+	t.Setenv("MYSQL_MAX_IDLE_CONNS", "50")
+	_, err = Load(path)
+	assert.NoError(t, err)
+}
+
+func TestLoad_RedisNodeAddrs(t *testing.T) {
+	clearEnv(t)
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost:6379"}, cfg.Redis.NodeAddrs())
+
+	t.Setenv("REDIS_MODE", "sentinel")
+	t.Setenv("REDIS_MASTER_NAME", "payments")
+	t.Setenv("REDIS_ADDRS", "sentinel-1:26379,sentinel-2:26379")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sentinel-1:26379", "sentinel-2:26379"}, cfg.Redis.NodeAddrs())
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	clearEnv(t)
+	_, err := Load(filepath.Join(t.TempDir(), "nope.yaml"))
+	assert.Error(t, err)
+}
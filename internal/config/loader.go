@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// readFile decodes a .yaml/.yml or .json config file into nested maps
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".json":
+		// UseNumber keeps large integers such as kobo amounts exact
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&file)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// load fills cfg from struct tags. Keys in file that match no field are
+// reported so typos don't silently fall back to defaults.
+func load(cfg interface{}, file map[string]interface{}) error {
+	used := map[string]bool{}
+	if err := loadStruct(reflect.ValueOf(cfg).Elem(), file, "", used); err != nil {
+		return err
+	}
+
+	var unknown []string
+	collectKeys(file, "", func(key string) {
+		if !used[key] {
+			unknown = append(unknown, key)
+		}
+	})
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown config file keys: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+func loadStruct(v reflect.Value, file map[string]interface{}, prefix string, used map[string]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("key")
+		if key == "" {
+			continue
+		}
+		path := prefix + key
+
+		if field.Type.Kind() == reflect.Struct {
+			section, _ := file[key].(map[string]interface{})
+			if err := loadStruct(v.Field(i), section, path+".", used); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := field.Tag.Lookup("default")
+		source := "default"
+		if value, found := file[key]; found {
+			raw, ok, source = fileValue(value), true, "config file key "+path
+			used[path] = true
+		}
+		if env := field.Tag.Get("env"); env != "" {
+			if value := os.Getenv(env); value != "" {
+				raw, ok, source = value, true, env
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := setField(v.Field(i), raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// fileValue flattens a decoded file value into the same string form an
+// env var would carry, so both share one parser
+func fileValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		// Comma-separated, dropping empty entries
+		var values []string
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+	return nil
+}
+
+func collectKeys(file map[string]interface{}, prefix string, fn func(string)) {
+	for key, value := range file {
+		if section, ok := value.(map[string]interface{}); ok {
+			collectKeys(section, prefix+key+".", fn)
+			continue
+		}
+		fn(prefix + key)
+	}
+}
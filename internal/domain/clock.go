@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// Clock tells the current time. Code that stamps or compares times takes
+// one, so tests can freeze it; SystemClock is the real time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function, such as one returning a fixed instant, to Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+// SystemClock reads the wall clock
+var SystemClock Clock = ClockFunc(time.Now)
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrCurrencyMismatch is returned for a payment in a currency other
+	// than the one the customer's loan is held in
+	ErrCurrencyMismatch = errors.New("payment currency does not match the customer's")
+	// ErrAmountTooPrecise is returned for an amount with more decimal
+	// places than its currency's minor unit allows
+	ErrAmountTooPrecise = errors.New("amount has more decimal places than its currency allows")
+)
+
+// Currency is an ISO 4217 currency. Amounts are held as integers in its
+// minor unit, 10^Exponent of which make one major unit.
+type Currency struct {
+	Code     string
+	Exponent int
+	// Symbol prefixes formatted amounts
+	Symbol string
+}
+
+var (
+	CurrencyNGN = Currency{Code: "NGN", Exponent: 2, Symbol: "₦"}
+	CurrencyKES = Currency{Code: "KES", Exponent: 2, Symbol: "KSh"}
+	CurrencyGHS = Currency{Code: "GHS", Exponent: 2, Symbol: "GH₵"}
+	CurrencyUGX = Currency{Code: "UGX", Exponent: 0, Symbol: "USh"}
+	CurrencyXOF = Currency{Code: "XOF", Exponent: 0, Symbol: "CFA"}
+
+	// DefaultCurrency is the currency of loans and payments that don't name
+	// one, which is every loan made before currencies were recorded
+	DefaultCurrency = CurrencyNGN
+)
+
+var currencies = map[string]Currency{
+	CurrencyNGN.Code: CurrencyNGN,
+	CurrencyKES.Code: CurrencyKES,
+	CurrencyGHS.Code: CurrencyGHS,
+	CurrencyUGX.Code: CurrencyUGX,
+	CurrencyXOF.Code: CurrencyXOF,
+}
+
+// LookupCurrency finds a supported currency by its code, in any case
+func LookupCurrency(code string) (Currency, error) {
+	currency, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return currency, nil
+}
+
+// CurrencyFor resolves a stored currency code; empty means DefaultCurrency
+func CurrencyFor(code string) Currency {
+	if code == "" {
+		return DefaultCurrency
+	}
+	if currency, err := LookupCurrency(code); err == nil {
+		return currency
+	}
+	// Not one we know, but it was stored, so show it as it is
+	return Currency{Code: code, Exponent: 2, Symbol: code + " "}
+}
+
+// ParseAmount converts a decimal amount in major units, such as "2500.50"
+// or "2.5e3", to minor units. The conversion is exact: an amount that
+// doesn't come to a whole number of minor units is rejected.
+func (c Currency) ParseAmount(s string) (int64, error) {
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Exponent)), nil)
+	amount.Mul(amount, new(big.Rat).SetInt(scale))
+	if !amount.IsInt() {
+		return 0, fmt.Errorf("%w: %s takes %d", ErrAmountTooPrecise, c.Code, c.Exponent)
+	}
+	if !amount.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	return amount.Num().Int64(), nil
+}
+
+// WholeUnits is amount in whole major units, with any minor units dropped
+func (c Currency) WholeUnits(amount int64) int64 {
+	for i := 0; i < c.Exponent; i++ {
+		amount /= 10
+	}
+	return amount
+}
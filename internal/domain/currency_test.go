@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrency_ParseAmount(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		amount   string
+		want     int64
+	}{
+		{CurrencyNGN, "250000.00", 25000000},
+		{CurrencyNGN, "2.5e5", 25000000},
+		{CurrencyNGN, "0.1", 10},
+		{CurrencyNGN, "19.99", 1999},
+		{CurrencyKES, "1500.5", 150050},
+		{CurrencyUGX, "250000", 250000},
+		{CurrencyUGX, "250000.000", 250000},
+		{CurrencyXOF, "1e3", 1000},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.currency.ParseAmount(tt.amount)
+		require.NoError(t, err, "%s %s", tt.currency.Code, tt.amount)
+		assert.Equal(t, tt.want, got, "%s %s", tt.currency.Code, tt.amount)
+	}
+}
+
+func TestCurrency_ParseAmountRejectsFractionsOfTheMinorUnit(t *testing.T) {
+	_, err := CurrencyNGN.ParseAmount("100.005")
+	assert.ErrorIs(t, err, ErrAmountTooPrecise)
+
+	_, err = CurrencyUGX.ParseAmount("1000.5")
+	assert.ErrorIs(t, err, ErrAmountTooPrecise, "UGX has no minor unit")
+
+	_, err = CurrencyNGN.ParseAmount("ten")
+	assert.Error(t, err)
+
+	_, err = CurrencyNGN.ParseAmount("1e30")
+	assert.Error(t, err)
+}
+
+func TestCurrencyFor(t *testing.T) {
+	assert.Equal(t, DefaultCurrency, CurrencyFor(""), "rows saved before currencies were recorded")
+	assert.Equal(t, CurrencyUGX, CurrencyFor("UGX"))
+
+	unknown := CurrencyFor("ZAR")
+	assert.Equal(t, "ZAR", unknown.Code)
+	assert.Equal(t, 2, unknown.Exponent)
+
+	_, err := LookupCurrency("ZAR")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+	found, err := LookupCurrency(" kes ")
+	require.NoError(t, err)
+	assert.Equal(t, CurrencyKES, found)
+}
+
+func TestCustomer_AcceptsCurrency(t *testing.T) {
+	legacy := &Customer{ID: "GIG00001"}
+	assert.NoError(t, legacy.AcceptsCurrency("NGN"))
+	assert.NoError(t, legacy.AcceptsCurrency(""))
+	assert.ErrorIs(t, legacy.AcceptsCurrency("UGX"), ErrCurrencyMismatch)
+
+	ugandan := &Customer{ID: "GIG00002", CurrencyCode: "UGX"}
+	assert.NoError(t, ugandan.AcceptsCurrency("UGX"))
+	assert.ErrorIs(t, ugandan.AcceptsCurrency("NGN"), ErrCurrencyMismatch)
+}
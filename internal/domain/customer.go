@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -13,12 +14,23 @@ var (
 	ErrDuplicateTransaction  = errors.New("duplicate transaction")
 	ErrInsufficientBalance   = errors.New("insufficient balance for operation")
 	ErrAssetAlreadyOwned     = errors.New("asset already fully owned")
+	ErrBelowMinimumPayment   = errors.New("payment below minimum amount")
+	ErrExcessiveOverpayment  = errors.New("payment exceeds outstanding balance by more than the allowed tolerance")
+	ErrCustomerNotFound      = errors.New("customer not found")
+	ErrCustomerAlreadyExists = errors.New("customer already exists")
+	ErrLoanWrittenOff        = errors.New("loan has been written off")
+	ErrAssetValueBelowPaid   = errors.New("asset value is below the amount already paid")
+	// ErrInvariantViolated wraps every failure reported by Customer.Validate
+	ErrInvariantViolated = errors.New("customer invariant violated")
 )
 
 // Customer represents the aggregate root in DDD
 type Customer struct {
-	ID                 string
-	AssetValue         int64 // in kobo (N1,000,000 = 100,000,000 kobo)
+	ID string
+	// BorrowerID groups loans held by the same person; empty for a
+	// borrower with a single loan
+	BorrowerID         string
+	AssetValue         int64 // in the currency's minor unit (N1,000,000 = 100,000,000 kobo)
 	RepaymentTermWeeks int
 	OutstandingBalance int64
 	TotalPaid          int64
@@ -26,16 +38,31 @@ type Customer struct {
 	LastPaymentDate    *time.Time
 	Status             CustomerStatus
 	Version            int64 // for optimistic locking
+	// CurrencyCode is the ISO 4217 currency the loan is held in; empty
+	// means DefaultCurrency
+	CurrencyCode string
+}
+
+// Currency is the currency the loan and its payments are held in
+func (c *Customer) Currency() Currency {
+	return CurrencyFor(c.CurrencyCode)
 }
 
 type CustomerStatus string
 
 const (
-	CustomerStatusActive    CustomerStatus = "ACTIVE"
-	CustomerStatusCompleted CustomerStatus = "COMPLETED"
-	CustomerStatusDefaulted CustomerStatus = "DEFAULTED"
+	CustomerStatusActive     CustomerStatus = "ACTIVE"
+	CustomerStatusCompleted  CustomerStatus = "COMPLETED"
+	CustomerStatusDefaulted  CustomerStatus = "DEFAULTED"
+	CustomerStatusWrittenOff CustomerStatus = "WRITTEN_OFF"
 )
 
+// CustomerStatuses lists every CustomerStatus, for clients that validate
+// against them
+func CustomerStatuses() []CustomerStatus {
+	return []CustomerStatus{CustomerStatusActive, CustomerStatusCompleted, CustomerStatusDefaulted, CustomerStatusWrittenOff}
+}
+
 // NewCustomer creates a new customer with asset deployment
 func NewCustomer(id string, assetValue int64, termWeeks int, deploymentDate time.Time) (*Customer, error) {
 	if id == "" {
@@ -69,6 +96,9 @@ func (c *Customer) ApplyPayment(amount int64, paymentDate time.Time) error {
 	if c.Status == CustomerStatusCompleted {
 		return ErrAssetAlreadyOwned
 	}
+	if c.Status == CustomerStatusWrittenOff {
+		return ErrLoanWrittenOff
+	}
 
 	// Calculate new balance
 	newBalance := c.OutstandingBalance - amount
@@ -90,6 +120,207 @@ func (c *Customer) ApplyPayment(amount int64, paymentDate time.Time) error {
 	return nil
 }
 
+// ReversePayment takes back amount that ApplyPayment added to TotalPaid.
+// The balance is recomputed from what remains paid, so it can't go above
+// the asset value, and a loan the payment had settled is reopened. Other
+// writers may have changed the customer in between; only this payment's
+// share is removed.
+func (c *Customer) ReversePayment(amount int64) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if c.Status == CustomerStatusWrittenOff {
+		return ErrLoanWrittenOff
+	}
+	if amount > c.TotalPaid {
+		return ErrInsufficientBalance
+	}
+
+	c.TotalPaid -= amount
+	c.OutstandingBalance = max(c.AssetValue-c.TotalPaid, 0)
+	c.ReconcileStatus()
+	return nil
+}
+
+// Overpayment returns how much of amount would exceed the outstanding
+// balance if applied now
+func (c *Customer) Overpayment(amount int64) int64 {
+	if amount <= c.OutstandingBalance {
+		return 0
+	}
+	return amount - c.OutstandingBalance
+}
+
+// ValidatePaymentAmount rejects payments smaller than minimum, unless the
+// payment settles the remaining balance in full. A zero minimum disables the check.
+func (c *Customer) ValidatePaymentAmount(amount int64, minimum int64) error {
+	if minimum <= 0 || amount >= minimum {
+		return nil
+	}
+	if amount >= c.OutstandingBalance {
+		return nil
+	}
+	return ErrBelowMinimumPayment
+}
+
+// OverpaymentLimit caps how far one payment may exceed the outstanding
+// balance, since a payment many times the balance is usually a wrong
+// customer or a misplaced decimal. The excess allowed is the larger of
+// Percent of the balance and Amount, so an exact payoff always passes.
+type OverpaymentLimit struct {
+	Enabled bool
+	Percent int64
+	Amount  int64
+}
+
+// Allowed returns the most a payment may exceed balance by
+func (l OverpaymentLimit) Allowed(balance int64) int64 {
+	return max(balance*l.Percent/100, l.Amount)
+}
+
+// ValidateOverpayment rejects a payment that exceeds the outstanding
+// balance by more than limit allows. A settled loan has no balance to
+// measure against and is left to the settled-payment handling.
+func (c *Customer) ValidateOverpayment(amount int64, limit OverpaymentLimit) error {
+	if !limit.Enabled || c.OutstandingBalance <= 0 {
+		return nil
+	}
+	excess := c.Overpayment(amount)
+	if excess <= limit.Allowed(c.OutstandingBalance) {
+		return nil
+	}
+	return fmt.Errorf("%w: exceeds the balance of %d by %d, at most %d allowed",
+		ErrExcessiveOverpayment, c.OutstandingBalance, excess, limit.Allowed(c.OutstandingBalance))
+}
+
+// AcceptsCurrency rejects a payment in a currency other than the loan's.
+// An empty code is taken to be the loan's own.
+func (c *Customer) AcceptsCurrency(code string) error {
+	if code == "" || code == c.Currency().Code {
+		return nil
+	}
+	return fmt.Errorf("%w: paid in %s, loan is in %s", ErrCurrencyMismatch, code, c.Currency().Code)
+}
+
+// WriteOff forgives the remaining balance, leaving TotalPaid untouched.
+// It returns the amount written off.
+func (c *Customer) WriteOff() (int64, error) {
+	switch c.Status {
+	case CustomerStatusCompleted:
+		return 0, ErrAssetAlreadyOwned
+	case CustomerStatusWrittenOff:
+		return 0, ErrLoanWrittenOff
+	}
+
+	amount := c.OutstandingBalance
+	c.OutstandingBalance = 0
+	c.Status = CustomerStatusWrittenOff
+
+	return amount, nil
+}
+
+// Restructure changes the loan's asset value and repayment term, keeping
+// everything paid so far. The balance is recomputed from TotalPaid, so an
+// asset value equal to it settles the loan. A value below it would leave
+// the customer owed a refund and is rejected with ErrAssetValueBelowPaid.
+func (c *Customer) Restructure(assetValue int64, termWeeks int) error {
+	switch c.Status {
+	case CustomerStatusCompleted:
+		return ErrAssetAlreadyOwned
+	case CustomerStatusWrittenOff:
+		return ErrLoanWrittenOff
+	}
+	if assetValue <= 0 {
+		return errors.New("asset value must be positive")
+	}
+	if termWeeks <= 0 {
+		return errors.New("repayment term must be positive")
+	}
+	if assetValue < c.TotalPaid {
+		return fmt.Errorf("%w: asset value %d, paid %d", ErrAssetValueBelowPaid, assetValue, c.TotalPaid)
+	}
+
+	c.AssetValue = assetValue
+	c.RepaymentTermWeeks = termWeeks
+	c.OutstandingBalance = assetValue - c.TotalPaid
+	c.ReconcileStatus()
+	return nil
+}
+
+// ReconcileStatus derives the status from the balance alone, repairing
+// customers left inconsistent by older code paths, and reports whether it
+// changed anything. A zero balance means COMPLETED; a positive one means
+// ACTIVE unless the customer has defaulted. Write-offs are terminal and
+// never touched.
+func (c *Customer) ReconcileStatus() bool {
+	var want CustomerStatus
+	switch {
+	case c.Status == CustomerStatusWrittenOff:
+		return false
+	case c.OutstandingBalance == 0:
+		want = CustomerStatusCompleted
+	case c.Status == CustomerStatusDefaulted:
+		return false
+	default:
+		want = CustomerStatusActive
+	}
+
+	if c.Status == want {
+		return false
+	}
+	c.Status = want
+	return true
+}
+
+// Validate checks the invariants every path that changes a customer must
+// keep, reporting each one that is broken:
+//   - the balance and the total paid are never negative
+//   - TotalPaid + OutstandingBalance == AssetValue, except that a settled
+//     loan may have been overpaid and a written-off one was forgiven the rest
+//   - a zero balance means COMPLETED or WRITTEN_OFF, and a positive one
+//     means ACTIVE or DEFAULTED
+func (c *Customer) Validate() error {
+	var errs []error
+	violated := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvariantViolated}, args...)...))
+	}
+
+	if c.OutstandingBalance < 0 {
+		violated("outstanding balance %d is negative", c.OutstandingBalance)
+	}
+	if c.TotalPaid < 0 {
+		violated("total paid %d is negative", c.TotalPaid)
+	}
+
+	accounted := c.TotalPaid + c.OutstandingBalance
+	switch {
+	case c.Status == CustomerStatusWrittenOff:
+		if c.TotalPaid >= c.AssetValue {
+			violated("written-off loan was already paid %d of %d", c.TotalPaid, c.AssetValue)
+		}
+	case c.OutstandingBalance == 0 && accounted >= c.AssetValue:
+		// Settled, possibly with an overpayment recorded in TotalPaid
+	case accounted != c.AssetValue:
+		violated("total paid %d plus outstanding %d does not equal asset value %d",
+			c.TotalPaid, c.OutstandingBalance, c.AssetValue)
+	}
+
+	switch c.Status {
+	case CustomerStatusCompleted, CustomerStatusWrittenOff:
+		if c.OutstandingBalance != 0 {
+			violated("status %s with outstanding balance %d", c.Status, c.OutstandingBalance)
+		}
+	case CustomerStatusActive, CustomerStatusDefaulted:
+		if c.OutstandingBalance == 0 {
+			violated("status %s with no outstanding balance", c.Status)
+		}
+	default:
+		violated("unknown status %q", c.Status)
+	}
+
+	return errors.Join(errs...)
+}
+
 // GetPaymentProgress returns the percentage of asset paid
 func (c *Customer) GetPaymentProgress() float64 {
 	if c.AssetValue == 0 {
@@ -98,7 +329,11 @@ func (c *Customer) GetPaymentProgress() float64 {
 	return float64(c.TotalPaid) / float64(c.AssetValue) * 100
 }
 
-// IsFullyPaid checks if the customer has fully paid for the asset
+// IsFullyPaid checks if the customer has fully paid for the asset.
+// A written-off loan has no balance but was never paid off.
 func (c *Customer) IsFullyPaid() bool {
+	if c.Status == CustomerStatusWrittenOff {
+		return false
+	}
 	return c.OutstandingBalance == 0 || c.Status == CustomerStatusCompleted
 }
@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomer_ReconcileStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      CustomerStatus
+		balance     int64
+		wantStatus  CustomerStatus
+		wantChanged bool
+	}{
+		{"active with zero balance", CustomerStatusActive, 0, CustomerStatusCompleted, true},
+		{"completed with balance", CustomerStatusCompleted, 500, CustomerStatusActive, true},
+		{"blank status with balance", "", 500, CustomerStatusActive, true},
+		{"blank status with zero balance", "", 0, CustomerStatusCompleted, true},
+		{"defaulted and paid off", CustomerStatusDefaulted, 0, CustomerStatusCompleted, true},
+		{"defaulted with balance", CustomerStatusDefaulted, 500, CustomerStatusDefaulted, false},
+		{"written off", CustomerStatusWrittenOff, 0, CustomerStatusWrittenOff, false},
+		{"written off with balance", CustomerStatusWrittenOff, 500, CustomerStatusWrittenOff, false},
+		{"consistent active", CustomerStatusActive, 500, CustomerStatusActive, false},
+		{"consistent completed", CustomerStatusCompleted, 0, CustomerStatusCompleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Customer{ID: "GIG00001", Status: tt.status, OutstandingBalance: tt.balance}
+
+			assert.Equal(t, tt.wantChanged, c.ReconcileStatus())
+			assert.Equal(t, tt.wantStatus, c.Status)
+			assert.False(t, c.ReconcileStatus(), "second run must be a no-op")
+		})
+	}
+}
+
+func TestCustomer_Validate(t *testing.T) {
+	valid := func() *Customer {
+		return &Customer{ID: "GIG00001", AssetValue: 1000, OutstandingBalance: 600, TotalPaid: 400, Status: CustomerStatusActive}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Customer)
+		broken string
+	}{
+		{"valid active", func(c *Customer) {}, ""},
+		{"valid defaulted", func(c *Customer) { c.Status = CustomerStatusDefaulted }, ""},
+		{"valid completed", func(c *Customer) { c.OutstandingBalance, c.TotalPaid, c.Status = 0, 1000, CustomerStatusCompleted }, ""},
+		{"overpaid completed", func(c *Customer) { c.OutstandingBalance, c.TotalPaid, c.Status = 0, 1250, CustomerStatusCompleted }, ""},
+		{"written off", func(c *Customer) { c.OutstandingBalance, c.Status = 0, CustomerStatusWrittenOff }, ""},
+		{"negative balance", func(c *Customer) { c.OutstandingBalance, c.TotalPaid = -100, 1100 }, "outstanding balance -100 is negative"},
+		{"negative total paid", func(c *Customer) { c.OutstandingBalance, c.TotalPaid = 1100, -100 }, "total paid -100 is negative"},
+		{"totals do not add up", func(c *Customer) { c.TotalPaid = 300 }, "does not equal asset value"},
+		{"underpaid with no balance", func(c *Customer) { c.OutstandingBalance, c.Status = 0, CustomerStatusCompleted }, "does not equal asset value"},
+		{"written off after full payment", func(c *Customer) { c.OutstandingBalance, c.TotalPaid, c.Status = 0, 1000, CustomerStatusWrittenOff }, "written-off loan"},
+		{"completed with balance", func(c *Customer) { c.Status = CustomerStatusCompleted }, "status COMPLETED with outstanding balance 600"},
+		{"written off with balance", func(c *Customer) { c.Status = CustomerStatusWrittenOff }, "status WRITTEN_OFF with outstanding balance 600"},
+		{"active with no balance", func(c *Customer) { c.OutstandingBalance, c.TotalPaid = 0, 1000 }, "status ACTIVE with no outstanding balance"},
+		{"unknown status", func(c *Customer) { c.Status = "" }, `unknown status ""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+
+			err := c.Validate()
+			if tt.broken == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvariantViolated)
+			assert.ErrorContains(t, err, tt.broken)
+		})
+	}
+}
+
+func TestCustomer_ValidateReportsEveryViolation(t *testing.T) {
+	c := &Customer{ID: "GIG00001", AssetValue: 1000, OutstandingBalance: -5, TotalPaid: -5, Status: CustomerStatusCompleted}
+
+	err := c.Validate()
+
+	assert.ErrorContains(t, err, "outstanding balance -5 is negative")
+	assert.ErrorContains(t, err, "total paid -5 is negative")
+	assert.ErrorContains(t, err, "does not equal asset value")
+	assert.ErrorContains(t, err, "status COMPLETED with outstanding balance -5")
+}
+
+func TestCustomer_PaymentsKeepInvariants(t *testing.T) {
+	c, err := NewCustomer("GIG00001", 1000, 10, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, c.Validate())
+
+	assert.NoError(t, c.ApplyPayment(400, time.Now()))
+	assert.NoError(t, c.Validate())
+
+	assert.NoError(t, c.ApplyPayment(900, time.Now()))
+	assert.NoError(t, c.Validate(), "overpayment")
+	assert.Equal(t, CustomerStatusCompleted, c.Status)
+
+	d, _ := NewCustomer("GIG00002", 1000, 10, time.Now())
+	assert.NoError(t, d.ApplyPayment(250, time.Now()))
+	_, err = d.WriteOff()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Validate())
+}
+
+func TestCustomer_ReversePayment(t *testing.T) {
+	c, _ := NewCustomer("GIG00001", 1000, 10, time.Now())
+	assert.NoError(t, c.ApplyPayment(400, time.Now()))
+	assert.NoError(t, c.ApplyPayment(400, time.Now()))
+
+	assert.NoError(t, c.ReversePayment(400))
+	assert.Equal(t, int64(400), c.TotalPaid)
+	assert.Equal(t, int64(600), c.OutstandingBalance)
+	assert.NoError(t, c.Validate())
+
+	assert.NoError(t, c.ApplyPayment(900, time.Now()))
+	assert.Equal(t, CustomerStatusCompleted, c.Status)
+	assert.NoError(t, c.ReversePayment(900))
+	assert.Equal(t, CustomerStatusActive, c.Status, "a loan the payment settled is reopened")
+	assert.Equal(t, int64(600), c.OutstandingBalance)
+	assert.NoError(t, c.Validate())
+
+	assert.ErrorIs(t, c.ReversePayment(500), ErrInsufficientBalance)
+	assert.ErrorIs(t, c.ReversePayment(0), ErrInvalidAmount)
+	_, _ = c.WriteOff()
+	assert.ErrorIs(t, c.ReversePayment(100), ErrLoanWrittenOff)
+}
+
+func TestCustomer_ValidateOverpayment(t *testing.T) {
+	limit := OverpaymentLimit{Enabled: true, Percent: 10, Amount: 5000}
+	tests := []struct {
+		name    string
+		balance int64
+		amount  int64
+		limit   OverpaymentLimit
+		want    error
+	}{
+		{"under the balance", 100000, 40000, limit, nil},
+		{"exact payoff", 100000, 100000, limit, nil},
+		{"within the percentage", 100000, 110000, limit, nil},
+		{"past the percentage", 100000, 110001, limit, ErrExcessiveOverpayment},
+		{"ten times the balance", 100000, 1000000, limit, ErrExcessiveOverpayment},
+		{"small balance uses the amount", 1000, 6000, limit, nil},
+		{"past the amount", 1000, 6001, limit, ErrExcessiveOverpayment},
+		{"exact payoff only", 1000, 1001, OverpaymentLimit{Enabled: true}, ErrExcessiveOverpayment},
+		{"disabled", 1000, 1000000, OverpaymentLimit{Percent: 10}, nil},
+		{"settled loan", 0, 1000000, limit, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Customer{ID: "GIG00001", OutstandingBalance: tt.balance}
+
+			err := c.ValidateOverpayment(tt.amount, tt.limit)
+
+			if tt.want == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomer_Restructure(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      CustomerStatus
+		assetValue  int64
+		termWeeks   int
+		wantBalance int64
+		wantStatus  CustomerStatus
+		wantErr     error
+	}{
+		{"discount reduces the balance", CustomerStatusActive, 80000000, 52, 50000000, CustomerStatusActive, nil},
+		{"term extension keeps the balance", CustomerStatusActive, 100000000, 78, 70000000, CustomerStatusActive, nil},
+		{"defaulted stays defaulted", CustomerStatusDefaulted, 90000000, 52, 60000000, CustomerStatusDefaulted, nil},
+		{"value equal to paid settles the loan", CustomerStatusActive, 30000000, 52, 0, CustomerStatusCompleted, nil},
+		{"value below paid is rejected", CustomerStatusActive, 29999999, 52, 70000000, CustomerStatusActive, ErrAssetValueBelowPaid},
+		{"completed loan is rejected", CustomerStatusCompleted, 80000000, 52, 70000000, CustomerStatusCompleted, ErrAssetAlreadyOwned},
+		{"written-off loan is rejected", CustomerStatusWrittenOff, 80000000, 52, 70000000, CustomerStatusWrittenOff, ErrLoanWrittenOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Customer{ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 52, OutstandingBalance: 70000000, TotalPaid: 30000000, Status: tt.status}
+
+			err := c.Restructure(tt.assetValue, tt.termWeeks)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, int64(100000000), c.AssetValue, "a rejected restructure changes nothing")
+				assert.Equal(t, 52, c.RepaymentTermWeeks)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.assetValue, c.AssetValue)
+				assert.Equal(t, tt.termWeeks, c.RepaymentTermWeeks)
+				assert.NoError(t, c.Validate())
+			}
+			assert.Equal(t, tt.wantBalance, c.OutstandingBalance)
+			assert.Equal(t, int64(30000000), c.TotalPaid)
+			assert.Equal(t, tt.wantStatus, c.Status)
+		})
+	}
+}
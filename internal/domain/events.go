@@ -2,6 +2,10 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +17,11 @@ const (
 	EventTypePaymentProcessed = "payment.processed"
 	EventTypePaymentFailed    = "payment.failed"
 	EventTypeCustomerUpdated  = "customer.updated"
+	EventTypePaymentFlagged   = "payment.flagged"
+	// EventTypePaymentOverpaid says where the excess of an overpayment went
+	EventTypePaymentOverpaid = "payment.overpaid"
+	// EventTypeReconciliationDaily carries the end-of-day payment summary
+	EventTypeReconciliationDaily = "reconciliation.daily"
 )
 
 // DomainEvent represents a domain event
@@ -21,6 +30,8 @@ type DomainEvent interface {
 	GetEventType() string
 	GetAggregateID() string
 	GetOccurredAt() time.Time
+	GetCorrelationID() string
+	GetSequence() int64
 	GetPayload() interface{}
 }
 
@@ -30,12 +41,64 @@ type BaseEvent struct {
 	EventType   string    `json:"event_type"`
 	AggregateID string    `json:"aggregate_id"`
 	OccurredAt  time.Time `json:"occurred_at"`
+	// CorrelationID ties the event back to the API request that caused it
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Sequence is the aggregate's Version after the change the event
+	// reports. It grows with every change to the aggregate, so an event
+	// with a lower sequence than one already seen for the same aggregate
+	// was delivered out of order. Events from the same change share it;
+	// zero on events that report no change.
+	Sequence int64 `json:"sequence,omitempty"`
 }
 
 func (e BaseEvent) GetEventID() string       { return e.EventID }
 func (e BaseEvent) GetEventType() string     { return e.EventType }
 func (e BaseEvent) GetAggregateID() string   { return e.AggregateID }
 func (e BaseEvent) GetOccurredAt() time.Time { return e.OccurredAt }
+func (e BaseEvent) GetCorrelationID() string { return e.CorrelationID }
+func (e BaseEvent) GetSequence() int64       { return e.Sequence }
+
+type correlationIDKey struct{}
+
+// ContextWithCorrelationID returns a copy of ctx carrying the correlation ID
+func ContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any
+func CorrelationIDFromContext(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
+
+// PaymentReceivedEvent - Raw inbound payment, before dedup or processing
+type PaymentReceivedEvent struct {
+	BaseEvent
+	Payload PaymentReceivedPayload `json:"payload"`
+}
+
+func (e PaymentReceivedEvent) GetPayload() interface{} { return e.Payload }
+
+type PaymentReceivedPayload struct {
+	CustomerID           string    `json:"customer_id"`
+	PaymentStatus        string    `json:"payment_status"`
+	TransactionReference string    `json:"transaction_reference"`
+	Amount               int64     `json:"amount"`
+	TransactionDate      time.Time `json:"transaction_date"`
+	ReceivedAt           time.Time `json:"received_at"`
+}
+
+func NewPaymentReceivedEvent(customerID string, payload PaymentReceivedPayload, occurredAt time.Time) *PaymentReceivedEvent {
+	return &PaymentReceivedEvent{
+		BaseEvent: BaseEvent{
+			EventID:     uuid.New().String(),
+			EventType:   EventTypePaymentReceived,
+			AggregateID: customerID,
+			OccurredAt:  occurredAt,
+		},
+		Payload: payload,
+	}
+}
 
 // PaymentProcessedEvent - Payment successfully applied
 type PaymentProcessedEvent struct {
@@ -54,15 +117,235 @@ type PaymentProcessedPayload struct {
 	PaymentProgress      float64   `json:"payment_progress"`
 	IsFullyPaid          bool      `json:"is_fully_paid"`
 	ProcessedAt          time.Time `json:"processed_at"`
+	// Currency is the ISO 4217 code of the amounts; absent on events
+	// published before it was added, which are in DefaultCurrency
+	Currency string `json:"currency,omitempty"`
+	// MoneyAsStrings encodes Amount, OutstandingBalance and TotalPaid as
+	// decimal strings, for consumers that parse JSON numbers as doubles and
+	// lose precision past 2^53. Decoding accepts either form.
+	MoneyAsStrings bool `json:"-"`
+}
+
+// paymentProcessedJSON is PaymentProcessedPayload without its JSON methods
+type paymentProcessedJSON PaymentProcessedPayload
+
+func (p PaymentProcessedPayload) MarshalJSON() ([]byte, error) {
+	if !p.MoneyAsStrings {
+		return json.Marshal(paymentProcessedJSON(p))
+	}
+	return json.Marshal(struct {
+		paymentProcessedJSON
+		Amount             int64 `json:"amount,string"`
+		OutstandingBalance int64 `json:"outstanding_balance,string"`
+		TotalPaid          int64 `json:"total_paid,string"`
+	}{paymentProcessedJSON(p), p.Amount, p.OutstandingBalance, p.TotalPaid})
 }
 
-func NewPaymentProcessedEvent(customerID string, payload PaymentProcessedPayload) *PaymentProcessedEvent {
+func (p *PaymentProcessedPayload) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		paymentProcessedJSON
+		Amount             json.RawMessage `json:"amount"`
+		OutstandingBalance json.RawMessage `json:"outstanding_balance"`
+		TotalPaid          json.RawMessage `json:"total_paid"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = PaymentProcessedPayload(raw.paymentProcessedJSON)
+	for _, field := range []struct {
+		name  string
+		raw   json.RawMessage
+		value *int64
+	}{
+		{"amount", raw.Amount, &p.Amount},
+		{"outstanding_balance", raw.OutstandingBalance, &p.OutstandingBalance},
+		{"total_paid", raw.TotalPaid, &p.TotalPaid},
+	} {
+		value, quoted, err := decodeMoney(field.raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.name, err)
+		}
+		*field.value = value
+		p.MoneyAsStrings = p.MoneyAsStrings || quoted
+	}
+	return nil
+}
+
+// decodeMoney reads a minor-unit amount written either as a JSON integer
+// or as a decimal string, reporting which. A missing field is zero.
+func decodeMoney(raw json.RawMessage) (int64, bool, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, false, nil
+	}
+
+	text, quoted := string(raw), false
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return 0, false, err
+		}
+		quoted = true
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid amount %s", raw)
+	}
+	return value, quoted, nil
+}
+
+func NewPaymentProcessedEvent(customerID string, payload PaymentProcessedPayload, occurredAt time.Time) *PaymentProcessedEvent {
 	return &PaymentProcessedEvent{
 		BaseEvent: BaseEvent{
 			EventID:     uuid.New().String(),
 			EventType:   EventTypePaymentProcessed,
 			AggregateID: customerID,
-			OccurredAt:  time.Now(),
+			OccurredAt:  occurredAt,
+		},
+		Payload: payload,
+	}
+}
+
+// CustomerUpdatedEvent - Customer account changed outside the payment flow
+type CustomerUpdatedEvent struct {
+	BaseEvent
+	Payload CustomerUpdatedPayload `json:"payload"`
+}
+
+func (e CustomerUpdatedEvent) GetPayload() interface{} { return e.Payload }
+
+type CustomerUpdatedPayload struct {
+	CustomerID         string    `json:"customer_id"`
+	PreviousStatus     string    `json:"previous_status"`
+	Status             string    `json:"status"`
+	OutstandingBalance int64     `json:"outstanding_balance"`
+	TotalPaid          int64     `json:"total_paid"`
+	Reason             string    `json:"reason,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	// AssetValue and RepaymentTermWeeks are the loan's new terms, set only
+	// when they changed
+	AssetValue         int64 `json:"asset_value,omitempty"`
+	RepaymentTermWeeks int   `json:"repayment_term_weeks,omitempty"`
+}
+
+func NewCustomerUpdatedEvent(customerID string, payload CustomerUpdatedPayload, occurredAt time.Time) *CustomerUpdatedEvent {
+	return &CustomerUpdatedEvent{
+		BaseEvent: BaseEvent{
+			EventID:     uuid.New().String(),
+			EventType:   EventTypeCustomerUpdated,
+			AggregateID: customerID,
+			OccurredAt:  occurredAt,
+		},
+		Payload: payload,
+	}
+}
+
+// PaymentFlaggedEvent - Payment applied but part of a suspicious burst
+type PaymentFlaggedEvent struct {
+	BaseEvent
+	Payload PaymentFlaggedPayload `json:"payload"`
+}
+
+func (e PaymentFlaggedEvent) GetPayload() interface{} { return e.Payload }
+
+type PaymentFlaggedPayload struct {
+	CustomerID           string    `json:"customer_id"`
+	TransactionReference string    `json:"transaction_reference"`
+	Amount               int64     `json:"amount"`
+	WindowPaymentCount   int64     `json:"window_payment_count"`
+	WindowTotalAmount    int64     `json:"window_total_amount"`
+	WindowStart          time.Time `json:"window_start"`
+	WindowSeconds        int64     `json:"window_seconds"`
+	Reasons              []string  `json:"reasons"`
+	FlaggedAt            time.Time `json:"flagged_at"`
+}
+
+func NewPaymentFlaggedEvent(customerID string, payload PaymentFlaggedPayload, occurredAt time.Time) *PaymentFlaggedEvent {
+	return &PaymentFlaggedEvent{
+		BaseEvent: BaseEvent{
+			EventID:     uuid.New().String(),
+			EventType:   EventTypePaymentFlagged,
+			AggregateID: customerID,
+			OccurredAt:  occurredAt,
+		},
+		Payload: payload,
+	}
+}
+
+// Where an overpayment's excess ended up
+const (
+	OverpaymentIgnored  = "ignored"
+	OverpaymentCredited = "credited"
+	OverpaymentApplied  = "applied_to_loan"
+)
+
+// PaymentOverpaidEvent - Payment exceeded the balance it settled
+type PaymentOverpaidEvent struct {
+	BaseEvent
+	Payload PaymentOverpaidPayload `json:"payload"`
+}
+
+func (e PaymentOverpaidEvent) GetPayload() interface{} { return e.Payload }
+
+// PaymentOverpaidPayload splits Excess between AppliedAmount on
+// TargetCustomerID's loan and CreditedAmount. Destination is where the
+// excess went first; when it was ignored both amounts are zero.
+type PaymentOverpaidPayload struct {
+	CustomerID           string    `json:"customer_id"`
+	TransactionReference string    `json:"transaction_reference"`
+	Excess               int64     `json:"excess"`
+	Policy               string    `json:"policy"`
+	Destination          string    `json:"destination"`
+	AppliedAmount        int64     `json:"applied_amount"`
+	TargetCustomerID     string    `json:"target_customer_id,omitempty"`
+	CreditedAmount       int64     `json:"credited_amount"`
+	OccurredAt           time.Time `json:"occurred_at"`
+}
+
+func NewPaymentOverpaidEvent(customerID string, payload PaymentOverpaidPayload, occurredAt time.Time) *PaymentOverpaidEvent {
+	return &PaymentOverpaidEvent{
+		BaseEvent: BaseEvent{
+			EventID:     uuid.New().String(),
+			EventType:   EventTypePaymentOverpaid,
+			AggregateID: customerID,
+			OccurredAt:  occurredAt,
+		},
+		Payload: payload,
+	}
+}
+
+// DailyReconciliationEvent - Summary of one local day's payments
+type DailyReconciliationEvent struct {
+	BaseEvent
+	Payload DailyReconciliationPayload `json:"payload"`
+}
+
+func (e DailyReconciliationEvent) GetPayload() interface{} { return e.Payload }
+
+// DailyReconciliationPayload counts every inbound payment for ReportDate by
+// outcome. TotalCount is their sum; TotalAmount is what was completed, in kobo.
+// ReportDate and Timezone identify the report, so consumers can drop repeats.
+type DailyReconciliationPayload struct {
+	ReportDate     string    `json:"report_date"`
+	Timezone       string    `json:"timezone"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	TotalCount     int64     `json:"total_count"`
+	TotalAmount    int64     `json:"total_amount"`
+	CompletedCount int64     `json:"completed_count"`
+	DuplicateCount int64     `json:"duplicate_count"`
+	FailedCount    int64     `json:"failed_count"`
+	GeneratedAt    time.Time `json:"generated_at"`
+}
+
+// NewDailyReconciliationEvent uses the report date as the aggregate, since
+// the report is about a day rather than a customer
+func NewDailyReconciliationEvent(payload DailyReconciliationPayload, occurredAt time.Time) *DailyReconciliationEvent {
+	return &DailyReconciliationEvent{
+		BaseEvent: BaseEvent{
+			EventID:     uuid.New().String(),
+			EventType:   EventTypeReconciliationDaily,
+			AggregateID: payload.ReportDate,
+			OccurredAt:  occurredAt,
 		},
 		Payload: payload,
 	}
@@ -80,3 +363,28 @@ type EventSubscriber interface {
 
 // EventHandler processes events
 type EventHandler func(ctx context.Context, event DomainEvent) error
+
+// ErrEventInProgress means another delivery of the event is still being handled
+var ErrEventInProgress = errors.New("event is already being handled")
+
+// HandledEvents remembers which events a handler has dealt with, so
+// at-least-once delivery can be made effectively once
+type HandledEvents interface {
+	// Claim marks eventID as being handled. It returns false if the event
+	// was already handled, and ErrEventInProgress while another claim holds.
+	Claim(ctx context.Context, eventID string) (bool, error)
+	// Complete records eventID as handled
+	Complete(ctx context.Context, eventID string) error
+	// Release drops the claim so a redelivery can try again
+	Release(ctx context.Context, eventID string) error
+}
+
+// EventSequences remembers the highest BaseEvent.Sequence a handler has
+// seen per aggregate, so it can recognize an event delivered after a
+// later one
+type EventSequences interface {
+	// Observe records sequence for the aggregate and reports whether a
+	// higher one was already observed. Seeing the same sequence again, as
+	// on a redelivery, is not stale.
+	Observe(ctx context.Context, aggregateID string, sequence int64) (stale bool, err error)
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentProcessedPayload_MoneyRoundTrips(t *testing.T) {
+	// Past 2^53, where a double can no longer hold every integer
+	const large = int64(9007199254740993)
+	processedAt := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		strings bool
+		want    string
+	}{
+		{"numbers", false, `"amount":9007199254740993,"outstanding_balance":0,"total_paid":9007199254740993`},
+		{"strings", true, `"amount":"9007199254740993","outstanding_balance":"0","total_paid":"9007199254740993"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := PaymentProcessedPayload{
+				CustomerID:           "GIG00001",
+				TransactionReference: "TXN001",
+				Amount:               large,
+				OutstandingBalance:   0,
+				TotalPaid:            large,
+				PaymentProgress:      100,
+				IsFullyPaid:          true,
+				ProcessedAt:          processedAt,
+				Currency:             "NGN",
+				MoneyAsStrings:       tc.strings,
+			}
+
+			data, err := json.Marshal(payload)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), tc.want)
+			assert.NotContains(t, string(data), "MoneyAsStrings")
+
+			var decoded PaymentProcessedPayload
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, payload, decoded)
+		})
+	}
+}
+
+func TestPaymentProcessedPayload_RejectsMalformedMoney(t *testing.T) {
+	for _, data := range []string{
+		`{"amount":"12.50"}`,
+		`{"amount":1.5}`,
+		`{"total_paid":"abc"}`,
+	} {
+		var decoded PaymentProcessedPayload
+		assert.Error(t, json.Unmarshal([]byte(data), &decoded), data)
+	}
+}
+
+func TestPaymentProcessedEvent_DecodesStringMoney(t *testing.T) {
+	event := NewPaymentProcessedEvent("GIG00001", PaymentProcessedPayload{
+		CustomerID:     "GIG00001",
+		Amount:         250000000,
+		TotalPaid:      250000000,
+		MoneyAsStrings: true,
+	}, time.Now())
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"amount":"250000000"`)
+
+	var decoded PaymentProcessedEvent
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, int64(250000000), decoded.Payload.Amount)
+	assert.Equal(t, event.EventID, decoded.EventID)
+}
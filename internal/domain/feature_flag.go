@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrFeatureFlagNotFound = errors.New("feature flag not found")
+	ErrInvalidFeatureFlag  = errors.New("invalid feature flag")
+)
+
+// featureFlagNamePattern keeps flag names to what fits in a URL and a
+// Redis hash field unescaped
+var featureFlagNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// FeatureFlag switches a behaviour on for some or all customers. Off, it
+// applies to nobody. On, it applies to RolloutPercent of customers, chosen
+// by hashing the flag name with the customer ID, so a customer stays in as
+// the percentage grows and each flag picks its own cohort. A boolean flag
+// is one at 100%.
+type FeatureFlag struct {
+	Name           string    `json:"name"`
+	Enabled        bool      `json:"enabled"`
+	RolloutPercent int       `json:"rollout_percent"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Validate checks the name and that RolloutPercent is between 0 and 100
+func (f *FeatureFlag) Validate() error {
+	if !featureFlagNamePattern.MatchString(f.Name) {
+		return fmt.Errorf("%w: name must be lowercase letters, digits and underscores, starting with a letter", ErrInvalidFeatureFlag)
+	}
+	if f.RolloutPercent < 0 || f.RolloutPercent > 100 {
+		return fmt.Errorf("%w: rollout percent must be between 0 and 100", ErrInvalidFeatureFlag)
+	}
+	return nil
+}
+
+// EnabledFor reports whether the flag applies to the customer
+func (f *FeatureFlag) EnabledFor(customerID string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if f.RolloutPercent >= 100 {
+		return true
+	}
+	return RolloutBucket(f.Name, customerID) < f.RolloutPercent
+}
+
+// RolloutBucket places a customer in one of 100 buckets for the named
+// flag. The same inputs always land in the same bucket.
+func RolloutBucket(flagName, customerID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flagName))
+	h.Write([]byte{0})
+	h.Write([]byte(customerID))
+	return int(h.Sum32() % 100)
+}
+
+// FeatureFlags answers whether a flag applies to a customer. A flag that
+// isn't set, or can't be read, answers fallback.
+type FeatureFlags interface {
+	Enabled(ctx context.Context, name, customerID string, fallback bool) bool
+}
+
+// FeatureFlagStore manages the flags FeatureFlags answers from
+type FeatureFlagStore interface {
+	FeatureFlags
+	Get(ctx context.Context, name string) (*FeatureFlag, error)
+	List(ctx context.Context) ([]*FeatureFlag, error)
+	Set(ctx context.Context, flag *FeatureFlag) error
+	Delete(ctx context.Context, name string) error
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlag_Boolean(t *testing.T) {
+	on := &FeatureFlag{Name: "sync_event_publishing", Enabled: true, RolloutPercent: 100}
+	off := &FeatureFlag{Name: "sync_event_publishing", Enabled: false, RolloutPercent: 100}
+
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("GIG%05d", i)
+		assert.True(t, on.EnabledFor(id))
+		assert.False(t, off.EnabledFor(id))
+	}
+}
+
+func TestFeatureFlag_PercentageIsDeterministicAndMonotonic(t *testing.T) {
+	assert.Equal(t, RolloutBucket("atomic_apply", "GIG00001"), RolloutBucket("atomic_apply", "GIG00001"))
+
+	at10 := &FeatureFlag{Name: "atomic_apply", Enabled: true, RolloutPercent: 10}
+	at50 := &FeatureFlag{Name: "atomic_apply", Enabled: true, RolloutPercent: 50}
+	zero := &FeatureFlag{Name: "atomic_apply", Enabled: true, RolloutPercent: 0}
+
+	in10, in50 := 0, 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("GIG%05d", i)
+		if at10.EnabledFor(id) {
+			in10++
+			assert.True(t, at50.EnabledFor(id), "%s must stay in as the rollout grows", id)
+		}
+		if at50.EnabledFor(id) {
+			in50++
+		}
+		assert.False(t, zero.EnabledFor(id))
+	}
+	assert.InDelta(t, 1000, in10, 150)
+	assert.InDelta(t, 5000, in50, 300)
+}
+
+func TestFeatureFlag_CohortsDifferByFlag(t *testing.T) {
+	a := &FeatureFlag{Name: "atomic_apply", Enabled: true, RolloutPercent: 50}
+	b := &FeatureFlag{Name: "velocity_check", Enabled: true, RolloutPercent: 50}
+
+	differ := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("GIG%05d", i)
+		if a.EnabledFor(id) != b.EnabledFor(id) {
+			differ++
+		}
+	}
+	assert.Greater(t, differ, 300)
+}
+
+func TestFeatureFlag_Validate(t *testing.T) {
+	assert.NoError(t, (&FeatureFlag{Name: "atomic_apply", RolloutPercent: 25}).Validate())
+	assert.ErrorIs(t, (&FeatureFlag{Name: "Atomic-Apply", RolloutPercent: 25}).Validate(), ErrInvalidFeatureFlag)
+	assert.ErrorIs(t, (&FeatureFlag{Name: "", RolloutPercent: 25}).Validate(), ErrInvalidFeatureFlag)
+	assert.ErrorIs(t, (&FeatureFlag{Name: "atomic_apply", RolloutPercent: 101}).Validate(), ErrInvalidFeatureFlag)
+	assert.ErrorIs(t, (&FeatureFlag{Name: "atomic_apply", RolloutPercent: -1}).Validate(), ErrInvalidFeatureFlag)
+}
@@ -2,21 +2,74 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
 type Payment struct {
 	ID                   string
 	CustomerID           string
-	Amount               int64 // in kobo
+	Amount               int64 // in the currency's minor unit
 	TransactionReference string
 	TransactionDate      time.Time
 	Status               PaymentStatus
 	ProcessedAt          time.Time
 	CreatedAt            time.Time
+	// BalanceAfter is the customer's outstanding balance once this payment
+	// was applied, so the row describes the balance it produced. It is nil
+	// for payments recorded before it was captured.
+	BalanceAfter *int64
+	// CurrencyCode is the customer's currency when the payment was
+	// recorded; empty on payments recorded before currencies were
+	CurrencyCode string
+	// Metadata holds extra fields from the provider, such as the channel or
+	// payer name, kept for reconciliation; nil when none were sent
+	Metadata map[string]string
 }
 
-var ErrOptimisticLock = errors.New("version mismatch - optimistic lock failed")
+// Currency is the currency Amount and BalanceAfter are in
+func (p *Payment) Currency() Currency {
+	return CurrencyFor(p.CurrencyCode)
+}
+
+var (
+	ErrOptimisticLock  = errors.New("version mismatch - optimistic lock failed")
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrInvalidMetadata = errors.New("invalid payment metadata")
+)
+
+// Bounds on Metadata, so provider fields can't bloat the payments table
+const (
+	MaxPaymentMetadataEntries     = 20
+	MaxPaymentMetadataKeyLength   = 64
+	MaxPaymentMetadataValueLength = 256
+)
+
+// ValidatePaymentMetadata checks metadata against the size bounds. Keys
+// must not be blank.
+func ValidatePaymentMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxPaymentMetadataEntries {
+		return fmt.Errorf("%w: must have at most %d entries", ErrInvalidMetadata, MaxPaymentMetadataEntries)
+	}
+	for key, value := range metadata {
+		switch {
+		case strings.TrimSpace(key) == "":
+			return fmt.Errorf("%w: keys must not be blank", ErrInvalidMetadata)
+		case len(key) > MaxPaymentMetadataKeyLength:
+			return fmt.Errorf("%w: keys must be at most %d bytes", ErrInvalidMetadata, MaxPaymentMetadataKeyLength)
+		case len(value) > MaxPaymentMetadataValueLength:
+			return fmt.Errorf("%w: value of %q must be at most %d bytes", ErrInvalidMetadata, key, MaxPaymentMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// PaymentTotals summarizes a set of payments; Amount is in kobo
+type PaymentTotals struct {
+	Count  int64
+	Amount int64
+}
 
 type PaymentStatus string
 
@@ -25,9 +78,19 @@ const (
 	PaymentStatusComplete  PaymentStatus = "COMPLETE"
 	PaymentStatusFailed    PaymentStatus = "FAILED"
 	PaymentStatusDuplicate PaymentStatus = "DUPLICATE"
+	// PaymentStatusWriteOff marks an audit row recording a forgiven balance
+	PaymentStatusWriteOff PaymentStatus = "WRITEOFF"
 )
 
-func NewPayment(customerID string, amount int64, transactionRef string, transactionDate time.Time, status PaymentStatus) (*Payment, error) {
+// PaymentStatuses lists every PaymentStatus, for clients that validate
+// against them
+func PaymentStatuses() []PaymentStatus {
+	return []PaymentStatus{PaymentStatusPending, PaymentStatusComplete, PaymentStatusFailed, PaymentStatusDuplicate, PaymentStatusWriteOff}
+}
+
+// NewPayment builds a payment received now; transactionDate is when the
+// provider says it happened
+func NewPayment(customerID string, amount int64, transactionRef string, transactionDate time.Time, status PaymentStatus, now time.Time) (*Payment, error) {
 	if customerID == "" {
 		return nil, ErrInvalidCustomerID
 	}
@@ -38,7 +101,6 @@ func NewPayment(customerID string, amount int64, transactionRef string, transact
 		return nil, ErrInvalidTransactionRef
 	}
 
-	now := time.Now()
 	return &Payment{
 		CustomerID:           customerID,
 		Amount:               amount,
@@ -49,8 +111,13 @@ func NewPayment(customerID string, amount int64, transactionRef string, transact
 	}, nil
 }
 
-func (p *Payment) MarkAsProcessed() {
-	p.ProcessedAt = time.Now()
+func (p *Payment) MarkAsProcessed(at time.Time) {
+	p.ProcessedAt = at
+}
+
+// RecordBalanceAfter captures the outstanding balance the payment left
+func (p *Payment) RecordBalanceAfter(balance int64) {
+	p.BalanceAfter = &balance
 }
 
 func (p *Payment) IsDuplicate() bool {
@@ -0,0 +1,106 @@
+package domain
+
+import "time"
+
+// paceWindowWeeks is how many of the most recent weeks ProjectPayoff
+// averages to measure the current pace
+const paceWindowWeeks = 4
+
+// PayoffEstimate is how many weekly installments are left and the date the
+// last of them would be paid
+type PayoffEstimate struct {
+	WeeksRemaining int
+	PayoffDate     time.Time
+}
+
+// PayoffProjection estimates when a customer will finish paying
+type PayoffProjection struct {
+	// Completed is set once the asset is fully paid; nothing is left to
+	// project then
+	Completed bool
+	// OnSchedule assumes the expected weekly installment is paid every week
+	// from the next due date on
+	OnSchedule *PayoffEstimate
+	// AtCurrentPace extrapolates WeeklyPace from now. It is nil when nothing
+	// was paid in the pace window.
+	AtCurrentPace *PayoffEstimate
+	// WeeklyPace is the average paid per week over the pace window, in kobo
+	WeeklyPace int64
+}
+
+// PaceWindow is the stretch before at whose payments measure the current
+// pace: the last four weeks, or the whole weeks since deployment when
+// there are fewer, but never less than one week
+func (c *Customer) PaceWindow(at time.Time) (from time.Time, weeks int) {
+	weeks = 1
+	if at.After(c.DeploymentDate) {
+		weeks = min(max(int(at.Sub(c.DeploymentDate)/installmentPeriod), 1), paceWindowWeeks)
+	}
+	return at.Add(-time.Duration(weeks) * installmentPeriod), weeks
+}
+
+// ProjectPayoff projects the payoff date at at, both on schedule and at the
+// pace set by paidInWindow, the amount paid over PaceWindow(at). Loans with
+// nothing outstanding, including written-off ones, have no estimates.
+func (c *Customer) ProjectPayoff(at time.Time, paidInWindow int64) PayoffProjection {
+	if c.OutstandingBalance <= 0 || c.Status == CustomerStatusWrittenOff {
+		return PayoffProjection{Completed: c.IsFullyPaid()}
+	}
+
+	var projection PayoffProjection
+	if weekly := c.ExpectedWeeklyAmount(); weekly > 0 {
+		weeks := weeksToPay(c.OutstandingBalance, weekly)
+		nextDue := c.DeploymentDate.Add(time.Duration(c.InstallmentsDue(at)+1) * installmentPeriod)
+		projection.OnSchedule = &PayoffEstimate{
+			WeeksRemaining: weeks,
+			PayoffDate:     nextDue.Add(time.Duration(weeks-1) * installmentPeriod),
+		}
+	}
+
+	_, windowWeeks := c.PaceWindow(at)
+	projection.WeeklyPace = paidInWindow / int64(windowWeeks)
+	if projection.WeeklyPace > 0 {
+		weeks := weeksToPay(c.OutstandingBalance, projection.WeeklyPace)
+		projection.AtCurrentPace = &PayoffEstimate{
+			WeeksRemaining: weeks,
+			PayoffDate:     at.Add(time.Duration(weeks) * installmentPeriod),
+		}
+	}
+
+	return projection
+}
+
+// weeksToPay is how many weekly payments of weekly it takes to clear balance
+func weeksToPay(balance, weekly int64) int {
+	return int((balance + weekly - 1) / weekly)
+}
+
+// maxBasisPoints is 100%
+const maxBasisPoints = 10000
+
+// PayoffQuote is what it takes to settle a loan in one payment
+type PayoffQuote struct {
+	// Completed is set once the asset is fully paid; the amounts are zero
+	Completed          bool
+	OutstandingBalance int64
+	// Discount is taken off the balance for paying early, rounded down
+	Discount int64
+	// Amount is the balance less Discount
+	Amount int64
+}
+
+// QuotePayoff quotes the remaining balance less an early-payoff discount of
+// discountBasisPoints (250 is 2.5%). A written-off loan has nothing left to
+// pay and isn't Completed either.
+func (c *Customer) QuotePayoff(discountBasisPoints int64) PayoffQuote {
+	if c.OutstandingBalance <= 0 || c.Status == CustomerStatusWrittenOff {
+		return PayoffQuote{Completed: c.IsFullyPaid()}
+	}
+	bps := min(max(discountBasisPoints, 0), maxBasisPoints)
+	discount := c.OutstandingBalance * bps / maxBasisPoints
+	return PayoffQuote{
+		OutstandingBalance: c.OutstandingBalance,
+		Discount:           discount,
+		Amount:             c.OutstandingBalance - discount,
+	}
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomer_PaceWindow(t *testing.T) {
+	c := scheduledCustomer(0)
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantWeeks int
+	}{
+		{"before the first week is out", weeksAfterDeployment(0, time.Hour), 1},
+		{"whole weeks since deployment", weeksAfterDeployment(2, time.Hour), 2},
+		{"capped at four weeks", weeksAfterDeployment(10, 0), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, weeks := c.PaceWindow(tt.at)
+			assert.Equal(t, tt.wantWeeks, weeks)
+			assert.Equal(t, tt.at.Add(-time.Duration(tt.wantWeeks)*installmentPeriod), from)
+		})
+	}
+}
+
+func TestCustomer_ProjectPayoff(t *testing.T) {
+	t.Run("exactly on schedule", func(t *testing.T) {
+		at := weeksAfterDeployment(1, time.Hour)
+		c := scheduledCustomer(334)
+
+		p := c.ProjectPayoff(at, 334)
+
+		assert.False(t, p.Completed)
+		require.NotNil(t, p.OnSchedule)
+		assert.Equal(t, 2, p.OnSchedule.WeeksRemaining)
+		assert.Equal(t, weeksAfterDeployment(3, 0), p.OnSchedule.PayoffDate, "finishes when the term ends")
+		assert.Equal(t, int64(334), p.WeeklyPace)
+		require.NotNil(t, p.AtCurrentPace)
+		assert.Equal(t, 2, p.AtCurrentPace.WeeksRemaining)
+		assert.Equal(t, at.Add(2*installmentPeriod), p.AtCurrentPace.PayoffDate)
+	})
+
+	t.Run("ahead of schedule", func(t *testing.T) {
+		at := weeksAfterDeployment(1, time.Hour)
+		c := scheduledCustomer(668)
+
+		p := c.ProjectPayoff(at, 668)
+
+		require.NotNil(t, p.OnSchedule)
+		assert.Equal(t, 1, p.OnSchedule.WeeksRemaining)
+		assert.Equal(t, weeksAfterDeployment(2, 0), p.OnSchedule.PayoffDate, "a week before the term ends")
+		require.NotNil(t, p.AtCurrentPace)
+		assert.Equal(t, 1, p.AtCurrentPace.WeeksRemaining)
+	})
+
+	t.Run("behind with no recent payments", func(t *testing.T) {
+		at := weeksAfterDeployment(2, time.Hour)
+		c := scheduledCustomer(0)
+
+		p := c.ProjectPayoff(at, 0)
+
+		require.NotNil(t, p.OnSchedule)
+		assert.Equal(t, 3, p.OnSchedule.WeeksRemaining)
+		assert.Equal(t, weeksAfterDeployment(5, 0), p.OnSchedule.PayoffDate, "two weeks past the end of the term")
+		assert.Zero(t, p.WeeklyPace)
+		assert.Nil(t, p.AtCurrentPace, "no pace to extrapolate")
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		c := scheduledCustomer(1000)
+		c.Status = CustomerStatusCompleted
+
+		p := c.ProjectPayoff(weeksAfterDeployment(3, 0), 332)
+
+		assert.Equal(t, PayoffProjection{Completed: true}, p)
+	})
+
+	t.Run("written off", func(t *testing.T) {
+		c := scheduledCustomer(334)
+		c.OutstandingBalance = 0
+		c.Status = CustomerStatusWrittenOff
+
+		p := c.ProjectPayoff(weeksAfterDeployment(3, 0), 0)
+
+		assert.Equal(t, PayoffProjection{}, p)
+	})
+}
+
+func TestCustomer_QuotePayoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		status  CustomerStatus
+		bps     int64
+		want    PayoffQuote
+	}{
+		{"no discount", 666, CustomerStatusActive, 0, PayoffQuote{OutstandingBalance: 666, Amount: 666}},
+		{"discount rounds down", 666, CustomerStatusActive, 250, PayoffQuote{OutstandingBalance: 666, Discount: 16, Amount: 650}},
+		{"defaulted loans are quoted too", 1000, CustomerStatusDefaulted, 1000, PayoffQuote{OutstandingBalance: 1000, Discount: 100, Amount: 900}},
+		{"discount capped at the balance", 1000, CustomerStatusActive, 20000, PayoffQuote{OutstandingBalance: 1000, Discount: 1000}},
+		{"completed", 0, CustomerStatusCompleted, 250, PayoffQuote{Completed: true}},
+		{"written off", 0, CustomerStatusWrittenOff, 250, PayoffQuote{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scheduledCustomer(1000 - tt.balance)
+			c.OutstandingBalance = tt.balance
+			c.Status = tt.status
+
+			assert.Equal(t, tt.want, c.QuotePayoff(tt.bps))
+		})
+	}
+}
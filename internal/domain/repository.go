@@ -1,18 +1,251 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrRequestCanceled and ErrRequestTimeout mean the caller's context ended
+// before a repository call finished. Neither is a fault in the service.
+var (
+	ErrRequestCanceled = errors.New("request canceled")
+	ErrRequestTimeout  = errors.New("request deadline exceeded")
+)
+
+// ContextError returns err wrapped in ErrRequestCanceled or
+// ErrRequestTimeout when ctx ending is what made it fail, and nil otherwise
+func ContextError(ctx context.Context, err error) error {
+	if errors.Is(err, ErrRequestCanceled) || errors.Is(err, ErrRequestTimeout) {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrRequestTimeout, err)
+	case errors.Is(err, context.Canceled), errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", ErrRequestCanceled, err)
+	}
+	return nil
+}
 
 type CustomerRepository interface {
 	FindByID(ctx context.Context, customerID string) (*Customer, error)
+	// FindByIDs returns the customers that exist, keyed by ID. Unknown IDs
+	// are simply absent from the map.
+	FindByIDs(ctx context.Context, customerIDs []string) (map[string]*Customer, error)
 	Save(ctx context.Context, customer *Customer) error
 	UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error
 }
 
+// UncachedCustomerFinder reads a customer from the store of record,
+// skipping any cache in front of it, for re-reads after an optimistic lock
+// conflict that a stale cached version would only repeat
+type UncachedCustomerFinder interface {
+	FindByIDSkipCache(ctx context.Context, customerID string) (*Customer, error)
+}
+
+// AtomicPaymentApplier records a payment and applies it to the customer in
+// one atomic step, for stores that can do both at once. Concurrent payments
+// are neither lost nor applied twice, with no optimistic-lock retry. A
+// reference already recorded returns ErrDuplicateTransaction and changes
+// nothing.
+type AtomicPaymentApplier interface {
+	// ApplyPayment enforces limits like Customer.ValidatePaymentAmount and
+	// Customer.ValidateOverpayment and the payment's currency like
+	// Customer.AcceptsCurrency, and returns the customer as saved, with its
+	// status from before the payment. The payment is recorded, and
+	// returned, with the balance it left in BalanceAfter and the customer's
+	// currency.
+	ApplyPayment(ctx context.Context, payment *Payment, limits PaymentLimits) (*Customer, CustomerStatus, error)
+}
+
+// PaymentLimits bounds the size of a single payment
+type PaymentLimits struct {
+	// Minimum rejects smaller payments that don't settle the balance; zero
+	// disables it
+	Minimum     int64
+	Overpayment OverpaymentLimit
+}
+
+// TxRepositories are repositories whose writes commit or roll back together
+type TxRepositories struct {
+	Customers CustomerRepository
+	Payments  PaymentRepository
+	// EventLog logs the events a change raises with the change itself
+	EventLog EventLog
+}
+
+// Transactor runs fn with repositories bound to one transaction, committing
+// if fn returns nil and rolling back otherwise
+type Transactor interface {
+	InTx(ctx context.Context, fn func(repos TxRepositories) error) error
+}
+
+// CustomerLister pages through customers in a status, for reports that
+// can't be served from the cache
+type CustomerLister interface {
+	FindByStatus(ctx context.Context, status string, limit, offset int) ([]*Customer, error)
+}
+
+// OtherLoanFinder looks up a borrower's other loans
+type OtherLoanFinder interface {
+	// FindOtherActiveLoan returns the oldest ACTIVE or DEFAULTED loan held
+	// by the same borrower as customer, or ErrCustomerNotFound if none
+	FindOtherActiveLoan(ctx context.Context, customer *Customer) (*Customer, error)
+}
+
+// CustomerCredit is money owed back to a customer, such as the excess of
+// an overpayment
+type CustomerCredit struct {
+	CustomerID           string
+	TransactionReference string
+	Amount               int64
+	CreatedAt            time.Time
+}
+
+// CreditLedger records refundable customer credit. Each customer's
+// transaction reference is credited at most once; recording it again
+// returns ErrDuplicateTransaction.
+type CreditLedger interface {
+	Record(ctx context.Context, credit *CustomerCredit) error
+}
+
+// LoggedEvent is a published domain event as the event log keeps it
+type LoggedEvent struct {
+	// Sequence orders the event among every other logged event
+	Sequence    int64
+	EventID     string
+	EventType   string
+	AggregateID string
+	// Payload is the event's JSON, exactly as it was published
+	Payload    []byte
+	OccurredAt time.Time
+	RecordedAt time.Time
+}
+
+// EventLog is the permanent, ordered record of every published domain
+// event, kept for audit and for rebuilding read models. Entries are never
+// changed or removed.
+type EventLog interface {
+	// Append logs event under the next sequence number and returns it.
+	// Appending an event ID already logged returns its original sequence.
+	Append(ctx context.Context, event DomainEvent) (int64, error)
+	// After returns up to limit entries recorded before until with a
+	// sequence above afterSeq, in sequence order
+	After(ctx context.Context, afterSeq int64, until time.Time, limit int) ([]*LoggedEvent, error)
+}
+
+// DedupScope is what a transaction reference must be unique within. Some
+// providers only keep references unique per customer, so two customers may
+// legitimately send the same one.
+type DedupScope string
+
+const (
+	DedupScopeGlobal   DedupScope = "global"
+	DedupScopeCustomer DedupScope = "customer"
+)
+
+// PerCustomer reports whether references are only unique per customer.
+// The zero value is global.
+func (s DedupScope) PerCustomer() bool {
+	return s == DedupScopeCustomer
+}
+
+// PaymentOrder is the transaction_date order of a payment listing
+type PaymentOrder string
+
+const (
+	PaymentOrderDesc PaymentOrder = "desc"
+	PaymentOrderAsc  PaymentOrder = "asc"
+)
+
+// PaymentFilter narrows a payment listing. No Statuses matches every
+// status, and an empty Order keeps the listing's own order.
+type PaymentFilter struct {
+	Statuses []PaymentStatus
+	Order    PaymentOrder
+}
+
+// Matches reports whether payment is in one of the filter's statuses
+func (f PaymentFilter) Matches(payment *Payment) bool {
+	return len(f.Statuses) == 0 || slices.Contains(f.Statuses, payment.Status)
+}
+
+// OrderOr is the filter's order, or fallback when it sets none
+func (f PaymentFilter) OrderOr(fallback PaymentOrder) PaymentOrder {
+	if f.Order == "" {
+		return fallback
+	}
+	return f.Order
+}
+
 type PaymentRepository interface {
 	Save(ctx context.Context, payment *Payment) error
-	FindByTransactionReference(ctx context.Context, txRef string) (*Payment, error)
-	ExistsByTransactionReference(ctx context.Context, txRef string) (bool, error)
+	// FindByTransactionReference and ExistsByTransactionReference look the
+	// reference up within the repository's DedupScope; customerID is only
+	// matched when references are unique per customer
+	FindByTransactionReference(ctx context.Context, customerID, txRef string) (*Payment, error)
+	ExistsByTransactionReference(ctx context.Context, customerID, txRef string) (bool, error)
 	FindByCustomerID(ctx context.Context, customerID string) ([]*Payment, error)
+	// FindByCustomerIDFiltered lists newest first unless filter says otherwise
+	FindByCustomerIDFiltered(ctx context.Context, customerID string, filter PaymentFilter) ([]*Payment, error)
 	FindByCustomerIDWithPagination(ctx context.Context, customerID string, limit, offset int) ([]*Payment, error)
 	CountByCustomerID(ctx context.Context, customerID string) (int64, error)
+	// FindByCustomerIDAfter returns up to limit payments matching filter,
+	// ordered by (transaction_date, id), that sort strictly after the given
+	// key. The order is ascending unless filter says otherwise. A zero
+	// afterDate and empty afterID start from the beginning.
+	FindByCustomerIDAfter(ctx context.Context, customerID string, filter PaymentFilter, afterDate time.Time, afterID string, limit int) ([]*Payment, error)
+	// FindByDateRange returns payments matching filter with from <=
+	// transaction_date <= to, newest first unless filter says otherwise. An
+	// empty customerID matches every customer.
+	FindByDateRange(ctx context.Context, from, to time.Time, customerID string, filter PaymentFilter, limit, offset int) ([]*Payment, error)
+	// TotalsByDateRange counts and sums the payments FindByDateRange would page over
+	TotalsByDateRange(ctx context.Context, from, to time.Time, customerID string, filter PaymentFilter) (PaymentTotals, error)
+}
+
+// PaymentPager reads one page of a customer's payments together with how
+// many they have in total, from a single consistent snapshot, so the count
+// always agrees with the page even while payments are being recorded
+type PaymentPager interface {
+	// FindPageByCustomerID returns up to limit payments matching filter,
+	// newest first unless filter says otherwise, skipping offset of them,
+	// and how many match in total
+	FindPageByCustomerID(ctx context.Context, customerID string, filter PaymentFilter, limit, offset int) ([]*Payment, int64, error)
+}
+
+// PaymentFeed reads every customer's payments in the order they were
+// recorded, for incremental exports
+type PaymentFeed interface {
+	// FindRecordedAfter returns up to limit payments recorded before until,
+	// ordered by (CreatedAt, ID), that sort strictly after the given key. A
+	// zero afterTime and empty afterID start from the beginning.
+	FindRecordedAfter(ctx context.Context, afterTime time.Time, afterID string, until time.Time, limit int) ([]*Payment, error)
+}
+
+// PaymentStatusTotaler breaks stored payments down by status, for reports
+type PaymentStatusTotaler interface {
+	// TotalsByStatus totals payments with from <= transaction_date < to.
+	// Statuses with no payments are absent from the map.
+	TotalsByStatus(ctx context.Context, from, to time.Time) (map[PaymentStatus]PaymentTotals, error)
+}
+
+// PaymentWeekTotaler totals a customer's payments per week of their
+// repayment schedule, for charts
+type PaymentWeekTotaler interface {
+	// TotalsByWeek totals the customer's COMPLETE payments by whole weeks
+	// since start, keyed by 0-based week. Payments dated before start count
+	// towards week 0; weeks with no payments are absent from the map.
+	TotalsByWeek(ctx context.Context, customerID string, start time.Time) (map[int]PaymentTotals, error)
+}
+
+// PaymentOutcomeLog remembers inbound payments that never became a stored
+// payment, such as duplicates and non-complete statuses, so daily reports
+// can count them
+type PaymentOutcomeLog interface {
+	Record(ctx context.Context, status PaymentStatus, txRef string, at time.Time) error
+	// Count returns how many outcomes with status were recorded in [from, to)
+	Count(ctx context.Context, status PaymentStatus, from, to time.Time) (int64, error)
 }
@@ -0,0 +1,171 @@
+package domain
+
+import "time"
+
+const installmentPeriod = 7 * 24 * time.Hour
+
+// InstallmentStanding compares a payment against the installment expected
+// for the week it was made in
+type InstallmentStanding string
+
+const (
+	InstallmentMet      InstallmentStanding = "MET"
+	InstallmentExceeded InstallmentStanding = "EXCEEDED"
+	InstallmentShort    InstallmentStanding = "SHORT"
+)
+
+// InstallmentStandings lists every InstallmentStanding
+func InstallmentStandings() []InstallmentStanding {
+	return []InstallmentStanding{InstallmentMet, InstallmentExceeded, InstallmentShort}
+}
+
+// ExpectedWeeklyAmount is the regular installment in kobo, rounded up so
+// the term is never overrun. The final installment may be smaller.
+func (c *Customer) ExpectedWeeklyAmount() int64 {
+	if c.RepaymentTermWeeks <= 0 {
+		return c.AssetValue
+	}
+	weeks := int64(c.RepaymentTermWeeks)
+	return (c.AssetValue + weeks - 1) / weeks
+}
+
+// InstallmentAmount returns what is due in the given 1-based week of the
+// term: the weekly amount, or whatever is left of the asset in the last week
+func (c *Customer) InstallmentAmount(week int) int64 {
+	if week < 1 {
+		return 0
+	}
+	weekly := c.ExpectedWeeklyAmount()
+	remaining := c.AssetValue - weekly*int64(week-1)
+	if remaining <= 0 {
+		return 0
+	}
+	if remaining < weekly {
+		return remaining
+	}
+	return weekly
+}
+
+// ScheduleWeek is the 0-based week of the repayment schedule at falls in,
+// counting whole weeks from deployment. Anything before deployment is in
+// week 0.
+func (c *Customer) ScheduleWeek(at time.Time) int {
+	if !at.After(c.DeploymentDate) {
+		return 0
+	}
+	return int(at.Sub(c.DeploymentDate) / installmentPeriod)
+}
+
+// InstallmentsDue counts the installments that have fallen due by at. The
+// first falls due one week after deployment.
+func (c *Customer) InstallmentsDue(at time.Time) int {
+	if !at.After(c.DeploymentDate) {
+		return 0
+	}
+	due := int(at.Sub(c.DeploymentDate) / installmentPeriod)
+	if c.RepaymentTermWeeks > 0 && due > c.RepaymentTermWeeks {
+		due = c.RepaymentTermWeeks
+	}
+	return due
+}
+
+// ExpectedPaidBy is the cumulative amount the schedule expects by at
+func (c *Customer) ExpectedPaidBy(at time.Time) int64 {
+	expected := c.ExpectedWeeklyAmount() * int64(c.InstallmentsDue(at))
+	if expected > c.AssetValue {
+		return c.AssetValue
+	}
+	return expected
+}
+
+// Arrears is how far the customer is behind the schedule at at, in kobo.
+// Customers ahead of schedule and written-off loans have no arrears.
+func (c *Customer) Arrears(at time.Time) int64 {
+	if c.Status == CustomerStatusWrittenOff {
+		return 0
+	}
+	arrears := c.ExpectedPaidBy(at) - c.TotalPaid
+	if arrears < 0 {
+		return 0
+	}
+	return arrears
+}
+
+// MissedInstallments is how many whole weekly installments the arrears at
+// at amount to
+func (c *Customer) MissedInstallments(at time.Time) int {
+	weekly := c.ExpectedWeeklyAmount()
+	if weekly <= 0 {
+		return 0
+	}
+	return int(c.Arrears(at) / weekly)
+}
+
+// UpdateDefaultStatus moves an ACTIVE customer to DEFAULTED once they have
+// missed threshold installments at at, and a DEFAULTED one back to ACTIVE
+// once they are under it again. Completed and written-off loans are left
+// alone, as is everyone when threshold is zero. It reports whether the
+// status changed.
+func (c *Customer) UpdateDefaultStatus(at time.Time, threshold int) bool {
+	if threshold <= 0 {
+		return false
+	}
+
+	inDefault := c.MissedInstallments(at) >= threshold
+	switch {
+	case c.Status == CustomerStatusActive && inDefault:
+		c.Status = CustomerStatusDefaulted
+	case c.Status == CustomerStatusDefaulted && !inDefault:
+		c.Status = CustomerStatusActive
+	default:
+		return false
+	}
+	return true
+}
+
+// DaysOverdue counts whole days since the oldest installment the customer
+// has not fully paid fell due. It is zero when there are no arrears.
+func (c *Customer) DaysOverdue(at time.Time) int {
+	if c.Arrears(at) == 0 {
+		return 0
+	}
+	weekly := c.ExpectedWeeklyAmount()
+	if weekly <= 0 {
+		return 0
+	}
+	covered := c.TotalPaid / weekly
+	due := c.DeploymentDate.Add(time.Duration(covered+1) * installmentPeriod)
+	if !at.After(due) {
+		return 0
+	}
+	return int(at.Sub(due) / (24 * time.Hour))
+}
+
+// ExpectedInstallment is what a payment made at paidAt should cover: the
+// installment for the week in progress, capped at the remaining balance.
+// Call it before the payment is applied.
+func (c *Customer) ExpectedInstallment(paidAt time.Time) int64 {
+	current := c.InstallmentsDue(paidAt) + 1
+	if c.RepaymentTermWeeks > 0 && current > c.RepaymentTermWeeks {
+		current = c.RepaymentTermWeeks
+	}
+	expected := c.InstallmentAmount(current)
+	if expected > c.OutstandingBalance {
+		return c.OutstandingBalance
+	}
+	return expected
+}
+
+// CompareInstallment reports whether amount met, exceeded or fell short of
+// ExpectedInstallment(paidAt). Call it before the payment is applied.
+func (c *Customer) CompareInstallment(amount int64, paidAt time.Time) InstallmentStanding {
+	expected := c.ExpectedInstallment(paidAt)
+	switch {
+	case amount > expected:
+		return InstallmentExceeded
+	case amount < expected:
+		return InstallmentShort
+	default:
+		return InstallmentMet
+	}
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var deployed = time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+func weeksAfterDeployment(weeks int, extra time.Duration) time.Time {
+	return deployed.Add(time.Duration(weeks)*installmentPeriod + extra)
+}
+
+// scheduledCustomer owes 1000 kobo over 3 weeks: 334, 334, then 332
+func scheduledCustomer(totalPaid int64) *Customer {
+	return &Customer{
+		ID:                 "GIG00001",
+		AssetValue:         1000,
+		RepaymentTermWeeks: 3,
+		OutstandingBalance: 1000 - totalPaid,
+		TotalPaid:          totalPaid,
+		DeploymentDate:     deployed,
+		Status:             CustomerStatusActive,
+	}
+}
+
+func TestCustomer_ExpectedWeeklyAmountRoundsUpAndFinalWeekIsPartial(t *testing.T) {
+	c := scheduledCustomer(0)
+
+	assert.Equal(t, int64(334), c.ExpectedWeeklyAmount())
+	assert.Equal(t, int64(334), c.InstallmentAmount(1))
+	assert.Equal(t, int64(334), c.InstallmentAmount(2))
+	assert.Equal(t, int64(332), c.InstallmentAmount(3))
+	assert.Zero(t, c.InstallmentAmount(4))
+
+	assert.Equal(t, int64(668), c.ExpectedPaidBy(weeksAfterDeployment(2, 0)))
+	assert.Equal(t, int64(1000), c.ExpectedPaidBy(weeksAfterDeployment(3, 0)))
+	assert.Equal(t, int64(1000), c.ExpectedPaidBy(weeksAfterDeployment(10, 0)), "never expects more than the asset")
+
+	even := &Customer{AssetValue: 900, RepaymentTermWeeks: 3}
+	assert.Equal(t, int64(300), even.ExpectedWeeklyAmount())
+	assert.Equal(t, int64(300), even.InstallmentAmount(3))
+}
+
+func TestCustomer_ScheduleStanding(t *testing.T) {
+	tests := []struct {
+		name         string
+		totalPaid    int64
+		paidAt       time.Time
+		amount       int64
+		wantExpected int64
+		wantStanding InstallmentStanding
+		wantArrears  int64
+	}{
+		{"on track", 334, weeksAfterDeployment(1, time.Hour), 334, 334, InstallmentMet, 0},
+		{"ahead", 668, weeksAfterDeployment(1, time.Hour), 500, 332, InstallmentExceeded, 0},
+		{"behind", 0, weeksAfterDeployment(2, time.Hour), 100, 332, InstallmentShort, 668},
+		{"final partial week met", 668, weeksAfterDeployment(2, time.Hour), 332, 332, InstallmentMet, 0},
+		{"after term", 668, weeksAfterDeployment(5, 0), 332, 332, InstallmentMet, 332},
+		{"before first due date", 0, deployed.Add(time.Hour), 334, 334, InstallmentMet, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scheduledCustomer(tt.totalPaid)
+
+			assert.Equal(t, tt.wantExpected, c.ExpectedInstallment(tt.paidAt))
+			assert.Equal(t, tt.wantStanding, c.CompareInstallment(tt.amount, tt.paidAt))
+			assert.Equal(t, tt.wantArrears, c.Arrears(tt.paidAt))
+		})
+	}
+}
+
+func TestCustomer_ArrearsAfterPayment(t *testing.T) {
+	c := scheduledCustomer(0)
+	at := weeksAfterDeployment(2, time.Hour)
+
+	assert.Equal(t, int64(668), c.Arrears(at))
+	assert.NoError(t, c.ApplyPayment(500, at))
+	assert.Equal(t, int64(168), c.Arrears(at))
+
+	c.Status = CustomerStatusWrittenOff
+	assert.Zero(t, c.Arrears(at), "written-off loans carry no arrears")
+}
+
+func TestCustomer_DaysOverdue(t *testing.T) {
+	tests := []struct {
+		name      string
+		totalPaid int64
+		at        time.Time
+		want      int
+	}{
+		{"nothing due yet", 0, deployed.Add(3 * 24 * time.Hour), 0},
+		{"first installment just due", 0, weeksAfterDeployment(1, 0), 0},
+		{"first installment missed", 0, weeksAfterDeployment(1, 4*24*time.Hour), 4},
+		{"partly paid first installment", 100, weeksAfterDeployment(2, 24*time.Hour), 8},
+		{"second installment missed", 334, weeksAfterDeployment(2, 2*24*time.Hour), 2},
+		{"on track", 668, weeksAfterDeployment(2, 2*24*time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scheduledCustomer(tt.totalPaid).DaysOverdue(tt.at))
+		})
+	}
+}
+
+func TestCustomer_UpdateDefaultStatus(t *testing.T) {
+	// Two of three installments due and nothing paid
+	at := weeksAfterDeployment(2, time.Hour)
+
+	tests := []struct {
+		name        string
+		status      CustomerStatus
+		totalPaid   int64
+		threshold   int
+		wantStatus  CustomerStatus
+		wantChanged bool
+	}{
+		{"active reaches threshold", CustomerStatusActive, 0, 2, CustomerStatusDefaulted, true},
+		{"active under threshold", CustomerStatusActive, 334, 2, CustomerStatusActive, false},
+		{"defaulted still behind", CustomerStatusDefaulted, 0, 2, CustomerStatusDefaulted, false},
+		{"defaulted catches up", CustomerStatusDefaulted, 334, 2, CustomerStatusActive, true},
+		{"disabled", CustomerStatusActive, 0, 0, CustomerStatusActive, false},
+		{"written off untouched", CustomerStatusWrittenOff, 0, 1, CustomerStatusWrittenOff, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scheduledCustomer(tt.totalPaid)
+			c.Status = tt.status
+
+			assert.Equal(t, tt.wantChanged, c.UpdateDefaultStatus(at, tt.threshold))
+			assert.Equal(t, tt.wantStatus, c.Status)
+		})
+	}
+}
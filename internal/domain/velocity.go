@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// PaymentVelocity summarises a customer's payments within one time window
+type PaymentVelocity struct {
+	Count       int64
+	TotalAmount int64
+	WindowStart time.Time
+	Window      time.Duration
+}
+
+// VelocityTracker counts payments per customer over a rolling series of
+// fixed windows
+type VelocityTracker interface {
+	// Record adds a payment to the customer's current window and returns
+	// the window's totals including it
+	Record(ctx context.Context, customerID string, amount int64) (PaymentVelocity, error)
+}
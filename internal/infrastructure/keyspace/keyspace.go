@@ -0,0 +1,15 @@
+// Package keyspace namespaces Redis keys so several environments or
+// services can share one Redis instance.
+package keyspace
+
+// Prefix is prepended to every key as "{prefix}:key". The empty prefix
+// leaves keys untouched.
+type Prefix string
+
+// Key returns key under the prefix
+func (p Prefix) Key(key string) string {
+	if p == "" {
+		return key
+	}
+	return string(p) + ":" + key
+}
@@ -0,0 +1,60 @@
+// Package logging builds the zap logger every binary logs through
+package logging
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// Options are the tunable parts of the logger
+type Options struct {
+	// Level is a zap level name: debug, info, warn, error, dpanic, panic or fatal
+	Level string
+	// Encoding is json for log shipping or console for reading locally
+	Encoding string
+	// Sampling drops repeated messages under load, as zap's production
+	// config does; turn it off when every line matters
+	Sampling bool
+}
+
+// New builds a logger from zap's production config with the level,
+// encoding and sampling taken from opts
+func New(opts Options) (*zap.Logger, error) {
+	cfg, err := config(opts)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Build()
+}
+
+func config(opts Options) (zap.Config, error) {
+	level, err := zapcore.ParseLevel(opts.Level)
+	if err != nil {
+		return zap.Config{}, fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(level)
+
+	switch opts.Encoding {
+	case EncodingJSON:
+	case EncodingConsole:
+		cfg.Encoding = EncodingConsole
+		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	default:
+		return zap.Config{}, fmt.Errorf("invalid log encoding %q, want %s or %s", opts.Encoding, EncodingJSON, EncodingConsole)
+	}
+
+	if !opts.Sampling {
+		cfg.Sampling = nil
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNew_LevelEncodingAndSampling(t *testing.T) {
+	cfg, err := config(Options{Level: "debug", Encoding: EncodingConsole, Sampling: false})
+	require.NoError(t, err)
+	assert.Equal(t, zapcore.DebugLevel, cfg.Level.Level())
+	assert.Equal(t, "console", cfg.Encoding)
+	assert.Nil(t, cfg.Sampling)
+
+	cfg, err = config(Options{Level: "warn", Encoding: EncodingJSON, Sampling: true})
+	require.NoError(t, err)
+	assert.Equal(t, zapcore.WarnLevel, cfg.Level.Level())
+	assert.Equal(t, "json", cfg.Encoding)
+	assert.NotNil(t, cfg.Sampling)
+
+	logger, err := New(Options{Level: "debug", Encoding: EncodingJSON})
+	require.NoError(t, err)
+	assert.True(t, logger.Core().Enabled(zap.DebugLevel))
+
+	logger, err = New(Options{Level: "error", Encoding: EncodingJSON})
+	require.NoError(t, err)
+	assert.False(t, logger.Core().Enabled(zap.WarnLevel))
+	assert.True(t, logger.Core().Enabled(zap.ErrorLevel))
+}
+
+func TestNew_RejectsUnknownSettings(t *testing.T) {
+	_, err := New(Options{Level: "verbose", Encoding: EncodingJSON})
+	assert.ErrorContains(t, err, `invalid log level "verbose"`)
+
+	_, err = New(Options{Level: "info", Encoding: "logfmt"})
+	assert.ErrorContains(t, err, `invalid log encoding "logfmt"`)
+}
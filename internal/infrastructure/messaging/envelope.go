@@ -0,0 +1,47 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+// eventEnvelope is what every backend publishes for an event: its identity
+// and routing fields, with the event itself JSON-encoded in Data
+type eventEnvelope struct {
+	EventID       string `json:"event_id"`
+	EventType     string `json:"event_type"`
+	AggregateID   string `json:"aggregate_id"`
+	CorrelationID string `json:"correlation_id"`
+	OccurredAt    int64  `json:"occurred_at"`
+	Data          string `json:"data"`
+}
+
+// newEventEnvelope encodes event into the envelope every backend publishes
+func newEventEnvelope(event domain.DomainEvent) (eventEnvelope, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return eventEnvelope{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return eventEnvelope{
+		EventID:       event.GetEventID(),
+		EventType:     event.GetEventType(),
+		AggregateID:   event.GetAggregateID(),
+		CorrelationID: event.GetCorrelationID(),
+		OccurredAt:    event.GetOccurredAt().Unix(),
+		Data:          string(data),
+	}, nil
+}
+
+// values lays the envelope out as stream entry fields
+func (e eventEnvelope) values() map[string]interface{} {
+	return map[string]interface{}{
+		"event_id":       e.EventID,
+		"event_type":     e.EventType,
+		"aggregate_id":   e.AggregateID,
+		"correlation_id": e.CorrelationID,
+		"occurred_at":    e.OccurredAt,
+		"data":           e.Data,
+	}
+}
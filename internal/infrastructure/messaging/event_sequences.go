@@ -0,0 +1,47 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+// observeSequenceScript keeps the highest sequence in KEYS[1] and answers
+// 1 when ARGV[1] is below it. The key's expiry is renewed on every call.
+var observeSequenceScript = redis.NewScript(`
+local seen = tonumber(redis.call('GET', KEYS[1]) or '0')
+local sequence = tonumber(ARGV[1])
+if sequence < seen then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+redis.call('SET', KEYS[1], sequence, 'PX', ARGV[2])
+return 0
+`)
+
+// RedisEventSequences keeps one key per aggregate a named handler has seen
+// events for, holding the highest sequence. Keys expire ttl after the
+// aggregate's last event; an event delayed longer than that is no longer
+// recognized as out of order.
+type RedisEventSequences struct {
+	client  redis.UniversalClient
+	keys    keyspace.Prefix
+	handler string
+	ttl     time.Duration
+}
+
+func NewRedisEventSequences(client redis.UniversalClient, keys keyspace.Prefix, handler string, ttl time.Duration) *RedisEventSequences {
+	return &RedisEventSequences{client: client, keys: keys, handler: handler, ttl: ttl}
+}
+
+func (s *RedisEventSequences) Observe(ctx context.Context, aggregateID string, sequence int64) (bool, error) {
+	key := s.keys.Key(fmt.Sprintf("event_sequence:%s:%s", s.handler, aggregateID))
+	stale, err := observeSequenceScript.Run(ctx, s.client, []string{key}, sequence, s.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to observe event sequence: %w", err)
+	}
+	return stale == 1, nil
+}
@@ -0,0 +1,44 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEventSequences_Observe(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	sequences := NewRedisEventSequences(client, "staging", "notifications", time.Hour)
+
+	for _, step := range []struct {
+		aggregate string
+		sequence  int64
+		stale     bool
+	}{
+		{"GIG00001", 2, false},
+		{"GIG00001", 4, false},
+		{"GIG00001", 3, true},
+		// Events from the same change share a sequence
+		{"GIG00001", 4, false},
+		{"GIG00002", 1, false},
+	} {
+		stale, err := sequences.Observe(ctx, step.aggregate, step.sequence)
+		require.NoError(t, err)
+		assert.Equal(t, step.stale, stale, "%s sequence %d", step.aggregate, step.sequence)
+	}
+
+	seen, err := mr.Get("staging:event_sequence:notifications:GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, "4", seen)
+	assert.Equal(t, time.Hour, mr.TTL("staging:event_sequence:notifications:GIG00001"))
+
+	// Once the key expires an old event is no longer recognized
+	mr.FastForward(2 * time.Hour)
+	stale, err := sequences.Observe(ctx, "GIG00001", 1)
+	require.NoError(t, err)
+	assert.False(t, stale)
+}
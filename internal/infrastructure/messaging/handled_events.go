@@ -0,0 +1,87 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	handledStateProcessing = "processing"
+	handledStateDone       = "done"
+)
+
+// RedisHandledEvents keeps one key per event a named handler has claimed.
+// A claim is a short-lived processing marker, so a consumer that crashes
+// mid-handling only blocks redelivery until it expires; completing it swaps
+// in a done marker that lasts as long as redeliveries are expected.
+type RedisHandledEvents struct {
+	client        redis.UniversalClient
+	keys          keyspace.Prefix
+	handler       string
+	processingTTL time.Duration
+	doneTTL       time.Duration
+}
+
+func NewRedisHandledEvents(client redis.UniversalClient, keys keyspace.Prefix, handler string, processingTTL, doneTTL time.Duration) *RedisHandledEvents {
+	return &RedisHandledEvents{
+		client:        client,
+		keys:          keys,
+		handler:       handler,
+		processingTTL: processingTTL,
+		doneTTL:       doneTTL,
+	}
+}
+
+func (h *RedisHandledEvents) Claim(ctx context.Context, eventID string) (bool, error) {
+	key := h.key(eventID)
+	claimed, err := h.client.SetNX(ctx, key, handledStateProcessing, h.processingTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+	if claimed {
+		return true, nil
+	}
+
+	state, err := h.client.Get(ctx, key).Result()
+	switch {
+	case err == redis.Nil:
+		// The other claim expired between the two calls; try once more
+		claimed, err = h.client.SetNX(ctx, key, handledStateProcessing, h.processingTTL).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to claim event: %w", err)
+		}
+		if !claimed {
+			return false, domain.ErrEventInProgress
+		}
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to read event state: %w", err)
+	case state == handledStateDone:
+		return false, nil
+	default:
+		return false, domain.ErrEventInProgress
+	}
+}
+
+func (h *RedisHandledEvents) Complete(ctx context.Context, eventID string) error {
+	if err := h.client.Set(ctx, h.key(eventID), handledStateDone, h.doneTTL).Err(); err != nil {
+		return fmt.Errorf("failed to mark event handled: %w", err)
+	}
+	return nil
+}
+
+func (h *RedisHandledEvents) Release(ctx context.Context, eventID string) error {
+	if err := h.client.Del(ctx, h.key(eventID)).Err(); err != nil {
+		return fmt.Errorf("failed to release event claim: %w", err)
+	}
+	return nil
+}
+
+func (h *RedisHandledEvents) key(eventID string) string {
+	return h.keys.Key(fmt.Sprintf("handled:%s:%s", h.handler, eventID))
+}
@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisHandledEvents_ClaimCompleteRelease(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	handled := NewRedisHandledEvents(client, "staging", "notifications", time.Minute, 72*time.Hour)
+
+	claimed, err := handled.Claim(ctx, "evt-1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.True(t, mr.Exists("staging:handled:notifications:evt-1"))
+
+	_, err = handled.Claim(ctx, "evt-1")
+	assert.ErrorIs(t, err, domain.ErrEventInProgress)
+
+	require.NoError(t, handled.Release(ctx, "evt-1"))
+	claimed, err = handled.Claim(ctx, "evt-1")
+	require.NoError(t, err)
+	assert.True(t, claimed, "a released claim can be taken again")
+
+	require.NoError(t, handled.Complete(ctx, "evt-1"))
+	claimed, err = handled.Claim(ctx, "evt-1")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, 72*time.Hour, mr.TTL("staging:handled:notifications:evt-1"))
+}
+
+func TestRedisHandledEvents_StaleClaimExpires(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	handled := NewRedisHandledEvents(client, "", "notifications", time.Minute, time.Hour)
+
+	claimed, err := handled.Claim(ctx, "evt-1")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	// The claiming consumer crashed; its marker runs out
+	mr.FastForward(2 * time.Minute)
+
+	claimed, err = handled.Claim(ctx, "evt-1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
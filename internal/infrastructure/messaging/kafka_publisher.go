@@ -0,0 +1,157 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// KafkaWriter writes messages to one topic; *kafka.Writer satisfies it
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaWriterFactory opens the writer for a topic. The publisher opens each
+// topic's writer once, on its first event.
+type KafkaWriterFactory func(topic string) KafkaWriter
+
+// NewKafkaWriterFactory returns writers that hash messages to partitions by
+// key and wait for every in-sync replica to acknowledge them
+func NewKafkaWriterFactory(brokers []string) KafkaWriterFactory {
+	return func(topic string) KafkaWriter {
+		return kafka.NewWriter(kafka.WriterConfig{
+			Brokers:      brokers,
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: -1,
+			BatchTimeout: 10 * time.Millisecond,
+		})
+	}
+}
+
+// KafkaEventPublisher publishes each event to the topic for its type, with
+// the same envelope RedisEventPublisher adds to a stream. The aggregate ID
+// is the message key, so a customer's events stay in order on one partition.
+type KafkaEventPublisher struct {
+	newWriter   KafkaWriterFactory
+	topicPrefix string
+	schemas     *SchemaRegistry
+	logger      *zap.Logger
+
+	mu      sync.Mutex
+	writers map[string]KafkaWriter
+}
+
+// KafkaPublisherOption configures optional KafkaEventPublisher behaviour
+type KafkaPublisherOption func(*KafkaEventPublisher)
+
+// WithKafkaSchemaValidation checks every event against its JSON Schema
+// before it is written. Events that don't conform are not published.
+func WithKafkaSchemaValidation(schemas *SchemaRegistry) KafkaPublisherOption {
+	return func(p *KafkaEventPublisher) {
+		p.schemas = schemas
+	}
+}
+
+func NewKafkaEventPublisher(newWriter KafkaWriterFactory, topicPrefix string, logger *zap.Logger, opts ...KafkaPublisherOption) *KafkaEventPublisher {
+	p := &KafkaEventPublisher{
+		newWriter:   newWriter,
+		topicPrefix: topicPrefix,
+		logger:      logger,
+		writers:     make(map[string]KafkaWriter),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Topic is where events of eventType are published
+func (p *KafkaEventPublisher) Topic(eventType string) string {
+	return p.topicPrefix + eventType
+}
+
+func (p *KafkaEventPublisher) writer(topic string) KafkaWriter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.writers[topic]
+	if !ok {
+		w = p.newWriter(topic)
+		p.writers[topic] = w
+	}
+	return w
+}
+
+func (p *KafkaEventPublisher) Publish(ctx context.Context, event domain.DomainEvent) error {
+	topic := p.Topic(event.GetEventType())
+
+	envelope, err := newEventEnvelope(event)
+	if err != nil {
+		return err
+	}
+
+	if p.schemas != nil {
+		if err := p.schemas.Validate(event.GetEventType(), []byte(envelope.Data)); err != nil {
+			p.logger.Error("event failed schema validation",
+				zap.Error(err),
+				zap.String("event_type", event.GetEventType()),
+				zap.String("event_id", event.GetEventID()),
+			)
+			return err
+		}
+	}
+
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event envelope: %w", err)
+	}
+
+	msg := kafka.Message{
+		Key:   []byte(event.GetAggregateID()),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(event.GetEventType())},
+			{Key: "event_id", Value: []byte(event.GetEventID())},
+		},
+		Time: event.GetOccurredAt(),
+	}
+	if err := p.writer(topic).WriteMessages(ctx, msg); err != nil {
+		p.logger.Error("failed to publish event",
+			zap.Error(err),
+			zap.String("event_type", event.GetEventType()),
+			zap.String("event_id", event.GetEventID()),
+		)
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	p.logger.Debug("event published",
+		zap.String("event_type", event.GetEventType()),
+		zap.String("event_id", event.GetEventID()),
+		zap.String("topic", topic),
+		zap.String("correlation_id", event.GetCorrelationID()),
+	)
+
+	return nil
+}
+
+// Close flushes and closes every topic writer the publisher opened
+func (p *KafkaEventPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for topic, w := range p.writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close writer for %s: %w", topic, err))
+		}
+		delete(p.writers, topic)
+	}
+	return errors.Join(errs...)
+}
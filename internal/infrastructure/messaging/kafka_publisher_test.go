@@ -0,0 +1,93 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockKafkaWriter struct {
+	topic    string
+	messages []kafka.Message
+	err      error
+	closed   bool
+}
+
+func (w *mockKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *mockKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// mockKafkaWriters hands out one recording writer per topic
+type mockKafkaWriters map[string]*mockKafkaWriter
+
+func (m mockKafkaWriters) factory(topic string) KafkaWriter {
+	w := &mockKafkaWriter{topic: topic}
+	m[topic] = w
+	return w
+}
+
+func TestKafkaPublish_WritesEnvelopeKeyedByAggregate(t *testing.T) {
+	writers := mockKafkaWriters{}
+	publisher := NewKafkaEventPublisher(writers.factory, "events.", zap.NewNop())
+	event := validProcessedEvent()
+
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	require.NoError(t, publisher.Publish(context.Background(), validProcessedEvent()))
+
+	require.Len(t, writers, 1, "events of one type share a topic writer")
+	w := writers["events."+domain.EventTypePaymentProcessed]
+	require.NotNil(t, w)
+	require.Len(t, w.messages, 2)
+
+	msg := w.messages[0]
+	assert.Equal(t, "GIG00001", string(msg.Key))
+	assert.Empty(t, msg.Topic, "the topic is the writer's")
+
+	var envelope eventEnvelope
+	require.NoError(t, json.Unmarshal(msg.Value, &envelope))
+	assert.Equal(t, event.GetEventID(), envelope.EventID)
+	assert.Equal(t, domain.EventTypePaymentProcessed, envelope.EventType)
+	assert.Equal(t, "GIG00001", envelope.AggregateID)
+	assert.Equal(t, event.GetCorrelationID(), envelope.CorrelationID)
+	assert.Equal(t, event.GetOccurredAt().Unix(), envelope.OccurredAt)
+
+	var decoded domain.PaymentProcessedEvent
+	require.NoError(t, json.Unmarshal([]byte(envelope.Data), &decoded))
+	assert.Equal(t, "TXN001", decoded.Payload.TransactionReference)
+
+	require.NoError(t, publisher.Close())
+	assert.True(t, w.closed)
+}
+
+func TestKafkaPublish_Failures(t *testing.T) {
+	schemas, err := NewSchemaRegistry()
+	require.NoError(t, err)
+	writers := mockKafkaWriters{}
+	publisher := NewKafkaEventPublisher(writers.factory, "events.", zap.NewNop(), WithKafkaSchemaValidation(schemas))
+
+	broken := validProcessedEvent()
+	broken.Payload.TransactionReference = ""
+	assert.ErrorIs(t, publisher.Publish(context.Background(), broken), ErrInvalidEvent)
+	assert.Empty(t, writers, "the broken event must not reach a topic")
+
+	require.NoError(t, publisher.Publish(context.Background(), validProcessedEvent()))
+	writeErr := errors.New("leader not available")
+	writers["events."+domain.EventTypePaymentProcessed].err = writeErr
+	assert.ErrorIs(t, publisher.Publish(context.Background(), validProcessedEvent()), writeErr)
+}
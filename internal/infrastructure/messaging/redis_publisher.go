@@ -2,54 +2,115 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 )
 
 type RedisEventPublisher struct {
-	client *redis.Client
-	logger *zap.Logger
+	client    redis.UniversalClient
+	keys      keyspace.Prefix
+	schemas   *SchemaRegistry
+	retention StreamRetentionPolicy
+	dedupTTL  time.Duration
+	logger    *zap.Logger
+	now       func() time.Time
 }
 
-func NewRedisEventPublisher(client *redis.Client, logger *zap.Logger) *RedisEventPublisher {
-	return &RedisEventPublisher{
-		client: client,
-		logger: logger,
+// PublisherOption configures optional RedisEventPublisher behaviour
+type PublisherOption func(*RedisEventPublisher)
+
+// WithSchemaValidation checks every event against its JSON Schema before
+// it is added to the stream. Events that don't conform are not published.
+func WithSchemaValidation(schemas *SchemaRegistry) PublisherOption {
+	return func(p *RedisEventPublisher) {
+		p.schemas = schemas
+	}
+}
+
+// WithStreamRetention sets how much history each event stream keeps.
+// Without it every stream keeps DefaultStreamRetention.
+func WithStreamRetention(policy StreamRetentionPolicy) PublisherOption {
+	return func(p *RedisEventPublisher) {
+		p.retention = policy
+	}
+}
+
+// WithPublishDeduplication makes publishing an event ID already published
+// within ttl a no-op, so an event raised twice, as by an outbox redelivery,
+// reaches its stream once. Each published ID is kept in a key that expires
+// after ttl; zero disables the check.
+func WithPublishDeduplication(ttl time.Duration) PublisherOption {
+	return func(p *RedisEventPublisher) {
+		p.dedupTTL = ttl
 	}
 }
 
+func NewRedisEventPublisher(client redis.UniversalClient, keys keyspace.Prefix, logger *zap.Logger, opts ...PublisherOption) *RedisEventPublisher {
+	p := &RedisEventPublisher{
+		client:    client,
+		keys:      keys,
+		retention: StreamRetentionPolicy{Default: DefaultStreamRetention},
+		logger:    logger,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// streamKey is shared by publisher and subscriber so both sides agree on
+// the prefixed stream name
+func streamKey(keys keyspace.Prefix, eventType string) string {
+	return keys.Key(fmt.Sprintf("events:%s", eventType))
+}
+
 func (p *RedisEventPublisher) Publish(ctx context.Context, event domain.DomainEvent) error {
-	streamKey := fmt.Sprintf("events:%s", event.GetEventType())
+	streamKey := streamKey(p.keys, event.GetEventType())
 
-	eventData, err := json.Marshal(event)
+	envelope, err := newEventEnvelope(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
 
-	args := &redis.XAddArgs{
-		Stream: streamKey,
-		MaxLen: 100000, // Keep last 100k events
-		Approx: true,
-		Values: map[string]interface{}{
-			"event_id":     event.GetEventID(),
-			"event_type":   event.GetEventType(),
-			"aggregate_id": event.GetAggregateID(),
-			"occurred_at":  event.GetOccurredAt().Unix(),
-			"data":         string(eventData),
-		},
+	if p.schemas != nil {
+		if err := p.schemas.Validate(event.GetEventType(), []byte(envelope.Data)); err != nil {
+			p.logger.Error("event failed schema validation",
+				zap.Error(err),
+				zap.String("event_type", event.GetEventType()),
+				zap.String("event_id", event.GetEventID()),
+			)
+			return err
+		}
 	}
 
-	_, err = p.client.XAdd(ctx, args).Result()
-	if err != nil {
+	if p.dedupTTL > 0 {
+		first, err := p.client.SetNX(ctx, p.publishedKey(event.GetEventID()), 1, p.dedupTTL).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check event was not already published: %w", err)
+		}
+		if !first {
+			p.logger.Debug("event already published, skipping",
+				zap.String("event_type", event.GetEventType()),
+				zap.String("event_id", event.GetEventID()),
+			)
+			return nil
+		}
+	}
+
+	retention := p.retention.For(event.GetEventType())
+	if err := retention.add(ctx, p.client, streamKey, envelope.values(), p.now()); err != nil {
 		p.logger.Error("failed to publish event",
 			zap.Error(err),
 			zap.String("event_type", event.GetEventType()),
 			zap.String("event_id", event.GetEventID()),
 		)
+		p.forgetPublished(ctx, event)
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
@@ -57,7 +118,29 @@ func (p *RedisEventPublisher) Publish(ctx context.Context, event domain.DomainEv
 		zap.String("event_type", event.GetEventType()),
 		zap.String("event_id", event.GetEventID()),
 		zap.String("stream", streamKey),
+		zap.String("correlation_id", event.GetCorrelationID()),
 	)
 
 	return nil
 }
+
+// publishedKey marks an event ID as published
+func (p *RedisEventPublisher) publishedKey(eventID string) string {
+	return p.keys.Key("published:" + eventID)
+}
+
+// forgetPublished drops the marker of an event that failed to publish, so
+// a retry isn't skipped as a duplicate. It runs even if ctx has ended, as
+// that may be why the publish failed.
+func (p *RedisEventPublisher) forgetPublished(ctx context.Context, event domain.DomainEvent) {
+	if p.dedupTTL <= 0 {
+		return
+	}
+	if err := p.client.Del(context.WithoutCancel(ctx), p.publishedKey(event.GetEventID())).Err(); err != nil {
+		p.logger.Warn("failed to clear published marker; retries are skipped until it expires",
+			zap.Error(err),
+			zap.String("event_type", event.GetEventType()),
+			zap.String("event_id", event.GetEventID()),
+		)
+	}
+}
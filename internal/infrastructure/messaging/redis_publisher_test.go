@@ -0,0 +1,162 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func validProcessedEvent() *domain.PaymentProcessedEvent {
+	return domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{
+		CustomerID:           "GIG00001",
+		TransactionReference: "TXN001",
+		Amount:               1000000,
+		OutstandingBalance:   99000000,
+		TotalPaid:            1000000,
+		PaymentProgress:      1,
+		ProcessedAt:          time.Now(),
+	}, time.Now())
+}
+
+func TestSchemaRegistry_CoversEveryEventType(t *testing.T) {
+	schemas, err := NewSchemaRegistry()
+	require.NoError(t, err)
+
+	for _, eventType := range []string{
+		domain.EventTypePaymentReceived,
+		domain.EventTypePaymentProcessed,
+		domain.EventTypeCustomerUpdated,
+		domain.EventTypePaymentFlagged,
+		domain.EventTypePaymentOverpaid,
+		domain.EventTypeReconciliationDaily,
+	} {
+		assert.Contains(t, schemas.schemas, eventType)
+	}
+}
+
+func TestPublish_ValidatesAgainstSchema(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	schemas, err := NewSchemaRegistry()
+	require.NoError(t, err)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop(), WithSchemaValidation(schemas))
+	stream := "events:" + domain.EventTypePaymentProcessed
+
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+
+	broken := validProcessedEvent()
+	broken.Payload.TransactionReference = ""
+	err = publisher.Publish(ctx, broken)
+	require.ErrorIs(t, err, ErrInvalidEvent)
+	assert.Contains(t, err.Error(), "transaction_reference")
+
+	entries, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the broken event must not reach the stream")
+}
+
+func TestPublish_AcceptsMoneyAsStrings(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	schemas, err := NewSchemaRegistry()
+	require.NoError(t, err)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop(), WithSchemaValidation(schemas))
+
+	event := validProcessedEvent()
+	event.Payload.MoneyAsStrings = true
+	require.NoError(t, publisher.Publish(ctx, event))
+
+	event = validProcessedEvent()
+	event.Payload.Amount = 0
+	event.Payload.MoneyAsStrings = true
+	assert.ErrorIs(t, publisher.Publish(ctx, event), ErrInvalidEvent, "a zero amount is invalid as a string too")
+}
+
+func TestPublish_ValidatesOtherEventTypes(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	schemas, err := NewSchemaRegistry()
+	require.NoError(t, err)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop(), WithSchemaValidation(schemas))
+
+	received := domain.NewPaymentReceivedEvent("GIG00001", domain.PaymentReceivedPayload{
+		CustomerID:           "GIG00001",
+		PaymentStatus:        "COMPLETE",
+		TransactionReference: "TXN001",
+		Amount:               1000000,
+		TransactionDate:      time.Now(),
+		ReceivedAt:           time.Now(),
+	}, time.Now())
+	assert.NoError(t, publisher.Publish(ctx, received))
+
+	updated := domain.NewCustomerUpdatedEvent("GIG00001", domain.CustomerUpdatedPayload{
+		CustomerID:     "GIG00001",
+		PreviousStatus: string(domain.CustomerStatusActive),
+		UpdatedAt:      time.Now(),
+	}, time.Now())
+	assert.ErrorIs(t, publisher.Publish(ctx, updated), ErrInvalidEvent, "status is missing")
+}
+
+func TestPublish_SkipsValidationWhenDisabled(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop())
+
+	broken := validProcessedEvent()
+	broken.Payload.TransactionReference = ""
+	require.NoError(t, publisher.Publish(ctx, broken))
+}
+
+func TestPublish_DeduplicatesByEventID(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop(), WithPublishDeduplication(time.Hour))
+	stream := "events:" + domain.EventTypePaymentProcessed
+	event := validProcessedEvent()
+
+	require.NoError(t, publisher.Publish(ctx, event))
+	require.NoError(t, publisher.Publish(ctx, event), "a repeat is a no-op, not an error")
+	entries, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, event.GetEventID(), entries[0].Values["event_id"])
+
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	assert.Equal(t, int64(2), client.XLen(ctx, stream).Val(), "another event ID is published")
+
+	mr.FastForward(time.Hour)
+	require.NoError(t, publisher.Publish(ctx, event))
+	assert.Equal(t, int64(3), client.XLen(ctx, stream).Val(), "the ID is forgotten after the TTL")
+}
+
+func TestPublish_DeduplicationOffByDefault(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop())
+	event := validProcessedEvent()
+
+	require.NoError(t, publisher.Publish(ctx, event))
+	require.NoError(t, publisher.Publish(ctx, event))
+	assert.Equal(t, int64(2), client.XLen(ctx, "events:"+domain.EventTypePaymentProcessed).Val())
+}
+
+func TestPublish_FailedPublishCanBeRetried(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop(), WithPublishDeduplication(time.Hour))
+	stream := "events:" + domain.EventTypePaymentProcessed
+	event := validProcessedEvent()
+
+	// A plain key in the stream's place makes XADD fail
+	require.NoError(t, client.Set(ctx, stream, "not a stream", 0).Err())
+	require.Error(t, publisher.Publish(ctx, event))
+
+	require.NoError(t, client.Del(ctx, stream).Err())
+	require.NoError(t, publisher.Publish(ctx, event))
+	assert.Equal(t, int64(1), client.XLen(ctx, stream).Val())
+}
@@ -3,42 +3,152 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 )
 
+// ErrTooManyFailures is returned by Start once the consecutive read
+// failure limit is hit, so the process can exit and be restarted
+var ErrTooManyFailures = errors.New("event subscriber gave up after repeated failures")
+
+// ErrHandlerTimeout is returned for a message whose handler overran the
+// per-handler timeout; the message is left unacked for redelivery
+var ErrHandlerTimeout = errors.New("event handler timed out")
+
+var (
+	ErrAlreadySubscribed = errors.New("already subscribed to event type")
+	ErrNotSubscribed     = errors.New("not subscribed to event type")
+)
+
 type RedisEventSubscriber struct {
-	client       *redis.Client
+	client       redis.UniversalClient
 	logger       *zap.Logger
-	handlers     map[string]domain.EventHandler
 	consumerName string
 	groupName    string
+	keys         keyspace.Prefix
+
+	// mu guards handlers, which Subscribe and Unsubscribe change while
+	// Start reads them
+	mu       sync.RWMutex
+	handlers map[string]domain.EventHandler
+
+	initialBackoff         time.Duration
+	maxBackoff             time.Duration
+	maxConsecutiveFailures int
+	handlerTimeout         time.Duration
+	// sleep waits between retries; swapped out in tests
+	sleep func(ctx context.Context, d time.Duration)
+
+	heartbeats *WorkerHeartbeats
+	// heartbeatEvery spaces out beats so the key isn't rewritten on every
+	// one-second read
+	heartbeatEvery time.Duration
+	startedAt      time.Time
+	lastBeat       time.Time
 }
 
-func NewRedisEventSubscriber(client *redis.Client, logger *zap.Logger, consumerName string) *RedisEventSubscriber {
-	return &RedisEventSubscriber{
-		client:       client,
-		logger:       logger,
-		handlers:     make(map[string]domain.EventHandler),
-		consumerName: consumerName,
-		groupName:    "payment-processors",
+// SubscriberOption configures optional RedisEventSubscriber behaviour
+type SubscriberOption func(*RedisEventSubscriber)
+
+// WithRetryBackoff sets the exponential backoff bounds used after a failed
+// read. Each wait is jittered between half and all of the current step.
+func WithRetryBackoff(initial, max time.Duration) SubscriberOption {
+	return func(s *RedisEventSubscriber) {
+		if initial > 0 {
+			s.initialBackoff = initial
+		}
+		if max >= s.initialBackoff {
+			s.maxBackoff = max
+		}
+	}
+}
+
+// WithKeyPrefix namespaces the streams read; it must match the publisher's
+func WithKeyPrefix(keys keyspace.Prefix) SubscriberOption {
+	return func(s *RedisEventSubscriber) {
+		s.keys = keys
+	}
+}
+
+// WithMaxConsecutiveFailures makes Start return after n failed reads in a
+// row. Zero retries forever.
+func WithMaxConsecutiveFailures(n int) SubscriberOption {
+	return func(s *RedisEventSubscriber) {
+		s.maxConsecutiveFailures = n
+	}
+}
+
+// WithHandlerTimeout bounds each handler call so one stuck notification
+// can't stall the consumer. Zero lets handlers run as long as they like.
+func WithHandlerTimeout(d time.Duration) SubscriberOption {
+	return func(s *RedisEventSubscriber) {
+		s.handlerTimeout = d
+	}
+}
+
+// WithHeartbeat has the subscriber report itself alive through heartbeats
+// after every read loop that succeeds, at most every ttl/3 so a beat or two
+// can be missed without the worker looking dead
+func WithHeartbeat(heartbeats *WorkerHeartbeats) SubscriberOption {
+	return func(s *RedisEventSubscriber) {
+		s.heartbeats = heartbeats
+		if heartbeats != nil {
+			s.heartbeatEvery = heartbeats.ttl / 3
+		}
+	}
+}
+
+// consumerGroup is the group every worker reads the event streams through
+const consumerGroup = "payment-processors"
+
+// idleWait is how long Start waits between checks while nothing is
+// subscribed, as a blocking read would otherwise
+const idleWait = 1 * time.Second
+
+func NewRedisEventSubscriber(client redis.UniversalClient, logger *zap.Logger, consumerName string, opts ...SubscriberOption) *RedisEventSubscriber {
+	s := &RedisEventSubscriber{
+		client:         client,
+		logger:         logger,
+		handlers:       make(map[string]domain.EventHandler),
+		consumerName:   consumerName,
+		groupName:      consumerGroup,
+		initialBackoff: 1 * time.Second,
+		maxBackoff:     30 * time.Second,
+		sleep:          sleepContext,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
+// Subscribe dispatches eventType to handler, from the next read on. A type
+// has one handler; subscribing it again returns ErrAlreadySubscribed, so
+// Unsubscribe first to replace it.
 func (s *RedisEventSubscriber) Subscribe(ctx context.Context, eventType string, handler domain.EventHandler) error {
-	s.handlers[eventType] = handler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.handlers[eventType]; exists {
+		return fmt.Errorf("%w: %s", ErrAlreadySubscribed, eventType)
+	}
 
-	streamKey := fmt.Sprintf("events:%s", eventType)
+	streamKey := streamKey(s.keys, eventType)
 
 	// Create consumer group if doesn't exist
 	err := s.client.XGroupCreateMkStream(ctx, streamKey, s.groupName, "0").Err()
 	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
 		return fmt.Errorf("failed to create consumer group: %w", err)
 	}
+	s.handlers[eventType] = handler
 
 	s.logger.Info("subscribed to event",
 		zap.String("event_type", eventType),
@@ -49,29 +159,170 @@ func (s *RedisEventSubscriber) Subscribe(ctx context.Context, eventType string,
 	return nil
 }
 
+// Unsubscribe stops dispatching eventType; a message already being handled
+// finishes. With leaveGroup the consumer is also removed from the stream's
+// group. Messages it read but never acknowledged can then no longer be
+// claimed, so only leave once nothing is pending. The group itself stays
+// for the other workers.
+func (s *RedisEventSubscriber) Unsubscribe(ctx context.Context, eventType string, leaveGroup bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.handlers[eventType]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, eventType)
+	}
+
+	streamKey := streamKey(s.keys, eventType)
+	if leaveGroup {
+		pending, err := s.client.XGroupDelConsumer(ctx, streamKey, s.groupName, s.consumerName).Result()
+		if err != nil {
+			return fmt.Errorf("failed to leave consumer group: %w", err)
+		}
+		if pending > 0 {
+			s.logger.Warn("left consumer group with unacknowledged messages",
+				zap.String("event_type", eventType),
+				zap.Int64("pending", pending),
+			)
+		}
+	}
+	delete(s.handlers, eventType)
+
+	s.logger.Info("unsubscribed from event",
+		zap.String("event_type", eventType),
+		zap.String("stream", streamKey),
+		zap.Bool("left_group", leaveGroup),
+	)
+
+	return nil
+}
+
+// subscribedTypes lists the event types with a handler right now
+func (s *RedisEventSubscriber) subscribedTypes() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	types := make([]string, 0, len(s.handlers))
+	for eventType := range s.handlers {
+		types = append(types, eventType)
+	}
+	return types
+}
+
+func (s *RedisEventSubscriber) handler(eventType string) (domain.EventHandler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	handler, exists := s.handlers[eventType]
+	return handler, exists
+}
+
 func (s *RedisEventSubscriber) Start(ctx context.Context) error {
 	s.logger.Info("starting event subscriber",
 		zap.String("consumer", s.consumerName),
 		zap.String("group", s.groupName),
 	)
 
+	failures := 0
+	backoff := s.initialBackoff
+	s.startedAt = time.Now()
+
 	for {
 		select {
 		case <-ctx.Done():
 			s.logger.Info("stopping event subscriber")
 			return nil
 		default:
-			if err := s.processEvents(ctx); err != nil {
-				s.logger.Error("error processing events", zap.Error(err))
-				time.Sleep(1 * time.Second)
+		}
+
+		err := s.processEvents(ctx)
+		if err == nil {
+			if failures > 0 {
+				s.logger.Info("event subscriber recovered", zap.Int("failures", failures))
 			}
+			failures = 0
+			backoff = s.initialBackoff
+			s.beat(ctx)
+			continue
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+
+		failures++
+		if s.maxConsecutiveFailures > 0 && failures >= s.maxConsecutiveFailures {
+			s.logger.Error("event subscriber giving up",
+				zap.Error(err),
+				zap.Int("failures", failures),
+			)
+			return fmt.Errorf("%w: %v", ErrTooManyFailures, err)
+		}
+
+		wait := jitter(backoff)
+		s.logger.Error("error processing events",
+			zap.Error(err),
+			zap.Int("failures", failures),
+			zap.Duration("retry_in", wait),
+		)
+		s.sleep(ctx, wait)
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
 		}
 	}
 }
 
+// beat refreshes the heartbeat when one is due. A failed beat is only
+// logged: the loop itself is fine, and if Redis is down the next read
+// fails anyway.
+func (s *RedisEventSubscriber) beat(ctx context.Context) {
+	if s.heartbeats == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(s.lastBeat) < s.heartbeatEvery {
+		return
+	}
+
+	err := s.heartbeats.Beat(ctx, WorkerStatus{
+		Consumer:  s.consumerName,
+		StartedAt: s.startedAt,
+		BeatAt:    now,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("failed to write worker heartbeat", zap.Error(err))
+		}
+		return
+	}
+	s.lastBeat = now
+}
+
+// jitter picks a wait in [d/2, d] so restarted workers don't retry in lockstep
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
 func (s *RedisEventSubscriber) processEvents(ctx context.Context) error {
-	for eventType := range s.handlers {
-		streamKey := fmt.Sprintf("events:%s", eventType)
+	eventTypes := s.subscribedTypes()
+	if len(eventTypes) == 0 {
+		// Subscribe may be called after Start; wait for it without spinning
+		sleepContext(ctx, idleWait)
+		return nil
+	}
+
+	for _, eventType := range eventTypes {
+		streamKey := streamKey(s.keys, eventType)
 
 		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
 			Group:    s.groupName,
@@ -108,7 +359,7 @@ func (s *RedisEventSubscriber) processEvents(ctx context.Context) error {
 }
 
 func (s *RedisEventSubscriber) handleMessage(ctx context.Context, eventType string, message redis.XMessage) error {
-	handler, exists := s.handlers[eventType]
+	handler, exists := s.handler(eventType)
 	if !exists {
 		return fmt.Errorf("no handler for event type: %s", eventType)
 	}
@@ -120,15 +371,92 @@ func (s *RedisEventSubscriber) handleMessage(ctx context.Context, eventType stri
 
 	var event domain.DomainEvent
 	switch eventType {
+	case domain.EventTypePaymentReceived:
+		var e domain.PaymentReceivedEvent
+		if err := json.Unmarshal([]byte(eventData), &e); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		event = &e
 	case domain.EventTypePaymentProcessed:
 		var e domain.PaymentProcessedEvent
 		if err := json.Unmarshal([]byte(eventData), &e); err != nil {
 			return fmt.Errorf("failed to unmarshal event: %w", err)
 		}
 		event = &e
+	case domain.EventTypeCustomerUpdated:
+		var e domain.CustomerUpdatedEvent
+		if err := json.Unmarshal([]byte(eventData), &e); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		event = &e
+	case domain.EventTypePaymentFlagged:
+		var e domain.PaymentFlaggedEvent
+		if err := json.Unmarshal([]byte(eventData), &e); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		event = &e
+	case domain.EventTypePaymentOverpaid:
+		var e domain.PaymentOverpaidEvent
+		if err := json.Unmarshal([]byte(eventData), &e); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		event = &e
+	case domain.EventTypeReconciliationDaily:
+		var e domain.DailyReconciliationEvent
+		if err := json.Unmarshal([]byte(eventData), &e); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		event = &e
 	default:
 		return fmt.Errorf("unknown event type: %s", eventType)
 	}
 
-	return handler(ctx, event)
+	correlationID := event.GetCorrelationID()
+	if correlationID == "" {
+		correlationID, _ = message.Values["correlation_id"].(string)
+	}
+	ctx = domain.ContextWithCorrelationID(ctx, correlationID)
+
+	s.logger.Debug("dispatching event",
+		zap.String("event_type", eventType),
+		zap.String("event_id", event.GetEventID()),
+		zap.String("message_id", message.ID),
+		zap.String("correlation_id", correlationID),
+	)
+
+	if s.handlerTimeout <= 0 {
+		return handler(ctx, event)
+	}
+	return s.runWithTimeout(ctx, handler, event, message.ID)
+}
+
+// runWithTimeout runs the handler in its own goroutine so the consumer can
+// move on even if the handler ignores its context
+func (s *RedisEventSubscriber) runWithTimeout(ctx context.Context, handler domain.EventHandler, event domain.DomainEvent, messageID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.handlerTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- handler(ctx, event)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+		elapsed := time.Since(start)
+		s.logger.Warn("event handler timed out",
+			zap.String("event_type", event.GetEventType()),
+			zap.String("event_id", event.GetEventID()),
+			zap.String("message_id", messageID),
+			zap.Duration("elapsed", elapsed),
+			zap.Duration("timeout", s.handlerTimeout),
+		)
+		return fmt.Errorf("%w after %s", ErrHandlerTimeout, elapsed.Round(time.Millisecond))
+	}
 }
@@ -0,0 +1,386 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestCorrelationID_RoundTripsThroughStream(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	logger := zap.NewNop()
+
+	publisher := NewRedisEventPublisher(client, "", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer")
+
+	var gotEvent *domain.PaymentProcessedEvent
+	var gotCtxID string
+	err := subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(ctx context.Context, event domain.DomainEvent) error {
+		gotEvent = event.(*domain.PaymentProcessedEvent)
+		gotCtxID = domain.CorrelationIDFromContext(ctx)
+		return nil
+	})
+	require.NoError(t, err)
+
+	event := domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{
+		CustomerID:           "GIG00001",
+		TransactionReference: "TXN001",
+		Amount:               1000000,
+	}, time.Now())
+	event.CorrelationID = "req-abc-123"
+	require.NoError(t, publisher.Publish(ctx, event))
+
+	entries, err := client.XRange(ctx, "events:"+domain.EventTypePaymentProcessed, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "req-abc-123", entries[0].Values["correlation_id"])
+
+	require.NoError(t, subscriber.processEvents(ctx))
+
+	require.NotNil(t, gotEvent)
+	assert.Equal(t, "req-abc-123", gotEvent.GetCorrelationID())
+	assert.Equal(t, "req-abc-123", gotCtxID)
+	assert.Equal(t, event.GetEventID(), gotEvent.GetEventID())
+}
+
+// recordSleeps replaces the subscriber's sleep so retries run instantly,
+// calling onSleep with the call number (from 1) before recording the wait
+func recordSleeps(s *RedisEventSubscriber, onSleep func(n int)) *[]time.Duration {
+	var waits []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) {
+		waits = append(waits, d)
+		if onSleep != nil {
+			onSleep(len(waits))
+		}
+	}
+	return &waits
+}
+
+// Deleting the stream makes XREADGROUP fail with NOGROUP until the group
+// is recreated, which stands in for a Redis outage.
+const processedStream = "events:" + domain.EventTypePaymentProcessed
+
+func TestStart_BacksOffAndGivesUp(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	subscriber := NewRedisEventSubscriber(client, zap.NewNop(), "test-consumer",
+		WithRetryBackoff(100*time.Millisecond, 400*time.Millisecond),
+		WithMaxConsecutiveFailures(6),
+	)
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		return nil
+	}))
+	waits := recordSleeps(subscriber, nil)
+
+	require.NoError(t, client.Del(ctx, processedStream).Err())
+	err := subscriber.Start(ctx)
+
+	assert.ErrorIs(t, err, ErrTooManyFailures)
+
+	steps := []time.Duration{100, 200, 400, 400, 400}
+	require.Len(t, *waits, len(steps), "gives up on the 6th failure without sleeping")
+	for i, step := range steps {
+		step *= time.Millisecond
+		assert.GreaterOrEqual(t, (*waits)[i], step/2, "wait %d", i)
+		assert.LessOrEqual(t, (*waits)[i], step, "wait %d", i)
+	}
+}
+
+func TestStart_ResetsBackoffAfterSuccessfulRead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, client := newTestRedis(t)
+
+	subscriber := NewRedisEventSubscriber(client, zap.NewNop(), "test-consumer",
+		WithRetryBackoff(100*time.Millisecond, 10*time.Second),
+	)
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		// Break the stream again once a read has succeeded
+		return client.Del(ctx, processedStream).Err()
+	}))
+
+	waits := recordSleeps(subscriber, func(n int) {
+		switch n {
+		case 3:
+			require.NoError(t, client.XGroupCreateMkStream(ctx, processedStream, "payment-processors", "0").Err())
+			require.NoError(t, NewRedisEventPublisher(client, "", zap.NewNop()).Publish(ctx,
+				domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{CustomerID: "GIG00001"}, time.Now()),
+			))
+		case 4:
+			cancel()
+		}
+	})
+
+	require.NoError(t, client.Del(ctx, processedStream).Err())
+	require.NoError(t, subscriber.Start(ctx))
+
+	require.Len(t, *waits, 4)
+	assert.Greater(t, (*waits)[2], 200*time.Millisecond, "third failure waits on the 400ms step")
+	assert.LessOrEqual(t, (*waits)[3], 100*time.Millisecond, "backoff resets after a successful read")
+}
+
+func TestKeyPrefix_PublisherAndSubscriberShareStream(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	logger := zap.NewNop()
+
+	publisher := NewRedisEventPublisher(client, "staging", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer", WithKeyPrefix("staging"))
+
+	var received int
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		received++
+		return nil
+	}))
+	require.NoError(t, publisher.Publish(ctx, domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{CustomerID: "GIG00001"}, time.Now())))
+
+	assert.Equal(t, []string{"staging:events:" + domain.EventTypePaymentProcessed}, mr.Keys())
+
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.Equal(t, 1, received)
+}
+
+func TestHandlerTimeout_LeavesMessageUnacked(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	core, logs := observer.New(zap.WarnLevel)
+
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop())
+	subscriber := NewRedisEventSubscriber(client, zap.New(core), "test-consumer", WithHandlerTimeout(20*time.Millisecond))
+
+	release := make(chan struct{})
+	defer close(release)
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		// A stuck sender that ignores its context
+		<-release
+		return nil
+	}))
+
+	event := domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{CustomerID: "GIG00001"}, time.Now())
+	require.NoError(t, publisher.Publish(ctx, event))
+
+	start := time.Now()
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.Less(t, time.Since(start), time.Second, "the consumer must not wait on the stuck handler")
+
+	stream := "events:" + domain.EventTypePaymentProcessed
+	pending, err := client.XPending(ctx, stream, subscriber.groupName).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending.Count, "timed-out message must stay unacked")
+
+	entries := logs.FilterMessage("event handler timed out").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, event.GetEventID(), fields["event_id"])
+	assert.GreaterOrEqual(t, fields["elapsed"], 20*time.Millisecond)
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	err = subscriber.handleMessage(ctx, domain.EventTypePaymentProcessed, messages[0])
+	assert.ErrorIs(t, err, ErrHandlerTimeout)
+}
+
+func TestHandlerTimeout_FastHandlerIsAcked(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop())
+	subscriber := NewRedisEventSubscriber(client, zap.NewNop(), "test-consumer", WithHandlerTimeout(time.Second))
+
+	var handled bool
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(ctx context.Context, event domain.DomainEvent) error {
+		_, hasDeadline := ctx.Deadline()
+		handled = hasDeadline
+		return nil
+	}))
+	require.NoError(t, publisher.Publish(ctx, domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{CustomerID: "GIG00001"}, time.Now())))
+
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.True(t, handled, "handler should see the per-message deadline")
+
+	pending, err := client.XPending(ctx, "events:"+domain.EventTypePaymentProcessed, subscriber.groupName).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestSubscribe_RejectsSecondHandler(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	logger := zap.NewNop()
+	publisher := NewRedisEventPublisher(client, "", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer")
+
+	var first, second int
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		first++
+		return nil
+	}))
+	err := subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		second++
+		return nil
+	})
+	require.ErrorIs(t, err, ErrAlreadySubscribed)
+
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.Equal(t, 1, first)
+	assert.Zero(t, second, "the first handler must stay in place")
+}
+
+func TestUnsubscribe_StopsDispatching(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	logger := zap.NewNop()
+	publisher := NewRedisEventPublisher(client, "", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer")
+	stream := "events:" + domain.EventTypePaymentProcessed
+
+	var received int
+	handler := func(context.Context, domain.DomainEvent) error {
+		received++
+		return nil
+	}
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, handler))
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	require.NoError(t, subscriber.processEvents(ctx))
+	require.Equal(t, 1, received)
+
+	require.NoError(t, subscriber.Unsubscribe(ctx, domain.EventTypePaymentProcessed, false))
+	assert.ErrorIs(t, subscriber.Unsubscribe(ctx, domain.EventTypePaymentProcessed, false), ErrNotSubscribed)
+
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.Equal(t, 1, received)
+
+	assert.Len(t, xinfo(t, client, "CONSUMERS", stream, consumerGroup), 1, "without leaveGroup the consumer stays in the group")
+
+	// Subscribing again picks up what arrived in between
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, handler))
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.Equal(t, 2, received)
+}
+
+// xinfo runs XINFO raw; go-redis v8 can't parse miniredis' newer replies
+func xinfo(t *testing.T, client *redis.Client, args ...interface{}) []interface{} {
+	t.Helper()
+	reply, err := client.Do(context.Background(), append([]interface{}{"XINFO"}, args...)...).Slice()
+	require.NoError(t, err)
+	return reply
+}
+
+func TestUnsubscribe_LeavesGroup(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	logger := zap.NewNop()
+	publisher := NewRedisEventPublisher(client, "", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer")
+	stream := "events:" + domain.EventTypePaymentProcessed
+
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		return nil
+	}))
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	require.NoError(t, subscriber.processEvents(ctx))
+
+	require.NoError(t, subscriber.Unsubscribe(ctx, domain.EventTypePaymentProcessed, true))
+
+	assert.Empty(t, xinfo(t, client, "CONSUMERS", stream, consumerGroup))
+	assert.Len(t, xinfo(t, client, "GROUPS", stream), 1, "other workers still read through the group")
+}
+
+func TestSubscribe_AfterStartIsPickedUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, client := newTestRedis(t)
+	logger := zap.NewNop()
+	publisher := NewRedisEventPublisher(client, "", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer")
+
+	done := make(chan error, 1)
+	go func() { done <- subscriber.Start(ctx) }()
+
+	var processed, flagged atomic.Int64
+	var wg sync.WaitGroup
+	for eventType, count := range map[string]*atomic.Int64{
+		domain.EventTypePaymentProcessed: &processed,
+		domain.EventTypePaymentFlagged:   &flagged,
+	} {
+		wg.Add(1)
+		go func(eventType string, count *atomic.Int64) {
+			defer wg.Done()
+			assert.NoError(t, subscriber.Subscribe(ctx, eventType, func(context.Context, domain.DomainEvent) error {
+				count.Add(1)
+				return nil
+			}))
+		}(eventType, count)
+	}
+	wg.Wait()
+
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	require.NoError(t, publisher.Publish(ctx, domain.NewPaymentFlaggedEvent("GIG00001", domain.PaymentFlaggedPayload{
+		CustomerID: "GIG00001", TransactionReference: "TXN001", Amount: 5000,
+	}, time.Now())))
+
+	assert.Eventually(t, func() bool {
+		return processed.Load() == 1 && flagged.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond, "handlers added after Start must be invoked")
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSubscribe_SafeWhileStarted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, client := newTestRedis(t)
+	logger := zap.NewNop()
+	publisher := NewRedisEventPublisher(client, "", logger)
+	subscriber := NewRedisEventSubscriber(client, logger, "test-consumer")
+
+	var received atomic.Int64
+	handler := func(context.Context, domain.DomainEvent) error {
+		received.Add(1)
+		return nil
+	}
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, handler))
+
+	done := make(chan error, 1)
+	go func() { done <- subscriber.Start(ctx) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if err := subscriber.Subscribe(ctx, domain.EventTypePaymentFlagged, handler); err == nil {
+					subscriber.Unsubscribe(ctx, domain.EventTypePaymentFlagged, false)
+				}
+			}
+		}()
+	}
+	require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+	wg.Wait()
+
+	assert.Eventually(t, func() bool { return received.Load() == 1 }, 3*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,77 @@
+package messaging
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var (
+	ErrNoEventSchema = errors.New("no schema registered for event type")
+	ErrInvalidEvent  = errors.New("event does not match its schema")
+)
+
+// SchemaRegistry holds the compiled JSON Schema for each event type. Schemas
+// live in schemas/<event_type>.json and are the contract with consumers.
+type SchemaRegistry struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewSchemaRegistry compiles the embedded event schemas
+func NewSchemaRegistry() (*SchemaRegistry, error) {
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event schemas: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+
+	registry := &SchemaRegistry{schemas: make(map[string]*jsonschema.Schema, len(entries))}
+	for _, entry := range entries {
+		name := entry.Name()
+		data, err := schemaFiles.ReadFile(path.Join("schemas", name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %s: %w", name, err)
+		}
+		url := "mem://schemas/" + name
+		if err := compiler.AddResource(url, bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to load schema %s: %w", name, err)
+		}
+		schema, err := compiler.Compile(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile schema %s: %w", name, err)
+		}
+		registry.schemas[strings.TrimSuffix(name, ".json")] = schema
+	}
+
+	return registry, nil
+}
+
+// Validate checks a marshaled event against the schema for its type
+func (r *SchemaRegistry) Validate(eventType string, data []byte) error {
+	schema, ok := r.schemas[eventType]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoEventSchema, eventType)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
+	if err := schema.Validate(doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
+	return nil
+}
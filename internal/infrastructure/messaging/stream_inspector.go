@@ -0,0 +1,97 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamInspector reads an event stream without consuming it, so support
+// can see what was published and what the workers haven't acknowledged
+type StreamInspector struct {
+	client redis.UniversalClient
+	keys   keyspace.Prefix
+}
+
+func NewStreamInspector(client redis.UniversalClient, keys keyspace.Prefix) *StreamInspector {
+	return &StreamInspector{client: client, keys: keys}
+}
+
+// StreamQuery bounds a read by message ID; From and To default to the
+// start and end of the stream, and Count caps both lists
+type StreamQuery struct {
+	From  string
+	To    string
+	Count int64
+}
+
+// StreamEntry is one message as stored. Payload is the decoded "data"
+// field, or nil when it isn't JSON.
+type StreamEntry struct {
+	ID      string
+	Fields  map[string]interface{}
+	Payload json.RawMessage
+}
+
+// PendingEntry is a message delivered to a worker but not yet acknowledged
+type PendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+type StreamSnapshot struct {
+	Stream  string
+	Entries []StreamEntry
+	Pending []PendingEntry
+}
+
+// Inspect returns the oldest query.Count messages in the range and the
+// consumer group's pending messages in the same range. A stream nobody has
+// subscribed to yet has no pending list.
+func (i *StreamInspector) Inspect(ctx context.Context, eventType string, query StreamQuery) (*StreamSnapshot, error) {
+	if query.From == "" {
+		query.From = "-"
+	}
+	if query.To == "" {
+		query.To = "+"
+	}
+
+	snapshot := &StreamSnapshot{Stream: streamKey(i.keys, eventType)}
+
+	messages, err := i.client.XRangeN(ctx, snapshot.Stream, query.From, query.To, query.Count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	snapshot.Entries = make([]StreamEntry, len(messages))
+	for n, message := range messages {
+		entry := StreamEntry{ID: message.ID, Fields: message.Values}
+		if data, ok := message.Values["data"].(string); ok && json.Valid([]byte(data)) {
+			entry.Payload = json.RawMessage(data)
+		}
+		snapshot.Entries[n] = entry
+	}
+
+	pending, err := i.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: snapshot.Stream,
+		Group:  consumerGroup,
+		Start:  query.From,
+		End:    query.To,
+		Count:  query.Count,
+	}).Result()
+	if err != nil && !strings.HasPrefix(err.Error(), "NOGROUP") {
+		return nil, fmt.Errorf("failed to read pending messages: %w", err)
+	}
+	snapshot.Pending = make([]PendingEntry, len(pending))
+	for n, p := range pending {
+		snapshot.Pending[n] = PendingEntry{ID: p.ID, Consumer: p.Consumer, Idle: p.Idle, Deliveries: p.RetryCount}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,77 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStreamInspector_ReadsEntriesAndPending(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop())
+
+	for i := 1; i <= 3; i++ {
+		event := domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{
+			CustomerID:           "GIG00001",
+			TransactionReference: fmt.Sprintf("TXN%d", i),
+			Amount:               int64(i) * 1000,
+		}, time.Now())
+		require.NoError(t, publisher.Publish(ctx, event))
+	}
+
+	inspector := NewStreamInspector(client, "")
+
+	// Nobody has subscribed yet, so there is no group and nothing pending
+	snapshot, err := inspector.Inspect(ctx, domain.EventTypePaymentProcessed, StreamQuery{Count: 50})
+	require.NoError(t, err)
+	assert.Equal(t, "events:payment.processed", snapshot.Stream)
+	require.Len(t, snapshot.Entries, 3)
+	assert.Empty(t, snapshot.Pending)
+
+	first := snapshot.Entries[0]
+	assert.Equal(t, domain.EventTypePaymentProcessed, first.Fields["event_type"])
+	var decoded domain.PaymentProcessedEvent
+	require.NoError(t, json.Unmarshal(first.Payload, &decoded))
+	assert.Equal(t, "TXN1", decoded.Payload.TransactionReference)
+	assert.Equal(t, int64(1000), decoded.Payload.Amount)
+
+	// A worker reads two messages and acknowledges neither
+	require.NoError(t, client.XGroupCreateMkStream(ctx, snapshot.Stream, consumerGroup, "0").Err())
+	require.NoError(t, client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: consumerGroup, Consumer: "worker-1", Streams: []string{snapshot.Stream, ">"}, Count: 2,
+	}).Err())
+
+	snapshot, err = inspector.Inspect(ctx, domain.EventTypePaymentProcessed, StreamQuery{Count: 50})
+	require.NoError(t, err)
+	require.Len(t, snapshot.Pending, 2)
+	assert.Equal(t, snapshot.Entries[0].ID, snapshot.Pending[0].ID)
+	assert.Equal(t, "worker-1", snapshot.Pending[0].Consumer)
+	assert.Equal(t, int64(1), snapshot.Pending[0].Deliveries)
+
+	// The range and count bound both lists
+	second := snapshot.Entries[1].ID
+	snapshot, err = inspector.Inspect(ctx, domain.EventTypePaymentProcessed, StreamQuery{From: second, Count: 1})
+	require.NoError(t, err)
+	require.Len(t, snapshot.Entries, 1)
+	assert.Equal(t, second, snapshot.Entries[0].ID)
+	require.Len(t, snapshot.Pending, 1)
+	assert.Equal(t, second, snapshot.Pending[0].ID)
+}
+
+func TestStreamInspector_EmptyStream(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	snapshot, err := NewStreamInspector(client, "").Inspect(context.Background(), domain.EventTypePaymentFlagged, StreamQuery{Count: 10})
+	require.NoError(t, err)
+	assert.Empty(t, snapshot.Entries)
+	assert.Empty(t, snapshot.Pending)
+}
@@ -0,0 +1,155 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+// reclaimConsumer is the group member stuck messages are claimed to while
+// they are re-queued
+const reclaimConsumer = "admin-reclaimer"
+
+// StreamReclaimer takes back messages a worker read but never acknowledged,
+// usually because it died mid-batch. Workers only read new messages, so a
+// claimed message is re-queued: a copy is appended for the group to
+// deliver as usual and the original acknowledged. Handlers skip event IDs
+// they have already handled, so a copy of one that did finish is harmless.
+type StreamReclaimer struct {
+	client redis.UniversalClient
+	keys   keyspace.Prefix
+}
+
+func NewStreamReclaimer(client redis.UniversalClient, keys keyspace.Prefix) *StreamReclaimer {
+	return &StreamReclaimer{client: client, keys: keys}
+}
+
+// RequeuedEntry pairs a claimed message with the copy that replaced it
+type RequeuedEntry struct {
+	ID    string
+	NewID string
+}
+
+type ClaimResult struct {
+	Stream   string
+	Requeued []RequeuedEntry
+	// Deleted lists pending messages trimmed from the stream before they
+	// were handled; they are dropped from the pending list
+	Deleted []string
+}
+
+// Claim re-queues up to count messages that have been pending for at least
+// minIdle, oldest first. A stream nobody has subscribed to yet has nothing
+// to claim. Messages re-queued before an error stay re-queued and are
+// listed in the result.
+func (c *StreamReclaimer) Claim(ctx context.Context, eventType string, minIdle time.Duration, count int64) (*ClaimResult, error) {
+	result := &ClaimResult{Stream: streamKey(c.keys, eventType), Requeued: []RequeuedEntry{}, Deleted: []string{}}
+
+	start := "0-0"
+	for remaining := count; remaining > 0; {
+		next, messages, deleted, err := c.autoClaim(ctx, result.Stream, minIdle, start, remaining)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				return result, nil
+			}
+			return result, fmt.Errorf("failed to claim pending messages: %w", err)
+		}
+		result.Deleted = append(result.Deleted, deleted...)
+
+		for _, message := range messages {
+			newID, err := c.requeue(ctx, result.Stream, message)
+			if err != nil {
+				return result, fmt.Errorf("failed to re-queue message %s: %w", message.ID, err)
+			}
+			result.Requeued = append(result.Requeued, RequeuedEntry{ID: message.ID, NewID: newID})
+		}
+		remaining -= int64(len(messages) + len(deleted))
+
+		if next == "0-0" {
+			break
+		}
+		start = next
+	}
+	return result, nil
+}
+
+// claimedMessage keeps a message's fields in stream order
+type claimedMessage struct {
+	ID     string
+	Fields []interface{}
+}
+
+// autoClaim runs XAUTOCLAIM. It is sent raw because go-redis v8 only reads
+// Redis 6.2's two-part reply; Redis 7 adds a third listing the IDs it
+// dropped because their messages were trimmed, which 6.2 sends as nil
+// entries instead. Both are reported as deleted.
+func (c *StreamReclaimer) autoClaim(ctx context.Context, stream string, minIdle time.Duration, start string, count int64) (next string, messages []claimedMessage, deleted []string, err error) {
+	reply, err := c.client.Do(ctx, "XAUTOCLAIM", stream, consumerGroup, reclaimConsumer,
+		minIdle.Milliseconds(), start, "COUNT", count).Slice()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(reply) < 2 {
+		return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM reply: %v", reply)
+	}
+
+	next, _ = reply[0].(string)
+	entries, _ := reply[1].([]interface{})
+	for _, raw := range entries {
+		entry, ok := raw.([]interface{})
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		id, _ := entry[0].(string)
+		fields, _ := entry[1].([]interface{})
+		if fields == nil {
+			deleted = append(deleted, id)
+			continue
+		}
+		messages = append(messages, claimedMessage{ID: id, Fields: fields})
+	}
+	if len(reply) > 2 {
+		ids, _ := reply[2].([]interface{})
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				deleted = append(deleted, s)
+			}
+		}
+	}
+
+	// A 6.2 nil entry is still pending; ack it so it isn't claimed again
+	if len(deleted) > 0 {
+		if err := c.client.XAck(ctx, stream, consumerGroup, deleted...).Err(); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	return next, messages, deleted, nil
+}
+
+// requeue appends a copy of message, marked with the ID it replaces, and
+// acknowledges the original in the same transaction
+func (c *StreamReclaimer) requeue(ctx context.Context, stream string, message claimedMessage) (string, error) {
+	values := make([]interface{}, 0, len(message.Fields)+2)
+	for i := 0; i+1 < len(message.Fields); i += 2 {
+		// A message claimed twice names only the one it replaces
+		if message.Fields[i] != "reclaimed_from" {
+			values = append(values, message.Fields[i], message.Fields[i+1])
+		}
+	}
+	values = append(values, "reclaimed_from", message.ID)
+
+	var add *redis.StringCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		add = pipe.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values})
+		pipe.XAck(ctx, stream, consumerGroup, message.ID)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return add.Val(), nil
+}
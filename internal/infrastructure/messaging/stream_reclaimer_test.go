@@ -0,0 +1,91 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStreamReclaimer_RequeuesMessagesOfADeadConsumer(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	start := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	mr.SetTime(start)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop())
+	stream := "events:" + domain.EventTypePaymentProcessed
+
+	var published []string
+	for i := 1; i <= 3; i++ {
+		event := domain.NewPaymentProcessedEvent("GIG00001", domain.PaymentProcessedPayload{
+			CustomerID: "GIG00001", TransactionReference: fmt.Sprintf("TXN%d", i), Amount: 1000,
+		}, start)
+		published = append(published, event.GetEventID())
+		require.NoError(t, publisher.Publish(ctx, event))
+	}
+
+	// A worker reads every message and dies before acknowledging any
+	require.NoError(t, client.XGroupCreateMkStream(ctx, stream, consumerGroup, "0").Err())
+	require.NoError(t, client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: consumerGroup, Consumer: "dead-worker", Streams: []string{stream, ">"}, Count: 10,
+	}).Err())
+	original, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+
+	reclaimer := NewStreamReclaimer(client, "")
+
+	// Not idle long enough yet
+	mr.SetTime(start.Add(time.Minute))
+	result, err := reclaimer.Claim(ctx, domain.EventTypePaymentProcessed, 5*time.Minute, 100)
+	require.NoError(t, err)
+	assert.Empty(t, result.Requeued)
+
+	mr.SetTime(start.Add(10 * time.Minute))
+	result, err = reclaimer.Claim(ctx, domain.EventTypePaymentProcessed, 5*time.Minute, 2)
+	require.NoError(t, err)
+	assert.Equal(t, stream, result.Stream)
+	require.Len(t, result.Requeued, 2, "count caps the claim")
+	assert.Equal(t, original[0].ID, result.Requeued[0].ID)
+	assert.Equal(t, original[1].ID, result.Requeued[1].ID)
+
+	result, err = reclaimer.Claim(ctx, domain.EventTypePaymentProcessed, 5*time.Minute, 100)
+	require.NoError(t, err)
+	require.Len(t, result.Requeued, 1)
+	assert.Equal(t, original[2].ID, result.Requeued[0].ID)
+
+	pending, err := client.XPending(ctx, stream, consumerGroup).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "the originals are acknowledged")
+
+	copies, err := client.XRange(ctx, stream, "("+original[2].ID, "+").Result()
+	require.NoError(t, err)
+	require.Len(t, copies, 3)
+	assert.Equal(t, original[0].ID, copies[0].Values["reclaimed_from"])
+	assert.Equal(t, original[0].Values["data"], copies[0].Values["data"])
+
+	// A live worker picks the copies up as new messages
+	subscriber := NewRedisEventSubscriber(client, zap.NewNop(), "live-worker")
+	var handled []string
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(_ context.Context, event domain.DomainEvent) error {
+		handled = append(handled, event.GetEventID())
+		return nil
+	}))
+	require.NoError(t, subscriber.processEvents(ctx))
+	assert.Equal(t, published, handled)
+}
+
+func TestStreamReclaimer_NoGroupHasNothingToClaim(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	result, err := NewStreamReclaimer(client, "").Claim(context.Background(), domain.EventTypePaymentProcessed, time.Minute, 100)
+
+	require.NoError(t, err)
+	assert.Empty(t, result.Requeued)
+	assert.Empty(t, result.Deleted)
+}
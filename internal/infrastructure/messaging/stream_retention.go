@@ -0,0 +1,112 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamRetention bounds how much history an events:<type> stream keeps.
+// The zero value keeps everything.
+type StreamRetention struct {
+	// MaxLen caps the number of entries; zero leaves it uncapped
+	MaxLen int64
+	// MaxAge drops entries older than this; zero keeps entries of any age
+	MaxAge time.Duration
+	// Approx lets Redis trim whole macro nodes only, which is much cheaper
+	// but can leave a few more entries than the limits allow
+	Approx bool
+}
+
+// DefaultStreamRetention is what every stream keeps unless configured
+// otherwise: about the last 100k events
+var DefaultStreamRetention = StreamRetention{MaxLen: 100000, Approx: true}
+
+// StreamRetentionPolicy picks the retention for each event type
+type StreamRetentionPolicy struct {
+	Default StreamRetention
+	// ByType overrides Default for individual event types
+	ByType map[string]StreamRetention
+}
+
+// For returns the retention that applies to eventType's stream
+func (p StreamRetentionPolicy) For(eventType string) StreamRetention {
+	if r, ok := p.ByType[eventType]; ok {
+		return r
+	}
+	return p.Default
+}
+
+// ParseStreamRetention builds a policy from defaults and per-type overrides
+// written as <event_type>=<max_len> or <event_type>=<max_len>/<max_age>,
+// e.g. "payment.received=500000" or "customer.updated=0/720h". An override
+// keeps the default's Approx.
+func ParseStreamRetention(defaults StreamRetention, overrides []string) (StreamRetentionPolicy, error) {
+	policy := StreamRetentionPolicy{Default: defaults, ByType: make(map[string]StreamRetention, len(overrides))}
+	for _, override := range overrides {
+		eventType, limits, ok := strings.Cut(override, "=")
+		eventType = strings.TrimSpace(eventType)
+		if !ok || eventType == "" {
+			return StreamRetentionPolicy{}, fmt.Errorf("stream retention %q must be <event_type>=<max_len>[/<max_age>]", override)
+		}
+		if _, dup := policy.ByType[eventType]; dup {
+			return StreamRetentionPolicy{}, fmt.Errorf("stream retention for %s is set twice", eventType)
+		}
+
+		retention := StreamRetention{Approx: defaults.Approx}
+		maxLen, maxAge, hasAge := strings.Cut(limits, "/")
+		n, err := strconv.ParseInt(strings.TrimSpace(maxLen), 10, 64)
+		if err != nil || n < 0 {
+			return StreamRetentionPolicy{}, fmt.Errorf("stream retention for %s: max length must be a non-negative integer, got %q", eventType, maxLen)
+		}
+		retention.MaxLen = n
+		if hasAge {
+			d, err := time.ParseDuration(strings.TrimSpace(maxAge))
+			if err != nil || d < 0 {
+				return StreamRetentionPolicy{}, fmt.Errorf("stream retention for %s: max age must be a non-negative duration, got %q", eventType, maxAge)
+			}
+			retention.MaxAge = d
+		}
+
+		policy.ByType[eventType] = retention
+	}
+	return policy, nil
+}
+
+// add appends values to stream and trims it to r. Redis allows one trim
+// strategy per XADD, so with both limits set the age limit is applied by
+// an XTRIM MINID in the same round trip.
+func (r StreamRetention) add(ctx context.Context, client redis.UniversalClient, stream string, values map[string]interface{}, now time.Time) error {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: r.MaxLen,
+		Approx: r.Approx,
+		Values: values,
+	}
+	if r.MaxAge <= 0 {
+		return client.XAdd(ctx, args).Err()
+	}
+
+	// Stream IDs start with the entry's millisecond timestamp
+	minID := fmt.Sprintf("%d-0", now.Add(-r.MaxAge).UnixMilli())
+	if r.MaxLen <= 0 {
+		args.MinID = minID
+		return client.XAdd(ctx, args).Err()
+	}
+
+	pipe := client.Pipeline()
+	add := pipe.XAdd(ctx, args)
+	if r.Approx {
+		pipe.XTrimMinIDApprox(ctx, stream, minID, 0)
+	} else {
+		pipe.XTrimMinID(ctx, stream, minID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	return add.Err()
+}
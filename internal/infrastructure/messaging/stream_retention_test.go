@@ -0,0 +1,79 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseStreamRetention(t *testing.T) {
+	defaults := StreamRetention{MaxLen: 100000, Approx: true}
+
+	policy, err := ParseStreamRetention(defaults, []string{"payment.received=500000", " customer.updated = 0/720h"})
+
+	require.NoError(t, err)
+	assert.Equal(t, StreamRetention{MaxLen: 500000, Approx: true}, policy.For(domain.EventTypePaymentReceived))
+	assert.Equal(t, StreamRetention{MaxAge: 720 * time.Hour, Approx: true}, policy.For(domain.EventTypeCustomerUpdated))
+	assert.Equal(t, defaults, policy.For(domain.EventTypePaymentProcessed))
+
+	for _, bad := range []string{"payment.received", "=10", "payment.received=lots", "payment.received=-1", "payment.received=10/soon"} {
+		_, err := ParseStreamRetention(defaults, []string{bad})
+		assert.Error(t, err, bad)
+	}
+	_, err = ParseStreamRetention(defaults, []string{"payment.received=1", "payment.received=2"})
+	assert.ErrorContains(t, err, "set twice")
+}
+
+func TestPublish_TrimsEachStreamToItsRetention(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	now := time.Date(2025, 11, 24, 12, 0, 0, 0, time.UTC)
+	publisher := NewRedisEventPublisher(client, "", zap.NewNop(), WithStreamRetention(StreamRetentionPolicy{
+		Default: StreamRetention{MaxLen: 3},
+		ByType: map[string]StreamRetention{
+			domain.EventTypeCustomerUpdated: {MaxAge: time.Hour},
+			domain.EventTypePaymentFlagged:  {MaxLen: 10, MaxAge: time.Hour},
+		},
+	}))
+	publisher.now = func() time.Time { return now }
+
+	// Entries already in the streams, two hours old
+	old := fmt.Sprintf("%d-0", now.Add(-2*time.Hour).UnixMilli())
+	for _, stream := range []string{"events:" + domain.EventTypeCustomerUpdated, "events:" + domain.EventTypePaymentFlagged} {
+		require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: old, Values: map[string]interface{}{"data": "old"}}).Err())
+	}
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, publisher.Publish(ctx, validProcessedEvent()))
+		require.NoError(t, publisher.Publish(ctx, domain.NewCustomerUpdatedEvent("GIG00001", domain.CustomerUpdatedPayload{CustomerID: "GIG00001"}, now)))
+		require.NoError(t, publisher.Publish(ctx, domain.NewPaymentFlaggedEvent("GIG00001", domain.PaymentFlaggedPayload{CustomerID: "GIG00001"}, now)))
+	}
+
+	length := func(eventType string) int64 {
+		n, err := client.XLen(ctx, "events:"+eventType).Result()
+		require.NoError(t, err)
+		return n
+	}
+	assert.Equal(t, int64(3), length(domain.EventTypePaymentProcessed), "default max length")
+	assert.Equal(t, int64(6), length(domain.EventTypeCustomerUpdated), "only the old entry is past the max age")
+	assert.Equal(t, int64(6), length(domain.EventTypePaymentFlagged), "the age limit applies alongside the length limit")
+
+	for _, eventType := range []string{domain.EventTypeCustomerUpdated, domain.EventTypePaymentFlagged} {
+		first, err := client.XRangeN(ctx, "events:"+eventType, "-", "+", 1).Result()
+		require.NoError(t, err)
+		assert.NotEqual(t, old, first[0].ID, eventType)
+	}
+}
+
+func TestPublish_DefaultRetentionCapsLength(t *testing.T) {
+	publisher := NewRedisEventPublisher(nil, "", zap.NewNop())
+
+	assert.Equal(t, DefaultStreamRetention, publisher.retention.For(domain.EventTypePaymentReceived))
+}
@@ -0,0 +1,129 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	heartbeatKeyPrefix = "worker:heartbeat:"
+	heartbeatScanBatch = 100
+)
+
+// WorkerHeartbeats tracks which workers are alive. Each worker keeps a
+// worker:heartbeat:{consumer} key fresh while its read loop turns; a worker
+// that crashes or gets stuck stops refreshing it and drops out once the
+// TTL passes.
+type WorkerHeartbeats struct {
+	client redis.UniversalClient
+	keys   keyspace.Prefix
+	ttl    time.Duration
+}
+
+func NewWorkerHeartbeats(client redis.UniversalClient, keys keyspace.Prefix, ttl time.Duration) *WorkerHeartbeats {
+	return &WorkerHeartbeats{client: client, keys: keys, ttl: ttl}
+}
+
+// WorkerStatus is what a worker last reported about itself
+type WorkerStatus struct {
+	Consumer  string    `json:"consumer"`
+	StartedAt time.Time `json:"started_at"`
+	BeatAt    time.Time `json:"beat_at"`
+	// ExpiresIn is how long until the worker counts as gone unless it
+	// beats again; it is not stored
+	ExpiresIn time.Duration `json:"-"`
+}
+
+// Beat records that the worker is alive as of status.BeatAt
+func (h *WorkerHeartbeats) Beat(ctx context.Context, status WorkerStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return h.client.Set(ctx, h.keys.Key(heartbeatKeyPrefix+status.Consumer), data, h.ttl).Err()
+}
+
+// Live lists the workers whose heartbeat hasn't expired, by consumer name
+func (h *WorkerHeartbeats) Live(ctx context.Context) ([]WorkerStatus, error) {
+	var (
+		mu      sync.Mutex
+		workers []WorkerStatus
+	)
+	scan := func(ctx context.Context, node redis.UniversalClient) error {
+		var cursor uint64
+		for {
+			batch, next, err := node.Scan(ctx, cursor, h.keys.Key(heartbeatKeyPrefix+"*"), heartbeatScanBatch).Result()
+			if err != nil {
+				return err
+			}
+			for _, key := range batch {
+				status, ok, err := h.read(ctx, node, key)
+				if err != nil {
+					return err
+				}
+				if ok {
+					mu.Lock()
+					workers = append(workers, status)
+					mu.Unlock()
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	}
+
+	// SCAN only walks the node it is sent to, so a cluster is scanned
+	// master by master
+	var err error
+	if cluster, ok := h.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, h.client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan worker heartbeats: %w", err)
+	}
+
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Consumer < workers[j].Consumer })
+	return workers, nil
+}
+
+// read loads one heartbeat; ok is false when it expired after the scan saw it
+func (h *WorkerHeartbeats) read(ctx context.Context, node redis.UniversalClient, key string) (WorkerStatus, bool, error) {
+	pipe := node.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return WorkerStatus{}, false, err
+	}
+
+	data, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return WorkerStatus{}, false, nil
+	}
+	if err != nil {
+		return WorkerStatus{}, false, err
+	}
+
+	var status WorkerStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		// Still alive as far as the key goes; keep the name at least
+		status = WorkerStatus{Consumer: strings.TrimPrefix(key, h.keys.Key(heartbeatKeyPrefix))}
+	}
+	status.ExpiresIn = max(ttl.Val(), 0)
+	return status, true, nil
+}
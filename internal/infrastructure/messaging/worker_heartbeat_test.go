@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWorkerHeartbeats_ListsLiveWorkersUntilTheyExpire(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	heartbeats := NewWorkerHeartbeats(client, "staging", 30*time.Second)
+
+	started := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, heartbeats.Beat(ctx, WorkerStatus{Consumer: "worker-b", StartedAt: started, BeatAt: started.Add(time.Minute)}))
+	require.NoError(t, heartbeats.Beat(ctx, WorkerStatus{Consumer: "worker-a", StartedAt: started, BeatAt: started.Add(time.Minute)}))
+	require.NoError(t, client.Set(ctx, "staging:customer:GIG00001", "{}", 0).Err())
+	assert.True(t, mr.Exists("staging:worker:heartbeat:worker-a"))
+
+	workers, err := heartbeats.Live(ctx)
+	require.NoError(t, err)
+	require.Len(t, workers, 2)
+	assert.Equal(t, "worker-a", workers[0].Consumer)
+	assert.Equal(t, "worker-b", workers[1].Consumer)
+	assert.True(t, started.Equal(workers[0].StartedAt))
+	assert.Equal(t, 30*time.Second, workers[0].ExpiresIn)
+
+	mr.FastForward(20 * time.Second)
+	require.NoError(t, heartbeats.Beat(ctx, WorkerStatus{Consumer: "worker-a", StartedAt: started, BeatAt: started.Add(2 * time.Minute)}))
+	mr.FastForward(15 * time.Second)
+
+	workers, err = heartbeats.Live(ctx)
+	require.NoError(t, err)
+	require.Len(t, workers, 1, "worker-b stopped beating")
+	assert.Equal(t, "worker-a", workers[0].Consumer)
+
+	mr.FastForward(time.Minute)
+	workers, err = heartbeats.Live(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, workers)
+}
+
+func TestStart_WritesHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, client := newTestRedis(t)
+	heartbeats := NewWorkerHeartbeats(client, "", time.Minute)
+
+	subscriber := NewRedisEventSubscriber(client, zap.NewNop(), "worker-1", WithHeartbeat(heartbeats))
+	require.NoError(t, subscriber.Subscribe(ctx, domain.EventTypePaymentProcessed, func(context.Context, domain.DomainEvent) error {
+		return nil
+	}))
+
+	done := make(chan error, 1)
+	go func() { done <- subscriber.Start(ctx) }()
+
+	var workers []WorkerStatus
+	require.Eventually(t, func() bool {
+		workers, _ = heartbeats.Live(context.Background())
+		return len(workers) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "worker-1", workers[0].Consumer)
+	assert.False(t, workers[0].StartedAt.After(workers[0].BeatAt))
+
+	cancel()
+	require.NoError(t, <-done)
+}
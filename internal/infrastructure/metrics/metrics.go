@@ -0,0 +1,204 @@
+// Package metrics is a small, dependency-free metrics registry that renders
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds named collectors
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the process-wide registry served by Handler
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[name]; exists {
+		panic(fmt.Sprintf("metrics: %s already registered", name))
+	}
+	r.collectors[name] = c
+}
+
+// Write renders every collector in name order
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mu.RLock()
+		c := r.collectors[name]
+		r.mu.RUnlock()
+		c.write(w)
+	}
+}
+
+// Handler serves the registry in Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	})
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+// NewCounter creates a counter on the Default registry
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.register(name, c)
+	return c
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(n int64) {
+	if n < 0 {
+		return
+	}
+	c.value.Add(n)
+}
+
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.Value())
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	name string
+	help string
+	bits atomic.Uint64
+}
+
+// NewGauge creates a gauge on the Default registry
+func NewGauge(name, help string) *Gauge {
+	return Default.NewGauge(name, help)
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, strconv.FormatFloat(g.Value(), 'g', -1, 64))
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	name    string
+	help    string
+	bounds  []float64
+	mu      sync.Mutex
+	counts  []uint64
+	sum     float64
+	samples uint64
+}
+
+// ExponentialBuckets returns count upper bounds starting at start, each
+// factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds
+}
+
+// NewHistogram creates a histogram on the Default registry
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	return Default.NewHistogram(name, help, bounds)
+}
+
+func (r *Registry) NewHistogram(name, help string, bounds []float64) *Histogram {
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	h := &Histogram{name: name, help: help, bounds: sorted, counts: make([]uint64, len(sorted))}
+	r.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.samples++
+}
+
+// Count returns how many observations have been made
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.samples
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.samples)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.samples)
+}
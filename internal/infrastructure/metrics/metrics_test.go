@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter_RendersPrometheusText(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("things_total", "Things seen.")
+	counter.Inc()
+	counter.Add(2)
+	counter.Add(-5)
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Equal(t, int64(3), counter.Value())
+	assert.Contains(t, body, "# TYPE things_total counter\n")
+	assert.True(t, strings.HasSuffix(body, "things_total 3\n"))
+}
+
+func TestRegistry_DuplicateNamePanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("dup_total", "")
+	assert.Panics(t, func() { registry.NewCounter("dup_total", "") })
+}
+
+func TestHistogram_RendersCumulativeBuckets(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.NewHistogram("latency_seconds", "Latency.", ExponentialBuckets(0.1, 2, 3))
+	histogram.Observe(0.05)
+	histogram.Observe(0.15)
+	histogram.Observe(0.3)
+	histogram.Observe(5)
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, uint64(4), histogram.Count())
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="0.2"} 2
+latency_seconds_bucket{le="0.4"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 5.5
+latency_seconds_count 4
+`, rec.Body.String())
+}
+
+func TestGauge_RendersLastValue(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.NewGauge("ratio", "A ratio.")
+	gauge.Set(0.25)
+	gauge.Set(0.75)
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 0.75, gauge.Value())
+	assert.Equal(t, "# HELP ratio A ratio.\n# TYPE ratio gauge\nratio 0.75\n", rec.Body.String())
+}
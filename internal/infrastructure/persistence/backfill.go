@@ -0,0 +1,78 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+// Columns BackfillProcessedAt can copy processed_at from
+const (
+	BackfillFromCreatedAt       = "created_at"
+	BackfillFromTransactionDate = "transaction_date"
+)
+
+// BackfillOptions tunes BackfillProcessedAt
+type BackfillOptions struct {
+	// BatchSize is how many rows are updated per statement
+	BatchSize int
+	// Source is the column processed_at is copied from
+	Source string
+	// OnBatch, if set, is called after each batch with the rows updated so far
+	OnBatch func(batch, updated int64)
+}
+
+// BackfillProcessedAt sets processed_at on COMPLETE payments that predate
+// MarkAsProcessed. Only NULL rows are touched, so it is safe to re-run and
+// to interrupt between batches.
+func BackfillProcessedAt(ctx context.Context, db *gorm.DB, opts BackfillOptions) (int64, error) {
+	if opts.BatchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive, got %d", opts.BatchSize)
+	}
+	switch opts.Source {
+	case BackfillFromCreatedAt, BackfillFromTransactionDate:
+	default:
+		return 0, fmt.Errorf("unsupported source column %q (want %s or %s)",
+			opts.Source, BackfillFromCreatedAt, BackfillFromTransactionDate)
+	}
+
+	db = db.WithContext(ctx)
+	// Rows whose source is also NULL are skipped, otherwise they would be
+	// picked up again by every batch
+	pending := func() *gorm.DB {
+		return db.Model(&PaymentModel{}).
+			Where("status = ? AND processed_at IS NULL", string(domain.PaymentStatusComplete)).
+			Where(opts.Source + " IS NOT NULL")
+	}
+
+	var updated, batch int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		var ids []string
+		if err := pending().Order("id").Limit(opts.BatchSize).Pluck("id", &ids).Error; err != nil {
+			return updated, fmt.Errorf("failed to find payments to backfill: %w", err)
+		}
+		if len(ids) == 0 {
+			return updated, nil
+		}
+
+		// Re-checking processed_at keeps a concurrent MarkAsProcessed from
+		// being overwritten
+		result := pending().Where("id IN ?", ids).
+			UpdateColumn("processed_at", gorm.Expr(opts.Source))
+		if result.Error != nil {
+			return updated, fmt.Errorf("failed to backfill batch %d: %w", batch+1, result.Error)
+		}
+
+		batch++
+		updated += result.RowsAffected
+		if opts.OnBatch != nil {
+			opts.OnBatch(batch, updated)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package persistence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func seedBackfillPayments(t *testing.T, db *gorm.DB) (created, txDate, processed time.Time) {
+	t.Helper()
+	require.NoError(t, db.AutoMigrate(&PaymentModel{}))
+
+	created = time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+	txDate = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	processed = time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	var rows []*PaymentModel
+	for i, ref := range []string{"TXN1", "TXN2", "TXN3", "TXN4", "TXN5"} {
+		rows = append(rows, &PaymentModel{
+			ID: "p" + ref, CustomerID: "GIG00001", Amount: int64(1000 * (i + 1)),
+			TransactionReference: ref, TransactionDate: txDate, Status: "COMPLETE", CreatedAt: created,
+		})
+	}
+	rows[3].ProcessedAt = &processed
+	rows[4].Status = "WRITEOFF"
+	require.NoError(t, db.Create(rows).Error)
+	return created, txDate, processed
+}
+
+func processedAtByRef(t *testing.T, db *gorm.DB) map[string]*time.Time {
+	t.Helper()
+	var rows []PaymentModel
+	require.NoError(t, db.Find(&rows).Error)
+	out := make(map[string]*time.Time, len(rows))
+	for i := range rows {
+		out[rows[i].TransactionReference] = rows[i].ProcessedAt
+	}
+	return out
+}
+
+func TestBackfillProcessedAt_FillsOnlyMissingCompletePayments(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	created, _, processed := seedBackfillPayments(t, db)
+
+	var batches []int64
+	updated, err := BackfillProcessedAt(ctx, db, BackfillOptions{
+		BatchSize: 2,
+		Source:    BackfillFromCreatedAt,
+		OnBatch:   func(batch, updated int64) { batches = append(batches, updated) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updated)
+	assert.Equal(t, []int64{2, 3}, batches)
+
+	got := processedAtByRef(t, db)
+	for _, ref := range []string{"TXN1", "TXN2", "TXN3"} {
+		require.NotNil(t, got[ref], ref)
+		assert.True(t, got[ref].Equal(created), ref)
+	}
+	assert.True(t, got["TXN4"].Equal(processed), "already set rows are left alone")
+	assert.Nil(t, got["TXN5"], "only COMPLETE payments are backfilled")
+
+	// A second run finds nothing left to do
+	updated, err = BackfillProcessedAt(ctx, db, BackfillOptions{BatchSize: 2, Source: BackfillFromCreatedAt})
+	require.NoError(t, err)
+	assert.Zero(t, updated)
+	assert.Equal(t, got, processedAtByRef(t, db))
+}
+
+func TestBackfillProcessedAt_FromTransactionDate(t *testing.T) {
+	db := newTestDB(t)
+	_, txDate, _ := seedBackfillPayments(t, db)
+
+	updated, err := BackfillProcessedAt(context.Background(), db, BackfillOptions{BatchSize: 100, Source: BackfillFromTransactionDate})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updated)
+	assert.True(t, processedAtByRef(t, db)["TXN1"].Equal(txDate))
+}
+
+func TestBackfillProcessedAt_ResumesAfterInterruption(t *testing.T) {
+	db := newTestDB(t)
+	seedBackfillPayments(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updated, err := BackfillProcessedAt(ctx, db, BackfillOptions{
+		BatchSize: 1,
+		Source:    BackfillFromCreatedAt,
+		OnBatch:   func(int64, int64) { cancel() },
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(1), updated, "the batch done before the interruption is kept")
+
+	updated, err = BackfillProcessedAt(context.Background(), db, BackfillOptions{BatchSize: 1, Source: BackfillFromCreatedAt})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated, "a re-run picks up the rows left")
+	for ref, at := range processedAtByRef(t, db) {
+		if ref != "TXN5" {
+			assert.NotNil(t, at, ref)
+		}
+	}
+}
+
+func TestBackfillProcessedAt_RejectsBadOptions(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := BackfillProcessedAt(context.Background(), db, BackfillOptions{BatchSize: 0, Source: BackfillFromCreatedAt})
+	assert.Error(t, err)
+	_, err = BackfillProcessedAt(context.Background(), db, BackfillOptions{BatchSize: 10, Source: "amount; DROP TABLE payments"})
+	assert.Error(t, err)
+}
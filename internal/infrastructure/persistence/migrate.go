@@ -0,0 +1,157 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the schema this build expects. Bump it whenever a model
+// change needs a migration so API pods can tell they are ahead of the database.
+const SchemaVersion = 9
+
+var (
+	ErrSchemaNotMigrated = errors.New("database has not been migrated")
+	ErrSchemaOutdated    = errors.New("database schema is older than this build")
+	ErrSchemaNewer       = errors.New("database schema is newer than this build")
+)
+
+// SchemaMigrationModel records each schema version applied
+type SchemaMigrationModel struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigrationModel) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate brings the schema up to SchemaVersion, with payments unique
+// within scope, and records it
+func Migrate(ctx context.Context, db *gorm.DB, scope domain.DedupScope) error {
+	db = db.WithContext(ctx)
+
+	if err := db.AutoMigrate(&SchemaMigrationModel{}, &CustomerModel{}, &PaymentModel{}, &CustomerCreditModel{}, &EventLogModel{}); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	// Credits were unique by reference alone before version 9
+	if db.Migrator().HasIndex(&CustomerCreditModel{}, "idx_customer_credits_transaction_reference") {
+		if err := db.Migrator().DropIndex(&CustomerCreditModel{}, "idx_customer_credits_transaction_reference"); err != nil {
+			return fmt.Errorf("failed to drop credit reference index: %w", err)
+		}
+	}
+	if err := ApplyDedupScope(ctx, db, scope); err != nil {
+		return err
+	}
+
+	return recordSchemaVersions(db)
+}
+
+// recordSchemaVersions records every version up to SchemaVersion not yet
+// recorded, so the history matches the migration files even for databases
+// whose earlier migrations skipped a row
+func recordSchemaVersions(db *gorm.DB) error {
+	var recorded []int
+	if err := db.Model(&SchemaMigrationModel{}).Pluck("version", &recorded).Error; err != nil {
+		return fmt.Errorf("failed to read schema versions: %w", err)
+	}
+	have := make(map[int]bool, len(recorded))
+	for _, version := range recorded {
+		have[version] = true
+	}
+
+	now := time.Now()
+	for version := 1; version <= SchemaVersion; version++ {
+		if have[version] {
+			continue
+		}
+		if err := db.Create(&SchemaMigrationModel{Version: version, AppliedAt: now}).Error; err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// globalReferenceIndex makes a transaction reference unique across every
+// customer's payments
+const globalReferenceIndex = "idx_payments_transaction_reference"
+
+// globalReferenceIndexNames are the names that index has gone by: the
+// column constraint migrations/001 creates and the one GORM created
+var globalReferenceIndexNames = []string{"transaction_reference", globalReferenceIndex}
+
+// ApplyDedupScope makes payments enforce unique transaction references
+// within scope. (customer_id, transaction_reference) is always unique;
+// global scope adds a unique index on the reference alone, which customer
+// scope drops. Going back to global fails while two customers share a
+// reference.
+func ApplyDedupScope(ctx context.Context, db *gorm.DB, scope domain.DedupScope) error {
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	var existing []string
+	for _, name := range globalReferenceIndexNames {
+		if migrator.HasIndex(&PaymentModel{}, name) {
+			existing = append(existing, name)
+		}
+	}
+
+	if scope.PerCustomer() {
+		for _, name := range existing {
+			if err := migrator.DropIndex(&PaymentModel{}, name); err != nil {
+				return fmt.Errorf("failed to drop global transaction reference index: %w", err)
+			}
+		}
+		return nil
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+	if err := db.Exec("CREATE UNIQUE INDEX " + globalReferenceIndex + " ON payments (transaction_reference)").Error; err != nil {
+		return fmt.Errorf("failed to create global transaction reference index: %w", err)
+	}
+	return nil
+}
+
+// CurrentSchemaVersion returns the newest version recorded in the database,
+// or 0 if it has never been migrated
+func CurrentSchemaVersion(ctx context.Context, db *gorm.DB) (int, error) {
+	db = db.WithContext(ctx)
+
+	if !db.Migrator().HasTable(&SchemaMigrationModel{}) {
+		return 0, nil
+	}
+
+	var version int
+	if err := db.Model(&SchemaMigrationModel{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// CheckSchemaVersion compares the database's schema version with the one
+// this build expects
+func CheckSchemaVersion(ctx context.Context, db *gorm.DB) (int, error) {
+	version, err := CurrentSchemaVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	return version, compareSchemaVersion(version, SchemaVersion)
+}
+
+func compareSchemaVersion(have, want int) error {
+	switch {
+	case have == 0:
+		return ErrSchemaNotMigrated
+	case have < want:
+		return fmt.Errorf("%w: database at %d, build expects %d", ErrSchemaOutdated, have, want)
+	case have > want:
+		return fmt.Errorf("%w: database at %d, build expects %d", ErrSchemaNewer, have, want)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestCompareSchemaVersion(t *testing.T) {
+	assert.NoError(t, compareSchemaVersion(3, 3))
+	assert.ErrorIs(t, compareSchemaVersion(0, 3), ErrSchemaNotMigrated)
+	assert.ErrorIs(t, compareSchemaVersion(2, 3), ErrSchemaOutdated)
+	assert.ErrorIs(t, compareSchemaVersion(4, 3), ErrSchemaNewer)
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	_, err := CheckSchemaVersion(ctx, db)
+	assert.ErrorIs(t, err, ErrSchemaNotMigrated)
+
+	require.NoError(t, Migrate(ctx, db, domain.DedupScopeGlobal))
+	require.NoError(t, Migrate(ctx, db, domain.DedupScopeGlobal), "migrating twice is harmless")
+
+	version, err := CheckSchemaVersion(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+
+	require.NoError(t, db.Create(&SchemaMigrationModel{Version: SchemaVersion + 1}).Error)
+	_, err = CheckSchemaVersion(ctx, db)
+	assert.ErrorIs(t, err, ErrSchemaNewer)
+
+	require.NoError(t, db.Where("version > ?", 0).Delete(&SchemaMigrationModel{}).Error)
+	require.NoError(t, db.Create(&SchemaMigrationModel{Version: SchemaVersion - 1}).Error)
+	_, err = CheckSchemaVersion(ctx, db)
+	assert.ErrorIs(t, err, ErrSchemaOutdated)
+}
+
+func recordedVersions(t *testing.T, db *gorm.DB) []int {
+	t.Helper()
+	var versions []int
+	require.NoError(t, db.Model(&SchemaMigrationModel{}).Order("version").Pluck("version", &versions).Error)
+	return versions
+}
+
+func allSchemaVersions() []int {
+	versions := make([]int, SchemaVersion)
+	for i := range versions {
+		versions[i] = i + 1
+	}
+	return versions
+}
+
+func TestMigrate_RecordsEveryVersion(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	require.NoError(t, Migrate(ctx, db, domain.DedupScopeGlobal))
+	assert.Equal(t, allSchemaVersions(), recordedVersions(t, db))
+
+	// A database set up by the SQL files when 003 recorded 2 instead of 3
+	require.NoError(t, db.Where("version IN ?", []int{1, 3}).Delete(&SchemaMigrationModel{}).Error)
+	require.NoError(t, Migrate(ctx, db, domain.DedupScopeGlobal))
+	assert.Equal(t, allSchemaVersions(), recordedVersions(t, db), "gaps in the history are filled")
+}
+
+// schemaMigrationStatement matches the statements in a migration file that
+// touch schema_migrations
+var schemaMigrationStatement = regexp.MustCompile(`(?is)(CREATE TABLE IF NOT EXISTS schema_migrations|INSERT IGNORE INTO schema_migrations)[^;]*;`)
+
+// TestMigrationFiles_RecordTheirOwnVersions applies the version bookkeeping
+// of every file in migrations/, in order, and checks each records its own
+// number. The rest of each file is MySQL-only and is left to cmd/migrate.
+func TestMigrationFiles_RecordTheirOwnVersions(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "migrations", "*.sql"))
+	require.NoError(t, err)
+	sort.Strings(files)
+	require.Len(t, files, SchemaVersion, "one migration file per schema version")
+
+	db := newTestDB(t)
+	mysqlOnly := regexp.MustCompile(`(?s)\)\s*ENGINE=[^;]*;`)
+	for i, file := range files {
+		version, err := strconv.Atoi(strings.SplitN(filepath.Base(file), "_", 2)[0])
+		require.NoError(t, err, file)
+		require.Equal(t, i+1, version, "migration files are numbered without gaps")
+
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		for _, stmt := range schemaMigrationStatement.FindAllString(string(content), -1) {
+			stmt = mysqlOnly.ReplaceAllString(stmt, ");")
+			stmt = strings.Replace(stmt, "INSERT IGNORE", "INSERT OR IGNORE", 1)
+			require.NoError(t, db.Exec(stmt).Error, file)
+		}
+
+		if version < 3 {
+			continue
+		}
+		current, err := CurrentSchemaVersion(context.Background(), db)
+		require.NoError(t, err)
+		assert.Equal(t, version, current, "%s records its own version", filepath.Base(file))
+	}
+	assert.Equal(t, allSchemaVersions(), recordedVersions(t, db))
+}
+
+func TestApplyDedupScope(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	require.NoError(t, Migrate(ctx, db, domain.DedupScopeGlobal))
+
+	payment := func(id, customerID string) *PaymentModel {
+		return &PaymentModel{ID: id, CustomerID: customerID, Amount: 100, TransactionReference: "TXN001",
+			TransactionDate: time.Now(), Status: "COMPLETE", CurrencyCode: "NGN"}
+	}
+	require.NoError(t, db.Create(payment("p1", "GIG00001")).Error)
+	assert.Error(t, db.Create(payment("p2", "GIG00002")).Error, "global scope refuses the reference for another customer")
+
+	require.NoError(t, Migrate(ctx, db, domain.DedupScopeCustomer))
+	assert.False(t, db.Migrator().HasIndex(&PaymentModel{}, globalReferenceIndex))
+	require.NoError(t, db.Create(payment("p2", "GIG00002")).Error)
+	assert.Error(t, db.Create(payment("p3", "GIG00002")).Error, "still unique per customer")
+
+	err := ApplyDedupScope(ctx, db, domain.DedupScopeGlobal)
+	assert.ErrorContains(t, err, "global transaction reference index", "two customers share a reference")
+}
@@ -9,6 +9,7 @@ import (
 // CustomerModel represents the database schema for customers
 type CustomerModel struct {
 	ID                 string    `gorm:"primaryKey;type:varchar(50)"`
+	BorrowerID         *string   `gorm:"type:varchar(50);index"`
 	AssetValue         int64     `gorm:"not null"`
 	OutstandingBalance int64     `gorm:"not null"`
 	TotalPaid          int64     `gorm:"not null;default:0"`
@@ -17,6 +18,7 @@ type CustomerModel struct {
 	LastPaymentDate    *time.Time
 	Status             string    `gorm:"type:varchar(20);not null;index"`
 	Version            int64     `gorm:"not null;default:1"`
+	CurrencyCode       string    `gorm:"type:char(3);not null;default:'NGN'"`
 	CreatedAt          time.Time `gorm:"autoCreateTime"`
 	UpdatedAt          time.Time `gorm:"autoUpdateTime"`
 }
@@ -27,7 +29,7 @@ func (CustomerModel) TableName() string {
 
 // ToDomain converts database model to domain entity
 func (m *CustomerModel) ToDomain() *domain.Customer {
-	return &domain.Customer{
+	customer := &domain.Customer{
 		ID:                 m.ID,
 		AssetValue:         m.AssetValue,
 		OutstandingBalance: m.OutstandingBalance,
@@ -37,12 +39,17 @@ func (m *CustomerModel) ToDomain() *domain.Customer {
 		LastPaymentDate:    m.LastPaymentDate,
 		Status:             domain.CustomerStatus(m.Status),
 		Version:            m.Version,
+		CurrencyCode:       m.CurrencyCode,
 	}
+	if m.BorrowerID != nil {
+		customer.BorrowerID = *m.BorrowerID
+	}
+	return customer
 }
 
 // FromDomain converts domain entity to database model
 func CustomerModelFromDomain(customer *domain.Customer) *CustomerModel {
-	return &CustomerModel{
+	model := &CustomerModel{
 		ID:                 customer.ID,
 		AssetValue:         customer.AssetValue,
 		OutstandingBalance: customer.OutstandingBalance,
@@ -52,19 +59,31 @@ func CustomerModelFromDomain(customer *domain.Customer) *CustomerModel {
 		LastPaymentDate:    customer.LastPaymentDate,
 		Status:             string(customer.Status),
 		Version:            customer.Version,
+		CurrencyCode:       customer.Currency().Code,
+	}
+	if customer.BorrowerID != "" {
+		model.BorrowerID = &customer.BorrowerID
 	}
+	return model
 }
 
 // PaymentModel represents the database schema for payments
 type PaymentModel struct {
-	ID                   string     `gorm:"primaryKey;type:varchar(50)"`
-	CustomerID           string     `gorm:"type:varchar(50);not null;index"`
-	Amount               int64      `gorm:"not null"`
-	TransactionReference string     `gorm:"type:varchar(100);uniqueIndex;not null"`
-	TransactionDate      time.Time  `gorm:"not null;index"`
+	ID         string `gorm:"primaryKey;type:varchar(50);index:idx_payments_customer_date_id,priority:3;index:idx_payments_created_id,priority:2"`
+	CustomerID string `gorm:"type:varchar(50);not null;index;index:idx_payments_customer_date_id,priority:1;uniqueIndex:idx_payments_customer_ref,priority:1"`
+	Amount     int64  `gorm:"not null"`
+	// TransactionReference is unique per customer here; ApplyDedupScope
+	// decides whether it is also unique across customers
+	TransactionReference string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_payments_customer_ref,priority:2"`
+	TransactionDate      time.Time  `gorm:"not null;index;index:idx_payments_customer_date_id,priority:2"`
 	Status               string     `gorm:"type:varchar(20);not null"`
 	ProcessedAt          *time.Time `gorm:"index"`
-	CreatedAt            time.Time  `gorm:"autoCreateTime"`
+	CreatedAt            time.Time  `gorm:"autoCreateTime;index:idx_payments_created_id,priority:1"`
+	// BalanceAfter is NULL on rows recorded before it was captured
+	BalanceAfter *int64
+	CurrencyCode string `gorm:"type:char(3);not null;default:'NGN'"`
+	// Metadata is NULL when the payment came without any
+	Metadata map[string]string `gorm:"type:json;serializer:json"`
 }
 
 func (PaymentModel) TableName() string {
@@ -81,6 +100,9 @@ func (m *PaymentModel) ToDomain() *domain.Payment {
 		TransactionDate:      m.TransactionDate,
 		Status:               domain.PaymentStatus(m.Status),
 		CreatedAt:            m.CreatedAt,
+		BalanceAfter:         m.BalanceAfter,
+		CurrencyCode:         m.CurrencyCode,
+		Metadata:             m.Metadata,
 	}
 	if m.ProcessedAt != nil {
 		payment.ProcessedAt = *m.ProcessedAt
@@ -98,9 +120,44 @@ func PaymentModelFromDomain(payment *domain.Payment) *PaymentModel {
 		TransactionDate:      payment.TransactionDate,
 		Status:               string(payment.Status),
 		CreatedAt:            payment.CreatedAt,
+		BalanceAfter:         payment.BalanceAfter,
+		CurrencyCode:         payment.Currency().Code,
+	}
+	if len(payment.Metadata) > 0 {
+		model.Metadata = payment.Metadata
 	}
 	if !payment.ProcessedAt.IsZero() {
 		model.ProcessedAt = &payment.ProcessedAt
 	}
 	return model
 }
+
+// CustomerCreditModel is one refundable credit; the unique customer and
+// transaction reference keep a retried payment from being credited twice
+type CustomerCreditModel struct {
+	ID                   uint      `gorm:"primaryKey;autoIncrement"`
+	CustomerID           string    `gorm:"type:varchar(50);not null;index;uniqueIndex:idx_customer_credits_customer_ref,priority:1"`
+	TransactionReference string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_customer_credits_customer_ref,priority:2"`
+	Amount               int64     `gorm:"not null"`
+	CreatedAt            time.Time `gorm:"not null"`
+}
+
+func (CustomerCreditModel) TableName() string {
+	return "customer_credits"
+}
+
+// EventLogModel is one published domain event. Rows are only ever
+// inserted, and Sequence is the order they were logged in.
+type EventLogModel struct {
+	Sequence    int64     `gorm:"primaryKey;autoIncrement"`
+	EventID     string    `gorm:"type:varchar(36);uniqueIndex;not null"`
+	EventType   string    `gorm:"type:varchar(64);not null;index"`
+	AggregateID string    `gorm:"type:varchar(100);not null"`
+	Payload     string    `gorm:"type:json;not null"`
+	OccurredAt  time.Time `gorm:"not null"`
+	RecordedAt  time.Time `gorm:"not null"`
+}
+
+func (EventLogModel) TableName() string {
+	return "event_log"
+}
@@ -0,0 +1,74 @@
+// Package redisclient builds the Redis client for the deployed topology:
+// a single node, a Sentinel-managed master, or a Cluster.
+package redisclient
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type Mode string
+
+const (
+	ModeSingle   Mode = "single"
+	ModeSentinel Mode = "sentinel"
+	ModeCluster  Mode = "cluster"
+)
+
+var ErrUnknownMode = errors.New("unknown redis mode")
+
+type Options struct {
+	Mode Mode
+	// Addrs is the node address in single mode, the sentinels in sentinel
+	// mode, and the cluster seed nodes in cluster mode
+	Addrs []string
+	// MasterName is the Sentinel master to follow; sentinel mode only
+	MasterName string
+	Password   string
+	// DB must be 0 in cluster mode, which has no SELECT
+	DB       int
+	PoolSize int
+}
+
+// New returns a client for opts.Mode. The empty mode means single.
+func New(opts Options) (redis.UniversalClient, error) {
+	if len(opts.Addrs) == 0 {
+		return nil, errors.New("redis address is required")
+	}
+
+	switch opts.Mode {
+	case ModeSingle, "":
+		return redis.NewClient(&redis.Options{
+			Addr:     opts.Addrs[0],
+			Password: opts.Password,
+			DB:       opts.DB,
+			PoolSize: opts.PoolSize,
+		}), nil
+
+	case ModeSentinel:
+		if opts.MasterName == "" {
+			return nil, errors.New("redis sentinel mode requires a master name")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    opts.MasterName,
+			SentinelAddrs: opts.Addrs,
+			Password:      opts.Password,
+			DB:            opts.DB,
+			PoolSize:      opts.PoolSize,
+		}), nil
+
+	case ModeCluster:
+		if opts.DB != 0 {
+			return nil, errors.New("redis cluster mode does not support a non-zero DB")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    opts.Addrs,
+			Password: opts.Password,
+			PoolSize: opts.PoolSize,
+		}), nil
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownMode, opts.Mode)
+}
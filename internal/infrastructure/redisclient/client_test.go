@@ -0,0 +1,64 @@
+package redisclient
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Single(t *testing.T) {
+	for _, mode := range []Mode{ModeSingle, ""} {
+		client, err := New(Options{Mode: mode, Addrs: []string{"redis:6379"}, DB: 2, PoolSize: 7})
+		require.NoError(t, err)
+		defer client.Close()
+
+		single, ok := client.(*redis.Client)
+		require.True(t, ok, "single mode should build a plain client")
+		assert.Equal(t, "redis:6379", single.Options().Addr)
+		assert.Equal(t, 2, single.Options().DB)
+		assert.Equal(t, 7, single.Options().PoolSize)
+	}
+}
+
+func TestNew_Sentinel(t *testing.T) {
+	client, err := New(Options{
+		Mode:       ModeSentinel,
+		Addrs:      []string{"sentinel-1:26379", "sentinel-2:26379"},
+		MasterName: "payments",
+		DB:         1,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	failover, ok := client.(*redis.Client)
+	require.True(t, ok)
+	assert.Equal(t, "FailoverClient", failover.Options().Addr)
+	assert.Equal(t, 1, failover.Options().DB)
+
+	_, err = New(Options{Mode: ModeSentinel, Addrs: []string{"sentinel-1:26379"}})
+	assert.Error(t, err, "master name is required")
+}
+
+func TestNew_Cluster(t *testing.T) {
+	client, err := New(Options{Mode: ModeCluster, Addrs: []string{"node-1:6379", "node-2:6379"}, PoolSize: 5})
+	require.NoError(t, err)
+	defer client.Close()
+
+	cluster, ok := client.(*redis.ClusterClient)
+	require.True(t, ok)
+	assert.Equal(t, []string{"node-1:6379", "node-2:6379"}, cluster.Options().Addrs)
+	assert.Equal(t, 5, cluster.Options().PoolSize)
+
+	_, err = New(Options{Mode: ModeCluster, Addrs: []string{"node-1:6379"}, DB: 1})
+	assert.Error(t, err, "cluster has no SELECT")
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Options{Mode: "replicated", Addrs: []string{"redis:6379"}})
+	assert.ErrorIs(t, err, ErrUnknownMode)
+
+	_, err = New(Options{Mode: ModeSingle})
+	assert.Error(t, err)
+}
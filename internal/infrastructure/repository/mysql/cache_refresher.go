@@ -0,0 +1,120 @@
+package sqlrepository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CacheRefreshConfig controls the background refresh of hot customers
+type CacheRefreshConfig struct {
+	// Interval is how often the refresher runs
+	Interval time.Duration
+	// Window is how recently a customer must have been read to count as hot
+	Window time.Duration
+	// Lead is how close to expiry a hot customer is reloaded
+	Lead time.Duration
+	// MaxPerRun caps the customers reloaded per run, bounding the extra
+	// MySQL load to MaxPerRun per Interval
+	MaxPerRun int
+	// KeyPrefix must match the repositories' so refreshed keys are the ones read
+	KeyPrefix keyspace.Prefix
+}
+
+// CustomerCacheRefresher reloads recently read customers from MySQL shortly
+// before their cached copy expires, so hot customers don't go cold every
+// customerCacheTTL. Reads are recorded by repositories built with
+// Config.TrackCustomerAccess.
+type CustomerCacheRefresher struct {
+	source *GORMCustomerRepository
+	cache  *redisrepository.RedisCustomerRepository
+	access *redisrepository.CustomerAccessLog
+	config CacheRefreshConfig
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+func NewCustomerCacheRefresher(db *gorm.DB, redisClient redis.UniversalClient, cfg CacheRefreshConfig, logger *zap.Logger) *CustomerCacheRefresher {
+	return &CustomerCacheRefresher{
+		source: NewCustomerRepository(db, logger),
+		cache:  redisrepository.NewRedisCustomerRepository(redisClient, customerCacheTTL, cfg.KeyPrefix),
+		access: redisrepository.NewCustomerAccessLog(redisClient, cfg.KeyPrefix),
+		config: cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Start refreshes every Interval in the background until ctx is done
+func (r *CustomerCacheRefresher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+					r.logger.Warn("customer cache refresh failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Refresh reloads the hot customers due to expire within Lead, most
+// recently read first and at most MaxPerRun of them, returning how many
+// were cached. Customers not read within Window are forgotten.
+func (r *CustomerCacheRefresher) Refresh(ctx context.Context) (int, error) {
+	since := r.now().Add(-r.config.Window)
+	if _, err := r.access.Forget(ctx, since); err != nil {
+		return 0, err
+	}
+
+	hot, err := r.access.AccessedSince(ctx, since)
+	if err != nil {
+		return 0, err
+	}
+	left, err := r.cache.ExpiresIn(ctx, hot)
+	if err != nil {
+		return 0, err
+	}
+
+	due := make([]string, 0, r.config.MaxPerRun)
+	for _, id := range hot {
+		if len(due) == r.config.MaxPerRun {
+			break
+		}
+		if ttl, ok := left[id]; ok && ttl < r.config.Lead {
+			due = append(due, id)
+		}
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	found, err := r.source.FindByIDs(ctx, due)
+	if err != nil {
+		return 0, err
+	}
+	customers := make([]*domain.Customer, 0, len(found))
+	for _, customer := range found {
+		customers = append(customers, customer)
+	}
+	if err := r.cache.SaveMany(ctx, customers); err != nil {
+		return 0, err
+	}
+
+	r.logger.Debug("customer cache refreshed",
+		zap.Int("hot", len(hot)),
+		zap.Int("refreshed", len(customers)),
+	)
+	return len(customers), nil
+}
@@ -0,0 +1,82 @@
+package sqlrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// cacheSeededCustomers puts the seeded customers in Redis with a fresh TTL
+func cacheSeededCustomers(t *testing.T, env *testEnv, ids ...string) {
+	t.Helper()
+	ctx := context.Background()
+	found, err := env.customerRepository().FindByIDs(ctx, ids)
+	require.NoError(t, err)
+	cache := redisrepository.NewRedisCustomerRepository(env.redis, customerCacheTTL, "")
+	for _, customer := range found {
+		require.NoError(t, cache.Save(ctx, customer))
+	}
+}
+
+func TestCustomerCacheRefresher_RefreshesOnlyRecentlyReadCustomers(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 3)
+	cacheSeededCustomers(t, env, "GIG00001", "GIG00002", "GIG00003")
+
+	repo := env.cachedCustomerRepository()
+	repo.access = redisrepository.NewCustomerAccessLog(env.redis, "")
+	_, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	// Read once, long enough ago to have gone cold
+	require.NoError(t, repo.access.Touch(ctx, "GIG00003", time.Now().Add(-time.Hour)))
+
+	env.mr.FastForward(customerCacheTTL - 30*time.Second)
+
+	refresher := NewCustomerCacheRefresher(env.db, env.redis, CacheRefreshConfig{
+		Window: 10 * time.Minute, Lead: time.Minute, MaxPerRun: 10,
+	}, zap.NewNop())
+	refreshed, err := refresher.Refresh(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, refreshed)
+	assert.Equal(t, customerCacheTTL, env.mr.TTL("customer:GIG00001"), "hot customer reloaded before expiry")
+	assert.Equal(t, 30*time.Second, env.mr.TTL("customer:GIG00002"), "never read, left to expire")
+	assert.Equal(t, 30*time.Second, env.mr.TTL("customer:GIG00003"), "read outside the window, left to expire")
+
+	accessed, err := env.mr.ZMembers("customer_access")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GIG00001"}, accessed, "cold customers are forgotten")
+}
+
+func TestCustomerCacheRefresher_BoundsReloadsPerRun(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 3)
+	access := redisrepository.NewCustomerAccessLog(env.redis, "")
+	now := time.Now()
+	for i, id := range []string{"GIG00001", "GIG00002", "GIG00003"} {
+		require.NoError(t, access.Touch(ctx, id, now.Add(time.Duration(i)*time.Second)))
+	}
+	// Hot customers that have already expired are reloaded too
+	refresher := NewCustomerCacheRefresher(env.db, env.redis, CacheRefreshConfig{
+		Window: 10 * time.Minute, Lead: time.Minute, MaxPerRun: 2,
+	}, zap.NewNop())
+
+	refreshed, err := refresher.Refresh(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, refreshed)
+	assert.True(t, env.mr.Exists("customer:GIG00003"), "most recently read first")
+	assert.True(t, env.mr.Exists("customer:GIG00002"))
+	assert.False(t, env.mr.Exists("customer:GIG00001"))
+
+	refreshed, err = refresher.Refresh(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, refreshed, "the rest wait for the next run")
+	assert.True(t, env.mr.Exists("customer:GIG00001"))
+}
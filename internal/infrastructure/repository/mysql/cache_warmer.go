@@ -0,0 +1,163 @@
+package sqlrepository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CacheWarmConfig controls the startup cache warm-up
+type CacheWarmConfig struct {
+	BatchSize   int
+	Concurrency int
+	// MaxCustomers caps the warm-up to the most recently active customers
+	MaxCustomers int
+	// KeyPrefix must match the repositories' so warmed keys are the ones read
+	KeyPrefix keyspace.Prefix
+}
+
+// CustomerCacheWarmer pre-populates Redis with active customers so the
+// first requests after a deploy don't all fall through to MySQL.
+type CustomerCacheWarmer struct {
+	source *GORMCustomerRepository
+	cache  *redisrepository.RedisCustomerRepository
+	config CacheWarmConfig
+	logger *zap.Logger
+}
+
+func NewCustomerCacheWarmer(db *gorm.DB, redisClient redis.UniversalClient, cfg CacheWarmConfig, logger *zap.Logger) *CustomerCacheWarmer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	return &CustomerCacheWarmer{
+		source: NewCustomerRepository(db, logger),
+		cache:  redisrepository.NewRedisCustomerRepository(redisClient, customerCacheTTL, cfg.KeyPrefix),
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Start warms the cache in the background and returns immediately
+func (w *CustomerCacheWarmer) Start(ctx context.Context) {
+	go func() {
+		if _, err := w.Warm(ctx); err != nil {
+			w.logger.Error("customer cache warm-up failed", zap.Error(err))
+		}
+	}()
+}
+
+// Warm loads active customers batch by batch, most recently updated first,
+// and writes them to Redis, returning how many were cached. Customers the
+// cache already holds at the same or a later version are left alone, so a
+// Save that lands during the warm-up is never overwritten.
+func (w *CustomerCacheWarmer) Warm(ctx context.Context) (int, error) {
+	start := time.Now()
+	warmed, skipped, read := 0, 0, 0
+
+	w.logger.Info("customer cache warm-up started",
+		zap.Int("batch_size", w.config.BatchSize),
+		zap.Int("concurrency", w.config.Concurrency),
+		zap.Int("max_customers", w.config.MaxCustomers),
+	)
+
+	var afterUpdatedAt time.Time
+	var afterID string
+	for batch := 1; ; batch++ {
+		limit := w.config.BatchSize
+		if w.config.MaxCustomers > 0 {
+			remaining := w.config.MaxCustomers - read
+			if remaining <= 0 {
+				break
+			}
+			if remaining < limit {
+				limit = remaining
+			}
+		}
+
+		models, err := w.source.findByStatusBefore(ctx, string(domain.CustomerStatusActive), afterUpdatedAt, afterID, limit)
+		if err != nil {
+			return warmed, err
+		}
+		read += len(models)
+
+		customers := make([]*domain.Customer, len(models))
+		for i := range models {
+			customers[i] = models[i].ToDomain()
+		}
+		cached, stale := w.cacheBatch(ctx, customers)
+		warmed += cached
+		skipped += stale
+
+		w.logger.Info("customer cache warm-up progress",
+			zap.Int("batch", batch),
+			zap.Int("warmed", warmed),
+			zap.Int("skipped", skipped),
+		)
+
+		if len(models) < limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return warmed, err
+		}
+		last := models[len(models)-1]
+		afterUpdatedAt, afterID = last.UpdatedAt, last.ID
+	}
+
+	w.logger.Info("customer cache warm-up finished",
+		zap.Int("warmed", warmed),
+		zap.Int("skipped", skipped),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return warmed, nil
+}
+
+// cacheBatch returns how many customers it cached and how many it skipped
+// because the cache already held them at least as fresh
+func (w *CustomerCacheWarmer) cacheBatch(ctx context.Context, customers []*domain.Customer) (cached, skipped int) {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	sem := make(chan struct{}, w.config.Concurrency)
+
+	for _, customer := range customers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(customer *domain.Customer) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			written, err := w.cache.SaveIfNewer(ctx, customer)
+			if err != nil {
+				w.logger.Warn("failed to warm customer cache",
+					zap.Error(err),
+					zap.String("customer_id", customer.ID),
+				)
+				return
+			}
+			mu.Lock()
+			if written {
+				cached++
+			} else {
+				skipped++
+			}
+			mu.Unlock()
+		}(customer)
+	}
+	wg.Wait()
+
+	return cached, skipped
+}
@@ -0,0 +1,124 @@
+package sqlrepository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// seedCustomers creates active customers GIG00001..n, with higher numbers
+// updated more recently, plus a couple of completed ones.
+func seedCustomers(t *testing.T, env *testEnv, n int) {
+	t.Helper()
+	ctx := context.Background()
+	repo := env.customerRepository()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	create := func(id string, status domain.CustomerStatus, updatedAt time.Time) {
+		require.NoError(t, repo.Create(ctx, &domain.Customer{
+			ID:                 id,
+			AssetValue:         100000000,
+			RepaymentTermWeeks: 50,
+			OutstandingBalance: 100000000,
+			Status:             status,
+		}))
+		require.NoError(t, env.db.Model(&persistence.CustomerModel{}).
+			Where("id = ?", id).
+			UpdateColumn("updated_at", updatedAt).Error)
+	}
+
+	for i := 1; i <= n; i++ {
+		create(fmt.Sprintf("GIG%05d", i), domain.CustomerStatusActive, base.Add(time.Duration(i)*time.Hour))
+	}
+	create("GIG90001", domain.CustomerStatusCompleted, base.Add(100*time.Hour))
+	create("GIG90002", domain.CustomerStatusCompleted, base.Add(101*time.Hour))
+}
+
+func TestCustomerCacheWarmer_WarmsActiveCustomers(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 7)
+
+	warmer := NewCustomerCacheWarmer(env.db, env.redis, CacheWarmConfig{BatchSize: 2, Concurrency: 3}, zap.NewNop())
+	warmed, err := warmer.Warm(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, warmed)
+	for i := 1; i <= 7; i++ {
+		assert.True(t, env.mr.Exists(fmt.Sprintf("customer:GIG%05d", i)))
+	}
+	assert.False(t, env.mr.Exists("customer:GIG90001"))
+	assert.False(t, env.mr.Exists("customer:GIG90002"))
+}
+
+func TestCustomerCacheWarmer_CapsToMostRecentlyActive(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 7)
+
+	warmer := NewCustomerCacheWarmer(env.db, env.redis, CacheWarmConfig{BatchSize: 2, Concurrency: 2, MaxCustomers: 3}, zap.NewNop())
+	warmed, err := warmer.Warm(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, warmed)
+	for i := 5; i <= 7; i++ {
+		assert.True(t, env.mr.Exists(fmt.Sprintf("customer:GIG%05d", i)))
+	}
+	for i := 1; i <= 4; i++ {
+		assert.False(t, env.mr.Exists(fmt.Sprintf("customer:GIG%05d", i)))
+	}
+
+	cached, err := env.cachedCustomerRepository().FindByID(context.Background(), "GIG00007")
+	require.NoError(t, err)
+	assert.Equal(t, domain.CustomerStatusActive, cached.Status)
+}
+
+func TestCustomerCacheWarmer_LeavesFresherEntries(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 4)
+
+	// A Save that landed first holds a later version than MySQL served
+	fresher := &domain.Customer{ID: "GIG00002", OutstandingBalance: 42, Status: domain.CustomerStatusActive, Version: 9}
+	require.NoError(t, redisrepository.NewRedisCustomerRepository(env.redis, customerCacheTTL, "").Save(ctx, fresher))
+
+	warmer := NewCustomerCacheWarmer(env.db, env.redis, CacheWarmConfig{BatchSize: 2, Concurrency: 2}, zap.NewNop())
+	warmed, err := warmer.Warm(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, warmed)
+	cached, err := env.cachedCustomerRepository().FindByID(ctx, "GIG00002")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), cached.OutstandingBalance)
+}
+
+func TestCustomerCacheWarmer_PagesByKeyWhileCustomersChange(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 7)
+
+	// The first customer read completes its loan straight after the first
+	// batch, which would shift every later OFFSET page up by one
+	var once sync.Once
+	require.NoError(t, env.db.Callback().Query().After("gorm:query").Register("test:complete_loan", func(db *gorm.DB) {
+		once.Do(func() {
+			require.NoError(t, env.db.Exec("UPDATE customers SET status = ? WHERE id = ?", domain.CustomerStatusCompleted, "GIG00007").Error)
+		})
+	}))
+
+	warmer := NewCustomerCacheWarmer(env.db, env.redis, CacheWarmConfig{BatchSize: 2, Concurrency: 1}, zap.NewNop())
+	warmed, err := warmer.Warm(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, warmed)
+	for i := 1; i <= 7; i++ {
+		assert.True(t, env.mr.Exists(fmt.Sprintf("customer:GIG%05d", i)), "GIG%05d", i)
+	}
+}
@@ -0,0 +1,41 @@
+package sqlrepository
+
+import (
+	"context"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GORMCreditLedger stores customer credit in MySQL
+type GORMCreditLedger struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewCreditLedger(db *gorm.DB, logger *zap.Logger) *GORMCreditLedger {
+	return &GORMCreditLedger{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (l *GORMCreditLedger) Record(ctx context.Context, credit *domain.CustomerCredit) error {
+	model := &persistence.CustomerCreditModel{
+		CustomerID:           credit.CustomerID,
+		TransactionReference: credit.TransactionReference,
+		Amount:               credit.Amount,
+		CreatedAt:            credit.CreatedAt,
+	}
+
+	if err := l.db.WithContext(ctx).Create(model).Error; err != nil {
+		if isDuplicateError(err) {
+			return domain.ErrDuplicateTransaction
+		}
+		return dbError(ctx, l.logger, "failed to record customer credit", err)
+	}
+
+	return nil
+}
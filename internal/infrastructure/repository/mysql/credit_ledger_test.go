@@ -0,0 +1,27 @@
+package sqlrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCreditLedger_RecordsEachReferenceOnce(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	ledger := NewCreditLedger(env.db, zap.NewNop())
+
+	credit := &domain.CustomerCredit{CustomerID: "GIG00001", TransactionReference: "TXN1", Amount: 600000, CreatedAt: time.Now()}
+	require.NoError(t, ledger.Record(ctx, credit))
+	assert.ErrorIs(t, ledger.Record(ctx, credit), domain.ErrDuplicateTransaction)
+
+	var total int64
+	require.NoError(t, env.db.Model(&persistence.CustomerCreditModel{}).Select("COALESCE(SUM(amount), 0)").Scan(&total).Error)
+	assert.Equal(t, int64(600000), total)
+}
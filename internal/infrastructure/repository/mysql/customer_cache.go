@@ -0,0 +1,240 @@
+package sqlrepository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"go.uber.org/zap"
+)
+
+// customerCacheTTL is how long a customer stays in Redis after a read or write
+const customerCacheTTL = 5 * time.Minute
+
+// customerCacheMetrics counts customer lookups served from Redis versus the
+// wrapped repository
+type customerCacheMetrics struct {
+	hits     *metrics.Counter
+	misses   *metrics.Counter
+	hitRatio *metrics.Gauge
+}
+
+func newCustomerCacheMetrics(registry *metrics.Registry) *customerCacheMetrics {
+	return &customerCacheMetrics{
+		hits:     registry.NewCounter("customer_cache_hits_total", "Customer lookups served from Redis."),
+		misses:   registry.NewCounter("customer_cache_misses_total", "Customer lookups that fell through to MySQL."),
+		hitRatio: registry.NewGauge("customer_cache_hit_ratio", "Share of customer lookups served from Redis since startup."),
+	}
+}
+
+var defaultCustomerCacheMetrics = newCustomerCacheMetrics(metrics.Default)
+
+func (m *customerCacheMetrics) record(hits, misses int) {
+	m.hits.Add(int64(hits))
+	m.misses.Add(int64(misses))
+
+	total := m.hits.Value() + m.misses.Value()
+	if total > 0 {
+		m.hitRatio.Set(float64(m.hits.Value()) / float64(total))
+	}
+}
+
+// CachingCustomerRepository puts Redis in front of a CustomerRepository.
+// Reads try the cache first and backfill it on a miss; writes evict the
+// cached customer before touching the store, so a failed or conflicting
+// write never leaves stale data behind. With a nil cache every call goes
+// straight to the wrapped repository.
+type CachingCustomerRepository struct {
+	next    domain.CustomerRepository
+	cache   *redisrepository.RedisCustomerRepository
+	metrics *customerCacheMetrics
+	logger  *zap.Logger
+	// txTouched is set when next is bound to a transaction. Cache writes are
+	// then deferred: written customers are recorded here and evicted after
+	// commit so the cache never holds uncommitted state.
+	txTouched *touchedCustomers
+	// access, when set, records each FindByID so the cache refresher knows
+	// which customers are hot
+	access *redisrepository.CustomerAccessLog
+}
+
+type touchedCustomers struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (t *touchedCustomers) add(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ids = append(t.ids, id)
+}
+
+func NewCachingCustomerRepository(next domain.CustomerRepository, cache *redisrepository.RedisCustomerRepository, logger *zap.Logger) *CachingCustomerRepository {
+	return &CachingCustomerRepository{
+		next:    next,
+		cache:   cache,
+		metrics: defaultCustomerCacheMetrics,
+		logger:  logger,
+	}
+}
+
+func (r *CachingCustomerRepository) FindByID(ctx context.Context, id string) (*domain.Customer, error) {
+	if r.cache == nil {
+		return r.next.FindByID(ctx, id)
+	}
+
+	cached, err := r.cache.FindByID(ctx, id)
+	if err == nil {
+		r.metrics.record(1, 0)
+		r.logger.Debug("customer cache hit", zap.String("customer_id", id))
+		r.recordAccess(ctx, id)
+		return cached, nil
+	}
+
+	r.metrics.record(0, 1)
+	r.logger.Debug("customer cache miss, querying MySQL", zap.String("customer_id", id))
+
+	customer, err := r.next.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.txTouched == nil {
+		go r.cache.Save(context.Background(), customer)
+	}
+	r.recordAccess(ctx, id)
+
+	return customer, nil
+}
+
+// recordAccess notes the read for the refresher. A failure only costs the
+// customer an early refresh, so it is not the caller's problem.
+func (r *CachingCustomerRepository) recordAccess(ctx context.Context, id string) {
+	if r.access == nil {
+		return
+	}
+	if err := r.access.Touch(ctx, id, time.Now()); err != nil {
+		r.logger.Debug("failed to record customer access", zap.Error(err), zap.String("customer_id", id))
+	}
+}
+
+// FindByIDSkipCache reads the customer from the wrapped repository and
+// puts that copy in the cache, replacing whatever it held. Retries after an
+// optimistic lock conflict use it, so a stale cached version can't make
+// them fail the same way.
+func (r *CachingCustomerRepository) FindByIDSkipCache(ctx context.Context, id string) (*domain.Customer, error) {
+	customer, err := r.next.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cache == nil {
+		return customer, nil
+	}
+	if r.txTouched != nil {
+		r.txTouched.add(id)
+	} else if err := r.cache.Save(ctx, customer); err != nil {
+		r.logger.Warn("failed to refresh cache after uncached read",
+			zap.Error(err),
+			zap.String("customer_id", id))
+	}
+
+	return customer, nil
+}
+
+// FindByIDs serves what it can from Redis in one round-trip and loads the
+// rest from the wrapped repository, backfilling the cache with what it found.
+func (r *CachingCustomerRepository) FindByIDs(ctx context.Context, ids []string) (map[string]*domain.Customer, error) {
+	if r.cache == nil {
+		return r.next.FindByIDs(ctx, ids)
+	}
+
+	customers, err := r.cache.FindByIDs(ctx, ids)
+	if err != nil {
+		r.logger.Warn("customer batch cache lookup failed, querying MySQL", zap.Error(err))
+		customers = make(map[string]*domain.Customer, len(ids))
+	}
+
+	misses := make([]string, 0, len(ids)-len(customers))
+	for _, id := range ids {
+		if _, ok := customers[id]; !ok {
+			misses = append(misses, id)
+		}
+	}
+
+	r.metrics.record(len(ids)-len(misses), len(misses))
+	r.logger.Debug("customer batch lookup",
+		zap.Int("requested", len(ids)),
+		zap.Int("cache_hits", len(customers)),
+	)
+
+	if len(misses) == 0 {
+		return customers, nil
+	}
+
+	found, err := r.next.FindByIDs(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]*domain.Customer, 0, len(found))
+	for id, customer := range found {
+		customers[id] = customer
+		loaded = append(loaded, customer)
+	}
+
+	if r.txTouched == nil && len(loaded) > 0 {
+		go r.cache.SaveMany(context.Background(), loaded)
+	}
+
+	return customers, nil
+}
+
+func (r *CachingCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	if r.cache == nil {
+		return r.next.Save(ctx, customer)
+	}
+
+	if _, err := r.cache.Delete(ctx, customer.ID); err != nil {
+		r.logger.Warn("failed to invalidate cache before save",
+			zap.Error(err),
+			zap.String("customer_id", customer.ID))
+	}
+
+	if err := r.next.Save(ctx, customer); err != nil {
+		return err
+	}
+
+	if r.txTouched != nil {
+		r.txTouched.add(customer.ID)
+	} else if err := r.cache.Save(ctx, customer); err != nil {
+		r.logger.Warn("failed to update cache after save",
+			zap.Error(err),
+			zap.String("customer_id", customer.ID))
+	}
+
+	return nil
+}
+
+func (r *CachingCustomerRepository) UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error {
+	if r.cache == nil {
+		return r.next.UpdateBalance(ctx, customerID, amount, version)
+	}
+
+	if _, err := r.cache.Delete(ctx, customerID); err != nil {
+		r.logger.Warn("failed to invalidate cache before balance update", zap.Error(err))
+	}
+
+	if err := r.next.UpdateBalance(ctx, customerID, amount, version); err != nil {
+		return err
+	}
+
+	if r.txTouched != nil {
+		r.txTouched.add(customerID)
+	}
+
+	return nil
+}
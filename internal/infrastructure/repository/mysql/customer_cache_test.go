@@ -0,0 +1,169 @@
+package sqlrepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestCachingCustomerRepository_CountsHitsAndMisses(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 2)
+	ctx := context.Background()
+	repo := env.cachedCustomerRepository()
+
+	_, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), repo.metrics.hits.Value())
+	assert.Equal(t, int64(1), repo.metrics.misses.Value())
+
+	// The miss is backfilled in the background; the next read is a hit
+	require.Eventually(t, func() bool { return env.mr.Exists("customer:GIG00001") }, time.Second, 10*time.Millisecond)
+	_, err = repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), repo.metrics.hits.Value())
+	assert.Equal(t, 0.5, repo.metrics.hitRatio.Value())
+
+	// Unknown IDs still count as misses: they were looked up in MySQL
+	customers, err := repo.FindByIDs(ctx, []string{"GIG00001", "GIG00002", "GIG99999"})
+	require.NoError(t, err)
+	assert.Len(t, customers, 2)
+	assert.Equal(t, int64(2), repo.metrics.hits.Value())
+	assert.Equal(t, int64(3), repo.metrics.misses.Value())
+	assert.Equal(t, 0.4, repo.metrics.hitRatio.Value())
+}
+
+// probeCustomerStore records whether the customer was still cached at the
+// moment each write reached the wrapped repository
+type probeCustomerStore struct {
+	domain.CustomerRepository
+	mr            *miniredis.Miniredis
+	cachedAtWrite []bool
+}
+
+func (s *probeCustomerStore) Save(ctx context.Context, customer *domain.Customer) error {
+	s.cachedAtWrite = append(s.cachedAtWrite, s.mr.Exists("customer:"+customer.ID))
+	return s.CustomerRepository.Save(ctx, customer)
+}
+
+func (s *probeCustomerStore) UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error {
+	s.cachedAtWrite = append(s.cachedAtWrite, s.mr.Exists("customer:"+customerID))
+	return s.CustomerRepository.UpdateBalance(ctx, customerID, amount, version)
+}
+
+func TestCachingCustomerRepository_InvalidatesBeforeWrite(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	ctx := context.Background()
+
+	repo := env.cachedCustomerRepository()
+	probe := &probeCustomerStore{CustomerRepository: repo.next, mr: env.mr}
+	repo.next = probe
+
+	customer, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return env.mr.Exists("customer:GIG00001") }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, customer.ApplyPayment(1000, time.Now()))
+	require.NoError(t, repo.Save(ctx, customer))
+	assert.Equal(t, []bool{false}, probe.cachedAtWrite, "evicted before MySQL was written")
+
+	// A successful save refreshes the cache with the new version
+	cached, err := repo.cache.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, customer.Version, cached.Version)
+
+	// A conflicting write leaves nothing cached, so the next read goes to MySQL
+	stale := *customer
+	stale.Version--
+	assert.ErrorIs(t, repo.Save(ctx, &stale), domain.ErrOptimisticLock)
+	assert.Equal(t, []bool{false, false}, probe.cachedAtWrite)
+	assert.False(t, env.mr.Exists("customer:GIG00001"))
+
+	require.NoError(t, repo.cache.Save(ctx, customer))
+	require.NoError(t, repo.UpdateBalance(ctx, "GIG00001", 500, customer.Version))
+	assert.Equal(t, []bool{false, false, false}, probe.cachedAtWrite)
+	assert.False(t, env.mr.Exists("customer:GIG00001"), "balance updates are not written back")
+}
+
+// staleBackfillStore puts stale back in the cache whenever a save loses
+// the optimistic lock, as a backfill of an older read landing just after
+// the eviction would
+type staleBackfillStore struct {
+	domain.CustomerRepository
+	cache *redisrepository.RedisCustomerRepository
+	stale domain.Customer
+}
+
+func (s *staleBackfillStore) Save(ctx context.Context, customer *domain.Customer) error {
+	err := s.CustomerRepository.Save(ctx, customer)
+	if errors.Is(err, domain.ErrOptimisticLock) {
+		stale := s.stale
+		s.cache.Save(ctx, &stale)
+	}
+	return err
+}
+
+func TestCachingCustomerRepository_RetryBypassesStaleCache(t *testing.T) {
+	ctx := context.Background()
+	req := service.ProcessPaymentRequest{
+		CustomerID:           "GIG00001",
+		PaymentStatus:        "COMPLETE",
+		TransactionAmount:    1000000,
+		TransactionDate:      time.Date(2025, 11, 24, 14, 54, 16, 0, time.UTC),
+		TransactionReference: "TXN001",
+	}
+
+	for _, bypass := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bypass=%v", bypass), func(t *testing.T) {
+			env := newTestEnv(t)
+			seedCustomers(t, env, 1)
+			repo := env.cachedCustomerRepository()
+
+			// The customer was updated outside this service: MySQL is on
+			// version 2 while the cache still holds version 1
+			stale, err := repo.FindByID(ctx, "GIG00001")
+			require.NoError(t, err)
+			require.Eventually(t, func() bool { return env.mr.Exists("customer:GIG00001") }, time.Second, 10*time.Millisecond)
+			require.NoError(t, env.db.Model(&persistence.CustomerModel{}).Where("id = ?", "GIG00001").
+				Update("version", gorm.Expr("version + 1")).Error)
+			require.NoError(t, repo.cache.Save(ctx, stale))
+			repo.next = &staleBackfillStore{CustomerRepository: repo.next, cache: repo.cache, stale: *stale}
+
+			var opts []service.PaymentServiceOption
+			if bypass {
+				opts = append(opts, service.WithUncachedCustomerFinder(repo))
+			}
+			payments := service.NewPaymentService(repo, env.paymentRepository(), nil, zap.NewNop(), opts...)
+
+			result, err := payments.ProcessPayment(ctx, req)
+			if !bypass {
+				assert.ErrorIs(t, err, domain.ErrOptimisticLock, "the retry read the stale cache again")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, service.OutcomeProcessed, result.Outcome)
+
+			stored, err := env.customerRepository().FindByID(ctx, "GIG00001")
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), stored.Version)
+			assert.Equal(t, int64(1000000), stored.TotalPaid)
+
+			cached, err := repo.cache.FindByID(ctx, "GIG00001")
+			require.NoError(t, err)
+			assert.Equal(t, stored.Version, cached.Version, "the cache holds the saved version")
+		})
+	}
+}
@@ -7,38 +7,35 @@ import (
 	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
 	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
-	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
-	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// GORMCustomerRepository reads and writes customers in MySQL. Caching is
+// layered on top by CachingCustomerRepository.
 type GORMCustomerRepository struct {
-	db        *gorm.DB
-	redisRepo *redisrepository.RedisCustomerRepository
-	logger    *zap.Logger
+	db     *gorm.DB
+	logger *zap.Logger
+	// rejectInvalid fails saves of customers that break their invariants
+	// instead of only reporting them
+	rejectInvalid bool
 }
 
-func NewCustomerRepository(db *gorm.DB, redisClient *redis.Client, logger *zap.Logger) *GORMCustomerRepository {
+var customerInvariantViolations = metrics.NewCounter(
+	"customer_invariant_violations_total",
+	"Customer saves that broke a domain invariant.",
+)
+
+func NewCustomerRepository(db *gorm.DB, logger *zap.Logger) *GORMCustomerRepository {
 	return &GORMCustomerRepository{
-		db:        db,
-		redisRepo: redisrepository.NewRedisCustomerRepository(redisClient, 5*time.Minute),
-		logger:    logger,
+		db:     db,
+		logger: logger,
 	}
 }
 
 func (r *GORMCustomerRepository) FindByID(ctx context.Context, id string) (*domain.Customer, error) {
-	// Try Redis cache first for high throughput
-	cached, err := r.redisRepo.FindByID(ctx, id)
-	if err == nil {
-		r.logger.Debug("customer cache hit", zap.String("customer_id", id))
-		return cached, nil
-	}
-
-	// Cache miss - query MySQL
-	r.logger.Debug("customer cache miss, querying MySQL", zap.String("customer_id", id))
-
 	var model persistence.CustomerModel
 	result := r.db.WithContext(ctx).First(&model, "id = ?", id)
 
@@ -46,41 +43,69 @@ func (r *GORMCustomerRepository) FindByID(ctx context.Context, id string) (*doma
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrCustomerNotFound
 		}
-		r.logger.Error("failed to query customer", zap.Error(result.Error))
-		return nil, fmt.Errorf("database error: %w", result.Error)
+		return nil, dbError(ctx, r.logger, "failed to query customer", result.Error)
 	}
 
-	customer := model.ToDomain()
+	return model.ToDomain(), nil
+}
 
-	go r.redisRepo.Save(context.Background(), customer)
+// FindByIDs loads the customers with a single IN query
+func (r *GORMCustomerRepository) FindByIDs(ctx context.Context, ids []string) (map[string]*domain.Customer, error) {
+	customers := make(map[string]*domain.Customer, len(ids))
+	if len(ids) == 0 {
+		return customers, nil
+	}
 
-	return customer, nil
+	var models []persistence.CustomerModel
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&models).Error; err != nil {
+		return nil, dbError(ctx, r.logger, "failed to query customers", err)
+	}
+
+	for _, model := range models {
+		customer := model.ToDomain()
+		customers[customer.ID] = customer
+	}
+
+	return customers, nil
 }
 
 func (r *GORMCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
-	model := persistence.CustomerModelFromDomain(customer)
-
-	if err := r.redisRepo.Delete(ctx, customer.ID); err != nil {
-		r.logger.Warn("failed to invalidate cache before save",
+	// Checked here, next to the write, so a bad code path is caught before
+	// its state is persisted rather than found later in the data
+	if err := customer.Validate(); err != nil {
+		customerInvariantViolations.Inc()
+		r.logger.Error("customer invariant violated",
 			zap.Error(err),
-			zap.String("customer_id", customer.ID))
+			zap.String("customer_id", customer.ID),
+			zap.Int64("asset_value", customer.AssetValue),
+			zap.Int64("total_paid", customer.TotalPaid),
+			zap.Int64("outstanding_balance", customer.OutstandingBalance),
+			zap.String("status", string(customer.Status)),
+			zap.Bool("rejected", r.rejectInvalid),
+		)
+		if r.rejectInvalid {
+			return err
+		}
 	}
 
+	model := persistence.CustomerModelFromDomain(customer)
+
 	result := r.db.WithContext(ctx).
 		Model(&persistence.CustomerModel{}).
 		Where("id = ? AND version = ?", customer.ID, customer.Version).
 		Updates(map[string]interface{}{
-			"outstanding_balance": model.OutstandingBalance,
-			"total_paid":          model.TotalPaid,
-			"last_payment_date":   model.LastPaymentDate,
-			"status":              model.Status,
-			"version":             gorm.Expr("version + 1"),
-			"updated_at":          time.Now(),
+			"asset_value":          model.AssetValue,
+			"repayment_term_weeks": model.RepaymentTermWeeks,
+			"outstanding_balance":  model.OutstandingBalance,
+			"total_paid":           model.TotalPaid,
+			"last_payment_date":    model.LastPaymentDate,
+			"status":               model.Status,
+			"version":              gorm.Expr("version + 1"),
+			"updated_at":           time.Now(),
 		})
 
 	if result.Error != nil {
-		r.logger.Error("failed to update customer", zap.Error(result.Error))
-		return fmt.Errorf("database error: %w", result.Error)
+		return dbError(ctx, r.logger, "failed to update customer", result.Error)
 	}
 
 	if result.RowsAffected == 0 {
@@ -89,12 +114,6 @@ func (r *GORMCustomerRepository) Save(ctx context.Context, customer *domain.Cust
 
 	customer.Version++
 
-	if err := r.redisRepo.Save(ctx, customer); err != nil {
-		r.logger.Warn("failed to update cache after save",
-			zap.Error(err),
-			zap.String("customer_id", customer.ID))
-	}
-
 	r.logger.Debug("customer saved to MySQL",
 		zap.String("customer_id", customer.ID),
 		zap.Int64("version", customer.Version),
@@ -108,6 +127,9 @@ func (r *GORMCustomerRepository) Create(ctx context.Context, customer *domain.Cu
 
 	result := r.db.WithContext(ctx).Create(model)
 	if result.Error != nil {
+		if isDuplicateError(result.Error) {
+			return ErrCustomerAlreadyExists
+		}
 		r.logger.Error("failed to create customer", zap.Error(result.Error))
 		return fmt.Errorf("failed to create customer: %w", result.Error)
 	}
@@ -120,10 +142,6 @@ func (r *GORMCustomerRepository) Create(ctx context.Context, customer *domain.Cu
 }
 
 func (r *GORMCustomerRepository) UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error {
-	if err := r.redisRepo.Delete(ctx, customerID); err != nil {
-		r.logger.Warn("failed to invalidate cache before balance update", zap.Error(err))
-	}
-
 	result := r.db.WithContext(ctx).
 		Model(&persistence.CustomerModel{}).
 		Where("id = ? AND version = ?", customerID, version).
@@ -145,13 +163,17 @@ func (r *GORMCustomerRepository) UpdateBalance(ctx context.Context, customerID s
 	return nil
 }
 
-func (r *GORMCustomerRepository) FindByStatus(ctx context.Context, status string, limit int) ([]*domain.Customer, error) {
+// FindByStatus returns customers in the given status, most recently
+// updated first. Ties are broken by id so offsets page stably.
+func (r *GORMCustomerRepository) FindByStatus(ctx context.Context, status string, limit, offset int) ([]*domain.Customer, error) {
 	var models []persistence.CustomerModel
 
 	result := r.db.WithContext(ctx).
 		Where("status = ?", status).
+		Order("updated_at DESC").
+		Order("id ASC").
 		Limit(limit).
-		Order("created_at DESC").
+		Offset(offset).
 		Find(&models)
 
 	if result.Error != nil {
@@ -165,3 +187,53 @@ func (r *GORMCustomerRepository) FindByStatus(ctx context.Context, status string
 
 	return customers, nil
 }
+
+// findByStatusBefore reads up to limit customers in status, most recently
+// updated first, that sort after the (updated_at, id) key given; a zero key
+// starts from the top. Paging by key keeps a customer updated mid-walk from
+// shifting the pages after it, as it would with an offset.
+func (r *GORMCustomerRepository) findByStatusBefore(ctx context.Context, status string, updatedAt time.Time, id string, limit int) ([]persistence.CustomerModel, error) {
+	var models []persistence.CustomerModel
+
+	query := r.db.WithContext(ctx).Where("status = ?", status)
+	if !updatedAt.IsZero() || id != "" {
+		query = query.Where("(updated_at < ? OR (updated_at = ? AND id < ?))", updatedAt, updatedAt, id)
+	}
+
+	result := query.
+		Order("updated_at DESC").
+		Order("id DESC").
+		Limit(limit).
+		Find(&models)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query customers: %w", result.Error)
+	}
+
+	return models, nil
+}
+
+// FindOtherActiveLoan returns the borrower's oldest other loan that still
+// takes payments. It reads MySQL directly so the version is current.
+func (r *GORMCustomerRepository) FindOtherActiveLoan(ctx context.Context, customer *domain.Customer) (*domain.Customer, error) {
+	if customer.BorrowerID == "" {
+		return nil, ErrCustomerNotFound
+	}
+
+	var model persistence.CustomerModel
+	result := r.db.WithContext(ctx).
+		Where("borrower_id = ? AND id <> ?", customer.BorrowerID, customer.ID).
+		Where("status IN ?", []string{string(domain.CustomerStatusActive), string(domain.CustomerStatusDefaulted)}).
+		Order("deployment_date ASC").
+		Order("id ASC").
+		First(&model)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, dbError(ctx, r.logger, "failed to query other loans", result.Error)
+	}
+
+	return model.ToDomain(), nil
+}
@@ -0,0 +1,167 @@
+package sqlrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomerFindByIDs_MixesCacheDatabaseAndMissing(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 3)
+	ctx := context.Background()
+
+	// GIG00001 is cached with a balance that differs from MySQL so we can
+	// tell which source served it
+	cache := redisrepository.NewRedisCustomerRepository(env.redis, time.Minute, "")
+	require.NoError(t, cache.Save(ctx, &domain.Customer{ID: "GIG00001", OutstandingBalance: 42, Status: domain.CustomerStatusActive}))
+
+	repo := env.cachedCustomerRepository()
+	customers, err := repo.FindByIDs(ctx, []string{"GIG00001", "GIG00002", "GIG00003", "GIG99999"})
+	require.NoError(t, err)
+
+	require.Len(t, customers, 3)
+	assert.Equal(t, int64(42), customers["GIG00001"].OutstandingBalance)
+	assert.Equal(t, int64(100000000), customers["GIG00002"].OutstandingBalance)
+	assert.Equal(t, int64(100000000), customers["GIG00003"].OutstandingBalance)
+	assert.NotContains(t, customers, "GIG99999")
+
+	// Misses loaded from MySQL are backfilled into the cache
+	assert.Eventually(t, func() bool {
+		return env.mr.Exists("customer:GIG00002") && env.mr.Exists("customer:GIG00003")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, env.mr.Exists("customer:GIG99999"))
+}
+
+func TestCustomerFindByIDs_FallsBackToDatabaseWhenRedisDown(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 2)
+	env.mr.Close()
+
+	customers, err := env.cachedCustomerRepository().FindByIDs(context.Background(), []string{"GIG00001", "GIG00002"})
+	require.NoError(t, err)
+	assert.Len(t, customers, 2)
+}
+
+func TestFindOtherActiveLoan_PicksOldestOpenLoanOfSameBorrower(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	repo := env.customerRepository()
+
+	deployed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	loan := func(id, borrower string, status domain.CustomerStatus, weeksLater int) *domain.Customer {
+		return &domain.Customer{
+			ID: id, BorrowerID: borrower, AssetValue: 1000000, RepaymentTermWeeks: 10, OutstandingBalance: 500000,
+			DeploymentDate: deployed.AddDate(0, 0, 7*weeksLater), Status: status, Version: 1,
+		}
+	}
+	for _, c := range []*domain.Customer{
+		loan("GIG00001", "BRW1", domain.CustomerStatusCompleted, 0),
+		loan("GIG00002", "BRW1", domain.CustomerStatusCompleted, 1),
+		loan("GIG00003", "BRW1", domain.CustomerStatusWrittenOff, 2),
+		loan("GIG00004", "BRW1", domain.CustomerStatusActive, 4),
+		loan("GIG00005", "BRW1", domain.CustomerStatusDefaulted, 3),
+		loan("GIG00006", "BRW2", domain.CustomerStatusActive, 0),
+		loan("GIG00007", "", domain.CustomerStatusActive, 0),
+	} {
+		require.NoError(t, repo.Create(ctx, c))
+	}
+
+	other, err := repo.FindOtherActiveLoan(ctx, &domain.Customer{ID: "GIG00001", BorrowerID: "BRW1"})
+	require.NoError(t, err)
+	assert.Equal(t, "GIG00005", other.ID)
+	assert.Equal(t, "BRW1", other.BorrowerID)
+
+	// The paying loan itself never counts
+	_, err = repo.FindOtherActiveLoan(ctx, &domain.Customer{ID: "GIG00006", BorrowerID: "BRW2"})
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+
+	_, err = repo.FindOtherActiveLoan(ctx, &domain.Customer{ID: "GIG00007"})
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+}
+
+func TestCustomerCreate_DuplicateIsAConflict(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	repo := env.customerRepository()
+
+	customer := &domain.Customer{
+		ID: "GIG00001", AssetValue: 1000000, RepaymentTermWeeks: 10, OutstandingBalance: 1000000,
+		DeploymentDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Status: domain.CustomerStatusActive, Version: 1,
+	}
+	require.NoError(t, repo.Create(ctx, customer))
+
+	again := *customer
+	again.OutstandingBalance = 1
+	err := repo.Create(ctx, &again)
+	assert.ErrorIs(t, err, ErrCustomerAlreadyExists)
+
+	stored, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000000), stored.OutstandingBalance, "the original row is untouched")
+}
+
+func TestCustomerRepository_ContextEndedIsNotADatabaseError(t *testing.T) {
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	repo := env.customerRepository()
+
+	customer, err := repo.FindByID(context.Background(), "GIG00001")
+	require.NoError(t, err)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	_, err = repo.FindByID(canceled, "GIG00001")
+	assert.ErrorIs(t, err, domain.ErrRequestCanceled)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = repo.FindByID(expired, "GIG00001")
+	assert.ErrorIs(t, err, domain.ErrRequestTimeout)
+
+	err = repo.Save(canceled, customer)
+	assert.ErrorIs(t, err, domain.ErrRequestCanceled)
+
+	err = repo.Save(expired, customer)
+	assert.ErrorIs(t, err, domain.ErrRequestTimeout)
+
+	// Nothing was written, so a later save with a live context still applies
+	require.NoError(t, repo.Save(context.Background(), customer))
+}
+
+func TestCustomerSave_InvariantViolations(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	repo := env.customerRepository()
+
+	customer, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	// Balance taken without recording the payment
+	customer.OutstandingBalance -= 5000
+	before := customerInvariantViolations.Value()
+
+	require.NoError(t, repo.Save(ctx, customer), "violations are only reported by default")
+	assert.Equal(t, before+1, customerInvariantViolations.Value())
+
+	repo.rejectInvalid = true
+	customer.OutstandingBalance -= 5000
+	err = repo.Save(ctx, customer)
+	require.ErrorIs(t, err, domain.ErrInvariantViolated)
+	assert.Equal(t, before+2, customerInvariantViolations.Value())
+
+	stored, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(99995000), stored.OutstandingBalance, "the rejected save is not written")
+
+	stored.TotalPaid = 5000
+	require.NoError(t, repo.Save(ctx, stored), "a consistent customer saves normally")
+	assert.Equal(t, before+2, customerInvariantViolations.Value())
+}
@@ -0,0 +1,37 @@
+package sqlrepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// dbError wraps a failed query. A query cut short by ctx ending is the
+// caller going away, not a database fault, so it is logged at debug and
+// returned as domain.ErrRequestCanceled or domain.ErrRequestTimeout.
+func dbError(ctx context.Context, logger *zap.Logger, msg string, err error, fields ...zap.Field) error {
+	fields = append([]zap.Field{zap.Error(err)}, fields...)
+	if ctxErr := domain.ContextError(ctx, err); ctxErr != nil {
+		logger.Debug(msg, fields...)
+		return ctxErr
+	}
+	logger.Error(msg, fields...)
+	return fmt.Errorf("database error: %w", err)
+}
+
+// isDuplicateError reports a unique key violation, from MySQL or from the
+// SQLite used in tests
+func isDuplicateError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, gorm.ErrDuplicatedKey) ||
+		strings.Contains(err.Error(), "Duplicate entry") ||
+		strings.Contains(err.Error(), "UNIQUE constraint")
+}
@@ -0,0 +1,89 @@
+package sqlrepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GORMEventLog keeps the event log in MySQL. Bound to a transaction (see
+// Repositories.WithTx) an append commits or rolls back with the state
+// change that raised the event.
+type GORMEventLog struct {
+	db     *gorm.DB
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+func NewEventLog(db *gorm.DB, logger *zap.Logger) *GORMEventLog {
+	return &GORMEventLog{
+		db:     db,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+func (l *GORMEventLog) Append(ctx context.Context, event domain.DomainEvent) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	model := &persistence.EventLogModel{
+		EventID:     event.GetEventID(),
+		EventType:   event.GetEventType(),
+		AggregateID: event.GetAggregateID(),
+		Payload:     string(payload),
+		OccurredAt:  event.GetOccurredAt(),
+		RecordedAt:  l.now(),
+	}
+
+	if err := l.db.WithContext(ctx).Create(model).Error; err != nil {
+		if !isDuplicateError(err) {
+			return 0, dbError(ctx, l.logger, "failed to append to event log", err)
+		}
+		// Already logged, by a retry or a redelivery: keep the first sequence
+		var existing persistence.EventLogModel
+		if err := l.db.WithContext(ctx).Select("sequence").Where("event_id = ?", model.EventID).Take(&existing).Error; err != nil {
+			return 0, dbError(ctx, l.logger, "failed to read event log", err)
+		}
+		return existing.Sequence, nil
+	}
+
+	return model.Sequence, nil
+}
+
+// After only sees committed rows, and sequences are taken at insert, so a
+// transaction can commit a lower sequence after a reader has moved past it.
+// Readers pass an until some way behind now to let those land first.
+func (l *GORMEventLog) After(ctx context.Context, afterSeq int64, until time.Time, limit int) ([]*domain.LoggedEvent, error) {
+	var models []persistence.EventLogModel
+	err := l.db.WithContext(ctx).
+		Where("sequence > ? AND recorded_at < ?", afterSeq, until).
+		Order("sequence ASC").
+		Limit(limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, dbError(ctx, l.logger, "failed to read event log", err)
+	}
+
+	events := make([]*domain.LoggedEvent, len(models))
+	for i, m := range models {
+		events[i] = &domain.LoggedEvent{
+			Sequence:    m.Sequence,
+			EventID:     m.EventID,
+			EventType:   m.EventType,
+			AggregateID: m.AggregateID,
+			Payload:     []byte(m.Payload),
+			OccurredAt:  m.OccurredAt,
+			RecordedAt:  m.RecordedAt,
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,164 @@
+package sqlrepository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func flaggedEvent(ref string) *domain.PaymentFlaggedEvent {
+	return domain.NewPaymentFlaggedEvent("GIG00001", domain.PaymentFlaggedPayload{
+		CustomerID: "GIG00001", TransactionReference: ref, Amount: 5000,
+	}, time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC))
+}
+
+func TestEventLog_AppendsInOrder(t *testing.T) {
+	ctx := context.Background()
+	log := NewEventLog(newTestEnv(t).db, zap.NewNop())
+
+	var events []*domain.PaymentFlaggedEvent
+	var last int64
+	for i := 0; i < 5; i++ {
+		event := flaggedEvent(fmt.Sprintf("TXN%03d", i))
+		seq, err := log.Append(ctx, event)
+		require.NoError(t, err)
+		assert.Greater(t, seq, last, "sequences only grow")
+		last = seq
+		events = append(events, event)
+	}
+
+	logged, err := log.After(ctx, 0, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, logged, 5)
+	for i, entry := range logged {
+		assert.Equal(t, events[i].EventID, entry.EventID)
+		assert.Equal(t, domain.EventTypePaymentFlagged, entry.EventType)
+		assert.Equal(t, "GIG00001", entry.AggregateID)
+		assert.True(t, events[i].OccurredAt.Equal(entry.OccurredAt))
+
+		var decoded domain.PaymentFlaggedEvent
+		require.NoError(t, json.Unmarshal(entry.Payload, &decoded))
+		assert.Equal(t, events[i].Payload.TransactionReference, decoded.Payload.TransactionReference)
+	}
+}
+
+func TestEventLog_AppendIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	log := NewEventLog(env.db, zap.NewNop())
+
+	event := flaggedEvent("TXN001")
+	first, err := log.Append(ctx, event)
+	require.NoError(t, err)
+	_, err = log.Append(ctx, flaggedEvent("TXN002"))
+	require.NoError(t, err)
+
+	again, err := log.Append(ctx, event)
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "a redelivered event keeps its sequence")
+
+	var rows int64
+	require.NoError(t, env.db.Model(&persistence.EventLogModel{}).Count(&rows).Error)
+	assert.Equal(t, int64(2), rows)
+}
+
+func TestEventLog_AfterSeq(t *testing.T) {
+	ctx := context.Background()
+	log := NewEventLog(newTestEnv(t).db, zap.NewNop())
+
+	var seqs []int64
+	for i := 0; i < 7; i++ {
+		seq, err := log.Append(ctx, flaggedEvent(fmt.Sprintf("TXN%03d", i)))
+		require.NoError(t, err)
+		seqs = append(seqs, seq)
+	}
+
+	// Paging by the last sequence seen reads every event exactly once
+	var read []int64
+	after := int64(0)
+	for {
+		page, err := log.After(ctx, after, time.Now().Add(time.Minute), 3)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		assert.LessOrEqual(t, len(page), 3)
+		for _, entry := range page {
+			read = append(read, entry.Sequence)
+		}
+		after = page[len(page)-1].Sequence
+	}
+	assert.Equal(t, seqs, read)
+
+	page, err := log.After(ctx, seqs[4], time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, seqs[5], page[0].Sequence)
+}
+
+func TestEventLog_AfterHoldsBackUnsettledEvents(t *testing.T) {
+	ctx := context.Background()
+	log := NewEventLog(newTestEnv(t).db, zap.NewNop())
+	start := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+
+	var seqs []int64
+	for i := 0; i < 3; i++ {
+		log.now = func() time.Time { return start.Add(time.Duration(i) * time.Second) }
+		seq, err := log.Append(ctx, flaggedEvent(fmt.Sprintf("TXN%03d", i)))
+		require.NoError(t, err)
+		seqs = append(seqs, seq)
+	}
+
+	page, err := log.After(ctx, 0, start.Add(2*time.Second), 10)
+	require.NoError(t, err)
+	require.Len(t, page, 2, "the event recorded at until is held back")
+	assert.Equal(t, seqs[1], page[1].Sequence)
+
+	page, err = log.After(ctx, seqs[1], start.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, seqs[2], page[0].Sequence)
+}
+
+func TestEventLog_RollsBackWithTransaction(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	repos := env.repositories()
+
+	errBoom := errors.New("boom")
+	err := repos.WithTx(ctx, func(txRepos *Repositories) error {
+		if err := payInTx(ctx, txRepos, "GIG00001", "TXN001", 2500000); err != nil {
+			return err
+		}
+		if _, err := txRepos.EventLog.Append(ctx, flaggedEvent("TXN001")); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	require.ErrorIs(t, err, errBoom)
+
+	logged, err := repos.EventLog.After(ctx, 0, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Empty(t, logged, "an event must not outlive the state change that raised it")
+
+	require.NoError(t, repos.WithTx(ctx, func(txRepos *Repositories) error {
+		if err := payInTx(ctx, txRepos, "GIG00001", "TXN001", 2500000); err != nil {
+			return err
+		}
+		_, err := txRepos.EventLog.Append(ctx, flaggedEvent("TXN001"))
+		return err
+	}))
+	logged, err = repos.EventLog.After(ctx, 0, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, logged, 1)
+}
@@ -0,0 +1,123 @@
+package sqlrepository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// PaymentCursor is a position in the payments table in the order rows were
+// recorded
+type PaymentCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// String formats the cursor as ParsePaymentCursor reads it
+func (c PaymentCursor) String() string {
+	if c.CreatedAt.IsZero() && c.ID == "" {
+		return ""
+	}
+	return c.CreatedAt.UTC().Format(time.RFC3339Nano) + "/" + c.ID
+}
+
+// ParsePaymentCursor reads a cursor written by PaymentCursor.String; empty
+// is the start of the table
+func ParsePaymentCursor(s string) (PaymentCursor, error) {
+	if s == "" {
+		return PaymentCursor{}, nil
+	}
+	at, id, ok := strings.Cut(s, "/")
+	if !ok {
+		return PaymentCursor{}, fmt.Errorf("invalid payment cursor %q: want <created_at>/<id>", s)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return PaymentCursor{}, fmt.Errorf("invalid payment cursor %q: %w", s, err)
+	}
+	return PaymentCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// PaymentReindexOptions controls a rebuild of the payment dedup keys
+type PaymentReindexOptions struct {
+	BatchSize int
+	// DedupTTL must match the repositories' so restored keys expire when the
+	// originals would have
+	DedupTTL time.Duration
+	// KeyPrefix and DedupScope must match the repositories' so restored
+	// keys are the ones read
+	KeyPrefix  keyspace.Prefix
+	DedupScope domain.DedupScope
+	// Lists also rebuilds each customer's payment list
+	Lists bool
+	// After resumes a run from the cursor it last reported
+	After PaymentCursor
+	// OnBatch, if set, is called after each batch
+	OnBatch func(progress PaymentReindexProgress)
+}
+
+// PaymentReindexProgress is how far a rebuild has got; Cursor is the last
+// payment scanned
+type PaymentReindexProgress struct {
+	Scanned  int64
+	Restored int64
+	Cursor   PaymentCursor
+}
+
+// ReindexPaymentDedup recreates the Redis dedup keys of recorded payments,
+// for after Redis has been flushed or replaced. Payments are read in
+// batches in the order they were recorded, up to when the run started;
+// those too old to still have a key are not read at all. Existing keys are
+// left alone, so it is safe to re-run and to resume from any reported
+// cursor.
+func ReindexPaymentDedup(ctx context.Context, db *gorm.DB, redisClient redis.UniversalClient, opts PaymentReindexOptions, logger *zap.Logger) (PaymentReindexProgress, error) {
+	progress := PaymentReindexProgress{Cursor: opts.After}
+	if opts.BatchSize <= 0 {
+		return progress, fmt.Errorf("batch size must be positive, got %d", opts.BatchSize)
+	}
+
+	source := NewPaymentRepository(db, redisClient, opts.DedupTTL, opts.KeyPrefix, opts.DedupScope, logger)
+	dedup := redisrepository.NewRedisPaymentRepository(redisClient, opts.DedupTTL, opts.KeyPrefix, opts.DedupScope)
+
+	now := time.Now()
+	cursor := opts.After
+	if oldest := now.Add(-opts.DedupTTL); opts.DedupTTL > 0 && cursor.CreatedAt.Before(oldest) {
+		cursor = PaymentCursor{CreatedAt: oldest}
+	}
+
+	for {
+		payments, err := source.FindRecordedAfter(ctx, cursor.CreatedAt, cursor.ID, now, opts.BatchSize)
+		if err != nil {
+			return progress, err
+		}
+		if len(payments) == 0 {
+			return progress, nil
+		}
+
+		restored, err := dedup.Restore(ctx, payments, now, opts.Lists)
+		progress.Restored += int64(restored)
+		if err != nil {
+			return progress, err
+		}
+
+		last := payments[len(payments)-1]
+		cursor = PaymentCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		progress.Scanned += int64(len(payments))
+		progress.Cursor = cursor
+		if opts.OnBatch != nil {
+			opts.OnBatch(progress)
+		}
+
+		if len(payments) < opts.BatchSize {
+			return progress, nil
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package sqlrepository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReindexPaymentDedup_RecreatesKeysFromRows(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		seedPayment(t, repo, "GIG00001", fmt.Sprintf("TXN%03d", i), date)
+	}
+	// Recorded longer ago than the dedup TTL, so its key would have expired
+	seedPayment(t, repo, "GIG00002", "OLD001", date)
+	require.NoError(t, env.db.Model(&persistence.PaymentModel{}).
+		Where("transaction_reference = ?", "OLD001").
+		UpdateColumn("created_at", time.Now().Add(-48*time.Hour)).Error)
+	env.mr.FlushAll()
+
+	opts := PaymentReindexOptions{BatchSize: 2, DedupTTL: 24 * time.Hour, Lists: true}
+	var batches int
+	opts.OnBatch = func(PaymentReindexProgress) { batches++ }
+	progress, err := ReindexPaymentDedup(ctx, env.db, env.redis, opts, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), progress.Scanned)
+	assert.Equal(t, int64(5), progress.Restored)
+	assert.Equal(t, 3, batches)
+	for i := 1; i <= 5; i++ {
+		key := fmt.Sprintf("payment:TXN%03d", i)
+		require.True(t, env.mr.Exists(key), key)
+		ttl := env.mr.TTL(key)
+		assert.True(t, ttl > 23*time.Hour && ttl <= 24*time.Hour, "expires a TTL after the row was recorded, got %s", ttl)
+	}
+	assert.False(t, env.mr.Exists("payment:OLD001"))
+	list, err := env.mr.List("customer:GIG00001:payments")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN001", "TXN002", "TXN003", "TXN004", "TXN005"}, list)
+
+	exists, err := repo.ExistsByTransactionReference(ctx, "GIG00001", "TXN003")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	again, err := ReindexPaymentDedup(ctx, env.db, env.redis, opts, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), again.Scanned)
+	assert.Zero(t, again.Restored, "keys already there are left alone")
+	list, err = env.mr.List("customer:GIG00001:payments")
+	require.NoError(t, err)
+	assert.Len(t, list, 5, "a re-run doesn't list payments twice")
+}
+
+func TestReindexPaymentDedup_ResumesFromCursor(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		seedPayment(t, repo, "GIG00001", fmt.Sprintf("TXN%03d", i), date)
+	}
+
+	var first PaymentCursor
+	opts := PaymentReindexOptions{BatchSize: 2}
+	opts.OnBatch = func(p PaymentReindexProgress) {
+		if first == (PaymentCursor{}) {
+			first = p.Cursor
+		}
+	}
+	_, err := ReindexPaymentDedup(ctx, env.db, env.redis, opts, zap.NewNop())
+	require.NoError(t, err)
+	env.mr.FlushAll()
+
+	after, err := ParsePaymentCursor(first.String())
+	require.NoError(t, err)
+	assert.True(t, first.CreatedAt.Equal(after.CreatedAt))
+	assert.Equal(t, first.ID, after.ID)
+
+	progress, err := ReindexPaymentDedup(ctx, env.db, env.redis, PaymentReindexOptions{BatchSize: 2, After: after}, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), progress.Restored, "the first batch is not read again")
+	assert.False(t, env.mr.Exists("customer:GIG00001:payments"), "lists are only rebuilt when asked")
+}
+
+func TestReindexPaymentDedup_RestoresKeysThePrefixedCustomerScopedRepositoriesRead(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	require.NoError(t, persistence.ApplyDedupScope(ctx, env.db, domain.DedupScopeCustomer))
+	cfg := Config{PaymentDedupTTL: time.Hour, KeyPrefix: "gm", DedupScope: domain.DedupScopeCustomer}
+	repos := NewRepositories(env.db, env.redis, cfg, zap.NewNop())
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	// Customer scope lets two customers send the same reference
+	payments := repos.Payment.(*GORMPaymentRepository)
+	seedPayment(t, payments, "GIG00001", "TXN001", date)
+	seedPayment(t, payments, "GIG00002", "TXN001", date)
+	env.mr.FlushAll()
+
+	progress, err := ReindexPaymentDedup(ctx, env.db, env.redis, PaymentReindexOptions{
+		BatchSize:  10,
+		DedupTTL:   cfg.PaymentDedupTTL,
+		KeyPrefix:  cfg.KeyPrefix,
+		DedupScope: cfg.DedupScope,
+		Lists:      true,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), progress.Restored)
+
+	assert.Equal(t, []string{
+		"gm:customer:GIG00001:payments",
+		"gm:customer:GIG00002:payments",
+		"gm:payment:GIG00001:TXN001",
+		"gm:payment:GIG00002:TXN001",
+	}, env.mr.Keys())
+	for _, customerID := range []string{"GIG00001", "GIG00002"} {
+		exists, err := repos.Payment.ExistsByTransactionReference(ctx, customerID, "TXN001")
+		require.NoError(t, err)
+		assert.True(t, exists, customerID)
+	}
+	exists, err := repos.Payment.ExistsByTransactionReference(ctx, "GIG00003", "TXN001")
+	require.NoError(t, err)
+	assert.False(t, exists, "another customer may still use the reference")
+}
+
+func TestReindexPaymentDedup_RejectsBadBatchSize(t *testing.T) {
+	env := newTestEnv(t)
+	seedPayment(t, env.paymentRepository(), "GIG00001", "TXN001", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	env.mr.FlushAll()
+
+	_, err := ReindexPaymentDedup(context.Background(), env.db, env.redis, PaymentReindexOptions{BatchSize: 0}, zap.NewNop())
+	assert.Error(t, err)
+	assert.Empty(t, env.mr.Keys(), "nothing is written")
+}
+
+func TestReindexPaymentDedup_StopsWhenContextEnds(t *testing.T) {
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		seedPayment(t, repo, "GIG00001", fmt.Sprintf("TXN%03d", i), date)
+	}
+	env.mr.FlushAll()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := PaymentReindexOptions{BatchSize: 1, OnBatch: func(PaymentReindexProgress) { cancel() }}
+	progress, err := ReindexPaymentDedup(ctx, env.db, env.redis, opts, zap.NewNop())
+	require.Error(t, err)
+	assert.Equal(t, int64(1), progress.Restored)
+
+	resumed, err := ReindexPaymentDedup(context.Background(), env.db, env.redis, PaymentReindexOptions{BatchSize: 1, After: progress.Cursor}, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resumed.Restored, "resuming from the reported cursor restores the rest")
+	assert.Len(t, env.mr.Keys(), 3)
+}
+
+func TestParsePaymentCursor(t *testing.T) {
+	cursor, err := ParsePaymentCursor("")
+	require.NoError(t, err)
+	assert.Equal(t, PaymentCursor{}, cursor)
+	assert.Empty(t, cursor.String())
+
+	for _, s := range []string{"garbage", "yesterday/p1"} {
+		_, err := ParsePaymentCursor(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -2,10 +2,13 @@ package sqlrepository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
 	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
 	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
 	"github.com/go-redis/redis/v8"
@@ -17,23 +20,34 @@ import (
 type GORMPaymentRepository struct {
 	db        *gorm.DB
 	redisRepo *redisrepository.RedisPaymentRepository
+	scope     domain.DedupScope
 	logger    *zap.Logger
 }
 
-func NewPaymentRepository(db *gorm.DB, redisClient *redis.Client, logger *zap.Logger) *GORMPaymentRepository {
-	return &GORMPaymentRepository{
-		db:        db,
-		redisRepo: redisrepository.NewRedisPaymentRepository(redisClient),
-		logger:    logger,
+// NewPaymentRepository keeps dedup keys in Redis in front of MySQL. With a
+// nil redisClient it never touches Redis, and duplicates are caught by the
+// unique indexes alone. scope must match the one the schema was migrated
+// with.
+func NewPaymentRepository(db *gorm.DB, redisClient redis.UniversalClient, dedupTTL time.Duration, keys keyspace.Prefix, scope domain.DedupScope, logger *zap.Logger) *GORMPaymentRepository {
+	r := &GORMPaymentRepository{
+		db:     db,
+		scope:  scope,
+		logger: logger,
 	}
+	if redisClient != nil {
+		r.redisRepo = redisrepository.NewRedisPaymentRepository(redisClient, dedupTTL, keys, scope)
+	}
+	return r
 }
 
 func (r *GORMPaymentRepository) Save(ctx context.Context, payment *domain.Payment) error {
-	exists, err := r.redisRepo.ExistsByTransactionReference(ctx, payment.TransactionReference)
-	if err != nil {
-		r.logger.Warn("redis dedup check failed, falling back to MySQL", zap.Error(err))
-	} else if exists {
-		return domain.ErrDuplicateTransaction
+	if r.redisRepo != nil {
+		exists, err := r.redisRepo.ExistsByTransactionReference(ctx, payment.CustomerID, payment.TransactionReference)
+		if err != nil {
+			r.logger.Warn("redis dedup check failed, falling back to MySQL", zap.Error(err))
+		} else if exists {
+			return domain.ErrDuplicateTransaction
+		}
 	}
 
 	if payment.ID == "" {
@@ -48,8 +62,7 @@ func (r *GORMPaymentRepository) Save(ctx context.Context, payment *domain.Paymen
 			return domain.ErrDuplicateTransaction
 		}
 
-		r.logger.Error("failed to save payment", zap.Error(result.Error))
-		return fmt.Errorf("database error: %w", result.Error)
+		return dbError(ctx, r.logger, "failed to save payment", result.Error)
 	}
 
 	r.logger.Debug("payment saved to MySQL",
@@ -60,50 +73,59 @@ func (r *GORMPaymentRepository) Save(ctx context.Context, payment *domain.Paymen
 	return nil
 }
 
-func (r *GORMPaymentRepository) FindByTransactionReference(ctx context.Context, txRef string) (*domain.Payment, error) {
+// referenceQuery matches txRef within the repository's dedup scope
+func (r *GORMPaymentRepository) referenceQuery(ctx context.Context, customerID, txRef string) *gorm.DB {
+	query := r.db.WithContext(ctx).Where("transaction_reference = ?", txRef)
+	if r.scope.PerCustomer() {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	return query
+}
+
+func (r *GORMPaymentRepository) FindByTransactionReference(ctx context.Context, customerID, txRef string) (*domain.Payment, error) {
 	var model persistence.PaymentModel
 
-	result := r.db.WithContext(ctx).
-		Where("transaction_reference = ?", txRef).
-		First(&model)
+	result := r.referenceQuery(ctx, customerID, txRef).First(&model)
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrPaymentNotFound
 		}
-		return nil, fmt.Errorf("database error: %w", result.Error)
+		return nil, dbError(ctx, r.logger, "failed to find payment by transaction reference", result.Error)
 	}
 
 	payment := model.ToDomain()
 
 	// Cache in Redis
-	go r.redisRepo.Save(context.Background(), payment)
+	if r.redisRepo != nil {
+		go r.redisRepo.Save(context.Background(), payment)
+	}
 
 	return payment, nil
 }
 
-func (r *GORMPaymentRepository) ExistsByTransactionReference(ctx context.Context, txRef string) (bool, error) {
-	exists, err := r.redisRepo.ExistsByTransactionReference(ctx, txRef)
-	if err == nil && exists {
-		r.logger.Debug("payment exists (Redis cache)", zap.String("tx_ref", txRef))
-		return true, nil
+func (r *GORMPaymentRepository) ExistsByTransactionReference(ctx context.Context, customerID, txRef string) (bool, error) {
+	if r.redisRepo != nil {
+		exists, err := r.redisRepo.ExistsByTransactionReference(ctx, customerID, txRef)
+		if err == nil && exists {
+			r.logger.Debug("payment exists (Redis cache)", zap.String("tx_ref", txRef))
+			return true, nil
+		}
 	}
 
 	var count int64
-	result := r.db.WithContext(ctx).
+	result := r.referenceQuery(ctx, customerID, txRef).
 		Model(&persistence.PaymentModel{}).
-		Where("transaction_reference = ?", txRef).
 		Count(&count)
 
 	if result.Error != nil {
-		r.logger.Error("failed to check payment existence", zap.Error(result.Error))
-		return false, fmt.Errorf("database error: %w", result.Error)
+		return false, dbError(ctx, r.logger, "failed to check payment existence", result.Error)
 	}
 
 	existsInDB := count > 0
 
-	if existsInDB {
-		payment, err := r.FindByTransactionReference(ctx, txRef)
+	if existsInDB && r.redisRepo != nil {
+		payment, err := r.FindByTransactionReference(ctx, customerID, txRef)
 		if err == nil {
 			go r.redisRepo.Save(context.Background(), payment)
 		}
@@ -121,11 +143,9 @@ func (r *GORMPaymentRepository) FindByCustomerID(ctx context.Context, customerID
 		Find(&models)
 
 	if result.Error != nil {
-		r.logger.Error("failed to fetch payments by customer ID",
-			zap.Error(result.Error),
+		return nil, dbError(ctx, r.logger, "failed to fetch payments by customer ID", result.Error,
 			zap.String("customer_id", customerID),
 		)
-		return nil, fmt.Errorf("database error: %w", result.Error)
 	}
 
 	payments := make([]*domain.Payment, len(models))
@@ -141,24 +161,51 @@ func (r *GORMPaymentRepository) FindByCustomerID(ctx context.Context, customerID
 	return payments, nil
 }
 
+func (r *GORMPaymentRepository) FindByCustomerIDFiltered(ctx context.Context, customerID string, filter domain.PaymentFilter) ([]*domain.Payment, error) {
+	var models []persistence.PaymentModel
+
+	direction := sortDirection(filter, domain.PaymentOrderDesc)
+	result := withStatuses(r.db.WithContext(ctx).Where("customer_id = ?", customerID), filter).
+		Order("transaction_date " + direction).
+		Order("id " + direction).
+		Find(&models)
+
+	if result.Error != nil {
+		return nil, dbError(ctx, r.logger, "failed to fetch filtered payments by customer ID", result.Error,
+			zap.String("customer_id", customerID),
+		)
+	}
+
+	payments := make([]*domain.Payment, len(models))
+	for i, model := range models {
+		payments[i] = model.ToDomain()
+	}
+
+	r.logger.Debug("fetched filtered payments by customer ID",
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(payments)),
+	)
+
+	return payments, nil
+}
+
 func (r *GORMPaymentRepository) FindByCustomerIDWithPagination(ctx context.Context, customerID string, limit, offset int) ([]*domain.Payment, error) {
 	var models []persistence.PaymentModel
 
 	result := r.db.WithContext(ctx).
 		Where("customer_id = ?", customerID).
 		Order("transaction_date DESC").
+		Order("id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&models)
 
 	if result.Error != nil {
-		r.logger.Error("failed to fetch payments by customer ID with pagination",
-			zap.Error(result.Error),
+		return nil, dbError(ctx, r.logger, "failed to fetch payments by customer ID with pagination", result.Error,
 			zap.String("customer_id", customerID),
 			zap.Int("limit", limit),
 			zap.Int("offset", offset),
 		)
-		return nil, fmt.Errorf("database error: %w", result.Error)
 	}
 
 	payments := make([]*domain.Payment, len(models))
@@ -176,6 +223,225 @@ func (r *GORMPaymentRepository) FindByCustomerIDWithPagination(ctx context.Conte
 	return payments, nil
 }
 
+func (r *GORMPaymentRepository) FindByCustomerIDAfter(ctx context.Context, customerID string, filter domain.PaymentFilter, afterDate time.Time, afterID string, limit int) ([]*domain.Payment, error) {
+	var models []persistence.PaymentModel
+
+	direction, past := sortDirection(filter, domain.PaymentOrderAsc), ">"
+	if direction == "DESC" {
+		past = "<"
+	}
+	query := withStatuses(r.db.WithContext(ctx).Where("customer_id = ?", customerID), filter)
+	if !afterDate.IsZero() || afterID != "" {
+		query = query.Where("(transaction_date "+past+" ? OR (transaction_date = ? AND id "+past+" ?))", afterDate, afterDate, afterID)
+	}
+
+	result := query.
+		Order("transaction_date " + direction).
+		Order("id " + direction).
+		Limit(limit).
+		Find(&models)
+
+	if result.Error != nil {
+		return nil, dbError(ctx, r.logger, "failed to fetch payments by customer ID after cursor", result.Error,
+			zap.String("customer_id", customerID),
+			zap.Int("limit", limit),
+		)
+	}
+
+	payments := make([]*domain.Payment, len(models))
+	for i, model := range models {
+		payments[i] = model.ToDomain()
+	}
+
+	r.logger.Debug("fetched payments by customer ID after cursor",
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(payments)),
+		zap.Int("limit", limit),
+	)
+
+	return payments, nil
+}
+
+// FindRecordedAfter reads idx_payments_created_id, so each poll of the feed
+// is a range scan from the cursor whatever the size of the table
+func (r *GORMPaymentRepository) FindRecordedAfter(ctx context.Context, afterTime time.Time, afterID string, until time.Time, limit int) ([]*domain.Payment, error) {
+	var models []persistence.PaymentModel
+
+	query := r.db.WithContext(ctx).Where("created_at < ?", until)
+	if !afterTime.IsZero() || afterID != "" {
+		query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", afterTime, afterTime, afterID)
+	}
+
+	result := query.
+		Order("created_at ASC").
+		Order("id ASC").
+		Limit(limit).
+		Find(&models)
+
+	if result.Error != nil {
+		return nil, dbError(ctx, r.logger, "failed to fetch payments recorded after cursor", result.Error,
+			zap.Time("after", afterTime),
+			zap.Int("limit", limit),
+		)
+	}
+
+	payments := make([]*domain.Payment, len(models))
+	for i, model := range models {
+		payments[i] = model.ToDomain()
+	}
+	return payments, nil
+}
+
+func (r *GORMPaymentRepository) dateRangeQuery(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).
+		Model(&persistence.PaymentModel{}).
+		Where("transaction_date BETWEEN ? AND ?", from, to)
+	if customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	return withStatuses(query, filter)
+}
+
+// withStatuses narrows query to the filter's statuses, if it names any
+func withStatuses(query *gorm.DB, filter domain.PaymentFilter) *gorm.DB {
+	if len(filter.Statuses) == 0 {
+		return query
+	}
+	return query.Where("status IN ?", filter.Statuses)
+}
+
+// sortDirection is the SQL direction of the filter's order, or of fallback
+// when it sets none
+func sortDirection(filter domain.PaymentFilter, fallback domain.PaymentOrder) string {
+	if filter.OrderOr(fallback) == domain.PaymentOrderAsc {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+func (r *GORMPaymentRepository) FindByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, error) {
+	var models []persistence.PaymentModel
+
+	direction := sortDirection(filter, domain.PaymentOrderDesc)
+	result := r.dateRangeQuery(ctx, from, to, customerID, filter).
+		Order("transaction_date " + direction).
+		Order("id " + direction).
+		Limit(limit).
+		Offset(offset).
+		Find(&models)
+
+	if result.Error != nil {
+		return nil, dbError(ctx, r.logger, "failed to fetch payments by date range", result.Error,
+			zap.Time("from", from),
+			zap.Time("to", to),
+			zap.String("customer_id", customerID),
+		)
+	}
+
+	payments := make([]*domain.Payment, len(models))
+	for i, model := range models {
+		payments[i] = model.ToDomain()
+	}
+
+	r.logger.Debug("fetched payments by date range",
+		zap.Time("from", from),
+		zap.Time("to", to),
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(payments)),
+	)
+
+	return payments, nil
+}
+
+func (r *GORMPaymentRepository) TotalsByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter) (domain.PaymentTotals, error) {
+	var totals struct {
+		Count  int64
+		Amount int64
+	}
+
+	result := r.dateRangeQuery(ctx, from, to, customerID, filter).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Scan(&totals)
+
+	if result.Error != nil {
+		return domain.PaymentTotals{}, dbError(ctx, r.logger, "failed to total payments by date range", result.Error,
+			zap.Time("from", from),
+			zap.Time("to", to),
+			zap.String("customer_id", customerID),
+		)
+	}
+
+	return domain.PaymentTotals{Count: totals.Count, Amount: totals.Amount}, nil
+}
+
+// TotalsByStatus uses a half-open range so consecutive days never share a
+// payment made exactly at midnight
+func (r *GORMPaymentRepository) TotalsByStatus(ctx context.Context, from, to time.Time) (map[domain.PaymentStatus]domain.PaymentTotals, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+		Amount int64
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&persistence.PaymentModel{}).
+		Where("transaction_date >= ? AND transaction_date < ?", from, to).
+		Select("status, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Group("status").
+		Scan(&rows)
+
+	if result.Error != nil {
+		return nil, dbError(ctx, r.logger, "failed to total payments by status", result.Error,
+			zap.Time("from", from),
+			zap.Time("to", to),
+		)
+	}
+
+	totals := make(map[domain.PaymentStatus]domain.PaymentTotals, len(rows))
+	for _, row := range rows {
+		totals[domain.PaymentStatus(row.Status)] = domain.PaymentTotals{Count: row.Count, Amount: row.Amount}
+	}
+	return totals, nil
+}
+
+// TotalsByWeek groups in SQL, so a long history is summed where it is
+// stored rather than read out row by row. Weeks are counted in whole
+// seconds, which both dialects do exactly.
+func (r *GORMPaymentRepository) TotalsByWeek(ctx context.Context, customerID string, start time.Time) (map[int]domain.PaymentTotals, error) {
+	var rows []struct {
+		Week   int
+		Count  int64
+		Amount int64
+	}
+
+	week := "GREATEST(FLOOR(TIMESTAMPDIFF(SECOND, ?, transaction_date) / 604800), 0)"
+	if r.db.Dialector.Name() == "sqlite" {
+		// SQLite divides integers towards zero, which is the floor once
+		// negative (pre-start) weeks are clamped to 0
+		week = "MAX((CAST(strftime('%s', transaction_date) AS INTEGER) - CAST(strftime('%s', ?) AS INTEGER)) / 604800, 0)"
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&persistence.PaymentModel{}).
+		Where("customer_id = ? AND status = ?", customerID, domain.PaymentStatusComplete).
+		Select(week+" AS week, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount", start.UTC()).
+		Group("week").
+		Scan(&rows)
+
+	if result.Error != nil {
+		return nil, dbError(ctx, r.logger, "failed to total payments by week", result.Error,
+			zap.String("customer_id", customerID),
+			zap.Time("start", start),
+		)
+	}
+
+	totals := make(map[int]domain.PaymentTotals, len(rows))
+	for _, row := range rows {
+		totals[row.Week] = domain.PaymentTotals{Count: row.Count, Amount: row.Amount}
+	}
+	return totals, nil
+}
+
 func (r *GORMPaymentRepository) CountByCustomerID(ctx context.Context, customerID string) (int64, error) {
 	var count int64
 
@@ -185,11 +451,9 @@ func (r *GORMPaymentRepository) CountByCustomerID(ctx context.Context, customerI
 		Count(&count)
 
 	if result.Error != nil {
-		r.logger.Error("failed to count payments by customer ID",
-			zap.Error(result.Error),
+		return 0, dbError(ctx, r.logger, "failed to count payments by customer ID", result.Error,
 			zap.String("customer_id", customerID),
 		)
-		return 0, fmt.Errorf("database error: %w", result.Error)
 	}
 
 	r.logger.Debug("counted payments by customer ID",
@@ -200,6 +464,54 @@ func (r *GORMPaymentRepository) CountByCustomerID(ctx context.Context, customerI
 	return count, nil
 }
 
+// FindPageByCustomerID counts the customer's matching payments and reads one
+// page of them in a single read-only REPEATABLE READ transaction. InnoDB
+// serves both queries from the snapshot the count takes, so a payment
+// recorded between them shows up in neither.
+func (r *GORMPaymentRepository) FindPageByCustomerID(ctx context.Context, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, int64, error) {
+	var (
+		count  int64
+		models []persistence.PaymentModel
+	)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := withStatuses(tx.Model(&persistence.PaymentModel{}).Where("customer_id = ?", customerID), filter).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count <= int64(offset) {
+			return nil
+		}
+		direction := sortDirection(filter, domain.PaymentOrderDesc)
+		return withStatuses(tx.Where("customer_id = ?", customerID), filter).
+			Order("transaction_date " + direction).
+			Order("id " + direction).
+			Limit(limit).
+			Offset(offset).
+			Find(&models).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, 0, dbError(ctx, r.logger, "failed to fetch page of payments by customer ID", err,
+			zap.String("customer_id", customerID),
+			zap.Int("limit", limit),
+			zap.Int("offset", offset),
+		)
+	}
+
+	payments := make([]*domain.Payment, len(models))
+	for i, model := range models {
+		payments[i] = model.ToDomain()
+	}
+
+	r.logger.Debug("fetched page of payments by customer ID",
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(payments)),
+		zap.Int64("total_count", count),
+	)
+
+	return payments, count, nil
+}
+
 func (r *GORMPaymentRepository) GetTotalPaidByCustomer(ctx context.Context, customerID string) (int64, error) {
 	var total int64
 
@@ -215,27 +527,3 @@ func (r *GORMPaymentRepository) GetTotalPaidByCustomer(ctx context.Context, cust
 
 	return total, nil
 }
-
-func isDuplicateError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	return errors.Is(err, gorm.ErrDuplicatedKey) ||
-		(err.Error() != "" && (contains(err.Error(), "Duplicate entry") ||
-			contains(err.Error(), "UNIQUE constraint")))
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
-		indexOf(s, substr) >= 0))
-}
-
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
-	}
-	return -1
-}
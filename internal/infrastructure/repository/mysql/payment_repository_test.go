@@ -0,0 +1,548 @@
+package sqlrepository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func seedPayment(t *testing.T, repo *GORMPaymentRepository, customerID, ref string, date time.Time) {
+	t.Helper()
+	payment, err := domain.NewPayment(customerID, 1000, ref, date, domain.PaymentStatusComplete, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(context.Background(), payment))
+}
+
+func TestFindByCustomerIDAfter_NoSkipsOrDuplicatesWithConcurrentInserts(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	original := map[string]bool{}
+	for i := 0; i < 25; i++ {
+		ref := fmt.Sprintf("TXN%03d", i)
+		// Pairs share a timestamp so the id tie-breaker is exercised
+		seedPayment(t, repo, "GIG00001", ref, base.Add(time.Duration(i/2)*time.Hour))
+		original[ref] = true
+	}
+	seedPayment(t, repo, "GIG00002", "OTHER001", base)
+
+	seen := map[string]int{}
+	var afterDate time.Time
+	var afterID string
+	pages := 0
+
+	for {
+		page, err := repo.FindByCustomerIDAfter(ctx, "GIG00001", domain.PaymentFilter{}, afterDate, afterID, 10)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		pages++
+
+		for _, p := range page {
+			assert.Equal(t, "GIG00001", p.CustomerID)
+			seen[p.TransactionReference]++
+		}
+		last := page[len(page)-1]
+		afterDate, afterID = last.TransactionDate, last.ID
+
+		// New payments arrive between page reads
+		if pages <= 2 {
+			seedPayment(t, repo, "GIG00001", fmt.Sprintf("NEW%03d", pages), base.Add(48*time.Hour+time.Duration(pages)*time.Minute))
+		}
+	}
+
+	for ref := range original {
+		assert.Equal(t, 1, seen[ref], "payment %s should be returned exactly once", ref)
+	}
+	assert.Equal(t, 1, seen["NEW001"])
+	assert.Equal(t, 1, seen["NEW002"])
+	assert.Zero(t, seen["OTHER001"])
+}
+
+func TestFindPageByCustomerID_CountMatchesPageWithConcurrentInserts(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 15; i++ {
+		seedPayment(t, repo, "GIG00001", fmt.Sprintf("TXN%03d", i), base.Add(time.Duration(i)*time.Hour))
+	}
+
+	// Another writer records 20 more payments while pages are read
+	const inserts = 20
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := 0; i < inserts; i++ {
+			payment, err := domain.NewPayment("GIG00001", 1000, fmt.Sprintf("LATE%03d", i), base.Add(time.Duration(100+i)*time.Hour), domain.PaymentStatusComplete, time.Now())
+			if err != nil {
+				return
+			}
+			// SQLite refuses a write while a read holds the table; MySQL doesn't
+			for repo.Save(ctx, payment) != nil {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	const pageSize = 10
+	reads := 0
+	for running := true; running; {
+		select {
+		case <-writerDone:
+			running = false
+		default:
+		}
+		payments, count, err := repo.FindPageByCustomerID(ctx, "GIG00001", domain.PaymentFilter{}, pageSize, pageSize)
+		// Give the writer a turn at the table
+		time.Sleep(100 * time.Microsecond)
+		if err != nil {
+			continue
+		}
+		// The second page holds exactly what the count says lies past the first
+		want := min(max(int(count)-pageSize, 0), pageSize)
+		require.Len(t, payments, want, "count %d", count)
+		reads++
+	}
+	require.Positive(t, reads)
+
+	payments, count, err := repo.FindPageByCustomerID(ctx, "GIG00001", domain.PaymentFilter{}, pageSize, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15+inserts), count)
+	assert.Len(t, payments, pageSize)
+
+	payments, count, err = repo.FindPageByCustomerID(ctx, "GIG00002", domain.PaymentFilter{}, pageSize, 0)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	assert.Empty(t, payments)
+}
+
+func TestFindPageByCustomerID_TiedDatesPageByID(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		seedPayment(t, repo, "GIG00001", fmt.Sprintf("TXN%03d", i), date)
+	}
+
+	var ids []string
+	for offset := 0; offset < 7; offset += 3 {
+		page, _, err := repo.FindPageByCustomerID(ctx, "GIG00001", domain.PaymentFilter{}, 3, offset)
+		require.NoError(t, err)
+		for _, p := range page {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	require.Len(t, ids, 7)
+	assert.True(t, slices.IsSortedFunc(ids, func(a, b string) int { return strings.Compare(b, a) }),
+		"payments on the same date come in id order, so each shows up on exactly one page")
+}
+
+func TestFindByCustomerIDAfter_OrdersByDateThenID(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	seedPayment(t, repo, "GIG00001", "TXN-B", base.Add(time.Hour))
+	seedPayment(t, repo, "GIG00001", "TXN-A", base)
+	seedPayment(t, repo, "GIG00001", "TXN-C", base.Add(time.Hour))
+
+	page, err := repo.FindByCustomerIDAfter(ctx, "GIG00001", domain.PaymentFilter{}, time.Time{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+
+	assert.Equal(t, "TXN-A", page[0].TransactionReference)
+	assert.True(t, page[1].TransactionDate.Equal(page[2].TransactionDate))
+	assert.Less(t, page[1].ID, page[2].ID)
+}
+
+func TestFindRecordedAfter_PollsEveryCustomerInRecordedOrder(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	record := func(customerID, ref string, recordedAt time.Time) {
+		t.Helper()
+		payment, err := domain.NewPayment(customerID, 1000, ref, base, domain.PaymentStatusComplete, recordedAt)
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, payment))
+	}
+	for i := 0; i < 9; i++ {
+		// Two customers interleaved, pairs sharing a timestamp
+		record(fmt.Sprintf("GIG0000%d", i%2+1), fmt.Sprintf("TXN%03d", i), base.Add(time.Duration(i/2)*time.Second))
+	}
+	until := base.Add(time.Hour)
+	record("GIG00001", "FUTURE", until)
+
+	seen := map[string]int{}
+	var refs []string
+	var afterTime time.Time
+	var afterID string
+	for polls := 1; ; polls++ {
+		page, err := repo.FindRecordedAfter(ctx, afterTime, afterID, until, 4)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, p := range page {
+			seen[p.TransactionReference]++
+			refs = append(refs, p.TransactionReference)
+		}
+		last := page[len(page)-1]
+		afterTime, afterID = last.CreatedAt, last.ID
+
+		// Payments keep arriving between polls
+		if polls == 1 {
+			record("GIG00002", "NEW001", base.Add(time.Minute))
+		}
+	}
+
+	assert.Len(t, refs, 10)
+	for ref, n := range seen {
+		assert.Equal(t, 1, n, "payment %s should be returned exactly once", ref)
+	}
+	assert.Equal(t, "NEW001", refs[len(refs)-1])
+	assert.Zero(t, seen["FUTURE"], "payments recorded at or after until are held back")
+}
+
+func TestFindByDateRange_BoundedAndAllCustomers(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	seedPayment(t, repo, "GIG00001", "TXN-BEFORE", base.Add(-time.Hour))
+	seedPayment(t, repo, "GIG00001", "TXN-1", base)
+	seedPayment(t, repo, "GIG00002", "TXN-2", base.Add(time.Hour))
+	seedPayment(t, repo, "GIG00001", "TXN-3", base.Add(2*time.Hour))
+	seedPayment(t, repo, "GIG00002", "TXN-AFTER", base.Add(3*time.Hour+time.Second))
+
+	from, to := base, base.Add(3*time.Hour)
+
+	page, err := repo.FindByDateRange(ctx, from, to, "", domain.PaymentFilter{}, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "TXN-3", page[0].TransactionReference)
+	assert.Equal(t, "TXN-2", page[1].TransactionReference)
+
+	page, err = repo.FindByDateRange(ctx, from, to, "", domain.PaymentFilter{}, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "TXN-1", page[0].TransactionReference)
+
+	totals, err := repo.TotalsByDateRange(ctx, from, to, "", domain.PaymentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentTotals{Count: 3, Amount: 3000}, totals)
+
+	page, err = repo.FindByDateRange(ctx, from, to, "GIG00001", domain.PaymentFilter{}, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	for _, p := range page {
+		assert.Equal(t, "GIG00001", p.CustomerID)
+	}
+
+	totals, err = repo.TotalsByDateRange(ctx, from, to, "GIG00001", domain.PaymentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentTotals{Count: 2, Amount: 2000}, totals)
+}
+
+func TestTotalsByStatus_SplitsAtDayBoundary(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	lagos := time.FixedZone("WAT", 60*60)
+	dayStart := time.Date(2025, 3, 1, 0, 0, 0, 0, lagos)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	seedPayment(t, repo, "GIG00001", "TXN-PREV-DAY", dayStart.Add(-time.Second))
+	seedPayment(t, repo, "GIG00001", "TXN-MIDNIGHT", dayStart)
+	seedPayment(t, repo, "GIG00002", "TXN-EVENING", dayEnd.Add(-time.Second))
+	seedPayment(t, repo, "GIG00002", "TXN-NEXT-DAY", dayEnd)
+	writeOff, err := domain.NewPayment("GIG00003", 5000, "WRITEOFF-1", dayStart.Add(12*time.Hour), domain.PaymentStatusWriteOff, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, writeOff))
+
+	totals, err := repo.TotalsByStatus(ctx, dayStart, dayEnd)
+	require.NoError(t, err)
+	assert.Equal(t, map[domain.PaymentStatus]domain.PaymentTotals{
+		domain.PaymentStatusComplete: {Count: 2, Amount: 2000},
+		domain.PaymentStatusWriteOff: {Count: 1, Amount: 5000},
+	}, totals)
+
+	totals, err = repo.TotalsByStatus(ctx, dayEnd.AddDate(0, 0, 1), dayEnd.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Empty(t, totals)
+}
+
+func TestPaymentSave_CanceledContext(t *testing.T) {
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	payment, err := domain.NewPayment("GIG00001", 100000, "TX-CANCELED", time.Now(), domain.PaymentStatusComplete, time.Now())
+	require.NoError(t, err)
+
+	err = repo.Save(ctx, payment)
+	assert.ErrorIs(t, err, domain.ErrRequestCanceled)
+
+	_, err = repo.FindByTransactionReference(context.Background(), "GIG00001", "TX-CANCELED")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+}
+
+func TestFindByCustomerIDFiltered_StatusesAndOrder(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i, status := range []domain.PaymentStatus{
+		domain.PaymentStatusComplete,
+		domain.PaymentStatusPending,
+		domain.PaymentStatusComplete,
+		domain.PaymentStatusWriteOff,
+		domain.PaymentStatusFailed,
+	} {
+		payment, err := domain.NewPayment("GIG00001", 1000, fmt.Sprintf("TXN%d", i), base.Add(time.Duration(i)*time.Hour), status, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, payment))
+	}
+	seedPayment(t, repo, "GIG00002", "OTHER001", base)
+
+	refs := func(payments []*domain.Payment) []string {
+		out := make([]string, len(payments))
+		for i, p := range payments {
+			out[i] = p.TransactionReference
+		}
+		return out
+	}
+
+	all, err := repo.FindByCustomerIDFiltered(ctx, "GIG00001", domain.PaymentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN4", "TXN3", "TXN2", "TXN1", "TXN0"}, refs(all), "defaults to every status, newest first")
+
+	complete, err := repo.FindByCustomerIDFiltered(ctx, "GIG00001", domain.PaymentFilter{
+		Statuses: []domain.PaymentStatus{domain.PaymentStatusComplete},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN2", "TXN0"}, refs(complete))
+
+	ascending, err := repo.FindByCustomerIDFiltered(ctx, "GIG00001", domain.PaymentFilter{
+		Statuses: []domain.PaymentStatus{domain.PaymentStatusComplete, domain.PaymentStatusPending},
+		Order:    domain.PaymentOrderAsc,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN0", "TXN1", "TXN2"}, refs(ascending))
+
+	descending, err := repo.FindByCustomerIDFiltered(ctx, "GIG00001", domain.PaymentFilter{Order: domain.PaymentOrderDesc})
+	require.NoError(t, err)
+	assert.Equal(t, refs(all), refs(descending))
+}
+
+func TestPaymentFilter_NarrowsPagesCursorsAndRanges(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i, status := range []domain.PaymentStatus{
+		domain.PaymentStatusComplete,
+		domain.PaymentStatusPending,
+		domain.PaymentStatusComplete,
+		domain.PaymentStatusWriteOff,
+		domain.PaymentStatusComplete,
+	} {
+		payment, err := domain.NewPayment("GIG00001", int64(1000*(i+1)), fmt.Sprintf("TXN%d", i), base.Add(time.Duration(i)*time.Hour), status, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, payment))
+	}
+
+	refs := func(payments []*domain.Payment) []string {
+		out := make([]string, len(payments))
+		for i, p := range payments {
+			out[i] = p.TransactionReference
+		}
+		return out
+	}
+	complete := domain.PaymentFilter{Statuses: []domain.PaymentStatus{domain.PaymentStatusComplete}}
+	completeAsc := domain.PaymentFilter{Statuses: complete.Statuses, Order: domain.PaymentOrderAsc}
+	completeDesc := domain.PaymentFilter{Statuses: complete.Statuses, Order: domain.PaymentOrderDesc}
+
+	page, count, err := repo.FindPageByCustomerID(ctx, "GIG00001", complete, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, []string{"TXN4", "TXN2"}, refs(page))
+
+	page, _, err = repo.FindPageByCustomerID(ctx, "GIG00001", completeAsc, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN4"}, refs(page))
+
+	page, err = repo.FindByCustomerIDAfter(ctx, "GIG00001", complete, time.Time{}, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN0", "TXN2", "TXN4"}, refs(page), "ascending by default")
+
+	page, err = repo.FindByCustomerIDAfter(ctx, "GIG00001", completeDesc, time.Time{}, "", 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"TXN4"}, refs(page))
+	page, err = repo.FindByCustomerIDAfter(ctx, "GIG00001", completeDesc, page[0].TransactionDate, page[0].ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN2", "TXN0"}, refs(page))
+
+	from, to := base, base.Add(3*time.Hour)
+	page, err = repo.FindByDateRange(ctx, from, to, "GIG00001", completeAsc, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN0", "TXN2"}, refs(page))
+
+	totals, err := repo.TotalsByDateRange(ctx, from, to, "", complete)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentTotals{Count: 2, Amount: 4000}, totals)
+}
+
+func TestPaymentSave_KeepsBalanceAfter(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	payment, err := domain.NewPayment("GIG00001", 1000, "TXN001", date, domain.PaymentStatusComplete, time.Now())
+	require.NoError(t, err)
+	payment.RecordBalanceAfter(99000)
+	require.NoError(t, repo.Save(ctx, payment))
+	seedPayment(t, repo, "GIG00001", "TXN002", date)
+
+	stored, err := repo.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	require.NotNil(t, stored.BalanceAfter)
+	assert.Equal(t, int64(99000), *stored.BalanceAfter)
+
+	legacy, err := repo.FindByTransactionReference(ctx, "GIG00001", "TXN002")
+	require.NoError(t, err)
+	assert.Nil(t, legacy.BalanceAfter, "rows without a captured balance stay NULL")
+}
+
+func TestPaymentSave_KeepsMetadata(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+	date := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	payment, err := domain.NewPayment("GIG00001", 1000, "TXN001", date, domain.PaymentStatusComplete, time.Now())
+	require.NoError(t, err)
+	payment.Metadata = map[string]string{"channel": "ussd", "agent_id": "AG-17"}
+	require.NoError(t, repo.Save(ctx, payment))
+	seedPayment(t, repo, "GIG00001", "TXN002", date)
+
+	stored, err := repo.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"channel": "ussd", "agent_id": "AG-17"}, stored.Metadata)
+
+	listed, err := repo.FindByCustomerID(ctx, "GIG00001")
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	for _, p := range listed {
+		if p.TransactionReference == "TXN002" {
+			assert.Nil(t, p.Metadata, "payments sent without metadata have none")
+		} else {
+			assert.Equal(t, "ussd", p.Metadata["channel"])
+		}
+	}
+}
+
+func TestTotalsByWeek_GroupsFromStart(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := env.paymentRepository()
+	start := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+
+	seedPayment(t, repo, "GIG00001", "EARLY", start.Add(-time.Hour))
+	seedPayment(t, repo, "GIG00001", "W0A", start)
+	seedPayment(t, repo, "GIG00001", "W0B", start.Add(week-time.Second))
+	seedPayment(t, repo, "GIG00001", "W1", start.Add(week))
+	// Nothing in week 2
+	seedPayment(t, repo, "GIG00001", "W3", start.Add(3*week+48*time.Hour))
+	seedPayment(t, repo, "GIG00002", "OTHER", start.Add(2*week))
+
+	pending, err := domain.NewPayment("GIG00001", 5000, "PENDING", start.Add(2*week), domain.PaymentStatusPending, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, pending))
+
+	totals, err := repo.TotalsByWeek(ctx, "GIG00001", start)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]domain.PaymentTotals{
+		0: {Count: 3, Amount: 3000},
+		1: {Count: 1, Amount: 1000},
+		3: {Count: 1, Amount: 1000},
+	}, totals, "payments before start count towards week 0; other customers and non-complete payments are left out")
+
+	totals, err = repo.TotalsByWeek(ctx, "GIG09999", start)
+	require.NoError(t, err)
+	assert.Empty(t, totals)
+}
+
+func TestPaymentSave_DedupScope(t *testing.T) {
+	tests := []struct {
+		scope     domain.DedupScope
+		sharedRef bool
+		cachedKey string
+	}{
+		{domain.DedupScopeGlobal, false, "payment:TXN001"},
+		{domain.DedupScopeCustomer, true, "payment:GIG00001:TXN001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			require.NoError(t, persistence.ApplyDedupScope(ctx, env.db, tt.scope))
+			repo := NewPaymentRepository(env.db, env.redis, time.Hour, "", tt.scope, zap.NewNop())
+			seedPayment(t, repo, "GIG00001", "TXN001", time.Now())
+
+			// The lookup caches the payment's dedup key in the background
+			exists, err := repo.ExistsByTransactionReference(ctx, "GIG00001", "TXN001")
+			require.NoError(t, err)
+			assert.True(t, exists)
+			assert.Eventually(t, func() bool { return env.mr.Exists(tt.cachedKey) }, time.Second, 10*time.Millisecond)
+
+			exists, err = repo.ExistsByTransactionReference(ctx, "GIG00002", "TXN001")
+			require.NoError(t, err)
+			assert.Equal(t, !tt.sharedRef, exists, "another customer's reference")
+
+			other, err := domain.NewPayment("GIG00002", 1000, "TXN001", time.Now(), domain.PaymentStatusComplete, time.Now())
+			require.NoError(t, err)
+			if tt.sharedRef {
+				require.NoError(t, repo.Save(ctx, other))
+				stored, err := repo.FindByTransactionReference(ctx, "GIG00002", "TXN001")
+				require.NoError(t, err)
+				assert.Equal(t, other.ID, stored.ID)
+			} else {
+				assert.ErrorIs(t, repo.Save(ctx, other), domain.ErrDuplicateTransaction)
+			}
+
+			again, err := domain.NewPayment("GIG00001", 1000, "TXN001", time.Now(), domain.PaymentStatusComplete, time.Now())
+			require.NoError(t, err)
+			assert.ErrorIs(t, repo.Save(ctx, again), domain.ErrDuplicateTransaction, "the same customer may never reuse a reference")
+		})
+	}
+}
@@ -1,27 +1,182 @@
 package sqlrepository
 
 import (
-	"errors"
+	"context"
+	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 var (
-	ErrCustomerNotFound = errors.New("customer not found")
-	ErrPaymentNotFound  = errors.New("payment not found")
+	ErrCustomerNotFound      = domain.ErrCustomerNotFound
+	ErrCustomerAlreadyExists = domain.ErrCustomerAlreadyExists
+	ErrPaymentNotFound       = domain.ErrPaymentNotFound
 )
 
 type Repositories struct {
 	Customer domain.CustomerRepository
-	Payment  domain.PaymentRepository
+	// UncachedCustomers reads Customer's customers past the cache
+	UncachedCustomers domain.UncachedCustomerFinder
+	Payment           domain.PaymentRepository
+	// PaymentFeed walks Payment's rows in the order they were recorded
+	PaymentFeed domain.PaymentFeed
+	// PaymentPager reads a page of Payment's rows with a matching count
+	PaymentPager domain.PaymentPager
+	// PaymentTotals breaks Payment's rows down by status for daily reports
+	PaymentTotals domain.PaymentStatusTotaler
+	// PaymentWeeks totals a customer's Payment rows per schedule week
+	PaymentWeeks domain.PaymentWeekTotaler
+	// CustomerLister pages customers straight from MySQL for reports
+	CustomerLister domain.CustomerLister
+	// OtherLoans and Credits back the overpayment policies
+	OtherLoans domain.OtherLoanFinder
+	Credits    domain.CreditLedger
+	// EventLog records published events; inside WithTx it is part of the
+	// transaction, so an event commits with the change that raised it
+	EventLog domain.EventLog
+	// CustomerCache is the Redis layer in front of Customer, exposed for
+	// manual eviction
+	CustomerCache *redisrepository.RedisCustomerRepository
+
+	db          *gorm.DB
+	redisClient redis.UniversalClient
+	config      Config
+	access      *redisrepository.CustomerAccessLog
+	logger      *zap.Logger
+}
+
+// Config holds tunables for the repository layer
+type Config struct {
+	PaymentDedupTTL time.Duration
+	// KeyPrefix namespaces every Redis key the repositories touch
+	KeyPrefix keyspace.Prefix
+	// RejectInvalidCustomers refuses to save a customer that fails
+	// Customer.Validate; otherwise violations are only logged and counted
+	RejectInvalidCustomers bool
+	// TrackCustomerAccess records customer reads for CustomerCacheRefresher
+	TrackCustomerAccess bool
+	// DedupScope is what transaction references are unique within
+	DedupScope domain.DedupScope
+	// DisableCache reads and writes MySQL only: no customer cache and no
+	// payment dedup keys. CustomerCache still evicts on request, so keys
+	// left from before can be cleared.
+	DisableCache bool
 }
 
-func NewRepositories(db *gorm.DB, redisClient *redis.Client, logger *zap.Logger) *Repositories {
+// NewRepositories wires the MySQL repositories with the Redis cache in front
+// of customers. The API and the worker both build theirs here, so a cache
+// miss in either falls back to MySQL.
+func NewRepositories(db *gorm.DB, redisClient redis.UniversalClient, cfg Config, logger *zap.Logger) *Repositories {
+	cache := redisrepository.NewRedisCustomerRepository(redisClient, customerCacheTTL, cfg.KeyPrefix)
+	cacheClient := redisClient
+	if cfg.DisableCache {
+		cacheClient = nil
+	}
+
+	customers := NewCustomerRepository(db, logger)
+	customers.rejectInvalid = cfg.RejectInvalidCustomers
+	cached := NewCachingCustomerRepository(customers, customerCacheOf(cache, cfg), logger)
+	if cfg.TrackCustomerAccess && !cfg.DisableCache {
+		cached.access = redisrepository.NewCustomerAccessLog(redisClient, cfg.KeyPrefix)
+	}
+	payments := NewPaymentRepository(db, cacheClient, cfg.PaymentDedupTTL, cfg.KeyPrefix, cfg.DedupScope, logger)
 	return &Repositories{
-		Customer: NewCustomerRepository(db, redisClient, logger),
-		Payment:  NewPaymentRepository(db, redisClient, logger),
+		Customer:          cached,
+		UncachedCustomers: cached,
+		Payment:           payments,
+		PaymentFeed:       payments,
+		PaymentPager:      payments,
+		PaymentTotals:     payments,
+		PaymentWeeks:      payments,
+		CustomerLister:    customers,
+		OtherLoans:        customers,
+		Credits:           NewCreditLedger(db, logger),
+		EventLog:          NewEventLog(db, logger),
+
+		CustomerCache: cache,
+
+		db:          db,
+		redisClient: cacheClient,
+		config:      cfg,
+		access:      cached.access,
+		logger:      logger,
+	}
+}
+
+// WithTx runs fn with repositories bound to a single MySQL transaction,
+// committing if fn returns nil and rolling back otherwise. Customer cache
+// writes are held back until commit, when the touched customers are evicted
+// so the next read repopulates from committed rows.
+func (r *Repositories) WithTx(ctx context.Context, fn func(txRepos *Repositories) error) error {
+	touched := &touchedCustomers{}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		customerRepo := NewCustomerRepository(tx, r.logger)
+		customerRepo.rejectInvalid = r.config.RejectInvalidCustomers
+		cachedRepo := NewCachingCustomerRepository(customerRepo, customerCacheOf(r.CustomerCache, r.config), r.logger)
+		cachedRepo.txTouched = touched
+		cachedRepo.access = r.access
+		paymentRepo := NewPaymentRepository(tx, r.redisClient, r.config.PaymentDedupTTL, r.config.KeyPrefix, r.config.DedupScope, r.logger)
+
+		return fn(&Repositories{
+			Customer:          cachedRepo,
+			UncachedCustomers: cachedRepo,
+			Payment:           paymentRepo,
+			PaymentFeed:       paymentRepo,
+			PaymentPager:      paymentRepo,
+			PaymentTotals:     paymentRepo,
+			PaymentWeeks:      paymentRepo,
+			CustomerLister:    customerRepo,
+			OtherLoans:        customerRepo,
+			Credits:           NewCreditLedger(tx, r.logger),
+			EventLog:          NewEventLog(tx, r.logger),
+			CustomerCache:     r.CustomerCache,
+
+			db:          tx,
+			redisClient: r.redisClient,
+			config:      r.config,
+			access:      r.access,
+			logger:      r.logger,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if r.config.DisableCache {
+		return nil
+	}
+
+	if _, err := r.CustomerCache.DeleteMany(ctx, touched.ids); err != nil {
+		r.logger.Warn("failed to invalidate cache after commit",
+			zap.Error(err),
+			zap.Strings("customer_ids", touched.ids),
+		)
+	}
+
+	return nil
+}
+
+// InTx is WithTx for callers that only know the domain interfaces
+func (r *Repositories) InTx(ctx context.Context, fn func(repos domain.TxRepositories) error) error {
+	return r.WithTx(ctx, func(txRepos *Repositories) error {
+		return fn(domain.TxRepositories{
+			Customers: txRepos.Customer,
+			Payments:  txRepos.Payment,
+			EventLog:  txRepos.EventLog,
+		})
+	})
+}
+
+// customerCacheOf is the cache reads and writes go through, nil when
+// caching is disabled
+func customerCacheOf(cache *redisrepository.RedisCustomerRepository, cfg Config) *redisrepository.RedisCustomerRepository {
+	if cfg.DisableCache {
+		return nil
 	}
+	return cache
 }
@@ -0,0 +1,257 @@
+package sqlrepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
+	"github.com/gigmile/payment-service/internal/infrastructure/persistence"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type testEnv struct {
+	db    *gorm.DB
+	redis *redis.Client
+	mr    *miniredis.Miniredis
+}
+
+// newTestEnv wires an in-memory SQLite database and a miniredis instance,
+// standing in for MySQL and Redis.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", name)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&persistence.CustomerModel{}, &persistence.PaymentModel{}, &persistence.CustomerCreditModel{}, &persistence.EventLogModel{}))
+	require.NoError(t, persistence.ApplyDedupScope(context.Background(), db, domain.DedupScopeGlobal))
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return &testEnv{db: db, redis: client, mr: mr}
+}
+
+func (e *testEnv) paymentRepository() *GORMPaymentRepository {
+	return NewPaymentRepository(e.db, e.redis, time.Hour, "", domain.DedupScopeGlobal, zap.NewNop())
+}
+
+func (e *testEnv) customerRepository() *GORMCustomerRepository {
+	return NewCustomerRepository(e.db, zap.NewNop())
+}
+
+// cachedCustomerRepository layers the Redis cache over MySQL, counting into
+// a private registry so tests don't share metrics
+func (e *testEnv) cachedCustomerRepository() *CachingCustomerRepository {
+	repo := NewCachingCustomerRepository(e.customerRepository(),
+		redisrepository.NewRedisCustomerRepository(e.redis, customerCacheTTL, ""), zap.NewNop())
+	repo.metrics = newCustomerCacheMetrics(metrics.NewRegistry())
+	return repo
+}
+
+func (e *testEnv) repositories() *Repositories {
+	return NewRepositories(e.db, e.redis, Config{PaymentDedupTTL: time.Hour}, zap.NewNop())
+}
+
+// payInTx applies a payment to the customer and records it, both through txRepos
+func payInTx(ctx context.Context, txRepos *Repositories, customerID, txRef string, amount int64) error {
+	customer, err := txRepos.Customer.FindByID(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if err := customer.ApplyPayment(amount, time.Now()); err != nil {
+		return err
+	}
+	if err := txRepos.Customer.Save(ctx, customer); err != nil {
+		return err
+	}
+
+	payment, err := domain.NewPayment(customerID, amount, txRef, time.Now(), domain.PaymentStatusComplete, time.Now())
+	if err != nil {
+		return err
+	}
+	return txRepos.Payment.Save(ctx, payment)
+}
+
+func TestWithTx_RollsBackBothWritesOnError(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	repos := env.repositories()
+
+	var before persistence.CustomerModel
+	require.NoError(t, env.db.First(&before, "id = ?", "GIG00001").Error)
+
+	errBoom := errors.New("boom")
+	err := repos.WithTx(ctx, func(txRepos *Repositories) error {
+		if err := payInTx(ctx, txRepos, "GIG00001", "TXN001", 2500000); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	require.ErrorIs(t, err, errBoom)
+
+	var model persistence.CustomerModel
+	require.NoError(t, env.db.First(&model, "id = ?", "GIG00001").Error)
+	assert.Equal(t, int64(100000000), model.OutstandingBalance)
+	assert.Equal(t, int64(0), model.TotalPaid)
+	assert.Equal(t, before.Version, model.Version)
+
+	var payments int64
+	require.NoError(t, env.db.Model(&persistence.PaymentModel{}).Count(&payments).Error)
+	assert.Zero(t, payments)
+
+	assert.False(t, env.mr.Exists("customer:GIG00001"), "uncommitted state must not reach the cache")
+}
+
+func TestWithTx_CommitsAndEvictsCache(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	repos := env.repositories()
+
+	// Warm the cache so we can see it evicted after commit
+	var before persistence.CustomerModel
+	require.NoError(t, env.db.First(&before, "id = ?", "GIG00001").Error)
+	require.NoError(t, repos.CustomerCache.Save(ctx, before.ToDomain()))
+
+	require.NoError(t, repos.WithTx(ctx, func(txRepos *Repositories) error {
+		return payInTx(ctx, txRepos, "GIG00001", "TXN001", 2500000)
+	}))
+
+	var model persistence.CustomerModel
+	require.NoError(t, env.db.First(&model, "id = ?", "GIG00001").Error)
+	assert.Equal(t, int64(97500000), model.OutstandingBalance)
+	assert.Equal(t, int64(2500000), model.TotalPaid)
+
+	var payments int64
+	require.NoError(t, env.db.Model(&persistence.PaymentModel{}).Count(&payments).Error)
+	assert.Equal(t, int64(1), payments)
+
+	assert.False(t, env.mr.Exists("customer:GIG00001"))
+}
+
+func TestInTx_DuplicatePaymentRollsBackCustomer(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 2)
+	repos := env.repositories()
+
+	earlier, err := domain.NewPayment("GIG00002", 2500000, "TXN001", time.Now(), domain.PaymentStatusComplete, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, repos.Payment.Save(ctx, earlier))
+
+	err = repos.InTx(ctx, func(txRepos domain.TxRepositories) error {
+		customer, err := txRepos.Customers.FindByID(ctx, "GIG00001")
+		if err != nil {
+			return err
+		}
+		if err := customer.ApplyPayment(2500000, time.Now()); err != nil {
+			return err
+		}
+		if err := txRepos.Customers.Save(ctx, customer); err != nil {
+			return err
+		}
+		payment, err := domain.NewPayment("GIG00001", 2500000, "TXN001", time.Now(), domain.PaymentStatusComplete, time.Now())
+		if err != nil {
+			return err
+		}
+		return txRepos.Payments.Save(ctx, payment)
+	})
+	require.ErrorIs(t, err, domain.ErrDuplicateTransaction)
+
+	var model persistence.CustomerModel
+	require.NoError(t, env.db.First(&model, "id = ?", "GIG00001").Error)
+	assert.Equal(t, int64(100000000), model.OutstandingBalance, "the balance change rolls back with the payment")
+	assert.Equal(t, int64(0), model.TotalPaid)
+}
+
+func TestRepositories_CustomerLookupFallsBackToMySQL(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 1)
+	repos := env.repositories()
+	require.False(t, env.mr.Exists("customer:GIG00001"))
+
+	// A customer the API never cached is still found, the way the worker
+	// looks one up to notify them
+	customer, err := repos.Customer.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, "GIG00001", customer.ID)
+	assert.Equal(t, int64(100000000), customer.OutstandingBalance)
+	assert.Eventually(t, func() bool { return env.mr.Exists("customer:GIG00001") }, time.Second, 10*time.Millisecond,
+		"the miss fills the cache")
+
+	// Once evicted, the next lookup reads the committed row again
+	require.NoError(t, env.db.Model(&persistence.CustomerModel{}).Where("id = ?", "GIG00001").
+		Update("outstanding_balance", 97500000).Error)
+	_, err = repos.CustomerCache.Delete(ctx, "GIG00001")
+	require.NoError(t, err)
+	customer, err = repos.Customer.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(97500000), customer.OutstandingBalance)
+
+	_, err = repos.Customer.FindByID(ctx, "GIG09999")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+}
+
+func TestRepositories_DisabledCacheNeverTouchesRedis(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	seedCustomers(t, env, 2)
+	repos := NewRepositories(env.db, env.redis, Config{
+		PaymentDedupTTL:     time.Hour,
+		TrackCustomerAccess: true,
+		DisableCache:        true,
+	}, zap.NewNop())
+	commands := env.mr.CommandCount()
+
+	customer, err := repos.Customer.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000000), customer.OutstandingBalance)
+	found, err := repos.Customer.FindByIDs(ctx, []string{"GIG00001", "GIG00002"})
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	require.NoError(t, repos.WithTx(ctx, func(txRepos *Repositories) error {
+		return payInTx(ctx, txRepos, "GIG00001", "TXN001", 2500000)
+	}))
+	customer, err = repos.Customer.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(97500000), customer.OutstandingBalance, "reads see the commit straight away")
+
+	// Duplicates are still caught, by MySQL alone
+	exists, err := repos.Payment.ExistsByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	_, err = repos.Payment.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	duplicate, err := domain.NewPayment("GIG00001", 2500000, "TXN001", time.Now(), domain.PaymentStatusComplete, time.Now())
+	require.NoError(t, err)
+	assert.ErrorIs(t, repos.Payment.Save(ctx, duplicate), domain.ErrDuplicateTransaction)
+
+	// Cache fills run in the background, so give any stray one time to land
+	assert.Never(t, func() bool { return env.mr.CommandCount() != commands }, 100*time.Millisecond, 10*time.Millisecond)
+	assert.Empty(t, env.mr.Keys())
+}
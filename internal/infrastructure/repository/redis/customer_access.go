@@ -0,0 +1,57 @@
+package redisrepository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+// customerAccessKey is one sorted set of customer IDs scored by when each
+// was last read, in Unix milliseconds
+const customerAccessKey = "customer_access"
+
+// CustomerAccessLog records when customers were last read, so the cache
+// refresher can tell hot customers from cold ones. Entries stay until
+// Forget drops them.
+type CustomerAccessLog struct {
+	client redis.UniversalClient
+	key    string
+}
+
+func NewCustomerAccessLog(client redis.UniversalClient, keys keyspace.Prefix) *CustomerAccessLog {
+	return &CustomerAccessLog{client: client, key: keys.Key(customerAccessKey)}
+}
+
+// Touch records that the customer was read at at
+func (l *CustomerAccessLog) Touch(ctx context.Context, customerID string, at time.Time) error {
+	member := &redis.Z{Score: float64(at.UnixMilli()), Member: customerID}
+	if err := l.client.ZAdd(ctx, l.key, member).Err(); err != nil {
+		return fmt.Errorf("failed to record customer access: %w", err)
+	}
+	return nil
+}
+
+// AccessedSince lists the customers read at or after since, most recent first
+func (l *CustomerAccessLog) AccessedSince(ctx context.Context, since time.Time) ([]string, error) {
+	ids, err := l.client.ZRevRangeByScore(ctx, l.key, &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accessed customers: %w", err)
+	}
+	return ids, nil
+}
+
+// Forget drops customers last read before before and returns how many
+func (l *CustomerAccessLog) Forget(ctx context.Context, before time.Time) (int64, error) {
+	removed, err := l.client.ZRemRangeByScore(ctx, l.key, "-inf", "("+strconv.FormatInt(before.UnixMilli(), 10)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to forget customer accesses: %w", err)
+	}
+	return removed, nil
+}
@@ -5,26 +5,31 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
 	"github.com/go-redis/redis/v8"
 )
 
 var (
-	ErrCustomerNotFound = errors.New("customer not found")
+	ErrCustomerNotFound = domain.ErrCustomerNotFound
 	ErrVersionMismatch  = errors.New("version mismatch - optimistic lock failed")
 )
 
 type RedisCustomerRepository struct {
-	client   *redis.Client
+	client   redis.UniversalClient
 	cacheTTL time.Duration
+	keys     keyspace.Prefix
 }
 
-func NewRedisCustomerRepository(client *redis.Client, cacheTTL time.Duration) *RedisCustomerRepository {
+func NewRedisCustomerRepository(client redis.UniversalClient, cacheTTL time.Duration, keys keyspace.Prefix) *RedisCustomerRepository {
 	return &RedisCustomerRepository{
 		client:   client,
 		cacheTTL: cacheTTL,
+		keys:     keys,
 	}
 }
 
@@ -47,6 +52,40 @@ func (r *RedisCustomerRepository) FindByID(ctx context.Context, customerID strin
 	return &customer, nil
 }
 
+// FindByIDs fetches cached customers with a single MGET. Keys that are
+// missing or fail to decode are left out so the caller can fall back to MySQL.
+func (r *RedisCustomerRepository) FindByIDs(ctx context.Context, customerIDs []string) (map[string]*domain.Customer, error) {
+	customers := make(map[string]*domain.Customer, len(customerIDs))
+	if len(customerIDs) == 0 {
+		return customers, nil
+	}
+
+	// Pipelined GETs rather than MGET: in cluster mode the keys usually
+	// hash to different slots, which MGET rejects
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(customerIDs))
+	for i, id := range customerIDs {
+		cmds[i] = pipe.Get(ctx, r.customerKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get customers: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		var customer domain.Customer
+		if err := json.Unmarshal(data, &customer); err != nil {
+			continue
+		}
+		customers[customerIDs[i]] = &customer
+	}
+
+	return customers, nil
+}
+
 func (r *RedisCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
 	key := r.customerKey(customer.ID)
 
@@ -62,6 +101,97 @@ func (r *RedisCustomerRepository) Save(ctx context.Context, customer *domain.Cus
 	return nil
 }
 
+// saveIfNewerScript sets KEYS[1] to ARGV[1] unless it already holds a
+// customer at version ARGV[2] or later, answering 1 when it wrote. ARGV[3]
+// is the expiry in milliseconds, 0 for none.
+var saveIfNewerScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if data then
+	local ok, cached = pcall(cjson.decode, data)
+	if ok and type(cached) == 'table' and tonumber(cached.Version) and tonumber(cached.Version) >= tonumber(ARGV[2]) then
+		return 0
+	end
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// SaveIfNewer caches customer unless the cache already holds it at the
+// same or a later version, so a bulk load can't overwrite a fresher entry
+// written meanwhile. It reports whether it wrote.
+func (r *RedisCustomerRepository) SaveIfNewer(ctx context.Context, customer *domain.Customer) (bool, error) {
+	data, err := json.Marshal(customer)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal customer: %w", err)
+	}
+
+	written, err := saveIfNewerScript.Run(ctx, r.client, []string{r.customerKey(customer.ID)},
+		data, customer.Version, r.cacheTTL.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to save customer: %w", err)
+	}
+	return written == 1, nil
+}
+
+// SaveMany caches the given customers in a single pipeline
+func (r *RedisCustomerRepository) SaveMany(ctx context.Context, customers []*domain.Customer) error {
+	if len(customers) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, customer := range customers {
+		data, err := json.Marshal(customer)
+		if err != nil {
+			return fmt.Errorf("failed to marshal customer: %w", err)
+		}
+		pipe.Set(ctx, r.customerKey(customer.ID), data, r.cacheTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save customers: %w", err)
+	}
+
+	return nil
+}
+
+// ExpiresIn returns how long each cached customer has left, in a single
+// pipeline. A customer that isn't cached has zero left; one cached without
+// an expiry is left out.
+func (r *RedisCustomerRepository) ExpiresIn(ctx context.Context, customerIDs []string) (map[string]time.Duration, error) {
+	left := make(map[string]time.Duration, len(customerIDs))
+	if len(customerIDs) == 0 {
+		return left, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(customerIDs))
+	for i, id := range customerIDs {
+		cmds[i] = pipe.PTTL(ctx, r.customerKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get customer expiries: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		// go-redis passes PTTL's -2 (missing) and -1 (no expiry) through as is
+		switch ttl := cmd.Val(); ttl {
+		case -2:
+			left[customerIDs[i]] = 0
+		case -1:
+		default:
+			left[customerIDs[i]] = ttl
+		}
+	}
+	return left, nil
+}
+
+// UpdateBalance applies a payment atomically in Lua. The script touches
+// only KEYS[1], so it runs unchanged against a cluster.
 func (r *RedisCustomerRepository) UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error {
 	script := `
 		local key = KEYS[1]
@@ -118,13 +248,114 @@ func (r *RedisCustomerRepository) UpdateBalance(ctx context.Context, customerID
 }
 
 func (r *RedisCustomerRepository) customerKey(customerID string) string {
-	return fmt.Sprintf("customer:%s", customerID)
+	return r.keys.Key(fmt.Sprintf("customer:%s", customerID))
 }
 
-func (r *RedisCustomerRepository) Delete(ctx context.Context, customerID string) error {
+// Delete evicts a cached customer and reports whether a key was present
+func (r *RedisCustomerRepository) Delete(ctx context.Context, customerID string) (bool, error) {
 	key := r.customerKey(customerID)
-	if err := r.client.Del(ctx, key).Err(); err != nil {
-		return fmt.Errorf("failed to delete customer: %w", err)
+	deleted, err := r.client.Del(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete customer: %w", err)
 	}
-	return nil
+	return deleted > 0, nil
+}
+
+// DeleteMany evicts the given customers in a single pipeline and returns how
+// many keys were removed
+func (r *RedisCustomerRepository) DeleteMany(ctx context.Context, customerIDs []string) (int64, error) {
+	if len(customerIDs) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, len(customerIDs))
+	for i, id := range customerIDs {
+		keys[i] = r.customerKey(id)
+	}
+
+	return r.deleteKeys(ctx, keys)
+}
+
+// DeleteByPattern evicts customers whose ID matches a Redis glob pattern.
+// It walks the keyspace with SCAN rather than KEYS so Redis isn't blocked,
+// then deletes the matches in pipelined chunks.
+func (r *RedisCustomerRepository) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	var keys []string
+	scan := func(ctx context.Context, node redis.UniversalClient) error {
+		var cursor uint64
+		for {
+			batch, next, err := node.Scan(ctx, cursor, r.customerKey(pattern), deleteBatchSize).Result()
+			if err != nil {
+				return err
+			}
+			for _, key := range batch {
+				// Skip sub-keys such as customer:{id}:payments
+				if !strings.Contains(strings.TrimPrefix(key, r.customerKey("")), ":") {
+					keys = append(keys, key)
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	}
+
+	// SCAN only walks the node it is sent to, so a cluster is scanned
+	// master by master
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, r.client)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan customer keys: %w", err)
+	}
+
+	var total int64
+	for start := 0; start < len(keys); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		deleted, err := r.deleteKeys(ctx, keys[start:end])
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// deleteBatchSize is the SCAN COUNT hint and pipeline size for bulk deletes
+const deleteBatchSize = 500
+
+func (r *RedisCustomerRepository) deleteKeys(ctx context.Context, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Del(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to delete customers: %w", err)
+	}
+
+	var deleted int64
+	for _, cmd := range cmds {
+		deleted += cmd.Val()
+	}
+	return deleted, nil
 }
@@ -0,0 +1,166 @@
+package redisrepository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedCachedCustomers(t *testing.T, repo *RedisCustomerRepository, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, repo.Save(context.Background(), &domain.Customer{ID: id, Status: domain.CustomerStatusActive}))
+	}
+}
+
+func TestRedisCustomerRepository_Delete_ReportsWhetherKeyExisted(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	repo := NewRedisCustomerRepository(client, time.Minute, "")
+	seedCachedCustomers(t, repo, "GIG00001")
+
+	existed, err := repo.Delete(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.True(t, existed)
+	assert.False(t, mr.Exists("customer:GIG00001"))
+
+	existed, err = repo.Delete(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.False(t, existed)
+}
+
+func TestRedisCustomerRepository_DeleteMany(t *testing.T) {
+	mr, client := newTestRedis(t)
+	repo := NewRedisCustomerRepository(client, time.Minute, "")
+	seedCachedCustomers(t, repo, "GIG00001", "GIG00002", "GIG00003")
+
+	deleted, err := repo.DeleteMany(context.Background(), []string{"GIG00001", "GIG00003", "GIG09999"})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), deleted)
+	assert.False(t, mr.Exists("customer:GIG00001"))
+	assert.True(t, mr.Exists("customer:GIG00002"))
+	assert.False(t, mr.Exists("customer:GIG00003"))
+}
+
+func TestRedisCustomerRepository_DeleteByPattern(t *testing.T) {
+	mr, client := newTestRedis(t)
+	repo := NewRedisCustomerRepository(client, time.Minute, "")
+
+	for i := 0; i < 1200; i++ {
+		seedCachedCustomers(t, repo, fmt.Sprintf("GIG1%04d", i))
+	}
+	seedCachedCustomers(t, repo, "GIG20001")
+	mr.Set("payment:GIG10001", "unrelated")
+
+	deleted, err := repo.DeleteByPattern(context.Background(), "GIG1*")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1200), deleted)
+	assert.True(t, mr.Exists("customer:GIG20001"))
+	assert.True(t, mr.Exists("payment:GIG10001"))
+}
+
+func TestRedisRepositories_PrefixKeysConsistently(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	customers := NewRedisCustomerRepository(client, time.Minute, "staging")
+	payments := NewRedisPaymentRepository(client, time.Hour, "staging", domain.DedupScopeGlobal)
+
+	seedCachedCustomers(t, customers, "GIG00001")
+	require.NoError(t, payments.Save(ctx, &domain.Payment{CustomerID: "GIG00001", TransactionReference: "TXN001"}))
+
+	assert.ElementsMatch(t, []string{
+		"staging:customer:GIG00001",
+		"staging:customer:GIG00001:payments",
+		"staging:payment:TXN001",
+	}, mr.Keys())
+
+	_, err := customers.FindByID(ctx, "GIG00001")
+	assert.NoError(t, err)
+	exists, err := payments.ExistsByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// An unprefixed repository lives in a different namespace
+	_, err = NewRedisCustomerRepository(client, time.Minute, "").FindByID(ctx, "GIG00001")
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
+}
+
+func TestRedisCustomerRepository_DeleteByPattern_SkipsSubKeys(t *testing.T) {
+	mr, client := newTestRedis(t)
+	customers := NewRedisCustomerRepository(client, time.Minute, "staging")
+	payments := NewRedisPaymentRepository(client, time.Hour, "staging", domain.DedupScopeGlobal)
+
+	seedCachedCustomers(t, customers, "GIG00001")
+	require.NoError(t, payments.Save(context.Background(), &domain.Payment{CustomerID: "GIG00001", TransactionReference: "TXN001"}))
+
+	deleted, err := customers.DeleteByPattern(context.Background(), "GIG*")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), deleted)
+	assert.False(t, mr.Exists("staging:customer:GIG00001"))
+	assert.True(t, mr.Exists("staging:customer:GIG00001:payments"))
+}
+
+func TestRedisCustomerRepository_FindByIDs_SkipsMissingAndCorrupt(t *testing.T) {
+	mr, client := newTestRedis(t)
+	repo := NewRedisCustomerRepository(client, time.Minute, "")
+	seedCachedCustomers(t, repo, "GIG00001", "GIG00003")
+	require.NoError(t, mr.Set("customer:GIG00004", "{not json"))
+
+	customers, err := repo.FindByIDs(context.Background(), []string{"GIG00001", "GIG00002", "GIG00003", "GIG00004"})
+	require.NoError(t, err)
+
+	require.Len(t, customers, 2)
+	assert.Equal(t, "GIG00001", customers["GIG00001"].ID)
+	assert.Equal(t, "GIG00003", customers["GIG00003"].ID)
+}
+
+func TestRedisCustomerRepository_SaveMany(t *testing.T) {
+	mr, client := newTestRedis(t)
+	repo := NewRedisCustomerRepository(client, time.Minute, "")
+
+	require.NoError(t, repo.SaveMany(context.Background(), []*domain.Customer{{ID: "GIG00001"}, {ID: "GIG00002"}}))
+
+	assert.True(t, mr.Exists("customer:GIG00001"))
+	assert.True(t, mr.Exists("customer:GIG00002"))
+	assert.Equal(t, time.Minute, mr.TTL("customer:GIG00002"))
+}
+
+func TestRedisCustomerRepository_SaveIfNewer_KeepsFresherEntry(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	repo := NewRedisCustomerRepository(client, time.Minute, "")
+
+	written, err := repo.SaveIfNewer(ctx, &domain.Customer{ID: "GIG00001", OutstandingBalance: 900, Version: 2})
+	require.NoError(t, err)
+	assert.True(t, written, "nothing cached yet")
+	assert.Equal(t, time.Minute, mr.TTL("customer:GIG00001"))
+
+	for _, version := range []int64{1, 2} {
+		written, err = repo.SaveIfNewer(ctx, &domain.Customer{ID: "GIG00001", OutstandingBalance: 1000, Version: version})
+		require.NoError(t, err)
+		assert.False(t, written, "version %d", version)
+	}
+	cached, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(900), cached.OutstandingBalance)
+
+	written, err = repo.SaveIfNewer(ctx, &domain.Customer{ID: "GIG00001", OutstandingBalance: 800, Version: 3})
+	require.NoError(t, err)
+	assert.True(t, written)
+	cached, err = repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(800), cached.OutstandingBalance)
+
+	require.NoError(t, mr.Set("customer:GIG00002", "not json"))
+	written, err = repo.SaveIfNewer(ctx, &domain.Customer{ID: "GIG00002", Version: 1})
+	require.NoError(t, err)
+	assert.True(t, written, "a corrupt entry is replaced")
+}
@@ -0,0 +1,184 @@
+package redisrepository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// featureFlagsKey is one hash of every flag, field name to JSON, so a
+// replica refreshes them all in one round-trip
+const featureFlagsKey = "feature_flags"
+
+// RedisFeatureFlags keeps feature flags in Redis so every replica sees the
+// same rollout. Enabled answers from a local copy of all flags, re-read at
+// most once per refresh interval, so a change takes up to that long to
+// reach every replica. Get, List, Set and Delete go to Redis.
+type RedisFeatureFlags struct {
+	client  redis.UniversalClient
+	key     string
+	refresh time.Duration
+	logger  *zap.Logger
+	now     func() time.Time
+
+	mu         sync.Mutex
+	flags      map[string]*domain.FeatureFlag
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+func NewRedisFeatureFlags(client redis.UniversalClient, keys keyspace.Prefix, refresh time.Duration, logger *zap.Logger) *RedisFeatureFlags {
+	return &RedisFeatureFlags{
+		client:  client,
+		key:     keys.Key(featureFlagsKey),
+		refresh: refresh,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Enabled reports whether the flag applies to the customer, or fallback if
+// the flag isn't set. Only one caller refreshes the local copy at a time;
+// the rest use the copy they have. If Redis can't be read the last known
+// flags stand.
+func (f *RedisFeatureFlags) Enabled(ctx context.Context, name, customerID string, fallback bool) bool {
+	f.mu.Lock()
+	stale := !f.refreshing && f.now().Sub(f.fetchedAt) >= f.refresh
+	if stale {
+		f.refreshing = true
+	}
+	flags := f.flags
+	f.mu.Unlock()
+
+	if stale {
+		fetched, err := f.fetchAll(ctx)
+
+		f.mu.Lock()
+		f.refreshing = false
+		// Failures wait out the interval too, so a Redis outage costs one
+		// lookup per interval rather than one per call
+		f.fetchedAt = f.now()
+		if err != nil {
+			f.logger.Warn("failed to read feature flags, keeping last known flags", zap.Error(err))
+		} else {
+			f.flags = fetched
+			flags = fetched
+		}
+		f.mu.Unlock()
+	}
+
+	flag, ok := flags[name]
+	if !ok {
+		return fallback
+	}
+	return flag.EnabledFor(customerID)
+}
+
+func (f *RedisFeatureFlags) Get(ctx context.Context, name string) (*domain.FeatureFlag, error) {
+	data, err := f.client.HGet(ctx, f.key, name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, domain.ErrFeatureFlagNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feature flag: %w", err)
+	}
+	return decodeFeatureFlag(name, data)
+}
+
+// List returns every flag, ordered by name
+func (f *RedisFeatureFlags) List(ctx context.Context) ([]*domain.FeatureFlag, error) {
+	flags, err := f.fetchAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f.store(flags)
+
+	list := make([]*domain.FeatureFlag, 0, len(flags))
+	for _, flag := range flags {
+		list = append(list, flag)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Set creates or replaces the flag, stamping UpdatedAt
+func (f *RedisFeatureFlags) Set(ctx context.Context, flag *domain.FeatureFlag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+	flag.UpdatedAt = f.now().UTC()
+	data, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	if err := f.client.HSet(ctx, f.key, flag.Name, data).Err(); err != nil {
+		return fmt.Errorf("failed to set feature flag: %w", err)
+	}
+	f.invalidate()
+	return nil
+}
+
+// Delete removes the flag, so callers get their fallback again
+func (f *RedisFeatureFlags) Delete(ctx context.Context, name string) error {
+	removed, err := f.client.HDel(ctx, f.key, name).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+	if removed == 0 {
+		return domain.ErrFeatureFlagNotFound
+	}
+	f.invalidate()
+	return nil
+}
+
+func (f *RedisFeatureFlags) fetchAll(ctx context.Context) (map[string]*domain.FeatureFlag, error) {
+	fields, err := f.client.HGetAll(ctx, f.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature flags: %w", err)
+	}
+
+	flags := make(map[string]*domain.FeatureFlag, len(fields))
+	for name, data := range fields {
+		flag, err := decodeFeatureFlag(name, []byte(data))
+		if err != nil {
+			// One bad field shouldn't take the other flags down with it
+			f.logger.Warn("ignoring unreadable feature flag", zap.String("flag", name), zap.Error(err))
+			continue
+		}
+		flags[name] = flag
+	}
+	return flags, nil
+}
+
+func decodeFeatureFlag(name string, data []byte) (*domain.FeatureFlag, error) {
+	var flag domain.FeatureFlag
+	if err := json.Unmarshal(data, &flag); err != nil {
+		return nil, fmt.Errorf("failed to decode feature flag %s: %w", name, err)
+	}
+	flag.Name = name
+	return &flag, nil
+}
+
+func (f *RedisFeatureFlags) store(flags map[string]*domain.FeatureFlag) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flags = flags
+	f.fetchedAt = f.now()
+}
+
+// invalidate makes this replica's next Enabled re-read Redis, so a change
+// made here applies here straight away
+func (f *RedisFeatureFlags) invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetchedAt = time.Time{}
+}
@@ -0,0 +1,85 @@
+package redisrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRedisFeatureFlags_SetGetListDelete(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	flags := NewRedisFeatureFlags(client, "staging", time.Second, zap.NewNop())
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	flags.now = func() time.Time { return now }
+
+	require.NoError(t, flags.Set(ctx, &domain.FeatureFlag{Name: "velocity_check", Enabled: true, RolloutPercent: 25}))
+	require.NoError(t, flags.Set(ctx, &domain.FeatureFlag{Name: "atomic_apply", Enabled: false, RolloutPercent: 100}))
+	assert.True(t, mr.Exists("staging:feature_flags"))
+
+	flag, err := flags.Get(ctx, "velocity_check")
+	require.NoError(t, err)
+	assert.True(t, flag.Enabled)
+	assert.Equal(t, 25, flag.RolloutPercent)
+	assert.Equal(t, now, flag.UpdatedAt)
+
+	list, err := flags.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "atomic_apply", list[0].Name)
+	assert.Equal(t, "velocity_check", list[1].Name)
+
+	require.NoError(t, flags.Delete(ctx, "velocity_check"))
+	_, err = flags.Get(ctx, "velocity_check")
+	assert.ErrorIs(t, err, domain.ErrFeatureFlagNotFound)
+	assert.ErrorIs(t, flags.Delete(ctx, "velocity_check"), domain.ErrFeatureFlagNotFound)
+
+	assert.ErrorIs(t, flags.Set(ctx, &domain.FeatureFlag{Name: "atomic_apply", Enabled: true, RolloutPercent: 150}), domain.ErrInvalidFeatureFlag)
+}
+
+func TestRedisFeatureFlags_EnabledUsesLocalCopyUntilRefresh(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	flags := NewRedisFeatureFlags(client, "", 5*time.Second, zap.NewNop())
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	flags.now = func() time.Time { return now }
+
+	// Unset flags answer the fallback
+	assert.True(t, flags.Enabled(ctx, "sync_event_publishing", "GIG00001", true))
+	assert.False(t, flags.Enabled(ctx, "sync_event_publishing", "GIG00001", false))
+
+	// Another replica switches the flag off; this one sees it after the refresh interval
+	other := NewRedisFeatureFlags(client, "", 5*time.Second, zap.NewNop())
+	require.NoError(t, other.Set(ctx, &domain.FeatureFlag{Name: "sync_event_publishing", Enabled: false, RolloutPercent: 100}))
+	assert.True(t, flags.Enabled(ctx, "sync_event_publishing", "GIG00001", true))
+	now = now.Add(5 * time.Second)
+	assert.False(t, flags.Enabled(ctx, "sync_event_publishing", "GIG00001", true))
+
+	// A change made through this replica applies here at once
+	require.NoError(t, flags.Set(ctx, &domain.FeatureFlag{Name: "sync_event_publishing", Enabled: true, RolloutPercent: 100}))
+	assert.True(t, flags.Enabled(ctx, "sync_event_publishing", "GIG00001", false))
+
+	// With Redis gone the last known flags stand
+	mr.Close()
+	now = now.Add(time.Minute)
+	assert.True(t, flags.Enabled(ctx, "sync_event_publishing", "GIG00001", false))
+}
+
+func TestRedisFeatureFlags_PercentageRollout(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	flags := NewRedisFeatureFlags(client, "", time.Second, zap.NewNop())
+	flag := &domain.FeatureFlag{Name: "atomic_apply", Enabled: true, RolloutPercent: 30}
+	require.NoError(t, flags.Set(ctx, flag))
+
+	for _, id := range []string{"GIG00001", "GIG00002", "GIG00003", "GIG00004", "GIG00005"} {
+		want := domain.RolloutBucket("atomic_apply", id) < 30
+		assert.Equal(t, want, flags.Enabled(ctx, "atomic_apply", id, false), id)
+		assert.Equal(t, want, flags.Enabled(ctx, "atomic_apply", id, true), "%s: a set flag ignores the fallback", id)
+	}
+}
@@ -0,0 +1,61 @@
+package redisrepository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisPaymentOutcomeLog keeps one sorted set per status, scored by the
+// millisecond each outcome was recorded. Counting any range is a single
+// ZCOUNT whatever timezone the caller reports in; entries older than the
+// retention are trimmed as new ones arrive.
+type RedisPaymentOutcomeLog struct {
+	client    redis.UniversalClient
+	retention time.Duration
+	keys      keyspace.Prefix
+	now       func() time.Time
+}
+
+func NewRedisPaymentOutcomeLog(client redis.UniversalClient, retention time.Duration, keys keyspace.Prefix) *RedisPaymentOutcomeLog {
+	return &RedisPaymentOutcomeLog{
+		client:    client,
+		retention: retention,
+		keys:      keys,
+		now:       time.Now,
+	}
+}
+
+func (l *RedisPaymentOutcomeLog) Record(ctx context.Context, status domain.PaymentStatus, txRef string, at time.Time) error {
+	key := l.key(status)
+	// The timestamp keeps repeated deliveries of one reference distinct
+	member := fmt.Sprintf("%s:%d", txRef, at.UnixNano())
+
+	pipe := l.client.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(at.UnixMilli()), Member: member})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(l.now().Add(-l.retention).UnixMilli(), 10))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record payment outcome: %w", err)
+	}
+	return nil
+}
+
+func (l *RedisPaymentOutcomeLog) Count(ctx context.Context, status domain.PaymentStatus, from, to time.Time) (int64, error) {
+	count, err := l.client.ZCount(ctx, l.key(status),
+		strconv.FormatInt(from.UnixMilli(), 10),
+		"("+strconv.FormatInt(to.UnixMilli(), 10),
+	).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count payment outcomes: %w", err)
+	}
+	return count, nil
+}
+
+func (l *RedisPaymentOutcomeLog) key(status domain.PaymentStatus) string {
+	return l.keys.Key("outcomes:" + string(status))
+}
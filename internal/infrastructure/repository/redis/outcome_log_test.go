@@ -0,0 +1,59 @@
+package redisrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisPaymentOutcomeLog_CountsHalfOpenRangePerStatus(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	log := NewRedisPaymentOutcomeLog(client, 48*time.Hour, "staging")
+
+	dayStart := time.Date(2025, 11, 24, 0, 0, 0, 0, time.FixedZone("WAT", 60*60))
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	log.now = func() time.Time { return dayEnd }
+
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusDuplicate, "TXN-1", dayStart.Add(-time.Millisecond)))
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusDuplicate, "TXN-1", dayStart))
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusDuplicate, "TXN-1", dayStart.Add(time.Hour)))
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusFailed, "TXN-2", dayEnd.Add(-time.Millisecond)))
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusFailed, "TXN-3", dayEnd))
+
+	duplicates, err := log.Count(ctx, domain.PaymentStatusDuplicate, dayStart, dayEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), duplicates, "repeats of one reference each count")
+
+	failed, err := log.Count(ctx, domain.PaymentStatusFailed, dayStart, dayEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+
+	none, err := log.Count(ctx, domain.PaymentStatusFailed, dayEnd.AddDate(0, 0, 1), dayEnd.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Zero(t, none)
+
+	assert.True(t, mr.Exists("staging:outcomes:DUPLICATE"))
+}
+
+func TestRedisPaymentOutcomeLog_TrimsPastRetention(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	log := NewRedisPaymentOutcomeLog(client, 24*time.Hour, "")
+
+	old := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+	log.now = func() time.Time { return old }
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusFailed, "TXN-OLD", old))
+
+	now := old.Add(25 * time.Hour)
+	log.now = func() time.Time { return now }
+	require.NoError(t, log.Record(ctx, domain.PaymentStatusFailed, "TXN-NEW", now))
+
+	count, err := log.Count(ctx, domain.PaymentStatusFailed, old.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,183 @@
+package redisrepository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// ErrClusterUnsupported is returned by NewRedisPaymentApplier for a cluster
+// client: the script touches the payment and customer keys together, and
+// they hash to different slots
+var ErrClusterUnsupported = errors.New("atomic payment apply needs a single-node or sentinel Redis")
+
+// applyPaymentScript records the payment under its reference, with the
+// balance it leaves in BalanceAfter and the customer's currency, and applies
+// it to the customer, or does nothing if the reference is already recorded.
+// Customers saved without a currency are in ARGV[7], the default.
+// ARGV[8..10] are the overpayment limit: enabled (1 or 0), percent, amount.
+// cjson re-encodes numbers with 14 significant digits, which covers any
+// balance in kobo this service will see.
+var applyPaymentScript = redis.NewScript(`
+	local customer_key = KEYS[1]
+	local payment_key = KEYS[2]
+	local list_key = KEYS[3]
+	local amount = tonumber(ARGV[2])
+	local minimum = tonumber(ARGV[3])
+	local dedup_ttl = tonumber(ARGV[4])
+	local overpay_enabled = ARGV[8] == '1'
+	local overpay_percent = tonumber(ARGV[9])
+	local overpay_amount = tonumber(ARGV[10])
+
+	if redis.call('EXISTS', payment_key) == 1 then
+		return {'DUPLICATE'}
+	end
+
+	local data = redis.call('GET', customer_key)
+	if not data then
+		return redis.error_reply('customer not found')
+	end
+
+	local customer = cjson.decode(data)
+	local previous = customer.Status
+	local payment = cjson.decode(ARGV[1])
+
+	local currency = customer.CurrencyCode
+	if type(currency) ~= 'string' or currency == '' then
+		currency = ARGV[7]
+	end
+	if type(payment.CurrencyCode) == 'string' and payment.CurrencyCode ~= '' and payment.CurrencyCode ~= currency then
+		return redis.error_reply('currency mismatch')
+	end
+
+	if previous == 'COMPLETED' then
+		return redis.error_reply('asset already owned')
+	end
+	if previous == 'WRITTEN_OFF' then
+		return redis.error_reply('loan written off')
+	end
+	if minimum > 0 and amount < minimum and amount < customer.OutstandingBalance then
+		return redis.error_reply('below minimum payment')
+	end
+	if overpay_enabled and customer.OutstandingBalance > 0 then
+		local allowed = math.max(math.floor(customer.OutstandingBalance * overpay_percent / 100), overpay_amount)
+		if amount - customer.OutstandingBalance > allowed then
+			return redis.error_reply('excessive overpayment')
+		end
+	end
+
+	customer.OutstandingBalance = math.max(customer.OutstandingBalance - amount, 0)
+	customer.TotalPaid = customer.TotalPaid + amount
+	customer.LastPaymentDate = ARGV[5]
+	customer.Version = customer.Version + 1
+	if customer.OutstandingBalance == 0 then
+		customer.Status = 'COMPLETED'
+	end
+
+	local encoded = cjson.encode(customer)
+	local ttl = redis.call('PTTL', customer_key)
+	if ttl > 0 then
+		redis.call('SET', customer_key, encoded, 'PX', ttl)
+	else
+		redis.call('SET', customer_key, encoded)
+	end
+
+	payment.BalanceAfter = customer.OutstandingBalance
+	payment.CurrencyCode = currency
+	local recorded = cjson.encode(payment)
+	if dedup_ttl > 0 then
+		redis.call('SET', payment_key, recorded, 'PX', dedup_ttl)
+	else
+		redis.call('SET', payment_key, recorded)
+	end
+	redis.call('RPUSH', list_key, ARGV[6])
+
+	return {'APPLIED', previous, encoded}
+`)
+
+// applyPaymentErrors maps the script's error replies to domain errors
+var applyPaymentErrors = map[string]error{
+	"customer not found":    domain.ErrCustomerNotFound,
+	"asset already owned":   domain.ErrAssetAlreadyOwned,
+	"loan written off":      domain.ErrLoanWrittenOff,
+	"below minimum payment": domain.ErrBelowMinimumPayment,
+	"excessive overpayment": domain.ErrExcessiveOverpayment,
+	"currency mismatch":     domain.ErrCurrencyMismatch,
+}
+
+// RedisPaymentApplier applies payments for deployments that keep customers
+// and payments in Redis, in one Lua script instead of a read, apply and
+// versioned save. Payments are stored the way RedisPaymentRepository stores
+// them and customers the way RedisCustomerRepository does.
+type RedisPaymentApplier struct {
+	client    redis.UniversalClient
+	customers *RedisCustomerRepository
+	payments  *RedisPaymentRepository
+}
+
+func NewRedisPaymentApplier(client redis.UniversalClient, dedupTTL time.Duration, keys keyspace.Prefix, scope domain.DedupScope) (*RedisPaymentApplier, error) {
+	if _, ok := client.(*redis.ClusterClient); ok {
+		return nil, ErrClusterUnsupported
+	}
+	return &RedisPaymentApplier{
+		client:    client,
+		customers: NewRedisCustomerRepository(client, 0, keys),
+		payments:  NewRedisPaymentRepository(client, dedupTTL, keys, scope),
+	}, nil
+}
+
+func (a *RedisPaymentApplier) ApplyPayment(ctx context.Context, payment *domain.Payment, limits domain.PaymentLimits) (*domain.Customer, domain.CustomerStatus, error) {
+	if payment.ID == "" {
+		payment.ID = uuid.New().String()
+	}
+	data, err := json.Marshal(payment)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal payment: %w", err)
+	}
+
+	keys := []string{
+		a.customers.customerKey(payment.CustomerID),
+		a.payments.paymentKey(payment.CustomerID, payment.TransactionReference),
+		a.payments.customerPaymentsKey(payment.CustomerID),
+	}
+	result, err := applyPaymentScript.Run(ctx, a.client, keys,
+		data,
+		payment.Amount,
+		limits.Minimum,
+		a.payments.dedupTTL.Milliseconds(),
+		payment.TransactionDate.Format(time.RFC3339Nano),
+		payment.TransactionReference,
+		domain.DefaultCurrency.Code,
+		limits.Overpayment.Enabled,
+		limits.Overpayment.Percent,
+		limits.Overpayment.Amount,
+	).StringSlice()
+	if err != nil {
+		if mapped, ok := applyPaymentErrors[err.Error()]; ok {
+			return nil, "", mapped
+		}
+		return nil, "", fmt.Errorf("failed to apply payment: %w", err)
+	}
+
+	switch {
+	case len(result) == 1 && result[0] == "DUPLICATE":
+		return nil, "", domain.ErrDuplicateTransaction
+	case len(result) != 3 || result[0] != "APPLIED":
+		return nil, "", fmt.Errorf("unexpected result from payment apply: %v", result)
+	}
+
+	var customer domain.Customer
+	if err := json.Unmarshal([]byte(result[2]), &customer); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal customer: %w", err)
+	}
+	payment.CurrencyCode = customer.Currency().Code
+	payment.RecordBalanceAfter(customer.OutstandingBalance)
+	return &customer, domain.CustomerStatus(result[1]), nil
+}
@@ -0,0 +1,261 @@
+package redisrepository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApplier(t *testing.T, customer *domain.Customer) (*RedisPaymentApplier, *RedisCustomerRepository, *RedisPaymentRepository, *miniredis.Miniredis) {
+	mr, client := newTestRedis(t)
+	customers := NewRedisCustomerRepository(client, 0, "")
+	require.NoError(t, customers.Save(context.Background(), customer))
+
+	applier, err := NewRedisPaymentApplier(client, time.Hour, "", domain.DedupScopeGlobal)
+	require.NoError(t, err)
+	return applier, customers, NewRedisPaymentRepository(client, time.Hour, "", domain.DedupScopeGlobal), mr
+}
+
+func newAppliedPayment(customerID, txRef string, amount int64) *domain.Payment {
+	return &domain.Payment{
+		ID:                   "payment-" + txRef,
+		CustomerID:           customerID,
+		Amount:               amount,
+		TransactionReference: txRef,
+		TransactionDate:      time.Date(2025, 11, 24, 14, 0, 0, 0, time.UTC),
+		Status:               domain.PaymentStatusComplete,
+	}
+}
+
+func TestRedisPaymentApplier_AppliesAndRecordsPayment(t *testing.T) {
+	ctx := context.Background()
+	applier, customers, payments, mr := newTestApplier(t, &domain.Customer{
+		ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusDefaulted, Version: 3,
+	})
+
+	customer, previous, err := applier.ApplyPayment(ctx, newAppliedPayment("GIG00001", "TXN001", 25000000), domain.PaymentLimits{})
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.CustomerStatusDefaulted, previous)
+	assert.Equal(t, int64(75000000), customer.OutstandingBalance)
+	assert.Equal(t, int64(25000000), customer.TotalPaid)
+	assert.Equal(t, int64(4), customer.Version)
+	require.NotNil(t, customer.LastPaymentDate)
+	assert.True(t, customer.LastPaymentDate.Equal(time.Date(2025, 11, 24, 14, 0, 0, 0, time.UTC)))
+
+	stored, err := customers.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, customer, stored)
+
+	payment, err := payments.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(25000000), payment.Amount)
+	require.NotNil(t, payment.BalanceAfter, "the recorded payment carries the balance it left")
+	assert.Equal(t, int64(75000000), *payment.BalanceAfter)
+	list, err := mr.List("customer:GIG00001:payments")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TXN001"}, list)
+}
+
+func TestRedisPaymentApplier_DuplicateChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	applier, customers, _, _ := newTestApplier(t, &domain.Customer{
+		ID: "GIG00002", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1,
+	})
+
+	_, _, err := applier.ApplyPayment(ctx, newAppliedPayment("GIG00002", "TXN002", 1000000), domain.PaymentLimits{})
+	require.NoError(t, err)
+	_, _, err = applier.ApplyPayment(ctx, newAppliedPayment("GIG00002", "TXN002", 1000000), domain.PaymentLimits{})
+
+	assert.ErrorIs(t, err, domain.ErrDuplicateTransaction)
+	stored, err := customers.FindByID(ctx, "GIG00002")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000000), stored.TotalPaid)
+}
+
+func TestRedisPaymentApplier_Rejections(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  domain.CustomerStatus
+		balance int64
+		amount  int64
+		minimum int64
+		want    error
+	}{
+		{"written off", domain.CustomerStatusWrittenOff, 0, 1000, 0, domain.ErrLoanWrittenOff},
+		{"fully paid", domain.CustomerStatusCompleted, 0, 1000, 0, domain.ErrAssetAlreadyOwned},
+		{"below minimum", domain.CustomerStatusActive, 100000, 1000, 5000, domain.ErrBelowMinimumPayment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			applier, _, payments, _ := newTestApplier(t, &domain.Customer{
+				ID: "GIG00003", AssetValue: 100000, OutstandingBalance: tt.balance, TotalPaid: 100000 - tt.balance, Status: tt.status, Version: 1,
+			})
+
+			_, _, err := applier.ApplyPayment(ctx, newAppliedPayment("GIG00003", "TXN003", tt.amount), domain.PaymentLimits{Minimum: tt.minimum})
+
+			assert.ErrorIs(t, err, tt.want)
+			exists, err := payments.ExistsByTransactionReference(ctx, "GIG00003", "TXN003")
+			require.NoError(t, err)
+			assert.False(t, exists, "a rejected payment leaves its reference free")
+		})
+	}
+
+	t.Run("small payment settling the balance passes the minimum", func(t *testing.T) {
+		applier, _, _, _ := newTestApplier(t, &domain.Customer{
+			ID: "GIG00003", AssetValue: 100000, OutstandingBalance: 1000, TotalPaid: 99000, Status: domain.CustomerStatusActive, Version: 1,
+		})
+
+		customer, _, err := applier.ApplyPayment(context.Background(), newAppliedPayment("GIG00003", "TXN003", 1000), domain.PaymentLimits{Minimum: 5000})
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.CustomerStatusCompleted, customer.Status)
+		assert.Zero(t, customer.OutstandingBalance)
+	})
+
+	t.Run("overpayment limit", func(t *testing.T) {
+		limits := domain.PaymentLimits{Overpayment: domain.OverpaymentLimit{Enabled: true, Percent: 10}}
+		for amount, want := range map[int64]error{
+			10000:  nil, // exact payoff
+			11000:  nil, // 10% over
+			11001:  domain.ErrExcessiveOverpayment,
+			100000: domain.ErrExcessiveOverpayment,
+		} {
+			ctx := context.Background()
+			applier, _, payments, _ := newTestApplier(t, &domain.Customer{
+				ID: "GIG00003", AssetValue: 100000, OutstandingBalance: 10000, TotalPaid: 90000, Status: domain.CustomerStatusActive, Version: 1,
+			})
+
+			customer, _, err := applier.ApplyPayment(ctx, newAppliedPayment("GIG00003", "TXN003", amount), limits)
+
+			exists, existsErr := payments.ExistsByTransactionReference(ctx, "GIG00003", "TXN003")
+			require.NoError(t, existsErr)
+			if want != nil {
+				assert.ErrorIs(t, err, want, amount)
+				assert.False(t, exists, amount)
+				continue
+			}
+			require.NoError(t, err, amount)
+			assert.Equal(t, domain.CustomerStatusCompleted, customer.Status, amount)
+			assert.True(t, exists, amount)
+		}
+	})
+
+	t.Run("payment in another currency", func(t *testing.T) {
+		ctx := context.Background()
+		applier, _, payments, _ := newTestApplier(t, &domain.Customer{
+			ID: "GIG00003", AssetValue: 100000, OutstandingBalance: 100000, Status: domain.CustomerStatusActive, Version: 1,
+		})
+		payment := newAppliedPayment("GIG00003", "TXN003", 1000)
+		payment.CurrencyCode = "UGX"
+
+		_, _, err := applier.ApplyPayment(ctx, payment, domain.PaymentLimits{})
+
+		assert.ErrorIs(t, err, domain.ErrCurrencyMismatch, "a customer saved without a currency is in the default")
+		exists, err := payments.ExistsByTransactionReference(ctx, "GIG00003", "TXN003")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("payment in the customer's currency", func(t *testing.T) {
+		applier, _, _, _ := newTestApplier(t, &domain.Customer{
+			ID: "GIG00003", AssetValue: 100000, OutstandingBalance: 100000, Status: domain.CustomerStatusActive, Version: 1, CurrencyCode: "UGX",
+		})
+		payment := newAppliedPayment("GIG00003", "TXN003", 1000)
+		payment.CurrencyCode = "UGX"
+
+		customer, _, err := applier.ApplyPayment(context.Background(), payment, domain.PaymentLimits{})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(99000), customer.OutstandingBalance)
+		assert.Equal(t, "UGX", customer.CurrencyCode)
+		assert.Equal(t, "UGX", payment.CurrencyCode)
+	})
+
+	t.Run("unknown customer", func(t *testing.T) {
+		applier, _, _, _ := newTestApplier(t, &domain.Customer{ID: "GIG00003", AssetValue: 100000, OutstandingBalance: 100000, Status: domain.CustomerStatusActive})
+
+		_, _, err := applier.ApplyPayment(context.Background(), newAppliedPayment("GIG09999", "TXN003", 1000), domain.PaymentLimits{})
+
+		assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	})
+}
+
+func TestRedisPaymentApplier_ConcurrentPaymentsAreNotLost(t *testing.T) {
+	ctx := context.Background()
+	applier, customers, _, mr := newTestApplier(t, &domain.Customer{
+		ID: "GIG00004", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1,
+	})
+
+	const workers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every reference is sent twice; only one of each may land
+			for attempt := 0; attempt < 2; attempt++ {
+				_, _, err := applier.ApplyPayment(ctx, newAppliedPayment("GIG00004", fmt.Sprintf("TXN%03d", i), 100000), domain.PaymentLimits{})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var duplicates int
+	for err := range errs {
+		require.ErrorIs(t, err, domain.ErrDuplicateTransaction)
+		duplicates++
+	}
+	assert.Equal(t, workers, duplicates)
+
+	customer, err := customers.FindByID(ctx, "GIG00004")
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers*100000), customer.TotalPaid)
+	assert.Equal(t, int64(100000000-workers*100000), customer.OutstandingBalance)
+	assert.Equal(t, int64(1+workers), customer.Version)
+	assert.NoError(t, customer.Validate())
+
+	list, err := mr.List("customer:GIG00004:payments")
+	require.NoError(t, err)
+	assert.Len(t, list, workers)
+}
+
+func TestRedisPaymentApplier_KeepsCustomerTTL(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	require.NoError(t, NewRedisCustomerRepository(client, time.Hour, "").Save(ctx, &domain.Customer{
+		ID: "GIG00005", AssetValue: 100000, OutstandingBalance: 100000, Status: domain.CustomerStatusActive, Version: 1,
+	}))
+	applier, err := NewRedisPaymentApplier(client, 0, "", domain.DedupScopeGlobal)
+	require.NoError(t, err)
+
+	_, _, err = applier.ApplyPayment(ctx, newAppliedPayment("GIG00005", "TXN005", 1000), domain.PaymentLimits{})
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, mr.TTL("customer:GIG00005"))
+	assert.Zero(t, mr.TTL("payment:TXN005"), "zero dedup TTL never expires")
+}
+
+func TestNewRedisPaymentApplier_RejectsCluster(t *testing.T) {
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:0"}})
+	t.Cleanup(func() { client.Close() })
+
+	_, err := NewRedisPaymentApplier(client, 0, "", domain.DedupScopeGlobal)
+
+	assert.ErrorIs(t, err, ErrClusterUnsupported)
+}
@@ -3,36 +3,45 @@ package redisrepository
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
 	"github.com/go-redis/redis/v8"
 )
 
 var (
-	ErrPaymentNotFound = errors.New("payment not found")
+	ErrPaymentNotFound = domain.ErrPaymentNotFound
 )
 
 type RedisPaymentRepository struct {
-	client *redis.Client
+	client redis.UniversalClient
+	// dedupTTL is how long a payment dedup key lives. Zero means no expiry.
+	dedupTTL time.Duration
+	keys     keyspace.Prefix
+	// scope decides whether dedup keys name the customer
+	scope domain.DedupScope
 }
 
-func NewRedisPaymentRepository(client *redis.Client) *RedisPaymentRepository {
+func NewRedisPaymentRepository(client redis.UniversalClient, dedupTTL time.Duration, keys keyspace.Prefix, scope domain.DedupScope) *RedisPaymentRepository {
 	return &RedisPaymentRepository{
-		client: client,
+		client:   client,
+		dedupTTL: dedupTTL,
+		keys:     keys,
+		scope:    scope,
 	}
 }
 
 func (r *RedisPaymentRepository) Save(ctx context.Context, payment *domain.Payment) error {
-	key := r.paymentKey(payment.TransactionReference)
+	key := r.paymentKey(payment.CustomerID, payment.TransactionReference)
 
 	data, err := json.Marshal(payment)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payment: %w", err)
 	}
 
-	wasSet, err := r.client.SetNX(ctx, key, data, 0).Result()
+	wasSet, err := r.client.SetNX(ctx, key, data, r.dedupTTL).Result()
 	if err != nil {
 		return fmt.Errorf("failed to save payment: %w", err)
 	}
@@ -49,8 +58,8 @@ func (r *RedisPaymentRepository) Save(ctx context.Context, payment *domain.Payme
 	return nil
 }
 
-func (r *RedisPaymentRepository) FindByTransactionReference(ctx context.Context, txRef string) (*domain.Payment, error) {
-	key := r.paymentKey(txRef)
+func (r *RedisPaymentRepository) FindByTransactionReference(ctx context.Context, customerID, txRef string) (*domain.Payment, error) {
+	key := r.paymentKey(customerID, txRef)
 
 	data, err := r.client.Get(ctx, key).Bytes()
 	if err != nil {
@@ -68,8 +77,8 @@ func (r *RedisPaymentRepository) FindByTransactionReference(ctx context.Context,
 	return &payment, nil
 }
 
-func (r *RedisPaymentRepository) ExistsByTransactionReference(ctx context.Context, txRef string) (bool, error) {
-	key := r.paymentKey(txRef)
+func (r *RedisPaymentRepository) ExistsByTransactionReference(ctx context.Context, customerID, txRef string) (bool, error) {
+	key := r.paymentKey(customerID, txRef)
 
 	exists, err := r.client.Exists(ctx, key).Result()
 	if err != nil {
@@ -79,10 +88,67 @@ func (r *RedisPaymentRepository) ExistsByTransactionReference(ctx context.Contex
 	return exists > 0, nil
 }
 
-func (r *RedisPaymentRepository) paymentKey(txRef string) string {
-	return fmt.Sprintf("payment:%s", txRef)
+// Restore recreates the dedup keys of recorded payments, each expiring
+// when it would have had Save written it at the payment's CreatedAt.
+// Payments whose key would already have expired are skipped, and keys that
+// exist are left alone. With lists, each payment whose key was restored is
+// also appended to its customer's payment list. It returns how many keys
+// were restored.
+func (r *RedisPaymentRepository) Restore(ctx context.Context, payments []*domain.Payment, now time.Time, lists bool) (int, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.BoolCmd, 0, len(payments))
+	restoring := make([]*domain.Payment, 0, len(payments))
+	for _, payment := range payments {
+		ttl := r.dedupTTL
+		if ttl > 0 {
+			ttl = payment.CreatedAt.Add(r.dedupTTL).Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+		data, err := json.Marshal(payment)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal payment: %w", err)
+		}
+		cmds = append(cmds, pipe.SetNX(ctx, r.paymentKey(payment.CustomerID, payment.TransactionReference), data, ttl))
+		restoring = append(restoring, payment)
+	}
+	if len(cmds) == 0 {
+		return 0, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to restore payments: %w", err)
+	}
+
+	restored := 0
+	pipe = r.client.Pipeline()
+	for i, cmd := range cmds {
+		if !cmd.Val() {
+			continue
+		}
+		restored++
+		if lists {
+			payment := restoring[i]
+			pipe.RPush(ctx, r.customerPaymentsKey(payment.CustomerID), payment.TransactionReference)
+		}
+	}
+	if lists && restored > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return restored, fmt.Errorf("failed to restore customer payment lists: %w", err)
+		}
+	}
+	return restored, nil
+}
+
+// paymentKey is payment:{ref}, or payment:{customer}:{ref} when references
+// are only unique per customer
+func (r *RedisPaymentRepository) paymentKey(customerID, txRef string) string {
+	if r.scope.PerCustomer() {
+		return r.keys.Key(fmt.Sprintf("payment:%s:%s", customerID, txRef))
+	}
+	return r.keys.Key(fmt.Sprintf("payment:%s", txRef))
 }
 
 func (r *RedisPaymentRepository) customerPaymentsKey(customerID string) string {
-	return fmt.Sprintf("customer:%s:payments", customerID)
+	return r.keys.Key(fmt.Sprintf("customer:%s:payments", customerID))
 }
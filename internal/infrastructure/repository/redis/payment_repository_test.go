@@ -0,0 +1,68 @@
+package redisrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestRedisPaymentRepository_Save_SetsDedupTTL(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+
+	ttl := 30 * 24 * time.Hour
+	repo := NewRedisPaymentRepository(client, ttl, "", domain.DedupScopeGlobal)
+
+	payment := &domain.Payment{
+		ID:                   "payment-1",
+		CustomerID:           "GIG00001",
+		Amount:               25000000,
+		TransactionReference: "TXN001",
+		TransactionDate:      time.Now(),
+		Status:               domain.PaymentStatusComplete,
+	}
+
+	require.NoError(t, repo.Save(ctx, payment))
+
+	assert.True(t, mr.Exists("payment:TXN001"))
+	assert.Equal(t, ttl, mr.TTL("payment:TXN001"))
+
+	// Once the window passes the key is gone and MySQL becomes the guard
+	mr.FastForward(ttl + time.Second)
+	exists, err := repo.ExistsByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRedisPaymentRepository_Save_ZeroTTLNeverExpires(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+
+	repo := NewRedisPaymentRepository(client, 0, "", domain.DedupScopeGlobal)
+
+	payment := &domain.Payment{
+		CustomerID:           "GIG00001",
+		Amount:               100,
+		TransactionReference: "TXN002",
+		Status:               domain.PaymentStatusComplete,
+	}
+
+	require.NoError(t, repo.Save(ctx, payment))
+	assert.Equal(t, time.Duration(0), mr.TTL("payment:TXN002"))
+
+	err := repo.Save(ctx, payment)
+	assert.ErrorIs(t, err, domain.ErrDuplicateTransaction)
+}
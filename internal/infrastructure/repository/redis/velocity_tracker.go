@@ -0,0 +1,53 @@
+package redisrepository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisVelocityTracker counts payments per customer in fixed windows, one
+// hash per customer and window. Fixed windows are cheap but a burst that
+// straddles a boundary is split across two counters.
+type RedisVelocityTracker struct {
+	client redis.UniversalClient
+	window time.Duration
+	keys   keyspace.Prefix
+	now    func() time.Time
+}
+
+func NewRedisVelocityTracker(client redis.UniversalClient, window time.Duration, keys keyspace.Prefix) *RedisVelocityTracker {
+	return &RedisVelocityTracker{
+		client: client,
+		window: window,
+		keys:   keys,
+		now:    time.Now,
+	}
+}
+
+func (t *RedisVelocityTracker) Record(ctx context.Context, customerID string, amount int64) (domain.PaymentVelocity, error) {
+	now := t.now()
+	start := now.Truncate(t.window)
+	key := t.keys.Key(fmt.Sprintf("velocity:%s:%d", customerID, start.Unix()))
+
+	pipe := t.client.TxPipeline()
+	count := pipe.HIncrBy(ctx, key, "count", 1)
+	total := pipe.HIncrBy(ctx, key, "total", amount)
+	// The window is over by then; keeping the key no longer than that
+	// bounds memory to the customers active in the last two windows
+	pipe.Expire(ctx, key, start.Add(2*t.window).Sub(now))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return domain.PaymentVelocity{}, fmt.Errorf("failed to record payment velocity: %w", err)
+	}
+
+	return domain.PaymentVelocity{
+		Count:       count.Val(),
+		TotalAmount: total.Val(),
+		WindowStart: start,
+		Window:      t.window,
+	}, nil
+}
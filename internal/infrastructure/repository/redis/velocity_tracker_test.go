@@ -0,0 +1,41 @@
+package redisrepository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisVelocityTracker_CountsPerCustomerAndWindow(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	tracker := NewRedisVelocityTracker(client, time.Hour, "staging")
+	now := time.Date(2025, 11, 24, 14, 10, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	_, err := tracker.Record(ctx, "GIG00001", 1000)
+	require.NoError(t, err)
+	v, err := tracker.Record(ctx, "GIG00001", 2500)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), v.Count)
+	assert.Equal(t, int64(3500), v.TotalAmount)
+	assert.Equal(t, time.Date(2025, 11, 24, 14, 0, 0, 0, time.UTC), v.WindowStart)
+	assert.Equal(t, time.Hour, v.Window)
+
+	other, err := tracker.Record(ctx, "GIG00002", 700)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), other.Count, "customers are counted separately")
+
+	// The next window starts from zero
+	now = now.Add(time.Hour)
+	v, err = tracker.Record(ctx, "GIG00001", 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v.Count)
+	assert.Equal(t, int64(100), v.TotalAmount)
+
+	assert.True(t, mr.Exists("staging:velocity:GIG00001:1763992800"))
+}
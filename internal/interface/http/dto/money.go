@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrencySymbol prefixes formatted amounts unless configured otherwise
+const DefaultCurrencySymbol = "₦"
+
+// FormatKobo renders an amount in kobo as naira with thousands separators
+// and two decimals, e.g. 25000000 with "₦" is "₦250,000.00"
+func FormatKobo(amount int64, symbol string) string {
+	return FormatMinor(amount, 2, symbol)
+}
+
+// FormatMinor renders an amount in a currency's minor unit, of which
+// 10^exponent make a major unit, with thousands separators and exponent
+// decimals: 25000000 at exponent 2 is "250,000.00", 250000 at exponent 0 is
+// "250,000". The digits are produced from the integer directly, so no float
+// rounding creeps in.
+func FormatMinor(amount int64, exponent int, symbol string) string {
+	negative := amount < 0
+	// Via uint64 so the smallest int64 negates without overflowing
+	minor := uint64(amount)
+	if negative {
+		minor = -minor
+	}
+
+	digits := strconv.FormatUint(minor, 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	major, fraction := digits[:len(digits)-exponent], digits[len(digits)-exponent:]
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	b.WriteString(symbol)
+	for i, digit := range major {
+		if i > 0 && (len(major)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if exponent > 0 {
+		b.WriteByte('.')
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
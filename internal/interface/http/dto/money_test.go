@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatKobo(t *testing.T) {
+	tests := []struct {
+		amount int64
+		symbol string
+		want   string
+	}{
+		{100000000, "₦", "₦1,000,000.00"},
+		{25000000, "₦", "₦250,000.00"},
+		{2010, "₦", "₦20.10"},
+		{2001, "₦", "₦20.01"},
+		{99, "₦", "₦0.99"},
+		{0, "₦", "₦0.00"},
+		{100000, "NGN ", "NGN 1,000.00"},
+		{123456, "", "1,234.56"},
+		{-2010, "₦", "-₦20.10"},
+		{math.MinInt64, "", "-92,233,720,368,547,758.08"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatKobo(tt.amount, tt.symbol), "amount %d", tt.amount)
+	}
+}
+
+func TestFormatMinor(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		exponent int
+		symbol   string
+		want     string
+	}{
+		{250000, 0, "USh", "USh250,000"},
+		{999, 0, "", "999"},
+		{-1500, 0, "CFA", "-CFA1,500"},
+		{0, 0, "", "0"},
+		{5, 3, "", "0.005"},
+		{1234567, 3, "", "1,234.567"},
+		{25000050, 2, "KSh", "KSh250,000.50"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatMinor(tt.amount, tt.exponent, tt.symbol), "amount %d at exponent %d", tt.amount, tt.exponent)
+	}
+}
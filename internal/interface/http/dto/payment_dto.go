@@ -1,76 +1,239 @@
 package dto
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
 )
 
 type PaymentRequest struct {
 	CustomerID           string `json:"customer_id"`
 	PaymentStatus        string `json:"payment_status"`
-	TransactionAmount    string `json:"transaction_amount"`
+	TransactionAmount    Amount `json:"transaction_amount"`
 	TransactionDate      string `json:"transaction_date"`
 	TransactionReference string `json:"transaction_reference"`
+	// Currency is the ISO 4217 code of TransactionAmount; when absent the
+	// payment is taken to be in the deployment's default currency
+	Currency string `json:"currency,omitempty"`
+	// Metadata is stored with the payment as sent, within the bounds
+	// domain.ValidatePaymentMetadata sets
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
-func (r *PaymentRequest) Validate() error {
-	if r.CustomerID == "" {
-		return errors.New("customer_id is required")
+// Amount is an amount in major units (naira, shillings) as its decimal
+// text. Providers send it either quoted ("250000.00") or as a bare JSON
+// number (250000.00); both decode to the same text, so the conversion to
+// minor units can't tell them apart.
+type Amount string
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	switch {
+	case string(data) == "null":
+		return nil
+	case data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*a = Amount(s)
+	case data[0] == '-' || (data[0] >= '0' && data[0] <= '9'):
+		// The decoder has already checked this is a well-formed number
+		*a = Amount(data)
+	case data[0] == '{':
+		return &json.UnmarshalTypeError{Value: "object", Type: reflect.TypeOf("")}
+	case data[0] == '[':
+		return &json.UnmarshalTypeError{Value: "array", Type: reflect.TypeOf("")}
+	default:
+		return &json.UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf("")}
+	}
+	return nil
+}
+
+// Normalize trims surrounding whitespace from every field so blank values
+// fail validation. Case and reference canonicalization happen in the service.
+func (r *PaymentRequest) Normalize() {
+	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.PaymentStatus = strings.TrimSpace(r.PaymentStatus)
+	r.TransactionAmount = Amount(strings.TrimSpace(string(r.TransactionAmount)))
+	r.TransactionDate = strings.TrimSpace(r.TransactionDate)
+	r.TransactionReference = strings.TrimSpace(r.TransactionReference)
+	r.Currency = strings.ToUpper(strings.TrimSpace(r.Currency))
+}
+
+// ValidationError reports every problem found in a request at once, keyed
+// by field, so a client can fix them all in one go
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) add(field, problem string) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
 	}
-	if r.PaymentStatus == "" {
-		return errors.New("payment_status is required")
+	e.Fields[field] = problem
+}
+
+// Error lists the problems by field name, e.g. "customer_id is required"
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	problems := make([]string, len(fields))
+	for i, field := range fields {
+		problems[i] = field + " " + e.Fields[field]
 	}
-	if r.TransactionAmount == "" {
-		return errors.New("transaction_amount is required")
+	return strings.Join(problems, "; ")
+}
+
+// Validate returns a *ValidationError listing every missing or malformed field
+func (r *PaymentRequest) Validate() error {
+	var verr ValidationError
+	required := func(field, value string) bool {
+		if value == "" {
+			verr.add(field, "is required")
+			return false
+		}
+		return true
 	}
-	if r.TransactionDate == "" {
-		return errors.New("transaction_date is required")
+
+	required("customer_id", r.CustomerID)
+	required("payment_status", r.PaymentStatus)
+	required("transaction_reference", r.TransactionReference)
+
+	if required("transaction_amount", string(r.TransactionAmount)) {
+		if _, err := strconv.ParseFloat(string(r.TransactionAmount), 64); err != nil {
+			verr.add("transaction_amount", "must be a valid number")
+		}
 	}
-	if r.TransactionReference == "" {
-		return errors.New("transaction_reference is required")
+
+	if required("transaction_date", r.TransactionDate) {
+		if _, err := time.Parse("2006-01-02 15:04:05", r.TransactionDate); err != nil {
+			verr.add("transaction_date", "must be in format 'YYYY-MM-DD HH:MM:SS'")
+		}
 	}
 
-	if _, err := strconv.ParseFloat(r.TransactionAmount, 64); err != nil {
-		return errors.New("transaction_amount must be a valid number")
+	if r.Currency != "" {
+		if _, err := domain.LookupCurrency(r.Currency); err != nil {
+			verr.add("currency", "is not a supported currency")
+		}
 	}
 
-	if _, err := time.Parse("2006-01-02 15:04:05", r.TransactionDate); err != nil {
-		return errors.New("transaction_date must be in format 'YYYY-MM-DD HH:MM:SS'")
+	if err := domain.ValidatePaymentMetadata(r.Metadata); err != nil {
+		verr.add("metadata", strings.TrimPrefix(err.Error(), domain.ErrInvalidMetadata.Error()+": "))
 	}
 
+	if len(verr.Fields) > 0 {
+		return &verr
+	}
 	return nil
 }
 
-func (r *PaymentRequest) GetAmountInKobo() (int64, error) {
-	amount, err := strconv.ParseFloat(r.TransactionAmount, 64)
-	if err != nil {
-		return 0, err
-	}
-	return int64(amount * 100), nil
+// GetAmount converts the amount to minor units of currency, rejecting one
+// more precise than the currency allows
+func (r *PaymentRequest) GetAmount(currency domain.Currency) (int64, error) {
+	return currency.ParseAmount(string(r.TransactionAmount))
+}
+
+// GetTransactionDate reads the wall-clock transaction_date as a time in
+// loc, so the instant doesn't depend on the server's zone; nil is UTC
+func (r *PaymentRequest) GetTransactionDate(loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", r.TransactionDate, locationOrUTC(loc))
 }
 
-func (r *PaymentRequest) GetTransactionDate() (time.Time, error) {
-	return time.Parse("2006-01-02 15:04:05", r.TransactionDate)
+func locationOrUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
 }
 
 type PaymentResponse struct {
-	Success            bool    `json:"success"`
+	// Outcome is PROCESSED, DUPLICATE, NOT_COMPLETE, ALREADY_PAID or PREVIEW; failed
+	// calls answer with an ErrorResponse whose outcome is FAILED
+	Outcome string `json:"outcome"`
+	Success bool   `json:"success"`
+	// Processed reports whether the payment has been applied; when false,
+	// Reason is a stable code such as STATUS_NOT_COMPLETE
+	Processed          bool    `json:"processed"`
+	Reason             string  `json:"reason,omitempty"`
 	Message            string  `json:"message"`
 	CustomerID         string  `json:"customer_id,omitempty"`
 	OutstandingBalance int64   `json:"outstanding_balance,omitempty"`
 	TotalPaid          int64   `json:"total_paid,omitempty"`
 	PaymentProgress    float64 `json:"payment_progress,omitempty"`
 	IsFullyPaid        bool    `json:"is_fully_paid,omitempty"`
+	// Preview is set for dry runs; nothing was persisted
+	Preview bool `json:"preview,omitempty"`
+	// Flagged marks a payment held for fraud review; it was still applied
+	Flagged     bool                 `json:"flagged"`
+	Installment *InstallmentResponse `json:"installment,omitempty"`
+	// PaymentID is the recorded payment, or for a duplicate the one
+	// recorded first; ProcessedAt is when it was applied. Both are left
+	// out when there is none.
+	PaymentID   string `json:"payment_id,omitempty"`
+	ProcessedAt string `json:"processed_at,omitempty"`
+}
+
+// InstallmentResponse compares a payment with the week's expected
+// installment; Standing is MET, EXCEEDED or SHORT
+type InstallmentResponse struct {
+	ExpectedAmount int64  `json:"expected_amount"`
+	Standing       string `json:"standing"`
+	Arrears        int64  `json:"arrears"`
+}
+
+// Error codes carried in ErrorResponse.Code
+const (
+	// ErrorCodeInternal marks unexpected server-side failures
+	ErrorCodeInternal = "INTERNAL_ERROR"
+	// ErrorCodeBadRequest marks input that could never be valid
+	ErrorCodeBadRequest = "BAD_REQUEST"
+	// ErrorCodeValidation marks a well-formed payment whose fields fail
+	// validation
+	ErrorCodeValidation = "VALIDATION_FAILED"
+	// ErrorCodeMaintenance marks writes refused while maintenance mode is on
+	ErrorCodeMaintenance = "MAINTENANCE"
+	// ErrorCodeIdempotencyInProgress marks a duplicate that gave up waiting
+	// for the first request with its Idempotency-Key
+	ErrorCodeIdempotencyInProgress = "IDEMPOTENCY_IN_PROGRESS"
+	// ErrorCodeIdempotencyKeyReused marks an Idempotency-Key sent again with
+	// a different request
+	ErrorCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+)
+
+// ErrorCodes lists every ErrorResponse.Code
+func ErrorCodes() []string {
+	return []string{ErrorCodeInternal, ErrorCodeBadRequest, ErrorCodeValidation, ErrorCodeMaintenance, ErrorCodeIdempotencyInProgress, ErrorCodeIdempotencyKeyReused}
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Details maps each invalid field to its problem, for validation failures
+	Details map[string]string `json:"details,omitempty"`
+	// Outcome is FAILED on errors from POST /payments and unset elsewhere
+	Outcome string `json:"outcome,omitempty"`
+	// Retryable says whether sending the same payment again may succeed,
+	// on errors from POST /payments only
+	Retryable *bool `json:"retryable,omitempty"`
 }
 
 type CustomerResponse struct {
 	CustomerID         string  `json:"customer_id"`
+	Currency           string  `json:"currency"`
 	AssetValue         int64   `json:"asset_value"`
 	RepaymentTermWeeks int     `json:"repayment_term_weeks"`
 	OutstandingBalance int64   `json:"outstanding_balance"`
@@ -78,14 +241,547 @@ type CustomerResponse struct {
 	PaymentProgress    float64 `json:"payment_progress"`
 	Status             string  `json:"status"`
 	IsFullyPaid        bool    `json:"is_fully_paid"`
+	// ExpectedWeeklyAmount and Arrears (as of now) come from the repayment schedule
+	ExpectedWeeklyAmount int64 `json:"expected_weekly_amount"`
+	Arrears              int64 `json:"arrears"`
+	// The amounts above are in Currency's minor unit (kobo for NGN); these
+	// render them in major units for display
+	AssetValueFormatted           string `json:"asset_value_formatted"`
+	OutstandingBalanceFormatted   string `json:"outstanding_balance_formatted"`
+	TotalPaidFormatted            string `json:"total_paid_formatted"`
+	ExpectedWeeklyAmountFormatted string `json:"expected_weekly_amount_formatted"`
+	ArrearsFormatted              string `json:"arrears_formatted"`
 }
 
-type PaymentRecordResponse struct {
-	ID                   string `json:"id"`
+// PayoffEstimateResponse is the weeks left and the date of the final payment
+type PayoffEstimateResponse struct {
+	WeeksRemaining int    `json:"weeks_remaining"`
+	PayoffDate     string `json:"payoff_date"`
+}
+
+// PayoffProjectionResponse answers GET /customers/{customer_id}/projection.
+// The estimates are left out once the customer has nothing left to pay, and
+// AtCurrentPace also when nothing was paid recently.
+type PayoffProjectionResponse struct {
 	CustomerID           string `json:"customer_id"`
-	TransactionAmount    int64  `json:"transaction_amount"`
+	Status               string `json:"status"`
+	Completed            bool   `json:"completed"`
+	OutstandingBalance   int64  `json:"outstanding_balance"`
+	TotalPaid            int64  `json:"total_paid"`
+	ExpectedWeeklyAmount int64  `json:"expected_weekly_amount"`
+	// WeeklyPace is the average paid per week over the last few weeks, in
+	// minor units
+	WeeklyPace    int64                   `json:"weekly_pace"`
+	OnSchedule    *PayoffEstimateResponse `json:"on_schedule,omitempty"`
+	AtCurrentPace *PayoffEstimateResponse `json:"at_current_pace,omitempty"`
+	AsOf          string                  `json:"as_of"`
+}
+
+// PayoffQuoteResponse answers GET /customers/{customer_id}/payoff-quote.
+// PayoffAmount is OutstandingBalance less Discount, in Currency's minor
+// unit; the formatted fields render them in major units. A completed loan
+// is quoted at zero with Message saying so.
+type PayoffQuoteResponse struct {
+	CustomerID          string `json:"customer_id"`
+	Status              string `json:"status"`
+	Currency            string `json:"currency"`
+	Completed           bool   `json:"completed"`
+	OutstandingBalance  int64  `json:"outstanding_balance"`
+	DiscountBasisPoints int64  `json:"discount_basis_points"`
+	Discount            int64  `json:"discount"`
+	PayoffAmount        int64  `json:"payoff_amount"`
+
+	OutstandingBalanceFormatted string `json:"outstanding_balance_formatted"`
+	DiscountFormatted           string `json:"discount_formatted"`
+	PayoffAmountFormatted       string `json:"payoff_amount_formatted"`
+
+	Message string `json:"message,omitempty"`
+	AsOf    string `json:"as_of"`
+}
+
+// WeeklyPaymentsResponse charts a customer's COMPLETE payments per week of
+// their repayment schedule, from deployment to the week in progress, with
+// no gaps. Amounts are in Currency's minor unit.
+type WeeklyPaymentsResponse struct {
+	CustomerID     string               `json:"customer_id"`
+	Currency       string               `json:"currency"`
+	DeploymentDate string               `json:"deployment_date"`
+	Weeks          []WeeklyPaymentEntry `json:"weeks"`
+	TotalCount     int64                `json:"total_count"`
+	TotalAmount    int64                `json:"total_amount"`
+	AsOf           string               `json:"as_of"`
+}
+
+// WeeklyPaymentEntry is one schedule week, Start inclusive to End
+// exclusive. ExpectedAmount is the installment due at its end; Partial
+// marks the week in progress.
+type WeeklyPaymentEntry struct {
+	Week            int    `json:"week"`
+	Start           string `json:"start"`
+	End             string `json:"end"`
+	Count           int64  `json:"count"`
+	Amount          int64  `json:"amount"`
+	AmountFormatted string `json:"amount_formatted"`
+	ExpectedAmount  int64  `json:"expected_amount"`
+	Partial         bool   `json:"partial"`
+}
+
+// BatchCustomersRequest lists the customers to fetch in one call
+type BatchCustomersRequest struct {
+	CustomerIDs []string `json:"customer_ids"`
+}
+
+// Validate checks the request against the configured maximum batch size
+func (r *BatchCustomersRequest) Validate(maxIDs int) error {
+	if len(r.CustomerIDs) == 0 {
+		return errors.New("customer_ids is required")
+	}
+	if len(r.CustomerIDs) > maxIDs {
+		return fmt.Errorf("at most %d customer_ids allowed", maxIDs)
+	}
+	for _, id := range r.CustomerIDs {
+		if strings.TrimSpace(id) == "" {
+			return errors.New("customer_ids must not contain empty values")
+		}
+	}
+	return nil
+}
+
+type BatchCustomersResponse struct {
+	Customers map[string]CustomerResponse `json:"customers"`
+	NotFound  []string                    `json:"not_found"`
+}
+
+// EnumsResponse lists the values each enumerated response field can take
+type EnumsResponse struct {
+	CustomerStatuses     []string `json:"customer_statuses"`
+	PaymentStatuses      []string `json:"payment_statuses"`
+	InstallmentStandings []string `json:"installment_standings"`
+	PaymentOutcomes      []string `json:"payment_outcomes"`
+	PaymentReasons       []string `json:"payment_reasons"`
+	ErrorCodes           []string `json:"error_codes"`
+}
+
+type DebugInfoResponse struct {
+	Version       string    `json:"version"`
+	Commit        string    `json:"commit"`
+	BuildDate     string    `json:"build_date"`
+	GoVersion     string    `json:"go_version"`
+	Goroutines    int       `json:"goroutines"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+type PaymentRecordResponse struct {
+	ID                string `json:"id"`
+	CustomerID        string `json:"customer_id"`
+	TransactionAmount int64  `json:"transaction_amount"`
+	Currency          string `json:"currency"`
+	// AmountFormatted is TransactionAmount in major units, for display
+	AmountFormatted      string `json:"amount_formatted"`
 	TransactionReference string `json:"transaction_reference"`
 	TransactionDate      string `json:"transaction_date"`
 	Status               string `json:"status"`
 	ProcessedAt          string `json:"processed_at"`
+	// Metadata is what the provider sent with the payment, if anything
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// BalanceAfter is the outstanding balance the payment left, in minor units;
+	// absent for payments recorded before it was captured
+	BalanceAfter *int64 `json:"balance_after,omitempty"`
+}
+
+// PaymentFeedResponse answers GET /payments/stream
+type PaymentFeedResponse struct {
+	Payments []PaymentRecordResponse `json:"payments"`
+	// NextCursor is the since_id of the next poll; it is always set
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+	// Totals count and sum Payments by currency code
+	Totals map[string]PaymentTotalsResponse `json:"totals"`
+}
+
+type PaymentTotalsResponse struct {
+	Count  int64 `json:"count"`
+	Amount int64 `json:"amount"`
+}
+
+// ParseDateRangeBound accepts RFC 3339, "2006-01-02 15:04:05" or a bare
+// date. The last two are read in loc (nil is UTC), like transaction dates.
+// A bare date given as the upper bound covers that whole day.
+func ParseDateRangeBound(value string, upper bool, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	loc = locationOrUTC(loc)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD, YYYY-MM-DD HH:MM:SS or RFC 3339", value)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+type WriteOffRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *WriteOffRequest) Validate() error {
+	if strings.TrimSpace(r.Reason) == "" {
+		return errors.New("reason is required")
+	}
+	return nil
+}
+
+type WriteOffResponse struct {
+	CustomerID           string `json:"customer_id"`
+	Status               string `json:"status"`
+	AmountWrittenOff     int64  `json:"amount_written_off"`
+	OutstandingBalance   int64  `json:"outstanding_balance"`
+	TotalPaid            int64  `json:"total_paid"`
+	TransactionReference string `json:"transaction_reference,omitempty"`
+}
+
+// RestructureRequest changes a loan's terms. AssetValue is in the loan
+// currency's minor unit; at least one of it and RepaymentTermWeeks is needed.
+type RestructureRequest struct {
+	AssetValue         *int64 `json:"asset_value,omitempty"`
+	RepaymentTermWeeks *int   `json:"repayment_term_weeks,omitempty"`
+	Reason             string `json:"reason"`
+}
+
+func (r *RestructureRequest) Validate() error {
+	if strings.TrimSpace(r.Reason) == "" {
+		return errors.New("reason is required")
+	}
+	if r.AssetValue == nil && r.RepaymentTermWeeks == nil {
+		return errors.New("asset_value or repayment_term_weeks is required")
+	}
+	if r.AssetValue != nil && *r.AssetValue <= 0 {
+		return errors.New("asset_value must be positive")
+	}
+	if r.RepaymentTermWeeks != nil && *r.RepaymentTermWeeks <= 0 {
+		return errors.New("repayment_term_weeks must be positive")
+	}
+	return nil
+}
+
+// RestructureResponse is the customer under the new terms, with the terms
+// they replaced
+type RestructureResponse struct {
+	Customer                   CustomerResponse `json:"customer"`
+	PreviousAssetValue         int64            `json:"previous_asset_value"`
+	PreviousRepaymentTermWeeks int              `json:"previous_repayment_term_weeks"`
+}
+
+// MaxReconcileIDs bounds how many customers one status repair may name
+const MaxReconcileIDs = 1000
+
+type ReconcileStatusRequest struct {
+	CustomerIDs []string `json:"customer_ids"`
+}
+
+func (r *ReconcileStatusRequest) Validate() error {
+	if len(r.CustomerIDs) == 0 {
+		return errors.New("customer_ids is required")
+	}
+	if len(r.CustomerIDs) > MaxReconcileIDs {
+		return errors.New("too many customer_ids")
+	}
+	return nil
+}
+
+type StatusChange struct {
+	CustomerID string `json:"customer_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+type ReconcileStatusResponse struct {
+	Checked      int            `json:"checked"`
+	ChangedCount int            `json:"changed_count"`
+	Changed      []StatusChange `json:"changed"`
+	NotFound     []string       `json:"not_found"`
+	Failed       []string       `json:"failed"`
+}
+
+// Pagination describes an offset-paginated list
+type Pagination struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalCount int64 `json:"total_count"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// CollectionsEntry is one row of the collections worklist; amounts are in kobo
+type CollectionsEntry struct {
+	CustomerID         string `json:"customer_id"`
+	Status             string `json:"status"`
+	OutstandingBalance int64  `json:"outstanding_balance"`
+	TotalPaid          int64  `json:"total_paid"`
+	Arrears            int64  `json:"arrears"`
+	DaysOverdue        int    `json:"days_overdue"`
+	LastPaymentDate    string `json:"last_payment_date,omitempty"`
+}
+
+type CollectionsResponse struct {
+	Customers    []CollectionsEntry `json:"customers"`
+	TotalArrears int64              `json:"total_arrears"`
+	Pagination   Pagination         `json:"pagination"`
+}
+
+type CacheEvictResponse struct {
+	CustomerID string `json:"customer_id"`
+	Existed    bool   `json:"existed"`
+}
+
+// MaxBulkEvictIDs bounds how many IDs one bulk eviction may name
+const MaxBulkEvictIDs = 1000
+
+// BulkCacheEvictRequest names customers to evict, either explicitly or by
+// an ID glob pattern such as "GIG000*"; exactly one must be given
+type BulkCacheEvictRequest struct {
+	CustomerIDs []string `json:"customer_ids"`
+	Pattern     string   `json:"pattern"`
+}
+
+func (r *BulkCacheEvictRequest) Validate() error {
+	if len(r.CustomerIDs) == 0 && r.Pattern == "" {
+		return errors.New("customer_ids or pattern is required")
+	}
+	if len(r.CustomerIDs) > 0 && r.Pattern != "" {
+		return errors.New("customer_ids and pattern are mutually exclusive")
+	}
+	if len(r.CustomerIDs) > MaxBulkEvictIDs {
+		return errors.New("too many customer_ids")
+	}
+	return nil
+}
+
+type BulkCacheEvictResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// MaintenanceRequest turns maintenance mode on; the reason is shown to
+// whoever checks the status
+type MaintenanceRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *MaintenanceRequest) Validate() error {
+	if strings.TrimSpace(r.Reason) == "" {
+		return errors.New("reason is required")
+	}
+	return nil
+}
+
+type MaintenanceResponse struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// EventStreamResponse is a read-only look at one event stream
+type EventStreamResponse struct {
+	Stream  string               `json:"stream"`
+	Entries []EventStreamEntry   `json:"entries"`
+	Pending []EventPendingRecord `json:"pending"`
+}
+
+// EventStreamEntry is a stream message; Payload is its decoded data field
+type EventStreamEntry struct {
+	ID      string                 `json:"id"`
+	Fields  map[string]interface{} `json:"fields"`
+	Payload json.RawMessage        `json:"payload,omitempty"`
+}
+
+// EventPendingRecord is a message a worker has read but not acknowledged
+type EventPendingRecord struct {
+	ID         string `json:"id"`
+	Consumer   string `json:"consumer"`
+	IdleMs     int64  `json:"idle_ms"`
+	Deliveries int64  `json:"deliveries"`
+}
+
+// EventClaimResponse reports the stuck messages re-queued on a stream.
+// Deleted messages were trimmed before anyone handled them and are only
+// dropped from the pending list.
+type EventClaimResponse struct {
+	Stream   string              `json:"stream"`
+	Claimed  int                 `json:"claimed"`
+	Requeued []EventRequeuedItem `json:"requeued"`
+	Deleted  []string            `json:"deleted"`
+}
+
+// EventRequeuedItem pairs a claimed message with the copy that replaced it
+type EventRequeuedItem struct {
+	ID    string `json:"id"`
+	NewID string `json:"new_id"`
+}
+
+// EventLogResponse is a page of the event log. NextAfterSeq is the
+// after_seq that reads the following page; with no new events it is the
+// after_seq that was asked for.
+type EventLogResponse struct {
+	Events       []EventLogEntry `json:"events"`
+	NextAfterSeq int64           `json:"next_after_seq"`
+}
+
+// EventLogEntry is one logged event; Payload is the event as published
+type EventLogEntry struct {
+	Sequence    int64           `json:"sequence"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	RecordedAt  time.Time       `json:"recorded_at"`
+}
+
+// WorkersResponse lists the event workers with a live heartbeat. Alive is
+// false when there are none, which is also answered with 503.
+type WorkersResponse struct {
+	Alive   bool           `json:"alive"`
+	Count   int            `json:"count"`
+	Workers []WorkerStatus `json:"workers"`
+}
+
+// WorkerStatus is one worker's last heartbeat; ExpiresInMs is how long it
+// has left to beat again before it counts as gone
+type WorkerStatus struct {
+	Consumer    string    `json:"consumer"`
+	StartedAt   time.Time `json:"started_at"`
+	LastBeatAt  time.Time `json:"last_beat_at"`
+	ExpiresInMs int64     `json:"expires_in_ms"`
+}
+
+// FeatureFlagRequest sets a feature flag. RolloutPercent defaults to 100,
+// a plain on/off switch; below that the flag covers that share of
+// customers.
+type FeatureFlagRequest struct {
+	Enabled        *bool `json:"enabled"`
+	RolloutPercent *int  `json:"rollout_percent,omitempty"`
+}
+
+func (r *FeatureFlagRequest) Validate() error {
+	if r.Enabled == nil {
+		return errors.New("enabled is required")
+	}
+	if r.RolloutPercent != nil && (*r.RolloutPercent < 0 || *r.RolloutPercent > 100) {
+		return errors.New("rollout_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+type FeatureFlagResponse struct {
+	Name           string    `json:"name"`
+	Enabled        bool      `json:"enabled"`
+	RolloutPercent int       `json:"rollout_percent"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type FeatureFlagsResponse struct {
+	Flags []FeatureFlagResponse `json:"flags"`
+}
+
+// Outcomes of one row of a payment upload
+const (
+	// UploadRowProcessed rows were applied to the customer's balance, now
+	// or by an earlier payment with the same reference
+	UploadRowProcessed = "processed"
+	// UploadRowNotProcessed rows were accepted but not applied, such as
+	// payments that are not COMPLETE
+	UploadRowNotProcessed = "not_processed"
+	// UploadRowSkipped rows were never sent for processing: malformed,
+	// invalid or repeating a reference seen earlier in the file
+	UploadRowSkipped = "skipped"
+	// UploadRowFailed rows could not be processed, such as for an unknown
+	// customer or a database error
+	UploadRowFailed = "failed"
+)
+
+// PaymentUploadResponse summarizes a CSV payment upload. Results are in
+// file order; Row is the line number in the file, the header being line 1.
+type PaymentUploadResponse struct {
+	Rows         int                `json:"rows"`
+	Processed    int                `json:"processed"`
+	NotProcessed int                `json:"not_processed"`
+	Skipped      int                `json:"skipped"`
+	Failed       int                `json:"failed"`
+	Results      []PaymentUploadRow `json:"results"`
+	// Aborted says why reading the file stopped early; rows after that
+	// point were not read
+	Aborted string `json:"aborted,omitempty"`
+}
+
+type PaymentUploadRow struct {
+	Row                  int    `json:"row"`
+	CustomerID           string `json:"customer_id,omitempty"`
+	TransactionReference string `json:"transaction_reference,omitempty"`
+	Status               string `json:"status"`
+	Reason               string `json:"reason,omitempty"`
+}
+
+// Count adds a finished row to the totals
+func (r *PaymentUploadResponse) Count(row PaymentUploadRow) {
+	r.Rows++
+	switch row.Status {
+	case UploadRowProcessed:
+		r.Processed++
+	case UploadRowNotProcessed:
+		r.NotProcessed++
+	case UploadRowSkipped:
+		r.Skipped++
+	case UploadRowFailed:
+		r.Failed++
+	}
+	r.Results = append(r.Results, row)
+}
+
+// DefaultCustomerIDPattern matches GigMile customer IDs such as GIG00001
+const DefaultCustomerIDPattern = `^GIG\d{5}$`
+
+// MaxCustomerIDLength is the width of the customers.id column
+const MaxCustomerIDLength = 50
+
+var customerIDCharset = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CustomerIDFormat rejects customer IDs that can't possibly exist, so they
+// are answered with 400 rather than reaching the repository. The nil
+// format only enforces the column width and a safe character set.
+type CustomerIDFormat struct {
+	pattern *regexp.Regexp
+}
+
+// NewCustomerIDFormat compiles pattern; empty skips the pattern check
+func NewCustomerIDFormat(pattern string) (*CustomerIDFormat, error) {
+	if pattern == "" {
+		return &CustomerIDFormat{}, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid customer ID pattern: %w", err)
+	}
+	return &CustomerIDFormat{pattern: re}, nil
+}
+
+// Validate checks an ID that has already been normalized (trimmed and
+// uppercased) the way the service looks customers up
+func (f *CustomerIDFormat) Validate(id string) error {
+	switch {
+	case id == "":
+		return errors.New("customer_id is required")
+	case len(id) > MaxCustomerIDLength:
+		return fmt.Errorf("customer_id must be at most %d characters", MaxCustomerIDLength)
+	case !customerIDCharset.MatchString(id):
+		return errors.New("customer_id may only contain letters, digits, '-' and '_'")
+	case f != nil && f.pattern != nil && !f.pattern.MatchString(id):
+		return fmt.Errorf("customer_id must match %s", f.pattern)
+	}
+	return nil
 }
@@ -0,0 +1,167 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentRequest_AmountAsStringOrNumber(t *testing.T) {
+	bodies := map[string]string{
+		"quoted string":   `{"transaction_amount": "250000.00"}`,
+		"padded string":   `{"transaction_amount": " 250000.00 "}`,
+		"unquoted number": `{"transaction_amount": 250000.00}`,
+		"integer":         `{"transaction_amount": 250000}`,
+		"exponent":        `{"transaction_amount": 2.5e5}`,
+	}
+
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			var req PaymentRequest
+			require.NoError(t, json.Unmarshal([]byte(body), &req))
+			req.Normalize()
+
+			kobo, err := req.GetAmount(domain.CurrencyNGN)
+			require.NoError(t, err)
+			assert.Equal(t, int64(25000000), kobo)
+		})
+	}
+}
+
+func TestPaymentRequest_AmountRejectsOtherTypes(t *testing.T) {
+	for _, body := range []string{
+		`{"transaction_amount": true}`,
+		`{"transaction_amount": {"value": 1}}`,
+		`{"transaction_amount": [1]}`,
+	} {
+		var req PaymentRequest
+		var typeErr *json.UnmarshalTypeError
+		err := json.Unmarshal([]byte(body), &req)
+		assert.ErrorAs(t, err, &typeErr, body)
+	}
+}
+
+func TestPaymentRequest_NullAmountIsMissing(t *testing.T) {
+	var req PaymentRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"customer_id": "GIG00001", "payment_status": "COMPLETE", "transaction_amount": null, "transaction_date": "2025-11-24 14:54:16", "transaction_reference": "TX1"}`), &req))
+	assert.EqualError(t, req.Validate(), "transaction_amount is required")
+}
+
+func TestPaymentRequest_ValidateReportsEveryField(t *testing.T) {
+	req := PaymentRequest{
+		PaymentStatus:     "COMPLETE",
+		TransactionAmount: "ten",
+		TransactionDate:   "24/11/2025",
+	}
+
+	err := req.Validate()
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, map[string]string{
+		"customer_id":           "is required",
+		"transaction_reference": "is required",
+		"transaction_amount":    "must be a valid number",
+		"transaction_date":      "must be in format 'YYYY-MM-DD HH:MM:SS'",
+	}, verr.Fields)
+	assert.EqualError(t, err, "customer_id is required; transaction_amount must be a valid number; "+
+		"transaction_date must be in format 'YYYY-MM-DD HH:MM:SS'; transaction_reference is required")
+}
+
+func TestPaymentRequest_AmountInZeroDecimalCurrency(t *testing.T) {
+	var req PaymentRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"transaction_amount": 250000, "currency": " ugx "}`), &req))
+	req.Normalize()
+	assert.Equal(t, "UGX", req.Currency)
+
+	amount, err := req.GetAmount(domain.CurrencyUGX)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250000), amount, "shillings have no minor unit")
+
+	req.TransactionAmount = "250000.50"
+	_, err = req.GetAmount(domain.CurrencyUGX)
+	assert.ErrorIs(t, err, domain.ErrAmountTooPrecise)
+}
+
+func TestPaymentRequest_ValidateRejectsUnknownCurrency(t *testing.T) {
+	req := PaymentRequest{
+		CustomerID:           "GIG00001",
+		PaymentStatus:        "COMPLETE",
+		TransactionAmount:    "1000",
+		TransactionDate:      "2025-11-24 14:54:16",
+		TransactionReference: "TX1",
+		Currency:             "XYZ",
+	}
+
+	assert.EqualError(t, req.Validate(), "currency is not a supported currency")
+}
+
+func TestPaymentRequest_ValidateBoundsMetadata(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= domain.MaxPaymentMetadataEntries; i++ {
+		tooMany[fmt.Sprintf("key%02d", i)] = "v"
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  string
+	}{
+		{"absent", nil, ""},
+		{"within bounds", map[string]string{"channel": "ussd"}, ""},
+		{"too many entries", tooMany, "metadata must have at most 20 entries"},
+		{"blank key", map[string]string{" ": "ussd"}, "metadata keys must not be blank"},
+		{"long key", map[string]string{strings.Repeat("k", 65): "ussd"}, "metadata keys must be at most 64 bytes"},
+		{"long value", map[string]string{"note": strings.Repeat("v", 257)}, `metadata value of "note" must be at most 256 bytes`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := PaymentRequest{
+				CustomerID:           "GIG00001",
+				PaymentStatus:        "COMPLETE",
+				TransactionAmount:    "1000",
+				TransactionDate:      "2025-11-24 14:54:16",
+				TransactionReference: "TX1",
+				Metadata:             tt.metadata,
+			}
+
+			err := req.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPaymentRequest_TransactionDateIgnoresServerZone(t *testing.T) {
+	lagos := time.FixedZone("WAT", 3600)
+	want := time.Date(2025, 11, 24, 22, 30, 0, 0, time.UTC)
+	req := PaymentRequest{TransactionDate: "2025-11-24 23:30:00"}
+
+	serverZone := time.Local
+	t.Cleanup(func() { time.Local = serverZone })
+	for _, zone := range []*time.Location{time.UTC, time.FixedZone("EST", -5*3600), time.FixedZone("JST", 9*3600)} {
+		time.Local = zone
+
+		got, err := req.GetTransactionDate(lagos)
+		require.NoError(t, err)
+		assert.True(t, want.Equal(got), "server in %s read %s", zone, got)
+
+		from, err := ParseDateRangeBound("2025-11-24", false, lagos)
+		require.NoError(t, err)
+		assert.True(t, time.Date(2025, 11, 23, 23, 0, 0, 0, time.UTC).Equal(from), "server in %s read %s", zone, from)
+	}
+
+	got, err := req.GetTransactionDate(nil)
+	require.NoError(t, err)
+	assert.True(t, want.Add(time.Hour).Equal(got), "no zone is UTC")
+}
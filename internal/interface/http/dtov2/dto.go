@@ -0,0 +1,99 @@
+// Package dtov2 holds the /api/v2 request and response shapes. v1 shapes
+// stay in package dto and never change incompatibly; a new version gets
+// its own package alongside these.
+package dtov2
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+// Money carries an amount as exact decimal strings, so no client has to
+// divide minor units or trust a float
+type Money struct {
+	// Kobo is the integer amount in the currency's minor unit
+	Kobo string `json:"kobo"`
+	// Naira is the same amount in major units as a plain decimal, e.g.
+	// "250000.00", or "250000" for a currency without a minor unit
+	Naira string `json:"naira"`
+	// Formatted is for display, e.g. "₦250,000.00"
+	Formatted string `json:"formatted"`
+	// Currency is the ISO 4217 code the amount is in
+	Currency string `json:"currency"`
+}
+
+func NewMoney(amount int64, currency domain.Currency, symbol string) Money {
+	return Money{
+		Kobo:      strconv.FormatInt(amount, 10),
+		Naira:     strings.ReplaceAll(dto.FormatMinor(amount, currency.Exponent, ""), ",", ""),
+		Formatted: dto.FormatMinor(amount, currency.Exponent, symbol),
+		Currency:  currency.Code,
+	}
+}
+
+// Customer groups a customer's loan by concern rather than as a flat list
+// of amount fields
+type Customer struct {
+	ID       string   `json:"id"`
+	Status   string   `json:"status"`
+	Asset    Asset    `json:"asset"`
+	Balance  Balance  `json:"balance"`
+	Schedule Schedule `json:"schedule"`
+}
+
+type Asset struct {
+	Value              Money `json:"value"`
+	RepaymentTermWeeks int   `json:"repayment_term_weeks"`
+}
+
+type Balance struct {
+	Outstanding Money `json:"outstanding"`
+	Paid        Money `json:"paid"`
+	// ProgressPercent is Paid over the asset value, to two decimals
+	ProgressPercent string `json:"progress_percent"`
+	FullyPaid       bool   `json:"fully_paid"`
+}
+
+// Schedule is the customer's standing against weekly installments as of now
+type Schedule struct {
+	WeeklyInstallment  Money `json:"weekly_installment"`
+	Arrears            Money `json:"arrears"`
+	MissedInstallments int   `json:"missed_installments"`
+}
+
+type Payment struct {
+	ID              string `json:"id"`
+	CustomerID      string `json:"customer_id"`
+	Amount          Money  `json:"amount"`
+	Reference       string `json:"reference"`
+	TransactionDate string `json:"transaction_date"`
+	Status          string `json:"status"`
+	// ProcessedAt is null for payments never marked processed
+	ProcessedAt *string `json:"processed_at"`
+	// BalanceAfter is the outstanding balance the payment left; null for
+	// payments recorded before it was captured
+	BalanceAfter *Money `json:"balance_after"`
+}
+
+// Envelope is the shape of every v2 response, so clients parse them all
+// the same way. A success carries Data and a null Error; a failure carries
+// Error and a null Data. Meta is always an object.
+type Envelope struct {
+	Data  interface{}        `json:"data"`
+	Meta  Meta               `json:"meta"`
+	Error *dto.ErrorResponse `json:"error"`
+}
+
+// Meta describes the data rather than being part of it
+type Meta struct {
+	CustomerID string          `json:"customer_id,omitempty"`
+	Pagination *dto.Pagination `json:"pagination,omitempty"`
+}
+
+// ErrorEnvelope wraps an error in the envelope
+func ErrorEnvelope(body dto.ErrorResponse) interface{} {
+	return Envelope{Error: &body}
+}
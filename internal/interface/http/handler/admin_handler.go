@@ -0,0 +1,727 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/messaging"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CustomerCache is the slice of the Redis customer cache the admin
+// endpoints need
+type CustomerCache interface {
+	Delete(ctx context.Context, customerID string) (bool, error)
+	DeleteMany(ctx context.Context, customerIDs []string) (int64, error)
+	DeleteByPattern(ctx context.Context, pattern string) (int64, error)
+}
+
+// MaintenanceSwitch pauses writes on every API replica
+type MaintenanceSwitch interface {
+	Enable(ctx context.Context, reason string) error
+	Disable(ctx context.Context) error
+	Status(ctx context.Context) (enabled bool, reason string, since time.Time, err error)
+}
+
+// EventInspector reads event streams without consuming them
+type EventInspector interface {
+	Inspect(ctx context.Context, eventType string, query messaging.StreamQuery) (*messaging.StreamSnapshot, error)
+}
+
+// EventReclaimer re-queues stream messages a worker read but never
+// acknowledged
+type EventReclaimer interface {
+	Claim(ctx context.Context, eventType string, minIdle time.Duration, count int64) (*messaging.ClaimResult, error)
+}
+
+// WorkerRegistry lists the event workers that are currently alive
+type WorkerRegistry interface {
+	Live(ctx context.Context) ([]messaging.WorkerStatus, error)
+}
+
+const (
+	defaultEventInspectCount = 50
+	maxEventInspectCount     = 500
+	defaultEventLogLimit     = 100
+	maxEventLogLimit         = 1000
+	defaultEventClaimCount   = 100
+	maxEventClaimCount       = 1000
+)
+
+var (
+	// eventTypePattern keeps the stream name to what event types look like
+	eventTypePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+	// streamIDPattern matches a stream ID (ms or ms-seq), or - and + for
+	// the ends of the stream
+	streamIDPattern = regexp.MustCompile(`^(\d+(-\d+)?|-|\+)$`)
+)
+
+type AdminHandler struct {
+	paymentService *service.PaymentService
+	customerCache  CustomerCache
+	config         Config
+	logger         *zap.Logger
+}
+
+func NewAdminHandler(paymentService *service.PaymentService, customerCache CustomerCache, cfg Config, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		paymentService: paymentService,
+		customerCache:  customerCache,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// WriteOffCustomer forgives the remaining balance on a customer's loan
+func (h *AdminHandler) WriteOffCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
+		return
+	}
+
+	if !isJSONContentType(r) {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.WriteOffRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	result, err := h.paymentService.WriteOffCustomer(r.Context(), customerID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrCustomerNotFound):
+			respondError(w, http.StatusNotFound, "customer not found", err)
+		case errors.Is(err, domain.ErrAssetAlreadyOwned):
+			respondError(w, http.StatusConflict, "customer has already completed payment", err)
+		case errors.Is(err, domain.ErrLoanWrittenOff):
+			respondError(w, http.StatusConflict, "customer loan has already been written off", err)
+		default:
+			logFailure(h.logger, "failed to write off customer", err,
+				zap.String("customer_id", customerID),
+			)
+			respondError(w, failureStatus(err), "failed to write off customer", err)
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, dto.WriteOffResponse{
+		CustomerID:           result.Customer.ID,
+		Status:               string(result.Customer.Status),
+		AmountWrittenOff:     result.AmountWrittenOff,
+		OutstandingBalance:   result.Customer.OutstandingBalance,
+		TotalPaid:            result.Customer.TotalPaid,
+		TransactionReference: result.TransactionReference,
+	})
+}
+
+// RestructureCustomer changes a customer's asset value and/or repayment term
+func (h *AdminHandler) RestructureCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
+		return
+	}
+
+	if !isJSONContentType(r) {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.RestructureRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	result, err := h.paymentService.RestructureLoan(r.Context(), customerID, service.RestructureRequest{
+		AssetValue: req.AssetValue,
+		TermWeeks:  req.RepaymentTermWeeks,
+		Reason:     strings.TrimSpace(req.Reason),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrCustomerNotFound):
+			respondError(w, http.StatusNotFound, "customer not found", err)
+		case errors.Is(err, domain.ErrAssetAlreadyOwned):
+			respondError(w, http.StatusConflict, "customer has already completed payment", err)
+		case errors.Is(err, domain.ErrLoanWrittenOff):
+			respondError(w, http.StatusConflict, "customer loan has already been written off", err)
+		case errors.Is(err, domain.ErrOptimisticLock):
+			respondError(w, http.StatusConflict, "customer was updated concurrently, retry", err)
+		case errors.Is(err, domain.ErrAssetValueBelowPaid):
+			respondError(w, http.StatusUnprocessableEntity, "asset value is below the amount already paid", err)
+		default:
+			logFailure(h.logger, "failed to restructure customer", err,
+				zap.String("customer_id", customerID),
+			)
+			respondError(w, failureStatus(err), "failed to restructure customer", err)
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, dto.RestructureResponse{
+		Customer:                   toCustomerResponse(result.Customer, h.config.moneyFormat()),
+		PreviousAssetValue:         result.PreviousAssetValue,
+		PreviousRepaymentTermWeeks: result.PreviousTermWeeks,
+	})
+}
+
+// ReconcileCustomerStatuses repairs customers whose status disagrees with
+// their balance
+func (h *AdminHandler) ReconcileCustomerStatuses(w http.ResponseWriter, r *http.Request) {
+	if !isJSONContentType(r) {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.ReconcileStatusRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	report, err := h.paymentService.ReconcileCustomerStatuses(r.Context(), req.CustomerIDs)
+	if err != nil {
+		logFailure(h.logger, "failed to reconcile customer statuses", err,
+			zap.Int("customer_ids", len(req.CustomerIDs)),
+		)
+		respondError(w, failureStatus(err), "failed to reconcile customer statuses", err)
+		return
+	}
+
+	h.logger.Info("customer statuses reconciled",
+		zap.Int("checked", report.Checked),
+		zap.Int("changed", len(report.Changed)),
+		zap.Int("failed", len(report.Failed)),
+	)
+
+	response := dto.ReconcileStatusResponse{
+		Checked:      report.Checked,
+		ChangedCount: len(report.Changed),
+		Changed:      make([]dto.StatusChange, len(report.Changed)),
+		NotFound:     report.NotFound,
+		Failed:       report.Failed,
+	}
+	for i, change := range report.Changed {
+		response.Changed[i] = dto.StatusChange{
+			CustomerID: change.CustomerID,
+			From:       string(change.From),
+			To:         string(change.To),
+		}
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// EvictCustomerCache removes one customer from the Redis cache
+func (h *AdminHandler) EvictCustomerCache(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
+		return
+	}
+
+	existed, err := h.customerCache.Delete(r.Context(), customerID)
+	if err != nil {
+		logFailure(h.logger, "failed to evict customer cache", err,
+			zap.String("customer_id", customerID),
+		)
+		respondError(w, failureStatus(err), "failed to evict customer cache", err)
+		return
+	}
+
+	h.logger.Info("customer cache evicted",
+		zap.String("customer_id", customerID),
+		zap.Bool("existed", existed),
+	)
+
+	respondJSON(w, http.StatusOK, dto.CacheEvictResponse{
+		CustomerID: customerID,
+		Existed:    existed,
+	})
+}
+
+// EvictCustomerCaches removes customers from the Redis cache either by an
+// explicit list of IDs or by an ID glob pattern
+func (h *AdminHandler) EvictCustomerCaches(w http.ResponseWriter, r *http.Request) {
+	if !isJSONContentType(r) {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.BulkCacheEvictRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	var (
+		deleted int64
+		err     error
+	)
+	if req.Pattern != "" {
+		deleted, err = h.customerCache.DeleteByPattern(r.Context(), req.Pattern)
+	} else {
+		deleted, err = h.customerCache.DeleteMany(r.Context(), req.CustomerIDs)
+	}
+	if err != nil {
+		logFailure(h.logger, "failed to bulk evict customer cache", err,
+			zap.String("pattern", req.Pattern),
+			zap.Int("customer_ids", len(req.CustomerIDs)),
+		)
+		respondError(w, failureStatus(err), "failed to evict customer cache", err)
+		return
+	}
+
+	h.logger.Info("customer caches evicted",
+		zap.String("pattern", req.Pattern),
+		zap.Int("customer_ids", len(req.CustomerIDs)),
+		zap.Int64("deleted", deleted),
+	)
+
+	respondJSON(w, http.StatusOK, dto.BulkCacheEvictResponse{Deleted: deleted})
+}
+
+// MaintenanceStatus reports whether writes are currently paused
+func (h *AdminHandler) MaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	if h.config.Maintenance == nil {
+		respondError(w, http.StatusNotFound, "maintenance mode is not configured", nil)
+		return
+	}
+
+	enabled, reason, since, err := h.config.Maintenance.Status(r.Context())
+	if err != nil {
+		logFailure(h.logger, "failed to read maintenance mode", err)
+		respondError(w, failureStatus(err), "failed to read maintenance mode", err)
+		return
+	}
+
+	resp := dto.MaintenanceResponse{Enabled: enabled, Reason: reason}
+	if !since.IsZero() {
+		resp.Since = &since
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// EnableMaintenance pauses payment processing and other writes; reads keep
+// working and the provider retries the 503s
+func (h *AdminHandler) EnableMaintenance(w http.ResponseWriter, r *http.Request) {
+	if h.config.Maintenance == nil {
+		respondError(w, http.StatusNotFound, "maintenance mode is not configured", nil)
+		return
+	}
+
+	if !isJSONContentType(r) {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.MaintenanceRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	if err := h.config.Maintenance.Enable(r.Context(), req.Reason); err != nil {
+		logFailure(h.logger, "failed to enable maintenance mode", err)
+		respondError(w, failureStatus(err), "failed to enable maintenance mode", err)
+		return
+	}
+
+	h.logger.Warn("maintenance mode enabled", zap.String("reason", req.Reason))
+	h.MaintenanceStatus(w, r)
+}
+
+// DisableMaintenance resumes writes
+func (h *AdminHandler) DisableMaintenance(w http.ResponseWriter, r *http.Request) {
+	if h.config.Maintenance == nil {
+		respondError(w, http.StatusNotFound, "maintenance mode is not configured", nil)
+		return
+	}
+
+	if err := h.config.Maintenance.Disable(r.Context()); err != nil {
+		logFailure(h.logger, "failed to disable maintenance mode", err)
+		respondError(w, failureStatus(err), "failed to disable maintenance mode", err)
+		return
+	}
+
+	h.logger.Warn("maintenance mode disabled")
+	respondJSON(w, http.StatusOK, dto.MaintenanceResponse{Enabled: false})
+}
+
+// InspectEvents returns recent messages on an event stream with their
+// decoded payloads, plus what the workers have read but not acknowledged.
+// from and to are stream IDs; count defaults to 50 and is capped at 500.
+func (h *AdminHandler) InspectEvents(w http.ResponseWriter, r *http.Request) {
+	if h.config.EventInspector == nil {
+		respondError(w, http.StatusNotFound, "event inspection is not configured", nil)
+		return
+	}
+
+	eventType := chi.URLParam(r, "event_type")
+	if !eventTypePattern.MatchString(eventType) {
+		respondError(w, http.StatusBadRequest, "invalid event type", nil)
+		return
+	}
+
+	query := messaging.StreamQuery{
+		From:  r.URL.Query().Get("from"),
+		To:    r.URL.Query().Get("to"),
+		Count: defaultEventInspectCount,
+	}
+	for _, id := range []string{query.From, query.To} {
+		if id != "" && !streamIDPattern.MatchString(id) {
+			respondError(w, http.StatusBadRequest, "from and to must be stream IDs", nil)
+			return
+		}
+	}
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		count, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || count < 1 {
+			respondError(w, http.StatusBadRequest, "count must be a positive integer", err)
+			return
+		}
+		query.Count = min(count, maxEventInspectCount)
+	}
+
+	snapshot, err := h.config.EventInspector.Inspect(r.Context(), eventType, query)
+	if err != nil {
+		logFailure(h.logger, "failed to inspect event stream", err, zap.String("event_type", eventType))
+		respondError(w, failureStatus(err), "failed to inspect event stream", err)
+		return
+	}
+
+	resp := dto.EventStreamResponse{
+		Stream:  snapshot.Stream,
+		Entries: make([]dto.EventStreamEntry, len(snapshot.Entries)),
+		Pending: make([]dto.EventPendingRecord, len(snapshot.Pending)),
+	}
+	for i, entry := range snapshot.Entries {
+		resp.Entries[i] = dto.EventStreamEntry{ID: entry.ID, Fields: entry.Fields, Payload: entry.Payload}
+	}
+	for i, pending := range snapshot.Pending {
+		resp.Pending[i] = dto.EventPendingRecord{
+			ID:         pending.ID,
+			Consumer:   pending.Consumer,
+			IdleMs:     pending.Idle.Milliseconds(),
+			Deliveries: pending.Deliveries,
+		}
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// ClaimEvents re-queues messages on an event stream that a worker read but
+// hasn't acknowledged for at least min_idle (a duration, defaulting to
+// EventClaimMinIdle), so a live worker handles them. count defaults to 100
+// and is capped at 1000.
+func (h *AdminHandler) ClaimEvents(w http.ResponseWriter, r *http.Request) {
+	if h.config.EventReclaimer == nil {
+		respondError(w, http.StatusNotFound, "event reclaiming is not configured", nil)
+		return
+	}
+
+	eventType := chi.URLParam(r, "event_type")
+	if !eventTypePattern.MatchString(eventType) {
+		respondError(w, http.StatusBadRequest, "invalid event type", nil)
+		return
+	}
+
+	minIdle := h.config.EventClaimMinIdle
+	if raw := r.URL.Query().Get("min_idle"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			respondError(w, http.StatusBadRequest, "min_idle must be a non-negative duration such as 5m", err)
+			return
+		}
+		minIdle = d
+	}
+	count := int64(defaultEventClaimCount)
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "count must be a positive integer", err)
+			return
+		}
+		count = min(n, maxEventClaimCount)
+	}
+
+	result, err := h.config.EventReclaimer.Claim(r.Context(), eventType, minIdle, count)
+	if err != nil {
+		logFailure(h.logger, "failed to claim pending events", err,
+			zap.String("event_type", eventType), zap.Int("requeued", len(result.Requeued)))
+		respondError(w, failureStatus(err), "failed to claim pending events", err)
+		return
+	}
+
+	resp := dto.EventClaimResponse{
+		Stream:   result.Stream,
+		Claimed:  len(result.Requeued),
+		Requeued: make([]dto.EventRequeuedItem, len(result.Requeued)),
+		Deleted:  result.Deleted,
+	}
+	for i, entry := range result.Requeued {
+		resp.Requeued[i] = dto.EventRequeuedItem{ID: entry.ID, NewID: entry.NewID}
+	}
+	h.logger.Info("claimed pending events",
+		zap.String("event_type", eventType),
+		zap.Duration("min_idle", minIdle),
+		zap.Int("requeued", resp.Claimed),
+		zap.Int("deleted", len(resp.Deleted)))
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// ReadEventLog lists logged events with a sequence above after_seq, oldest
+// first, for replaying into new read models
+func (h *AdminHandler) ReadEventLog(w http.ResponseWriter, r *http.Request) {
+	if h.config.EventLog == nil {
+		respondError(w, http.StatusNotFound, "event log is not configured", nil)
+		return
+	}
+
+	var afterSeq int64
+	if raw := r.URL.Query().Get("after_seq"); raw != "" {
+		seq, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || seq < 0 {
+			respondError(w, http.StatusBadRequest, "after_seq must be a non-negative integer", err)
+			return
+		}
+		afterSeq = seq
+	}
+	limit := defaultEventLogLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer", err)
+			return
+		}
+		limit = min(n, maxEventLogLimit)
+	}
+
+	settle := h.config.EventLogSettleWindow
+	if settle <= 0 {
+		settle = service.DefaultFeedSettleWindow
+	}
+	events, err := h.config.EventLog.After(r.Context(), afterSeq, time.Now().Add(-settle), limit)
+	if err != nil {
+		logFailure(h.logger, "failed to read event log", err, zap.Int64("after_seq", afterSeq))
+		respondError(w, failureStatus(err), "failed to read event log", err)
+		return
+	}
+
+	resp := dto.EventLogResponse{
+		Events:       make([]dto.EventLogEntry, len(events)),
+		NextAfterSeq: afterSeq,
+	}
+	for i, event := range events {
+		resp.Events[i] = dto.EventLogEntry{
+			Sequence:    event.Sequence,
+			EventID:     event.EventID,
+			EventType:   event.EventType,
+			AggregateID: event.AggregateID,
+			Payload:     event.Payload,
+			OccurredAt:  event.OccurredAt,
+			RecordedAt:  event.RecordedAt,
+		}
+		resp.NextAfterSeq = event.Sequence
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// ListWorkers reports the event workers with a live heartbeat. With none
+// alive notifications are piling up unsent, so it answers 503 for
+// monitoring to alert on.
+func (h *AdminHandler) ListWorkers(w http.ResponseWriter, r *http.Request) {
+	if h.config.Workers == nil {
+		respondError(w, http.StatusNotFound, "worker heartbeats are not configured", nil)
+		return
+	}
+
+	workers, err := h.config.Workers.Live(r.Context())
+	if err != nil {
+		logFailure(h.logger, "failed to list workers", err)
+		respondError(w, failureStatus(err), "failed to list workers", err)
+		return
+	}
+
+	resp := dto.WorkersResponse{
+		Alive:   len(workers) > 0,
+		Count:   len(workers),
+		Workers: make([]dto.WorkerStatus, len(workers)),
+	}
+	for i, worker := range workers {
+		resp.Workers[i] = dto.WorkerStatus{
+			Consumer:    worker.Consumer,
+			StartedAt:   worker.StartedAt,
+			LastBeatAt:  worker.BeatAt,
+			ExpiresInMs: worker.ExpiresIn.Milliseconds(),
+		}
+	}
+
+	status := http.StatusOK
+	if !resp.Alive {
+		h.logger.Warn("no event workers alive")
+		status = http.StatusServiceUnavailable
+	}
+	respondJSON(w, status, resp)
+}
+
+// ListFeatureFlags returns every feature flag, ordered by name
+func (h *AdminHandler) ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	if h.config.FeatureFlags == nil {
+		respondError(w, http.StatusNotFound, "feature flags are not configured", nil)
+		return
+	}
+
+	flags, err := h.config.FeatureFlags.List(r.Context())
+	if err != nil {
+		logFailure(h.logger, "failed to list feature flags", err)
+		respondError(w, failureStatus(err), "failed to list feature flags", err)
+		return
+	}
+
+	resp := dto.FeatureFlagsResponse{Flags: make([]dto.FeatureFlagResponse, len(flags))}
+	for i, flag := range flags {
+		resp.Flags[i] = toFeatureFlagResponse(flag)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// GetFeatureFlag returns one feature flag as it is stored now
+func (h *AdminHandler) GetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if h.config.FeatureFlags == nil {
+		respondError(w, http.StatusNotFound, "feature flags are not configured", nil)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	flag, err := h.config.FeatureFlags.Get(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, domain.ErrFeatureFlagNotFound) {
+			respondError(w, http.StatusNotFound, "feature flag not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to get feature flag", err, zap.String("flag", name))
+		respondError(w, failureStatus(err), "failed to get feature flag", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, toFeatureFlagResponse(flag))
+}
+
+// SetFeatureFlag creates or replaces a feature flag. Every replica picks
+// it up within the flag refresh interval.
+func (h *AdminHandler) SetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if h.config.FeatureFlags == nil {
+		respondError(w, http.StatusNotFound, "feature flags are not configured", nil)
+		return
+	}
+
+	if !isJSONContentType(r) {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.FeatureFlagRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	flag := &domain.FeatureFlag{
+		Name:           chi.URLParam(r, "name"),
+		Enabled:        *req.Enabled,
+		RolloutPercent: 100,
+	}
+	if req.RolloutPercent != nil {
+		flag.RolloutPercent = *req.RolloutPercent
+	}
+
+	if err := h.config.FeatureFlags.Set(r.Context(), flag); err != nil {
+		if errors.Is(err, domain.ErrInvalidFeatureFlag) {
+			respondError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		logFailure(h.logger, "failed to set feature flag", err, zap.String("flag", flag.Name))
+		respondError(w, failureStatus(err), "failed to set feature flag", err)
+		return
+	}
+
+	h.logger.Warn("feature flag set",
+		zap.String("flag", flag.Name),
+		zap.Bool("enabled", flag.Enabled),
+		zap.Int("rollout_percent", flag.RolloutPercent),
+	)
+	respondJSON(w, http.StatusOK, toFeatureFlagResponse(flag))
+}
+
+// DeleteFeatureFlag removes a feature flag, returning the behaviour it
+// controlled to its configured default
+func (h *AdminHandler) DeleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if h.config.FeatureFlags == nil {
+		respondError(w, http.StatusNotFound, "feature flags are not configured", nil)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := h.config.FeatureFlags.Delete(r.Context(), name); err != nil {
+		if errors.Is(err, domain.ErrFeatureFlagNotFound) {
+			respondError(w, http.StatusNotFound, "feature flag not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to delete feature flag", err, zap.String("flag", name))
+		respondError(w, failureStatus(err), "failed to delete feature flag", err)
+		return
+	}
+
+	h.logger.Warn("feature flag deleted", zap.String("flag", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toFeatureFlagResponse(flag *domain.FeatureFlag) dto.FeatureFlagResponse {
+	return dto.FeatureFlagResponse{
+		Name:           flag.Name,
+		Enabled:        flag.Enabled,
+		RolloutPercent: flag.RolloutPercent,
+		UpdatedAt:      flag.UpdatedAt,
+	}
+}
@@ -0,0 +1,760 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/messaging"
+	redisrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/redis"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/gigmile/payment-service/internal/interface/http/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func postWriteOff(h *AdminHandler, customerID, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Post("/api/v1/admin/customers/{customer_id}/writeoff", h.WriteOffCustomer)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/customers/"+customerID+"/writeoff", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWriteOffCustomer_Success(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 500000, TotalPaid: 99500000, Status: domain.CustomerStatusActive, Version: 1}
+	customers := newFakeCustomerRepo(customer)
+	payments := newFakePaymentRepo()
+	h := NewAdminHandler(service.NewPaymentService(customers, payments, nil, logger), nil, Config{}, logger)
+
+	rec := postWriteOff(h, "GIG00001", `{"reason": "small balance forgiven"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.WriteOffResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "WRITTEN_OFF", resp.Status)
+	assert.Equal(t, int64(500000), resp.AmountWrittenOff)
+	assert.Equal(t, int64(0), resp.OutstandingBalance)
+	assert.Equal(t, int64(99500000), resp.TotalPaid)
+
+	audit, err := payments.FindByTransactionReference(context.Background(), "GIG00001", resp.TransactionReference)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentStatusWriteOff, audit.Status)
+	assert.Equal(t, int64(500000), audit.Amount)
+}
+
+func TestWriteOffCustomer_Errors(t *testing.T) {
+	completed := &domain.Customer{ID: "GIG00002", AssetValue: 100000000, TotalPaid: 100000000, Status: domain.CustomerStatusCompleted, Version: 1}
+	writtenOff := &domain.Customer{ID: "GIG00003", AssetValue: 100000000, TotalPaid: 1000000, Status: domain.CustomerStatusWrittenOff, Version: 1}
+
+	tests := []struct {
+		name       string
+		customerID string
+		body       string
+		wantStatus int
+	}{
+		{"already completed", "GIG00002", `{"reason": "goodwill"}`, http.StatusConflict},
+		{"already written off", "GIG00003", `{"reason": "goodwill"}`, http.StatusConflict},
+		{"unknown customer", "GIG09999", `{"reason": "goodwill"}`, http.StatusNotFound},
+		{"missing reason", "GIG00002", `{"reason": "  "}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			paymentService := service.NewPaymentService(newFakeCustomerRepo(completed, writtenOff), newFakePaymentRepo(), nil, logger)
+			h := NewAdminHandler(paymentService, nil, Config{}, logger)
+
+			rec := postWriteOff(h, tt.customerID, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func patchCustomer(h *AdminHandler, customerID, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Patch("/api/v1/admin/customers/{customer_id}", h.RestructureCustomer)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/admin/customers/"+customerID, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRestructureCustomer_Success(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 52, OutstandingBalance: 60000000, TotalPaid: 40000000, Status: domain.CustomerStatusActive, Version: 1}
+	customers := newFakeCustomerRepo(customer)
+	h := NewAdminHandler(service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger), nil, Config{}, logger)
+
+	rec := patchCustomer(h, "GIG00001", `{"asset_value": 90000000, "repayment_term_weeks": 78, "reason": "hardship restructure"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp dto.RestructureResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(100000000), resp.PreviousAssetValue)
+	assert.Equal(t, 52, resp.PreviousRepaymentTermWeeks)
+	assert.Equal(t, int64(50000000), resp.Customer.OutstandingBalance)
+	assert.Equal(t, int64(40000000), resp.Customer.TotalPaid)
+
+	saved, err := customers.FindByID(context.Background(), "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(90000000), saved.AssetValue)
+	assert.Equal(t, 78, saved.RepaymentTermWeeks)
+}
+
+func TestRestructureCustomer_Errors(t *testing.T) {
+	active := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 52, OutstandingBalance: 60000000, TotalPaid: 40000000, Status: domain.CustomerStatusActive, Version: 1}
+	completed := &domain.Customer{ID: "GIG00002", AssetValue: 100000000, RepaymentTermWeeks: 52, TotalPaid: 100000000, Status: domain.CustomerStatusCompleted, Version: 1}
+
+	tests := []struct {
+		name       string
+		customerID string
+		body       string
+		wantStatus int
+	}{
+		{"value below paid", "GIG00001", `{"asset_value": 30000000, "reason": "discount"}`, http.StatusUnprocessableEntity},
+		{"already completed", "GIG00002", `{"repayment_term_weeks": 78, "reason": "extension"}`, http.StatusConflict},
+		{"unknown customer", "GIG09999", `{"repayment_term_weeks": 78, "reason": "extension"}`, http.StatusNotFound},
+		{"missing reason", "GIG00001", `{"repayment_term_weeks": 78}`, http.StatusBadRequest},
+		{"no terms", "GIG00001", `{"reason": "extension"}`, http.StatusBadRequest},
+		{"non-positive term", "GIG00001", `{"repayment_term_weeks": 0, "reason": "extension"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			paymentService := service.NewPaymentService(newFakeCustomerRepo(active, completed), newFakePaymentRepo(), nil, logger)
+			h := NewAdminHandler(paymentService, nil, Config{}, logger)
+
+			rec := patchCustomer(h, tt.customerID, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func newCacheTestAdminHandler(t *testing.T) (*AdminHandler, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	cache := redisrepository.NewRedisCustomerRepository(client, time.Minute, "")
+	for _, id := range []string{"GIG00001", "GIG00002", "GIG00010", "GIG00100"} {
+		require.NoError(t, cache.Save(context.Background(), &domain.Customer{ID: id}))
+	}
+
+	logger := zap.NewNop()
+	return NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), cache, Config{}, logger), mr
+}
+
+func deleteCache(h *AdminHandler, path, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Delete("/api/v1/admin/customers/{customer_id}/cache", h.EvictCustomerCache)
+	r.Delete("/api/v1/admin/cache/customers", h.EvictCustomerCaches)
+
+	req := httptest.NewRequest(http.MethodDelete, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEvictCustomerCache(t *testing.T) {
+	h, mr := newCacheTestAdminHandler(t)
+
+	rec := deleteCache(h, "/api/v1/admin/customers/GIG00001/cache", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.CacheEvictResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, dto.CacheEvictResponse{CustomerID: "GIG00001", Existed: true}, resp)
+	assert.False(t, mr.Exists("customer:GIG00001"))
+	assert.True(t, mr.Exists("customer:GIG00002"))
+
+	rec = deleteCache(h, "/api/v1/admin/customers/GIG00001/cache", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.False(t, resp.Existed)
+}
+
+func TestEvictCustomerCaches(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantDeleted int64
+		wantGone    []string
+		wantKept    []string
+	}{
+		{
+			name:        "by ids",
+			body:        `{"customer_ids": ["GIG00001", "GIG00010", "GIG09999"]}`,
+			wantStatus:  http.StatusOK,
+			wantDeleted: 2,
+			wantGone:    []string{"GIG00001", "GIG00010"},
+			wantKept:    []string{"GIG00002", "GIG00100"},
+		},
+		{
+			name:        "by pattern",
+			body:        `{"pattern": "GIG0000*"}`,
+			wantStatus:  http.StatusOK,
+			wantDeleted: 2,
+			wantGone:    []string{"GIG00001", "GIG00002"},
+			wantKept:    []string{"GIG00010", "GIG00100"},
+		},
+		{
+			name:       "neither given",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantKept:   []string{"GIG00001", "GIG00002", "GIG00010", "GIG00100"},
+		},
+		{
+			name:       "both given",
+			body:       `{"customer_ids": ["GIG00001"], "pattern": "GIG*"}`,
+			wantStatus: http.StatusBadRequest,
+			wantKept:   []string{"GIG00001", "GIG00002", "GIG00010", "GIG00100"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mr := newCacheTestAdminHandler(t)
+
+			rec := deleteCache(h, "/api/v1/admin/cache/customers", tt.body)
+			require.Equal(t, tt.wantStatus, rec.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				var resp dto.BulkCacheEvictResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.Equal(t, tt.wantDeleted, resp.Deleted)
+			}
+			for _, id := range tt.wantGone {
+				assert.False(t, mr.Exists("customer:"+id), id)
+			}
+			for _, id := range tt.wantKept {
+				assert.True(t, mr.Exists("customer:"+id), id)
+			}
+		})
+	}
+}
+
+func postReconcile(h *AdminHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/customers/reconcile-status", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ReconcileCustomerStatuses(rec, req)
+	return rec
+}
+
+func TestReconcileCustomerStatuses(t *testing.T) {
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(
+		&domain.Customer{ID: "GIG00001", OutstandingBalance: 0, Status: domain.CustomerStatusActive, Version: 1},
+		&domain.Customer{ID: "GIG00002", OutstandingBalance: 700, Status: domain.CustomerStatusCompleted, Version: 1},
+		&domain.Customer{ID: "GIG00003", OutstandingBalance: 700, Status: domain.CustomerStatusDefaulted, Version: 1},
+	)
+	h := NewAdminHandler(service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger), nil, Config{}, logger)
+	body := `{"customer_ids": ["GIG00001", "GIG00002", "GIG00003", "GIG00404"]}`
+
+	rec := postReconcile(h, body)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.ReconcileStatusResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Checked)
+	assert.Equal(t, 2, resp.ChangedCount)
+	assert.Equal(t, []dto.StatusChange{
+		{CustomerID: "GIG00001", From: "ACTIVE", To: "COMPLETED"},
+		{CustomerID: "GIG00002", From: "COMPLETED", To: "ACTIVE"},
+	}, resp.Changed)
+	assert.Equal(t, []string{"GIG00404"}, resp.NotFound)
+
+	// Running it again is a no-op
+	rec = postReconcile(h, body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 0, resp.ChangedCount)
+	assert.Empty(t, resp.Changed)
+}
+
+func TestReconcileCustomerStatuses_RequiresIDs(t *testing.T) {
+	logger := zap.NewNop()
+	h := NewAdminHandler(service.NewPaymentService(newFakeCustomerRepo(), newFakePaymentRepo(), nil, logger), nil, Config{}, logger)
+
+	assert.Equal(t, http.StatusBadRequest, postReconcile(h, `{"customer_ids": []}`).Code)
+}
+
+// newMaintenanceRouter mounts the admin toggle next to a paused POST
+// /payments and an unpaused customer read, as the real router does
+func newMaintenanceRouter(t *testing.T) http.Handler {
+	t.Helper()
+	logger := zap.NewNop()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	maintenance := middleware.NewMaintenanceMode(client, "", time.Minute, time.Minute, logger)
+
+	customers := newFakeCustomerRepo(&domain.Customer{ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1})
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+	cfg := Config{Maintenance: maintenance}
+	payments := NewPaymentHandler(paymentService, cfg, logger)
+	admin := NewAdminHandler(paymentService, nil, cfg, logger)
+
+	r := chi.NewRouter()
+	r.With(maintenance.Middleware).Post("/api/v1/payments", payments.ProcessPayment)
+	r.Get("/api/v1/customers/{customer_id}", payments.GetCustomer)
+	r.Get("/api/v1/admin/maintenance", admin.MaintenanceStatus)
+	r.Put("/api/v1/admin/maintenance", admin.EnableMaintenance)
+	r.Delete("/api/v1/admin/maintenance", admin.DisableMaintenance)
+	return r
+}
+
+func sendJSON(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMaintenanceToggle_PausesPaymentsButNotReads(t *testing.T) {
+	r := newMaintenanceRouter(t)
+	payment := func(ref string) string {
+		return `{"customer_id": "GIG00001", "payment_status": "COMPLETE", "transaction_amount": "100000",
+			"transaction_date": "2025-11-24 14:54:16", "transaction_reference": "` + ref + `"}`
+	}
+
+	require.Equal(t, http.StatusOK, sendJSON(r, http.MethodPost, "/api/v1/payments", payment("TXN1")).Code)
+
+	rec := sendJSON(r, http.MethodPut, "/api/v1/admin/maintenance", `{"reason": "schema migration"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status dto.MaintenanceResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "schema migration", status.Reason)
+	assert.NotNil(t, status.Since)
+
+	rec = sendJSON(r, http.MethodPost, "/api/v1/payments", payment("TXN2"))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	rec = sendJSON(r, http.MethodGet, "/api/v1/customers/GIG00001", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var customer dto.CustomerResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &customer))
+	assert.Equal(t, int64(10000000), customer.TotalPaid, "the paused payment was not applied")
+
+	require.Equal(t, http.StatusOK, sendJSON(r, http.MethodDelete, "/api/v1/admin/maintenance", "").Code)
+	assert.Equal(t, http.StatusOK, sendJSON(r, http.MethodPost, "/api/v1/payments", payment("TXN2")).Code)
+
+	rec = sendJSON(r, http.MethodGet, "/api/v1/admin/maintenance", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.False(t, status.Enabled)
+}
+
+func TestEnableMaintenance_RequiresReason(t *testing.T) {
+	r := newMaintenanceRouter(t)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(r, http.MethodPut, "/api/v1/admin/maintenance", `{"reason": " "}`).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(r, http.MethodPut, "/api/v1/admin/maintenance", `{}`).Code)
+}
+
+func TestMaintenanceEndpoints_NotConfigured(t *testing.T) {
+	logger := zap.NewNop()
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{}, logger)
+
+	rec := httptest.NewRecorder()
+	h.MaintenanceStatus(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/maintenance", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestInspectEvents(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	publisher := messaging.NewRedisEventPublisher(client, "", logger)
+	for _, ref := range []string{"TXN1", "TXN2", "TXN3"} {
+		require.NoError(t, publisher.Publish(ctx, domain.NewPaymentFlaggedEvent("GIG00001", domain.PaymentFlaggedPayload{
+			CustomerID: "GIG00001", TransactionReference: ref, Amount: 5000,
+		}, time.Now())))
+	}
+	stream := "events:" + domain.EventTypePaymentFlagged
+	require.NoError(t, client.XGroupCreateMkStream(ctx, stream, "payment-processors", "0").Err())
+	require.NoError(t, client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "payment-processors", Consumer: "worker-1", Streams: []string{stream, ">"}, Count: 1,
+	}).Err())
+
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil,
+		Config{EventInspector: messaging.NewStreamInspector(client, "")}, logger)
+	r := chi.NewRouter()
+	r.Get("/api/v1/admin/events/{event_type}", h.InspectEvents)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/admin/events/payment.flagged?count=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp dto.EventStreamResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, stream, resp.Stream)
+	require.Len(t, resp.Entries, 2)
+	assert.Equal(t, domain.EventTypePaymentFlagged, resp.Entries[0].Fields["event_type"])
+
+	var event domain.PaymentFlaggedEvent
+	require.NoError(t, json.Unmarshal(resp.Entries[1].Payload, &event))
+	assert.Equal(t, "TXN2", event.Payload.TransactionReference)
+
+	require.Len(t, resp.Pending, 1)
+	assert.Equal(t, resp.Entries[0].ID, resp.Pending[0].ID)
+	assert.Equal(t, "worker-1", resp.Pending[0].Consumer)
+
+	rec = get("/api/v1/admin/events/payment.flagged?from=" + resp.Entries[1].ID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Len(t, resp.Entries, 2)
+	assert.Empty(t, resp.Pending)
+
+	for _, path := range []string{
+		"/api/v1/admin/events/payment.flagged?count=0",
+		"/api/v1/admin/events/payment.flagged?count=lots",
+		"/api/v1/admin/events/payment.flagged?from=yesterday",
+		"/api/v1/admin/events/Payment*",
+	} {
+		assert.Equal(t, http.StatusBadRequest, get(path).Code, path)
+	}
+}
+
+func TestInspectEvents_CapsCount(t *testing.T) {
+	logger := zap.NewNop()
+	inspector := &recordingInspector{}
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{EventInspector: inspector}, logger)
+	r := chi.NewRouter()
+	r.Get("/api/v1/admin/events/{event_type}", h.InspectEvents)
+
+	for query, want := range map[string]int64{"": 50, "?count=10": 10, "?count=100000": 500} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/events/payment.processed"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, want, inspector.query.Count, query)
+	}
+}
+
+type recordingInspector struct {
+	query messaging.StreamQuery
+}
+
+func (i *recordingInspector) Inspect(ctx context.Context, eventType string, query messaging.StreamQuery) (*messaging.StreamSnapshot, error) {
+	i.query = query
+	return &messaging.StreamSnapshot{}, nil
+}
+
+func TestClaimEvents_RequeuesADeadWorkersMessages(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	mr := miniredis.RunT(t)
+	start := time.Now()
+	mr.SetTime(start)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	publisher := messaging.NewRedisEventPublisher(client, "", logger)
+	for _, ref := range []string{"TXN1", "TXN2"} {
+		require.NoError(t, publisher.Publish(ctx, domain.NewPaymentFlaggedEvent("GIG00001", domain.PaymentFlaggedPayload{
+			CustomerID: "GIG00001", TransactionReference: ref, Amount: 5000,
+		}, start)))
+	}
+	stream := "events:" + domain.EventTypePaymentFlagged
+	require.NoError(t, client.XGroupCreateMkStream(ctx, stream, "payment-processors", "0").Err())
+	require.NoError(t, client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "payment-processors", Consumer: "dead-worker", Streams: []string{stream, ">"}, Count: 10,
+	}).Err())
+
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{
+		EventReclaimer:    messaging.NewStreamReclaimer(client, ""),
+		EventClaimMinIdle: 5 * time.Minute,
+	}, logger)
+	r := chi.NewRouter()
+	r.Post("/api/v1/admin/events/{event_type}/claim", h.ClaimEvents)
+	claim := func(path string) (*httptest.ResponseRecorder, dto.EventClaimResponse) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		var resp dto.EventClaimResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec, resp
+	}
+
+	// Under the configured minimum idle time nothing is taken
+	mr.SetTime(start.Add(time.Minute))
+	rec, resp := claim("/api/v1/admin/events/payment.flagged/claim")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Zero(t, resp.Claimed)
+
+	rec, resp = claim("/api/v1/admin/events/payment.flagged/claim?min_idle=30s")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, stream, resp.Stream)
+	assert.Equal(t, 2, resp.Claimed)
+	require.Len(t, resp.Requeued, 2)
+	assert.Empty(t, resp.Deleted)
+
+	pending, err := client.XPending(ctx, stream, "payment-processors").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+	copies, err := client.XRange(ctx, stream, resp.Requeued[0].NewID, resp.Requeued[0].NewID).Result()
+	require.NoError(t, err)
+	require.Len(t, copies, 1)
+	assert.Equal(t, resp.Requeued[0].ID, copies[0].Values["reclaimed_from"])
+
+	for _, path := range []string{
+		"/api/v1/admin/events/payment.flagged/claim?min_idle=soon",
+		"/api/v1/admin/events/payment.flagged/claim?min_idle=-1m",
+		"/api/v1/admin/events/payment.flagged/claim?count=0",
+		"/api/v1/admin/events/Payment*/claim",
+	} {
+		rec, _ := claim(path)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+	}
+}
+
+func TestClaimEvents_DefaultsAndCaps(t *testing.T) {
+	logger := zap.NewNop()
+	reclaimer := &recordingReclaimer{}
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil,
+		Config{EventReclaimer: reclaimer, EventClaimMinIdle: 5 * time.Minute}, logger)
+	r := chi.NewRouter()
+	r.Post("/api/v1/admin/events/{event_type}/claim", h.ClaimEvents)
+
+	for query, want := range map[string]recordingReclaimer{
+		"":                          {minIdle: 5 * time.Minute, count: 100},
+		"?min_idle=0s&count=10":     {minIdle: 0, count: 10},
+		"?min_idle=1h&count=100000": {minIdle: time.Hour, count: 1000},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/events/payment.processed/claim"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, want, *reclaimer, query)
+	}
+
+	unconfigured := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{}, logger)
+	rec := httptest.NewRecorder()
+	unconfigured.ClaimEvents(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/events/payment.processed/claim", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+type recordingReclaimer struct {
+	minIdle time.Duration
+	count   int64
+}
+
+func (c *recordingReclaimer) Claim(ctx context.Context, eventType string, minIdle time.Duration, count int64) (*messaging.ClaimResult, error) {
+	c.minIdle, c.count = minIdle, count
+	return &messaging.ClaimResult{}, nil
+}
+
+func TestReadEventLog(t *testing.T) {
+	logger := zap.NewNop()
+	log := &pagedEventLog{}
+	for seq := int64(1); seq <= 3; seq++ {
+		log.events = append(log.events, &domain.LoggedEvent{
+			Sequence:  seq,
+			EventID:   fmt.Sprintf("event-%d", seq),
+			EventType: domain.EventTypePaymentProcessed,
+			Payload:   []byte(`{"event_id":"event"}`),
+		})
+	}
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{EventLog: log}, logger)
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ReadEventLog(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/events/log"+query, nil))
+		return rec
+	}
+
+	rec := get("?after_seq=1&limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp dto.EventLogResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Events, 1)
+	assert.Equal(t, int64(2), resp.Events[0].Sequence)
+	assert.JSONEq(t, `{"event_id":"event"}`, string(resp.Events[0].Payload))
+	assert.Equal(t, int64(2), resp.NextAfterSeq)
+
+	rec = get("?after_seq=3")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Events)
+	assert.Equal(t, int64(3), resp.NextAfterSeq, "nothing new keeps the position")
+
+	get("?limit=100000")
+	assert.Equal(t, 1000, log.limit)
+	assert.WithinDuration(t, time.Now().Add(-service.DefaultFeedSettleWindow), log.until, time.Second,
+		"events newer than the settle window are held back")
+
+	for _, query := range []string{"?after_seq=-1", "?after_seq=x", "?limit=0"} {
+		assert.Equal(t, http.StatusBadRequest, get(query).Code, query)
+	}
+
+	h = NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{}, logger)
+	assert.Equal(t, http.StatusNotFound, get("").Code)
+}
+
+type pagedEventLog struct {
+	events []*domain.LoggedEvent
+	limit  int
+	until  time.Time
+}
+
+func (l *pagedEventLog) Append(ctx context.Context, event domain.DomainEvent) (int64, error) {
+	return 0, nil
+}
+
+func (l *pagedEventLog) After(ctx context.Context, afterSeq int64, until time.Time, limit int) ([]*domain.LoggedEvent, error) {
+	l.limit = limit
+	l.until = until
+	var page []*domain.LoggedEvent
+	for _, event := range l.events {
+		if event.Sequence > afterSeq && len(page) < limit {
+			page = append(page, event)
+		}
+	}
+	return page, nil
+}
+
+func TestListWorkers(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	heartbeats := messaging.NewWorkerHeartbeats(client, "", 30*time.Second)
+
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{Workers: heartbeats}, logger)
+	list := func() (int, dto.WorkersResponse) {
+		rec := httptest.NewRecorder()
+		h.ListWorkers(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/workers", nil))
+		var resp dto.WorkersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	t.Run("no workers", func(t *testing.T) {
+		status, resp := list()
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.False(t, resp.Alive)
+		assert.Zero(t, resp.Count)
+		assert.NotNil(t, resp.Workers)
+	})
+
+	t.Run("worker alive", func(t *testing.T) {
+		beat := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+		require.NoError(t, heartbeats.Beat(ctx, messaging.WorkerStatus{Consumer: "worker-host-1", StartedAt: beat.Add(-time.Hour), BeatAt: beat}))
+
+		status, resp := list()
+		assert.Equal(t, http.StatusOK, status)
+		assert.True(t, resp.Alive)
+		require.Len(t, resp.Workers, 1)
+		assert.Equal(t, "worker-host-1", resp.Workers[0].Consumer)
+		assert.True(t, beat.Equal(resp.Workers[0].LastBeatAt))
+		assert.Equal(t, int64(30000), resp.Workers[0].ExpiresInMs)
+	})
+
+	t.Run("worker stopped beating", func(t *testing.T) {
+		mr.FastForward(31 * time.Second)
+		status, resp := list()
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.False(t, resp.Alive)
+	})
+}
+
+func TestListWorkers_NotConfigured(t *testing.T) {
+	logger := zap.NewNop()
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{}, logger)
+	rec := httptest.NewRecorder()
+	h.ListWorkers(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/workers", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestFeatureFlagEndpoints(t *testing.T) {
+	logger := zap.NewNop()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	flags := redisrepository.NewRedisFeatureFlags(client, "", time.Second, logger)
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{FeatureFlags: flags}, logger)
+
+	r := chi.NewRouter()
+	r.Get("/flags", h.ListFeatureFlags)
+	r.Get("/flags/{name}", h.GetFeatureFlag)
+	r.Put("/flags/{name}", h.SetFeatureFlag)
+	r.Delete("/flags/{name}", h.DeleteFeatureFlag)
+
+	rec := sendJSON(r, http.MethodPut, "/flags/velocity_check", `{"enabled": true, "rollout_percent": 10}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = sendJSON(r, http.MethodPut, "/flags/sync_event_publishing", `{"enabled": false}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = sendJSON(r, http.MethodGet, "/flags/velocity_check", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var flag dto.FeatureFlagResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flag))
+	assert.True(t, flag.Enabled)
+	assert.Equal(t, 10, flag.RolloutPercent)
+	assert.False(t, flag.UpdatedAt.IsZero())
+
+	rec = sendJSON(r, http.MethodGet, "/flags", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list dto.FeatureFlagsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Flags, 2)
+	assert.Equal(t, "sync_event_publishing", list.Flags[0].Name)
+	assert.Equal(t, 100, list.Flags[0].RolloutPercent, "a flag without a percentage is a plain switch")
+
+	for _, tc := range []struct {
+		path, body string
+	}{
+		{"/flags/velocity_check", `{"rollout_percent": 10}`},
+		{"/flags/velocity_check", `{"enabled": true, "rollout_percent": 101}`},
+		{"/flags/Velocity-Check", `{"enabled": true}`},
+	} {
+		rec = sendJSON(r, http.MethodPut, tc.path, tc.body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tc.path+" "+tc.body)
+	}
+
+	rec = sendJSON(r, http.MethodDelete, "/flags/velocity_check", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = sendJSON(r, http.MethodGet, "/flags/velocity_check", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = sendJSON(r, http.MethodDelete, "/flags/velocity_check", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestFeatureFlagEndpoints_NotConfigured(t *testing.T) {
+	logger := zap.NewNop()
+	h := NewAdminHandler(service.NewPaymentService(nil, nil, nil, logger), nil, Config{}, logger)
+
+	rec := httptest.NewRecorder()
+	h.ListFeatureFlags(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/flags", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"go.uber.org/zap"
+)
+
+// CollectionsHandler serves the collections team's arrears worklist
+type CollectionsHandler struct {
+	collections *service.CollectionsService
+	config      Config
+	logger      *zap.Logger
+}
+
+func NewCollectionsHandler(collections *service.CollectionsService, cfg Config, logger *zap.Logger) *CollectionsHandler {
+	return &CollectionsHandler{
+		collections: collections,
+		config:      cfg,
+		logger:      logger,
+	}
+}
+
+// Worklist lists customers in arrears, largest first. The status query
+// parameter selects DEFAULTED (default), AT_RISK or ALL.
+func (h *CollectionsHandler) Worklist(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := service.CollectionsQuery{
+		Status: strings.ToUpper(strings.TrimSpace(query.Get("status"))),
+	}
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		q.Page = p
+	}
+	if ps, err := strconv.Atoi(query.Get("page_size")); err == nil && ps > 0 {
+		q.PageSize = ps
+	}
+
+	result, err := h.collections.Worklist(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCollectionsFilter) {
+			respondError(w, http.StatusBadRequest, "invalid status", err)
+			return
+		}
+		logFailure(h.logger, "failed to build collections worklist", err)
+		respondError(w, failureStatus(err), "failed to build collections worklist", err)
+		return
+	}
+
+	response := dto.CollectionsResponse{
+		Customers:    make([]dto.CollectionsEntry, len(result.Entries)),
+		TotalArrears: result.TotalArrears,
+		Pagination: dto.Pagination{
+			Page:       result.Page,
+			PageSize:   result.PageSize,
+			TotalCount: result.TotalCount,
+			TotalPages: result.TotalPages,
+		},
+	}
+	for i, entry := range result.Entries {
+		row := dto.CollectionsEntry{
+			CustomerID:         entry.Customer.ID,
+			Status:             string(entry.Customer.Status),
+			OutstandingBalance: entry.Customer.OutstandingBalance,
+			TotalPaid:          entry.Customer.TotalPaid,
+			Arrears:            entry.Arrears,
+			DaysOverdue:        entry.DaysOverdue,
+		}
+		if entry.Customer.LastPaymentDate != nil {
+			row.LastPaymentDate = formatTime(*entry.Customer.LastPaymentDate, h.config.location())
+		}
+		response.Customers[i] = row
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// behindCustomer owes 10,000 kobo at 1,000 a week and has paid nothing
+func behindCustomer(id string, status domain.CustomerStatus, weeksAgo int) *domain.Customer {
+	lastPayment := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	return &domain.Customer{
+		ID:                 id,
+		AssetValue:         10000,
+		RepaymentTermWeeks: 10,
+		OutstandingBalance: 10000,
+		DeploymentDate:     time.Now().Add(-time.Duration(weeksAgo)*7*24*time.Hour - time.Hour),
+		LastPaymentDate:    &lastPayment,
+		Status:             status,
+	}
+}
+
+func getCollections(h *CollectionsHandler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/collections?"+query, nil)
+	rec := httptest.NewRecorder()
+	h.Worklist(rec, req)
+	return rec
+}
+
+func TestCollectionsWorklist(t *testing.T) {
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(
+		behindCustomer("GIG00001", domain.CustomerStatusDefaulted, 2),
+		behindCustomer("GIG00002", domain.CustomerStatusDefaulted, 5),
+		behindCustomer("GIG00003", domain.CustomerStatusActive, 3),
+	)
+	h := NewCollectionsHandler(service.NewCollectionsService(customers, logger), Config{}, logger)
+
+	rec := getCollections(h, "page_size=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.CollectionsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Customers, 1)
+	assert.Equal(t, "GIG00002", resp.Customers[0].CustomerID)
+	assert.Equal(t, int64(5000), resp.Customers[0].Arrears)
+	assert.Equal(t, 28, resp.Customers[0].DaysOverdue)
+	assert.Equal(t, "2025-01-02T10:00:00Z", resp.Customers[0].LastPaymentDate)
+	assert.Equal(t, int64(7000), resp.TotalArrears)
+	assert.Equal(t, int64(2), resp.Pagination.TotalCount)
+	assert.Equal(t, 2, resp.Pagination.TotalPages)
+
+	rec = getCollections(h, "status=at_risk")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Customers, 1)
+	assert.Equal(t, "GIG00003", resp.Customers[0].CustomerID)
+
+	rec = getCollections(h, "status=COMPLETED")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,17 @@
+package handler
+
+import "time"
+
+// location is the zone wall-clock dates in requests are read in, and the
+// zone dates in responses are written in
+func (c Config) location() *time.Location {
+	if c.Timezone == nil {
+		return time.UTC
+	}
+	return c.Timezone
+}
+
+// formatTime writes t as RFC 3339 in loc
+func formatTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/buildinfo"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+// DebugHandler serves build and runtime details for support. Routes are
+// only mounted when enabled and always sit behind admin auth.
+type DebugHandler struct {
+	startedAt time.Time
+}
+
+func NewDebugHandler(startedAt time.Time) *DebugHandler {
+	return &DebugHandler{startedAt: startedAt}
+}
+
+// Info reports which build is running and basic runtime health
+func (h *DebugHandler) Info(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, dto.DebugInfoResponse{
+		Version:       buildinfo.Version,
+		Commit:        buildinfo.Commit,
+		BuildDate:     buildinfo.Date,
+		GoVersion:     runtime.Version(),
+		Goroutines:    runtime.NumGoroutine(),
+		StartedAt:     h.startedAt.UTC(),
+		UptimeSeconds: int64(time.Since(h.startedAt) / time.Second),
+	})
+}
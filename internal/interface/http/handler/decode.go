@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// bodyError is a client-facing decode failure. Error() is the precise
+// top-level message; the wrapped error carries the detail.
+type bodyError struct {
+	msg string
+	err error
+}
+
+func (e *bodyError) Error() string { return e.msg }
+func (e *bodyError) Unwrap() error { return e.err }
+
+func isJSONContentType(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// decodeJSONBody decodes exactly one JSON object from the request body,
+// rejecting malformed input, unknown fields (when asked) and trailing data.
+func decodeJSONBody(r *http.Request, dst interface{}, disallowUnknownFields bool) error {
+	decoder := json.NewDecoder(r.Body)
+	if disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(dst); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.Is(err, io.EOF):
+			return &bodyError{msg: "request body must not be empty"}
+		case errors.As(err, &typeErr) && typeErr.Field == "":
+			// Errors from custom unmarshalers don't always carry the field
+			return &bodyError{msg: "request body has a field of the wrong type", err: err}
+		case errors.As(err, &typeErr):
+			return &bodyError{msg: "request body has a field of the wrong type", err: fmt.Errorf("field %q must be a %s", typeErr.Field, typeErr.Type)}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return &bodyError{msg: "request body contains an unknown field", err: err}
+		default:
+			return &bodyError{msg: "request body is not valid JSON", err: err}
+		}
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return &bodyError{msg: "request body contains extra data after the JSON object"}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+// EnumsHandler lists the values enumerated fields can take, so clients can
+// validate against them or generate types instead of hard-coding strings
+type EnumsHandler struct {
+	response dto.EnumsResponse
+}
+
+func NewEnumsHandler() *EnumsHandler {
+	return &EnumsHandler{response: dto.EnumsResponse{
+		CustomerStatuses:     enumStrings(domain.CustomerStatuses()),
+		PaymentStatuses:      enumStrings(domain.PaymentStatuses()),
+		InstallmentStandings: enumStrings(domain.InstallmentStandings()),
+		PaymentOutcomes:      enumStrings(service.PaymentOutcomes()),
+		PaymentReasons:       service.PaymentReasons(),
+		ErrorCodes:           dto.ErrorCodes(),
+	}}
+}
+
+// List serves every enum
+func (h *EnumsHandler) List(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.response)
+}
+
+func enumStrings[T ~string](values []T) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
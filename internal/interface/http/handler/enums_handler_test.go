@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// declaredConstants returns the string value of every constant declared in
+// dir's non-test files that match says to keep, given its name and the
+// name of its type ("" when untyped)
+func declaredConstants(t *testing.T, dir string, match func(name, typ string) bool) []string {
+	t.Helper()
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+
+	var values []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					vs := spec.(*ast.ValueSpec)
+					typ := ""
+					if ident, ok := vs.Type.(*ast.Ident); ok {
+						typ = ident.Name
+					}
+					for i, name := range vs.Names {
+						if i >= len(vs.Values) || !match(name.Name, typ) {
+							continue
+						}
+						lit, ok := vs.Values[i].(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							continue
+						}
+						value, err := strconv.Unquote(lit.Value)
+						require.NoError(t, err)
+						values = append(values, value)
+					}
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, values, "no constants matched in %s", dir)
+	return values
+}
+
+func ofType(want string) func(name, typ string) bool {
+	return func(_, typ string) bool { return typ == want }
+}
+
+func namedLike(prefix string) func(name, typ string) bool {
+	return func(name, _ string) bool { return strings.HasPrefix(name, prefix) }
+}
+
+func TestEnums_ListsEveryDeclaredConstant(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewEnumsHandler().List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/enums", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp dto.EnumsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	tests := []struct {
+		name   string
+		dir    string
+		match  func(name, typ string) bool
+		listed []string
+	}{
+		{"customer statuses", "../../../domain", ofType("CustomerStatus"), resp.CustomerStatuses},
+		{"payment statuses", "../../../domain", ofType("PaymentStatus"), resp.PaymentStatuses},
+		{"installment standings", "../../../domain", ofType("InstallmentStanding"), resp.InstallmentStandings},
+		{"payment outcomes", "../../../application/service", ofType("PaymentOutcome"), resp.PaymentOutcomes},
+		{"payment reasons", "../../../application/service", namedLike("Reason"), resp.PaymentReasons},
+		{"error codes", "../dto", namedLike("ErrorCode"), resp.ErrorCodes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A constant missing here was declared without adding it to its list
+			assert.ElementsMatch(t, declaredConstants(t, tt.dir, tt.match), tt.listed)
+		})
+	}
+}
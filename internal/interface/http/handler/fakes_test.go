@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+var errFakeNotFound = errors.New("not found")
+
+// fakeCustomerRepo is a minimal in-memory domain.CustomerRepository
+type fakeCustomerRepo struct {
+	mu        sync.Mutex
+	customers map[string]*domain.Customer
+}
+
+func newFakeCustomerRepo(customers ...*domain.Customer) *fakeCustomerRepo {
+	repo := &fakeCustomerRepo{customers: make(map[string]*domain.Customer)}
+	for _, c := range customers {
+		repo.customers[c.ID] = c
+	}
+	return repo
+}
+
+func (r *fakeCustomerRepo) FindByID(ctx context.Context, customerID string) (*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.customers[customerID]
+	if !ok {
+		return nil, domain.ErrCustomerNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (r *fakeCustomerRepo) FindByIDs(ctx context.Context, customerIDs []string) (map[string]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := make(map[string]*domain.Customer)
+	for _, id := range customerIDs {
+		if c, ok := r.customers[id]; ok {
+			copied := *c
+			found[id] = &copied
+		}
+	}
+	return found, nil
+}
+
+// FindByStatus pages customers in ID order
+func (r *fakeCustomerRepo) FindByStatus(ctx context.Context, status string, limit, offset int) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []*domain.Customer
+	for _, c := range r.customers {
+		if string(c.Status) == status {
+			copied := *c
+			matched = append(matched, &copied)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	if offset >= len(matched) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[offset:end], nil
+}
+
+func (r *fakeCustomerRepo) Save(ctx context.Context, customer *domain.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.customers[customer.ID]
+	if !ok {
+		return errFakeNotFound
+	}
+	if current.Version != customer.Version {
+		return domain.ErrOptimisticLock
+	}
+	customer.Version++
+	copied := *customer
+	r.customers[customer.ID] = &copied
+	return nil
+}
+
+func (r *fakeCustomerRepo) UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error {
+	return errors.New("not implemented")
+}
+
+// fakePaymentRepo is a minimal in-memory domain.PaymentRepository whose
+// references are unique within scope
+type fakePaymentRepo struct {
+	mu       sync.Mutex
+	scope    domain.DedupScope
+	payments map[string]*domain.Payment
+}
+
+func newFakePaymentRepo(payments ...*domain.Payment) *fakePaymentRepo {
+	return newScopedFakePaymentRepo(domain.DedupScopeGlobal, payments...)
+}
+
+func newScopedFakePaymentRepo(scope domain.DedupScope, payments ...*domain.Payment) *fakePaymentRepo {
+	repo := &fakePaymentRepo{scope: scope, payments: make(map[string]*domain.Payment)}
+	for _, p := range payments {
+		repo.payments[repo.key(p.CustomerID, p.TransactionReference)] = p
+	}
+	return repo
+}
+
+func (r *fakePaymentRepo) key(customerID, txRef string) string {
+	if r.scope.PerCustomer() {
+		return customerID + "\x00" + txRef
+	}
+	return txRef
+}
+
+func (r *fakePaymentRepo) Save(ctx context.Context, payment *domain.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := r.key(payment.CustomerID, payment.TransactionReference)
+	if _, ok := r.payments[key]; ok {
+		return domain.ErrDuplicateTransaction
+	}
+	if payment.ID == "" {
+		payment.ID = "payment-" + payment.TransactionReference
+	}
+	copied := *payment
+	r.payments[key] = &copied
+	return nil
+}
+
+func (r *fakePaymentRepo) FindByTransactionReference(ctx context.Context, customerID, txRef string) (*domain.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.payments[r.key(customerID, txRef)]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+	copied := *p
+	return &copied, nil
+}
+
+func (r *fakePaymentRepo) ExistsByTransactionReference(ctx context.Context, customerID, txRef string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.payments[r.key(customerID, txRef)]
+	return ok, nil
+}
+
+// byCustomer returns the customer's payments newest first
+func (r *fakePaymentRepo) byCustomer(customerID string) []*domain.Payment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var payments []*domain.Payment
+	for _, p := range r.payments {
+		if p.CustomerID == customerID {
+			copied := *p
+			payments = append(payments, &copied)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].TransactionDate.After(payments[j].TransactionDate)
+	})
+	return payments
+}
+
+func (r *fakePaymentRepo) FindByCustomerID(ctx context.Context, customerID string) ([]*domain.Payment, error) {
+	return r.byCustomer(customerID), nil
+}
+
+func (r *fakePaymentRepo) FindByCustomerIDFiltered(ctx context.Context, customerID string, filter domain.PaymentFilter) ([]*domain.Payment, error) {
+	payments := []*domain.Payment{}
+	for _, p := range r.byCustomer(customerID) {
+		if filter.Matches(p) {
+			payments = append(payments, p)
+		}
+	}
+	if filter.Order == domain.PaymentOrderAsc {
+		slices.Reverse(payments)
+	}
+	return payments, nil
+}
+
+func (r *fakePaymentRepo) FindByCustomerIDWithPagination(ctx context.Context, customerID string, limit, offset int) ([]*domain.Payment, error) {
+	payments := r.byCustomer(customerID)
+	if offset >= len(payments) {
+		return []*domain.Payment{}, nil
+	}
+	end := offset + limit
+	if end > len(payments) {
+		end = len(payments)
+	}
+	return payments[offset:end], nil
+}
+
+func (r *fakePaymentRepo) CountByCustomerID(ctx context.Context, customerID string) (int64, error) {
+	return int64(len(r.byCustomer(customerID))), nil
+}
+
+func (r *fakePaymentRepo) FindByCustomerIDAfter(ctx context.Context, customerID string, filter domain.PaymentFilter, afterDate time.Time, afterID string, limit int) ([]*domain.Payment, error) {
+	descending := filter.OrderOr(domain.PaymentOrderAsc) == domain.PaymentOrderDesc
+	payments := r.byCustomer(customerID)
+	sort.Slice(payments, func(i, j int) bool {
+		if payments[i].TransactionDate.Equal(payments[j].TransactionDate) {
+			return (payments[i].ID < payments[j].ID) != descending
+		}
+		return payments[i].TransactionDate.Before(payments[j].TransactionDate) != descending
+	})
+	start := afterDate.IsZero() && afterID == ""
+	var page []*domain.Payment
+	for _, p := range payments {
+		if !filter.Matches(p) {
+			continue
+		}
+		past := p.TransactionDate.After(afterDate) || (p.TransactionDate.Equal(afterDate) && p.ID > afterID)
+		if descending {
+			past = p.TransactionDate.Before(afterDate) || (p.TransactionDate.Equal(afterDate) && p.ID < afterID)
+		}
+		if start || past {
+			page = append(page, p)
+			if len(page) == limit {
+				break
+			}
+		}
+	}
+	return page, nil
+}
+
+// inDateRange returns matching payments newest first unless filter says
+// otherwise
+func (r *fakePaymentRepo) inDateRange(from, to time.Time, customerID string, filter domain.PaymentFilter) []*domain.Payment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var payments []*domain.Payment
+	for _, p := range r.payments {
+		if p.TransactionDate.Before(from) || p.TransactionDate.After(to) {
+			continue
+		}
+		if (customerID != "" && p.CustomerID != customerID) || !filter.Matches(p) {
+			continue
+		}
+		copied := *p
+		payments = append(payments, &copied)
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].TransactionDate.After(payments[j].TransactionDate)
+	})
+	if filter.Order == domain.PaymentOrderAsc {
+		slices.Reverse(payments)
+	}
+	return payments
+}
+
+func (r *fakePaymentRepo) FindByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, error) {
+	payments := r.inDateRange(from, to, customerID, filter)
+	if offset >= len(payments) {
+		return []*domain.Payment{}, nil
+	}
+	end := offset + limit
+	if end > len(payments) {
+		end = len(payments)
+	}
+	return payments[offset:end], nil
+}
+
+func (r *fakePaymentRepo) TotalsByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter) (domain.PaymentTotals, error) {
+	var totals domain.PaymentTotals
+	for _, p := range r.inDateRange(from, to, customerID, filter) {
+		totals.Count++
+		totals.Amount += p.Amount
+	}
+	return totals, nil
+}
@@ -1,19 +1,151 @@
 package handler
 
 import (
+	"context"
+	"time"
+
 	"github.com/gigmile/payment-service/internal/application/service"
 	"github.com/gigmile/payment-service/internal/domain"
 	sqlrepository "github.com/gigmile/payment-service/internal/infrastructure/repository/mysql"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
 	"go.uber.org/zap"
 )
 
 type Handlers struct {
-	Payment *PaymentHandler
+	Payment     *PaymentHandler
+	PaymentV2   *PaymentHandlerV2
+	Admin       *AdminHandler
+	Debug       *DebugHandler
+	Enums       *EnumsHandler
+	Collections *CollectionsHandler
+	PaymentFeed *PaymentFeedHandler
+
+	paymentService *service.PaymentService
+}
+
+// WaitForEvents blocks until events published while serving requests have
+// been sent, or ctx is done. Call it after the HTTP server has drained.
+func (h *Handlers) WaitForEvents(ctx context.Context) error {
+	return h.paymentService.WaitForPublishes(ctx)
 }
 
-func NewHandlers(repos *sqlrepository.Repositories, eventPublisher domain.EventPublisher, logger *zap.Logger) *Handlers {
-	paymentService := service.NewPaymentService(repos.Customer, repos.Payment, eventPublisher, logger)
+// Config holds tunables for the HTTP handlers
+type Config struct {
+	DisallowUnknownFields bool
+	MinimumPaymentAmount  int64
+	SlowPaymentThreshold  time.Duration
+	TxRefRule             service.TransactionReferenceRule
+	OverpaymentPolicy     service.OverpaymentPolicy
+	// DedupScope is what transaction references are unique within, for
+	// catching references repeated within one CSV upload
+	DedupScope domain.DedupScope
+	// OverpaymentLimit rejects payments far above the outstanding balance
+	OverpaymentLimit domain.OverpaymentLimit
+	// EarlyPayoffDiscountBPS is taken off the balance in payoff quotes, in
+	// basis points
+	PayoffDiscountBPS int64
+	// CustomerIDFormat rejects malformed customer IDs in routes with 400;
+	// nil only checks length and characters
+	CustomerIDFormat *dto.CustomerIDFormat
+	// Timezone is the zone wall-clock transaction dates and date range
+	// bounds are read in, and response dates are written in; nil is UTC
+	Timezone *time.Location
+	// DefaultCurrency is the currency of payment requests that don't name
+	// one; the zero value is domain.DefaultCurrency
+	DefaultCurrency domain.Currency
+	// CurrencySymbol prefixes formatted DefaultCurrency amounts in
+	// responses; other currencies, or an empty symbol, use the currency's own
+	CurrencySymbol string
+	// MaxBatchCustomerIDs caps the IDs accepted by POST /customers/batch
+	MaxBatchCustomerIDs int
+	// MaxPaymentDateRange caps from..to on GET /payments; zero is uncapped
+	MaxPaymentDateRange time.Duration
+	// PageSizes are the default and maximum page size of every paginated
+	// listing; the zero value keeps each listing's own
+	PageSizes service.PageSizes
+	// FeedSettleWindow holds payments back from GET /payments/stream until
+	// they are this old; zero uses service.DefaultFeedSettleWindow
+	FeedSettleWindow time.Duration
+	// DefaultThreshold is the missed installments that mark a customer
+	// DEFAULTED; zero disables default tracking
+	DefaultThreshold int
+	// ViewInvalidator is told about every customer write so cached GET
+	// responses are dropped; nil when response caching is off
+	ViewInvalidator service.CustomerViewInvalidator
+	// Maintenance backs the admin maintenance toggle; nil answers 404
+	Maintenance MaintenanceSwitch
+	// EventInspector backs the admin event stream view; nil answers 404
+	EventInspector EventInspector
+	// EventReclaimer backs the admin claim of stuck stream messages, which
+	// must have been pending EventClaimMinIdle unless the request says
+	// otherwise; nil answers 404
+	EventReclaimer    EventReclaimer
+	EventClaimMinIdle time.Duration
+	// EventLog keeps every event the payment service publishes and backs
+	// the admin event log view; nil logs nothing and answers 404
+	EventLog domain.EventLog
+	// EventLogSettleWindow holds events back from the admin event log view
+	// until they are this old; zero uses service.DefaultFeedSettleWindow
+	EventLogSettleWindow time.Duration
+	// FeatureFlags backs the admin flag routes and narrows rollouts in the
+	// payment service; nil answers 404 and leaves behaviours as configured
+	FeatureFlags domain.FeatureFlagStore
+	// Workers backs the admin worker liveness view; nil answers 404
+	Workers WorkerRegistry
+	// OutcomeLog records duplicate and failed payments for the daily report
+	OutcomeLog domain.PaymentOutcomeLog
+	// VelocityTracker enables fraud flagging against VelocityLimits; nil disables it
+	VelocityTracker domain.VelocityTracker
+	VelocityLimits  service.VelocityLimits
+	// UploadConcurrency bounds the rows of a CSV upload processed at once
+	// and MaxUploadBytes caps its size; zero uses the defaults
+	UploadConcurrency int
+	MaxUploadBytes    int64
+	// SyncEventPublishing publishes events before responding and fails the
+	// request when publishing fails
+	SyncEventPublishing bool
+	// EventMoneyAsStrings publishes event amounts as decimal strings
+	EventMoneyAsStrings bool
+	// AtomicApplier applies COMPLETE payments in one step instead of the
+	// optimistic-lock retry; nil keeps the usual path
+	AtomicApplier domain.AtomicPaymentApplier
+}
+
+func NewHandlers(repos *sqlrepository.Repositories, eventPublisher domain.EventPublisher, cfg Config, logger *zap.Logger) *Handlers {
+	paymentService := service.NewPaymentService(repos.Customer, repos.Payment, eventPublisher, logger,
+		service.WithMinimumPaymentAmount(cfg.MinimumPaymentAmount),
+		service.WithOverpaymentLimit(cfg.OverpaymentLimit),
+		service.WithEarlyPayoffDiscount(cfg.PayoffDiscountBPS),
+		service.WithSlowPaymentThreshold(cfg.SlowPaymentThreshold),
+		service.WithTransactionReferenceRule(cfg.TxRefRule),
+		service.WithVelocityCheck(cfg.VelocityTracker, cfg.VelocityLimits),
+		service.WithMaxPaymentDateRange(cfg.MaxPaymentDateRange),
+		service.WithPageSizes(cfg.PageSizes),
+		service.WithDefaultThreshold(cfg.DefaultThreshold),
+		service.WithCustomerViewInvalidator(cfg.ViewInvalidator),
+		service.WithOutcomeLog(cfg.OutcomeLog),
+		service.WithOverpaymentPolicy(cfg.OverpaymentPolicy, repos.Credits, repos.OtherLoans),
+		service.WithSyncPublishing(cfg.SyncEventPublishing),
+		service.WithMoneyAsStrings(cfg.EventMoneyAsStrings),
+		service.WithUncachedCustomerFinder(repos.UncachedCustomers),
+		service.WithPaymentPager(repos.PaymentPager),
+		service.WithTransactor(repos),
+		service.WithEventLog(cfg.EventLog),
+		service.WithPaymentWeekTotaler(repos.PaymentWeeks),
+		service.WithFeatureFlags(cfg.FeatureFlags),
+		service.WithAtomicApply(cfg.AtomicApplier),
+	)
+	collections := service.NewCollectionsService(repos.CustomerLister, logger, service.WithWorklistPageSizes(cfg.PageSizes))
 	return &Handlers{
-		Payment: NewPaymentHandler(paymentService, logger),
+		Payment:   NewPaymentHandler(paymentService, cfg, logger),
+		PaymentV2: NewPaymentHandlerV2(paymentService, cfg, logger),
+		Admin:     NewAdminHandler(paymentService, repos.CustomerCache, cfg, logger),
+		Debug:     NewDebugHandler(time.Now()),
+		Enums:     NewEnumsHandler(),
+
+		Collections: NewCollectionsHandler(collections, cfg, logger),
+		PaymentFeed: NewPaymentFeedHandler(service.NewPaymentFeedService(repos.PaymentFeed, cfg.FeedSettleWindow, logger), cfg, logger),
+
+		paymentService: paymentService,
 	}
 }
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+// paymentCurrency is the currency of payment requests that don't name one
+func (c Config) paymentCurrency() domain.Currency {
+	if c.DefaultCurrency.Code == "" {
+		return domain.DefaultCurrency
+	}
+	return c.DefaultCurrency
+}
+
+// requestCurrency is the currency a payment request is in: the one it
+// names, which Validate has checked, or paymentCurrency
+func (c Config) requestCurrency(req *dto.PaymentRequest) domain.Currency {
+	if currency, err := domain.LookupCurrency(req.Currency); err == nil {
+		return currency
+	}
+	return c.paymentCurrency()
+}
+
+// moneyFormat renders amounts for display in the currency they are held in
+type moneyFormat struct {
+	defaultCode string
+	// defaultSymbol, when set, replaces the default currency's own symbol
+	defaultSymbol string
+}
+
+func (c Config) moneyFormat() moneyFormat {
+	return moneyFormat{defaultCode: c.paymentCurrency().Code, defaultSymbol: c.CurrencySymbol}
+}
+
+func (f moneyFormat) symbol(currency domain.Currency) string {
+	if f.defaultSymbol != "" && currency.Code == f.defaultCode {
+		return f.defaultSymbol
+	}
+	return currency.Symbol
+}
+
+func (f moneyFormat) format(amount int64, currency domain.Currency) string {
+	return dto.FormatMinor(amount, currency.Exponent, f.symbol(currency))
+}
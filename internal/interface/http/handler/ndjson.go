@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// defaultStreamPageSize is how many payments are read from the repository
+// per flush when streaming NDJSON
+const defaultStreamPageSize = 500
+
+// wantsNDJSON reports whether the client listed application/x-ndjson in
+// its Accept header. Anything else gets the regular JSON response.
+func wantsNDJSON(r *http.Request) bool {
+	return acceptsMediaType(r, ndjsonContentType)
+}
+
+// acceptsMediaType reports whether the client listed mediaType itself in
+// its Accept header; wildcards don't count
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			parsed, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err == nil && parsed == mediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ndjsonWriter writes one PaymentRecordResponse per line and flushes after
+// every page. Headers go out with the first page, so errors raised before
+// that can still be answered with a regular JSON error.
+type ndjsonWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	enc     *json.Encoder
+	started bool
+	count   int
+	// money formats each record's amount and loc its dates
+	money moneyFormat
+	loc   *time.Location
+}
+
+func newNDJSONWriter(w http.ResponseWriter, money moneyFormat, loc *time.Location) *ndjsonWriter {
+	return &ndjsonWriter{
+		w:     w,
+		rc:    http.NewResponseController(w),
+		enc:   json.NewEncoder(w),
+		money: money,
+		loc:   loc,
+	}
+}
+
+// start sends the headers. A stream may run well past the server's write
+// timeout, so its write deadline is lifted; the request deadline still
+// bounds it.
+func (nw *ndjsonWriter) start() {
+	if nw.started {
+		return
+	}
+	nw.started = true
+	nw.rc.SetWriteDeadline(time.Time{})
+	nw.w.Header().Set("Content-Type", ndjsonContentType)
+	nw.w.WriteHeader(http.StatusOK)
+}
+
+// writePage encodes a page of payments and flushes it to the client
+func (nw *ndjsonWriter) writePage(payments []*domain.Payment) error {
+	nw.start()
+	for _, record := range toPaymentRecordResponses(payments, nw.money, nw.loc) {
+		if err := nw.enc.Encode(record); err != nil {
+			return err
+		}
+		nw.count++
+	}
+	if err := nw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// flushRecorder notes how many lines had been written at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	linesAtFlush []int
+}
+
+func (r *flushRecorder) Flush() {
+	r.linesAtFlush = append(r.linesAtFlush, bytes.Count(r.Body.Bytes(), []byte("\n")))
+	r.ResponseRecorder.Flush()
+}
+
+func newStreamingHandler(paymentCount, pageSize int) *PaymentHandler {
+	base := time.Date(2025, 11, 1, 9, 0, 0, 0, time.UTC)
+	payments := make([]*domain.Payment, paymentCount)
+	for i := range payments {
+		payments[i] = &domain.Payment{
+			ID:                   fmt.Sprintf("p%02d", i),
+			CustomerID:           "GIG00001",
+			Amount:               int64(1000 + i),
+			TransactionReference: fmt.Sprintf("TXN%02d", i),
+			TransactionDate:      base.Add(time.Duration(i) * time.Hour),
+			Status:               domain.PaymentStatusComplete,
+		}
+	}
+	customers := newFakeCustomerRepo(&domain.Customer{ID: "GIG00001", Status: domain.CustomerStatusActive})
+	logger := zap.NewNop()
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(payments...), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+	h.streamPageSize = pageSize
+	return h
+}
+
+func getPayments(h *PaymentHandler, query, accept string) *flushRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/payments?"+query, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.GetCustomerPayments(rec, req)
+	return rec
+}
+
+func decodeNDJSON(t *testing.T, body []byte) []dto.PaymentRecordResponse {
+	var records []dto.PaymentRecordResponse
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var record dto.PaymentRecordResponse
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "each line is one JSON object")
+		records = append(records, record)
+	}
+	return records
+}
+
+func sortByID(records []dto.PaymentRecordResponse) {
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+}
+
+func TestGetCustomerPayments_NDJSONMatchesJSON(t *testing.T) {
+	h := newStreamingHandler(7, 3)
+
+	jsonRec := getPayments(h, "customer_id=GIG00001", "")
+	require.Equal(t, http.StatusOK, jsonRec.Code)
+	assert.Equal(t, "application/json", jsonRec.Header().Get("Content-Type"))
+	var jsonResp struct {
+		Payments []dto.PaymentRecordResponse `json:"payments"`
+	}
+	require.NoError(t, json.Unmarshal(jsonRec.Body.Bytes(), &jsonResp))
+
+	ndjsonRec := getPayments(h, "customer_id=GIG00001", "application/x-ndjson")
+	require.Equal(t, http.StatusOK, ndjsonRec.Code)
+	assert.Equal(t, "application/x-ndjson", ndjsonRec.Header().Get("Content-Type"))
+	streamed := decodeNDJSON(t, ndjsonRec.Body.Bytes())
+
+	require.Len(t, jsonResp.Payments, 7)
+	sortByID(jsonResp.Payments)
+	sortByID(streamed)
+	assert.Equal(t, jsonResp.Payments, streamed)
+}
+
+func TestGetCustomerPayments_NDJSONFlushesEachPage(t *testing.T) {
+	h := newStreamingHandler(7, 3)
+
+	rec := getPayments(h, "customer_id=GIG00001", "text/plain, application/x-ndjson;q=0.9")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, []int{3, 6, 7}, rec.linesAtFlush, "each page reaches the client before the next is read")
+}
+
+// slowPaymentRepo takes delay over every page a stream reads
+type slowPaymentRepo struct {
+	*fakePaymentRepo
+	delay time.Duration
+}
+
+func (r *slowPaymentRepo) FindByCustomerIDAfter(ctx context.Context, customerID string, filter domain.PaymentFilter, afterDate time.Time, afterID string, limit int) ([]*domain.Payment, error) {
+	time.Sleep(r.delay)
+	return r.fakePaymentRepo.FindByCustomerIDAfter(ctx, customerID, filter, afterDate, afterID, limit)
+}
+
+func TestGetCustomerPayments_NDJSONOutlastsServerWriteTimeout(t *testing.T) {
+	base := time.Date(2025, 11, 1, 9, 0, 0, 0, time.UTC)
+	payments := make([]*domain.Payment, 8)
+	for i := range payments {
+		payments[i] = &domain.Payment{
+			ID: fmt.Sprintf("p%02d", i), CustomerID: "GIG00001", Amount: 1000,
+			TransactionReference: fmt.Sprintf("TXN%02d", i), TransactionDate: base.Add(time.Duration(i) * time.Hour),
+			Status: domain.PaymentStatusComplete,
+		}
+	}
+	customers := newFakeCustomerRepo(&domain.Customer{ID: "GIG00001", Status: domain.CustomerStatusActive})
+	repo := &slowPaymentRepo{fakePaymentRepo: newFakePaymentRepo(payments...), delay: 50 * time.Millisecond}
+	h := NewPaymentHandler(service.NewPaymentService(customers, repo, nil, zap.NewNop()), Config{}, zap.NewNop())
+	h.streamPageSize = 2
+
+	// Five page reads take well over the write timeout
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(h.GetCustomerPayments))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/payments?customer_id=GIG00001", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "the stream is not cut off")
+
+	assert.Len(t, decodeNDJSON(t, body), 8)
+}
+
+func TestGetCustomerPayments_NDJSONDateRange(t *testing.T) {
+	h := newStreamingHandler(30, 4)
+
+	query := "from=2025-11-01T10:00:00Z&to=2025-11-01T20:00:00Z&customer_id=GIG00001"
+	rangeRec := getPayments(h, query+"&page_size=100", "")
+	require.Equal(t, http.StatusOK, rangeRec.Code)
+	var rangeResp dateRangeResponse
+	require.NoError(t, json.Unmarshal(rangeRec.Body.Bytes(), &rangeResp))
+
+	rec := getPayments(h, query, "application/x-ndjson")
+	require.Equal(t, http.StatusOK, rec.Code)
+	streamed := decodeNDJSON(t, rec.Body.Bytes())
+
+	require.Len(t, streamed, 11)
+	assert.Equal(t, rangeResp.Payments, streamed, "same newest-first order as the JSON listing")
+	assert.Len(t, rec.linesAtFlush, 3)
+}
+
+func TestGetCustomerPayments_NDJSONErrorsBeforeStreaming(t *testing.T) {
+	h := newStreamingHandler(2, 3)
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"missing customer_id", "", http.StatusBadRequest},
+		{"unknown customer", "customer_id=GIG99999", http.StatusNotFound},
+		{"inverted range", "from=2025-11-25&to=2025-11-24", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getPayments(h, tt.query, "application/x-ndjson")
+			assert.Equal(t, tt.want, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestGetCustomerPayments_NDJSONEmptyResult(t *testing.T) {
+	h := newStreamingHandler(0, 3)
+
+	rec := getPayments(h, "customer_id=GIG00001", "application/x-ndjson")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Body.String())
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"go.uber.org/zap"
+)
+
+// PaymentFeedHandler serves the incremental feed of every customer's
+// payments that the data warehouse polls
+type PaymentFeedHandler struct {
+	feed   *service.PaymentFeedService
+	config Config
+	logger *zap.Logger
+}
+
+func NewPaymentFeedHandler(feed *service.PaymentFeedService, cfg Config, logger *zap.Logger) *PaymentFeedHandler {
+	return &PaymentFeedHandler{
+		feed:   feed,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Since lists payments recorded after the since_id cursor, oldest first,
+// up to limit of them. Without since_id the feed starts from the first
+// payment.
+func (h *PaymentFeedHandler) Since(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > service.MaxFeedLimit {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", service.MaxFeedLimit), err)
+			return
+		}
+		limit = n
+	}
+
+	page, err := h.feed.Since(r.Context(), query.Get("since_id"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			respondError(w, http.StatusBadRequest, "invalid since_id", err)
+			return
+		}
+		logFailure(h.logger, "failed to read payment feed", err)
+		respondError(w, failureStatus(err), "failed to get payments", err)
+		return
+	}
+
+	response := dto.PaymentFeedResponse{
+		Payments:   toPaymentRecordResponses(page.Payments, h.config.moneyFormat(), h.config.location()),
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+		Totals:     make(map[string]dto.PaymentTotalsResponse, len(page.Totals)),
+	}
+	for currency, totals := range page.Totals {
+		response.Totals[currency] = dto.PaymentTotalsResponse{Count: totals.Count, Amount: totals.Amount}
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
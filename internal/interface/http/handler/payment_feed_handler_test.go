@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/gigmile/payment-service/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func getPaymentFeed(h *PaymentFeedHandler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/payments/stream?"+query, nil)
+	rec := httptest.NewRecorder()
+	h.Since(rec, req)
+	return rec
+}
+
+func TestPaymentFeed_PollsWithNextCursor(t *testing.T) {
+	logger := zap.NewNop()
+	recordedAt := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	payments := testutil.NewMemoryPaymentRepository()
+	for i, ref := range []string{"TXN001", "TXN002", "TXN003"} {
+		payment, err := domain.NewPayment("GIG00001", 1000, ref, recordedAt, domain.PaymentStatusComplete, recordedAt.Add(time.Duration(i)*time.Second))
+		require.NoError(t, err)
+		require.NoError(t, payments.Save(context.Background(), payment))
+	}
+	h := NewPaymentFeedHandler(service.NewPaymentFeedService(payments, 0, logger), Config{}, logger)
+
+	rec := getPaymentFeed(h, "limit=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var first dto.PaymentFeedResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &first))
+	require.Len(t, first.Payments, 2)
+	assert.Equal(t, "TXN001", first.Payments[0].TransactionReference)
+	assert.True(t, first.HasMore)
+	assert.Equal(t, dto.PaymentTotalsResponse{Count: 2, Amount: 2000}, first.Totals["NGN"])
+
+	rec = getPaymentFeed(h, "limit=2&since_id="+first.NextCursor)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var second dto.PaymentFeedResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &second))
+	require.Len(t, second.Payments, 1)
+	assert.Equal(t, "TXN003", second.Payments[0].TransactionReference)
+	assert.False(t, second.HasMore)
+	assert.NotEmpty(t, second.NextCursor)
+}
+
+func TestPaymentFeed_RejectsBadParameters(t *testing.T) {
+	logger := zap.NewNop()
+	h := NewPaymentFeedHandler(service.NewPaymentFeedService(testutil.NewMemoryPaymentRepository(), 0, logger), Config{}, logger)
+
+	for _, query := range []string{"limit=0", "limit=1001", "limit=ten", "since_id=garbage!"} {
+		rec := getPaymentFeed(h, query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
@@ -1,11 +1,17 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
 	"github.com/gigmile/payment-service/internal/interface/http/dto"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -13,13 +19,18 @@ import (
 
 type PaymentHandler struct {
 	paymentService *service.PaymentService
+	config         Config
 	logger         *zap.Logger
+	// streamPageSize is the repository page size used for NDJSON responses
+	streamPageSize int
 }
 
-func NewPaymentHandler(paymentService *service.PaymentService, logger *zap.Logger) *PaymentHandler {
+func NewPaymentHandler(paymentService *service.PaymentService, cfg Config, logger *zap.Logger) *PaymentHandler {
 	return &PaymentHandler{
 		paymentService: paymentService,
+		config:         cfg,
 		logger:         logger,
+		streamPageSize: defaultStreamPageSize,
 	}
 }
 
@@ -27,25 +38,42 @@ func NewPaymentHandler(paymentService *service.PaymentService, logger *zap.Logge
 func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request) {
 	var req dto.PaymentRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid request body", err)
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			respondPaymentError(w, http.StatusBadRequest, "dry_run must be a boolean", err)
+			return
+		}
+		dryRun = parsed
+	}
+
+	if !isJSONContentType(r) {
+		respondPaymentError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
 		return
 	}
 
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		respondPaymentError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	req.Normalize()
 	if err := req.Validate(); err != nil {
-		h.respondError(w, http.StatusBadRequest, "validation failed", err)
+		respondPaymentInvalid(w, "validation failed", err)
 		return
 	}
 
-	amount, err := req.GetAmountInKobo()
+	currency := h.config.requestCurrency(&req)
+	amount, err := req.GetAmount(currency)
 	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid transaction amount", err)
+		respondPaymentInvalid(w, "invalid transaction amount", err)
 		return
 	}
 
-	txDate, err := req.GetTransactionDate()
+	txDate, err := req.GetTransactionDate(h.config.location())
 	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid transaction date", err)
+		respondPaymentInvalid(w, "invalid transaction date", err)
 		return
 	}
 
@@ -55,25 +83,78 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 		TransactionAmount:    amount,
 		TransactionDate:      txDate,
 		TransactionReference: req.TransactionReference,
+		Currency:             currency.Code,
+		Metadata:             req.Metadata,
+		DryRun:               dryRun,
 	})
 
+	if errors.Is(err, domain.ErrCurrencyMismatch) {
+		respondPaymentError(w, http.StatusUnprocessableEntity, "payment currency does not match the customer's loan currency", err)
+		return
+	}
+
+	if errors.Is(err, domain.ErrLoanWrittenOff) {
+		respondPaymentError(w, http.StatusConflict, "customer loan has been written off", err)
+		return
+	}
+
+	if errors.Is(err, domain.ErrBelowMinimumPayment) {
+		respondPaymentError(w, http.StatusUnprocessableEntity, "payment is below the minimum accepted amount and does not settle the outstanding balance", err)
+		return
+	}
+
+	if errors.Is(err, domain.ErrExcessiveOverpayment) {
+		respondPaymentError(w, http.StatusUnprocessableEntity, "payment exceeds the outstanding balance by more than the allowed tolerance", err)
+		return
+	}
+
+	if errors.Is(err, domain.ErrDuplicateTransaction) {
+		respondPaymentError(w, http.StatusConflict, "transaction_reference is already used by a different payment", err)
+		return
+	}
+
+	if errors.Is(err, domain.ErrCustomerNotFound) {
+		respondPaymentError(w, http.StatusUnprocessableEntity, "customer not found", err)
+		return
+	}
+
+	if err != nil && !service.IsRetryable(err) {
+		respondPaymentError(w, http.StatusUnprocessableEntity, "payment was rejected", err)
+		return
+	}
+
 	if err != nil {
-		h.logger.Error("failed to process payment",
-			zap.Error(err),
+		logFailure(h.logger, "failed to process payment", err,
 			zap.String("customer_id", req.CustomerID),
 		)
-		h.respondError(w, http.StatusInternalServerError, "failed to process payment", err)
+		respondPaymentError(w, retryableFailureStatus(err), "failed to process payment", err)
 		return
 	}
 
 	response := dto.PaymentResponse{
+		Outcome:            string(result.Outcome),
 		Success:            result.Success,
+		Processed:          result.Processed,
+		Reason:             result.Reason,
 		Message:            result.Message,
 		CustomerID:         result.CustomerID,
 		OutstandingBalance: result.OutstandingBalance,
 		TotalPaid:          result.TotalPaid,
 		PaymentProgress:    result.PaymentProgress,
 		IsFullyPaid:        result.IsFullyPaid,
+		Preview:            result.DryRun,
+		Flagged:            result.Flagged,
+		PaymentID:          result.PaymentID,
+	}
+	if !result.ProcessedAt.IsZero() {
+		response.ProcessedAt = formatTime(result.ProcessedAt, h.config.location())
+	}
+	if result.Installment != nil {
+		response.Installment = &dto.InstallmentResponse{
+			ExpectedAmount: result.Installment.Expected,
+			Standing:       string(result.Installment.Standing),
+			Arrears:        result.Installment.Arrears,
+		}
 	}
 
 	h.respondJSON(w, http.StatusOK, response)
@@ -81,75 +162,293 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 
 // GetCustomer retrieves customer information
 func (h *PaymentHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
-	customerID := chi.URLParam(r, "customer_id")
-
-	if customerID == "" {
-		h.respondError(w, http.StatusBadRequest, "customer_id is required", nil)
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
 		return
 	}
 
 	customer, err := h.paymentService.GetCustomer(r.Context(), customerID)
 	if err != nil {
-		h.logger.Error("failed to get customer",
-			zap.Error(err),
+		if errors.Is(err, domain.ErrCustomerNotFound) {
+			h.respondError(w, http.StatusNotFound, "customer not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to get customer", err,
+			zap.String("customer_id", customerID),
+		)
+		h.respondError(w, failureStatus(err), "failed to get customer", err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, toCustomerResponse(customer, h.config.moneyFormat()))
+}
+
+// GetCustomerProjection estimates when a customer will finish paying
+func (h *PaymentHandler) GetCustomerProjection(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
+		return
+	}
+
+	result, err := h.paymentService.ProjectPayoff(r.Context(), customerID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCustomerNotFound) {
+			h.respondError(w, http.StatusNotFound, "customer not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to project customer payoff", err,
 			zap.String("customer_id", customerID),
 		)
-		h.respondError(w, http.StatusNotFound, "customer not found", err)
+		h.respondError(w, failureStatus(err), "failed to project customer payoff", err)
+		return
+	}
+
+	customer, projection := result.Customer, result.Projection
+	h.respondJSON(w, http.StatusOK, dto.PayoffProjectionResponse{
+		CustomerID:           customer.ID,
+		Status:               string(customer.Status),
+		Completed:            projection.Completed,
+		OutstandingBalance:   customer.OutstandingBalance,
+		TotalPaid:            customer.TotalPaid,
+		ExpectedWeeklyAmount: customer.ExpectedWeeklyAmount(),
+		WeeklyPace:           projection.WeeklyPace,
+		OnSchedule:           toPayoffEstimateResponse(projection.OnSchedule, h.config.location()),
+		AtCurrentPace:        toPayoffEstimateResponse(projection.AtCurrentPace, h.config.location()),
+		AsOf:                 formatTime(result.At, h.config.location()),
+	})
+}
+
+func toPayoffEstimateResponse(estimate *domain.PayoffEstimate, loc *time.Location) *dto.PayoffEstimateResponse {
+	if estimate == nil {
+		return nil
+	}
+	return &dto.PayoffEstimateResponse{
+		WeeksRemaining: estimate.WeeksRemaining,
+		PayoffDate:     formatTime(estimate.PayoffDate, loc),
+	}
+}
+
+// GetPayoffQuote quotes the single payment that settles a customer's loan,
+// less any configured early-payoff discount
+func (h *PaymentHandler) GetPayoffQuote(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
 		return
 	}
 
-	response := dto.CustomerResponse{
-		CustomerID:         customer.ID,
-		AssetValue:         customer.AssetValue,
-		RepaymentTermWeeks: customer.RepaymentTermWeeks,
-		OutstandingBalance: customer.OutstandingBalance,
-		TotalPaid:          customer.TotalPaid,
-		PaymentProgress:    customer.GetPaymentProgress(),
-		Status:             string(customer.Status),
-		IsFullyPaid:        customer.IsFullyPaid(),
+	result, err := h.paymentService.QuotePayoff(r.Context(), customerID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCustomerNotFound) {
+			h.respondError(w, http.StatusNotFound, "customer not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to quote customer payoff", err,
+			zap.String("customer_id", customerID),
+		)
+		h.respondError(w, failureStatus(err), "failed to quote customer payoff", err)
+		return
+	}
+
+	customer, quote := result.Customer, result.Quote
+	currency := customer.Currency()
+	money := h.config.moneyFormat()
+	resp := dto.PayoffQuoteResponse{
+		CustomerID:          customer.ID,
+		Status:              string(customer.Status),
+		Currency:            currency.Code,
+		Completed:           quote.Completed,
+		OutstandingBalance:  quote.OutstandingBalance,
+		DiscountBasisPoints: result.DiscountBasisPoints,
+		Discount:            quote.Discount,
+		PayoffAmount:        quote.Amount,
+
+		OutstandingBalanceFormatted: money.format(quote.OutstandingBalance, currency),
+		DiscountFormatted:           money.format(quote.Discount, currency),
+		PayoffAmountFormatted:       money.format(quote.Amount, currency),
+
+		AsOf: formatTime(result.At, h.config.location()),
+	}
+	switch {
+	case quote.Completed:
+		resp.Message = "loan is already fully paid; nothing is left to pay off"
+	case customer.Status == domain.CustomerStatusWrittenOff:
+		resp.Message = "loan has been written off; nothing is left to pay off"
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// GetWeeklyPayments totals a customer's payments per schedule week, for
+// charting collections
+func (h *PaymentHandler) GetWeeklyPayments(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerID(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
+		return
+	}
+
+	result, err := h.paymentService.WeeklyPayments(r.Context(), customerID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCustomerNotFound) {
+			h.respondError(w, http.StatusNotFound, "customer not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to get weekly payments", err,
+			zap.String("customer_id", customerID),
+		)
+		h.respondError(w, failureStatus(err), "failed to get weekly payments", err)
+		return
+	}
+
+	customer := result.Customer
+	currency := customer.Currency()
+	money, loc := h.config.moneyFormat(), h.config.location()
+	resp := dto.WeeklyPaymentsResponse{
+		CustomerID:     customer.ID,
+		Currency:       currency.Code,
+		DeploymentDate: formatTime(customer.DeploymentDate, loc),
+		Weeks:          make([]dto.WeeklyPaymentEntry, len(result.Weeks)),
+		TotalCount:     result.Totals.Count,
+		TotalAmount:    result.Totals.Amount,
+		AsOf:           formatTime(result.At, loc),
+	}
+	for i, week := range result.Weeks {
+		resp.Weeks[i] = dto.WeeklyPaymentEntry{
+			Week:            week.Week,
+			Start:           formatTime(week.Start, loc),
+			End:             formatTime(week.End, loc),
+			Count:           week.Totals.Count,
+			Amount:          week.Totals.Amount,
+			AmountFormatted: money.format(week.Totals.Amount, currency),
+			ExpectedAmount:  week.Expected,
+			Partial:         week.Partial,
+		}
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// GetCustomersBatch retrieves several customers in one request
+func (h *PaymentHandler) GetCustomersBatch(w http.ResponseWriter, r *http.Request) {
+	if !isJSONContentType(r) {
+		h.respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil)
+		return
+	}
+
+	var req dto.BatchCustomersRequest
+	if err := decodeJSONBody(r, &req, h.config.DisallowUnknownFields); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), errors.Unwrap(err))
+		return
+	}
+
+	if err := req.Validate(h.config.MaxBatchCustomerIDs); err != nil {
+		h.respondError(w, http.StatusBadRequest, "validation failed", err)
+		return
+	}
+
+	customers, notFound, err := h.paymentService.GetCustomers(r.Context(), req.CustomerIDs)
+	if err != nil {
+		logFailure(h.logger, "failed to get customers", err,
+			zap.Int("customer_ids", len(req.CustomerIDs)),
+		)
+		h.respondError(w, failureStatus(err), "failed to get customers", err)
+		return
+	}
+
+	response := dto.BatchCustomersResponse{
+		Customers: make(map[string]dto.CustomerResponse, len(customers)),
+		NotFound:  notFound,
+	}
+	for id, customer := range customers {
+		response.Customers[id] = toCustomerResponse(customer, h.config.moneyFormat())
 	}
 
 	h.respondJSON(w, http.StatusOK, response)
 }
 
-// GetCustomerPayments retrieves all payments for a customer
+func toCustomerResponse(customer *domain.Customer, money moneyFormat) dto.CustomerResponse {
+	currency := customer.Currency()
+	expectedWeekly := customer.ExpectedWeeklyAmount()
+	arrears := customer.Arrears(time.Now())
+	return dto.CustomerResponse{
+		CustomerID:           customer.ID,
+		Currency:             currency.Code,
+		AssetValue:           customer.AssetValue,
+		RepaymentTermWeeks:   customer.RepaymentTermWeeks,
+		OutstandingBalance:   customer.OutstandingBalance,
+		TotalPaid:            customer.TotalPaid,
+		PaymentProgress:      customer.GetPaymentProgress(),
+		Status:               string(customer.Status),
+		IsFullyPaid:          customer.IsFullyPaid(),
+		ExpectedWeeklyAmount: expectedWeekly,
+		Arrears:              arrears,
+
+		AssetValueFormatted:           money.format(customer.AssetValue, currency),
+		OutstandingBalanceFormatted:   money.format(customer.OutstandingBalance, currency),
+		TotalPaidFormatted:            money.format(customer.TotalPaid, currency),
+		ExpectedWeeklyAmountFormatted: money.format(expectedWeekly, currency),
+		ArrearsFormatted:              money.format(arrears, currency),
+	}
+}
+
+// GetCustomerPayments retrieves all payments for a customer, or payments in
+// a date range when from/to are given, narrowed by status and ordered by
+// order in every form. Clients accepting application/x-ndjson get the full
+// result streamed one payment per line.
 func (h *PaymentHandler) GetCustomerPayments(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("customer_id"); id != "" {
+		if _, ok := checkCustomerID(w, h.config.CustomerIDFormat, id); !ok {
+			return
+		}
+	}
+
+	filter, filtered, err := parsePaymentFilter(r.URL.Query())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if wantsNDJSON(r) {
+		h.streamPayments(w, r, filter)
+		return
+	}
+
+	if r.URL.Query().Has("from") || r.URL.Query().Has("to") {
+		h.getPaymentsByDateRange(w, r, filter)
+		return
+	}
+
 	customerID := r.URL.Query().Get("customer_id")
 	if customerID == "" {
 		h.respondError(w, http.StatusBadRequest, "customer_id is required", nil)
 		return
 	}
 
+	if r.URL.Query().Has("cursor") {
+		h.getCustomerPaymentsByCursor(w, r, customerID, filter)
+		return
+	}
+
 	pageStr := r.URL.Query().Get("page")
 	pageSizeStr := r.URL.Query().Get("page_size")
 
 	if pageStr != "" || pageSizeStr != "" {
-		h.getCustomerPaymentsPaginated(w, r, customerID, pageStr, pageSizeStr)
+		h.getCustomerPaymentsPaginated(w, r, customerID, filter, pageStr, pageSizeStr)
 		return
 	}
 
-	payments, err := h.paymentService.GetCustomerPayments(r.Context(), customerID)
+	var payments []*domain.Payment
+	if filtered {
+		payments, err = h.paymentService.GetCustomerPaymentsFiltered(r.Context(), customerID, filter)
+	} else {
+		payments, err = h.paymentService.GetCustomerPayments(r.Context(), customerID)
+	}
 	if err != nil {
-		h.logger.Error("failed to get customer payments",
-			zap.Error(err),
+		logFailure(h.logger, "failed to get customer payments", err,
 			zap.String("customer_id", customerID),
 		)
-		h.respondError(w, http.StatusInternalServerError, "failed to get customer payments", err)
+		h.respondError(w, failureStatus(err), "failed to get customer payments", err)
 		return
 	}
 
-	response := make([]dto.PaymentRecordResponse, len(payments))
-	for i, payment := range payments {
-		response[i] = dto.PaymentRecordResponse{
-			ID:                   payment.ID,
-			CustomerID:           payment.CustomerID,
-			TransactionAmount:    payment.Amount,
-			TransactionReference: payment.TransactionReference,
-			TransactionDate:      payment.TransactionDate.Format("2006-01-02T15:04:05Z07:00"),
-			Status:               string(payment.Status),
-			ProcessedAt:          payment.ProcessedAt.Format("2006-01-02T15:04:05Z07:00"),
-		}
-	}
+	response := toPaymentRecordResponses(payments, h.config.moneyFormat(), h.config.location())
 
 	h.logger.Info("customer payments retrieved successfully",
 		zap.String("customer_id", customerID),
@@ -163,9 +462,67 @@ func (h *PaymentHandler) GetCustomerPayments(w http.ResponseWriter, r *http.Requ
 	})
 }
 
-func (h *PaymentHandler) getCustomerPaymentsPaginated(w http.ResponseWriter, r *http.Request, customerID, pageStr, pageSizeStr string) {
+// GetCustomerPaymentsByPath serves GET /customers/{customer_id}/payments,
+// the same listing GET /payments gives for ?customer_id=, with the same
+// query parameters. A customer_id in the query as well must name the same
+// customer.
+func (h *PaymentHandler) GetCustomerPaymentsByPath(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customer_id")
+	query := r.URL.Query()
+	if id := query.Get("customer_id"); id != "" && service.NormalizeCustomerID(id) != service.NormalizeCustomerID(customerID) {
+		h.respondError(w, http.StatusBadRequest, "customer_id in the query does not match the path", nil)
+		return
+	}
+
+	query.Set("customer_id", customerID)
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	h.GetCustomerPayments(w, r)
+}
+
+// filterablePaymentStatuses are the statuses GET /payments can filter on
+var filterablePaymentStatuses = []domain.PaymentStatus{
+	domain.PaymentStatusPending,
+	domain.PaymentStatusComplete,
+	domain.PaymentStatusFailed,
+	domain.PaymentStatusDuplicate,
+	domain.PaymentStatusWriteOff,
+}
+
+// parsePaymentFilter reads status (comma-separated or repeated) and order
+// from the query. filtered is false when neither was sent, so the default
+// listing is left untouched.
+func parsePaymentFilter(query url.Values) (filter domain.PaymentFilter, filtered bool, err error) {
+	for _, value := range query["status"] {
+		for _, part := range strings.Split(value, ",") {
+			status := domain.PaymentStatus(strings.ToUpper(strings.TrimSpace(part)))
+			if !slices.Contains(filterablePaymentStatuses, status) {
+				return filter, false, fmt.Errorf("unknown payment status %q", strings.TrimSpace(part))
+			}
+			if !slices.Contains(filter.Statuses, status) {
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		}
+		filtered = true
+	}
+
+	if query.Has("order") {
+		switch order := domain.PaymentOrder(strings.ToLower(query.Get("order"))); order {
+		case domain.PaymentOrderAsc, domain.PaymentOrderDesc:
+			filter.Order = order
+		default:
+			return filter, false, errors.New("order must be asc or desc")
+		}
+		filtered = true
+	}
+
+	return filter, filtered, nil
+}
+
+func (h *PaymentHandler) getCustomerPaymentsPaginated(w http.ResponseWriter, r *http.Request, customerID string, filter domain.PaymentFilter, pageStr, pageSizeStr string) {
 	page := 1
-	pageSize := 10
+	// Zero leaves the default to the service
+	pageSize := 0
 
 	if pageStr != "" {
 		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
@@ -182,32 +539,22 @@ func (h *PaymentHandler) getCustomerPaymentsPaginated(w http.ResponseWriter, r *
 	params := service.PaginationParams{
 		Page:     page,
 		PageSize: pageSize,
+		Filter:   filter,
 	}
 
 	result, err := h.paymentService.GetCustomerPaymentsPaginated(r.Context(), customerID, params)
 	if err != nil {
-		h.logger.Error("failed to get customer payments with pagination",
-			zap.Error(err),
+		logFailure(h.logger, "failed to get customer payments with pagination", err,
 			zap.String("customer_id", customerID),
 			zap.Int("page", page),
 			zap.Int("page_size", pageSize),
 		)
-		h.respondError(w, http.StatusInternalServerError, "failed to get customer payments", err)
+		h.respondError(w, failureStatus(err), "failed to get customer payments", err)
 		return
 	}
 
-	response := make([]dto.PaymentRecordResponse, len(result.Payments))
-	for i, payment := range result.Payments {
-		response[i] = dto.PaymentRecordResponse{
-			ID:                   payment.ID,
-			CustomerID:           payment.CustomerID,
-			TransactionAmount:    payment.Amount,
-			TransactionReference: payment.TransactionReference,
-			TransactionDate:      payment.TransactionDate.Format("2006-01-02T15:04:05Z07:00"),
-			Status:               string(payment.Status),
-			ProcessedAt:          payment.ProcessedAt.Format("2006-01-02T15:04:05Z07:00"),
-		}
-	}
+	response := toPaymentRecordResponses(result.Payments, h.config.moneyFormat(), h.config.location())
+	setPaginationHeaders(w, r, result.Page, result.PageSize, result.TotalPages, result.TotalCount)
 
 	h.logger.Info("customer payments retrieved successfully with pagination",
 		zap.String("customer_id", customerID),
@@ -229,6 +576,233 @@ func (h *PaymentHandler) getCustomerPaymentsPaginated(w http.ResponseWriter, r *
 	})
 }
 
+// setPaginationHeaders adds RFC 8288 Link headers and X-Total-Count, so
+// generic clients can page without reading the body. Links are relative
+// and keep the rest of the query; next and prev are left out at the ends.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, page, pageSize, totalPages int, totalCount int64) {
+	last := max(totalPages, 1)
+	link := func(target int, rel string) string {
+		query := r.URL.Query()
+		// /customers/{customer_id}/payments already names the customer
+		if chi.URLParam(r, "customer_id") != "" {
+			query.Del("customer_id")
+		}
+		query.Set("page", strconv.Itoa(target))
+		query.Set("page_size", strconv.Itoa(pageSize))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, query.Encode(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page > 1 {
+		links = append(links, link(min(page-1, last), "prev"))
+	}
+	if page < last {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+	w.Header().Set("X-Total-Count", strconv.FormatInt(totalCount, 10))
+}
+
+// parseDateRangeQuery reads from, to and customer_id from the query
+// string, answering 400 itself when they are unusable
+func (h *PaymentHandler) parseDateRangeQuery(w http.ResponseWriter, r *http.Request) (service.DateRangeQuery, bool) {
+	query := r.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		h.respondError(w, http.StatusBadRequest, "from and to are both required", nil)
+		return service.DateRangeQuery{}, false
+	}
+
+	from, err := dto.ParseDateRangeBound(query.Get("from"), false, h.config.location())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid from", err)
+		return service.DateRangeQuery{}, false
+	}
+	to, err := dto.ParseDateRangeBound(query.Get("to"), true, h.config.location())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid to", err)
+		return service.DateRangeQuery{}, false
+	}
+
+	return service.DateRangeQuery{
+		From:       from,
+		To:         to,
+		CustomerID: query.Get("customer_id"),
+	}, true
+}
+
+func (h *PaymentHandler) getPaymentsByDateRange(w http.ResponseWriter, r *http.Request, filter domain.PaymentFilter) {
+	query := r.URL.Query()
+	q, ok := h.parseDateRangeQuery(w, r)
+	if !ok {
+		return
+	}
+	from, to := q.From, q.To
+	q.Filter = filter
+
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		q.Page = p
+	}
+	if ps, err := strconv.Atoi(query.Get("page_size")); err == nil && ps > 0 {
+		q.PageSize = ps
+	}
+
+	result, err := h.paymentService.GetPaymentsByDateRange(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDateRange) || errors.Is(err, service.ErrDateRangeTooWide) {
+			h.respondError(w, http.StatusBadRequest, "invalid date range", err)
+			return
+		}
+		logFailure(h.logger, "failed to get payments by date range", err,
+			zap.Time("from", from),
+			zap.Time("to", to),
+			zap.String("customer_id", q.CustomerID),
+		)
+		h.respondError(w, failureStatus(err), "failed to get payments", err)
+		return
+	}
+
+	response := toPaymentRecordResponses(result.Payments, h.config.moneyFormat(), h.config.location())
+
+	h.logger.Info("payments retrieved by date range",
+		zap.Time("from", from),
+		zap.Time("to", to),
+		zap.String("customer_id", q.CustomerID),
+		zap.Int("count", len(response)),
+		zap.Int64("total_count", result.TotalCount),
+	)
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":     formatTime(from, h.config.location()),
+		"to":       formatTime(to, h.config.location()),
+		"payments": response,
+		"totals": map[string]interface{}{
+			"count":  result.TotalCount,
+			"amount": result.TotalAmount,
+		},
+		"pagination": map[string]interface{}{
+			"page":        result.Page,
+			"page_size":   result.PageSize,
+			"total_count": result.TotalCount,
+			"total_pages": result.TotalPages,
+		},
+	})
+}
+
+// streamPayments writes every matching payment as NDJSON, reading the
+// repository one page at a time. Paging parameters are ignored. Once the
+// first page is out an error can only be logged; the client sees the stream
+// end early.
+func (h *PaymentHandler) streamPayments(w http.ResponseWriter, r *http.Request, filter domain.PaymentFilter) {
+	nw := newNDJSONWriter(w, h.config.moneyFormat(), h.config.location())
+
+	var err error
+	var customerID string
+	if r.URL.Query().Has("from") || r.URL.Query().Has("to") {
+		q, ok := h.parseDateRangeQuery(w, r)
+		if !ok {
+			return
+		}
+		customerID = q.CustomerID
+		q.Filter = filter
+		err = h.paymentService.StreamPaymentsByDateRange(r.Context(), q, h.streamPageSize, nw.writePage)
+	} else {
+		customerID = r.URL.Query().Get("customer_id")
+		if customerID == "" {
+			h.respondError(w, http.StatusBadRequest, "customer_id is required", nil)
+			return
+		}
+		err = h.paymentService.StreamCustomerPayments(r.Context(), customerID, filter, h.streamPageSize, nw.writePage)
+	}
+
+	if err != nil {
+		logFailure(h.logger, "failed to stream payments", err,
+			zap.String("customer_id", customerID),
+			zap.Int("written", nw.count),
+		)
+		if nw.started {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidDateRange) || errors.Is(err, service.ErrDateRangeTooWide):
+			h.respondError(w, http.StatusBadRequest, "invalid date range", err)
+		case errors.Is(err, domain.ErrCustomerNotFound):
+			h.respondError(w, http.StatusNotFound, "customer not found", err)
+		default:
+			h.respondError(w, failureStatus(err), "failed to get payments", err)
+		}
+		return
+	}
+
+	// An empty result still answers with an (empty) NDJSON body
+	nw.start()
+
+	h.logger.Info("payments streamed",
+		zap.String("customer_id", customerID),
+		zap.Int("count", nw.count),
+	)
+}
+
+func (h *PaymentHandler) getCustomerPaymentsByCursor(w http.ResponseWriter, r *http.Request, customerID string, filter domain.PaymentFilter) {
+	cursor := r.URL.Query().Get("cursor")
+
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	result, err := h.paymentService.GetCustomerPaymentsByCursor(r.Context(), customerID, filter, cursor, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			h.respondError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+		logFailure(h.logger, "failed to get customer payments with cursor", err,
+			zap.String("customer_id", customerID),
+			zap.Int("limit", limit),
+		)
+		h.respondError(w, failureStatus(err), "failed to get customer payments", err)
+		return
+	}
+
+	response := toPaymentRecordResponses(result.Payments, h.config.moneyFormat(), h.config.location())
+
+	h.logger.Info("customer payments retrieved successfully with cursor",
+		zap.String("customer_id", customerID),
+		zap.Int("count", len(response)),
+		zap.Bool("has_more", result.NextCursor != ""),
+	)
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"customer_id": customerID,
+		"payments":    response,
+		"next_cursor": result.NextCursor,
+	})
+}
+
+func toPaymentRecordResponses(payments []*domain.Payment, money moneyFormat, loc *time.Location) []dto.PaymentRecordResponse {
+	response := make([]dto.PaymentRecordResponse, len(payments))
+	for i, payment := range payments {
+		response[i] = dto.PaymentRecordResponse{
+			ID:                   payment.ID,
+			CustomerID:           payment.CustomerID,
+			TransactionAmount:    payment.Amount,
+			Currency:             payment.Currency().Code,
+			AmountFormatted:      money.format(payment.Amount, payment.Currency()),
+			TransactionReference: payment.TransactionReference,
+			TransactionDate:      formatTime(payment.TransactionDate, loc),
+			Status:               string(payment.Status),
+			ProcessedAt:          formatTime(payment.ProcessedAt, loc),
+			Metadata:             payment.Metadata,
+			BalanceAfter:         payment.BalanceAfter,
+		}
+	}
+	return response
+}
+
 // HealthCheck handles health check endpoint
 func (h *PaymentHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, map[string]string{
@@ -237,20 +811,31 @@ func (h *PaymentHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PaymentHandler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+	respondJSON(w, status, data)
 }
 
 func (h *PaymentHandler) respondError(w http.ResponseWriter, status int, message string, err error) {
-	response := dto.ErrorResponse{
-		Error:   message,
-		Message: "",
-	}
+	respondError(w, status, message, err)
+}
 
-	if err != nil {
-		response.Message = err.Error()
-	}
+// respondPaymentError is respondError for POST /payments, whose answers
+// always carry an outcome
+func respondPaymentError(w http.ResponseWriter, status int, message string, err error) {
+	respondJSON(w, status, paymentErrorResponse(status, message, err))
+}
+
+// respondPaymentInvalid rejects a payment that parsed but whose fields
+// don't hold up with 422, which the provider won't retry
+func respondPaymentInvalid(w http.ResponseWriter, message string, err error) {
+	response := paymentErrorResponse(http.StatusUnprocessableEntity, message, err)
+	response.Code = dto.ErrorCodeValidation
+	respondJSON(w, http.StatusUnprocessableEntity, response)
+}
 
-	h.respondJSON(w, status, response)
+func paymentErrorResponse(status int, message string, err error) dto.ErrorResponse {
+	response := errorResponse(message, err)
+	response.Outcome = string(service.OutcomeFailed)
+	retryable := status >= http.StatusInternalServerError || status == statusClientClosedRequest
+	response.Retryable = &retryable
+	return response
 }
@@ -0,0 +1,1098 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const validPaymentBody = `{
+	"customer_id": "GIG00001",
+	"payment_status": "PENDING",
+	"transaction_amount": "10000",
+	"transaction_date": "2025-11-24 14:54:16",
+	"transaction_reference": "TXN001"
+}`
+
+func newTestPaymentHandler(cfg Config) *PaymentHandler {
+	logger := zap.NewNop()
+	paymentService := service.NewPaymentService(nil, nil, nil, logger)
+	return NewPaymentHandler(paymentService, cfg, logger)
+}
+
+func postPayment(h *PaymentHandler, contentType, body string) (*httptest.ResponseRecorder, dto.ErrorResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ProcessPayment(rec, req)
+
+	var errResp dto.ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &errResp)
+	return rec, errResp
+}
+
+func TestProcessPayment_RejectsNonJSONContentType(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	rec, errResp := postPayment(h, "application/x-www-form-urlencoded", "customer_id=GIG00001")
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(t, "Content-Type must be application/json", errResp.Error)
+	assert.Equal(t, "FAILED", errResp.Outcome)
+
+	rec, _ = postPayment(h, "", validPaymentBody)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestProcessPayment_AcceptsJSONContentTypeWithCharset(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	rec, _ := postPayment(h, "application/json; charset=utf-8", validPaymentBody)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestProcessPayment_MalformedBodies(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "not JSON",
+			body:    `customer_id=GIG00001`,
+			wantErr: "request body is not valid JSON",
+		},
+		{
+			name:    "truncated JSON",
+			body:    `{"customer_id": "GIG00001"`,
+			wantErr: "request body is not valid JSON",
+		},
+		{
+			name:    "empty body",
+			body:    ``,
+			wantErr: "request body must not be empty",
+		},
+		{
+			name:    "wrong field type",
+			body:    `{"customer_id": 12345}`,
+			wantErr: "request body has a field of the wrong type",
+		},
+		{
+			name:    "boolean amount",
+			body:    `{"customer_id": "GIG00001", "transaction_amount": true}`,
+			wantErr: "request body has a field of the wrong type",
+		},
+		{
+			name:    "unknown field when disallowed",
+			cfg:     Config{DisallowUnknownFields: true},
+			body:    `{"customer_id": "GIG00001", "channel": "USSD"}`,
+			wantErr: "request body contains an unknown field",
+		},
+		{
+			name:    "two concatenated objects",
+			body:    validPaymentBody + validPaymentBody,
+			wantErr: "request body contains extra data after the JSON object",
+		},
+		{
+			name:    "trailing garbage",
+			body:    validPaymentBody + `garbage`,
+			wantErr: "request body contains extra data after the JSON object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestPaymentHandler(tt.cfg)
+
+			rec, errResp := postPayment(h, "application/json", tt.body)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tt.wantErr, errResp.Error)
+		})
+	}
+}
+
+func TestProcessPayment_UnknownFieldsAllowedByDefault(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	body := strings.Replace(validPaymentBody, `"customer_id"`, `"channel": "USSD", "customer_id"`, 1)
+	rec, _ := postPayment(h, "application/json", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestProcessPayment_NumericAmountAccepted(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	body := strings.Replace(validPaymentBody, `"10000"`, `10000.00`, 1)
+	rec, _ := postPayment(h, "application/json", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestProcessPayment_TrailingWhitespaceAllowed(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	rec, _ := postPayment(h, "application/json", validPaymentBody+"\n\n")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestProcessPayment_StatusNotCompleteIsNotProcessed(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	for _, status := range []string{"PENDING", "FAILED"} {
+		t.Run(status, func(t *testing.T) {
+			body := strings.Replace(validPaymentBody, `"PENDING"`, `"`+status+`"`, 1)
+			rec, _ := postPayment(h, "application/json", body)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var resp map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "NOT_COMPLETE", resp["outcome"])
+			assert.Equal(t, false, resp["success"])
+			assert.Equal(t, false, resp["processed"])
+			assert.Equal(t, service.ReasonStatusNotComplete, resp["reason"])
+			assert.Contains(t, resp["message"], status)
+			assert.NotContains(t, resp, "payment_id")
+			assert.NotContains(t, resp, "processed_at")
+		})
+	}
+}
+
+func TestProcessPayment_BelowMinimumReturns422(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), newFakePaymentRepo(), nil, logger,
+		service.WithMinimumPaymentAmount(50000),
+	)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+
+	body := strings.NewReplacer(`"PENDING"`, `"COMPLETE"`, `"10000"`, `"100"`).Replace(validPaymentBody)
+	rec, errResp := postPayment(h, "application/json", body)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, errResp.Error, "minimum")
+	assert.Equal(t, "FAILED", errResp.Outcome)
+}
+
+func TestProcessPayment_GrossOverpaymentReturns422(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 50000, TotalPaid: 99950000, Status: domain.CustomerStatusActive, Version: 1}
+	paymentRepo := newFakePaymentRepo()
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), paymentRepo, nil, logger,
+		service.WithOverpaymentLimit(domain.OverpaymentLimit{Enabled: true, Percent: 10}),
+	)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+
+	body := strings.Replace(validPaymentBody, `"PENDING"`, `"COMPLETE"`, 1)
+	rec, errResp := postPayment(h, "application/json", body)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, errResp.Error, "outstanding balance")
+	assert.Equal(t, "FAILED", errResp.Outcome)
+	assert.Equal(t, int64(50000), customer.OutstandingBalance)
+	exists, err := paymentRepo.ExistsByTransactionReference(context.Background(), "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestProcessPayment_FullyPaidCustomerIsNotAServerError(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, TotalPaid: 100000000, Status: domain.CustomerStatusCompleted, Version: 4}
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+
+	body := strings.Replace(validPaymentBody, `"PENDING"`, `"COMPLETE"`, 1)
+	rec, _ := postPayment(h, "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.PaymentResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "ALREADY_PAID", resp.Outcome)
+	assert.True(t, resp.Success)
+	assert.False(t, resp.Processed)
+	assert.Equal(t, service.ReasonAlreadyFullyPaid, resp.Reason)
+	assert.Contains(t, resp.Message, "already fully paid")
+	assert.True(t, resp.IsFullyPaid)
+	assert.Zero(t, resp.OutstandingBalance)
+	assert.Equal(t, "payment-TXN001", resp.PaymentID)
+	assert.Empty(t, resp.ProcessedAt, "recorded but not applied")
+
+	rec, _ = postPayment(h, "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "DUPLICATE", resp.Outcome, "the payment was recorded")
+	assert.Equal(t, "payment-TXN001", resp.PaymentID)
+}
+
+func TestProcessPayment_ReturnsPaymentIDAndProcessedAt(t *testing.T) {
+	logger := zap.NewNop()
+	processedAt := time.Date(2025, 11, 24, 15, 0, 0, 0, time.UTC)
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	payments := newFakePaymentRepo()
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), payments, nil, logger,
+		service.WithClock(domain.ClockFunc(func() time.Time { return processedAt })),
+	)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+	body := strings.Replace(validPaymentBody, `"PENDING"`, `"COMPLETE"`, 1)
+
+	rec, _ := postPayment(h, "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp dto.PaymentResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "PROCESSED", resp.Outcome)
+	assert.Equal(t, "payment-TXN001", resp.PaymentID)
+	assert.Equal(t, "2025-11-24T15:00:00Z", resp.ProcessedAt)
+
+	rec, _ = postPayment(h, "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp = dto.PaymentResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "DUPLICATE", resp.Outcome)
+	assert.Equal(t, "payment-TXN001", resp.PaymentID, "the payment the first request recorded")
+}
+
+func TestProcessPayment_TransactionDateInConfiguredTimezone(t *testing.T) {
+	logger := zap.NewNop()
+	lagos := time.FixedZone("WAT", 3600)
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	payments := newFakePaymentRepo()
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), payments, nil, logger)
+	h := NewPaymentHandler(paymentService, Config{Timezone: lagos}, logger)
+	body := strings.Replace(validPaymentBody, `"PENDING"`, `"COMPLETE"`, 1)
+
+	rec, _ := postPayment(h, "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	stored := payments.payments["TXN001"]
+	require.NotNil(t, stored)
+	assert.True(t, time.Date(2025, 11, 24, 13, 54, 16, 0, time.UTC).Equal(stored.TransactionDate), stored.TransactionDate)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/payments?customer_id=GIG00001", nil)
+	rec = httptest.NewRecorder()
+	h.GetCustomerPayments(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp struct {
+		Payments []dto.PaymentRecordResponse `json:"payments"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Payments, 1)
+	assert.Equal(t, "2025-11-24T14:54:16+01:00", resp.Payments[0].TransactionDate, "written back in the zone it was sent in")
+}
+
+func TestProcessPayment_MetadataRoundTrips(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	payments := newFakePaymentRepo()
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), payments, nil, logger)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+	body := strings.NewReplacer(`"PENDING"`, `"COMPLETE"`, `"TXN001"`, `"TXN001", "metadata": {"channel": "ussd"}`).Replace(validPaymentBody)
+
+	rec, _ := postPayment(h, "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotNil(t, payments.payments["TXN001"])
+	assert.Equal(t, map[string]string{"channel": "ussd"}, payments.payments["TXN001"].Metadata)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/payments?customer_id=GIG00001", nil)
+	rec = httptest.NewRecorder()
+	h.GetCustomerPayments(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp struct {
+		Payments []dto.PaymentRecordResponse `json:"payments"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Payments, 1)
+	assert.Equal(t, map[string]string{"channel": "ussd"}, resp.Payments[0].Metadata)
+
+	oversized := strings.Replace(body, `"ussd"`, `"`+strings.Repeat("u", domain.MaxPaymentMetadataValueLength+1)+`"`, 1)
+	rec, errResp := postPayment(h, "application/json", strings.Replace(oversized, "TXN001", "TXN002", 1))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "validation failed", errResp.Error)
+	assert.Equal(t, dto.ErrorCodeValidation, errResp.Code)
+	require.NotNil(t, errResp.Retryable)
+	assert.False(t, *errResp.Retryable)
+	assert.Contains(t, errResp.Details["metadata"], "at most 256 bytes")
+	assert.Nil(t, payments.payments["TXN002"])
+}
+
+func TestProcessPayment_ZeroDecimalCurrency(t *testing.T) {
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(&domain.Customer{
+		ID: "GIG00001", AssetValue: 5000000, RepaymentTermWeeks: 50, OutstandingBalance: 5000000,
+		Status: domain.CustomerStatusActive, Version: 1, CurrencyCode: "UGX",
+	})
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{CurrencySymbol: dto.DefaultCurrencySymbol}, logger)
+
+	ugx := strings.NewReplacer(`"PENDING"`, `"COMPLETE"`, `"TXN001"`, `"TXN001", "currency": "ugx"`).Replace(validPaymentBody)
+	rec, _ := postPayment(h, "application/json", ugx)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp dto.PaymentResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(4990000), resp.OutstandingBalance, "10000 shillings, with no minor unit")
+
+	rec, errResp := postPayment(h, "application/json", strings.Replace(ugx, `"10000"`, `"10000.50"`, 1))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "invalid transaction amount", errResp.Error)
+
+	rec, _ = getCustomer(h, "/api/v1/customers/GIG00001")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var customer dto.CustomerResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &customer))
+	assert.Equal(t, "UGX", customer.Currency)
+	assert.Equal(t, "USh4,990,000", customer.OutstandingBalanceFormatted, "the naira symbol is only for NGN")
+}
+
+func TestProcessPayment_CurrencyMismatchReturns422(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{
+		ID: "GIG00001", AssetValue: 5000000, OutstandingBalance: 5000000,
+		Status: domain.CustomerStatusActive, Version: 1, CurrencyCode: "UGX",
+	}
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+
+	// No currency means the default, NGN
+	body := strings.Replace(validPaymentBody, `"PENDING"`, `"COMPLETE"`, 1)
+	rec, errResp := postPayment(h, "application/json", body)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, errResp.Error, "currency")
+	assert.Equal(t, int64(5000000), customer.OutstandingBalance)
+
+	h.config.DefaultCurrency = domain.CurrencyUGX
+	rec, _ = postPayment(h, "application/json", body)
+	assert.Equal(t, http.StatusOK, rec.Code, "a deployment may default to another currency")
+}
+
+func TestProcessPayment_CaseAndWhitespaceVariantsDedup(t *testing.T) {
+	logger := zap.NewNop()
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+	customers := newFakeCustomerRepo(customer)
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+
+	first := strings.NewReplacer(`"PENDING"`, `"COMPLETE"`, `"GIG00001"`, `"  gig00001 "`, `"TXN001"`, `" TXN001"`).Replace(validPaymentBody)
+	rec, _ := postPayment(h, "application/json", first)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.PaymentResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "PROCESSED", resp.Outcome)
+	assert.Equal(t, "GIG00001", resp.CustomerID)
+	assert.Equal(t, int64(99000000), resp.OutstandingBalance)
+
+	second := strings.NewReplacer(`"PENDING"`, `"COMPLETE"`).Replace(validPaymentBody)
+	rec, _ = postPayment(h, "application/json", second)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "DUPLICATE", resp.Outcome)
+	assert.Contains(t, resp.Message, "duplicate")
+	assert.Equal(t, int64(99000000), resp.OutstandingBalance)
+}
+
+func TestProcessPayment_WhitespaceOnlyFieldsRejected(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	body := strings.Replace(validPaymentBody, `"GIG00001"`, `"   "`, 1)
+	rec, errResp := postPayment(h, "application/json", body)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "customer_id is required", errResp.Message)
+}
+
+func postCustomersBatch(h *PaymentHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/customers/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.GetCustomersBatch(rec, req)
+	return rec
+}
+
+func TestGetCustomersBatch(t *testing.T) {
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(
+		&domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive},
+		&domain.Customer{ID: "GIG00002", AssetValue: 100000000, OutstandingBalance: 0, TotalPaid: 100000000, Status: domain.CustomerStatusCompleted},
+	)
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{MaxBatchCustomerIDs: 3}, logger)
+
+	rec := postCustomersBatch(h, `{"customer_ids": ["GIG00001", " gig00002", "GIG00404", "GIG00001"]}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code, "four IDs exceed the cap before de-duplication")
+
+	rec = postCustomersBatch(h, `{"customer_ids": ["GIG00001", " gig00002", "GIG00404"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.BatchCustomersResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Customers, 2)
+	assert.Equal(t, int64(100000000), resp.Customers["GIG00001"].OutstandingBalance)
+	assert.True(t, resp.Customers["GIG00002"].IsFullyPaid)
+	assert.Equal(t, []string{"GIG00404"}, resp.NotFound)
+}
+
+func TestGetCustomersBatch_ValidationErrors(t *testing.T) {
+	h := newTestPaymentHandler(Config{MaxBatchCustomerIDs: 10})
+
+	for _, body := range []string{`{}`, `{"customer_ids": []}`, `{"customer_ids": ["GIG00001", "  "]}`} {
+		rec := postCustomersBatch(h, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func newDateRangeHandler() *PaymentHandler {
+	base := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	payments := newFakePaymentRepo(
+		&domain.Payment{ID: "p1", CustomerID: "GIG00001", Amount: 1000, TransactionReference: "TXN1", TransactionDate: base},
+		&domain.Payment{ID: "p2", CustomerID: "GIG00002", Amount: 2000, TransactionReference: "TXN2", TransactionDate: base.Add(time.Hour)},
+		&domain.Payment{ID: "p3", CustomerID: "GIG00001", Amount: 4000, TransactionReference: "TXN3", TransactionDate: base.AddDate(0, 0, 1)},
+		&domain.Payment{ID: "p4", CustomerID: "GIG00002", Amount: 8000, TransactionReference: "TXN4", TransactionDate: base.AddDate(0, 0, 5)},
+	)
+	logger := zap.NewNop()
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(), payments, nil, logger,
+		service.WithMaxPaymentDateRange(7*24*time.Hour))
+	return NewPaymentHandler(paymentService, Config{}, logger)
+}
+
+type dateRangeResponse struct {
+	Payments []dto.PaymentRecordResponse `json:"payments"`
+	Totals   struct {
+		Count  int64 `json:"count"`
+		Amount int64 `json:"amount"`
+	} `json:"totals"`
+	Pagination struct {
+		TotalPages int `json:"total_pages"`
+	} `json:"pagination"`
+}
+
+func getPaymentsByRange(h *PaymentHandler, query string) (*httptest.ResponseRecorder, dateRangeResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/payments?"+query, nil)
+	rec := httptest.NewRecorder()
+	h.GetCustomerPayments(rec, req)
+
+	var resp dateRangeResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestGetPaymentsByDateRange_Bounded(t *testing.T) {
+	h := newDateRangeHandler()
+
+	rec, resp := getPaymentsByRange(h, "from=2025-11-24&to=2025-11-25&customer_id=gig00001")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, resp.Payments, 2)
+	assert.Equal(t, "TXN3", resp.Payments[0].TransactionReference)
+	assert.Equal(t, "TXN1", resp.Payments[1].TransactionReference)
+	assert.Equal(t, int64(2), resp.Totals.Count)
+	assert.Equal(t, int64(5000), resp.Totals.Amount)
+}
+
+func TestGetPaymentsByDateRange_AllCustomersPaginated(t *testing.T) {
+	h := newDateRangeHandler()
+
+	rec, resp := getPaymentsByRange(h, "from=2025-11-24&to=2025-11-25&page_size=2&page=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, resp.Payments, 1)
+	assert.Equal(t, "TXN1", resp.Payments[0].TransactionReference)
+	assert.Equal(t, int64(3), resp.Totals.Count)
+	assert.Equal(t, int64(7000), resp.Totals.Amount)
+	assert.Equal(t, 2, resp.Pagination.TotalPages)
+}
+
+func TestGetPaymentsByDateRange_Rejected(t *testing.T) {
+	h := newDateRangeHandler()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"inverted", "from=2025-11-25&to=2025-11-24"},
+		{"too wide", "from=2025-11-01&to=2025-11-30"},
+		{"missing to", "from=2025-11-24"},
+		{"bad date", "from=24/11/2025&to=2025-11-25"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := getPaymentsByRange(h, tt.query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func getCustomer(h *PaymentHandler, path string) (*httptest.ResponseRecorder, dto.ErrorResponse) {
+	r := chi.NewRouter()
+	r.Get("/api/v1/customers/{customer_id}", h.GetCustomer)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var errResp dto.ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &errResp)
+	return rec, errResp
+}
+
+func TestGetCustomer_MalformedIDsRejected(t *testing.T) {
+	format, err := dto.NewCustomerIDFormat(dto.DefaultCustomerIDPattern)
+	require.NoError(t, err)
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(&domain.Customer{ID: "GIG00001", AssetValue: 1000, RepaymentTermWeeks: 10, OutstandingBalance: 1000, Status: domain.CustomerStatusActive})
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{CustomerIDFormat: format}, logger)
+
+	tests := []struct {
+		name     string
+		path     string
+		want     int
+		wantCode string
+	}{
+		{"too long", "/api/v1/customers/GIG" + strings.Repeat("0", 60), http.StatusBadRequest, dto.ErrorCodeBadRequest},
+		{"wrong pattern", "/api/v1/customers/GIG0001", http.StatusBadRequest, dto.ErrorCodeBadRequest},
+		{"encoded traversal", "/api/v1/customers/GIG0001%2F..%2Fadmin", http.StatusBadRequest, dto.ErrorCodeBadRequest},
+		{"valid but missing", "/api/v1/customers/GIG99999", http.StatusNotFound, ""},
+		{"valid", "/api/v1/customers/gig00001", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, errResp := getCustomer(h, tt.path)
+			assert.Equal(t, tt.want, rec.Code)
+			assert.Equal(t, tt.wantCode, errResp.Code)
+			if tt.want == http.StatusBadRequest {
+				assert.Equal(t, "invalid customer_id", errResp.Error)
+				assert.NotEmpty(t, errResp.Message)
+			}
+		})
+	}
+}
+
+func TestGetCustomerPayments_MalformedCustomerIDRejected(t *testing.T) {
+	h := newDateRangeHandler()
+
+	rec, _ := getPaymentsByRange(h, "customer_id="+strings.Repeat("G", 51))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = getPaymentsByRange(h, "customer_id=GIG0001/../admin")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetCustomer_FormatsAmountsInNaira(t *testing.T) {
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(&domain.Customer{
+		ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50,
+		OutstandingBalance: 99997990, TotalPaid: 2010, Status: domain.CustomerStatusActive,
+	})
+	paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+	h := NewPaymentHandler(paymentService, Config{CurrencySymbol: dto.DefaultCurrencySymbol}, logger)
+
+	rec, _ := getCustomer(h, "/api/v1/customers/GIG00001")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp dto.CustomerResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(100000000), resp.AssetValue, "raw kobo is kept")
+	assert.Equal(t, "₦1,000,000.00", resp.AssetValueFormatted)
+	assert.Equal(t, "₦999,979.90", resp.OutstandingBalanceFormatted)
+	assert.Equal(t, "₦20.10", resp.TotalPaidFormatted)
+	assert.Equal(t, "₦20,000.00", resp.ExpectedWeeklyAmountFormatted)
+}
+
+func TestGetCustomerProjection(t *testing.T) {
+	logger := zap.NewNop()
+	deployed := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	now := deployed.Add(7*24*time.Hour + time.Hour)
+	customers := newFakeCustomerRepo(
+		&domain.Customer{ID: "GIG00001", AssetValue: 1000, RepaymentTermWeeks: 3, OutstandingBalance: 666, TotalPaid: 334, DeploymentDate: deployed, Status: domain.CustomerStatusActive},
+		&domain.Customer{ID: "GIG00002", AssetValue: 1000, RepaymentTermWeeks: 3, TotalPaid: 1000, DeploymentDate: deployed, Status: domain.CustomerStatusCompleted},
+	)
+	payments := newFakePaymentRepo(&domain.Payment{CustomerID: "GIG00001", Amount: 334, TransactionReference: "TXN001", TransactionDate: deployed.Add(6 * 24 * time.Hour)})
+	paymentService := service.NewPaymentService(customers, payments, nil, logger, service.WithClock(domain.ClockFunc(func() time.Time { return now })))
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+	r := chi.NewRouter()
+	r.Get("/api/v1/customers/{customer_id}/projection", h.GetCustomerProjection)
+
+	get := func(path string) (int, dto.PayoffProjectionResponse) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp dto.PayoffProjectionResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	code, resp := get("/api/v1/customers/GIG00001/projection")
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, resp.Completed)
+	assert.Equal(t, int64(334), resp.WeeklyPace)
+	require.NotNil(t, resp.OnSchedule)
+	assert.Equal(t, 2, resp.OnSchedule.WeeksRemaining)
+	assert.Equal(t, "2025-01-27T09:00:00Z", resp.OnSchedule.PayoffDate)
+	require.NotNil(t, resp.AtCurrentPace)
+	assert.Equal(t, 2, resp.AtCurrentPace.WeeksRemaining)
+
+	code, resp = get("/api/v1/customers/GIG00002/projection")
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.Completed)
+	assert.Nil(t, resp.OnSchedule)
+	assert.Nil(t, resp.AtCurrentPace)
+
+	code, _ = get("/api/v1/customers/GIG99999/projection")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGetPayoffQuote(t *testing.T) {
+	logger := zap.NewNop()
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	customers := newFakeCustomerRepo(
+		&domain.Customer{ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50, OutstandingBalance: 60000050, TotalPaid: 39999950, Status: domain.CustomerStatusActive},
+		&domain.Customer{ID: "GIG00002", AssetValue: 100000000, RepaymentTermWeeks: 50, TotalPaid: 100000000, Status: domain.CustomerStatusCompleted},
+	)
+	newRouter := func(discountBPS int64) chi.Router {
+		paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger,
+			service.WithClock(domain.ClockFunc(func() time.Time { return now })),
+			service.WithEarlyPayoffDiscount(discountBPS),
+		)
+		h := NewPaymentHandler(paymentService, Config{CurrencySymbol: "₦"}, logger)
+		r := chi.NewRouter()
+		r.Get("/api/v1/customers/{customer_id}/payoff-quote", h.GetPayoffQuote)
+		return r
+	}
+	get := func(r chi.Router, path string) (int, dto.PayoffQuoteResponse) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp dto.PayoffQuoteResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	t.Run("without a discount", func(t *testing.T) {
+		code, resp := get(newRouter(0), "/api/v1/customers/GIG00001/payoff-quote")
+
+		require.Equal(t, http.StatusOK, code)
+		assert.False(t, resp.Completed)
+		assert.Equal(t, "NGN", resp.Currency)
+		assert.Equal(t, int64(60000050), resp.OutstandingBalance)
+		assert.Zero(t, resp.Discount)
+		assert.Equal(t, int64(60000050), resp.PayoffAmount)
+		assert.Equal(t, "₦600,000.50", resp.PayoffAmountFormatted)
+		assert.Empty(t, resp.Message)
+		assert.Equal(t, "2025-11-24T09:00:00Z", resp.AsOf)
+	})
+
+	t.Run("with a discount", func(t *testing.T) {
+		code, resp := get(newRouter(250), "/api/v1/customers/GIG00001/payoff-quote")
+
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, int64(250), resp.DiscountBasisPoints)
+		assert.Equal(t, int64(60000050), resp.OutstandingBalance)
+		assert.Equal(t, int64(1500001), resp.Discount)
+		assert.Equal(t, int64(58500049), resp.PayoffAmount)
+		assert.Equal(t, "₦15,000.01", resp.DiscountFormatted)
+		assert.Equal(t, "₦585,000.49", resp.PayoffAmountFormatted)
+		assert.Equal(t, int64(60000050), customers.customers["GIG00001"].OutstandingBalance, "quoting changes nothing")
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		code, resp := get(newRouter(250), "/api/v1/customers/GIG00002/payoff-quote")
+
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, resp.Completed)
+		assert.Zero(t, resp.PayoffAmount)
+		assert.Equal(t, "₦0.00", resp.PayoffAmountFormatted)
+		assert.Contains(t, resp.Message, "already fully paid")
+	})
+
+	code, _ := get(newRouter(0), "/api/v1/customers/GIG99999/payoff-quote")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGetWeeklyPayments(t *testing.T) {
+	logger := zap.NewNop()
+	deployed := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	now := deployed.Add(3*week + 2*24*time.Hour)
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 1000000, RepaymentTermWeeks: 4, DeploymentDate: deployed, OutstandingBalance: 450000, TotalPaid: 550000, Status: domain.CustomerStatusActive}
+	payments := newFakePaymentRepo(
+		&domain.Payment{ID: "p1", CustomerID: "GIG00001", Amount: 250000, TransactionReference: "TXN1", TransactionDate: deployed.Add(time.Hour), Status: domain.PaymentStatusComplete},
+		&domain.Payment{ID: "p2", CustomerID: "GIG00001", Amount: 100000, TransactionReference: "TXN2", TransactionDate: deployed.Add(week + time.Hour), Status: domain.PaymentStatusComplete},
+		&domain.Payment{ID: "p3", CustomerID: "GIG00001", Amount: 50000, TransactionReference: "TXN3", TransactionDate: deployed.Add(week + 2*time.Hour), Status: domain.PaymentStatusComplete},
+		&domain.Payment{ID: "p4", CustomerID: "GIG00001", Amount: 70000, TransactionReference: "TXN4", TransactionDate: deployed.Add(2*week + time.Hour), Status: domain.PaymentStatusPending},
+		&domain.Payment{ID: "p5", CustomerID: "GIG00001", Amount: 150000, TransactionReference: "TXN5", TransactionDate: deployed.Add(3*week + time.Hour), Status: domain.PaymentStatusComplete},
+	)
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), payments, nil, logger,
+		service.WithClock(domain.ClockFunc(func() time.Time { return now })),
+	)
+	h := NewPaymentHandler(paymentService, Config{CurrencySymbol: "₦"}, logger)
+	r := chi.NewRouter()
+	r.Get("/api/v1/customers/{customer_id}/payments/weekly", h.GetWeeklyPayments)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/customers/gig00001/payments/weekly", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp dto.WeeklyPaymentsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	assert.Equal(t, "GIG00001", resp.CustomerID)
+	assert.Equal(t, "2025-01-06T09:00:00Z", resp.DeploymentDate)
+	require.Len(t, resp.Weeks, 4)
+	assert.Equal(t, dto.WeeklyPaymentEntry{
+		Week: 1, Start: "2025-01-06T09:00:00Z", End: "2025-01-13T09:00:00Z",
+		Count: 1, Amount: 250000, AmountFormatted: "₦2,500.00", ExpectedAmount: 250000,
+	}, resp.Weeks[0])
+	assert.Equal(t, int64(2), resp.Weeks[1].Count)
+	assert.Equal(t, int64(150000), resp.Weeks[1].Amount)
+	assert.Zero(t, resp.Weeks[2].Count, "the gap week is listed with nothing in it")
+	assert.Zero(t, resp.Weeks[2].Amount)
+	assert.Equal(t, int64(250000), resp.Weeks[2].ExpectedAmount)
+	assert.Equal(t, int64(150000), resp.Weeks[3].Amount)
+	assert.True(t, resp.Weeks[3].Partial, "the current week is still in progress")
+	assert.False(t, resp.Weeks[2].Partial)
+	assert.Equal(t, int64(4), resp.TotalCount)
+	assert.Equal(t, int64(550000), resp.TotalAmount)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/customers/GIG09999/payments/weekly", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetPaymentsByDateRange_FormatsAmounts(t *testing.T) {
+	h := newDateRangeHandler()
+	h.config.CurrencySymbol = "NGN "
+
+	rec, resp := getPaymentsByRange(h, "from=2025-11-24&to=2025-11-25&customer_id=GIG00001")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, resp.Payments, 2)
+	assert.Equal(t, int64(4000), resp.Payments[0].TransactionAmount)
+	assert.Equal(t, "NGN 40.00", resp.Payments[0].AmountFormatted)
+}
+
+// failingCustomerRepo fails every lookup and save with err
+type failingCustomerRepo struct {
+	*fakeCustomerRepo
+	err error
+}
+
+func (r *failingCustomerRepo) FindByID(ctx context.Context, customerID string) (*domain.Customer, error) {
+	return nil, r.err
+}
+
+func (r *failingCustomerRepo) Save(ctx context.Context, customer *domain.Customer) error {
+	return r.err
+}
+
+func TestContextEndedMapsTo499And504(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		want        int
+		wantPayment int
+	}{
+		{"canceled", fmt.Errorf("%w: %w", domain.ErrRequestCanceled, context.Canceled), statusClientClosedRequest, statusClientClosedRequest},
+		{"timed out", fmt.Errorf("%w: %w", domain.ErrRequestTimeout, context.DeadlineExceeded), http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+		// A payment that may go through if sent again is 503
+		{"database down", errors.New("database error: connection refused"), http.StatusInternalServerError, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			customers := &failingCustomerRepo{fakeCustomerRepo: newFakeCustomerRepo(), err: tt.err}
+			paymentService := service.NewPaymentService(customers, newFakePaymentRepo(), nil, logger)
+			h := NewPaymentHandler(paymentService, Config{}, logger)
+
+			rec, _ := getCustomer(h, "/api/v1/customers/GIG00001")
+			assert.Equal(t, tt.want, rec.Code)
+
+			rec, _ = postPayment(h, "application/json", strings.Replace(validPaymentBody, "PENDING", "COMPLETE", 1))
+			assert.Equal(t, tt.wantPayment, rec.Code)
+		})
+	}
+}
+
+func TestProcessPayment_RetryableErrorsAre5xx(t *testing.T) {
+	body := strings.Replace(validPaymentBody, `"PENDING"`, `"COMPLETE"`, 1)
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive, Version: 1}
+
+	tests := []struct {
+		name      string
+		customers domain.CustomerRepository
+		options   []service.PaymentServiceOption
+		want      int
+		retryable bool
+	}{
+		{
+			name:      "transient database failure",
+			customers: &failingCustomerRepo{fakeCustomerRepo: newFakeCustomerRepo(), err: errors.New("database error: connection refused")},
+			want:      http.StatusServiceUnavailable,
+			retryable: true,
+		},
+		{
+			name:      "unknown customer",
+			customers: newFakeCustomerRepo(),
+			want:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "below the minimum payment",
+			customers: newFakeCustomerRepo(customer),
+			options:   []service.PaymentServiceOption{service.WithMinimumPaymentAmount(5000000)},
+			want:      http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			paymentService := service.NewPaymentService(tt.customers, newFakePaymentRepo(), nil, logger, tt.options...)
+			h := NewPaymentHandler(paymentService, Config{}, logger)
+
+			rec, errResp := postPayment(h, "application/json", body)
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+			require.NotNil(t, errResp.Retryable)
+			assert.Equal(t, tt.retryable, *errResp.Retryable)
+		})
+	}
+}
+
+func TestGetCustomerPaymentsPaginated_LinkHeaders(t *testing.T) {
+	base := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	payments := newFakePaymentRepo()
+	for i := 0; i < 5; i++ {
+		payments.Save(context.Background(), &domain.Payment{
+			ID: fmt.Sprintf("p%d", i), CustomerID: "GIG00001", Amount: 1000,
+			TransactionReference: fmt.Sprintf("TXN%d", i), TransactionDate: base.AddDate(0, 0, i),
+		})
+	}
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(&domain.Customer{ID: "GIG00001", Status: domain.CustomerStatusActive})
+	h := NewPaymentHandler(service.NewPaymentService(customers, payments, nil, logger), Config{}, logger)
+
+	link := func(page int, rel string) string {
+		return fmt.Sprintf(`</api/v1/payments?customer_id=GIG00001&page=%d&page_size=2>; rel="%s"`, page, rel)
+	}
+
+	tests := []struct {
+		name  string
+		page  int
+		links []string
+	}{
+		{"first page", 1, []string{link(1, "first"), link(2, "next"), link(3, "last")}},
+		{"middle page", 2, []string{link(1, "first"), link(1, "prev"), link(3, "next"), link(3, "last")}},
+		{"last page", 3, []string{link(1, "first"), link(2, "prev"), link(3, "last")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := getPaymentsByRange(h, fmt.Sprintf("customer_id=GIG00001&page=%d&page_size=2", tt.page))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, strings.Join(tt.links, ", "), rec.Header().Get("Link"))
+			assert.Equal(t, "5", rec.Header().Get("X-Total-Count"))
+		})
+	}
+}
+
+func TestGetCustomerPaymentsByPath_MatchesQueryRoute(t *testing.T) {
+	base := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	payments := newFakePaymentRepo()
+	for i := 0; i < 5; i++ {
+		payments.Save(context.Background(), &domain.Payment{
+			ID: fmt.Sprintf("p%d", i), CustomerID: "GIG00001", Amount: 1000, Status: domain.PaymentStatusComplete,
+			TransactionReference: fmt.Sprintf("TXN%d", i), TransactionDate: base.AddDate(0, 0, i),
+		})
+	}
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(&domain.Customer{ID: "GIG00001", Status: domain.CustomerStatusActive})
+	h := NewPaymentHandler(service.NewPaymentService(customers, payments, nil, logger), Config{}, logger)
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/payments", h.GetCustomerPayments)
+	r.Get("/api/v1/customers/{customer_id}/payments", h.GetCustomerPaymentsByPath)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	for _, query := range []string{"", "page=2&page_size=2", "cursor=&limit=2", "status=COMPLETE&order=asc", "from=2025-11-25&to=2025-11-27"} {
+		byQuery := get("/api/v1/payments?customer_id=GIG00001&" + query)
+		byPath := get("/api/v1/customers/GIG00001/payments?" + query)
+
+		require.Equal(t, http.StatusOK, byQuery.Code, query)
+		assert.Equal(t, byQuery.Code, byPath.Code, query)
+		assert.JSONEq(t, byQuery.Body.String(), byPath.Body.String(), query)
+		assert.Equal(t, byQuery.Header().Get("X-Total-Count"), byPath.Header().Get("X-Total-Count"), query)
+	}
+
+	rec := get("/api/v1/customers/GIG00001/payments?page=1&page_size=2")
+	assert.Equal(t, `</api/v1/customers/GIG00001/payments?page=1&page_size=2>; rel="first", `+
+		`</api/v1/customers/GIG00001/payments?page=2&page_size=2>; rel="next", `+
+		`</api/v1/customers/GIG00001/payments?page=3&page_size=2>; rel="last"`, rec.Header().Get("Link"))
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/customers/GIG00001/payments?customer_id=GIG00002").Code)
+	assert.Equal(t, http.StatusOK, get("/api/v1/customers/GIG00001/payments?customer_id=gig00001").Code)
+	assert.Equal(t, get("/api/v1/payments?customer_id=GIG09999").Code, get("/api/v1/customers/GIG09999/payments").Code)
+}
+
+func TestGetCustomerPaymentsPaginated_ConfiguredPageSizes(t *testing.T) {
+	base := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	payments := newFakePaymentRepo()
+	for i := 0; i < 8; i++ {
+		ref := fmt.Sprintf("TXN%d", i)
+		payments.payments[ref] = &domain.Payment{ID: ref, CustomerID: "GIG00001", Amount: 1000, TransactionReference: ref, TransactionDate: base.Add(time.Duration(i) * time.Hour), Status: domain.PaymentStatusComplete}
+	}
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 1000000, OutstandingBalance: 992000, Status: domain.CustomerStatusActive}
+	logger := zap.NewNop()
+	paymentService := service.NewPaymentService(newFakeCustomerRepo(customer), payments, nil, logger,
+		service.WithPageSizes(service.PageSizes{Default: 3, Max: 5}),
+	)
+	h := NewPaymentHandler(paymentService, Config{}, logger)
+
+	list := func(query string) (count, pageSize int) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/payments?customer_id=GIG00001&"+query, nil)
+		rec := httptest.NewRecorder()
+		h.GetCustomerPayments(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp struct {
+			Payments   []dto.PaymentRecordResponse `json:"payments"`
+			Pagination struct {
+				PageSize int `json:"page_size"`
+			} `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return len(resp.Payments), resp.Pagination.PageSize
+	}
+
+	count, pageSize := list("page=1")
+	assert.Equal(t, 3, count, "page_size omitted uses the configured default")
+	assert.Equal(t, 3, pageSize)
+
+	count, pageSize = list("page=1&page_size=50")
+	assert.Equal(t, 5, count, "an oversized page is capped at the configured max")
+	assert.Equal(t, 5, pageSize)
+
+	count, _ = list("page=1&page_size=4")
+	assert.Equal(t, 4, count)
+}
+
+// newMixedStatusHandler serves four hourly payments of GIG00001 on
+// 2025-11-24: TXN1 COMPLETE, TXN2 PENDING, TXN3 COMPLETE and TXN4 WRITEOFF.
+// list GETs /payments for the customer with query added and returns the
+// references listed.
+func newMixedStatusHandler() (h *PaymentHandler, list func(query string) (*httptest.ResponseRecorder, []string)) {
+	base := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	payments := newFakePaymentRepo(
+		&domain.Payment{ID: "p1", CustomerID: "GIG00001", Amount: 1000, TransactionReference: "TXN1", TransactionDate: base, Status: domain.PaymentStatusComplete},
+		&domain.Payment{ID: "p2", CustomerID: "GIG00001", Amount: 2000, TransactionReference: "TXN2", TransactionDate: base.Add(time.Hour), Status: domain.PaymentStatusPending},
+		&domain.Payment{ID: "p3", CustomerID: "GIG00001", Amount: 4000, TransactionReference: "TXN3", TransactionDate: base.Add(2 * time.Hour), Status: domain.PaymentStatusComplete},
+		&domain.Payment{ID: "p4", CustomerID: "GIG00001", Amount: 8000, TransactionReference: "TXN4", TransactionDate: base.Add(3 * time.Hour), Status: domain.PaymentStatusWriteOff},
+	)
+	customer := &domain.Customer{ID: "GIG00001", AssetValue: 1000000, OutstandingBalance: 985000, Status: domain.CustomerStatusActive}
+	logger := zap.NewNop()
+	h = NewPaymentHandler(service.NewPaymentService(newFakeCustomerRepo(customer), payments, nil, logger), Config{}, logger)
+
+	list = func(query string) (*httptest.ResponseRecorder, []string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/payments?customer_id=GIG00001&"+query, nil)
+		rec := httptest.NewRecorder()
+		h.GetCustomerPayments(rec, req)
+		var resp struct {
+			Payments []dto.PaymentRecordResponse `json:"payments"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		refs := []string{}
+		for _, p := range resp.Payments {
+			refs = append(refs, p.TransactionReference)
+		}
+		return rec, refs
+	}
+	return h, list
+}
+
+func TestGetCustomerPayments_StatusAndOrder(t *testing.T) {
+	_, list := newMixedStatusHandler()
+
+	rec, refs := list("")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"TXN4", "TXN3", "TXN2", "TXN1"}, refs, "default is unchanged")
+
+	_, refs = list("status=complete")
+	assert.Equal(t, []string{"TXN3", "TXN1"}, refs)
+
+	_, refs = list("status=COMPLETE,PENDING&order=asc")
+	assert.Equal(t, []string{"TXN1", "TXN2", "TXN3"}, refs)
+
+	_, refs = list("status=WRITEOFF&status=PENDING&order=desc")
+	assert.Equal(t, []string{"TXN4", "TXN2"}, refs)
+
+	for _, query := range []string{"status=REVERSED", "order=newest", "status=COMPLETE&page=1&order=newest"} {
+		rec, _ := list(query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestGetCustomerPayments_StatusAndOrderNarrowEveryListing(t *testing.T) {
+	h, list := newMixedStatusHandler()
+
+	rec, refs := list("status=COMPLETE,PENDING&page=1&page_size=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"TXN3", "TXN2"}, refs)
+	assert.Equal(t, "3", rec.Header().Get("X-Total-Count"), "the count is of matching payments")
+
+	_, refs = list("status=COMPLETE,PENDING&order=asc&page=2&page_size=2")
+	assert.Equal(t, []string{"TXN3"}, refs)
+
+	rec, refs = list("status=COMPLETE,PENDING&order=desc&limit=2&cursor=")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"TXN3", "TXN2"}, refs)
+	var cursorResp struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cursorResp))
+	_, refs = list("status=COMPLETE,PENDING&order=desc&limit=2&cursor=" + cursorResp.NextCursor)
+	assert.Equal(t, []string{"TXN1"}, refs)
+
+	_, refs = list("status=COMPLETE&cursor=")
+	assert.Equal(t, []string{"TXN1", "TXN3"}, refs, "cursors still walk oldest first by default")
+
+	rec, refs = list("status=COMPLETE&order=asc&from=2025-11-24&to=2025-11-24")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"TXN1", "TXN3"}, refs)
+	var rangeResp dateRangeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rangeResp))
+	assert.Equal(t, int64(2), rangeResp.Totals.Count)
+	assert.Equal(t, int64(5000), rangeResp.Totals.Amount)
+
+	streamed := decodeNDJSON(t, getPayments(h, "customer_id=GIG00001&status=WRITEOFF,PENDING&order=desc", "application/x-ndjson").Body.Bytes())
+	require.Len(t, streamed, 2)
+	assert.Equal(t, "TXN4", streamed[0].TransactionReference)
+	assert.Equal(t, "TXN2", streamed[1].TransactionReference)
+}
+
+func TestProcessPayment_ValidationListsEveryField(t *testing.T) {
+	h := newTestPaymentHandler(Config{})
+
+	body := strings.NewReplacer(`"GIG00001"`, `""`, `"10000"`, `"lots"`, `"TXN001"`, `""`).Replace(validPaymentBody)
+	rec, errResp := postPayment(h, "application/json", body)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "validation failed", errResp.Error)
+	assert.Equal(t, dto.ErrorCodeValidation, errResp.Code)
+	require.NotNil(t, errResp.Retryable)
+	assert.False(t, *errResp.Retryable)
+	assert.Equal(t, map[string]string{
+		"customer_id":           "is required",
+		"transaction_amount":    "must be a valid number",
+		"transaction_reference": "is required",
+	}, errResp.Details)
+}
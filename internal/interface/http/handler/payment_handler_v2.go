@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/gigmile/payment-service/internal/interface/http/dtov2"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// PaymentHandlerV2 serves /api/v2 from the same PaymentService as v1; only
+// the response shapes differ. Every response, errors included, is a
+// dtov2.Envelope.
+type PaymentHandlerV2 struct {
+	paymentService *service.PaymentService
+	config         Config
+	logger         *zap.Logger
+}
+
+func NewPaymentHandlerV2(paymentService *service.PaymentService, cfg Config, logger *zap.Logger) *PaymentHandlerV2 {
+	return &PaymentHandlerV2{
+		paymentService: paymentService,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// GetCustomer returns the customer as a dtov2.Customer
+func (h *PaymentHandlerV2) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := checkCustomerIDV2(w, h.config.CustomerIDFormat, chi.URLParam(r, "customer_id"))
+	if !ok {
+		return
+	}
+
+	customer, err := h.paymentService.GetCustomer(r.Context(), customerID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCustomerNotFound) {
+			respondErrorV2(w, http.StatusNotFound, "customer not found", err)
+			return
+		}
+		logFailure(h.logger, "failed to get customer", err,
+			zap.String("customer_id", customerID),
+		)
+		respondErrorV2(w, failureStatus(err), "failed to get customer", err)
+		return
+	}
+
+	respondV2(w, http.StatusOK, toCustomerV2(customer, h.config.moneyFormat(), time.Now()), dtov2.Meta{})
+}
+
+// GetCustomerPayments pages through a customer's payments. Unlike v1 the
+// response is always paginated (page 1 of 10 by default).
+func (h *PaymentHandlerV2) GetCustomerPayments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("customer_id") == "" {
+		respondErrorV2(w, http.StatusBadRequest, "customer_id is required", nil)
+		return
+	}
+	customerID, ok := checkCustomerIDV2(w, h.config.CustomerIDFormat, query.Get("customer_id"))
+	if !ok {
+		return
+	}
+
+	params := service.PaginationParams{}
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		params.Page = p
+	}
+	if ps, err := strconv.Atoi(query.Get("page_size")); err == nil && ps > 0 {
+		params.PageSize = ps
+	}
+
+	result, err := h.paymentService.GetCustomerPaymentsPaginated(r.Context(), customerID, params)
+	if err != nil {
+		logFailure(h.logger, "failed to get customer payments", err,
+			zap.String("customer_id", customerID),
+		)
+		respondErrorV2(w, failureStatus(err), "failed to get customer payments", err)
+		return
+	}
+
+	payments := make([]dtov2.Payment, len(result.Payments))
+	for i, payment := range result.Payments {
+		payments[i] = toPaymentV2(payment, h.config.moneyFormat(), h.config.location())
+	}
+	setPaginationHeaders(w, r, result.Page, result.PageSize, result.TotalPages, result.TotalCount)
+
+	respondV2(w, http.StatusOK, payments, dtov2.Meta{
+		CustomerID: customerID,
+		Pagination: &dto.Pagination{
+			Page:       result.Page,
+			PageSize:   result.PageSize,
+			TotalCount: result.TotalCount,
+			TotalPages: result.TotalPages,
+		},
+	})
+}
+
+// respondV2 answers with data in the v2 envelope
+func respondV2(w http.ResponseWriter, status int, data interface{}, meta dtov2.Meta) {
+	respondJSON(w, status, dtov2.Envelope{Data: data, Meta: meta})
+}
+
+// respondErrorV2 is respondError in the v2 envelope
+func respondErrorV2(w http.ResponseWriter, status int, message string, err error) {
+	respondJSON(w, status, dtov2.ErrorEnvelope(errorResponse(message, err)))
+}
+
+// checkCustomerIDV2 is checkCustomerID in the v2 envelope
+func checkCustomerIDV2(w http.ResponseWriter, format *dto.CustomerIDFormat, id string) (string, bool) {
+	id, invalid := validateCustomerID(format, id)
+	if invalid != nil {
+		respondJSON(w, http.StatusBadRequest, dtov2.ErrorEnvelope(*invalid))
+		return "", false
+	}
+	return id, true
+}
+
+func toCustomerV2(customer *domain.Customer, money moneyFormat, now time.Time) dtov2.Customer {
+	currency := customer.Currency()
+	symbol := money.symbol(currency)
+	return dtov2.Customer{
+		ID:     customer.ID,
+		Status: string(customer.Status),
+		Asset: dtov2.Asset{
+			Value:              dtov2.NewMoney(customer.AssetValue, currency, symbol),
+			RepaymentTermWeeks: customer.RepaymentTermWeeks,
+		},
+		Balance: dtov2.Balance{
+			Outstanding:     dtov2.NewMoney(customer.OutstandingBalance, currency, symbol),
+			Paid:            dtov2.NewMoney(customer.TotalPaid, currency, symbol),
+			ProgressPercent: fmt.Sprintf("%.2f", customer.GetPaymentProgress()),
+			FullyPaid:       customer.IsFullyPaid(),
+		},
+		Schedule: dtov2.Schedule{
+			WeeklyInstallment:  dtov2.NewMoney(customer.ExpectedWeeklyAmount(), currency, symbol),
+			Arrears:            dtov2.NewMoney(customer.Arrears(now), currency, symbol),
+			MissedInstallments: customer.MissedInstallments(now),
+		},
+	}
+}
+
+func toPaymentV2(payment *domain.Payment, money moneyFormat, loc *time.Location) dtov2.Payment {
+	currency := payment.Currency()
+	symbol := money.symbol(currency)
+	record := dtov2.Payment{
+		ID:              payment.ID,
+		CustomerID:      payment.CustomerID,
+		Amount:          dtov2.NewMoney(payment.Amount, currency, symbol),
+		Reference:       payment.TransactionReference,
+		TransactionDate: formatTime(payment.TransactionDate, loc),
+		Status:          string(payment.Status),
+	}
+	if !payment.ProcessedAt.IsZero() {
+		processedAt := formatTime(payment.ProcessedAt, loc)
+		record.ProcessedAt = &processedAt
+	}
+	if payment.BalanceAfter != nil {
+		balance := dtov2.NewMoney(*payment.BalanceAfter, currency, symbol)
+		record.BalanceAfter = &balance
+	}
+	return record
+}
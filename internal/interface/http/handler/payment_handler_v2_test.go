@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/gigmile/payment-service/internal/interface/http/dtov2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newVersionedRouter mounts the v1 and v2 handlers over one PaymentService
+func newVersionedRouter() http.Handler {
+	logger := zap.NewNop()
+	customers := newFakeCustomerRepo(&domain.Customer{
+		ID: "GIG00001", AssetValue: 100000000, RepaymentTermWeeks: 50,
+		OutstandingBalance: 99997990, TotalPaid: 2010, Status: domain.CustomerStatusActive,
+		DeploymentDate: time.Now(),
+	})
+	payments := newFakePaymentRepo(&domain.Payment{
+		ID: "p1", CustomerID: "GIG00001", Amount: 2010, TransactionReference: "TXN1",
+		TransactionDate: time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC), Status: domain.PaymentStatusComplete,
+	})
+	paymentService := service.NewPaymentService(customers, payments, nil, logger)
+	// The default pattern always compiles
+	format, _ := dto.NewCustomerIDFormat(dto.DefaultCustomerIDPattern)
+	cfg := Config{CurrencySymbol: dto.DefaultCurrencySymbol, CustomerIDFormat: format}
+	v1 := NewPaymentHandler(paymentService, cfg, logger)
+	v2 := NewPaymentHandlerV2(paymentService, cfg, logger)
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/customers/{customer_id}", v1.GetCustomer)
+	r.Get("/api/v1/payments", v1.GetCustomerPayments)
+	r.Get("/api/v2/customers/{customer_id}", v2.GetCustomer)
+	r.Get("/api/v2/payments", v2.GetCustomerPayments)
+	return r
+}
+
+func getJSON(t *testing.T, r http.Handler, path string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body
+}
+
+func TestGetCustomer_V1AndV2Shapes(t *testing.T) {
+	r := newVersionedRouter()
+
+	v1 := getJSON(t, r, "/api/v1/customers/GIG00001")
+	assert.Equal(t, "GIG00001", v1["customer_id"])
+	assert.Equal(t, float64(99997990), v1["outstanding_balance"], "v1 amounts are kobo numbers")
+	assert.NotContains(t, v1, "balance")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/customers/GIG00001", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var v2 dtov2.Customer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dtov2.Envelope{Data: &v2}))
+
+	assert.Equal(t, "GIG00001", v2.ID)
+	assert.Equal(t, dtov2.Money{Kobo: "99997990", Naira: "999979.90", Formatted: "₦999,979.90", Currency: "NGN"}, v2.Balance.Outstanding)
+	assert.Equal(t, "20.10", v2.Balance.Paid.Naira)
+	assert.Equal(t, "0.00", v2.Balance.ProgressPercent)
+	assert.Equal(t, "2000000", v2.Schedule.WeeklyInstallment.Kobo)
+	assert.Equal(t, 50, v2.Asset.RepaymentTermWeeks)
+
+	raw := getJSON(t, r, "/api/v2/customers/GIG00001")["data"].(map[string]interface{})
+	assert.NotContains(t, raw, "customer_id")
+	assert.NotContains(t, raw, "outstanding_balance")
+}
+
+func TestGetCustomerPayments_V1AndV2Shapes(t *testing.T) {
+	r := newVersionedRouter()
+
+	v1 := getJSON(t, r, "/api/v1/payments?customer_id=GIG00001")
+	require.Contains(t, v1, "payments")
+	record := v1["payments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(2010), record["transaction_amount"])
+	assert.Equal(t, "0001-01-01T00:00:00Z", record["processed_at"], "v1 renders a zero processed_at")
+
+	v2 := getJSON(t, r, "/api/v2/payments?customer_id=gig00001")
+	assert.NotContains(t, v2, "payments")
+	meta := v2["meta"].(map[string]interface{})
+	assert.Equal(t, "GIG00001", meta["customer_id"])
+	data := v2["data"].([]interface{})
+	require.Len(t, data, 1)
+	payment := data[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"kobo": "2010", "naira": "20.10", "formatted": "₦20.10", "currency": "NGN",
+	}, payment["amount"])
+	assert.Equal(t, "TXN1", payment["reference"])
+	assert.Nil(t, payment["processed_at"], "v2 reports a missing processed_at as null")
+
+	pagination := meta["pagination"].(map[string]interface{})
+	assert.Equal(t, float64(1), pagination["page"])
+	assert.Equal(t, float64(10), pagination["page_size"])
+	assert.Equal(t, float64(1), pagination["total_count"])
+}
+
+func TestGetCustomerPaymentsV2_RequiresCustomerID(t *testing.T) {
+	r := newVersionedRouter()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/payments", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestV2_EveryResponseIsAnEnvelope(t *testing.T) {
+	r := newVersionedRouter()
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"customer", "/api/v2/customers/GIG00001", http.StatusOK},
+		{"payments", "/api/v2/payments?customer_id=GIG00001", http.StatusOK},
+		{"unknown customer", "/api/v2/customers/GIG09999", http.StatusNotFound},
+		{"malformed customer ID", "/api/v2/customers/nope", http.StatusBadRequest},
+		{"payments without customer", "/api/v2/payments", http.StatusBadRequest},
+		{"payments for malformed customer ID", "/api/v2/payments?customer_id=nope", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var body map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.ElementsMatch(t, []string{"data", "meta", "error"}, keys(body))
+			assert.Equal(t, byte('{'), body["meta"][0], "meta is always an object")
+
+			if tt.status == http.StatusOK {
+				assert.NotEqual(t, "null", string(body["data"]))
+				assert.Equal(t, "null", string(body["error"]))
+				return
+			}
+			assert.Equal(t, "null", string(body["data"]))
+			var errResp dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(body["error"], &errResp))
+			assert.NotEmpty(t, errResp.Error)
+		})
+	}
+}
+
+func keys(m map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
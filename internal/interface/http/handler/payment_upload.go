@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"go.uber.org/zap"
+)
+
+const (
+	csvContentType = "text/csv"
+	// uploadFormField is the multipart field carrying the CSV file
+	uploadFormField = "file"
+
+	defaultUploadConcurrency = 4
+	defaultMaxUploadBytes    = 10 << 20
+)
+
+// uploadColumns are the CSV header names; they match the JSON fields of
+// POST /payments. An optional currency column gives each row's currency, as
+// the currency field does there; other columns are ignored.
+var uploadColumns = []string{
+	"customer_id",
+	"payment_status",
+	"transaction_amount",
+	"transaction_date",
+	"transaction_reference",
+}
+
+// UploadPayments processes a bank settlement CSV sent as multipart/form-data
+// in the "file" field. Rows are read as they stream in and each one goes
+// through ProcessPayment, a bounded number at a time. Rows that can't be
+// processed are skipped with a reason rather than failing the upload. The
+// summary is JSON, or the per-row results as CSV when the client accepts
+// text/csv.
+func (h *PaymentHandler) UploadPayments(w http.ResponseWriter, r *http.Request) {
+	maxBytes := h.config.MaxUploadBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxUploadBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		h.respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be multipart/form-data", err)
+		return
+	}
+	file, err := findFormFile(reader, uploadFormField)
+	if err != nil {
+		h.respondError(w, uploadReadStatus(err), fmt.Sprintf("multipart field %q with the CSV file is required", uploadFormField), err)
+		return
+	}
+
+	rows := csv.NewReader(file)
+	rows.FieldsPerRecord = -1
+	rows.TrimLeadingSpace = true
+
+	header, err := rows.Read()
+	if errors.Is(err, io.EOF) {
+		h.respondError(w, http.StatusBadRequest, "CSV file is empty", nil)
+		return
+	}
+	if err != nil {
+		h.respondError(w, uploadReadStatus(err), "failed to read CSV header", err)
+		return
+	}
+	columns, err := uploadColumnIndex(header)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid CSV header", err)
+		return
+	}
+
+	summary, aborted := h.processUploadRows(r.Context(), rows, len(header), columns)
+
+	status := http.StatusOK
+	if aborted != nil {
+		status = uploadReadStatus(aborted)
+		summary.Aborted = aborted.Error()
+		h.logger.Warn("payment upload stopped early",
+			zap.Error(aborted),
+			zap.Int("rows", summary.Rows),
+		)
+	}
+
+	h.logger.Info("payment upload processed",
+		zap.Int("rows", summary.Rows),
+		zap.Int("processed", summary.Processed),
+		zap.Int("not_processed", summary.NotProcessed),
+		zap.Int("skipped", summary.Skipped),
+		zap.Int("failed", summary.Failed),
+	)
+
+	if acceptsMediaType(r, csvContentType) {
+		writeUploadResultsCSV(w, status, summary)
+		return
+	}
+	h.respondJSON(w, status, summary)
+}
+
+// processUploadRows reads rows until the end of the file and waits for
+// every row it started. aborted is the error that stopped reading early.
+func (h *PaymentHandler) processUploadRows(ctx context.Context, rows *csv.Reader, width int, columns map[string]int) (summary *dto.PaymentUploadResponse, aborted error) {
+	concurrency := h.config.UploadConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultUploadConcurrency
+	}
+
+	var (
+		mu      sync.Mutex
+		results []dto.PaymentUploadRow
+		wg      sync.WaitGroup
+	)
+	record := func(row dto.PaymentUploadRow) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, row)
+	}
+	skip := func(line int, req *dto.PaymentRequest, reason string) {
+		row := dto.PaymentUploadRow{Row: line, Status: dto.UploadRowSkipped, Reason: reason}
+		if req != nil {
+			row.CustomerID = req.CustomerID
+			row.TransactionReference = req.TransactionReference
+		}
+		record(row)
+	}
+
+	slots := make(chan struct{}, concurrency)
+	firstSeen := make(map[string]int)
+	// Settlement files often hold several rows for one customer; running
+	// those one at a time keeps them from failing each other's optimistic
+	// lock, while different customers still run side by side
+	customerLocks := make(map[string]*sync.Mutex)
+
+	for {
+		fields, err := rows.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			skip(parseErr.StartLine, nil, "malformed CSV: "+parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			aborted = err
+			break
+		}
+
+		line, _ := rows.FieldPos(0)
+		if len(fields) != width {
+			skip(line, nil, fmt.Sprintf("expected %d fields, got %d", width, len(fields)))
+			continue
+		}
+
+		req := &dto.PaymentRequest{
+			CustomerID:           fields[columns["customer_id"]],
+			PaymentStatus:        fields[columns["payment_status"]],
+			TransactionAmount:    dto.Amount(fields[columns["transaction_amount"]]),
+			TransactionDate:      fields[columns["transaction_date"]],
+			TransactionReference: fields[columns["transaction_reference"]],
+		}
+		if i, ok := columns["currency"]; ok {
+			req.Currency = fields[i]
+		}
+		req.Normalize()
+		if err := req.Validate(); err != nil {
+			skip(line, req, err.Error())
+			continue
+		}
+		currency := h.config.requestCurrency(req)
+		amount, err := req.GetAmount(currency)
+		if err != nil {
+			skip(line, req, "invalid transaction amount: "+err.Error())
+			continue
+		}
+		txDate, err := req.GetTransactionDate(h.config.location())
+		if err != nil {
+			skip(line, req, "invalid transaction date: "+err.Error())
+			continue
+		}
+
+		// Rows run concurrently, so a reference repeated within the file
+		// is caught here rather than racing itself in the service
+		customerID := service.NormalizeCustomerID(req.CustomerID)
+		ref := h.config.TxRefRule.Normalize(req.TransactionReference)
+		if h.config.DedupScope.PerCustomer() {
+			ref = customerID + "\x00" + ref
+		}
+		if first, ok := firstSeen[ref]; ok {
+			skip(line, req, fmt.Sprintf("duplicate transaction_reference, first seen on row %d", first))
+			continue
+		}
+		firstSeen[ref] = line
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			aborted = domain.ContextError(ctx, ctx.Err())
+		}
+		if aborted != nil {
+			break
+		}
+
+		lock, ok := customerLocks[customerID]
+		if !ok {
+			lock = &sync.Mutex{}
+			customerLocks[customerID] = lock
+		}
+
+		wg.Add(1)
+		go func(line int, req *dto.PaymentRequest, lock *sync.Mutex) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			lock.Lock()
+			defer lock.Unlock()
+
+			result, err := h.paymentService.ProcessPayment(ctx, service.ProcessPaymentRequest{
+				CustomerID:           req.CustomerID,
+				PaymentStatus:        req.PaymentStatus,
+				TransactionAmount:    amount,
+				TransactionDate:      txDate,
+				TransactionReference: req.TransactionReference,
+				Currency:             currency.Code,
+			})
+			record(h.uploadRowOutcome(line, req, result, err))
+		}(line, req, lock)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Row < results[j].Row })
+	summary = &dto.PaymentUploadResponse{Results: make([]dto.PaymentUploadRow, 0, len(results))}
+	for _, row := range results {
+		summary.Count(row)
+	}
+	return summary, aborted
+}
+
+func (h *PaymentHandler) uploadRowOutcome(line int, req *dto.PaymentRequest, result *service.ProcessPaymentResponse, err error) dto.PaymentUploadRow {
+	row := dto.PaymentUploadRow{
+		Row:                  line,
+		CustomerID:           req.CustomerID,
+		TransactionReference: req.TransactionReference,
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrCustomerNotFound):
+		row.Status, row.Reason = dto.UploadRowFailed, "customer not found"
+	case errors.Is(err, domain.ErrLoanWrittenOff):
+		row.Status, row.Reason = dto.UploadRowFailed, "customer loan has been written off"
+	case errors.Is(err, domain.ErrBelowMinimumPayment):
+		row.Status, row.Reason = dto.UploadRowFailed, "payment is below the minimum accepted amount"
+	case errors.Is(err, domain.ErrExcessiveOverpayment):
+		row.Status, row.Reason = dto.UploadRowFailed, "payment exceeds the outstanding balance by more than the allowed tolerance"
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		row.Status, row.Reason = dto.UploadRowFailed, "payment currency does not match the customer's loan currency"
+	case errors.Is(err, domain.ErrDuplicateTransaction):
+		row.Status, row.Reason = dto.UploadRowFailed, "transaction_reference is already used by a different payment"
+	case err != nil:
+		logFailure(h.logger, "failed to process uploaded payment", err,
+			zap.Int("row", line),
+			zap.String("customer_id", req.CustomerID),
+		)
+		row.Status, row.Reason = dto.UploadRowFailed, "failed to process payment"
+	case result.Processed:
+		row.Status = dto.UploadRowProcessed
+	default:
+		row.Status, row.Reason = dto.UploadRowNotProcessed, result.Reason
+	}
+	return row
+}
+
+// findFormFile skips ahead to the named multipart field
+func findFormFile(reader *multipart.Reader, name string) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == name {
+			return part, nil
+		}
+	}
+}
+
+// uploadColumnIndex maps each required column to its position in header.
+// Names are matched case-insensitively; a missing or repeated required
+// column is an error.
+func uploadColumnIndex(header []string) (map[string]int, error) {
+	index := make(map[string]int, len(uploadColumns))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, dup := index[name]; dup {
+			return nil, fmt.Errorf("column %q appears more than once", name)
+		}
+		index[name] = i
+	}
+
+	var missing []string
+	for _, name := range uploadColumns {
+		if _, ok := index[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return index, nil
+}
+
+// uploadReadStatus is 413 when the upload outgrew its limit and 400 for
+// any other unreadable body
+func uploadReadStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, domain.ErrRequestCanceled), errors.Is(err, domain.ErrRequestTimeout):
+		return failureStatus(err)
+	}
+	return http.StatusBadRequest
+}
+
+func writeUploadResultsCSV(w http.ResponseWriter, status int, summary *dto.PaymentUploadResponse) {
+	w.Header().Set("Content-Type", csvContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="payment-upload-results.csv"`)
+	w.WriteHeader(status)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"row", "customer_id", "transaction_reference", "status", "reason"})
+	for _, row := range summary.Results {
+		out.Write([]string{strconv.Itoa(row.Row), row.CustomerID, row.TransactionReference, row.Status, row.Reason})
+	}
+	out.Flush()
+}
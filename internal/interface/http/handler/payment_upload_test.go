@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const uploadHeader = "customer_id,payment_status,transaction_amount,transaction_date,transaction_reference\n"
+
+func newUploadHandler(cfg Config, payments ...*domain.Payment) (*PaymentHandler, *fakeCustomerRepo) {
+	customers := newFakeCustomerRepo(
+		&domain.Customer{ID: "GIG00001", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive},
+		&domain.Customer{ID: "GIG00002", AssetValue: 100000000, OutstandingBalance: 100000000, Status: domain.CustomerStatusActive},
+	)
+	logger := zap.NewNop()
+	paymentService := service.NewPaymentService(customers, newScopedFakePaymentRepo(cfg.DedupScope, payments...), nil, logger)
+	return NewPaymentHandler(paymentService, cfg, logger), customers
+}
+
+func uploadCSV(h *PaymentHandler, field, content, accept string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("source", "settlement")
+	part, _ := form.CreateFormFile(field, "settlement.csv")
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.UploadPayments(rec, req)
+	return rec
+}
+
+func decodeUpload(t *testing.T, rec *httptest.ResponseRecorder) dto.PaymentUploadResponse {
+	t.Helper()
+	var resp dto.PaymentUploadResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestUploadPayments_WellFormed(t *testing.T) {
+	h, customers := newUploadHandler(Config{UploadConcurrency: 2})
+
+	rec := uploadCSV(h, "file", uploadHeader+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-U1\n"+
+		"GIG00002,COMPLETE,20000.50,2025-11-24 10:00:00,TXN-U2\n"+
+		"GIG00001,COMPLETE,5000,2025-11-24 11:00:00,TXN-U3\n"+
+		"GIG00002,PENDING,5000,2025-11-24 12:00:00,TXN-U4\n"+
+		"gig00001,COMPLETE,1000,2025-11-24 13:00:00,TXN-U5\n", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp := decodeUpload(t, rec)
+	assert.Equal(t, 5, resp.Rows)
+	assert.Equal(t, 4, resp.Processed)
+	assert.Equal(t, 1, resp.NotProcessed)
+	assert.Zero(t, resp.Skipped+resp.Failed)
+	require.Len(t, resp.Results, 5)
+	for i, row := range resp.Results {
+		assert.Equal(t, i+2, row.Row, "results are in file order")
+	}
+	assert.Equal(t, service.ReasonStatusNotComplete, resp.Results[3].Reason)
+
+	first, _ := customers.FindByID(context.Background(), "GIG00001")
+	second, _ := customers.FindByID(context.Background(), "GIG00002")
+	assert.Equal(t, int64(100000000-1000000-500000-100000), first.OutstandingBalance, "every row for the same customer applied")
+	assert.Equal(t, int64(100000000-2000050), second.OutstandingBalance)
+}
+
+func TestUploadPayments_BadRowsAreSkipped(t *testing.T) {
+	h, _ := newUploadHandler(Config{})
+
+	rec := uploadCSV(h, "file", uploadHeader+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-B1\n"+
+		"GIG00001,COMPLETE,ten thousand,2025-11-24 09:00:00,TXN-B2\n"+
+		"GIG00001,COMPLETE,10000\n"+
+		"GIG00001,COMPLETE,10000,yesterday,TXN-B3\n"+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,\"TXN\"B4\"\n"+
+		"GIG99999,COMPLETE,10000,2025-11-24 09:00:00,TXN-B5\n"+
+		"GIG00002,COMPLETE,10000,2025-11-24 09:00:00,TXN-B6\n", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp := decodeUpload(t, rec)
+	assert.Equal(t, 7, resp.Rows)
+	assert.Equal(t, 2, resp.Processed)
+	assert.Equal(t, 4, resp.Skipped)
+	assert.Equal(t, 1, resp.Failed)
+
+	byRow := map[int]dto.PaymentUploadRow{}
+	for _, row := range resp.Results {
+		byRow[row.Row] = row
+	}
+	assert.Contains(t, byRow[3].Reason, "transaction_amount")
+	assert.Equal(t, "expected 5 fields, got 3", byRow[4].Reason)
+	assert.Contains(t, byRow[5].Reason, "transaction_date must be in format")
+	assert.Contains(t, byRow[6].Reason, "malformed CSV")
+	assert.Equal(t, dto.UploadRowFailed, byRow[7].Status)
+	assert.Equal(t, "customer not found", byRow[7].Reason)
+	assert.Equal(t, dto.UploadRowProcessed, byRow[8].Status, "rows after a bad one are still processed")
+}
+
+func TestUploadPayments_DuplicateReferences(t *testing.T) {
+	stored := &domain.Payment{ID: "p0", CustomerID: "GIG00002", Amount: 500, TransactionReference: "TXN-D0", Status: domain.PaymentStatusComplete}
+	h, customers := newUploadHandler(Config{UploadConcurrency: 4}, stored)
+
+	rec := uploadCSV(h, "file", uploadHeader+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-D1\n"+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-D1\n"+
+		"GIG00002,COMPLETE,10000,2025-11-24 09:00:00, TXN-D1 \n"+
+		"GIG00002,COMPLETE,10000,2025-11-24 09:00:00,TXN-D0\n", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp := decodeUpload(t, rec)
+	assert.Equal(t, 2, resp.Processed, "the first TXN-D1 and the already stored TXN-D0")
+	assert.Equal(t, 2, resp.Skipped)
+	assert.Equal(t, "duplicate transaction_reference, first seen on row 2", resp.Results[1].Reason)
+	assert.Equal(t, "duplicate transaction_reference, first seen on row 2", resp.Results[2].Reason)
+
+	first, _ := customers.FindByID(context.Background(), "GIG00001")
+	second, _ := customers.FindByID(context.Background(), "GIG00002")
+	assert.Equal(t, int64(100000000-1000000), first.OutstandingBalance, "TXN-D1 applied once")
+	assert.Equal(t, int64(100000000), second.OutstandingBalance, "a stored reference is not applied again")
+}
+
+func TestUploadPayments_DuplicateReferencesPerCustomer(t *testing.T) {
+	h, customers := newUploadHandler(Config{UploadConcurrency: 4, DedupScope: domain.DedupScopeCustomer})
+
+	rec := uploadCSV(h, "file", uploadHeader+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-C1\n"+
+		"GIG00002,COMPLETE,10000,2025-11-24 09:00:00,TXN-C1\n"+
+		"gig00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-C1\n", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp := decodeUpload(t, rec)
+	assert.Equal(t, 2, resp.Processed, "another customer may reuse the reference")
+	assert.Equal(t, 1, resp.Skipped)
+	assert.Equal(t, "duplicate transaction_reference, first seen on row 2", resp.Results[2].Reason)
+
+	first, _ := customers.FindByID(context.Background(), "GIG00001")
+	second, _ := customers.FindByID(context.Background(), "GIG00002")
+	assert.Equal(t, int64(100000000-1000000), first.OutstandingBalance)
+	assert.Equal(t, int64(100000000-1000000), second.OutstandingBalance)
+}
+
+func TestUploadPayments_ResultsAsCSV(t *testing.T) {
+	h, _ := newUploadHandler(Config{})
+
+	rec := uploadCSV(h, "file", uploadHeader+
+		"GIG00001,COMPLETE,10000,2025-11-24 09:00:00,TXN-C1\n"+
+		"GIG00001,COMPLETE,,2025-11-24 09:00:00,TXN-C2\n", "text/csv")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"row", "customer_id", "transaction_reference", "status", "reason"},
+		{"2", "GIG00001", "TXN-C1", "processed", ""},
+		{"3", "GIG00001", "TXN-C2", "skipped", "transaction_amount is required"},
+	}, records)
+}
+
+func TestUploadPayments_RejectedUploads(t *testing.T) {
+	h, _ := newUploadHandler(Config{MaxUploadBytes: 512})
+
+	tests := []struct {
+		name    string
+		field   string
+		content string
+		status  int
+		message string
+	}{
+		{"no file field", "attachment", uploadHeader, http.StatusBadRequest, "with the CSV file is required"},
+		{"empty file", "file", "", http.StatusBadRequest, "CSV file is empty"},
+		{"missing column", "file", "customer_id,payment_status,transaction_amount,transaction_date\n", http.StatusBadRequest, "invalid CSV header"},
+		{"repeated column", "file", strings.TrimSuffix(uploadHeader, "\n") + ",customer_id\n", http.StatusBadRequest, "invalid CSV header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := uploadCSV(h, tt.field, tt.content, "")
+			require.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.message)
+		})
+	}
+
+	t.Run("not multipart", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/payments/upload", strings.NewReader(uploadHeader))
+		req.Header.Set("Content-Type", "text/csv")
+		rec := httptest.NewRecorder()
+		h.UploadPayments(rec, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		content := uploadHeader + strings.Repeat("GIG00001,COMPLETE,100,2025-11-24 09:00:00,TXN-L\n", 20)
+		rec := uploadCSV(h, "file", content, "")
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		resp := decodeUpload(t, rec)
+		assert.NotEmpty(t, resp.Aborted)
+	})
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"go.uber.org/zap"
+)
+
+// statusClientClosedRequest is nginx's 499, for a client that hung up
+// before the response was ready; net/http has no constant for it
+const statusClientClosedRequest = 499
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func respondError(w http.ResponseWriter, status int, message string, err error) {
+	respondJSON(w, status, errorResponse(message, err))
+}
+
+// errorResponse carries err as the message, plus the per-field details of
+// a validation error
+func errorResponse(message string, err error) dto.ErrorResponse {
+	response := dto.ErrorResponse{
+		Error:   message,
+		Message: "",
+	}
+
+	if err != nil {
+		response.Message = err.Error()
+	}
+	var verr *dto.ValidationError
+	if errors.As(err, &verr) {
+		response.Details = verr.Fields
+	}
+
+	return response
+}
+
+// failureStatus is the status for an unexpected error. A request whose
+// context ended is not a server fault, so it gets 499 or 504 instead of 500.
+func failureStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrRequestCanceled):
+		return statusClientClosedRequest
+	case errors.Is(err, domain.ErrRequestTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// retryableFailureStatus is failureStatus for an error that may clear if
+// the request is sent again: 503 rather than 500, so callers that retry on
+// 5xx know it is worth it
+func retryableFailureStatus(err error) int {
+	if status := failureStatus(err); status != http.StatusInternalServerError {
+		return status
+	}
+	return http.StatusServiceUnavailable
+}
+
+// logFailure logs an unexpected error at the level failureStatus implies:
+// info for a client that went away, warn for a timeout, error otherwise
+func logFailure(logger *zap.Logger, msg string, err error, fields ...zap.Field) {
+	fields = append([]zap.Field{zap.Error(err)}, fields...)
+	switch failureStatus(err) {
+	case statusClientClosedRequest:
+		logger.Info(msg, fields...)
+	case http.StatusGatewayTimeout:
+		logger.Warn(msg, fields...)
+	default:
+		logger.Error(msg, fields...)
+	}
+}
+
+// checkCustomerID normalizes a customer ID taken from the route and answers
+// 400 when it can't possibly be valid, so only well-formed IDs can 404
+func checkCustomerID(w http.ResponseWriter, format *dto.CustomerIDFormat, id string) (string, bool) {
+	id, invalid := validateCustomerID(format, id)
+	if invalid != nil {
+		respondJSON(w, http.StatusBadRequest, invalid)
+		return "", false
+	}
+	return id, true
+}
+
+// validateCustomerID is checkCustomerID without the response, for handlers
+// that answer in another shape
+func validateCustomerID(format *dto.CustomerIDFormat, id string) (string, *dto.ErrorResponse) {
+	id = service.NormalizeCustomerID(id)
+	if err := format.Validate(id); err != nil {
+		return "", &dto.ErrorResponse{
+			Error:   "invalid customer_id",
+			Code:    dto.ErrorCodeBadRequest,
+			Message: err.Error(),
+		}
+	}
+	return id, nil
+}
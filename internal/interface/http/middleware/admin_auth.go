@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"go.uber.org/zap"
+)
+
+// AdminAuth guards admin routes with a static bearer token. With no token
+// configured every request is refused, so admin routes stay closed by default.
+func AdminAuth(token string, logger *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				logger.Warn("admin request unauthorized",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("remote_addr", r.RemoteAddr),
+				)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, r, http.StatusUnauthorized, dto.ErrorResponse{Error: "unauthorized"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
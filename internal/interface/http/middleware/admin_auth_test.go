@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "Bearer nope", http.StatusUnauthorized},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := AdminAuth(tt.token, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/customers/GIG00001/cache", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
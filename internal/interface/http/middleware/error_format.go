@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+type errorFormatKey struct{}
+
+// ErrorFormat lets a route group answer the errors middleware writes in its
+// own shape, such as the /api/v2 envelope, instead of a bare ErrorResponse
+type ErrorFormat func(body dto.ErrorResponse) interface{}
+
+// Middleware applies the format to the errors of middleware that runs after it
+func (f ErrorFormat) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), errorFormatKey{}, f)))
+	})
+}
+
+// ErrorFormats picks the ErrorFormat of the route group a request's path
+// falls under, keyed by path prefix such as "/api/v2". Run it ahead of
+// Recovery, RequestTimeout and the rest so their errors are formatted too;
+// the router hasn't matched a route group by then.
+type ErrorFormats map[string]ErrorFormat
+
+// Middleware applies the format of the longest prefix the path is under;
+// paths under none are left with bare errors
+func (f ErrorFormats) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var matched string
+		for prefix := range f {
+			if len(prefix) > len(matched) && underPrefix(r.URL.Path, prefix) {
+				matched = prefix
+			}
+		}
+		if matched == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		f[matched].Middleware(next).ServeHTTP(w, r)
+	})
+}
+
+// underPrefix reports whether path is prefix or below it, whole segments only
+func underPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// writeError answers with body in the request's ErrorFormat, if it has one
+func writeError(w http.ResponseWriter, r *http.Request, status int, body dto.ErrorResponse) {
+	var response interface{} = body
+	if format, ok := r.Context().Value(errorFormatKey{}).(ErrorFormat); ok {
+		response = format(body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
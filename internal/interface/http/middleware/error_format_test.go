@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestErrorFormat_WrapsMiddlewareErrors(t *testing.T) {
+	wrap := ErrorFormat(func(body dto.ErrorResponse) interface{} {
+		return map[string]interface{}{"error": body, "data": nil}
+	})
+	limited := QueryLimits{MaxPageSize: 10}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	wrap.Middleware(limited).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?page_size=11", nil))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body struct {
+		Error dto.ErrorResponse `json:"error"`
+		Data  interface{}       `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, dto.ErrorCodeBadRequest, body.Error.Code)
+	assert.Contains(t, body.Error.Error, "page_size")
+
+	// Without a format the error is written as is
+	rec = httptest.NewRecorder()
+	limited.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?page_size=11", nil))
+	var bare dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bare))
+	assert.Equal(t, dto.ErrorCodeBadRequest, bare.Code)
+}
+
+func TestErrorFormats_FormatByPathPrefix(t *testing.T) {
+	formats := ErrorFormats{"/api/v2": func(body dto.ErrorResponse) interface{} {
+		return map[string]interface{}{"error": body, "data": nil}
+	}}
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	chain := formats.Middleware(Recovery(zap.NewNop(), false)(RequestTimeout{}.Middleware(panicking)))
+	serve := func(path string, header http.Header) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		chain.ServeHTTP(rec, req)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	for name, header := range map[string]http.Header{
+		"recovered panic": nil,
+		"bad timeout":     {RequestTimeoutHeader: []string{"0"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			body := serve("/api/v2/payments", header)
+			assert.Contains(t, body, "data")
+			assert.IsType(t, map[string]interface{}{}, body["error"])
+
+			for _, path := range []string{"/api/v1/payments", "/api/v20/payments"} {
+				body = serve(path, header)
+				assert.NotContains(t, body, "data", path)
+				assert.IsType(t, "", body["error"], path)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader marks responses served from a stored result
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength bounds the keys clients may send
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodyBytes bounds the body read into memory to fingerprint
+	maxIdempotentBodyBytes = 1 << 20
+	// statusClientClosedRequest is the handlers' 499 for a client that hung
+	// up before the response was ready
+	statusClientClosedRequest = 499
+	// idempotencyLockMargin is how much longer than the longest request a
+	// claim is held, for storing the response once the handler returns
+	idempotencyLockMargin = 30 * time.Second
+	// idempotencyPollInterval is how often a duplicate re-reads the key
+	// while the first request is still running
+	idempotencyPollInterval = 25 * time.Millisecond
+)
+
+// Idempotency record states
+const (
+	idempotencyProcessing = "processing"
+	idempotencyDone       = "done"
+)
+
+// idempotencyRecord is what is stored per key. While the first request runs
+// only State and Fingerprint are set; once it finishes the response is
+// stored alongside them.
+type idempotencyRecord struct {
+	State       string `json:"state"`
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency makes writes safe to retry with an Idempotency-Key header.
+// The first request with a key claims it with SETNX and is processed; its
+// response is kept for the TTL and replayed to every later request with
+// the same key. Keys are soft: once the TTL passes the key is forgotten and
+// a request reusing it is processed again as new.
+//
+// A request that arrives while the first is still running waits up to the
+// configured wait for its result, then gets 409. Only final outcomes are
+// kept: after a 5xx, 499, 429 or 408 the key is released, so a retry is
+// processed again.
+type Idempotency struct {
+	client  redis.UniversalClient
+	keys    keyspace.Prefix
+	ttl     time.Duration
+	wait    time.Duration
+	lockTTL time.Duration
+	logger  *zap.Logger
+}
+
+// NewIdempotency holds each claim for maxRequestTimeout, the longest a
+// request may run (zero is DefaultRequestTimeout), plus a margin. A claim
+// that expired while its request was still running would let a retry be
+// processed a second time; one that outlives it only delays retries after
+// a replica dies mid-request.
+func NewIdempotency(client redis.UniversalClient, keys keyspace.Prefix, ttl, wait, maxRequestTimeout time.Duration, logger *zap.Logger) *Idempotency {
+	if maxRequestTimeout <= 0 {
+		maxRequestTimeout = DefaultRequestTimeout
+	}
+	return &Idempotency{
+		client:  client,
+		keys:    keys,
+		ttl:     ttl,
+		wait:    wait,
+		lockTTL: maxRequestTimeout + idempotencyLockMargin,
+		logger:  logger,
+	}
+}
+
+// Middleware applies the key when one is sent; requests without one pass
+// through untouched. If Redis can't be reached the request is processed
+// without the guarantee rather than refused. A nil Idempotency lets
+// everything through.
+func (i *Idempotency) Middleware(next http.Handler) http.Handler {
+	if i == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeIdempotencyError(w, r, http.StatusBadRequest, dto.ErrorCodeBadRequest,
+				"Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeIdempotencyError(w, r, http.StatusRequestEntityTooLarge, dto.ErrorCodeBadRequest, "request body too large")
+			return
+		case err != nil:
+			writeIdempotencyError(w, r, http.StatusBadRequest, dto.ErrorCodeBadRequest, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		redisKey := i.keys.Key("idempotency:" + key)
+
+		record, claimed, err := i.await(r.Context(), redisKey, fingerprint)
+		switch {
+		case err != nil && r.Context().Err() != nil:
+			// The client is gone; nobody is left to answer
+			return
+		case err != nil:
+			i.logger.Warn("idempotency store unavailable, processing without it",
+				zap.Error(err),
+				zap.String("idempotency_key", key),
+			)
+			next.ServeHTTP(w, r)
+			return
+		case claimed:
+			i.process(w, r, next, redisKey, fingerprint)
+		case record == nil:
+			i.logger.Info("duplicate request still in progress",
+				zap.String("idempotency_key", key),
+				zap.Duration("waited", i.wait),
+			)
+			writeIdempotencyError(w, r, http.StatusConflict, dto.ErrorCodeIdempotencyInProgress,
+				"a request with this Idempotency-Key is still being processed, retry later")
+		case record.Fingerprint != fingerprint:
+			writeIdempotencyError(w, r, http.StatusUnprocessableEntity, dto.ErrorCodeIdempotencyKeyReused,
+				"Idempotency-Key was already used for a different request")
+		default:
+			replayIdempotent(w, record)
+		}
+	})
+}
+
+// await claims the key, or waits for the request holding it to finish. It
+// returns claimed when this request should be processed, the finished
+// record when there is one to replay, or neither when the wait ran out. A
+// record with a different fingerprint is returned straight away, finished
+// or not, since it can never be replayed to this request.
+func (i *Idempotency) await(ctx context.Context, redisKey, fingerprint string) (*idempotencyRecord, bool, error) {
+	claim, err := json.Marshal(idempotencyRecord{State: idempotencyProcessing, Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+
+	deadline := time.Now().Add(i.wait)
+	for {
+		claimed, err := i.client.SetNX(ctx, redisKey, claim, i.lockTTL).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if claimed {
+			return nil, true, nil
+		}
+
+		data, err := i.client.Get(ctx, redisKey).Bytes()
+		switch {
+		case errors.Is(err, redis.Nil):
+			// Released or expired between the two calls; try to claim it again
+			continue
+		case err != nil:
+			return nil, false, err
+		}
+
+		var record idempotencyRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, false, err
+		}
+		if record.State == idempotencyDone || record.Fingerprint != fingerprint {
+			return &record, false, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, false, nil
+		}
+		timer := time.NewTimer(min(idempotencyPollInterval, time.Until(deadline)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// process runs the request that holds the claim and stores its response
+func (i *Idempotency) process(w http.ResponseWriter, r *http.Request, next http.Handler, redisKey, fingerprint string) {
+	capture := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(capture, r)
+
+	w.WriteHeader(capture.status)
+	w.Write(capture.body.Bytes())
+
+	// The outcome must be recorded even if the client has gone, or a retry
+	// would wait out the claim and then be processed a second time
+	ctx := context.WithoutCancel(r.Context())
+	if !finalStatus(capture.status) {
+		if err := i.client.Del(ctx, redisKey).Err(); err != nil {
+			i.logger.Warn("failed to release idempotency key", zap.Error(err))
+		}
+		return
+	}
+
+	data, err := json.Marshal(idempotencyRecord{
+		State:       idempotencyDone,
+		Fingerprint: fingerprint,
+		Status:      capture.status,
+		ContentType: w.Header().Get("Content-Type"),
+		Body:        capture.body.Bytes(),
+	})
+	if err == nil {
+		err = i.client.Set(ctx, redisKey, data, i.ttl).Err()
+	}
+	if err != nil {
+		i.logger.Warn("failed to store idempotent response", zap.Error(err))
+	}
+}
+
+// finalStatus reports whether a response is the request's outcome rather
+// than a failure to reach one, which a retry should get another go at
+func finalStatus(status int) bool {
+	switch status {
+	case statusClientClosedRequest, http.StatusTooManyRequests, http.StatusRequestTimeout:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+func replayIdempotent(w http.ResponseWriter, record *idempotencyRecord) {
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// requestFingerprint ties a key to the request it was first sent with. The
+// query is part of it, so a dry_run preview is never replayed to the real
+// request that follows it.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeIdempotencyError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeError(w, r, status, dto.ErrorResponse{Error: message, Code: code})
+}
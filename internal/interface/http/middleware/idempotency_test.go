@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestIdempotency(t *testing.T, mr *miniredis.Miniredis, wait time.Duration) *Idempotency {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewIdempotency(client, "test", time.Hour, wait, 0, zap.NewNop())
+}
+
+// countingPayment answers 201 with a body numbered by how many times it ran
+func countingPayment(calls *atomic.Int32, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if release != nil {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int32{"call": n})
+	})
+}
+
+func postPayment(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(countingPayment(&calls, nil))
+
+	first := postPayment(h, "key-1", `{"amount":1}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	second := postPayment(h, "key-1", `{"amount":1}`)
+	require.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, int32(1), calls.Load())
+
+	postPayment(h, "", `{"amount":1}`)
+	postPayment(h, "", `{"amount":1}`)
+	assert.Equal(t, int32(3), calls.Load(), "requests without a key are always processed")
+}
+
+func TestIdempotency_ExpiredKeyIsProcessedAgain(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(countingPayment(&calls, nil))
+
+	postPayment(h, "key-1", `{"amount":1}`)
+	mr.FastForward(59 * time.Minute)
+	assert.Equal(t, "true", postPayment(h, "key-1", `{"amount":1}`).Header().Get("Idempotent-Replayed"))
+
+	mr.FastForward(2 * time.Minute)
+	rec := postPayment(h, "key-1", `{"amount":1}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, `{"call":2}`, rec.Body.String())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIdempotency_ConcurrentDuplicatesWaitForResult(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := newTestIdempotency(t, mr, 5*time.Second).Middleware(countingPayment(&calls, release))
+
+	const requests = 10
+	results := make([]*httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for n := range results {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			results[n] = postPayment(h, "key-1", `{"amount":1}`)
+		}(n)
+	}
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load(), "only the request that won the key is processed")
+	replayed := 0
+	for _, rec := range results {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"call":1}`, rec.Body.String())
+		if rec.Header().Get("Idempotent-Replayed") == "true" {
+			replayed++
+		}
+	}
+	assert.Equal(t, requests-1, replayed)
+}
+
+func TestIdempotency_ConcurrentDuplicateGivesUpWith409(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := newTestIdempotency(t, mr, 20*time.Millisecond).Middleware(countingPayment(&calls, release))
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- postPayment(h, "key-1", `{"amount":1}`) }()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	rec := postPayment(h, "key-1", `{"amount":1}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, dto.ErrorCodeIdempotencyInProgress, body.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-first).Code)
+	assert.Equal(t, "true", postPayment(h, "key-1", `{"amount":1}`).Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_KeyReusedForDifferentRequest(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(countingPayment(&calls, nil))
+
+	postPayment(h, "key-1", `{"amount":1}`)
+	rec := postPayment(h, "key-1", `{"amount":2}`)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, dto.ErrorCodeIdempotencyKeyReused, body.Code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_KeyReusedWithDifferentQuery(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(countingPayment(&calls, nil))
+	post := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"amount":1}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusCreated, post("/payments?dry_run=true").Code)
+	rec := post("/payments")
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the preview must not be replayed as the real payment")
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, dto.ErrorCodeIdempotencyKeyReused, body.Code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_ServerErrorReleasesKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	assert.Equal(t, http.StatusInternalServerError, postPayment(h, "key-1", `{}`).Code)
+	assert.False(t, mr.Exists("test:idempotency:key-1"))
+	assert.Equal(t, http.StatusCreated, postPayment(h, "key-1", `{}`).Code)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIdempotency_UnfinishedOutcomesReleaseKey(t *testing.T) {
+	for _, status := range []int{statusClientClosedRequest, http.StatusGatewayTimeout, http.StatusTooManyRequests, http.StatusRequestTimeout} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			var calls atomic.Int32
+			h := newTestIdempotency(t, mr, time.Second).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					w.WriteHeader(status)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			assert.Equal(t, status, postPayment(h, "key-1", `{}`).Code)
+			assert.False(t, mr.Exists("test:idempotency:key-1"))
+			retry := postPayment(h, "key-1", `{}`)
+			assert.Equal(t, http.StatusCreated, retry.Code)
+			assert.Empty(t, retry.Header().Get(idempotencyReplayedHeader))
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	}
+}
+
+func TestIdempotency_RejectsOversizedBody(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(countingPayment(&calls, nil))
+
+	rec := postPayment(h, "key-1", strings.Repeat("x", maxIdempotentBodyBytes+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Zero(t, calls.Load())
+	assert.False(t, mr.Exists("test:idempotency:key-1"))
+}
+
+func TestIdempotency_ClaimOutlivesMaxRequestTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := NewIdempotency(client, "test", time.Hour, time.Second, 2*time.Minute, zap.NewNop()).
+		Middleware(countingPayment(&calls, release))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		postPayment(h, "key-1", `{"amount":1}`)
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	assert.Equal(t, 2*time.Minute+idempotencyLockMargin, mr.TTL("test:idempotency:key-1"))
+	close(release)
+	<-done
+	assert.Equal(t, time.Hour, mr.TTL("test:idempotency:key-1"))
+}
+
+func TestIdempotency_RejectsOverlongKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	h := newTestIdempotency(t, mr, time.Second).Middleware(countingPayment(&calls, nil))
+
+	rec := postPayment(h, strings.Repeat("k", 256), `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Zero(t, calls.Load())
+}
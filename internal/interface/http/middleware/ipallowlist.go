@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"go.uber.org/zap"
+)
+
+// IPAllowList rejects requests whose client IP falls outside a fixed set of
+// CIDRs. An empty list allows everything, which is what local dev wants.
+//
+// The client IP is the connection's peer. Forwarding headers are only
+// believed when the peer is a trusted proxy, since anyone else can send
+// them with an allowed address.
+type IPAllowList struct {
+	prefixes []netip.Prefix
+	trusted  []netip.Prefix
+	logger   *zap.Logger
+}
+
+// NewIPAllowList parses cidrs and trustedProxies up front so a bad entry
+// fails at startup. Bare addresses are accepted as single-host ranges.
+func NewIPAllowList(cidrs, trustedProxies []string, logger *zap.Logger) (*IPAllowList, error) {
+	prefixes, err := parsePrefixes(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow-list entry %w", err)
+	}
+	trusted, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy %w", err)
+	}
+	return &IPAllowList{prefixes: prefixes, trusted: trusted, logger: logger}, nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, raw := range cidrs {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		if !strings.Contains(raw, "/") {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", raw, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", raw, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allows reports whether ip is permitted
+func (a *IPAllowList) Allows(ip netip.Addr) bool {
+	if a == nil || len(a.prefixes) == 0 {
+		return true
+	}
+	return containsAddr(a.prefixes, ip)
+}
+
+// Middleware enforces the allow-list against the peer KeepPeerAddr saw, or
+// r.RemoteAddr when it didn't run
+func (a *IPAllowList) Middleware(next http.Handler) http.Handler {
+	if a == nil || len(a.prefixes) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, ok := a.clientIP(r)
+		if !ok || !a.Allows(ip) {
+			a.logger.Warn("request rejected by IP allow-list",
+				zap.String("peer_addr", peerAddr(r)),
+				zap.String("client_ip", ip.String()),
+				zap.String("path", r.URL.Path),
+			)
+			writeError(w, r, http.StatusForbidden, dto.ErrorResponse{Error: "source IP not allowed"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP is the peer, or when the peer is a trusted proxy, the nearest
+// address in X-Forwarded-For that isn't one, falling back to X-Real-IP
+func (a *IPAllowList) clientIP(r *http.Request) (netip.Addr, bool) {
+	peer, ok := parseHostAddr(peerAddr(r))
+	if !ok || !containsAddr(a.trusted, peer) {
+		return peer, ok
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHostAddr(strings.TrimSpace(hops[i]))
+		if !ok {
+			break
+		}
+		if !containsAddr(a.trusted, hop) {
+			return hop, true
+		}
+	}
+	if realIP, ok := parseHostAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ok {
+		return realIP, true
+	}
+	return peer, true
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHostAddr parses an address with or without a port
+func parseHostAddr(host string) (netip.Addr, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+type peerAddrKey struct{}
+
+// KeepPeerAddr remembers the connection's address before chi's RealIP
+// replaces r.RemoteAddr with whatever the forwarding headers claim
+func KeepPeerAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// peerAddr is the address KeepPeerAddr saw, or r.RemoteAddr without it
+func peerAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(peerAddrKey{}).(string); ok {
+		return addr
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func allowListStatus(t *testing.T, allow *IPAllowList, remoteAddr string) int {
+	t.Helper()
+	h := allow.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestIPAllowList(t *testing.T) {
+	allow, err := NewIPAllowList([]string{"196.46.20.0/24", " 41.58.1.7 ", "2001:db8:abcd::/48"}, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       int
+	}{
+		{"ipv4 in range with port", "196.46.20.15:51234", http.StatusOK},
+		{"ipv4 in range bare (RealIP)", "196.46.20.200", http.StatusOK},
+		{"single host entry", "41.58.1.7", http.StatusOK},
+		{"ipv4 out of range", "196.46.21.1:443", http.StatusForbidden},
+		{"ipv4-mapped ipv6 in range", "[::ffff:196.46.20.9]:8080", http.StatusOK},
+		{"ipv6 in range", "[2001:db8:abcd:12::1]:443", http.StatusOK},
+		{"ipv6 bare in range", "2001:db8:abcd::5", http.StatusOK},
+		{"ipv6 out of range", "[2001:db8:abce::1]:443", http.StatusForbidden},
+		{"unparseable address", "not-an-ip", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, allowListStatus(t, allow, tt.remoteAddr))
+		})
+	}
+}
+
+func TestIPAllowList_EmptyAllowsAll(t *testing.T) {
+	allow, err := NewIPAllowList(nil, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, allowListStatus(t, allow, "8.8.8.8:53"))
+	assert.Equal(t, http.StatusOK, allowListStatus(t, allow, "[2606:4700::1111]:443"))
+	assert.Equal(t, http.StatusOK, allowListStatus(t, nil, "8.8.8.8:53"))
+}
+
+func TestNewIPAllowList_RejectsInvalidEntries(t *testing.T) {
+	for _, bad := range []string{"10.0.0.0/33", "300.1.1.1", "2001:db8::/129", "example.com"} {
+		_, err := NewIPAllowList([]string{bad}, nil, zap.NewNop())
+		assert.Error(t, err, bad)
+		_, err = NewIPAllowList(nil, []string{bad}, zap.NewNop())
+		assert.Error(t, err, bad)
+	}
+}
+
+// forwardedStatus sends a request from peer with headers through
+// KeepPeerAddr and chi's RealIP, as the router does
+func forwardedStatus(t *testing.T, allow *IPAllowList, peer string, headers map[string]string) int {
+	t.Helper()
+	h := KeepPeerAddr(chimiddleware.RealIP(allow.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments", nil)
+	req.RemoteAddr = peer
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestIPAllowList_IgnoresForwardedHeadersFromUntrustedPeers(t *testing.T) {
+	allow, err := NewIPAllowList([]string{"196.46.20.0/24"}, []string{"10.0.0.0/8"}, zap.NewNop())
+	require.NoError(t, err)
+
+	for _, header := range []string{"X-Forwarded-For", "X-Real-IP", "True-Client-IP"} {
+		status := forwardedStatus(t, allow, "203.0.113.9:4000", map[string]string{header: "196.46.20.15"})
+		assert.Equal(t, http.StatusForbidden, status, header)
+	}
+}
+
+func TestIPAllowList_BelievesTrustedProxies(t *testing.T) {
+	allow, err := NewIPAllowList([]string{"196.46.20.0/24"}, []string{"10.0.0.0/8"}, zap.NewNop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		forwardedFor string
+		want         int
+	}{
+		{"allowed client behind proxy", "196.46.20.15", http.StatusOK},
+		{"allowed client behind two proxies", "196.46.20.15, 10.9.9.9", http.StatusOK},
+		{"spoofed hop before the real client", "196.46.20.15, 203.0.113.9", http.StatusForbidden},
+		{"disallowed client behind proxy", "203.0.113.9", http.StatusForbidden},
+		{"proxy without a forwarded client", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := forwardedStatus(t, allow, "10.1.2.3:4000", map[string]string{"X-Forwarded-For": tt.forwardedFor})
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// maintenanceKey holds the switch while it is on, so every API replica
+// sees the same state
+const maintenanceKey = "maintenance"
+
+// maintenanceFlag is what is stored under maintenanceKey
+type maintenanceFlag struct {
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+}
+
+// MaintenanceMode pauses writes while reads keep working. The flag lives in
+// Redis; each replica re-reads it at most once per refresh interval, so a
+// toggle takes up to that long to reach every replica.
+type MaintenanceMode struct {
+	client     redis.UniversalClient
+	key        string
+	refresh    time.Duration
+	retryAfter time.Duration
+	logger     *zap.Logger
+	now        func() time.Time
+
+	mu         sync.Mutex
+	flag       *maintenanceFlag
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+func NewMaintenanceMode(client redis.UniversalClient, keys keyspace.Prefix, refresh, retryAfter time.Duration, logger *zap.Logger) *MaintenanceMode {
+	return &MaintenanceMode{
+		client:     client,
+		key:        keys.Key(maintenanceKey),
+		refresh:    refresh,
+		retryAfter: retryAfter,
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+// Enable turns maintenance on for every replica
+func (m *MaintenanceMode) Enable(ctx context.Context, reason string) error {
+	flag := &maintenanceFlag{Reason: reason, Since: m.now().UTC()}
+	data, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	if err := m.client.Set(ctx, m.key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to enable maintenance mode: %w", err)
+	}
+	m.store(flag)
+	return nil
+}
+
+// Disable turns maintenance off for every replica
+func (m *MaintenanceMode) Disable(ctx context.Context) error {
+	if err := m.client.Del(ctx, m.key).Err(); err != nil {
+		return fmt.Errorf("failed to disable maintenance mode: %w", err)
+	}
+	m.store(nil)
+	return nil
+}
+
+// Status reads the flag from Redis, bypassing the local copy
+func (m *MaintenanceMode) Status(ctx context.Context) (bool, string, time.Time, error) {
+	flag, err := m.fetch(ctx)
+	if err != nil {
+		return false, "", time.Time{}, err
+	}
+	m.store(flag)
+	if flag == nil {
+		return false, "", time.Time{}, nil
+	}
+	return true, flag.Reason, flag.Since, nil
+}
+
+// Enabled answers from the local copy, refreshing it from Redis once it is
+// older than the refresh interval. Only one request refreshes at a time;
+// the rest use the copy they have. If Redis can't be read the last known
+// state stands.
+func (m *MaintenanceMode) Enabled(ctx context.Context) bool {
+	m.mu.Lock()
+	stale := !m.refreshing && m.now().Sub(m.fetchedAt) >= m.refresh
+	if stale {
+		m.refreshing = true
+	}
+	enabled := m.flag != nil
+	m.mu.Unlock()
+
+	if !stale {
+		return enabled
+	}
+
+	flag, err := m.fetch(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshing = false
+	// Failures wait out the interval too, so a Redis outage costs one
+	// lookup per interval rather than one per request
+	m.fetchedAt = m.now()
+	if err != nil {
+		m.logger.Warn("failed to read maintenance flag, keeping last known state",
+			zap.Error(err),
+			zap.Bool("enabled", enabled),
+		)
+		return enabled
+	}
+	m.flag = flag
+	return flag != nil
+}
+
+func (m *MaintenanceMode) fetch(ctx context.Context) (*maintenanceFlag, error) {
+	data, err := m.client.Get(ctx, m.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var flag maintenanceFlag
+	if err := json.Unmarshal(data, &flag); err != nil {
+		// Someone set the key by hand; it still means "on"
+		flag = maintenanceFlag{Reason: string(data)}
+	}
+	return &flag, nil
+}
+
+func (m *MaintenanceMode) store(flag *maintenanceFlag) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flag = flag
+	m.fetchedAt = m.now()
+}
+
+// Middleware rejects requests with 503 and Retry-After while maintenance is
+// on. The payment provider retries on 503, so no payment is lost. A nil
+// MaintenanceMode lets everything through.
+func (m *MaintenanceMode) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		m.logger.Info("request rejected during maintenance",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		)
+		w.Header().Set("Retry-After", strconv.Itoa(int(m.retryAfter.Seconds())))
+		writeError(w, r, http.StatusServiceUnavailable, dto.ErrorResponse{
+			Error: "service is in maintenance mode, retry later",
+			Code:  dto.ErrorCodeMaintenance,
+		})
+	})
+}
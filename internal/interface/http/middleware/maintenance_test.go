@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestMaintenance(t *testing.T, mr *miniredis.Miniredis, refresh time.Duration) *MaintenanceMode {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewMaintenanceMode(client, "test", refresh, 30*time.Second, zap.NewNop())
+}
+
+// maintenanceRouter pauses POST /payments but not GET /payments
+func maintenanceRouter(m *MaintenanceMode) http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	r := chi.NewRouter()
+	r.With(m.Middleware).Post("/payments", ok)
+	r.Get("/payments", ok)
+	return r
+}
+
+func serve(h http.Handler, method string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/payments", nil))
+	return rec
+}
+
+func TestMaintenanceMode_RejectsWritesWhileReadsSucceed(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	m := newTestMaintenance(t, mr, time.Second)
+	r := maintenanceRouter(m)
+
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodPost).Code)
+
+	require.NoError(t, m.Enable(ctx, "schema migration"))
+	assert.True(t, mr.Exists("test:maintenance"))
+
+	rec := serve(r, http.MethodPost)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, dto.ErrorCodeMaintenance, body.Code)
+
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet).Code, "reads keep working")
+
+	enabled, reason, since, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, "schema migration", reason)
+	assert.False(t, since.IsZero())
+
+	require.NoError(t, m.Disable(ctx))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodPost).Code)
+}
+
+func TestMaintenanceMode_OtherReplicasCatchUpAfterRefresh(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	admin := newTestMaintenance(t, mr, time.Second)
+	replica := newTestMaintenance(t, mr, time.Second)
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	replica.now = func() time.Time { return now }
+
+	assert.False(t, replica.Enabled(ctx))
+	require.NoError(t, admin.Enable(ctx, "incident"))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.False(t, replica.Enabled(ctx), "the cached copy is used within the refresh interval")
+
+	now = now.Add(time.Second)
+	assert.True(t, replica.Enabled(ctx))
+
+	require.NoError(t, admin.Disable(ctx))
+	now = now.Add(time.Second)
+	assert.False(t, replica.Enabled(ctx))
+}
+
+func TestMaintenanceMode_HandSetKeyCountsAsOn(t *testing.T) {
+	mr := miniredis.RunT(t)
+	m := newTestMaintenance(t, mr, 0)
+	require.NoError(t, mr.Set("test:maintenance", "1"))
+
+	assert.True(t, m.Enabled(context.Background()))
+}
+
+func TestMaintenanceMode_KeepsLastStateWhenRedisDown(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	m := newTestMaintenance(t, mr, 0)
+
+	require.NoError(t, m.Enable(ctx, "incident"))
+	mr.Close()
+
+	assert.True(t, m.Enabled(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(maintenanceRouter(m), http.MethodPost).Code)
+}
+
+func TestMaintenanceMode_NilPassesThrough(t *testing.T) {
+	var m *MaintenanceMode
+	assert.Equal(t, http.StatusOK, serve(maintenanceRouter(m), http.MethodPost).Code)
+}
@@ -1,12 +1,65 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"runtime/debug"
+	"strings"
 	"time"
 
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// CorrelationIDHeader lets callers supply their own trace ID
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength bounds a caller's X-Correlation-ID, which is
+// echoed into response headers, logs and events
+const maxCorrelationIDLength = 128
+
+// CorrelationID puts a correlation ID on the request context so it can be
+// carried into published events. It prefers the caller's X-Correlation-ID
+// and falls back to the request ID, or a new UUID when neither is a valid
+// ID. Must run after chi's RequestID.
+func CorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationID := r.Header.Get(CorrelationIDHeader)
+		if !validCorrelationID(correlationID) {
+			correlationID = chimiddleware.GetReqID(r.Context())
+		}
+		if !validCorrelationID(correlationID) {
+			correlationID = uuid.NewString()
+		}
+
+		w.Header().Set(CorrelationIDHeader, correlationID)
+		ctx := domain.ContextWithCorrelationID(r.Context(), correlationID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validCorrelationID accepts up to maxCorrelationIDLength letters, digits
+// and . _ : / + = - characters, which covers UUIDs, trace IDs and chi's
+// request IDs
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("._:/+=-", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Logger middleware logs HTTP requests
 func Logger(logger *zap.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -24,6 +77,7 @@ func Logger(logger *zap.Logger) func(next http.Handler) http.Handler {
 				zap.Int("status", wrapped.statusCode),
 				zap.Duration("duration", time.Since(start)),
 				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("correlation_id", domain.CorrelationIDFromContext(r.Context())),
 			)
 		})
 	}
@@ -39,17 +93,49 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// Recovery middleware recovers from panics
-func Recovery(logger *zap.Logger) func(next http.Handler) http.Handler {
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can still flush through the logger
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+var panicsRecovered = metrics.NewCounter("panics_recovered_total", "Panics recovered by the HTTP recovery middleware.")
+
+// Recovery middleware recovers from panics, logs the stack trace and answers
+// with a JSON 500 carrying the request ID. The panic value is only echoed to
+// the client when exposeDetails is set, which should stay off in production.
+func Recovery(logger *zap.Logger, exposeDetails bool) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
-				if err := recover(); err != nil {
+				if rec := recover(); rec != nil {
+					if rec == http.ErrAbortHandler {
+						// Deliberate abort; let net/http handle it
+						panic(rec)
+					}
+
+					requestID := chimiddleware.GetReqID(r.Context())
+					panicsRecovered.Inc()
+
 					logger.Error("panic recovered",
-						zap.Any("error", err),
+						zap.Any("error", rec),
+						zap.String("method", r.Method),
 						zap.String("path", r.URL.Path),
+						zap.String("request_id", requestID),
+						zap.String("correlation_id", domain.CorrelationIDFromContext(r.Context())),
+						zap.String("stack", string(debug.Stack())),
 					)
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+					response := dto.ErrorResponse{
+						Error:     "internal server error",
+						Code:      dto.ErrorCodeInternal,
+						RequestID: requestID,
+					}
+					if exposeDetails {
+						response.Message = fmt.Sprint(rec)
+					}
+
+					writeError(w, r, http.StatusInternalServerError, response)
 				}
 			}()
 
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newPanickingServer(t *testing.T, exposeDetails bool) *httptest.Server {
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(Recovery(zap.NewNop(), exposeDetails))
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("deliberate failure: secret internals")
+	})
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getErrorResponse(t *testing.T, url string) (*http.Response, dto.ErrorResponse) {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp, body
+}
+
+func TestRecovery_RespondsWithJSONAndStaysUp(t *testing.T) {
+	srv := newPanickingServer(t, false)
+	before := panicsRecovered.Value()
+
+	resp, body := getErrorResponse(t, srv.URL+"/panic")
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, dto.ErrorCodeInternal, body.Code)
+	assert.NotEmpty(t, body.RequestID)
+	assert.Empty(t, body.Message, "panic message must not leak by default")
+	assert.Equal(t, before+1, panicsRecovered.Value())
+
+	ok, err := http.Get(srv.URL + "/ok")
+	require.NoError(t, err)
+	ok.Body.Close()
+	assert.Equal(t, http.StatusOK, ok.StatusCode)
+}
+
+func TestRecovery_ExposesDetailsWhenEnabled(t *testing.T) {
+	srv := newPanickingServer(t, true)
+
+	resp, body := getErrorResponse(t, srv.URL+"/panic")
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "deliberate failure: secret internals", body.Message)
+}
+
+func TestCorrelationID_ReplacesInvalidInboundIDs(t *testing.T) {
+	var seen string
+	handler := chimiddleware.RequestID(CorrelationID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = domain.CorrelationIDFromContext(r.Context())
+	})))
+	serve := func(inbound, requestID string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if inbound != "" {
+			req.Header.Set(CorrelationIDHeader, inbound)
+		}
+		if requestID != "" {
+			req.Header.Set(chimiddleware.RequestIDHeader, requestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, seen, rec.Header().Get(CorrelationIDHeader))
+		return seen
+	}
+
+	assert.Equal(t, "trace-01HZ.abc:42", serve("trace-01HZ.abc:42", ""))
+	assert.Equal(t, strings.Repeat("a", 128), serve(strings.Repeat("a", 128), ""))
+
+	for _, inbound := range []string{strings.Repeat("a", 129), "two words", "line\tbreak", `"quoted"`, "naïve"} {
+		assert.Equal(t, "req-7", serve(inbound, "req-7"), "%q falls back to the request ID", inbound)
+		id := serve(inbound, "bad request id")
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err, "%q with no usable request ID gets a new UUID", inbound)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+// pageSizeParams are the query parameters that size a page of results
+var pageSizeParams = []string{"page_size", "limit"}
+
+// QueryLimits bounds how much a list endpoint may be asked for. The zero
+// value checks nothing.
+type QueryLimits struct {
+	// MaxPageSize bounds page_size and limit; zero leaves them unchecked
+	MaxPageSize int
+	// MaxDateRange bounds to - from when both are given; zero leaves the
+	// range unchecked
+	MaxDateRange time.Duration
+	// ScopeParam names the parameter that narrows a query to one customer.
+	// A query without it spans every customer and must carry all of
+	// RequiredFilters.
+	ScopeParam      string
+	RequiredFilters []string
+}
+
+// Middleware answers 400 for queries outside the limits, before the handler
+// or anything cached behind it runs. Values the limits don't cover, such as
+// a from that isn't a date, are left for the handler to reject.
+func (l QueryLimits) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.check(r); err != nil {
+			writeError(w, r, http.StatusBadRequest, dto.ErrorResponse{Error: err.Error(), Code: dto.ErrorCodeBadRequest})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l QueryLimits) check(r *http.Request) error {
+	query := r.URL.Query()
+
+	if l.MaxPageSize > 0 {
+		for _, name := range pageSizeParams {
+			if !query.Has(name) {
+				continue
+			}
+			size, err := strconv.Atoi(query.Get(name))
+			if err != nil || size < 1 || size > l.MaxPageSize {
+				return fmt.Errorf("%s must be between 1 and %d", name, l.MaxPageSize)
+			}
+		}
+	}
+
+	if l.ScopeParam != "" && query.Get(l.ScopeParam) == "" {
+		for _, name := range l.RequiredFilters {
+			if query.Get(name) == "" {
+				return fmt.Errorf("queries without %s must set %s", l.ScopeParam, strings.Join(l.RequiredFilters, " and "))
+			}
+		}
+	}
+
+	if l.MaxDateRange > 0 && query.Get("from") != "" && query.Get("to") != "" {
+		// The width is the same in any zone, so UTC will do
+		from, fromErr := dto.ParseDateRangeBound(query.Get("from"), false, time.UTC)
+		to, toErr := dto.ParseDateRangeBound(query.Get("to"), true, time.UTC)
+		if fromErr == nil && toErr == nil && to.Sub(from) > l.MaxDateRange {
+			return fmt.Errorf("date range from %s to %s is wider than the maximum of %s",
+				query.Get("from"), query.Get("to"), l.MaxDateRange)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLimits(t *testing.T) {
+	limits := QueryLimits{
+		MaxPageSize:     100,
+		MaxDateRange:    31 * 24 * time.Hour,
+		ScopeParam:      "customer_id",
+		RequiredFilters: []string{"from", "to"},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		message string
+	}{
+		{"page size at maximum", "customer_id=GIG00001&page_size=100", http.StatusOK, ""},
+		{"page size above maximum", "customer_id=GIG00001&page_size=101", http.StatusBadRequest, "page_size must be between 1 and 100"},
+		{"page size zero", "customer_id=GIG00001&page_size=0", http.StatusBadRequest, "page_size must be between 1 and 100"},
+		{"page size not a number", "customer_id=GIG00001&page_size=lots", http.StatusBadRequest, "page_size must be between 1 and 100"},
+		{"cursor limit at maximum", "customer_id=GIG00001&cursor=abc&limit=100", http.StatusOK, ""},
+		{"cursor limit above maximum", "customer_id=GIG00001&cursor=abc&limit=100000", http.StatusBadRequest, "limit must be between 1 and 100"},
+		{"range at maximum", "from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z", http.StatusOK, ""},
+		{"range just over maximum", "from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:01Z", http.StatusBadRequest,
+			"date range from 2025-01-01T00:00:00Z to 2025-02-01T00:00:01Z is wider than the maximum of 744h0m0s"},
+		{"bare dates cover the whole last day", "from=2025-01-01&to=2025-01-31", http.StatusOK, ""},
+		{"bare dates one day too wide", "from=2025-01-01&to=2025-02-01", http.StatusBadRequest,
+			"date range from 2025-01-01 to 2025-02-01 is wider than the maximum of 744h0m0s"},
+		{"unparseable date left to the handler", "from=yesterday&to=2025-02-01", http.StatusOK, ""},
+		{"all customers without filters", "", http.StatusBadRequest, "queries without customer_id must set from and to"},
+		{"all customers with half a range", "from=2025-01-01", http.StatusBadRequest, "queries without customer_id must set from and to"},
+		{"one customer needs no range", "customer_id=GIG00001", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := limits.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/payments?"+tt.query, nil))
+
+			require.Equal(t, tt.want, rec.Code)
+			if tt.message != "" {
+				var body dto.ErrorResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tt.message, body.Error)
+				assert.Equal(t, dto.ErrorCodeBadRequest, body.Code)
+			}
+		})
+	}
+}
+
+func TestQueryLimits_ZeroValueChecksNothing(t *testing.T) {
+	h := QueryLimits{}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/payments?page_size=100000&from=2000-01-01&to=2030-01-01", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+)
+
+// RequestTimeoutHeader lets a caller bound how long its request may take,
+// in milliseconds
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// DefaultRequestTimeout applies when neither the caller nor the config
+// sets one
+const DefaultRequestTimeout = 30 * time.Second
+
+// RequestTimeout puts a deadline on every request's context. Callers may
+// pick their own with RequestTimeoutHeader, up to Max.
+type RequestTimeout struct {
+	// Default applies to requests without the header; zero uses
+	// DefaultRequestTimeout
+	Default time.Duration
+	// Max is the longest timeout a caller may ask for; zero caps it at
+	// Default, so callers can only shorten it
+	Max time.Duration
+}
+
+// Middleware answers 400 for a header that isn't a whole number of
+// milliseconds between 1 and Max. Like chi's Timeout, it answers 504 if
+// the deadline passes before the handler writes anything else.
+func (t RequestTimeout) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, err := t.timeout(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, dto.ErrorResponse{Error: err.Error(), Code: dto.ErrorCodeBadRequest})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer func() {
+			cancel()
+			if ctx.Err() == context.DeadlineExceeded {
+				w.WriteHeader(http.StatusGatewayTimeout)
+			}
+		}()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (t RequestTimeout) timeout(r *http.Request) (time.Duration, error) {
+	def := t.Default
+	if def <= 0 {
+		def = DefaultRequestTimeout
+	}
+	max := t.Max
+	if max <= 0 {
+		max = def
+	}
+
+	value := r.Header.Get(RequestTimeoutHeader)
+	if value == "" {
+		return def, nil
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 1 || ms > max.Milliseconds() {
+		return 0, fmt.Errorf("%s must be between 1 and %d milliseconds", RequestTimeoutHeader, max.Milliseconds())
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
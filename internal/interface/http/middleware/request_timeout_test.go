@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeout_SetsContextDeadline(t *testing.T) {
+	timeout := RequestTimeout{Default: 10 * time.Second, Max: 20 * time.Second}
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"default without header", "", 10 * time.Second},
+		{"shorter than default", "250", 250 * time.Millisecond},
+		{"longer than default", "15000", 15 * time.Second},
+		{"at maximum", "20000", 20 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			h := timeout.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok := r.Context().Deadline()
+				require.True(t, ok)
+				remaining = time.Until(deadline)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/customers/GIG00001", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestTimeoutHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.InDelta(t, tt.want, remaining, float64(time.Second))
+		})
+	}
+}
+
+func TestRequestTimeout_RejectsAbsurdValues(t *testing.T) {
+	timeout := RequestTimeout{Default: 10 * time.Second, Max: 20 * time.Second}
+
+	for _, header := range []string{"20001", "0", "-5", "1.5", "soon", "99999999999999999999"} {
+		t.Run(header, func(t *testing.T) {
+			called := false
+			h := timeout.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/customers/GIG00001", nil)
+			req.Header.Set(RequestTimeoutHeader, header)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.False(t, called)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			var body dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "X-Request-Timeout must be between 1 and 20000 milliseconds", body.Error)
+		})
+	}
+}
+
+func TestRequestTimeout_ZeroValueUsesDefault(t *testing.T) {
+	var remaining time.Duration
+	h := RequestTimeout{}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ := r.Context().Deadline()
+		remaining = time.Until(deadline)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set(RequestTimeoutHeader, "31000")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "callers may only shorten the default")
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.InDelta(t, DefaultRequestTimeout, remaining, float64(time.Second))
+}
+
+func TestRequestTimeout_AnswersGatewayTimeoutWhenDeadlinePasses(t *testing.T) {
+	h := RequestTimeout{Default: time.Second}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		assert.ErrorIs(t, r.Context().Err(), context.DeadlineExceeded)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/customers/GIG00001", nil)
+	req.Header.Set(RequestTimeoutHeader, "20")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+}
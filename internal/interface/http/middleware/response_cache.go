@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/infrastructure/keyspace"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// Routes that can opt into response caching
+const (
+	CacheRouteCustomer = "customer"
+	CacheRoutePayments = "payments"
+)
+
+// ResponseCache keeps short-lived copies of successful JSON GET responses in
+// Redis, scoped to the customer they describe. Each customer has a
+// generation counter that is part of every cache key; InvalidateCustomer
+// moves the counter on, so everything cached for them before a write is
+// never served again and simply expires. The counter never expires or goes
+// back, so an old generation can't come round again.
+type ResponseCache struct {
+	client redis.UniversalClient
+	keys   keyspace.Prefix
+	ttl    time.Duration
+	routes map[string]bool
+	logger *zap.Logger
+}
+
+// NewResponseCache enables caching for the named routes. Unknown route
+// names fail here so a typo in config is caught at startup.
+func NewResponseCache(client redis.UniversalClient, keys keyspace.Prefix, ttl time.Duration, routes []string, logger *zap.Logger) (*ResponseCache, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("response cache TTL must be positive, got %s", ttl)
+	}
+
+	enabled := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		route = strings.TrimSpace(route)
+		switch route {
+		case "":
+			continue
+		case CacheRouteCustomer, CacheRoutePayments:
+			enabled[route] = true
+		default:
+			return nil, fmt.Errorf("unknown response cache route %q", route)
+		}
+	}
+
+	return &ResponseCache{
+		client: client,
+		keys:   keys,
+		ttl:    ttl,
+		routes: enabled,
+		logger: logger,
+	}, nil
+}
+
+// cachedHeaders are the handler-set headers replayed on a hit
+var cachedHeaders = []string{"Link", "X-Total-Count"}
+
+// cachedResponse is what is stored per request
+type cachedResponse struct {
+	ContentType string            `json:"content_type"`
+	ETag        string            `json:"etag"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body"`
+}
+
+// Middleware caches route when it was enabled. customerID picks the
+// customer a request is about; requests it returns "" for are not cached,
+// since no write would ever invalidate them.
+func (c *ResponseCache) Middleware(route string, customerID func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if c == nil || !c.routes[route] {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || !acceptsPlainJSON(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id := customerID(r)
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			generation, err := c.client.Get(ctx, c.generationKey(id)).Result()
+			if err != nil && err != redis.Nil {
+				c.logger.Warn("response cache unavailable, serving uncached", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := c.entryKey(id, generation, r)
+
+			if data, err := c.client.Get(ctx, key).Bytes(); err == nil {
+				var entry cachedResponse
+				if err := json.Unmarshal(data, &entry); err == nil {
+					c.serve(w, r, &entry, "HIT")
+					return
+				}
+			}
+
+			capture := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(capture, r)
+
+			if capture.status != http.StatusOK {
+				w.WriteHeader(capture.status)
+				w.Write(capture.body.Bytes())
+				return
+			}
+
+			entry := &cachedResponse{
+				ContentType: w.Header().Get("Content-Type"),
+				ETag:        etagFor(capture.body.Bytes()),
+				Body:        capture.body.Bytes(),
+			}
+			for _, name := range cachedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					if entry.Headers == nil {
+						entry.Headers = make(map[string]string)
+					}
+					entry.Headers[name] = value
+				}
+			}
+			c.store(ctx, id, generation, key, entry)
+			c.serve(w, r, entry, "MISS")
+		})
+	}
+}
+
+// store caches entry unless the customer was invalidated while it was
+// being computed, in which case it may already be stale
+func (c *ResponseCache) store(ctx context.Context, customerID, generation, key string, entry *cachedResponse) {
+	current, err := c.client.Get(ctx, c.generationKey(customerID)).Result()
+	if err != nil && err != redis.Nil {
+		c.logger.Warn("failed to store cached response", zap.Error(err))
+		return
+	}
+	if current != generation {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = c.client.Set(ctx, key, data, c.ttl).Err()
+	}
+	if err != nil {
+		c.logger.Warn("failed to store cached response", zap.Error(err))
+	}
+}
+
+// InvalidateCustomer stops every response cached for the customer from
+// being served again
+func (c *ResponseCache) InvalidateCustomer(ctx context.Context, customerID string) error {
+	key := c.generationKey(customerID)
+	// PERSIST drops the expiry generation keys were once written with
+	pipe := c.client.TxPipeline()
+	pipe.Incr(ctx, key)
+	pipe.Persist(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to invalidate cached responses: %w", err)
+	}
+	return nil
+}
+
+func (c *ResponseCache) serve(w http.ResponseWriter, r *http.Request, entry *cachedResponse, state string) {
+	header := w.Header()
+	header.Set("ETag", entry.ETag)
+	header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(c.ttl.Seconds())))
+	header.Set("X-Cache", state)
+	for name, value := range entry.Headers {
+		header.Set(name, value)
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), entry.ETag) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", entry.ContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.Body)
+}
+
+func (c *ResponseCache) generationKey(customerID string) string {
+	return c.keys.Key("httpcache:gen:" + customerID)
+}
+
+func (c *ResponseCache) entryKey(customerID, generation string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.URL.RequestURI()))
+	return c.keys.Key("httpcache:" + customerID + ":" + generation + ":" + hex.EncodeToString(sum[:]))
+}
+
+// captureWriter buffers the handler's response so it can be cached before
+// it is sent. Headers go straight to the real writer.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.status = code
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	return cw.body.Write(b)
+}
+
+// acceptsPlainJSON reports whether the client takes the default JSON
+// representation. Anything more specific, such as an NDJSON stream, is
+// passed through uncached.
+func acceptsPlainJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				return false
+			}
+			switch mediaType {
+			case "application/json", "application/*", "*/*":
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func etagFor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements If-None-Match's weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type cachedServer struct {
+	router *chi.Mux
+	cache  *ResponseCache
+	mr     *miniredis.Miniredis
+	calls  atomic.Int64
+	status atomic.Int64
+	// during, if set, runs inside the handler
+	during func()
+}
+
+func newCachedServer(t *testing.T, routes ...string) *cachedServer {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	cache, err := NewResponseCache(client, "test", time.Minute, routes, zap.NewNop())
+	require.NoError(t, err)
+
+	s := &cachedServer{router: chi.NewRouter(), cache: cache, mr: mr}
+	s.status.Store(http.StatusOK)
+	customerID := func(r *http.Request) string { return chi.URLParam(r, "customer_id") }
+
+	s.router.With(cache.Middleware(CacheRouteCustomer, customerID)).
+		Get("/customers/{customer_id}", func(w http.ResponseWriter, r *http.Request) {
+			call := s.calls.Add(1)
+			if s.during != nil {
+				s.during()
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Total-Count", "1")
+			w.WriteHeader(int(s.status.Load()))
+			fmt.Fprintf(w, `{"customer_id":%q,"call":%d}`, chi.URLParam(r, "customer_id"), call)
+		})
+	return s
+}
+
+func (s *cachedServer) get(path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestResponseCache_ServesRepeatReadsFromCache(t *testing.T) {
+	s := newCachedServer(t, CacheRouteCustomer)
+
+	first := s.get("/customers/GIG00001", nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	assert.NotEmpty(t, first.Header().Get("ETag"))
+	assert.Equal(t, "private, max-age=60", first.Header().Get("Cache-Control"))
+
+	second := s.get("/customers/GIG00001", nil)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+	assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, first.Header().Get("ETag"), second.Header().Get("ETag"))
+	assert.Equal(t, "1", second.Header().Get("X-Total-Count"), "pagination headers are replayed")
+
+	other := s.get("/customers/GIG00002", nil)
+	assert.Equal(t, "MISS", other.Header().Get("X-Cache"))
+	assert.Equal(t, int64(2), s.calls.Load())
+}
+
+func TestResponseCache_InvalidateCustomerDropsTheirResponses(t *testing.T) {
+	s := newCachedServer(t, CacheRouteCustomer)
+
+	before := s.get("/customers/GIG00001", nil)
+	s.get("/customers/GIG00002", nil)
+
+	require.NoError(t, s.cache.InvalidateCustomer(context.Background(), "GIG00001"))
+
+	after := s.get("/customers/GIG00001", nil)
+	assert.Equal(t, "MISS", after.Header().Get("X-Cache"))
+	assert.NotEqual(t, before.Body.String(), after.Body.String())
+	assert.NotEqual(t, before.Header().Get("ETag"), after.Header().Get("ETag"))
+
+	untouched := s.get("/customers/GIG00002", nil)
+	assert.Equal(t, "HIT", untouched.Header().Get("X-Cache"), "other customers stay cached")
+	assert.Equal(t, int64(3), s.calls.Load())
+}
+
+func TestResponseCache_InvalidatedWhileComputingIsNotStored(t *testing.T) {
+	s := newCachedServer(t, CacheRouteCustomer)
+	s.during = func() {
+		require.NoError(t, s.cache.InvalidateCustomer(context.Background(), "GIG00001"))
+	}
+
+	first := s.get("/customers/GIG00001", nil)
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	s.during = nil
+
+	second := s.get("/customers/GIG00001", nil)
+	assert.Equal(t, "MISS", second.Header().Get("X-Cache"), "a response computed across a write may be stale")
+	assert.Equal(t, int64(2), s.calls.Load())
+}
+
+func TestResponseCache_GenerationNeverExpires(t *testing.T) {
+	s := newCachedServer(t, CacheRouteCustomer)
+	genKey := "test:httpcache:gen:GIG00001"
+	// A generation left with an expiry by an earlier release
+	s.mr.Set(genKey, "1700000000000000000")
+	s.mr.SetTTL(genKey, time.Minute)
+
+	require.NoError(t, s.cache.InvalidateCustomer(context.Background(), "GIG00001"))
+	require.NoError(t, s.cache.InvalidateCustomer(context.Background(), "GIG00001"))
+	s.mr.FastForward(time.Hour)
+
+	require.True(t, s.mr.Exists(genKey))
+	assert.Zero(t, s.mr.TTL(genKey))
+	gen, err := s.mr.Get(genKey)
+	require.NoError(t, err)
+	assert.Equal(t, "1700000000000000002", gen, "each invalidation moves the generation forward")
+}
+
+func TestResponseCache_IfNoneMatchReturnsNotModified(t *testing.T) {
+	s := newCachedServer(t, CacheRouteCustomer)
+
+	first := s.get("/customers/GIG00001", nil)
+	etag := first.Header().Get("ETag")
+
+	rec := s.get("/customers/GIG00001", http.Header{"If-None-Match": {`"stale", W/` + etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	require.NoError(t, s.cache.InvalidateCustomer(context.Background(), "GIG00001"))
+
+	changed := s.get("/customers/GIG00001", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, changed.Code, "a write changes the representation")
+	assert.NotEmpty(t, changed.Body.String())
+}
+
+func TestResponseCache_PassesThrough(t *testing.T) {
+	t.Run("route not enabled", func(t *testing.T) {
+		s := newCachedServer(t, CacheRoutePayments)
+		s.get("/customers/GIG00001", nil)
+		rec := s.get("/customers/GIG00001", nil)
+		assert.Empty(t, rec.Header().Get("X-Cache"))
+		assert.Equal(t, int64(2), s.calls.Load())
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		s := newCachedServer(t, CacheRouteCustomer)
+		s.status.Store(http.StatusNotFound)
+		assert.Equal(t, http.StatusNotFound, s.get("/customers/GIG00001", nil).Code)
+		s.status.Store(http.StatusOK)
+		assert.Equal(t, "MISS", s.get("/customers/GIG00001", nil).Header().Get("X-Cache"))
+	})
+
+	t.Run("non-JSON accept", func(t *testing.T) {
+		s := newCachedServer(t, CacheRouteCustomer)
+		ndjson := http.Header{"Accept": {"application/x-ndjson"}}
+		s.get("/customers/GIG00001", ndjson)
+		rec := s.get("/customers/GIG00001", ndjson)
+		assert.Empty(t, rec.Header().Get("X-Cache"))
+		assert.Equal(t, int64(2), s.calls.Load())
+	})
+}
+
+func TestNewResponseCache_RejectsUnknownRoute(t *testing.T) {
+	_, err := NewResponseCache(nil, "", time.Minute, []string{"customers"}, zap.NewNop())
+	assert.Error(t, err)
+}
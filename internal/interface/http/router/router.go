@@ -1,8 +1,13 @@
 package router
 
 import (
+	"net/http"
+	"net/http/pprof"
 	"time"
 
+	"github.com/gigmile/payment-service/internal/application/service"
+	"github.com/gigmile/payment-service/internal/infrastructure/metrics"
+	"github.com/gigmile/payment-service/internal/interface/http/dtov2"
 	"github.com/gigmile/payment-service/internal/interface/http/handler"
 	"github.com/gigmile/payment-service/internal/interface/http/middleware"
 	"github.com/go-chi/chi/v5"
@@ -10,23 +15,151 @@ import (
 	"go.uber.org/zap"
 )
 
-func NewRouter(handlers *handler.Handlers, logger *zap.Logger) *chi.Mux {
+// Config holds router-level tunables
+type Config struct {
+	// ExposeErrorDetails echoes panic messages in 500 responses; keep it
+	// off in production
+	ExposeErrorDetails bool
+	// PaymentSourceAllowList restricts POST /payments to the provider's
+	// source IPs; nil allows all
+	PaymentSourceAllowList *middleware.IPAllowList
+	// AdminToken is the bearer token required on /api/v1/admin and /debug routes
+	AdminToken string
+	// DebugEnabled mounts GET /debug/info; DebugPprof adds the pprof
+	// handlers under /debug/pprof. Both are admin-only and off by default.
+	DebugEnabled bool
+	DebugPprof   bool
+	// ResponseCache serves repeat customer and payment reads from Redis;
+	// nil disables it
+	ResponseCache *middleware.ResponseCache
+	// Maintenance answers writes with 503 while it is switched on; nil
+	// never pauses anything
+	Maintenance *middleware.MaintenanceMode
+	// Idempotency replays POST /payments responses by Idempotency-Key; nil
+	// processes every request
+	Idempotency *middleware.Idempotency
+	// MaxPaymentDateRange caps from..to on GET /payments; zero is uncapped
+	MaxPaymentDateRange time.Duration
+	// MaxPageSize refuses list requests for bigger pages with 400; zero
+	// uses defaultMaxPageSize
+	MaxPageSize int
+	// RequestTimeout sets each request's deadline; the zero value gives
+	// every request middleware.DefaultRequestTimeout
+	RequestTimeout middleware.RequestTimeout
+}
+
+// defaultMaxPageSize matches service.DefaultPageSizes. MaxPageSize should
+// match the size the service caps list queries at, so asking for more is
+// refused instead of silently trimmed.
+const defaultMaxPageSize = 100
+
+func NewRouter(handlers *handler.Handlers, cfg Config, logger *zap.Logger) *chi.Mux {
 	r := chi.NewRouter()
 
+	maxListPageSize := cfg.MaxPageSize
+	if maxListPageSize <= 0 {
+		maxListPageSize = defaultMaxPageSize
+	}
+
+	paymentListLimits := middleware.QueryLimits{
+		MaxPageSize:     maxListPageSize,
+		MaxDateRange:    cfg.MaxPaymentDateRange,
+		ScopeParam:      "customer_id",
+		RequiredFilters: []string{"from", "to"},
+	}
+	// The path already scopes these to one customer
+	customerPaymentLimits := middleware.QueryLimits{
+		MaxPageSize:  maxListPageSize,
+		MaxDateRange: cfg.MaxPaymentDateRange,
+	}
+	pageLimits := middleware.QueryLimits{MaxPageSize: maxListPageSize}
+
 	r.Use(chimiddleware.RequestID)
+	r.Use(middleware.CorrelationID)
+	r.Use(middleware.ErrorFormats{"/api/v2": dtov2.ErrorEnvelope}.Middleware)
+	r.Use(middleware.KeepPeerAddr)
 	r.Use(chimiddleware.RealIP)
-	r.Use(middleware.Recovery(logger))
+	r.Use(middleware.Recovery(logger, cfg.ExposeErrorDetails))
 	r.Use(middleware.Logger(logger))
 	r.Use(chimiddleware.Compress(5))
-	r.Use(chimiddleware.Timeout(30 * time.Second))
+	r.Use(cfg.RequestTimeout.Middleware)
 
 	r.Get("/health", handlers.Payment.HealthCheck)
+	r.Method("GET", "/metrics", metrics.Handler())
+
+	if cfg.DebugEnabled {
+		r.Route("/debug", func(r chi.Router) {
+			r.Use(middleware.AdminAuth(cfg.AdminToken, logger))
+
+			r.Get("/info", handlers.Debug.Info)
+			if cfg.DebugPprof {
+				r.HandleFunc("/pprof/*", pprof.Index)
+				r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+				r.HandleFunc("/pprof/profile", pprof.Profile)
+				r.HandleFunc("/pprof/symbol", pprof.Symbol)
+				r.HandleFunc("/pprof/trace", pprof.Trace)
+			}
+		})
+	}
 
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Post("/payments", handlers.Payment.ProcessPayment)
-		r.Get("/payments", handlers.Payment.GetCustomerPayments)
-		r.Get("/customers/{customer_id}", handlers.Payment.GetCustomer)
+		r.With(cfg.PaymentSourceAllowList.Middleware, cfg.Maintenance.Middleware, cfg.Idempotency.Middleware).
+			Post("/payments", handlers.Payment.ProcessPayment)
+		r.With(cfg.PaymentSourceAllowList.Middleware, cfg.Maintenance.Middleware).
+			Post("/payments/upload", handlers.Payment.UploadPayments)
+		r.With(paymentListLimits.Middleware, cfg.ResponseCache.Middleware(middleware.CacheRoutePayments, customerFromQuery)).
+			Get("/payments", handlers.Payment.GetCustomerPayments)
+		r.Get("/payments/stream", handlers.PaymentFeed.Since)
+		r.Get("/enums", handlers.Enums.List)
+		r.Post("/customers/batch", handlers.Payment.GetCustomersBatch)
+		r.With(cfg.ResponseCache.Middleware(middleware.CacheRouteCustomer, customerFromPath)).
+			Get("/customers/{customer_id}", handlers.Payment.GetCustomer)
+		r.Get("/customers/{customer_id}/projection", handlers.Payment.GetCustomerProjection)
+		r.Get("/customers/{customer_id}/payoff-quote", handlers.Payment.GetPayoffQuote)
+		r.Get("/customers/{customer_id}/payments/weekly", handlers.Payment.GetWeeklyPayments)
+		r.With(customerPaymentLimits.Middleware, cfg.ResponseCache.Middleware(middleware.CacheRoutePayments, customerFromPath)).
+			Get("/customers/{customer_id}/payments", handlers.Payment.GetCustomerPaymentsByPath)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.AdminAuth(cfg.AdminToken, logger))
+
+			r.With(cfg.Maintenance.Middleware).Post("/customers/{customer_id}/writeoff", handlers.Admin.WriteOffCustomer)
+			r.With(cfg.Maintenance.Middleware).Patch("/customers/{customer_id}", handlers.Admin.RestructureCustomer)
+			r.With(cfg.Maintenance.Middleware).Post("/customers/reconcile-status", handlers.Admin.ReconcileCustomerStatuses)
+			r.Delete("/customers/{customer_id}/cache", handlers.Admin.EvictCustomerCache)
+			r.Delete("/cache/customers", handlers.Admin.EvictCustomerCaches)
+			r.With(pageLimits.Middleware).Get("/collections", handlers.Collections.Worklist)
+			r.Get("/maintenance", handlers.Admin.MaintenanceStatus)
+			r.Put("/maintenance", handlers.Admin.EnableMaintenance)
+			r.Delete("/maintenance", handlers.Admin.DisableMaintenance)
+			r.Get("/events/log", handlers.Admin.ReadEventLog)
+			r.Get("/events/{event_type}", handlers.Admin.InspectEvents)
+			r.Post("/events/{event_type}/claim", handlers.Admin.ClaimEvents)
+			r.Get("/workers", handlers.Admin.ListWorkers)
+			r.Get("/flags", handlers.Admin.ListFeatureFlags)
+			r.Get("/flags/{name}", handlers.Admin.GetFeatureFlag)
+			r.Put("/flags/{name}", handlers.Admin.SetFeatureFlag)
+			r.Delete("/flags/{name}", handlers.Admin.DeleteFeatureFlag)
+		})
+	})
+
+	// v2 only adds read routes with new response shapes; v1 stays as is
+	r.Route("/api/v2", func(r chi.Router) {
+		r.With(pageLimits.Middleware, cfg.ResponseCache.Middleware(middleware.CacheRoutePayments, customerFromQuery)).
+			Get("/payments", handlers.PaymentV2.GetCustomerPayments)
+		r.With(cfg.ResponseCache.Middleware(middleware.CacheRouteCustomer, customerFromPath)).
+			Get("/customers/{customer_id}", handlers.PaymentV2.GetCustomer)
 	})
 
 	return r
 }
+
+// customerFromPath and customerFromQuery scope cached responses to the
+// customer they describe, normalized the way the service looks them up
+func customerFromPath(r *http.Request) string {
+	return service.NormalizeCustomerID(chi.URLParam(r, "customer_id"))
+}
+
+func customerFromQuery(r *http.Request) string {
+	return service.NormalizeCustomerID(r.URL.Query().Get("customer_id"))
+}
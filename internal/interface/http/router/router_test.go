@@ -0,0 +1,122 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/buildinfo"
+	"github.com/gigmile/payment-service/internal/interface/http/dto"
+	"github.com/gigmile/payment-service/internal/interface/http/dtov2"
+	"github.com/gigmile/payment-service/internal/interface/http/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testAdminToken = "s3cret"
+
+func newTestRouter(cfg Config) http.Handler {
+	cfg.AdminToken = testAdminToken
+	handlers := &handler.Handlers{Debug: handler.NewDebugHandler(time.Now().Add(-time.Minute))}
+	return NewRouter(handlers, cfg, zap.NewNop())
+}
+
+func get(r http.Handler, path string, authorized bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if authorized {
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDebugRoutes_NotFoundWhenDisabled(t *testing.T) {
+	r := newTestRouter(Config{})
+
+	assert.Equal(t, http.StatusNotFound, get(r, "/debug/info", true).Code)
+	assert.Equal(t, http.StatusNotFound, get(r, "/debug/pprof/", true).Code)
+}
+
+func TestDebugInfo_WhenEnabled(t *testing.T) {
+	r := newTestRouter(Config{DebugEnabled: true})
+
+	assert.Equal(t, http.StatusUnauthorized, get(r, "/debug/info", false).Code)
+
+	rec := get(r, "/debug/info", true)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var info dto.DebugInfoResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, buildinfo.Version, info.Version)
+	assert.Equal(t, buildinfo.Commit, info.Commit)
+	assert.NotEmpty(t, info.GoVersion)
+	assert.Positive(t, info.Goroutines)
+	assert.GreaterOrEqual(t, info.UptimeSeconds, int64(60))
+
+	assert.Equal(t, http.StatusNotFound, get(r, "/debug/pprof/", true).Code, "pprof needs its own flag")
+}
+
+func TestDebugPprof_WhenEnabled(t *testing.T) {
+	r := newTestRouter(Config{DebugEnabled: true, DebugPprof: true})
+
+	assert.Equal(t, http.StatusUnauthorized, get(r, "/debug/pprof/", false).Code)
+	assert.Equal(t, http.StatusOK, get(r, "/debug/pprof/", true).Code)
+	assert.Equal(t, http.StatusOK, get(r, "/debug/pprof/goroutine?debug=1", true).Code)
+}
+
+func TestListRoutes_EnforceQueryLimits(t *testing.T) {
+	r := newTestRouter(Config{MaxPaymentDateRange: 24 * time.Hour})
+
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/payments?customer_id=GIG00001&page_size=101", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/payments?from=2025-01-01&to=2025-01-03", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/payments", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v2/payments?customer_id=GIG00001&page_size=101", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/customers/GIG00001/payments?page_size=101", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/customers/GIG00001/payments?from=2025-01-01&to=2025-01-03", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/admin/collections?page_size=101", true).Code)
+}
+
+func TestListRoutes_ConfiguredMaxPageSize(t *testing.T) {
+	r := newTestRouter(Config{MaxPageSize: 20})
+
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/payments?customer_id=GIG00001&page_size=21", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v2/payments?customer_id=GIG00001&page_size=21", false).Code)
+	assert.Equal(t, http.StatusBadRequest, get(r, "/api/v1/admin/collections?page_size=21", true).Code)
+	assert.NotEqual(t, http.StatusBadRequest, get(r, "/api/v1/customers/GIG00001/payments?page_size=20", false).Code)
+}
+
+func TestV2Routes_MiddlewareErrorsUseTheEnvelope(t *testing.T) {
+	r := newTestRouter(Config{})
+
+	rec := get(r, "/api/v2/payments?customer_id=GIG00001&page_size=101", false)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var envelope dtov2.Envelope
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envelope))
+	assert.Nil(t, envelope.Data)
+	require.NotNil(t, envelope.Error)
+	assert.Equal(t, dto.ErrorCodeBadRequest, envelope.Error.Code)
+	assert.Contains(t, envelope.Error.Error, "page_size")
+
+	// Errors from middleware ahead of the route group are wrapped too
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/payments?customer_id=GIG00001", nil)
+	req.Header.Set("X-Request-Timeout", "0")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	envelope = dtov2.Envelope{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envelope))
+	require.NotNil(t, envelope.Error)
+	assert.Contains(t, envelope.Error.Error, "X-Request-Timeout")
+
+	// v1 keeps its bare error shape
+	rec = get(r, "/api/v1/payments?customer_id=GIG00001&page_size=101", false)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var errResp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	assert.Contains(t, errResp["error"], "page_size")
+	assert.NotContains(t, errResp, "data")
+}
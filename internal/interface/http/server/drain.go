@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultDrainLogInterval is how often Shutdown reports progress when
+// Drain.LogInterval isn't set
+const DefaultDrainLogInterval = time.Second
+
+// Drain counts a server's open connections through its ConnState hook so
+// shutdown can report how many requests are still being served, and
+// whether any had to be cut off
+type Drain struct {
+	srv    *http.Server
+	logger *zap.Logger
+
+	// LogInterval is how often Shutdown logs the connections left; zero
+	// uses DefaultDrainLogInterval
+	LogInterval time.Duration
+
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+// NewDrain installs the tracking hook on srv, keeping any ConnState hook it
+// already has. Call it before the server starts serving.
+func NewDrain(srv *http.Server, logger *zap.Logger) *Drain {
+	d := &Drain{
+		srv:    srv,
+		logger: logger,
+		conns:  make(map[net.Conn]http.ConnState),
+	}
+
+	next := srv.ConnState
+	srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		d.track(conn, state)
+		if next != nil {
+			next(conn, state)
+		}
+	}
+	return d
+}
+
+func (d *Drain) track(conn net.Conn, state http.ConnState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(d.conns, conn)
+	default:
+		d.conns[conn] = state
+	}
+}
+
+// Remaining returns how many connections are open, and how many of those
+// are mid-request
+func (d *Drain) Remaining() (open, active int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, state := range d.conns {
+		if state == http.StateActive {
+			active++
+		}
+	}
+	return len(d.conns), active
+}
+
+// Shutdown stops the server accepting connections and waits for the open
+// ones to finish until ctx is done, logging how many are left every
+// LogInterval. If ctx ends first, the remaining connections are closed
+// and ctx's error is returned.
+func (d *Drain) Shutdown(ctx context.Context) error {
+	interval := d.LogInterval
+	if interval <= 0 {
+		interval = DefaultDrainLogInterval
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.srv.Shutdown(ctx) }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				d.logger.Info("all connections drained")
+				return nil
+			}
+			open, active := d.Remaining()
+			d.logger.Error("shutdown timed out, forcibly closing remaining connections",
+				zap.Int("open_connections", open),
+				zap.Int("active_requests", active),
+				zap.Error(err))
+			d.srv.Close()
+			return err
+		case <-ticker.C:
+			open, active := d.Remaining()
+			d.logger.Info("draining connections",
+				zap.Int("open_connections", open),
+				zap.Int("active_requests", active))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// startSlowServer serves a handler that takes hold to respond, and returns
+// once a request is in flight
+func startSlowServer(t *testing.T, hold time.Duration) (*http.Server, *Drain, *observer.ObservedLogs, <-chan error) {
+	t.Helper()
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-time.After(hold):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})}
+	core, logs := observer.New(zapcore.InfoLevel)
+	drain := NewDrain(srv, zap.New(core))
+	drain.LogInterval = 10 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-started
+	return srv, drain, logs, requestErr
+}
+
+func TestDrain_WaitsForSlowRequest(t *testing.T) {
+	_, drain, logs, requestErr := startSlowServer(t, 100*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, drain.Shutdown(ctx))
+
+	assert.NoError(t, <-requestErr)
+	open, active := drain.Remaining()
+	assert.Zero(t, open)
+	assert.Zero(t, active)
+
+	progress := logs.FilterMessage("draining connections").All()
+	require.NotEmpty(t, progress)
+	assert.Equal(t, int64(1), progress[0].ContextMap()["active_requests"])
+	assert.Equal(t, 1, logs.FilterMessage("all connections drained").Len())
+	assert.Zero(t, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+}
+
+func TestDrain_TimeoutClosesRemainingConnections(t *testing.T) {
+	_, drain, logs, requestErr := startSlowServer(t, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := drain.Shutdown(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	// The slow request was cut off rather than answered
+	assert.Error(t, <-requestErr)
+
+	residual := logs.FilterMessage("shutdown timed out, forcibly closing remaining connections").All()
+	require.Len(t, residual, 1)
+	assert.Equal(t, zapcore.ErrorLevel, residual[0].Level)
+	assert.Equal(t, int64(1), residual[0].ContextMap()["open_connections"])
+	assert.Equal(t, int64(1), residual[0].ContextMap()["active_requests"])
+	assert.Zero(t, logs.FilterMessage("all connections drained").Len())
+}
+
+func TestNewDrain_KeepsExistingConnStateHook(t *testing.T) {
+	var seen []http.ConnState
+	srv := &http.Server{ConnState: func(_ net.Conn, state http.ConnState) { seen = append(seen, state) }}
+	drain := NewDrain(srv, zap.NewNop())
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	srv.ConnState(conn, http.StateNew)
+	srv.ConnState(conn, http.StateActive)
+	open, active := drain.Remaining()
+	assert.Equal(t, 1, open)
+	assert.Equal(t, 1, active)
+
+	srv.ConnState(conn, http.StateClosed)
+	open, _ = drain.Remaining()
+	assert.Zero(t, open)
+	assert.Equal(t, []http.ConnState{http.StateNew, http.StateActive, http.StateClosed}, seen)
+}
@@ -0,0 +1,14 @@
+package server
+
+import "time"
+
+// WriteTimeoutHeadroom is how long past the longest request deadline the
+// server keeps a response open, for the handler to write its answer
+const WriteTimeoutHeadroom = 10 * time.Second
+
+// WriteTimeout is the http.Server WriteTimeout for requests that may run
+// for up to maxRequestTimeout. Any shorter and the server would cut off
+// responses the request deadline still allows.
+func WriteTimeout(maxRequestTimeout time.Duration) time.Duration {
+	return maxRequestTimeout + WriteTimeoutHeadroom
+}
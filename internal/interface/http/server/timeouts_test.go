@@ -0,0 +1,35 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTimeout_OutlastsTheLongestRequest(t *testing.T) {
+	assert.Equal(t, 40*time.Second, WriteTimeout(30*time.Second))
+	assert.Greater(t, WriteTimeout(2*time.Minute), 2*time.Minute)
+}
+
+func TestWriteTimeout_ResponseAtTheDeadlineIsDelivered(t *testing.T) {
+	const maxRequestTimeout = 200 * time.Millisecond
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(maxRequestTimeout)
+		io.WriteString(w, "done")
+	}))
+	srv.Config.WriteTimeout = WriteTimeout(maxRequestTimeout)
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "done", string(body))
+}
@@ -0,0 +1,288 @@
+// Package testutil provides in-memory stand-ins for the MySQL and Redis
+// repositories, so services can be tested end to end, including under
+// concurrency, without either store running
+package testutil
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/google/uuid"
+)
+
+// MemoryCustomerRepository is a domain.CustomerRepository and
+// domain.CustomerLister backed by a map. Like the MySQL repository it hands
+// out copies and only saves a customer whose Version matches the stored
+// one, returning domain.ErrOptimisticLock otherwise. It also refuses to save
+// a customer that fails Validate, so tests catch broken invariants at once.
+type MemoryCustomerRepository struct {
+	mu        sync.Mutex
+	customers map[string]*domain.Customer
+}
+
+// NewMemoryCustomerRepository returns a repository holding copies of customers
+func NewMemoryCustomerRepository(customers ...*domain.Customer) *MemoryCustomerRepository {
+	r := &MemoryCustomerRepository{customers: make(map[string]*domain.Customer, len(customers))}
+	for _, c := range customers {
+		r.customers[c.ID] = copyCustomer(c)
+	}
+	return r
+}
+
+func copyCustomer(c *domain.Customer) *domain.Customer {
+	copied := *c
+	if c.LastPaymentDate != nil {
+		paidAt := *c.LastPaymentDate
+		copied.LastPaymentDate = &paidAt
+	}
+	return &copied
+}
+
+func (r *MemoryCustomerRepository) FindByID(ctx context.Context, customerID string) (*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.customers[customerID]
+	if !ok {
+		return nil, domain.ErrCustomerNotFound
+	}
+	return copyCustomer(c), nil
+}
+
+func (r *MemoryCustomerRepository) FindByIDs(ctx context.Context, customerIDs []string) (map[string]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := make(map[string]*domain.Customer, len(customerIDs))
+	for _, id := range customerIDs {
+		if c, ok := r.customers[id]; ok {
+			found[id] = copyCustomer(c)
+		}
+	}
+	return found, nil
+}
+
+// FindByStatus pages customers in the status in ID order
+func (r *MemoryCustomerRepository) FindByStatus(ctx context.Context, status string, limit, offset int) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []*domain.Customer
+	for _, c := range r.customers {
+		if string(c.Status) == status {
+			matched = append(matched, copyCustomer(c))
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	return page(matched, limit, offset), nil
+}
+
+// Save stores customer and bumps its Version. An unknown customer is a
+// version mismatch too, as with the MySQL repository's conditional update.
+func (r *MemoryCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	if err := customer.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.customers[customer.ID]
+	if !ok || current.Version != customer.Version {
+		return domain.ErrOptimisticLock
+	}
+	customer.Version++
+	r.customers[customer.ID] = copyCustomer(customer)
+	return nil
+}
+
+// UpdateBalance applies amount at the given version without loading the
+// customer, leaving the status alone like the MySQL repository does
+func (r *MemoryCustomerRepository) UpdateBalance(ctx context.Context, customerID string, amount int64, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.customers[customerID]
+	if !ok || current.Version != version {
+		return domain.ErrOptimisticLock
+	}
+	current.OutstandingBalance -= amount
+	current.TotalPaid += amount
+	current.Version++
+	return nil
+}
+
+// MemoryPaymentRepository is a domain.PaymentRepository and
+// domain.PaymentFeed backed by a map
+// keyed by transaction reference, which is unique across customers as in
+// MySQL's default dedup scope: saving a reference twice returns
+// domain.ErrDuplicateTransaction.
+type MemoryPaymentRepository struct {
+	mu       sync.Mutex
+	payments map[string]*domain.Payment
+}
+
+// NewMemoryPaymentRepository returns a repository holding copies of payments
+func NewMemoryPaymentRepository(payments ...*domain.Payment) *MemoryPaymentRepository {
+	r := &MemoryPaymentRepository{payments: make(map[string]*domain.Payment, len(payments))}
+	for _, p := range payments {
+		copied := *p
+		r.payments[p.TransactionReference] = &copied
+	}
+	return r
+}
+
+// Save stores payment, giving it an ID first if it has none
+func (r *MemoryPaymentRepository) Save(ctx context.Context, payment *domain.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.payments[payment.TransactionReference]; ok {
+		return domain.ErrDuplicateTransaction
+	}
+	if payment.ID == "" {
+		payment.ID = uuid.New().String()
+	}
+	copied := *payment
+	r.payments[payment.TransactionReference] = &copied
+	return nil
+}
+
+func (r *MemoryPaymentRepository) FindByTransactionReference(ctx context.Context, customerID, txRef string) (*domain.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.payments[txRef]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+	copied := *p
+	return &copied, nil
+}
+
+func (r *MemoryPaymentRepository) ExistsByTransactionReference(ctx context.Context, customerID, txRef string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.payments[txRef]
+	return ok, nil
+}
+
+// matching returns copies of the payments keep accepts, newest first with
+// ties broken by ID
+func (r *MemoryPaymentRepository) matching(keep func(*domain.Payment) bool) []*domain.Payment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payments := []*domain.Payment{}
+	for _, p := range r.payments {
+		if keep(p) {
+			copied := *p
+			payments = append(payments, &copied)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if payments[i].TransactionDate.Equal(payments[j].TransactionDate) {
+			return payments[i].ID > payments[j].ID
+		}
+		return payments[i].TransactionDate.After(payments[j].TransactionDate)
+	})
+	return payments
+}
+
+func (r *MemoryPaymentRepository) byCustomer(customerID string) []*domain.Payment {
+	return r.matching(func(p *domain.Payment) bool { return p.CustomerID == customerID })
+}
+
+// inDateRange lists the range newest first unless filter says otherwise
+func (r *MemoryPaymentRepository) inDateRange(from, to time.Time, customerID string, filter domain.PaymentFilter) []*domain.Payment {
+	payments := r.matching(func(p *domain.Payment) bool {
+		if p.TransactionDate.Before(from) || p.TransactionDate.After(to) {
+			return false
+		}
+		return (customerID == "" || p.CustomerID == customerID) && filter.Matches(p)
+	})
+	if filter.Order == domain.PaymentOrderAsc {
+		slices.Reverse(payments)
+	}
+	return payments
+}
+
+func (r *MemoryPaymentRepository) FindByCustomerID(ctx context.Context, customerID string) ([]*domain.Payment, error) {
+	return r.byCustomer(customerID), nil
+}
+
+func (r *MemoryPaymentRepository) FindByCustomerIDFiltered(ctx context.Context, customerID string, filter domain.PaymentFilter) ([]*domain.Payment, error) {
+	payments := r.matching(func(p *domain.Payment) bool {
+		return p.CustomerID == customerID && filter.Matches(p)
+	})
+	if filter.Order == domain.PaymentOrderAsc {
+		slices.Reverse(payments)
+	}
+	return payments, nil
+}
+
+func (r *MemoryPaymentRepository) FindByCustomerIDWithPagination(ctx context.Context, customerID string, limit, offset int) ([]*domain.Payment, error) {
+	return page(r.byCustomer(customerID), limit, offset), nil
+}
+
+func (r *MemoryPaymentRepository) CountByCustomerID(ctx context.Context, customerID string) (int64, error) {
+	return int64(len(r.byCustomer(customerID))), nil
+}
+
+func (r *MemoryPaymentRepository) FindPageByCustomerID(ctx context.Context, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, int64, error) {
+	payments, _ := r.FindByCustomerIDFiltered(ctx, customerID, filter)
+	return page(payments, limit, offset), int64(len(payments)), nil
+}
+
+func (r *MemoryPaymentRepository) FindByCustomerIDAfter(ctx context.Context, customerID string, filter domain.PaymentFilter, afterDate time.Time, afterID string, limit int) ([]*domain.Payment, error) {
+	filter.Order = filter.OrderOr(domain.PaymentOrderAsc)
+	payments, _ := r.FindByCustomerIDFiltered(ctx, customerID, filter)
+	if afterDate.IsZero() && afterID == "" {
+		return page(payments, limit, 0), nil
+	}
+	var after []*domain.Payment
+	for _, p := range payments {
+		past := p.TransactionDate.After(afterDate) || (p.TransactionDate.Equal(afterDate) && p.ID > afterID)
+		if filter.Order == domain.PaymentOrderDesc {
+			past = p.TransactionDate.Before(afterDate) || (p.TransactionDate.Equal(afterDate) && p.ID < afterID)
+		}
+		if past {
+			after = append(after, p)
+		}
+	}
+	return page(after, limit, 0), nil
+}
+
+func (r *MemoryPaymentRepository) FindByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter, limit, offset int) ([]*domain.Payment, error) {
+	return page(r.inDateRange(from, to, customerID, filter), limit, offset), nil
+}
+
+func (r *MemoryPaymentRepository) TotalsByDateRange(ctx context.Context, from, to time.Time, customerID string, filter domain.PaymentFilter) (domain.PaymentTotals, error) {
+	var totals domain.PaymentTotals
+	for _, p := range r.inDateRange(from, to, customerID, filter) {
+		totals.Count++
+		totals.Amount += p.Amount
+	}
+	return totals, nil
+}
+
+func (r *MemoryPaymentRepository) FindRecordedAfter(ctx context.Context, afterTime time.Time, afterID string, until time.Time, limit int) ([]*domain.Payment, error) {
+	payments := r.matching(func(p *domain.Payment) bool {
+		if !p.CreatedAt.Before(until) {
+			return false
+		}
+		return p.CreatedAt.After(afterTime) || (p.CreatedAt.Equal(afterTime) && p.ID > afterID)
+	})
+	sort.Slice(payments, func(i, j int) bool {
+		if payments[i].CreatedAt.Equal(payments[j].CreatedAt) {
+			return payments[i].ID < payments[j].ID
+		}
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+	return page(payments, limit, 0), nil
+}
+
+// page slices out limit items starting at offset, as LIMIT/OFFSET would
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := min(offset+limit, len(items))
+	return items[offset:end]
+}
@@ -0,0 +1,146 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gigmile/payment-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCustomer() *domain.Customer {
+	return &domain.Customer{
+		ID:                 "GIG00001",
+		AssetValue:         1000,
+		RepaymentTermWeeks: 10,
+		OutstandingBalance: 1000,
+		Status:             domain.CustomerStatusActive,
+		Version:            1,
+	}
+}
+
+func TestMemoryCustomerRepository_OptimisticLock(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryCustomerRepository(newCustomer())
+
+	first, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	second, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+
+	require.NoError(t, first.ApplyPayment(100, time.Now()))
+	require.NoError(t, repo.Save(ctx, first))
+	assert.Equal(t, int64(2), first.Version)
+
+	require.NoError(t, second.ApplyPayment(200, time.Now()))
+	assert.ErrorIs(t, repo.Save(ctx, second), domain.ErrOptimisticLock, "saved from a stale version")
+
+	stored, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), stored.TotalPaid)
+	assert.Equal(t, int64(2), stored.Version)
+
+	assert.ErrorIs(t, repo.UpdateBalance(ctx, "GIG00001", 50, 1), domain.ErrOptimisticLock)
+	require.NoError(t, repo.UpdateBalance(ctx, "GIG00001", 50, 2))
+	stored, _ = repo.FindByID(ctx, "GIG00001")
+	assert.Equal(t, int64(150), stored.TotalPaid)
+	assert.Equal(t, int64(3), stored.Version)
+}
+
+func TestMemoryCustomerRepository_HandsOutCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryCustomerRepository(newCustomer())
+
+	c, err := repo.FindByID(ctx, "GIG00001")
+	require.NoError(t, err)
+	c.TotalPaid = 999
+
+	stored, _ := repo.FindByID(ctx, "GIG00001")
+	assert.Zero(t, stored.TotalPaid)
+
+	_, err = repo.FindByID(ctx, "GIG99999")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	assert.ErrorIs(t, repo.Save(ctx, &domain.Customer{ID: "GIG99999", AssetValue: 1, OutstandingBalance: 1, Status: domain.CustomerStatusActive}), domain.ErrOptimisticLock)
+}
+
+func TestMemoryCustomerRepository_RejectsBrokenInvariants(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryCustomerRepository(newCustomer())
+
+	c, _ := repo.FindByID(ctx, "GIG00001")
+	c.TotalPaid = 100
+
+	assert.ErrorIs(t, repo.Save(ctx, c), domain.ErrInvariantViolated)
+}
+
+func TestMemoryCustomerRepository_ConcurrentSavesNeverLoseUpdates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryCustomerRepository(newCustomer())
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := repo.FindByID(ctx, "GIG00001")
+			if err != nil {
+				return
+			}
+			c.ApplyPayment(10, time.Now())
+			if repo.Save(ctx, c) == nil {
+				mu.Lock()
+				saved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, _ := repo.FindByID(ctx, "GIG00001")
+	assert.Equal(t, int64(10*saved), stored.TotalPaid, "only saves from the current version land")
+	assert.Equal(t, int64(1+saved), stored.Version)
+}
+
+func TestMemoryPaymentRepository(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2025, 11, 24, 0, 0, 0, 0, time.UTC)
+	repo := NewMemoryPaymentRepository()
+
+	for i, ref := range []string{"TXN001", "TXN002", "TXN003"} {
+		require.NoError(t, repo.Save(ctx, &domain.Payment{CustomerID: "GIG00001", Amount: 100, TransactionReference: ref, TransactionDate: day.Add(time.Duration(i) * time.Hour), Status: domain.PaymentStatusComplete}))
+	}
+	require.NoError(t, repo.Save(ctx, &domain.Payment{CustomerID: "GIG00002", Amount: 500, TransactionReference: "TXN004", TransactionDate: day}))
+
+	assert.ErrorIs(t, repo.Save(ctx, &domain.Payment{CustomerID: "GIG00002", TransactionReference: "TXN001"}), domain.ErrDuplicateTransaction)
+
+	stored, err := repo.FindByTransactionReference(ctx, "GIG00001", "TXN001")
+	require.NoError(t, err)
+	assert.NotEmpty(t, stored.ID)
+	assert.Equal(t, "GIG00001", stored.CustomerID)
+	_, err = repo.FindByTransactionReference(ctx, "GIG00001", "TXN999")
+	assert.ErrorIs(t, err, domain.ErrPaymentNotFound)
+
+	payments, err := repo.FindByCustomerID(ctx, "GIG00001")
+	require.NoError(t, err)
+	require.Len(t, payments, 3)
+	assert.Equal(t, "TXN003", payments[0].TransactionReference, "newest first")
+
+	paged, err := repo.FindByCustomerIDWithPagination(ctx, "GIG00001", 2, 2)
+	require.NoError(t, err)
+	require.Len(t, paged, 1)
+	assert.Equal(t, "TXN001", paged[0].TransactionReference)
+
+	after, err := repo.FindByCustomerIDAfter(ctx, "GIG00001", domain.PaymentFilter{}, day, stored.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, after, 2)
+	assert.Equal(t, "TXN002", after[0].TransactionReference, "oldest first after the key")
+
+	totals, err := repo.TotalsByDateRange(ctx, day, day.Add(time.Hour), "", domain.PaymentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentTotals{Count: 3, Amount: 700}, totals)
+}
@@ -0,0 +1,3 @@
+-- Composite index backing keyset pagination over a customer's payments,
+-- ordered by (transaction_date, id)
+CREATE INDEX idx_payments_customer_date_id ON payments (customer_id, transaction_date, id);
@@ -0,0 +1,10 @@
+-- Tracks the schema version applied; the API can refuse to start when it
+-- doesn't match persistence.SchemaVersion. Every later migration records
+-- its own number; 001 and 002 ran before this table existed, so they are
+-- recorded here.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (1), (2), (3);
@@ -0,0 +1,5 @@
+-- Outstanding balance each payment left the customer with. Rows recorded
+-- before it was captured stay NULL.
+ALTER TABLE payments ADD COLUMN balance_after BIGINT NULL;
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (4);
@@ -0,0 +1,6 @@
+-- ISO 4217 currency of each loan and payment. Everything recorded before
+-- currencies were tracked is in naira.
+ALTER TABLE customers ADD COLUMN currency_code CHAR(3) NOT NULL DEFAULT 'NGN';
+ALTER TABLE payments ADD COLUMN currency_code CHAR(3) NOT NULL DEFAULT 'NGN';
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (5);
@@ -0,0 +1,5 @@
+-- Index backing the payment feed, which walks every customer's payments
+-- in the order they were recorded, (created_at, id)
+CREATE INDEX idx_payments_created_id ON payments (created_at, id);
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (6);
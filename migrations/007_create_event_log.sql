@@ -0,0 +1,16 @@
+-- Every published domain event, in the order it was logged. Rows are never
+-- updated or deleted, whatever the event streams keep.
+CREATE TABLE IF NOT EXISTS event_log (
+    sequence BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id VARCHAR(100) NOT NULL,
+    payload JSON NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+
+    UNIQUE INDEX idx_event_log_event_id (event_id),
+    INDEX idx_event_log_event_type (event_type)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (7);
@@ -0,0 +1,5 @@
+-- Free-form key/value pairs the provider sent with a payment. NULL when
+-- none were sent.
+ALTER TABLE payments ADD COLUMN metadata JSON NULL;
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (8);
@@ -0,0 +1,8 @@
+-- Transaction references are always unique per customer. By default they
+-- are also unique across customers, through the column's own unique index.
+-- With PAYMENT_DEDUP_SCOPE=customer, cmd/migrate drops that index; to do
+-- it by hand:
+--   ALTER TABLE payments DROP INDEX transaction_reference;
+CREATE UNIQUE INDEX idx_payments_customer_ref ON payments (customer_id, transaction_reference);
+
+INSERT IGNORE INTO schema_migrations (version) VALUES (9);